@@ -0,0 +1,211 @@
+package blockstore
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	dshelp "github.com/ipfs/go-ipfs/thirdparty/ds-help"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// AccessTimePrefix namespaces the access times of blocks in the datastore.
+var AccessTimePrefix = ds.NewKey("/local/atime")
+
+// accessFlushInterval is how often the recorded access times are written
+// to the datastore.
+var accessFlushInterval = time.Minute
+
+// AccessTracker is a Blockstore that records when blocks were last read, so
+// that the least recently used blocks can be evicted first.
+//
+// The times are coarse: they are truncated to the tracker granularity, kept
+// in memory, and written to the datastore in batches. A block read again
+// within the same period costs no write.
+type AccessTracker struct {
+	Blockstore
+
+	ds          ds.Batching
+	granularity time.Duration
+
+	lk      sync.Mutex
+	pending map[string]time.Time
+}
+
+// NewAccessTracker wraps bs to record the access times of blocks in d. The
+// pending times are written out until ctx is done.
+func NewAccessTracker(ctx context.Context, bs Blockstore, d ds.Batching, granularity time.Duration) *AccessTracker {
+	t := &AccessTracker{
+		Blockstore:  bs,
+		ds:          d,
+		granularity: granularity,
+		pending:     make(map[string]time.Time),
+	}
+	go t.flushLoop(ctx)
+	return t
+}
+
+func (t *AccessTracker) Get(k *cid.Cid) (blocks.Block, error) {
+	blk, err := t.Blockstore.Get(k)
+	if err == nil {
+		t.touch(k)
+	}
+	return blk, err
+}
+
+func (t *AccessTracker) Put(b blocks.Block) error {
+	if err := t.Blockstore.Put(b); err != nil {
+		return err
+	}
+	t.touch(b.Cid())
+	return nil
+}
+
+func (t *AccessTracker) PutMany(bs []blocks.Block) error {
+	if err := t.Blockstore.PutMany(bs); err != nil {
+		return err
+	}
+	for _, b := range bs {
+		t.touch(b.Cid())
+	}
+	return nil
+}
+
+func (t *AccessTracker) DeleteBlock(k *cid.Cid) error {
+	if err := t.Blockstore.DeleteBlock(k); err != nil {
+		return err
+	}
+
+	key := accessKey(k)
+	t.lk.Lock()
+	delete(t.pending, key.String())
+	t.lk.Unlock()
+
+	err := t.ds.Delete(key)
+	if err != nil && err != ds.ErrNotFound {
+		log.Warningf("removing access time of %s: %s", k, err)
+	}
+	return nil
+}
+
+func (t *AccessTracker) touch(k *cid.Cid) {
+	now := time.Now().Truncate(t.granularity)
+
+	t.lk.Lock()
+	t.pending[accessKey(k).String()] = now
+	t.lk.Unlock()
+}
+
+// LastAccess returns when the block was last read or written, or the zero
+// time if it wasn't since access times are tracked.
+func (t *AccessTracker) LastAccess(k *cid.Cid) time.Time {
+	key := accessKey(k)
+
+	t.lk.Lock()
+	at, ok := t.pending[key.String()]
+	t.lk.Unlock()
+	if ok {
+		return at
+	}
+
+	at, _ = t.stored(key)
+	return at
+}
+
+// SortLRU sorts the given cids from the least to the most recently used.
+// Blocks without an access time come first.
+func (t *AccessTracker) SortLRU(cids []*cid.Cid) {
+	times := make([]time.Time, len(cids))
+	for i, c := range cids {
+		times[i] = t.LastAccess(c)
+	}
+	sort.Sort(&byAccess{cids, times})
+}
+
+// Flush writes the pending access times to the datastore.
+func (t *AccessTracker) Flush() error {
+	t.lk.Lock()
+	pending := t.pending
+	t.pending = make(map[string]time.Time)
+	t.lk.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	b, err := t.ds.Batch()
+	if err != nil {
+		return err
+	}
+
+	for k, at := range pending {
+		key := ds.NewKey(k)
+		// most reads hit blocks read recently, skip rewriting the same time
+		if stored, ok := t.stored(key); ok && stored.Equal(at) {
+			continue
+		}
+
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, uint64(at.Unix()))
+		if err := b.Put(key, buf); err != nil {
+			return err
+		}
+	}
+	return b.Commit()
+}
+
+func (t *AccessTracker) flushLoop(ctx context.Context) {
+	tick := time.NewTicker(accessFlushInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			if err := t.Flush(); err != nil {
+				log.Error("writing block access times: ", err)
+			}
+			return
+		}
+
+		if err := t.Flush(); err != nil {
+			log.Error("writing block access times: ", err)
+		}
+	}
+}
+
+func (t *AccessTracker) stored(key ds.Key) (time.Time, bool) {
+	val, err := t.ds.Get(key)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	buf, ok := val.([]byte)
+	if !ok || len(buf) != 8 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(buf)), 0), true
+}
+
+func accessKey(k *cid.Cid) ds.Key {
+	return AccessTimePrefix.Child(dshelp.CidToDsKey(k))
+}
+
+type byAccess struct {
+	cids  []*cid.Cid
+	times []time.Time
+}
+
+func (s *byAccess) Len() int { return len(s.cids) }
+
+func (s *byAccess) Swap(i, j int) {
+	s.cids[i], s.cids[j] = s.cids[j], s.cids[i]
+	s.times[i], s.times[j] = s.times[j], s.times[i]
+}
+
+func (s *byAccess) Less(i, j int) bool { return s.times[i].Before(s.times[j]) }
@@ -0,0 +1,77 @@
+package blockstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	ds_sync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+func TestAccessTracker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := ds_sync.MutexWrap(ds.NewMapDatastore())
+	at := NewAccessTracker(ctx, NewBlockstore(d), d, time.Second)
+
+	old := blocks.NewBlock([]byte("old"))
+	recent := blocks.NewBlock([]byte("recent"))
+	untracked := blocks.NewBlock([]byte("untracked"))
+
+	// blocks stored before tracking was enabled have no access time
+	if err := at.Blockstore.Put(untracked); err != nil {
+		t.Fatal(err)
+	}
+	if err := at.Put(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := at.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// access times are truncated to the granularity
+	time.Sleep(time.Second)
+	if _, err := at.Get(recent.Cid()); err == nil {
+		t.Fatal("expected block to be missing")
+	}
+	if err := at.Put(recent); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := at.Get(recent.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !at.LastAccess(old.Cid()).Before(at.LastAccess(recent.Cid())) {
+		t.Fatal("old block should have an older access time")
+	}
+	if !at.LastAccess(untracked.Cid()).IsZero() {
+		t.Fatal("untracked block should have no access time")
+	}
+
+	cids := []*cid.Cid{recent.Cid(), old.Cid(), untracked.Cid()}
+	at.SortLRU(cids)
+	if !cids[0].Equals(untracked.Cid()) || !cids[1].Equals(old.Cid()) || !cids[2].Equals(recent.Cid()) {
+		t.Fatalf("wrong eviction order: %s", cids)
+	}
+
+	// the times survive a restart once flushed
+	if err := at.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	at2 := NewAccessTracker(ctx, NewBlockstore(d), d, time.Second)
+	if !at2.LastAccess(recent.Cid()).Equal(at.LastAccess(recent.Cid())) {
+		t.Fatal("access time was not persisted")
+	}
+
+	if err := at2.DeleteBlock(recent.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if !at2.LastAccess(recent.Cid()).IsZero() {
+		t.Fatal("access time of deleted block should be removed")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"context"
 	"errors"
 	"sync"
-	"sync/atomic"
 
 	blocks "github.com/ipfs/go-ipfs/blocks"
 	dshelp "github.com/ipfs/go-ipfs/thirdparty/ds-help"
@@ -51,18 +50,25 @@ type Blockstore interface {
 	HashOnRead(enabled bool)
 }
 
+// ErrBlockInUse is returned when deleting, during a garbage collection, a
+// block used by a sequence of puts expected to finish with a pin.
+var ErrBlockInUse = errors.New("blockstore: block in use by a pin in progress")
+
 // GCLocker abstract functionality to lock a blockstore when performing
 // garbage-collection operations.
 type GCLocker interface {
-	// GCLock locks the blockstore for garbage collection. No operations
-	// that expect to finish with a pin should ocurr simultaneously.
-	// Reading during GC is safe, and requires no lock.
+	// GCLock locks the blockstore for garbage collection. Garbage
+	// collections happen one at a time, but don't wait for the pins in
+	// progress: while the lock is held, the blocks these used can't be
+	// deleted, see PinLock. Reading during GC is safe, and requires no lock.
 	GCLock() Unlocker
 
 	// PinLock locks the blockstore for sequences of puts expected to finish
 	// with a pin (before GC). Multiple put->pin sequences can write through
-	// at the same time, but no GC should not happen simulatenously.
-	// Reading during Pinning is safe, and requires no lock.
+	// at the same time, and with GC: the blocks read or written through the
+	// GCBlockstore while the lock is held are protected from the garbage
+	// collections, which fail to delete them with ErrBlockInUse, until the
+	// sequence ends and the garbage collection running then, if any, ends.
 	PinLock() Unlocker
 
 	// GcRequested returns true if GCLock has been called and is waiting to
@@ -239,18 +245,6 @@ func (bs *blockstore) AllKeysChan(ctx context.Context) (<-chan *cid.Cid, error)
 	return output, nil
 }
 
-// NewGCLocker returns a default implementation of
-// GCLocker using standard [RW] mutexes.
-func NewGCLocker() GCLocker {
-	return &gclocker{}
-}
-
-type gclocker struct {
-	lk      sync.RWMutex
-	gcreq   int32
-	gcreqlk sync.Mutex
-}
-
 // Unlocker represents an object which can Unlock
 // something.
 type Unlocker interface {
@@ -265,19 +259,3 @@ func (u *unlocker) Unlock() {
 	u.unlock()
 	u.unlock = nil // ensure its not called twice
 }
-
-func (bs *gclocker) GCLock() Unlocker {
-	atomic.AddInt32(&bs.gcreq, 1)
-	bs.lk.Lock()
-	atomic.AddInt32(&bs.gcreq, -1)
-	return &unlocker{bs.lk.Unlock}
-}
-
-func (bs *gclocker) PinLock() Unlocker {
-	bs.lk.RLock()
-	return &unlocker{bs.lk.RUnlock}
-}
-
-func (bs *gclocker) GCRequested() bool {
-	return atomic.LoadInt32(&bs.gcreq) > 0
-}
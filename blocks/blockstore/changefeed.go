@@ -0,0 +1,198 @@
+package blockstore
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// ChangeFeedPrefix namespaces the log of added blocks in the datastore.
+var ChangeFeedPrefix = ds.NewKey("/local/changefeed")
+
+var changeFeedHeadKey = ChangeFeedPrefix.ChildString("head")
+
+// Change is a block added to a ChangeFeed. Seq numbers the changes from 1, in
+// the order the blocks were added.
+type Change struct {
+	Seq uint64
+	Cid *cid.Cid
+}
+
+// ChangeFeed is a Blockstore that logs the blocks added to it, so that
+// indexers and replicas can follow the new blocks from a cursor, the Seq of
+// the last change they saw. Blocks that were already stored aren't logged
+// again, and removed blocks stay in the log.
+type ChangeFeed struct {
+	Blockstore
+
+	ds ds.Batching
+
+	lk     sync.Mutex
+	head   uint64
+	notify chan struct{}
+}
+
+// NewChangeFeed wraps bs to log its new blocks in d, after the ones logged
+// before.
+func NewChangeFeed(bs Blockstore, d ds.Batching) (*ChangeFeed, error) {
+	f := &ChangeFeed{
+		Blockstore: bs,
+		ds:         d,
+		notify:     make(chan struct{}),
+	}
+
+	val, err := d.Get(changeFeedHeadKey)
+	switch err {
+	case nil:
+		buf, ok := val.([]byte)
+		if !ok || len(buf) != 8 {
+			return nil, fmt.Errorf("invalid change feed head")
+		}
+		f.head = binary.BigEndian.Uint64(buf)
+	case ds.ErrNotFound:
+	default:
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *ChangeFeed) Put(b blocks.Block) error {
+	has, err := f.Blockstore.Has(b.Cid())
+	if err != nil {
+		return err
+	}
+	if err := f.Blockstore.Put(b); err != nil {
+		return err
+	}
+	if has {
+		return nil
+	}
+	return f.log([]*cid.Cid{b.Cid()})
+}
+
+func (f *ChangeFeed) PutMany(bs []blocks.Block) error {
+	var added []*cid.Cid
+	seen := make(map[string]bool, len(bs))
+	for _, b := range bs {
+		k := b.Cid().KeyString()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		has, err := f.Blockstore.Has(b.Cid())
+		if err != nil {
+			return err
+		}
+		if !has {
+			added = append(added, b.Cid())
+		}
+	}
+
+	if err := f.Blockstore.PutMany(bs); err != nil {
+		return err
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	return f.log(added)
+}
+
+// log appends cs to the log, and wakes up the waiting readers.
+func (f *ChangeFeed) log(cs []*cid.Cid) error {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	b, err := f.ds.Batch()
+	if err != nil {
+		return err
+	}
+
+	head := f.head
+	for _, c := range cs {
+		head++
+		if err := b.Put(changeKey(head), c.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, head)
+	if err := b.Put(changeFeedHeadKey, buf); err != nil {
+		return err
+	}
+	if err := b.Commit(); err != nil {
+		return err
+	}
+
+	f.head = head
+	close(f.notify)
+	f.notify = make(chan struct{})
+	return nil
+}
+
+// Head returns the Seq of the last change.
+func (f *ChangeFeed) Head() uint64 {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return f.head
+}
+
+// Since returns the changes following the one numbered after, up to limit of
+// them, or all of them if limit is 0.
+func (f *ChangeFeed) Since(after uint64, limit int) ([]Change, error) {
+	head := f.Head()
+
+	var out []Change
+	for seq := after + 1; seq <= head; seq++ {
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+
+		val, err := f.ds.Get(changeKey(seq))
+		if err != nil {
+			return nil, err
+		}
+		buf, ok := val.([]byte)
+		if !ok {
+			return nil, ErrValueTypeMismatch
+		}
+		c, err := cid.Cast(buf)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Change{Seq: seq, Cid: c})
+	}
+	return out, nil
+}
+
+// Wait blocks until there are changes after the one numbered after, or ctx
+// is done.
+func (f *ChangeFeed) Wait(ctx context.Context, after uint64) error {
+	for {
+		f.lk.Lock()
+		head, notify := f.head, f.notify
+		f.lk.Unlock()
+
+		if head > after {
+			return nil
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func changeKey(seq uint64) ds.Key {
+	// zero padded, so that the keys sort in order
+	return ChangeFeedPrefix.ChildString(fmt.Sprintf("log/%020d", seq))
+}
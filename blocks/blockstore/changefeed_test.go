@@ -0,0 +1,92 @@
+package blockstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	ds_sync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+)
+
+func TestChangeFeed(t *testing.T) {
+	d := ds_sync.MutexWrap(ds.NewMapDatastore())
+	bs := NewBlockstore(d)
+
+	stored := blocks.NewBlock([]byte("stored before"))
+	if err := bs.Put(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewChangeFeed(bs, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := blocks.NewBlock([]byte("a"))
+	b := blocks.NewBlock([]byte("b"))
+	c := blocks.NewBlock([]byte("c"))
+
+	if err := f.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	// blocks already stored aren't logged
+	if err := f.PutMany([]blocks.Block{stored, a, b, b, c}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := f.Since(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []blocks.Block{a, b, c}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %d", len(expected), len(changes))
+	}
+	for i, ch := range changes {
+		if ch.Seq != uint64(i+1) || !ch.Cid.Equals(expected[i].Cid()) {
+			t.Fatalf("change %d: got %d %s", i, ch.Seq, ch.Cid)
+		}
+	}
+
+	changes, err = f.Since(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || !changes[0].Cid.Equals(b.Cid()) {
+		t.Fatalf("expected the change after 1, got %v", changes)
+	}
+
+	// the log continues after a restart
+	f, err = NewChangeFeed(bs, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Head() != 3 {
+		t.Fatalf("expected head 3, got %d", f.Head())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := f.Wait(ctx, 3); err != context.DeadlineExceeded {
+		t.Fatalf("expected wait to time out, got %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- f.Wait(context.Background(), 3)
+	}()
+	if err := f.Put(blocks.NewBlock([]byte("d"))); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("wait wasn't woken up by the new block")
+	}
+}
@@ -0,0 +1,238 @@
+package blockstore
+
+import (
+	"sync"
+	"sync/atomic"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// NewGCLocker returns a default implementation of GCLocker, which tracks
+// the blocks used by the pins in progress rather than making garbage
+// collections wait for them.
+func NewGCLocker() GCLocker {
+	l := &gclocker{
+		pins:     make(map[uint64]struct{}),
+		deleting: make(map[string]int),
+	}
+	l.deleted = sync.NewCond(l.lk.RLocker())
+	return l
+}
+
+// usedShards is the number of shards of the sets of blocks used, which are
+// marked concurrently.
+const usedShards = 16
+
+// gclocker protects the blocks used by the pins in progress from the garbage
+// collections. Each block read or written while pins are in progress is
+// marked used, in the set of the latest pin start. A set is dropped once the
+// pins in progress when it was the latest ended, unless a garbage collection
+// is running: the pins it didn't see may have used the blocks.
+type gclocker struct {
+	// gclk makes the garbage collections happen one at a time
+	gclk  sync.Mutex
+	gcreq int32
+
+	// lk is only read locked to mark blocks, the sets have their own locks
+	lk sync.RWMutex
+	// signaled when deletions end
+	deleted *sync.Cond
+	seq     uint64
+	gcs     int
+	// the pins in progress, by the sequence number of their start
+	pins map[uint64]struct{}
+	// the sets of the blocks used, one for each pin start, oldest first
+	used []*usedSet
+	// the blocks being deleted by a garbage collection
+	deleting map[string]int
+
+	// 1 while pins are in progress, read without lk on every block
+	tracking int32
+}
+
+// usedSet are the blocks used from the start of a pin to the start of the
+// next one.
+type usedSet struct {
+	start  uint64
+	shards [usedShards]struct {
+		lk   sync.Mutex
+		keys map[string]struct{}
+	}
+}
+
+func newUsedSet(start uint64) *usedSet {
+	s := &usedSet{start: start}
+	for i := range s.shards {
+		s.shards[i].keys = make(map[string]struct{})
+	}
+	return s
+}
+
+func (s *usedSet) add(k string) {
+	sh := &s.shards[k[len(k)-1]%usedShards]
+	sh.lk.Lock()
+	sh.keys[k] = struct{}{}
+	sh.lk.Unlock()
+}
+
+func (s *usedSet) has(k string) bool {
+	sh := &s.shards[k[len(k)-1]%usedShards]
+	sh.lk.Lock()
+	defer sh.lk.Unlock()
+	_, ok := sh.keys[k]
+	return ok
+}
+
+func (l *gclocker) GCLock() Unlocker {
+	atomic.AddInt32(&l.gcreq, 1)
+	l.gclk.Lock()
+	atomic.AddInt32(&l.gcreq, -1)
+
+	l.lk.Lock()
+	l.gcs++
+	l.lk.Unlock()
+
+	return &unlocker{func() {
+		l.lk.Lock()
+		l.gcs--
+		l.prune()
+		l.lk.Unlock()
+
+		l.gclk.Unlock()
+	}}
+}
+
+func (l *gclocker) PinLock() Unlocker {
+	l.lk.Lock()
+	l.seq++
+	start := l.seq
+	l.pins[start] = struct{}{}
+	l.used = append(l.used, newUsedSet(start))
+	atomic.StoreInt32(&l.tracking, 1)
+	l.lk.Unlock()
+
+	return &unlocker{func() {
+		l.lk.Lock()
+		delete(l.pins, start)
+		if len(l.pins) == 0 {
+			atomic.StoreInt32(&l.tracking, 0)
+		}
+		l.prune()
+		l.lk.Unlock()
+	}}
+}
+
+func (l *gclocker) GCRequested() bool {
+	return atomic.LoadInt32(&l.gcreq) > 0
+}
+
+// prune drops the sets of the blocks used before the oldest pin in progress
+// started. It is called with lk held.
+func (l *gclocker) prune() {
+	if l.gcs > 0 {
+		return
+	}
+
+	oldest := l.seq + 1
+	for start := range l.pins {
+		if start < oldest {
+			oldest = start
+		}
+	}
+	n := 0
+	for n < len(l.used) && l.used[n].start < oldest {
+		l.used[n] = nil
+		n++
+	}
+	l.used = l.used[n:]
+}
+
+// use marks c used, if pins are in progress. Using a block being deleted
+// waits for the deletion: the block is then fetched or written again.
+func (l *gclocker) use(c *cid.Cid) {
+	if atomic.LoadInt32(&l.tracking) == 0 {
+		return
+	}
+
+	k := c.KeyString()
+	l.lk.RLock()
+	defer l.lk.RUnlock()
+	for l.deleting[k] > 0 {
+		l.deleted.Wait()
+	}
+	if len(l.used) > 0 {
+		l.used[len(l.used)-1].add(k)
+	}
+}
+
+// deleteBlock deletes c with del, unless a garbage collection is running and
+// the block was used by a pin in progress.
+func (l *gclocker) deleteBlock(c *cid.Cid, del func(*cid.Cid) error) error {
+	k := c.KeyString()
+	l.lk.Lock()
+	if l.gcs == 0 {
+		l.lk.Unlock()
+		return del(c)
+	}
+	for _, s := range l.used {
+		if s.has(k) {
+			l.lk.Unlock()
+			return ErrBlockInUse
+		}
+	}
+	l.deleting[k]++
+	l.lk.Unlock()
+
+	err := del(c)
+
+	l.lk.Lock()
+	if l.deleting[k]--; l.deleting[k] == 0 {
+		delete(l.deleting, k)
+	}
+	l.lk.Unlock()
+	l.deleted.Broadcast()
+	return err
+}
+
+// blockUser is the GCLocker of a GCBlockstore tracking the blocks used.
+type blockUser interface {
+	use(*cid.Cid)
+	deleteBlock(*cid.Cid, func(*cid.Cid) error) error
+}
+
+func (bs gcBlockstore) DeleteBlock(c *cid.Cid) error {
+	if u, ok := bs.GCLocker.(blockUser); ok {
+		return u.deleteBlock(c, bs.Blockstore.DeleteBlock)
+	}
+	return bs.Blockstore.DeleteBlock(c)
+}
+
+func (bs gcBlockstore) Has(c *cid.Cid) (bool, error) {
+	bs.use(c)
+	return bs.Blockstore.Has(c)
+}
+
+func (bs gcBlockstore) Get(c *cid.Cid) (blocks.Block, error) {
+	bs.use(c)
+	return bs.Blockstore.Get(c)
+}
+
+func (bs gcBlockstore) Put(b blocks.Block) error {
+	bs.use(b.Cid())
+	return bs.Blockstore.Put(b)
+}
+
+func (bs gcBlockstore) PutMany(bls []blocks.Block) error {
+	for _, b := range bls {
+		bs.use(b.Cid())
+	}
+	return bs.Blockstore.PutMany(bls)
+}
+
+func (bs gcBlockstore) use(c *cid.Cid) {
+	if u, ok := bs.GCLocker.(blockUser); ok {
+		u.use(c)
+	}
+}
@@ -0,0 +1,155 @@
+package blockstore
+
+import (
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	ds_sync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+)
+
+func newTestGCBlockstore() GCBlockstore {
+	return NewGCBlockstore(NewBlockstore(ds_sync.MutexWrap(ds.NewMapDatastore())), NewGCLocker())
+}
+
+func TestPinLockAndGCLockDontWait(t *testing.T) {
+	l := NewGCLocker()
+
+	gc := l.GCLock()
+	done := make(chan struct{})
+	go func() {
+		l.PinLock().Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("PinLock waited for the gc")
+	}
+	gc.Unlock()
+
+	pin := l.PinLock()
+	done = make(chan struct{})
+	go func() {
+		l.GCLock().Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GCLock waited for the pin")
+	}
+	pin.Unlock()
+}
+
+func TestGCSparesBlocksOfPinsInProgress(t *testing.T) {
+	bs := newTestGCBlockstore()
+	used := blocks.NewBlock([]byte("used by a pin"))
+	read := blocks.NewBlock([]byte("read by a pin"))
+	unused := blocks.NewBlock([]byte("unused"))
+	for _, b := range []blocks.Block{read, unused} {
+		if err := bs.Put(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pin := bs.PinLock()
+	if err := bs.Put(used); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.Get(read.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	gc := bs.GCLock()
+	if err := bs.DeleteBlock(used.Cid()); err != ErrBlockInUse {
+		t.Fatalf("deleting a block written by a pin in progress: %v", err)
+	}
+	if err := bs.DeleteBlock(read.Cid()); err != ErrBlockInUse {
+		t.Fatalf("deleting a block read by a pin in progress: %v", err)
+	}
+	if err := bs.DeleteBlock(unused.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the gc may have missed the pin, its blocks are kept until the gc ends
+	pin.Unlock()
+	if err := bs.DeleteBlock(used.Cid()); err != ErrBlockInUse {
+		t.Fatalf("deleting a block of a pin which ended during the gc: %v", err)
+	}
+	gc.Unlock()
+
+	gc = bs.GCLock()
+	if err := bs.DeleteBlock(used.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	gc.Unlock()
+}
+
+func TestBlocksUsedOutsideOfPinsAreNotKept(t *testing.T) {
+	bs := newTestGCBlockstore()
+	b := blocks.NewBlock([]byte("no pin"))
+	if err := bs.Put(b); err != nil {
+		t.Fatal(err)
+	}
+
+	gc := bs.GCLock()
+	defer gc.Unlock()
+	if _, err := bs.Get(b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.DeleteBlock(b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPinStartedDuringGC(t *testing.T) {
+	bs := newTestGCBlockstore()
+	b := blocks.NewBlock([]byte("late pin"))
+
+	gc := bs.GCLock()
+	defer gc.Unlock()
+
+	pin := bs.PinLock()
+	defer pin.Unlock()
+	if err := bs.Put(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.DeleteBlock(b.Cid()); err != ErrBlockInUse {
+		t.Fatalf("deleting a block of a pin started during the gc: %v", err)
+	}
+}
+
+func TestBlocksOfEndedPinsAreDropped(t *testing.T) {
+	bs := newTestGCBlockstore()
+	first := blocks.NewBlock([]byte("used by the first pin"))
+	second := blocks.NewBlock([]byte("used by the second pin"))
+
+	pin1 := bs.PinLock()
+	if err := bs.Put(first); err != nil {
+		t.Fatal(err)
+	}
+	pin2 := bs.PinLock()
+	defer pin2.Unlock()
+	if err := bs.Put(second); err != nil {
+		t.Fatal(err)
+	}
+
+	// the blocks of the first pin are dropped once it ends, though the
+	// second one is still in progress
+	pin1.Unlock()
+	if n := len(bs.(gcBlockstore).GCLocker.(*gclocker).used); n != 1 {
+		t.Fatalf("expected the set of the second pin only, got %d sets", n)
+	}
+
+	gc := bs.GCLock()
+	defer gc.Unlock()
+	if err := bs.DeleteBlock(first.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.DeleteBlock(second.Cid()); err != ErrBlockInUse {
+		t.Fatalf("deleting a block of a pin in progress: %v", err)
+	}
+}
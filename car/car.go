@@ -0,0 +1,217 @@
+// Package car reads and writes CAR (content addressable archive) files: a
+// header naming the roots of dags, followed by the blocks of the dags, each
+// prefixed by its CID. Moving a dag in a CAR file keeps its CIDs.
+//
+// Only version 1 of the format is supported, with definite length CBOR in
+// the header.
+package car
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// Version is the version of the CAR format written.
+const Version = 1
+
+// MaxSectionSize bounds the size of the header and of each block read.
+var MaxSectionSize uint64 = 4 << 20
+
+// ErrInvalidHeader is returned when a CAR header can't be decoded.
+var ErrInvalidHeader = errors.New("car: invalid header")
+
+// Header is the header of a CAR file.
+type Header struct {
+	Roots   []*cid.Cid
+	Version uint64
+}
+
+// Write writes the dag under root to w, each block once, parents before
+// their children.
+func Write(ctx context.Context, ds dag.DAGService, root *cid.Cid, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := writeSection(bw, encodeHeader(&Header{Roots: []*cid.Cid{root}, Version: Version})); err != nil {
+		return err
+	}
+
+	seen := cid.NewSet()
+	var walk func(c *cid.Cid) error
+	walk = func(c *cid.Cid) error {
+		if !seen.Visit(c) {
+			return nil
+		}
+
+		nd, err := ds.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err := writeSection(bw, c.Bytes(), nd.RawData()); err != nil {
+			return err
+		}
+
+		for _, lnk := range nd.Links() {
+			if err := walk(lnk.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeSection(w io.Writer, parts ...[]byte) error {
+	size := 0
+	for _, p := range parts {
+		size += len(p)
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(size))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+	for _, p := range parts {
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reader reads the blocks of a CAR file.
+type Reader struct {
+	r      *bufio.Reader
+	Header Header
+}
+
+// NewReader reads the header of the CAR file in r.
+func NewReader(r io.Reader) (*Reader, error) {
+	cr := &Reader{r: bufio.NewReader(r)}
+
+	data, err := cr.section()
+	if err == io.EOF {
+		return nil, ErrInvalidHeader
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := decodeHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if h.Version != Version {
+		return nil, fmt.Errorf("car: unsupported version %d", h.Version)
+	}
+	cr.Header = *h
+	return cr, nil
+}
+
+// BadBlockError is returned by Reader.Next for a block whose data doesn't
+// match its CID. The block is skipped: reading can go on with the next one.
+type BadBlockError struct {
+	Cid *cid.Cid
+}
+
+func (e *BadBlockError) Error() string {
+	return fmt.Sprintf("car: data of block %s doesn't match its hash", e.Cid)
+}
+
+// Next returns the next block, after checking that its data matches its
+// CID, or io.EOF at the end of the file.
+func (cr *Reader) Next() (blocks.Block, error) {
+	c, data, err := cr.NextUnchecked()
+	if err != nil {
+		return nil, err
+	}
+	return Check(c, data)
+}
+
+// NextUnchecked returns the CID and the data of the next block without
+// checking them, or io.EOF at the end of the file. Check makes the block, so
+// that the blocks can be checked by several goroutines.
+func (cr *Reader) NextUnchecked() (*cid.Cid, []byte, error) {
+	data, err := cr.section()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, n, err := readCid(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, data[n:], nil
+}
+
+// Check makes the block of c, after checking that data matches it. It returns
+// a *BadBlockError if it doesn't.
+func Check(c *cid.Cid, data []byte) (blocks.Block, error) {
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, &BadBlockError{Cid: c}
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// section reads the next length prefixed section.
+func (cr *Reader) section() ([]byte, error) {
+	size, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > MaxSectionSize {
+		return nil, fmt.Errorf("car: section of %d bytes is too large", size)
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(cr.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readCid reads the CID at the start of a block section, and returns its
+// length.
+func readCid(data []byte) (*cid.Cid, int, error) {
+	// CIDv0 are bare sha2-256 multihashes
+	if len(data) >= 34 && data[0] == 0x12 && data[1] == 0x20 {
+		c, err := cid.Cast(data[:34])
+		return c, 34, err
+	}
+
+	// <version><codec><multihash code><multihash length><digest>
+	n := 0
+	for i := 0; i < 3; i++ {
+		_, l := binary.Uvarint(data[n:])
+		if l <= 0 {
+			return nil, 0, fmt.Errorf("car: invalid cid")
+		}
+		n += l
+	}
+	size, l := binary.Uvarint(data[n:])
+	if l <= 0 || uint64(len(data)-n-l) < size {
+		return nil, 0, fmt.Errorf("car: invalid cid")
+	}
+	n += l + int(size)
+
+	c, err := cid.Cast(data[:n])
+	return c, n, err
+}
@@ -0,0 +1,144 @@
+package car
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	dstest "github.com/ipfs/go-ipfs/merkledag/test"
+)
+
+func TestWriteRead(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+
+	leaf := dag.NodeWithData([]byte("leaf"))
+	other := dag.NodeWithData([]byte("other leaf"))
+	root := dag.NodeWithData([]byte("root"))
+	for _, nd := range []*dag.ProtoNode{leaf, other} {
+		if _, err := ds.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the same leaf twice, written once
+	names := []string{"a", "b", "c"}
+	for i, nd := range []*dag.ProtoNode{leaf, other, leaf} {
+		if err := root.AddNodeLink(names[i], nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ds.Add(root); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Write(ctx, ds, root.Cid(), buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Header.Roots) != 1 || !r.Header.Roots[0].Equals(root.Cid()) {
+		t.Fatalf("wrong roots: %v", r.Header.Roots)
+	}
+
+	expected := []*dag.ProtoNode{root, leaf, other}
+	for i := 0; ; i++ {
+		b, err := r.Next()
+		if err == io.EOF {
+			if i != len(expected) {
+				t.Fatalf("expected %d blocks, got %d", len(expected), i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i >= len(expected) || !b.Cid().Equals(expected[i].Cid()) {
+			t.Fatalf("unexpected block %d: %s", i, b.Cid())
+		}
+		if !bytes.Equal(b.RawData(), expected[i].RawData()) {
+			t.Fatalf("wrong data for block %s", b.Cid())
+		}
+	}
+}
+
+func TestReadCorruptBlock(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+
+	nd := dag.NodeWithData([]byte("some data"))
+	if _, err := ds.Add(nd); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Write(ctx, ds, nd.Cid(), buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xff
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Next()
+	if bad, ok := err.(*BadBlockError); !ok || !bad.Cid.Equals(nd.Cid()) {
+		t.Fatalf("expected a BadBlockError, got %v", err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected the end of the file after the bad block, got %v", err)
+	}
+}
+
+func TestInvalidHeader(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte{3, 0xa1, 0x61, 'x'})); err != ErrInvalidHeader {
+		t.Fatalf("expected ErrInvalidHeader, got %v", err)
+	}
+}
+
+func TestImportSkipsBadBlocks(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+
+	nd := dag.NodeWithData([]byte("some data"))
+	if _, err := ds.Add(nd); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Write(ctx, ds, nd.Cid(), buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xff
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Import(ctx, dstest.Bserv(), false); err == nil {
+		t.Fatal("expected the bad block to fail the import")
+	}
+
+	r, err = NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := dstest.Bserv()
+	stats, err := r.Import(ctx, bs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Blocks != 0 || len(stats.BadBlocks) != 1 || !stats.BadBlocks[0].Equals(nd.Cid()) {
+		t.Fatalf("expected the bad block to be skipped, got %+v", stats)
+	}
+	if has, _ := bs.Blockstore().Has(nd.Cid()); has {
+		t.Fatal("the bad block was stored")
+	}
+}
@@ -0,0 +1,204 @@
+package car
+
+import (
+	"encoding/binary"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// The header is a CBOR map, {"roots": [CID, ...], "version": 1}, with the
+// CIDs in the tag 42 encoding of IPLD. Only the few CBOR items it is made of
+// are handled here.
+
+const (
+	cborUint  = 0
+	cborBytes = 2
+	cborText  = 3
+	cborArray = 4
+	cborMap   = 5
+	cborTag   = 6
+
+	cborCidTag = 42
+)
+
+func encodeHeader(h *Header) []byte {
+	var buf []byte
+	buf = appendCborHead(buf, cborMap, 2)
+
+	buf = appendCborHead(buf, cborText, 5)
+	buf = append(buf, "roots"...)
+	buf = appendCborHead(buf, cborArray, uint64(len(h.Roots)))
+	for _, c := range h.Roots {
+		// the CID is prefixed with the identity multibase
+		b := append([]byte{0}, c.Bytes()...)
+		buf = appendCborHead(buf, cborTag, cborCidTag)
+		buf = appendCborHead(buf, cborBytes, uint64(len(b)))
+		buf = append(buf, b...)
+	}
+
+	buf = appendCborHead(buf, cborText, 7)
+	buf = append(buf, "version"...)
+	buf = appendCborHead(buf, cborUint, h.Version)
+	return buf
+}
+
+func appendCborHead(buf []byte, major byte, v uint64) []byte {
+	m := major << 5
+	switch {
+	case v < 24:
+		return append(buf, m|byte(v))
+	case v <= 0xff:
+		return append(buf, m|24, byte(v))
+	case v <= 0xffff:
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, uint16(v))
+		return append(append(buf, m|25), b...)
+	case v <= 0xffffffff:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(v))
+		return append(append(buf, m|26), b...)
+	default:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, v)
+		return append(append(buf, m|27), b...)
+	}
+}
+
+type cborReader struct {
+	data []byte
+}
+
+// head reads the major type and argument of the next item.
+func (r *cborReader) head() (byte, uint64, error) {
+	if len(r.data) == 0 {
+		return 0, 0, ErrInvalidHeader
+	}
+	major, info := r.data[0]>>5, r.data[0]&0x1f
+	r.data = r.data[1:]
+
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		// indefinite lengths and floats aren't used in headers
+		return 0, 0, ErrInvalidHeader
+	}
+	if len(r.data) < size {
+		return 0, 0, ErrInvalidHeader
+	}
+
+	var v uint64
+	for _, b := range r.data[:size] {
+		v = v<<8 | uint64(b)
+	}
+	r.data = r.data[size:]
+	return major, v, nil
+}
+
+func (r *cborReader) bytes(n uint64) ([]byte, error) {
+	if uint64(len(r.data)) < n {
+		return nil, ErrInvalidHeader
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b, nil
+}
+
+// skip skips an item whose head was read.
+func (r *cborReader) skip(major byte, v uint64) error {
+	var items uint64
+	switch major {
+	case cborBytes, cborText:
+		_, err := r.bytes(v)
+		return err
+	case cborArray:
+		items = v
+	case cborMap:
+		items = 2 * v
+	case cborTag:
+		items = 1
+	case 1, 7: // negative integers, simple values
+		return nil
+	}
+
+	for i := uint64(0); i < items; i++ {
+		m, iv, err := r.head()
+		if err != nil {
+			return err
+		}
+		if err := r.skip(m, iv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeHeader(data []byte) (*Header, error) {
+	r := &cborReader{data: data}
+	major, fields, err := r.head()
+	if err != nil || major != cborMap {
+		return nil, ErrInvalidHeader
+	}
+
+	h := new(Header)
+	for i := uint64(0); i < fields; i++ {
+		major, n, err := r.head()
+		if err != nil || major != cborText {
+			return nil, ErrInvalidHeader
+		}
+		key, err := r.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+
+		major, v, err := r.head()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case string(key) == "version" && major == cborUint:
+			h.Version = v
+		case string(key) == "roots" && major == cborArray:
+			for j := uint64(0); j < v; j++ {
+				c, err := r.cid()
+				if err != nil {
+					return nil, err
+				}
+				h.Roots = append(h.Roots, c)
+			}
+		default:
+			if err := r.skip(major, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return h, nil
+}
+
+func (r *cborReader) cid() (*cid.Cid, error) {
+	major, tag, err := r.head()
+	if err != nil || major != cborTag || tag != cborCidTag {
+		return nil, ErrInvalidHeader
+	}
+	major, n, err := r.head()
+	if err != nil || major != cborBytes {
+		return nil, ErrInvalidHeader
+	}
+	b, err := r.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 2 || b[0] != 0 {
+		return nil, ErrInvalidHeader
+	}
+	return cid.Cast(b[1:])
+}
@@ -0,0 +1,118 @@
+package car
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// importBatchSize is the number of blocks written at once by Import.
+const importBatchSize = 1024
+
+// ImportStats counts what Import read.
+type ImportStats struct {
+	// Blocks and Bytes count the blocks stored.
+	Blocks int
+	Bytes  uint64
+
+	// BadBlocks lists the blocks whose data doesn't match their CID, which
+	// were skipped.
+	BadBlocks []*cid.Cid
+}
+
+// Import stores the remaining blocks of the archive through bs. While one
+// goroutine reads the archive, the blocks are checked by one worker per CPU,
+// and the checked blocks written in batches. A block whose data doesn't
+// match its CID fails the import with a *BadBlockError, unless skipBad is
+// set: it is then left out and listed in the stats.
+func (cr *Reader) Import(ctx context.Context, bs bserv.BlockService, skipBad bool) (*ImportStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type section struct {
+		c    *cid.Cid
+		data []byte
+	}
+	type checked struct {
+		b   blocks.Block
+		err error
+	}
+	sections := make(chan section, importBatchSize)
+	results := make(chan checked, importBatchSize)
+	readErr := make(chan error, 1)
+
+	go func() {
+		defer close(sections)
+		for {
+			c, data, err := cr.NextUnchecked()
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				readErr <- err
+				return
+			}
+			select {
+			case sections <- section{c, data}:
+			case <-ctx.Done():
+				readErr <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range sections {
+				b, err := Check(s.c, s.data)
+				select {
+				case results <- checked{b, err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	stats := new(ImportStats)
+	batch := make([]blocks.Block, 0, importBatchSize)
+	for res := range results {
+		if bad, ok := res.err.(*BadBlockError); ok && skipBad {
+			stats.BadBlocks = append(stats.BadBlocks, bad.Cid)
+			continue
+		}
+		if res.err != nil {
+			return nil, res.err
+		}
+		batch = append(batch, res.b)
+		stats.Blocks++
+		stats.Bytes += uint64(len(res.b.RawData()))
+
+		if len(batch) == importBatchSize {
+			if _, err := bs.AddBlocks(batch); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := <-readErr; err != nil {
+		return nil, err
+	}
+	if _, err := bs.AddBlocks(batch); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	corebench "github.com/ipfs/go-ipfs/core/corebench"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+// benchmarkOutput is the report of 'ipfs benchmark'. Its JSON encoding is a
+// corebench.Report, which --compare reads back.
+type benchmarkOutput struct {
+	*corebench.Report
+	Baseline []corebench.Change `json:",omitempty"`
+}
+
+// benchmarkCmd runs the benchmarks in the process of the client, they don't
+// need a daemon.
+var benchmarkCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Measure the performance of ipfs on this machine.",
+		ShortDescription: `
+'ipfs benchmark' runs ipfs nodes in memory, connected over a simulated network,
+and times the main operations of ipfs on them. It doesn't use the repo, nor
+the network of this machine.
+`,
+		LongDescription: `
+'ipfs benchmark' runs ipfs nodes in memory, connected over a simulated network,
+and times the main operations of ipfs on them. It doesn't use the repo, nor
+the network of this machine.
+
+The benchmarks are:
+
+  add         adding content to a node
+  pin-fetch   pinning content the other nodes of the network have, over the
+              links of the network
+  gc          the garbage collection of unpinned content
+  gateway     downloading content from the HTTP gateway of a node
+
+Each run uses different content, generated from --seed. The network, the
+content and the runs only depend on the options, so the reports of the same
+options compare across machines and versions of ipfs. Save a report with
+'--enc=json' and compare the next ones to it with --compare:
+
+  ipfs benchmark --enc=json > baseline.json
+  ipfs benchmark --compare=baseline.json
+
+The defaults are 4 nodes, 1MiB of content per run, 5 runs, links without
+latency nor bandwidth limit, and seed 1.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("benchmark", false, true, "The benchmarks to run, all of them if none is given."),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("nodes", "n", "The number of nodes of the network."),
+		cmds.StringOption("size", "s", "The size of the content of each run, e.g. 10MB."),
+		cmds.IntOption("runs", "r", "The number of runs of each benchmark."),
+		cmds.StringOption("latency", "The latency of the links between the nodes, e.g. 20ms."),
+		cmds.StringOption("bandwidth", "The bandwidth of the links between the nodes per second, e.g. 10MB."),
+		cmds.IntOption("seed", "The seed generating the content."),
+		cmds.StringOption("compare", "The JSON report to compare the results to."),
+	},
+	Type: benchmarkOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		cfg, err := benchmarkConfig(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		var baseline *corebench.Report
+		if fname, found, _ := req.Option("compare").String(); found {
+			baseline, err = readBenchmarkReport(fname)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+			if baseline.Config != cfg {
+				res.SetError(fmt.Errorf("%s was run with other options, its results don't compare", fname), cmds.ErrClient)
+				return
+			}
+		}
+
+		rep, err := corebench.Run(req.Context(), cfg, req.Arguments()...)
+		if err != nil {
+			code := cmds.ErrNormal
+			if strings.HasPrefix(err.Error(), corebench.ErrUnknownBenchmark.Error()) {
+				code = cmds.ErrClient
+			}
+			res.SetError(err, code)
+			return
+		}
+
+		out := &benchmarkOutput{Report: rep}
+		if baseline != nil {
+			out.Baseline, err = rep.Compare(baseline)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*benchmarkOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			cfg := out.Config
+			bw := "unlimited"
+			if cfg.Bandwidth > 0 {
+				bw = humanize.Bytes(uint64(cfg.Bandwidth)) + "/s"
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "go-ipfs %s, %s, %s, %d CPUs\n", out.Version, out.System, out.Golang, out.CPUs)
+			fmt.Fprintf(buf, "%d nodes, %s per run, %d runs, latency %s, bandwidth %s, seed %d\n\n",
+				cfg.Nodes, humanize.IBytes(uint64(cfg.Size)), cfg.Runs, cfg.Latency, bw, cfg.Seed)
+
+			changes := make(map[string]corebench.Change)
+			for _, c := range out.Baseline {
+				changes[c.Name] = c
+			}
+
+			w := tabwriter.NewWriter(buf, 1, 2, 2, ' ', 0)
+			header := "BENCHMARK\tMEDIAN\tMIN\tMAX\tTHROUGHPUT"
+			if out.Baseline != nil {
+				header += "\tBASELINE\tCHANGE"
+			}
+			fmt.Fprintln(w, header)
+			for _, r := range out.Results {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s/s", r.Name, roundDuration(r.Median),
+					roundDuration(r.Min), roundDuration(r.Max), humanize.Bytes(uint64(r.Throughput)))
+				if c, ok := changes[r.Name]; ok {
+					fmt.Fprintf(w, "\t%s\t%+.1f%%", roundDuration(c.Baseline), c.Change*100)
+				}
+				fmt.Fprintln(w)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+}
+
+// benchmarkConfig reads the config of the benchmarks from the options, the
+// ones not given are the defaults.
+func benchmarkConfig(req cmds.Request) (corebench.Config, error) {
+	cfg := corebench.DefaultConfig
+
+	if nodes, found, err := req.Option("nodes").Int(); err != nil {
+		return cfg, err
+	} else if found {
+		cfg.Nodes = nodes
+	}
+	if runs, found, err := req.Option("runs").Int(); err != nil {
+		return cfg, err
+	} else if found {
+		cfg.Runs = runs
+	}
+	if seed, found, err := req.Option("seed").Int(); err != nil {
+		return cfg, err
+	} else if found {
+		cfg.Seed = int64(seed)
+	}
+
+	if size, found, _ := req.Option("size").String(); found {
+		s, err := humanize.ParseBytes(size)
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing size option: %s", err)
+		}
+		cfg.Size = int64(s)
+	}
+	if latency, found, _ := req.Option("latency").String(); found {
+		d, err := time.ParseDuration(latency)
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing latency option: %s", err)
+		}
+		cfg.Latency = d
+	}
+	if bandwidth, found, _ := req.Option("bandwidth").String(); found {
+		bw, err := humanize.ParseBytes(bandwidth)
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing bandwidth option: %s", err)
+		}
+		cfg.Bandwidth = float64(bw)
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func readBenchmarkReport(fname string) (*corebench.Report, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	var rep corebench.Report
+	if err := json.Unmarshal(data, &rep); err != nil {
+		return nil, fmt.Errorf("reading the report %s: %s", fname, err)
+	}
+	return &rep, nil
+}
+
+// roundDuration rounds d to 3 significant digits, for the reports to read
+// well.
+func roundDuration(d time.Duration) time.Duration {
+	unit := time.Duration(1)
+	for d/unit >= 1000 {
+		unit *= 10
+	}
+	return d - d%unit
+}
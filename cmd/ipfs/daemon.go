@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
 	"errors"
 	_ "expvar"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
 	"sort"
 	"sync"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
@@ -17,9 +22,12 @@ import (
 	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	"github.com/ipfs/go-ipfs/core/corerouting"
+	versioncheck "github.com/ipfs/go-ipfs/core/versioncheck"
+	watchdog "github.com/ipfs/go-ipfs/core/watchdog"
 	nodeMount "github.com/ipfs/go-ipfs/fuse/node"
+	repo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
-	migrate "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
 
 	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
 	mprome "gx/ipfs/QmSk46nSD78YiuNojYMS8NW6hSCjH95JajqqzzoychZgef/go-metrics-prometheus"
@@ -38,6 +46,7 @@ const (
 	migrateKwd                = "migrate"
 	mountKwd                  = "mount"
 	offlineKwd                = "offline"
+	recoverKwd                = "recover"
 	routingOptionKwd          = "routing"
 	routingOptionSupernodeKwd = "supernode"
 	routingOptionDHTClientKwd = "dhtclient"
@@ -132,6 +141,16 @@ be enabled by running the daemon as:
 This will later be transitioned into a config option once it gets out of the
 'experimental' stage.
 
+Repo integrity
+
+On start, the daemon checks the parts of the repo it can't run without: the
+identity, the pins and the root of the files API ('ipfs files'). If one can't
+be loaded, the problems are listed and the daemon offers to repair them from
+the local blocks, before it starts: the broken pins are set aside and the
+pins recorded by 'ipfs pin add' are pinned again, and the files root is
+restored to the last one which can be loaded. Pass --recover to repair them
+without a prompt, or --recover=false to never repair them.
+
 DEPRECATION NOTICE
 
 Previously, ipfs used an environment variable as seen below:
@@ -157,6 +176,7 @@ Headers.
 		cmds.BoolOption(adjustFDLimitKwd, "Check and raise file descriptor limits if needed").Default(true),
 		cmds.BoolOption(offlineKwd, "Run offline. Do not connect to the rest of the network but provide local API.").Default(false),
 		cmds.BoolOption(migrateKwd, "If true, assume yes at the migrate prompt. If false, assume no."),
+		cmds.BoolOption(recoverKwd, "If true, assume yes at the prompt to repair a broken repo. If false, assume no."),
 		cmds.BoolOption(enableFloodSubKwd, "Instantiate the ipfs daemon with the experimental pubsub feature enabled."),
 		cmds.BoolOption(enableMultiplexKwd, "Add the experimental 'go-multiplex' stream muxer to libp2p on construction.").Default(true),
 		// TODO: add way to override addresses. tricky part: updating the config if also --init.
@@ -258,7 +278,7 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 			return
 		}
 
-		err = migrate.RunMigration(fsrepo.RepoVersion)
+		err = fsrepo.Migrate(ctx.ConfigRoot)
 		if err != nil {
 			fmt.Println("The migrations of fs-repo failed:")
 			fmt.Printf("  %s\n", err)
@@ -283,8 +303,29 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		return
 	}
 
+	// the identity lives on in another repo, don't run it twice
+	exportedAt, exported, err := fsrepo.IdentityExported(ctx.ConfigRoot)
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		repo.Close()
+		return
+	}
+	if exported {
+		res.SetError(fmt.Errorf("the identity of this repo was exported on %s. Running it next to the repo it was imported in would disrupt both nodes. Remove %s to start anyway",
+			exportedAt.Format(time.RFC1123), filepath.Join(ctx.ConfigRoot, fsrepo.IdentityExportedFile)), cmds.ErrClient)
+		repo.Close()
+		return
+	}
+
+	if err := checkRepo(req, repo); err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		repo.Close()
+		return
+	}
+
 	offline, _, _ := req.Option(offlineKwd).Bool()
 	pubsub, _, _ := req.Option(enableFloodSubKwd).Bool()
+	pubsub = pubsub || cfg.FeatureEnabled("pubsub")
 	mplex, _, _ := req.Option(enableMultiplexKwd).Bool()
 
 	// Start assembling node config
@@ -406,13 +447,31 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		}
 	}
 
+	// proxy http requests to other nodes - if it is set in the config
+	err, p2pErrc := maybeServeP2PProxy(node, cfg)
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+
+	// watch the subsystems for stalls - unless disabled in the config
+	err, wdErrc := maybeRunWatchdog(req, node, cfg)
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+
 	// initialize metrics collector
 	prometheus.MustRegister(&corehttp.IpfsNodeCollector{Node: node})
 
+	if !offline && !cfg.Version.NoCheck {
+		go versioncheck.Start(req.Context(), node, cfg.Version, os.Stderr)
+	}
+
 	fmt.Printf("Daemon is ready\n")
 	// collect long-running errors and block for shutdown
 	// TODO(cryptix): our fuse currently doesnt follow this pattern for graceful shutdown
-	for err := range merge(apiErrc, gwErrc, gcErrc) {
+	for err := range merge(apiErrc, gwErrc, gcErrc, p2pErrc, wdErrc) {
 		if err != nil {
 			log.Error(err)
 			res.SetError(err, cmds.ErrNormal)
@@ -513,60 +572,160 @@ func printSwarmAddrs(node *core.IpfsNode) {
 
 // serveHTTPGateway collects options, creates listener, prints status message and starts serving requests
 func serveHTTPGateway(req cmds.Request) (error, <-chan error) {
-	cfg, err := req.InvocContext().GetConfig()
+	node, err := req.InvocContext().ConstructNode()
 	if err != nil {
-		return fmt.Errorf("serveHTTPGateway: GetConfig() failed: %s", err), nil
+		return fmt.Errorf("serveHTTPGateway: ConstructNode() failed: %s", err), nil
 	}
 
-	gatewayMaddr, err := ma.NewMultiaddr(cfg.Addresses.Gateway)
+	servers, err := startHTTPGateway(req, node)
 	if err != nil {
-		return fmt.Errorf("serveHTTPGateway: invalid gateway address: %q (err: %s)", cfg.Addresses.Gateway, err), nil
+		return err, nil
 	}
 
-	writable, writableOptionFound, err := req.Option(writableKwd).Bool()
+	// 'ipfs daemon restart-service gateway' closes the listeners and opens
+	// them again, with the current config
+	restart := make(chan chan error)
+	err = node.RegisterService("gateway", func() error {
+		done := make(chan error)
+		select {
+		case restart <- done:
+			return <-done
+		case <-node.Process().Closing():
+			return errors.New("the node is closing")
+		}
+	})
 	if err != nil {
-		return fmt.Errorf("serveHTTPGateway: req.Option(%s) failed: %s", writableKwd, err), nil
+		servers.stop()
+		return fmt.Errorf("serveHTTPGateway: %s", err), nil
 	}
-	if !writableOptionFound {
-		writable = cfg.Gateway.Writable
+
+	errc := make(chan error)
+	go func() {
+		defer close(errc)
+		for {
+			select {
+			case err, ok := <-servers.errc:
+				if !ok {
+					// the servers stopped, the node closes
+					servers.errc = nil
+					continue
+				}
+				errc <- err
+			case done := <-restart:
+				servers.stop()
+				s, err := startHTTPGateway(req, node)
+				if err != nil {
+					// the gateway stays down until restarted again
+					s = &gatewayServers{}
+				}
+				servers = s
+				done <- err
+			case <-node.Process().Closing():
+				// the servers stop with the node
+				if servers.errc != nil {
+					for err := range servers.errc {
+						errc <- err
+					}
+				}
+				return
+			}
+		}
+	}()
+	return nil, errc
+}
+
+// gatewayServers are the servers of the gateway, one per listener.
+type gatewayServers struct {
+	listeners []manet.Listener
+	errc      <-chan error
+}
+
+// stop closes the listeners and waits for the servers to return. Their
+// errors are the ones of their closed listeners.
+func (s *gatewayServers) stop() {
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	if s.errc != nil {
+		for range s.errc {
+		}
 	}
+}
 
-	gwLis, err := manet.Listen(gatewayMaddr)
+// startHTTPGateway listens on the gateway addresses and starts serving
+// requests.
+func startHTTPGateway(req cmds.Request, node *core.IpfsNode) (*gatewayServers, error) {
+	cfg, err := node.Repo.Config()
 	if err != nil {
-		return fmt.Errorf("serveHTTPGateway: manet.Listen(%s) failed: %s", gatewayMaddr, err), nil
+		return nil, fmt.Errorf("serveHTTPGateway: Config() failed: %s", err)
 	}
-	// we might have listened to /tcp/0 - lets see what we are listing on
-	gatewayMaddr = gwLis.Multiaddr()
 
-	if writable {
-		fmt.Printf("Gateway (writable) server listening on %s\n", gatewayMaddr)
-	} else {
-		fmt.Printf("Gateway (readonly) server listening on %s\n", gatewayMaddr)
+	writable, writableOptionFound, err := req.Option(writableKwd).Bool()
+	if err != nil {
+		return nil, fmt.Errorf("serveHTTPGateway: req.Option(%s) failed: %s", writableKwd, err)
 	}
-
-	var opts = []corehttp.ServeOption{
-		corehttp.MetricsCollectionOption("gateway"),
-		corehttp.CommandsROOption(*req.InvocContext()),
-		corehttp.VersionOption(),
-		corehttp.IPNSHostnameOption(),
-		corehttp.GatewayOption(writable, "/ipfs", "/ipns"),
+	if !writableOptionFound {
+		writable = cfg.Gateway.Writable
 	}
 
-	if len(cfg.Gateway.RootRedirect) > 0 {
-		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
-	}
+	s := &gatewayServers{}
+	var noFetch []bool
+	for _, addr := range cfg.Addresses.Gateway {
+		gatewayMaddr, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			s.stop()
+			return nil, fmt.Errorf("serveHTTPGateway: invalid gateway address: %q (err: %s)", addr, err)
+		}
 
-	node, err := req.InvocContext().ConstructNode()
-	if err != nil {
-		return fmt.Errorf("serveHTTPGateway: ConstructNode() failed: %s", err), nil
+		gwLis, err := manet.Listen(gatewayMaddr)
+		if err != nil {
+			s.stop()
+			return nil, fmt.Errorf("serveHTTPGateway: manet.Listen(%s) failed: %s", gatewayMaddr, err)
+		}
+		// we might have listened to /tcp/0 - lets see what we are listing on
+		gatewayMaddr = gwLis.Multiaddr()
+
+		nf := cfg.Gateway.NoFetch
+		if lcfg, ok := cfg.Gateway.Listeners[addr]; ok {
+			nf = lcfg.NoFetch
+		}
+
+		mode := "readonly"
+		if writable {
+			mode = "writable"
+		}
+		if nf {
+			mode += ", local content only"
+		}
+		fmt.Printf("Gateway (%s) server listening on %s\n", mode, gatewayMaddr)
+		s.listeners = append(s.listeners, gwLis)
+		noFetch = append(noFetch, nf)
 	}
 
-	errc := make(chan error)
-	go func() {
-		errc <- corehttp.Serve(node, gwLis.NetListener(), opts...)
-		close(errc)
-	}()
-	return nil, errc
+	var errcs []<-chan error
+	for i, gwLis := range s.listeners {
+		gatewayOpt := corehttp.GatewayOption(writable, "/ipfs", "/ipns")
+		if noFetch[i] {
+			gatewayOpt = corehttp.NoFetchGatewayOption(writable, "/ipfs", "/ipns")
+		}
+
+		var opts = []corehttp.ServeOption{
+			corehttp.MetricsCollectionOption("gateway"),
+			corehttp.CommandsROOption(*req.InvocContext()),
+			corehttp.VersionOption(),
+			corehttp.IPNSHostnameOption(),
+			gatewayOpt,
+		}
+
+		errc := make(chan error)
+		go func(lis net.Listener) {
+			errc <- corehttp.Serve(node, lis, opts...)
+			close(errc)
+		}(gwLis.NetListener())
+		errcs = append(errcs, errc)
+	}
+	s.errc = merge(errcs...)
+	return s, nil
 }
 
 //collects options and opens the fuse mountpoint
@@ -623,6 +782,131 @@ func maybeRunGC(req cmds.Request, node *core.IpfsNode) (error, <-chan error) {
 	return nil, errc
 }
 
+// maybeServeP2PProxy serves the proxy of http requests to the services other
+// nodes expose with 'ipfs p2p http expose' on Addresses.P2PProxy, if set. It
+// sends requests from this node's identity, so only a loopback address is
+// allowed: anyone able to reach the proxy could speak for the node.
+func maybeServeP2PProxy(node *core.IpfsNode, cfg *config.Config) (error, <-chan error) {
+	if cfg.Addresses.P2PProxy == "" {
+		return nil, nil
+	}
+	if !cfg.FeatureEnabled("p2p-http") {
+		return errors.New("Addresses.P2PProxy is set, but the p2p-http feature is disabled"), nil
+	}
+
+	proxyMaddr, err := ma.NewMultiaddr(cfg.Addresses.P2PProxy)
+	if err != nil {
+		return fmt.Errorf("serveP2PProxy: invalid p2p proxy address: %q (err: %s)", cfg.Addresses.P2PProxy, err), nil
+	}
+	if !manet.IsIPLoopback(proxyMaddr) {
+		return fmt.Errorf("serveP2PProxy: %s is not a loopback address", proxyMaddr), nil
+	}
+
+	proxyLis, err := manet.Listen(proxyMaddr)
+	if err != nil {
+		return fmt.Errorf("serveP2PProxy: manet.Listen(%s) failed: %s", proxyMaddr, err), nil
+	}
+	// we might have listened to /tcp/0 - lets see what we are listing on
+	fmt.Printf("P2P proxy server listening on %s\n", proxyLis.Multiaddr())
+
+	errc := make(chan error)
+	go func() {
+		errc <- corehttp.Serve(node, proxyLis.NetListener(), corehttp.P2PProxyOption())
+		close(errc)
+	}()
+	return nil, errc
+}
+
+// maybeRunWatchdog starts the watchdog of the commands handler, bitswap and
+// the DHT, which dumps the goroutine stacks to the diagnostics directory of
+// the repo when one of them stalls. When it is asked to restart the stalled
+// subsystems and can't, it shuts the node down, for a supervisor to restart
+// the daemon.
+func maybeRunWatchdog(req cmds.Request, node *core.IpfsNode, cfg *config.Config) (error, <-chan error) {
+	if cfg.Watchdog.Disabled {
+		return nil, nil
+	}
+
+	wcfg := watchdog.Config{
+		Dir:     filepath.Join(req.InvocContext().ConfigRoot, "diagnostics"),
+		Restart: cfg.Watchdog.Restart,
+	}
+	var err error
+	wcfg.Interval, err = watchdogDuration(cfg.Watchdog.Interval, config.DefaultWatchdogInterval)
+	if err != nil {
+		return fmt.Errorf("invalid Watchdog.Interval: %s", err), nil
+	}
+	wcfg.Timeout, err = watchdogDuration(cfg.Watchdog.Timeout, config.DefaultWatchdogTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid Watchdog.Timeout: %s", err), nil
+	}
+
+	apiAddr, err := fsrepo.APIAddr(req.InvocContext().ConfigRoot)
+	if err != nil {
+		return err, nil
+	}
+	apiNetAddr, err := manet.ToNetAddr(apiAddr)
+	if err != nil {
+		return err, nil
+	}
+	checks := append([]watchdog.Check{{
+		Name:  "api",
+		Probe: apiProbe("http://" + apiNetAddr.String() + "/api/v0/version"),
+	}}, node.WatchdogChecks()...)
+
+	wd := watchdog.New(wcfg, checks...)
+	errc := make(chan error)
+	go func() {
+		if err := wd.Run(req.Context()); err != nil {
+			errc <- err
+			node.Close()
+		}
+		close(errc)
+	}()
+	return nil, errc
+}
+
+// watchdogDuration parses a duration of the watchdog config, def if empty.
+func watchdogDuration(s, def string) (time.Duration, error) {
+	if s == "" {
+		s = def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s isn't positive", s)
+	}
+	return d, nil
+}
+
+// apiProbe returns a probe requesting url from the API server, which goes
+// through its listener and the commands handler.
+func apiProbe(url string) watchdog.Probe {
+	return func(ctx context.Context) error {
+		r, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(r.WithContext(ctx))
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		defer resp.Body.Close()
+		if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("api answered %s", resp.Status)
+		}
+		return nil
+	}
+}
+
 // merge does fan-in of multiple read-only error channels
 // taken from http://blog.golang.org/pipelines
 func merge(cs ...<-chan error) <-chan error {
@@ -653,6 +937,46 @@ func merge(cs ...<-chan error) <-chan error {
 	return out
 }
 
+// checkRepo checks the critical parts of the repo before a node is built on
+// it, and repairs them if the user agrees.
+func checkRepo(req cmds.Request, r repo.Repo) error {
+	problems, err := corerepo.CheckRepo(req.Context(), r)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	fmt.Println("Found problems in the repo, the node can't start on it:")
+	repairable := true
+	for _, p := range problems {
+		fmt.Printf("  %s\n", p)
+		fmt.Printf("    fix: %s\n", p.Fix)
+		repairable = repairable && p.Repairable()
+	}
+	if !repairable {
+		return errors.New("the repo must be repaired by hand")
+	}
+
+	dorecover, found, _ := req.Option(recoverKwd).Bool()
+	if !found {
+		dorecover = YesNoPrompt("Repair them now? [y/N]")
+	}
+	if !dorecover {
+		fmt.Println("Not repairing the repo now.")
+		return errors.New("the repo needs to be repaired, run 'ipfs daemon --recover'")
+	}
+
+	for _, p := range problems {
+		if err := p.Repair(req.Context()); err != nil {
+			return fmt.Errorf("repairing the %s failed: %s", p.Part, err)
+		}
+		fmt.Printf("Repaired the %s.\n", p.Part)
+	}
+	return nil
+}
+
 func YesNoPrompt(prompt string) bool {
 	var s string
 	for i := 0; i < 3; i++ {
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"time"
 
 	context "context"
 	assets "github.com/ipfs/go-ipfs/assets"
@@ -15,6 +17,9 @@ import (
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
 )
 
 const (
@@ -40,6 +45,7 @@ environment variable:
 	Options: []cmds.Option{
 		cmds.IntOption("bits", "b", "Number of bits to use in the generated RSA private key.").Default(nBitsForKeypairDefault),
 		cmds.BoolOption("empty-repo", "e", "Don't add and pin help files to the local storage.").Default(false),
+		cmds.StringOption("import-identity", "Use the identity exported to the given file by 'ipfs key export-identity'."),
 
 		// TODO need to decide whether to expose the override as a file or a
 		// directory. That is: should we allow the user to also specify the
@@ -96,7 +102,16 @@ environment variable:
 			}
 		}
 
-		if err := doInit(os.Stdout, req.InvocContext().ConfigRoot, empty, nBitsForKeypair, conf); err != nil {
+		var identity *config.IdentityBundle
+		if fname, found, _ := req.Option("import-identity").String(); found {
+			identity, err = readIdentityBundle(fname)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		if err := doInit(os.Stdout, req.InvocContext().ConfigRoot, empty, nBitsForKeypair, conf, identity); err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
@@ -108,10 +123,10 @@ Reinitializing would overwrite your keys.
 `)
 
 func initWithDefaults(out io.Writer, repoRoot string) error {
-	return doInit(out, repoRoot, false, nBitsForKeypairDefault, nil)
+	return doInit(out, repoRoot, false, nBitsForKeypairDefault, nil, nil)
 }
 
-func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, conf *config.Config) error {
+func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, conf *config.Config, identity *config.IdentityBundle) error {
 	if _, err := fmt.Fprintf(out, "initializing IPFS node at %s\n", repoRoot); err != nil {
 		return err
 	}
@@ -124,27 +139,123 @@ func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, con
 		return errRepoExists
 	}
 
-	if conf == nil {
-		var err error
+	var err error
+	switch {
+	case identity != nil && conf == nil:
+		conf, err = config.InitWithIdentity(identity.Identity)
+	case identity != nil:
+		conf.Identity = identity.Identity
+	case conf == nil:
 		conf, err = config.Init(out, nBitsForKeypair)
-		if err != nil {
-			return err
-		}
+	}
+	if err != nil {
+		return err
+	}
+	if identity != nil && len(identity.Peering) > 0 {
+		conf.Peering.Peers = identity.Peering
 	}
 
 	if err := fsrepo.Init(repoRoot, conf); err != nil {
 		return err
 	}
 
+	if identity != nil {
+		if err := importIdentity(out, repoRoot, identity); err != nil {
+			return err
+		}
+	}
+
 	if !empty {
 		if err := addDefaultAssets(out, repoRoot); err != nil {
 			return err
 		}
 	}
 
+	// keep publishing the imported name rather than an empty directory
+	if identity != nil && identity.Records["self"] != nil {
+		return nil
+	}
 	return initializeIpnsKeyspace(repoRoot)
 }
 
+func readIdentityBundle(fname string) (*config.IdentityBundle, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	identity := new(config.IdentityBundle)
+	if err := json.NewDecoder(f).Decode(identity); err != nil {
+		return nil, fmt.Errorf("invalid identity file: %s", err)
+	}
+
+	sk, err := identity.Identity.DecodePrivateKey("")
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity file: %s", err)
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	if id.Pretty() != identity.Identity.PeerID {
+		return nil, fmt.Errorf("invalid identity file: the private key doesn't match peer ID %s", identity.Identity.PeerID)
+	}
+	return identity, nil
+}
+
+// importIdentity stores the IPNS keys and records of an exported identity in
+// a new repo.
+func importIdentity(out io.Writer, repoRoot string, identity *config.IdentityBundle) error {
+	r, err := fsrepo.Open(repoRoot)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	keys := make(map[string]ci.PrivKey)
+	for name, enc := range identity.Keys {
+		b, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			return fmt.Errorf("invalid key %s: %s", name, err)
+		}
+		k, err := ci.UnmarshalPrivateKey(b)
+		if err != nil {
+			return fmt.Errorf("invalid key %s: %s", name, err)
+		}
+		if err := r.Keystore().Put(name, k); err != nil {
+			return err
+		}
+		keys[name] = k
+	}
+
+	sk, err := identity.Identity.DecodePrivateKey("")
+	if err != nil {
+		return err
+	}
+	keys["self"] = sk
+
+	for name, rec := range identity.Records {
+		k, ok := keys[name]
+		if !ok {
+			return fmt.Errorf("invalid identity file: record without a key for %s", name)
+		}
+		id, err := peer.IDFromPrivateKey(k)
+		if err != nil {
+			return err
+		}
+		if err := r.Datastore().Put(namesys.IpnsDsKey(id), rec); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(out, `imported identity %s with %d IPNS keys, exported on %s
+make sure the node it was exported from is stopped for good: two nodes with
+one identity disrupt each other
+`, identity.Identity.PeerID, len(identity.Keys), identity.Exported.Format(time.RFC1123))
+	return err
+}
+
 func checkWriteable(dir string) error {
 	_, err := os.Stat(dir)
 	if err == nil {
@@ -21,9 +21,10 @@ var commandsClientCmd = commands.CommandsCmd(Root)
 // Commands in localCommands should always be run locally (even if daemon is running).
 // They can override subcommands in commands.Root by defining a subcommand with the same name.
 var localCommands = map[string]*cmds.Command{
-	"daemon":   daemonCmd,
-	"init":     initCmd,
-	"commands": commandsClientCmd,
+	"daemon":    daemonCmd,
+	"init":      initCmd,
+	"commands":  commandsClientCmd,
+	"benchmark": benchmarkCmd,
 }
 var localMap = make(map[*cmds.Command]bool)
 
@@ -32,6 +33,9 @@ func init() {
 	// (some commands make references to Root)
 	Root.Subcommands = localCommands
 
+	// the subcommands of 'ipfs daemon' act on the running daemon
+	daemonCmd.Subcommands = commands.DaemonCmd.Subcommands
+
 	// copy all subcommands from commands.Root into this root (if they aren't already present)
 	for k, v := range commands.Root.Subcommands {
 		if _, found := Root.Subcommands[k]; !found {
@@ -103,9 +107,27 @@ var cmdDetailsMap = map[*cmds.Command]cmdDetails{
 	daemonCmd:                             {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
 	commandsClientCmd:                     {doesNotUseRepo: true},
 	commands.CommandsDaemonCmd:            {doesNotUseRepo: true},
+	benchmarkCmd:                          {doesNotUseRepo: true},
 	commands.VersionCmd:                   {doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
 	commands.LogCmd:                       {cannotRunOnClient: true},
 	commands.ActiveReqsCmd:                {cannotRunOnClient: true},
 	commands.RepoFsckCmd:                  {cannotRunOnDaemon: true},
 	commands.ConfigCmd.Subcommand("edit"): {cannotRunOnDaemon: true, doesNotUseRepo: true},
+
+	// the daemon must be stopped before its identity moves
+	commands.KeyCmd.Subcommand("export-identity"): {cannotRunOnDaemon: true},
+	commands.IDCmd.Subcommand("rotate"):           {cannotRunOnDaemon: true},
+
+	// needs the peers of a running daemon
+	commands.VersionCmd.Subcommand("check"): {cannotRunOnClient: true},
+
+	// the background pins live in the daemon
+	commands.PinCmd.Subcommand("status"): {cannotRunOnClient: true},
+	commands.PinCmd.Subcommand("jobs"):   {cannotRunOnClient: true},
+
+	// the events are the ones of the pins of the daemon
+	commands.PinCmd.Subcommand("events"): {cannotRunOnClient: true},
+
+	// the services are the ones of the running daemon
+	commands.DaemonCmd.Subcommand("restart-service"): {cannotRunOnClient: true},
 }
@@ -165,7 +165,8 @@ func mainRet() int {
 		if isClientError(err) {
 			printMetaHelp(os.Stderr)
 		}
-		return 1
+		// scripts branch on the codes of named errors
+		return cmds.ExitCode(err)
 	}
 
 	// everything went better than expected :)
@@ -431,7 +432,7 @@ func commandShouldRunOnDaemon(details cmdDetails, req cmds.Request, root *cmds.C
 			// check if daemon locked. legacy error text, for now.
 			log.Debugf("Command cannot run on daemon. Checking if daemon is locked")
 			if daemonLocked, _ := fsrepo.LockedByOtherProcess(req.InvocContext().ConfigRoot); daemonLocked {
-				return nil, cmds.ClientError("ipfs daemon is running. please stop it to run this command")
+				return nil, cmds.NamedClientError(cmds.ErrNameDaemonRunning, "ipfs daemon is running. please stop it to run this command")
 			}
 			return nil, nil
 		}
@@ -440,7 +441,7 @@ func commandShouldRunOnDaemon(details cmdDetails, req cmds.Request, root *cmds.C
 	}
 
 	if details.cannotRunOnClient {
-		return nil, cmds.ClientError("must run on the ipfs daemon")
+		return nil, cmds.NamedClientError(cmds.ErrNameDaemonRequired, "must run on the ipfs daemon")
 	}
 
 	return nil, nil
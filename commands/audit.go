@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-ipfs/repo/audit"
+)
+
+// AlwaysMutates is the Mutates function of commands whose requests all
+// change the state of the node.
+func AlwaysMutates(Request) bool {
+	return true
+}
+
+// AuditLogPath returns the path of the audit log of the repo at root.
+func AuditLogPath(root string) string {
+	return filepath.Join(root, audit.File)
+}
+
+// auditRequest records the request in the audit log, if it is enabled.
+func auditRequest(req Request, res Response) {
+	ctx := req.InvocContext()
+	cfg, err := ctx.GetConfig()
+	if err != nil {
+		log.Errorf("audit: loading the config: %s", err)
+		return
+	}
+	if !cfg.Audit.Enabled {
+		return
+	}
+
+	e := audit.Entry{
+		Time:      time.Now().UTC(),
+		Requester: Requester(req),
+		Command:   strings.Join(req.Path(), " "),
+		Arguments: req.Arguments(),
+	}
+	if err := res.Error(); err != nil {
+		e.Error = err.Message
+	}
+
+	if err := audit.Append(AuditLogPath(ctx.ConfigRoot), cfg.Audit.HashChain, e); err != nil {
+		log.Errorf("audit: %s", err)
+	}
+}
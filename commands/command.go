@@ -69,6 +69,21 @@ type Command struct {
 	// fewer checks and validations will be performed on such commands.
 	External bool
 
+	// Deprecated, if set, marks the command as deprecated. The value
+	// describes the replacement, and is reported to API clients along with
+	// the command's output.
+	Deprecated string
+
+	// Feature, if set, is the name of the feature the command and its
+	// subcommands belong to. They fail unless it is enabled in the config.
+	Feature string
+
+	// Mutates tells whether a request changes the state of the node, like
+	// pinning or writing the config. Those requests are recorded in the
+	// audit log, when it is enabled. Use AlwaysMutates for commands whose
+	// requests all do.
+	Mutates func(req Request) bool
+
 	// Type describes the type of the output of the Command's Run Function.
 	// In precise terms, the value of Type is an instance of the return type of
 	// the Run Function.
@@ -113,8 +128,21 @@ func (c *Command) Call(req Request) Response {
 		return res
 	}
 
+	if err := checkFeatures(req, cmds); err != nil {
+		res.SetError(err, ErrClient)
+		return res
+	}
+
+	if cmd.Deprecated != "" {
+		log.Warningf("deprecated command called: %s", cmd.Deprecated)
+	}
+
 	cmd.Run(req, res)
+	if cmd.Mutates != nil && cmd.Mutates(req) {
+		auditRequest(req, res)
+	}
 	if res.Error() != nil {
+		SetDoneError(req, res)
 		return res
 	}
 
@@ -137,6 +165,10 @@ func (c *Command) Call(req Request) Response {
 		} else if ch, ok := output.(chan interface{}); ok {
 			output = (<-chan interface{})(ch)
 		}
+
+		if ch, ok := output.(<-chan interface{}); ok && req.Context() != nil {
+			res.SetOutput(stopOnDone(req, res, ch))
+		}
 	}
 
 	// If the command specified an output type, ensure the actual value
@@ -153,6 +185,24 @@ func (c *Command) Call(req Request) Response {
 	return res
 }
 
+// checkFeatures returns an error if one of the commands of a path belongs
+// to a feature disabled in the config.
+func checkFeatures(req Request, path []*Command) error {
+	for _, c := range path {
+		if c.Feature == "" {
+			continue
+		}
+		cfg, err := req.InvocContext().GetConfig()
+		if err != nil {
+			return err
+		}
+		if !cfg.FeatureEnabled(c.Feature) {
+			return fmt.Errorf("the %s feature is disabled, enable it with 'ipfs features enable %s'", c.Feature, c.Feature)
+		}
+	}
+	return nil
+}
+
 // Resolve returns the subcommands at the given path
 func (c *Command) Resolve(pth []string) ([]*Command, error) {
 	cmds := make([]*Command, len(pth)+1)
@@ -305,3 +355,8 @@ func checkArgValue(v string, found bool, def Argument) error {
 func ClientError(msg string) error {
 	return &Error{Code: ErrClient, Message: msg}
 }
+
+// NamedClientError is like ClientError, for an error with a stable name.
+func NamedClientError(name, msg string) error {
+	return &Error{Code: ErrClient, Message: msg, Name: name}
+}
@@ -1,6 +1,12 @@
 package commands
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
 
 func noop(req Request, res Response) {
 	return
@@ -194,3 +200,90 @@ func TestHelpProcessing(t *testing.T) {
 		t.Error("LongDescription was not set on basis of ShortDescription")
 	}
 }
+
+func TestTimeout(t *testing.T) {
+	cmd := &Command{
+		Run: func(req Request, res Response) {
+			<-req.Context().Done()
+			res.SetError(req.Context().Err(), ErrNormal)
+		},
+	}
+
+	opts, _ := cmd.GetOptions(nil)
+	req, _ := NewRequest(nil, nil, nil, nil, cmd, opts)
+	req.SetOption(TimeoutOpt, "10ms")
+	if err := req.SetRootContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	res := cmd.Call(req)
+	if res.Error() == nil || res.Error().Code != ErrTimeout {
+		t.Fatalf("expected a timeout error, got %v", res.Error())
+	}
+}
+
+func TestTimeoutChannel(t *testing.T) {
+	cmd := &Command{
+		Run: func(req Request, res Response) {
+			out := make(chan interface{})
+			res.SetOutput((<-chan interface{})(out))
+			go func() {
+				defer close(out)
+				// ignores the deadline
+				for i := 0; i < 100; i++ {
+					out <- i
+					time.Sleep(time.Millisecond)
+				}
+			}()
+		},
+	}
+
+	opts, _ := cmd.GetOptions(nil)
+	req, _ := NewRequest(nil, nil, nil, nil, cmd, opts)
+	req.SetOption(TimeoutOpt, "10ms")
+	if err := req.SetRootContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	res := cmd.Call(req)
+	n := 0
+	for range res.Output().(<-chan interface{}) {
+		n++
+	}
+	if n == 100 {
+		t.Fatal("output should stop at the deadline")
+	}
+	if res.Error() == nil || res.Error().Code != ErrTimeout {
+		t.Fatalf("expected a timeout error, got %v", res.Error())
+	}
+}
+
+func TestFeatureGating(t *testing.T) {
+	cmd := &Command{
+		Subcommands: map[string]*Command{
+			"sub": {Run: noop},
+		},
+		Feature: "sharding",
+	}
+
+	call := func(cfg *config.Config) *Error {
+		opts, _ := cmd.GetOptions([]string{"sub"})
+		req, _ := NewRequest([]string{"sub"}, nil, nil, nil, cmd, opts)
+		req.SetInvocContext(Context{
+			LoadConfig: func(string) (*config.Config, error) { return cfg, nil },
+		})
+		return cmd.Call(req).Error()
+	}
+
+	if err := call(&config.Config{}); err == nil || err.Code != ErrClient {
+		t.Fatalf("expected the command of a disabled feature to fail, got %v", err)
+	}
+	cfg := &config.Config{Features: map[string]bool{"sharding": true}}
+	if err := call(cfg); err != nil {
+		t.Fatalf("expected the command of an enabled feature to run, got %s", err)
+	}
+	cfg = &config.Config{Experimental: config.Experiments{ShardingEnabled: true}}
+	if err := call(cfg); err != nil {
+		t.Fatalf("expected the legacy option to enable the feature, got %s", err)
+	}
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"sync"
+)
+
+// The stable names of errors. Scripts and clients branch on them rather than
+// on the messages, which are for humans and may change. They are part of the
+// API: the Name of the errors of the HTTP API, and the exit code of the CLI.
+const (
+	ErrNameInvalidPath      = "ErrInvalidPath"
+	ErrNamePathUnresolvable = "ErrPathUnresolvable"
+	ErrNameBlockNotFound    = "ErrBlockNotFound"
+	ErrNamePinNotFound      = "ErrPinNotFound"
+	ErrNameTimeout          = "ErrTimeout"
+	ErrNameCanceled         = "ErrCanceled"
+	ErrNameDaemonRequired   = "ErrDaemonRequired"
+	ErrNameDaemonRunning    = "ErrDaemonRunning"
+)
+
+// exitCodes are the exit codes of the CLI for the named errors. The other
+// errors exit with 1. The codes never change once given.
+var exitCodes = map[string]int{
+	ErrNameInvalidPath:      10,
+	ErrNamePathUnresolvable: 11,
+	ErrNameBlockNotFound:    12,
+	ErrNamePinNotFound:      13,
+	ErrNameTimeout:          20,
+	ErrNameCanceled:         21,
+	ErrNameDaemonRequired:   30,
+	ErrNameDaemonRunning:    31,
+}
+
+// ExitCode returns the exit code of the CLI for err.
+func ExitCode(err error) int {
+	if code, ok := exitCodes[ErrorName(err)]; ok {
+		return code
+	}
+	return 1
+}
+
+// NamedError gives a stable name to an error.
+type NamedError struct {
+	Name string
+	Err  error
+}
+
+// WithName names err, which keeps its message.
+func WithName(name string, err error) error {
+	return &NamedError{Name: name, Err: err}
+}
+
+func (e *NamedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *NamedError) ErrorName() string {
+	return e.Name
+}
+
+// ErrorName returns the stable name of err, or "" if it has none. Errors are
+// named by an ErrorName method, or by the matchers registered for them. The
+// name of an error wrapping another one with a Cause method is the one of
+// the cause, if it has none of its own.
+func ErrorName(err error) string {
+	for err != nil {
+		if n, ok := err.(interface {
+			ErrorName() string
+		}); ok && n.ErrorName() != "" {
+			return n.ErrorName()
+		}
+
+		errMatchersLk.RLock()
+		for _, m := range errMatchers {
+			if m.match(err) {
+				errMatchersLk.RUnlock()
+				return m.name
+			}
+		}
+		errMatchersLk.RUnlock()
+
+		c, ok := err.(interface {
+			Cause() error
+		})
+		if !ok {
+			return ""
+		}
+		err = c.Cause()
+	}
+	return ""
+}
+
+type errMatcher struct {
+	name  string
+	match func(error) bool
+}
+
+var (
+	errMatchersLk sync.RWMutex
+	errMatchers   []errMatcher
+)
+
+// RegisterErrorName names the errors for which match returns true, for the
+// errors of packages which don't know about commands.
+func RegisterErrorName(name string, match func(error) bool) {
+	errMatchersLk.Lock()
+	defer errMatchersLk.Unlock()
+	errMatchers = append(errMatchers, errMatcher{name: name, match: match})
+}
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type causeErr struct {
+	msg   string
+	cause error
+}
+
+func (e *causeErr) Error() string { return e.msg }
+func (e *causeErr) Cause() error  { return e.cause }
+
+func TestErrorName(t *testing.T) {
+	errPinNotFound := errors.New("not pinned")
+	errOther := errors.New("something else")
+	RegisterErrorName(ErrNamePinNotFound, func(err error) bool {
+		return err == errPinNotFound
+	})
+
+	cases := []struct {
+		err  error
+		name string
+		code int
+	}{
+		{errOther, "", 1},
+		{WithName(ErrNameInvalidPath, errOther), ErrNameInvalidPath, 10},
+		{errPinNotFound, ErrNamePinNotFound, 13},
+		{&causeErr{"pin: not pinned", errPinNotFound}, ErrNamePinNotFound, 13},
+		{&causeErr{"wrapped", errOther}, "", 1},
+		{&causeErr{"named", WithName(ErrNameTimeout, errOther)}, ErrNameTimeout, 20},
+		{WithName(ErrNameBlockNotFound, errPinNotFound), ErrNameBlockNotFound, 12},
+		{&Error{Message: "x", Name: ErrNameDaemonRunning}, ErrNameDaemonRunning, 31},
+		{ErrCanceled, ErrNameCanceled, 21},
+	}
+	for i, c := range cases {
+		if n := ErrorName(c.err); n != c.name {
+			t.Errorf("case %d: name %q, expected %q", i, n, c.name)
+		}
+		if code := ExitCode(c.err); code != c.code {
+			t.Errorf("case %d: exit code %d, expected %d", i, code, c.code)
+		}
+	}
+
+	if WithName(ErrNameInvalidPath, errOther).Error() != errOther.Error() {
+		t.Error("naming an error should keep its message")
+	}
+}
+
+func TestErrorNameMarshalling(t *testing.T) {
+	cmd := &Command{}
+	opts, _ := cmd.GetOptions(nil)
+	req, _ := NewRequest(nil, nil, nil, nil, nil, opts)
+	req.SetOption(EncShort, JSON)
+
+	res := NewResponse(req)
+	res.SetError(WithName(ErrNameInvalidPath, fmt.Errorf("bad path")), ErrNormal)
+	reader, err := res.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(reader)
+	expected := `{"Message":"bad path","Code":0,"Name":"ErrInvalidPath"}`
+	if removeWhitespace(buf.String()) != expected {
+		t.Errorf("incorrect JSON error: %s", buf.String())
+	}
+
+	res = NewResponse(req)
+	res.SetError(fmt.Errorf("no name"), ErrNormal)
+	reader, err = res.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	buf.ReadFrom(reader)
+	if removeWhitespace(buf.String()) != `{"Message":"noname","Code":0}` {
+		t.Errorf("errors without a name should have no Name: %s", buf.String())
+	}
+}
@@ -99,6 +99,11 @@ func (c *client) Send(req cmds.Request) (cmds.Response, error) {
 
 	httpRes, err := c.httpClient.Do(httpReq)
 	if err != nil {
+		if cmds.TimedOut(req) {
+			res := cmds.NewResponse(req)
+			res.SetError(cmds.TimeoutError(req), cmds.ErrTimeout)
+			return res, nil
+		}
 		return nil, err
 	}
 
@@ -243,7 +248,9 @@ func readStreamedJson(req cmds.Request, rr io.Reader, out chan<- interface{}, re
 	for {
 		v, err := decodeTypedVal(outputType, dec)
 		if err != nil {
-			if err != io.EOF {
+			if cmds.TimedOut(req) {
+				resp.SetError(cmds.TimeoutError(req), cmds.ErrTimeout)
+			} else if err != io.EOF {
 				log.Error(err)
 				resp.SetError(err, cmds.ErrNormal)
 			}
@@ -252,6 +259,9 @@ func readStreamedJson(req cmds.Request, rr io.Reader, out chan<- interface{}, re
 
 		select {
 		case <-ctx.Done():
+			if cmds.TimedOut(req) {
+				resp.SetError(cmds.TimeoutError(req), cmds.ErrTimeout)
+			}
 			return
 		case out <- v:
 		}
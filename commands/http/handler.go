@@ -5,12 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/repo/config"
@@ -45,6 +47,7 @@ const (
 	streamHeader             = "X-Stream-Output"
 	channelHeader            = "X-Chunked-Output"
 	extraContentLengthHeader = "X-Content-Length"
+	deprecatedHeader         = "X-Ipfs-Deprecated"
 	uaHeader                 = "User-Agent"
 	contentTypeHeader        = "Content-Type"
 	contentDispHeader        = "Content-Disposition"
@@ -55,7 +58,11 @@ const (
 	originHeader             = "origin"
 )
 
-var AllowedExposedHeadersArr = []string{streamHeader, channelHeader, extraContentLengthHeader}
+// abortGrace is how long a command may take to return once its request is
+// canceled.
+var abortGrace = 5 * time.Second
+
+var AllowedExposedHeadersArr = []string{streamHeader, channelHeader, extraContentLengthHeader, deprecatedHeader}
 var AllowedExposedHeaders = strings.Join(AllowedExposedHeadersArr, ", ")
 
 const (
@@ -170,20 +177,20 @@ func (i internalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rlog := i.ctx.ReqLog.Add(req)
+	rlog := i.ctx.ReqLog.Add(req, cancel)
 	defer rlog.Finish()
 
 	//ps: take note of the name clash - commands.Context != context.Context
 	req.SetInvocContext(i.ctx)
 
-	err = req.SetRootContext(ctx)
+	err = req.SetRootContext(cmds.WithRequester(ctx, requester(r)))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// call the command
-	res := i.root.Call(req)
+	res := i.call(req)
 
 	// set user's headers first.
 	for k, v := range i.cfg.Headers {
@@ -196,6 +203,42 @@ func (i internalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	sendResponse(w, r, res, req)
 }
 
+// requester returns who made an API request: the address it came from. The
+// API doesn't authenticate its clients, the user names they send can't be
+// trusted.
+func requester(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// call runs the command of req. Once the request is canceled or times out,
+// the command is given abortGrace to return before it is abandoned, so that
+// a wedged command doesn't hold the request forever.
+func (i internalHandler) call(req cmds.Request) cmds.Response {
+	done := make(chan cmds.Response, 1)
+	go func() {
+		done <- i.root.Call(req)
+	}()
+
+	select {
+	case res := <-done:
+		return res
+	case <-req.Context().Done():
+	}
+
+	select {
+	case res := <-done:
+		return res
+	case <-time.After(abortGrace):
+		log.Warningf("command %s did not return after its request was canceled, abandoning it", strings.Join(req.Path(), "/"))
+		res := cmds.NewResponse(req)
+		cmds.SetDoneError(req, res)
+		return res
+	}
+}
+
 func guessMimeType(res cmds.Response) (string, error) {
 	// Try to guess mimeType from the encoding option
 	enc, found, err := res.Request().Option(cmds.EncShort).String()
@@ -227,9 +270,12 @@ func sendResponse(w http.ResponseWriter, r *http.Request, res cmds.Response, req
 	status := http.StatusOK
 	// if response contains an error, write an HTTP error status code
 	if e := res.Error(); e != nil {
-		if e.Code == cmds.ErrClient {
+		switch e.Code {
+		case cmds.ErrClient:
 			status = http.StatusBadRequest
-		} else {
+		case cmds.ErrTimeout:
+			status = http.StatusRequestTimeout
+		default:
 			status = http.StatusInternalServerError
 		}
 		// NOTE: The error will actually be written out by the reader below
@@ -248,6 +294,10 @@ func sendResponse(w http.ResponseWriter, r *http.Request, res cmds.Response, req
 		h.Set("X-Content-Length", strconv.FormatUint(res.Length(), 10))
 	}
 
+	if cmd := req.Command(); cmd != nil && cmd.Deprecated != "" {
+		h.Set(deprecatedHeader, cmd.Deprecated)
+	}
+
 	if _, ok := res.Output().(io.Reader); ok {
 		// set streams output type to text to avoid issues with browsers rendering
 		// html pages on priveleged api ports
@@ -194,7 +194,7 @@ const (
 var OptionEncodingType = StringOption(EncLong, EncShort, "The encoding type the output should be encoded with (json, xml, or text)")
 var OptionRecursivePath = BoolOption(RecLong, RecShort, "Add directory paths recursively").Default(false)
 var OptionStreamChannels = BoolOption(ChanOpt, "Stream channel output")
-var OptionTimeout = StringOption(TimeoutOpt, "set a global timeout on the command")
+var OptionTimeout = StringOption(TimeoutOpt, "set a global timeout on the command, the command fails once it passes")
 
 // global options, added to every command
 var globalOptions = []Option{
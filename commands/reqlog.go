@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -15,8 +16,12 @@ type ReqLogEntry struct {
 	Args      []string
 	ID        int
 
-	req Request
-	log *ReqLog
+	// Canceled is true if the request was canceled with ReqLog.Cancel.
+	Canceled bool
+
+	req    Request
+	log    *ReqLog
+	cancel func()
 }
 
 func (r *ReqLogEntry) Finish() {
@@ -31,12 +36,14 @@ func (r *ReqLogEntry) Finish() {
 	// remove references to save memory
 	r.req = nil
 	r.log = nil
+	r.cancel = nil
 
 }
 
 func (r *ReqLogEntry) Copy() *ReqLogEntry {
 	out := *r
 	out.log = nil
+	out.cancel = nil
 	return &out
 }
 
@@ -47,7 +54,9 @@ type ReqLog struct {
 	keep     time.Duration
 }
 
-func (rl *ReqLog) Add(req Request) *ReqLogEntry {
+// Add records a new active request. cancel aborts the request, it is called
+// when the request is canceled with Cancel.
+func (rl *ReqLog) Add(req Request, cancel func()) *ReqLogEntry {
 	rl.lock.Lock()
 	defer rl.lock.Unlock()
 
@@ -60,6 +69,7 @@ func (rl *ReqLog) Add(req Request) *ReqLogEntry {
 		ID:        rl.nextID,
 		req:       req,
 		log:       rl,
+		cancel:    cancel,
 	}
 
 	rl.nextID++
@@ -67,6 +77,25 @@ func (rl *ReqLog) Add(req Request) *ReqLogEntry {
 	return rle
 }
 
+// Cancel aborts the active request with the given ID.
+func (rl *ReqLog) Cancel(id int) error {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	for _, r := range rl.Requests {
+		if r.ID != id {
+			continue
+		}
+		if !r.Active || r.cancel == nil {
+			return fmt.Errorf("request %d is not active", id)
+		}
+		r.Canceled = true
+		r.cancel()
+		return nil
+	}
+	return fmt.Errorf("no request with id %d", id)
+}
+
 func (rl *ReqLog) ClearInactive() {
 	rl.lock.Lock()
 	defer rl.lock.Unlock()
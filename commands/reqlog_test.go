@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"testing"
+)
+
+func TestReqLogCancel(t *testing.T) {
+	rl := new(ReqLog)
+	req, _ := NewEmptyRequest()
+
+	canceled := false
+	e := rl.Add(req, func() { canceled = true })
+
+	if err := rl.Cancel(e.ID + 1); err == nil {
+		t.Fatal("canceling an unknown request should fail")
+	}
+
+	if err := rl.Cancel(e.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !canceled || !rl.Report()[0].Canceled {
+		t.Fatal("request should be canceled")
+	}
+
+	e.Finish()
+	if err := rl.Cancel(e.ID); err == nil {
+		t.Fatal("canceling a finished request should fail")
+	}
+}
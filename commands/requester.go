@@ -0,0 +1,26 @@
+package commands
+
+import "context"
+
+type requesterKey struct{}
+
+// LocalRequester is who made requests run in the process of the command line
+// rather than through the API.
+const LocalRequester = "local"
+
+// WithRequester returns a context telling who made the request, like the
+// user an API request was authenticated as.
+func WithRequester(ctx context.Context, who string) context.Context {
+	return context.WithValue(ctx, requesterKey{}, who)
+}
+
+// Requester returns who made the request, as set with WithRequester on its
+// root context.
+func Requester(req Request) string {
+	if ctx := req.Context(); ctx != nil {
+		if who, ok := ctx.Value(requesterKey{}).(string); ok {
+			return who
+		}
+	}
+	return LocalRequester
+}
@@ -19,6 +19,7 @@ const (
 	ErrClient                          // error was caused by the client, (e.g. invalid CLI usage)
 	ErrImplementation                  // programmer error in the server
 	ErrNotFound                        // == HTTP 404 Not Found
+	ErrTimeout                         // the request deadline passed
 	// TODO: add more types of errors for better error-specific handling
 )
 
@@ -26,12 +27,18 @@ const (
 type Error struct {
 	Message string
 	Code    ErrorType
+	// the stable name of the error, if it has one, see ErrorName
+	Name string `json:",omitempty"`
 }
 
 func (e Error) Error() string {
 	return e.Message
 }
 
+func (e Error) ErrorName() string {
+	return e.Name
+}
+
 // EncodingType defines a supported encoding
 type EncodingType string
 
@@ -157,7 +164,7 @@ func (r *response) Error() *Error {
 }
 
 func (r *response) SetError(err error, code ErrorType) {
-	r.err = &Error{Message: err.Error(), Code: code}
+	r.err = &Error{Message: err.Error(), Code: code, Name: ErrorName(err)}
 }
 
 func (r *response) Marshal() (io.Reader, error) {
@@ -54,7 +54,7 @@ func TestMarshalling(t *testing.T) {
 }
 
 func TestErrTypeOrder(t *testing.T) {
-	if ErrNormal != 0 || ErrClient != 1 || ErrImplementation != 2 || ErrNotFound != 3 {
+	if ErrNormal != 0 || ErrClient != 1 || ErrImplementation != 2 || ErrNotFound != 3 || ErrTimeout != 4 {
 		t.Fatal("ErrType order is wrong")
 	}
 }
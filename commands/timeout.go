@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrCanceled is reported for requests canceled before their command
+// finished.
+var ErrCanceled = WithName(ErrNameCanceled, errors.New("request canceled"))
+
+// TimedOut returns true if the request was canceled because its deadline,
+// set with the global timeout option, passed.
+func TimedOut(req Request) bool {
+	ctx := req.Context()
+	return ctx != nil && ctx.Err() == context.DeadlineExceeded
+}
+
+// TimeoutError returns the error reported for requests that timed out.
+func TimeoutError(req Request) error {
+	tout, _, _ := req.Option(TimeoutOpt).String()
+	return WithName(ErrNameTimeout, fmt.Errorf("command timed out after %s", tout))
+}
+
+// SetDoneError reports why the request stopped, if its context is done,
+// rather than the error the command got from whatever it was waiting on.
+func SetDoneError(req Request, res Response) {
+	ctx := req.Context()
+	if ctx == nil {
+		return
+	}
+
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		res.SetError(TimeoutError(req), ErrTimeout)
+	case context.Canceled:
+		res.SetError(ErrCanceled, ErrNormal)
+	}
+}
+
+// stopOnDone forwards the values of ch until the request is canceled or its
+// deadline passes. The command may keep producing values after that, they
+// are drained so that it doesn't block forever.
+func stopOnDone(req Request, res Response, ch <-chan interface{}) <-chan interface{} {
+	ctx := req.Context()
+	if ctx.Done() == nil {
+		return ch
+	}
+
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok {
+					if res.Error() != nil {
+						SetDoneError(req, res)
+					}
+					return
+				}
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					go drain(ch)
+					SetDoneError(req, res)
+					return
+				}
+			case <-ctx.Done():
+				go drain(ch)
+				SetDoneError(req, res)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func drain(ch <-chan interface{}) {
+	for range ch {
+	}
+}
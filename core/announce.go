@@ -0,0 +1,143 @@
+package core
+
+import (
+	"fmt"
+	"net"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	p2pbhost "gx/ipfs/QmRai5yZNL67pWCoznW7sBdFnqZrFULuJ5w8KhmRyhdgN4/go-libp2p/p2p/host/basic"
+	mamask "gx/ipfs/QmSMZwvs3n4GBikZ7hKzT17c3bk65FmyZo2JqtJ16swqCv/multiaddr-filter"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+)
+
+// makeAddrsFactory returns the function the host uses to compute the
+// addresses it announces, from the Announce, AppendAnnounce, NoAnnounce and
+// NoAnnounceInterfaces settings.
+func makeAddrsFactory(cfg config.Addresses) (p2pbhost.AddrsFactory, error) {
+	annAddrs, err := parseAddrs(cfg.Announce)
+	if err != nil {
+		return nil, fmt.Errorf("incorrectly formatted announce address in config: %s", err)
+	}
+	appendAddrs, err := parseAddrs(cfg.AppendAnnounce)
+	if err != nil {
+		return nil, fmt.Errorf("incorrectly formatted append announce address in config: %s", err)
+	}
+
+	var masks []*net.IPNet
+	noAnnAddrs := make(map[string]bool)
+	for _, s := range cfg.NoAnnounce {
+		f, err := mamask.NewMask(s)
+		if err == nil {
+			masks = append(masks, f)
+			continue
+		}
+		maddr, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("incorrectly formatted no announce address in config: %s", s)
+		}
+		noAnnAddrs[maddr.String()] = true
+	}
+
+	for _, name := range cfg.NoAnnounceInterfaces {
+		if _, err := net.InterfaceByName(name); err != nil {
+			// interfaces may come and go, don't fail on one that isn't up
+			log.Warningf("no announce interface %s: %s", name, err)
+		}
+	}
+
+	return func(allAddrs []ma.Multiaddr) []ma.Multiaddr {
+		addrs := allAddrs
+		if len(annAddrs) > 0 {
+			addrs = annAddrs
+		}
+
+		// interface addresses are looked up every time as they may change
+		ifmasks := interfaceMasks(cfg.NoAnnounceInterfaces)
+
+		var out []ma.Multiaddr
+		for _, maddr := range addrs {
+			if noAnnAddrs[maddr.String()] {
+				continue
+			}
+			if ip := addrIP(maddr); ip != nil && (ipBlocked(ip, masks) || ipBlocked(ip, ifmasks)) {
+				continue
+			}
+			out = append(out, maddr)
+		}
+
+		for _, maddr := range appendAddrs {
+			if !containsAddr(out, maddr) {
+				out = append(out, maddr)
+			}
+		}
+		return out
+	}, nil
+}
+
+func parseAddrs(strs []string) ([]ma.Multiaddr, error) {
+	var addrs []ma.Multiaddr
+	for _, s := range strs {
+		maddr, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", s, err)
+		}
+		addrs = append(addrs, maddr)
+	}
+	return addrs, nil
+}
+
+// interfaceMasks returns the host networks of the addresses of the named
+// interfaces.
+func interfaceMasks(names []string) []*net.IPNet {
+	var masks []*net.IPNet
+	for _, name := range names {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			log.Debugf("could not get addresses of interface %s: %s", name, err)
+			continue
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			bits := 8 * len(ipnet.IP)
+			masks = append(masks, &net.IPNet{IP: ipnet.IP, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+	return masks
+}
+
+// addrIP returns the IP address of maddr, or nil if it has none.
+func addrIP(maddr ma.Multiaddr) net.IP {
+	if v, err := maddr.ValueForProtocol(ma.P_IP4); err == nil {
+		return net.ParseIP(v)
+	}
+	if v, err := maddr.ValueForProtocol(ma.P_IP6); err == nil {
+		return net.ParseIP(v)
+	}
+	return nil
+}
+
+func ipBlocked(ip net.IP, masks []*net.IPNet) bool {
+	for _, m := range masks {
+		if m.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAddr(addrs []ma.Multiaddr, maddr ma.Multiaddr) bool {
+	for _, a := range addrs {
+		if a.Equal(maddr) {
+			return true
+		}
+	}
+	return false
+}
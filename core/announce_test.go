@@ -0,0 +1,62 @@
+package core
+
+import (
+	"testing"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestAddrsFactory(t *testing.T) {
+	listen, err := parseAddrs([]string{
+		"/ip4/127.0.0.1/tcp/4001",
+		"/ip4/10.1.2.3/tcp/4001",
+		"/ip4/192.168.1.5/tcp/4001",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		cfg    config.Addresses
+		expect []string
+	}{
+		{
+			cfg:    config.Addresses{},
+			expect: []string{"/ip4/127.0.0.1/tcp/4001", "/ip4/10.1.2.3/tcp/4001", "/ip4/192.168.1.5/tcp/4001"},
+		},
+		{
+			cfg: config.Addresses{
+				NoAnnounce:     []string{"/ip4/10.0.0.0/ipcidr/8", "/ip4/127.0.0.1/tcp/4001"},
+				AppendAnnounce: []string{"/ip4/1.2.3.4/tcp/4001"},
+			},
+			expect: []string{"/ip4/192.168.1.5/tcp/4001", "/ip4/1.2.3.4/tcp/4001"},
+		},
+		{
+			cfg: config.Addresses{
+				Announce:       []string{"/ip4/1.2.3.4/tcp/4001"},
+				AppendAnnounce: []string{"/ip4/1.2.3.4/tcp/4001", "/ip4/5.6.7.8/tcp/4001"},
+			},
+			expect: []string{"/ip4/1.2.3.4/tcp/4001", "/ip4/5.6.7.8/tcp/4001"},
+		},
+	}
+
+	for i, c := range cases {
+		f, err := makeAddrsFactory(c.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := f(listen)
+		if len(out) != len(c.expect) {
+			t.Fatalf("case %d: expected %v, got %v", i, c.expect, out)
+		}
+		for j, a := range out {
+			if a.String() != c.expect[j] {
+				t.Fatalf("case %d: expected %v, got %v", i, c.expect, out)
+			}
+		}
+	}
+
+	if _, err := makeAddrsFactory(config.Addresses{AppendAnnounce: []string{"not an addr"}}); err == nil {
+		t.Fatal("expected an invalid address to be rejected")
+	}
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+// AnnounceFilterConstructor builds an announce filter for a node. It's called
+// when the node goes online, before its blocks are announced.
+type AnnounceFilterConstructor func(n *IpfsNode) (exchange.AnnounceFilter, error)
+
+// RulesAnnounceFilter is the name of the filter applying the rules of the
+// Reprovider config. It is registered by the corerepo package, which keeps the
+// pin names the rules match on.
+const RulesAnnounceFilter = "rules"
+
+var announceFiltersLk sync.Mutex
+
+var announceFilters = make(map[string]AnnounceFilterConstructor)
+
+// RegisterAnnounceFilter makes an announce filter available to the
+// Reprovider.Filters config of the nodes constructed afterwards. It is meant
+// to be called from init functions.
+func RegisterAnnounceFilter(name string, c AnnounceFilterConstructor) error {
+	if name == "" {
+		return fmt.Errorf("invalid announce filter name %q", name)
+	}
+
+	announceFiltersLk.Lock()
+	defer announceFiltersLk.Unlock()
+
+	if _, ok := announceFilters[name]; ok {
+		return fmt.Errorf("announce filter %s is already registered", name)
+	}
+	announceFilters[name] = c
+	return nil
+}
+
+// setupAnnounceFilter builds the announce filters enabled in the config, and
+// applies them to the reprovider and to the blocks added to bitswap.
+func (n *IpfsNode) setupAnnounceFilter(cfg config.Reprovider) error {
+	names := cfg.Filters
+	if len(cfg.Rules) > 0 {
+		names = append([]string{RulesAnnounceFilter}, names...)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	var filters []exchange.AnnounceFilter
+	for _, name := range names {
+		announceFiltersLk.Lock()
+		c, ok := announceFilters[name]
+		announceFiltersLk.Unlock()
+		if !ok {
+			return fmt.Errorf("announce filter %s is not registered", name)
+		}
+
+		f, err := c(n)
+		if err != nil {
+			return fmt.Errorf("announce filter %s: %s", name, err)
+		}
+		filters = append(filters, f)
+	}
+
+	n.AnnounceFilter = exchange.AllAnnounceFilters(filters...)
+	if n.Reprovider != nil {
+		n.Reprovider.Filter = n.AnnounceFilter
+	}
+	if bs := n.Bitswap(); bs != nil {
+		bs.SetAnnounceFilter(n.AnnounceFilter)
+	}
+	return nil
+}
@@ -5,19 +5,26 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"os"
 	"syscall"
 	"time"
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	budget "github.com/ipfs/go-ipfs/exchange/budget"
+	httpfetch "github.com/ipfs/go-ipfs/exchange/httpfetch"
 	offline "github.com/ipfs/go-ipfs/exchange/offline"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	pin "github.com/ipfs/go-ipfs/pin"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+	pinsync "github.com/ipfs/go-ipfs/pin/pinsync"
 	repo "github.com/ipfs/go-ipfs/repo"
 	cfg "github.com/ipfs/go-ipfs/repo/config"
+	sharing "github.com/ipfs/go-ipfs/sharing"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
@@ -177,7 +184,7 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 	}
 
 	// TEMP: setting global sharding switch here
-	uio.UseHAMTSharding = conf.Experimental.ShardingEnabled
+	uio.UseHAMTSharding = conf.FeatureEnabled("sharding")
 
 	opts.HasBloomFilterSize = conf.Datastore.BloomFilterSize
 	if !cfg.Permament {
@@ -193,11 +200,32 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 	n.GCLocker = bstore.NewGCLocker()
 	n.Blockstore = bstore.NewGCBlockstore(cbs, n.GCLocker)
 
-	if conf.Experimental.FilestoreEnabled {
+	if conf.FeatureEnabled("filestore") {
 		n.Filestore = filestore.NewFilestore(bs, n.Repo.FileManager())
 		n.Blockstore = bstore.NewGCBlockstore(n.Filestore, n.GCLocker)
 	}
 
+	if conf.Datastore.TrackAccessTimes {
+		granularity := kAccessTimeGranularity
+		if conf.Datastore.AccessTimeGranularity != "" {
+			granularity, err = time.ParseDuration(conf.Datastore.AccessTimeGranularity)
+			if err != nil {
+				return fmt.Errorf("invalid Datastore.AccessTimeGranularity: %s", err)
+			}
+		}
+
+		n.AccessTimes = bstore.NewAccessTracker(ctx, n.Blockstore, n.Repo.Datastore(), granularity)
+		n.Blockstore = bstore.NewGCBlockstore(n.AccessTimes, n.GCLocker)
+	}
+
+	if conf.Datastore.ChangeFeed {
+		n.Changes, err = bstore.NewChangeFeed(n.Blockstore, n.Repo.Datastore())
+		if err != nil {
+			return err
+		}
+		n.Blockstore = bstore.NewGCBlockstore(n.Changes, n.GCLocker)
+	}
+
 	rcfg, err := n.Repo.Config()
 	if err != nil {
 		return err
@@ -216,24 +244,58 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		n.Exchange = offline.Exchange(n.Blockstore)
 	}
 
-	n.Blocks = bserv.New(n.Blockstore, n.Exchange)
+	ex := n.Exchange
+	if cfg.Online && len(rcfg.Exchange.HTTPSources) > 0 {
+		ex = httpfetch.New(ex, rcfg.Exchange.HTTPSources)
+	}
+	if cfg.Online {
+		ex = budget.New(ex, requestLimits(rcfg.Exchange))
+	}
+
+	n.Blocks = bserv.New(n.Blockstore, ex)
 	n.DAG = dag.NewDAGService(n.Blocks)
 
 	internalDag := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
 	n.Pinning, err = pin.LoadPinner(n.Repo.Datastore(), n.DAG, internalDag)
 	if err != nil {
-		// TODO: we should move towards only running 'NewPinner' explicity on
-		// node init instead of implicitly here as a result of the pinner keys
-		// not being found in the datastore.
-		// this is kinda sketchy and could cause data loss
-		n.Pinning = pin.NewPinner(n.Repo.Datastore(), n.DAG, internalDag)
+		// starting without the pins would let the gc remove pinned data
+		return fmt.Errorf("%s (run 'ipfs daemon --recover' to repair the repo)", err)
+	}
+	n.GCMarks = gc.NewMarkCache()
+	if cfg.Permament {
+		// the jobs would die with a short lived node
+		n.PinJobs = NewPinQueue(ctx)
 	}
 	n.Resolver = path.NewBasicResolver(n.DAG)
 
+	if n.Floodsub != nil {
+		n.PinSync, err = pinsync.New(ctx, n.Floodsub, n.PeerHost, n.Pinning, n.DAG, n.Blockstore, n.Repo.Datastore(), n.PrivateKey)
+		if err != nil {
+			return err
+		}
+		n.Sharing, err = sharing.New(ctx, n.Floodsub, n.PeerHost, n.Repo.Datastore(), n.PrivateKey)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = n.loadFilesRoot()
 	if err != nil {
-		return err
+		return fmt.Errorf("cannot load the files root: %s (run 'ipfs daemon --recover' to repair the repo)", err)
 	}
 
 	return nil
 }
+
+// requestLimits returns the concurrency budgets of the block request
+// classes, from the defaults and the config.
+func requestLimits(conf cfg.Exchange) map[exchange.Class]int {
+	limits := make(map[exchange.Class]int)
+	for _, c := range []exchange.Class{exchange.Interactive, exchange.Background, exchange.Bulk} {
+		limits[c] = budget.DefaultLimits[c]
+		if n, ok := conf.MaxConcurrentRequests[c.String()]; ok {
+			limits[c] = n
+		}
+	}
+	return limits
+}
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	metrics "gx/ipfs/QmVMbSdq6PbznPC83SENVhH7JZn3BqqxkKgrHJFN2RuARf/go-libp2p-metrics"
+	pro "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// bandwidthLimiter is a metrics.Reporter that enforces a bandwidth schedule.
+// The swarm reports the bytes read and written on each stream after the fact,
+// the limiter holds the stream back until the rates are under their caps.
+type bandwidthLimiter struct {
+	metrics.Reporter
+
+	limits []*scheduledLimit
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+type scheduledLimit struct {
+	start, end int // minutes since midnight
+	protocols  []string
+
+	in, out *bucket
+}
+
+// bucket is a token bucket holding up to a second of transfer.
+type bucket struct {
+	lk    sync.Mutex
+	rate  float64 // bytes per second
+	avail float64
+	last  time.Time
+}
+
+func newBandwidthLimiter(sched []config.BandwidthLimit, rep metrics.Reporter) (*bandwidthLimiter, error) {
+	bl := &bandwidthLimiter{
+		Reporter: rep,
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+
+	for i, l := range sched {
+		start, err := parseTimeOfDay(l.Start)
+		if err != nil {
+			return nil, fmt.Errorf("bandwidth schedule %d: %s", i, err)
+		}
+		end, err := parseTimeOfDay(l.End)
+		if err != nil {
+			return nil, fmt.Errorf("bandwidth schedule %d: %s", i, err)
+		}
+		in, err := newBucket(l.MaxIn)
+		if err != nil {
+			return nil, fmt.Errorf("bandwidth schedule %d: %s", i, err)
+		}
+		out, err := newBucket(l.MaxOut)
+		if err != nil {
+			return nil, fmt.Errorf("bandwidth schedule %d: %s", i, err)
+		}
+
+		bl.limits = append(bl.limits, &scheduledLimit{
+			start:     start,
+			end:       end,
+			protocols: l.Protocols,
+			in:        in,
+			out:       out,
+		})
+	}
+	return bl, nil
+}
+
+func (bl *bandwidthLimiter) LogSentMessageStream(size int64, proto pro.ID, p peer.ID) {
+	bl.Reporter.LogSentMessageStream(size, proto, p)
+	bl.wait(size, proto, false)
+}
+
+func (bl *bandwidthLimiter) LogRecvMessageStream(size int64, proto pro.ID, p peer.ID) {
+	bl.Reporter.LogRecvMessageStream(size, proto, p)
+	bl.wait(size, proto, true)
+}
+
+// wait sleeps as long as the active limits of the protocol need to catch up
+// with size more bytes.
+func (bl *bandwidthLimiter) wait(size int64, proto pro.ID, in bool) {
+	now := bl.now()
+
+	var delay time.Duration
+	for _, l := range bl.limits {
+		if !l.active(now) || !l.matches(proto) {
+			continue
+		}
+
+		b := l.out
+		if in {
+			b = l.in
+		}
+		if b == nil {
+			continue
+		}
+		if d := b.take(size, now); d > delay {
+			delay = d
+		}
+	}
+
+	if delay > 0 {
+		bl.sleep(delay)
+	}
+}
+
+func (l *scheduledLimit) active(now time.Time) bool {
+	m := now.Hour()*60 + now.Minute()
+	switch {
+	case l.start == l.end:
+		return true
+	case l.start < l.end:
+		return m >= l.start && m < l.end
+	default:
+		return m >= l.start || m < l.end
+	}
+}
+
+func (l *scheduledLimit) matches(proto pro.ID) bool {
+	if len(l.protocols) == 0 {
+		return true
+	}
+	for _, p := range l.protocols {
+		if strings.HasPrefix(string(proto), p) {
+			return true
+		}
+	}
+	return false
+}
+
+func newBucket(rate string) (*bucket, error) {
+	if rate == "" {
+		return nil, nil
+	}
+	r, err := humanize.ParseBytes(rate)
+	if err != nil {
+		return nil, err
+	}
+	if r == 0 {
+		return nil, fmt.Errorf("invalid rate %q", rate)
+	}
+	return &bucket{rate: float64(r), avail: float64(r)}, nil
+}
+
+// take removes size bytes from the bucket, and returns how long to wait for
+// it not to be overdrawn anymore.
+func (b *bucket) take(size int64, now time.Time) time.Duration {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if !b.last.IsZero() {
+		b.avail += now.Sub(b.last).Seconds() * b.rate
+		if b.avail > b.rate {
+			b.avail = b.rate
+		}
+	}
+	b.last = now
+
+	b.avail -= float64(size)
+	if b.avail >= 0 {
+		return 0
+	}
+	return time.Duration(-b.avail / b.rate * float64(time.Second))
+}
+
+// parseTimeOfDay parses "15:04" into minutes since midnight.
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	metrics "gx/ipfs/QmVMbSdq6PbznPC83SENVhH7JZn3BqqxkKgrHJFN2RuARf/go-libp2p-metrics"
+)
+
+func TestBandwidthLimiter(t *testing.T) {
+	bl, err := newBandwidthLimiter([]config.BandwidthLimit{
+		{
+			Start:     "22:00",
+			End:       "08:00",
+			Protocols: []string{"/ipfs/bitswap"},
+			MaxOut:    "1kB",
+		},
+	}, metrics.NewBandwidthCounter())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2017, 6, 1, 23, 0, 0, 0, time.Local)
+	var slept time.Duration
+	bl.now = func() time.Time { return now }
+	bl.sleep = func(d time.Duration) { slept += d }
+
+	// the first second of transfer is free
+	bl.LogSentMessageStream(1000, "/ipfs/bitswap/1.1.0", "")
+	if slept != 0 {
+		t.Fatalf("expected no wait, waited %s", slept)
+	}
+
+	bl.LogSentMessageStream(500, "/ipfs/bitswap/1.1.0", "")
+	if slept != 500*time.Millisecond {
+		t.Fatalf("expected to wait 500ms, waited %s", slept)
+	}
+
+	// other protocols and directions aren't limited
+	slept = 0
+	bl.LogSentMessageStream(5000, "/ipfs/kad/1.0.0", "")
+	bl.LogRecvMessageStream(5000, "/ipfs/bitswap/1.1.0", "")
+	if slept != 0 {
+		t.Fatalf("expected no wait, waited %s", slept)
+	}
+
+	// nor is bitswap outside of the window
+	now = time.Date(2017, 6, 2, 12, 0, 0, 0, time.Local)
+	bl.LogSentMessageStream(5000, "/ipfs/bitswap/1.1.0", "")
+	if slept != 0 {
+		t.Fatalf("expected no wait, waited %s", slept)
+	}
+}
+
+func TestBandwidthLimiterConfig(t *testing.T) {
+	for _, l := range []config.BandwidthLimit{
+		{Start: "8am", End: "22:00"},
+		{Start: "08:00", End: "22:00", MaxIn: "fast"},
+		{Start: "08:00", End: "22:00", MaxOut: "0"},
+	} {
+		if _, err := newBandwidthLimiter([]config.BandwidthLimit{l}, nil); err == nil {
+			t.Errorf("expected %+v to be invalid", l)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"text/tabwriter"
 	"time"
 
@@ -27,6 +28,7 @@ Lists running and recently run commands.
 	Subcommands: map[string]*cmds.Command{
 		"clear":    clearInactiveCmd,
 		"set-time": setRequestClearCmd,
+		"cancel":   cancelRequestCmd,
 	},
 	Marshalers: map[cmds.EncodingType]cmds.Marshaler{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
@@ -111,3 +113,35 @@ var setRequestClearCmd = &cmds.Command{
 		req.InvocContext().ReqLog.SetKeepTime(tval)
 	},
 }
+
+var cancelRequestCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Cancel a running command.",
+		ShortDescription: `
+Cancels the command with the given ID, as listed by 'ipfs diag cmds -v'. A
+command that doesn't stop once canceled is abandoned after a few seconds,
+and its client gets an error.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("id", true, false, "ID of the command to cancel."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		id, err := strconv.Atoi(req.Arguments()[0])
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid command id: %s", err), cmds.ErrClient)
+			return
+		}
+
+		rlog := req.InvocContext().ReqLog
+		if rlog == nil {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		if err := rlog.Cancel(id); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
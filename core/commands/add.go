@@ -4,22 +4,25 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"strings"
+	"time"
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	blockservice "github.com/ipfs/go-ipfs/blockservice"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	files "github.com/ipfs/go-ipfs/commands/files"
 	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	"github.com/ipfs/go-ipfs/core/coreunix"
 	offline "github.com/ipfs/go-ipfs/exchange/offline"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	dagtest "github.com/ipfs/go-ipfs/merkledag/test"
 	mfs "github.com/ipfs/go-ipfs/mfs"
+	config "github.com/ipfs/go-ipfs/repo/config"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 
-	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	"gx/ipfs/QmeWjRodbcZFKe5tMN7poEx3izym6osrLSnTLf9UjJZBbs/pb"
 )
 
@@ -42,6 +45,8 @@ const (
 	fstoreCacheOptionName = "fscache"
 	cidVersionOptionName  = "cid-version"
 	hashOptionName        = "hash"
+	expireInOptionName    = "expire-in"
+	reportDedupOptionName = "report-dedup"
 )
 
 const adderOutChanSize = 8
@@ -71,6 +76,15 @@ its filename. For example:
 You can now refer to the added file in a gateway, like so:
 
   /ipfs/QmaG4FuMqEBnQNn3C8XJ5bpW8kLs7zq2ZXgHptJHbKDDVx/example.jpg
+
+The defaults of --cid-version, --hash, --raw-leaves, --chunker and --trickle
+are taken from the Import section of the config.
+
+Content added with --pin=false is removed by the next garbage collection.
+With --expire-in, it is kept for at least the given duration, after which it
+is collected like any other unpinned content:
+
+  > ipfs add --pin=false --expire-in=24h example.jpg
 `,
 	},
 
@@ -89,11 +103,13 @@ You can now refer to the added file in a gateway, like so:
 		cmds.BoolOption(hiddenOptionName, "H", "Include files that are hidden. Only takes effect on recursive add."),
 		cmds.StringOption(chunkerOptionName, "s", "Chunking algorithm to use."),
 		cmds.BoolOption(pinOptionName, "Pin this object when adding.").Default(true),
+		cmds.StringOption(expireInOptionName, "Keep the unpinned object from garbage collection for the given duration, like \"24h\". Requires --pin=false."),
 		cmds.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes. (experimental)"),
 		cmds.BoolOption(noCopyOptionName, "Add the file using filestore. (experimental)"),
 		cmds.BoolOption(fstoreCacheOptionName, "Check the filestore for pre-existing blocks. (experimental)"),
 		cmds.IntOption(cidVersionOptionName, "Cid version. Non-zero value will change default of 'raw-leaves' to true. (experimental)").Default(0),
 		cmds.StringOption(hashOptionName, "Hash function to use. Will set Cid version to 1 if used. (experimental)").Default("sha2-256"),
+		cmds.BoolOption(reportDedupOptionName, "Report how many blocks were written, and how many were already stored.").Default(false),
 	},
 	PreRun: func(req cmds.Request) error {
 		quiet, _, _ := req.Option(quietOptionName).Bool()
@@ -167,11 +183,53 @@ You can now refer to the added file in a gateway, like so:
 		rawblks, rbset, _ := req.Option(rawLeavesOptionName).Bool()
 		nocopy, _, _ := req.Option(noCopyOptionName).Bool()
 		fscache, _, _ := req.Option(fstoreCacheOptionName).Bool()
-		cidVer, _, _ := req.Option(cidVersionOptionName).Int()
+		cidVer, cvset, _ := req.Option(cidVersionOptionName).Int()
 		hashFunStr, hfset, _ := req.Option(hashOptionName).String()
+		_, tset, _ := req.Option(trickleOptionName).Bool()
+
+		// the Import config sets the defaults of the options not given
+		if !cvset {
+			cidVer = cfg.Import.CidVersion
+		}
+		if !hfset && cfg.Import.HashFunction != "" {
+			hashFunStr = cfg.Import.HashFunction
+		}
+		if !rbset && cfg.Import.RawLeaves {
+			rawblks, rbset = true, true
+		}
+		if chunker == "" {
+			chunker = cfg.Import.Chunker
+		}
+		if !tset {
+			switch cfg.Import.Layout {
+			case "", config.DefaultLayout:
+			case "trickle":
+				trickle = true
+			default:
+				res.SetError(fmt.Errorf("unknown layout in Import config: %s", cfg.Import.Layout), cmds.ErrNormal)
+				return
+			}
+		}
 
-		if nocopy && !cfg.Experimental.FilestoreEnabled {
-			res.SetError(errors.New("filestore is not enabled, see https://git.io/vy4XN"),
+		var retain time.Duration
+		if expireIn, found, _ := req.Option(expireInOptionName).String(); found {
+			if dopin {
+				res.SetError(errors.New("--expire-in requires --pin=false"), cmds.ErrClient)
+				return
+			}
+			retain, err = time.ParseDuration(expireIn)
+			if err != nil {
+				res.SetError(fmt.Errorf("error parsing expire-in option: %s", err), cmds.ErrClient)
+				return
+			}
+			if retain <= 0 {
+				res.SetError(errors.New("--expire-in must be positive"), cmds.ErrClient)
+				return
+			}
+		}
+
+		if nocopy && !cfg.FeatureEnabled("filestore") {
+			res.SetError(errors.New("filestore is not enabled, run 'ipfs features enable filestore', see https://git.io/vy4XN"),
 				cmds.ErrClient)
 			return
 		}
@@ -189,25 +247,16 @@ You can now refer to the added file in a gateway, like so:
 			cidVer = 1
 		}
 
-		if cidVer >= 1 && !rbset {
-			rawblks = true
-		}
-
-		prefix, err := dag.PrefixForCidVersion(cidVer)
+		prefix, err := dag.PrefixForHash(cidVer, hashFunStr)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		hashFunCode, ok := mh.Names[strings.ToLower(hashFunStr)]
-		if !ok {
-			res.SetError(fmt.Errorf("unrecognized hash function: %s", strings.ToLower(hashFunStr)), cmds.ErrNormal)
-			return
+		if prefix.Version >= 1 && !rbset {
+			rawblks = true
 		}
 
-		prefix.MhType = hashFunCode
-		prefix.MhLength = -1
-
 		if hash {
 			nilnode, err := core.NewNode(n.Context(), &core.BuildCfg{
 				//TODO: need this to be true or all files
@@ -233,7 +282,8 @@ You can now refer to the added file in a gateway, like so:
 		}
 
 		bserv := blockservice.New(addblockstore, exch)
-		dserv := dag.NewDAGService(bserv)
+		dedup := coreunix.NewDedupCounter(n.Context(), bserv)
+		dserv := dag.NewDAGService(dedup)
 
 		fileAdder, err := coreunix.NewAdder(req.Context(), n.Pinning, n.Blockstore, dserv)
 		if err != nil {
@@ -256,6 +306,13 @@ You can now refer to the added file in a gateway, like so:
 		fileAdder.NoCopy = nocopy
 		fileAdder.Prefix = &prefix
 
+		if retain > 0 && !hash {
+			until := time.Now().Add(retain)
+			fileAdder.Retain = func(c *cid.Cid) error {
+				return corerepo.Retain(n.Repo, c, until)
+			}
+		}
+
 		if hash {
 			md := dagtest.Mock()
 			mr, err := mfs.NewRoot(req.Context(), md, ft.EmptyDirNode(), nil)
@@ -294,9 +351,22 @@ You can now refer to the added file in a gateway, like so:
 				return nil
 			}
 
-			return fileAdder.PinRoot()
+			if err := fileAdder.PinRoot(); err != nil {
+				return err
+			}
+			if !dopin {
+				return nil
+			}
+
+			root, err := fileAdder.RootNode()
+			if err != nil {
+				return err
+			}
+			return recordPinInfo(n, req, []*cid.Cid{root.Cid()}, nil, "", nil)
 		}
 
+		reportDedup, _, _ := req.Option(reportDedupOptionName).Bool()
+
 		go func() {
 			defer close(outChan)
 			if err := addAllAndPin(req.Files()); err != nil {
@@ -304,6 +374,10 @@ You can now refer to the added file in a gateway, like so:
 				return
 			}
 
+			if reportDedup {
+				stats := dedup.Stats()
+				outChan <- &coreunix.AddedObject{Dedup: &stats}
+			}
 		}()
 	},
 	PostRun: func(req cmds.Request, res cmds.Response) {
@@ -341,6 +415,7 @@ You can now refer to the added file in a gateway, like so:
 
 		lastFile := ""
 		lastHash := ""
+		var dedup *coreunix.DedupStats
 		var totalProgress, prevFiles, lastBytes int64
 
 	LOOP:
@@ -351,10 +426,19 @@ You can now refer to the added file in a gateway, like so:
 					if quieter {
 						fmt.Fprintln(res.Stdout(), lastHash)
 					}
+					if dedup != nil {
+						if progress {
+							fmt.Fprintf(res.Stderr(), "\033[2K\r")
+						}
+						fmt.Fprintf(res.Stdout(), "wrote %d blocks (%s), %d blocks (%s) were already stored\n",
+							dedup.NewBlocks, humanize.Bytes(dedup.NewBytes), dedup.DupBlocks, humanize.Bytes(dedup.DupBytes))
+					}
 					break LOOP
 				}
 				output := out.(*coreunix.AddedObject)
-				if len(output.Hash) > 0 {
+				if output.Dedup != nil {
+					dedup = output.Dedup
+				} else if len(output.Hash) > 0 {
 					lastHash = output.Hash
 					if quieter {
 						continue
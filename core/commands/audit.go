@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	"github.com/ipfs/go-ipfs/repo/audit"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+var AuditCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect the audit log.",
+		ShortDescription: `
+The audit log records the requests changing the state of the node: pin add,
+rm and update, files writes, config changes and key operations. It is enabled
+by the Audit.Enabled config option, and kept in the audit.log file of the
+repo. With Audit.HashChain, its entries are chained with their hashes, and
+'ipfs audit verify' finds the ones that were modified or removed.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"tail":   auditTailCmd,
+		"verify": auditVerifyCmd,
+	},
+}
+
+type AuditEntries struct {
+	Entries []audit.Entry
+}
+
+var auditTailCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the last entries of the audit log.",
+		ShortDescription: `
+Shows when the last requests changing the state of the node were made, by
+whom, their command and arguments, and the errors they failed with.
+`,
+	},
+
+	Options: []cmds.Option{
+		cmds.IntOption("n", "Number of entries to show.").Default(10),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, _, err := req.Option("n").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if n < 0 {
+			res.SetError(fmt.Errorf("invalid number of entries %d", n), cmds.ErrClient)
+			return
+		}
+
+		entries, err := audit.Tail(cmds.AuditLogPath(req.InvocContext().ConfigRoot), n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&AuditEntries{Entries: entries})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*AuditEntries)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			for _, e := range out.Entries {
+				fmt.Fprintf(buf, "%s %s %s", e.Time.Format(time.RFC3339), e.Requester, e.Command)
+				if len(e.Arguments) > 0 {
+					fmt.Fprintf(buf, " %s", strings.Join(e.Arguments, " "))
+				}
+				if e.Error != "" {
+					fmt.Fprintf(buf, " (failed: %s)", e.Error)
+				}
+				fmt.Fprintln(buf)
+			}
+			return buf, nil
+		},
+	},
+	Type: AuditEntries{},
+}
+
+type AuditVerifyOutput struct {
+	Entries int
+	Chained int
+}
+
+var auditVerifyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Verify the hash chain of the audit log.",
+		ShortDescription: `
+Checks the hashes of the chained entries of the audit log, and that none of
+them were removed. Entries recorded while Audit.HashChain was off aren't
+chained, and can't be verified. Removing the last entries of the log can't be
+detected.
+`,
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		total, chained, err := audit.Verify(cmds.AuditLogPath(req.InvocContext().ConfigRoot))
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&AuditVerifyOutput{Entries: total, Chained: chained})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*AuditVerifyOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "verified %d chained entries of %d\n", out.Chained, out.Entries)
+			return buf, nil
+		},
+	},
+	Type: AuditVerifyOutput{},
+}
@@ -47,8 +47,8 @@ var unwantCmd = &cmds.Command{
 			return
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
-		if !ok {
+		bs := nd.Bitswap()
+		if bs == nil {
 			res.SetError(u.ErrCast(), cmds.ErrNormal)
 			return
 		}
@@ -90,8 +90,8 @@ Print out all blocks currently on the bitswap wantlist for the local peer.`,
 			return
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
-		if !ok {
+		bs := nd.Bitswap()
+		if bs == nil {
 			res.SetError(u.ErrCast(), cmds.ErrNormal)
 			return
 		}
@@ -119,8 +119,13 @@ Print out all blocks currently on the bitswap wantlist for the local peer.`,
 
 var bitswapStatCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
-		Tagline:          "Show some diagnostic information on the bitswap agent.",
-		ShortDescription: ``,
+		Tagline: "Show some diagnostic information on the bitswap agent.",
+		ShortDescription: `
+Shows the blocks and data bitswap exchanged, its wantlist and partners, and
+the fetch sessions in progress. The first blocks of a session are requested
+from all the peers, the next ones from the peers which sent the first ones
+the fastest; each session lists its peers, fastest first.
+`,
 	},
 	Type: bitswap.Stat{},
 	Run: func(req cmds.Request, res cmds.Response) {
@@ -135,8 +140,8 @@ var bitswapStatCmd = &cmds.Command{
 			return
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
-		if !ok {
+		bs := nd.Bitswap()
+		if bs == nil {
 			res.SetError(u.ErrCast(), cmds.ErrNormal)
 			return
 		}
@@ -172,6 +177,13 @@ var bitswapStatCmd = &cmds.Command{
 			for _, p := range out.Peers {
 				fmt.Fprintf(buf, "\t\t%s\n", p)
 			}
+			fmt.Fprintf(buf, "\tsessions [%d]\n", len(out.Sessions))
+			for _, s := range out.Sessions {
+				fmt.Fprintf(buf, "\t\tsession %d: %d blocks, %s, %d dup blocks\n", s.ID, s.BlocksReceived, humanize.Bytes(s.DataReceived), s.DupBlksReceived)
+				for _, p := range s.Peers {
+					fmt.Fprintf(buf, "\t\t\t%s: %d blocks, %s/s, latency %s\n", p.Peer, p.BlocksReceived, humanize.Bytes(p.Throughput), p.Latency)
+				}
+			}
 			return buf, nil
 		},
 	},
@@ -202,8 +214,8 @@ prints the ledger associated with a given peer.
 			return
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
-		if !ok {
+		bs := nd.Bitswap()
+		if bs == nil {
 			res.SetError(u.ErrCast(), cmds.ErrNormal)
 			return
 		}
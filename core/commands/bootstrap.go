@@ -32,12 +32,32 @@ Running 'ipfs bootstrap' with no arguments will run 'ipfs bootstrap list'.
 	Type:       bootstrapListCmd.Type,
 
 	Subcommands: map[string]*cmds.Command{
-		"list": bootstrapListCmd,
-		"add":  bootstrapAddCmd,
-		"rm":   bootstrapRemoveCmd,
+		"list":   bootstrapListCmd,
+		"add":    bootstrapAddCmd,
+		"rm":     bootstrapRemoveCmd,
+		"health": bootstrapHealthCmd,
 	},
 }
 
+var bootstrapSetDesc = "The set of default peers to add: 'public', 'lan' or 'pnet'."
+var bootstrapAppendDesc = "Add the peers to the bootstrap list, rather than replace it."
+
+const bootstrapSetsHelp = `
+The default peers come in sets, for the environments the node runs in:
+
+  public  the bootstrap peers of the public network (the default)
+  lan     none: in LAN mode, the peers of the local network are found
+          with mDNS, and the public peers can't be dialed
+  pnet    none: the public peers can't join a private network, the peers
+          of the network have to be added by hand
+
+With --append=false, the bootstrap list is replaced with the set, e.g. to
+move a node from the public network to a private one:
+
+  > ipfs bootstrap add default --set=pnet --append=false
+  > ipfs bootstrap add /ip4/10.0.0.1/tcp/4001/ipfs/QmPeer...
+`
+
 var bootstrapAddCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Add peers to the bootstrap list.",
@@ -52,6 +72,8 @@ in the bootstrap list).
 
 	Options: []cmds.Option{
 		cmds.BoolOption("default", "Add default bootstrap nodes. (Deprecated, use 'default' subcommand instead)"),
+		cmds.StringOption("set", bootstrapSetDesc).Default(config.DefaultBootstrapSet),
+		cmds.BoolOption("append", bootstrapAppendDesc).Default(true),
 	},
 	Subcommands: map[string]*cmds.Command{
 		"default": bootstrapAddDefaultCmd,
@@ -67,7 +89,7 @@ in the bootstrap list).
 		var inputPeers []config.BootstrapPeer
 		if deflt {
 			// parse separately for meaningful, correct error.
-			defltPeers, err := config.DefaultBootstrapPeers()
+			defltPeers, err := bootstrapDefaultPeers(req)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
@@ -84,24 +106,13 @@ in the bootstrap list).
 			inputPeers = parsedPeers
 		}
 
-		if len(inputPeers) == 0 {
+		appendPeers, _, _ := req.Option("append").Bool()
+		if len(inputPeers) == 0 && appendPeers {
 			res.SetError(errors.New("no bootstrap peers to add"), cmds.ErrClient)
 			return
 		}
 
-		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
-		if err != nil {
-			res.SetError(err, cmds.ErrNormal)
-			return
-		}
-		defer r.Close()
-		cfg, err := r.Config()
-		if err != nil {
-			res.SetError(err, cmds.ErrNormal)
-			return
-		}
-
-		added, err := bootstrapAdd(r, cfg, inputPeers)
+		added, err := bootstrapSave(req, inputPeers)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -131,29 +142,21 @@ var bootstrapAddDefaultCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Add default peers to the bootstrap list.",
 		ShortDescription: `Outputs a list of peers that were added (that weren't already
-in the bootstrap list).`,
+in the bootstrap list).
+` + bootstrapSetsHelp,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("set", bootstrapSetDesc).Default(config.DefaultBootstrapSet),
+		cmds.BoolOption("append", bootstrapAppendDesc).Default(true),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		defltPeers, err := config.DefaultBootstrapPeers()
+		defltPeers, err := bootstrapDefaultPeers(req)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
-		if err != nil {
-			res.SetError(err, cmds.ErrNormal)
-			return
-		}
-
-		defer r.Close()
-		cfg, err := r.Config()
-		if err != nil {
-			res.SetError(err, cmds.ErrNormal)
-			return
-		}
-
-		added, err := bootstrapAdd(r, cfg, defltPeers)
+		added, err := bootstrapSave(req, defltPeers)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -346,6 +349,39 @@ func bootstrapWritePeers(w io.Writer, prefix string, peers []string) error {
 	return nil
 }
 
+// bootstrapDefaultPeers returns the default peers of the set given with --set.
+func bootstrapDefaultPeers(req cmds.Request) ([]config.BootstrapPeer, error) {
+	set, _, err := req.Option("set").String()
+	if err != nil {
+		return nil, err
+	}
+	return config.DefaultBootstrapSetPeers(set)
+}
+
+// bootstrapSave adds peers to the bootstrap list, or replaces it with them
+// with --append=false, and returns the peers added.
+func bootstrapSave(req cmds.Request, peers []config.BootstrapPeer) ([]config.BootstrapPeer, error) {
+	appendPeers, _, err := req.Option("append").Bool()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if !appendPeers {
+		cfg.Bootstrap = nil
+	}
+	return bootstrapAdd(r, cfg, peers)
+}
+
 func bootstrapAdd(r repo.Repo, cfg *config.Config, peers []config.BootstrapPeer) ([]config.BootstrapPeer, error) {
 	addedMap := map[string]struct{}{}
 	addedList := make([]config.BootstrapPeer, 0, len(peers))
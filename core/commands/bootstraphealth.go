@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	swarm "gx/ipfs/Qmeo7oJxR65PLPx68KPFi8rjzcEmmWN2dL66fPuq9nVMv8/go-libp2p-swarm"
+)
+
+// BootstrapPeerHealth is the state of a bootstrap peer: the round trip time
+// of a ping, or why it can't be reached.
+type BootstrapPeerHealth struct {
+	Peer    string
+	Latency time.Duration `json:",omitempty"`
+	Error   string        `json:",omitempty"`
+}
+
+type BootstrapHealthOutput struct {
+	Peers []BootstrapPeerHealth
+}
+
+var bootstrapHealthCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Check that the bootstrap peers can be reached.",
+		ShortDescription: `
+'ipfs bootstrap health' dials each peer of the bootstrap list, and pings it.
+It reports the round trip time of the ping of the peers which answer, and
+why the other ones can't be reached. Dead bootstrap peers slow the startup of
+the daemon down: they are best removed with 'ipfs bootstrap rm'.
+
+The peers are checked at the same time, each for --dial-timeout at most.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("dial-timeout", "How long to wait for each peer.").Default("10s"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		// Must be online!
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		tstr, _, _ := req.Option("dial-timeout").String()
+		timeout, err := time.ParseDuration(tstr)
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid dial timeout: %s", err), cmds.ErrClient)
+			return
+		}
+
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		peers, err := cfg.BootstrapPeers()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &BootstrapHealthOutput{Peers: make([]BootstrapPeerHealth, len(peers))}
+		var wg sync.WaitGroup
+		for i, bp := range peers {
+			wg.Add(1)
+			go func(i int, bp config.BootstrapPeer) {
+				defer wg.Done()
+				out.Peers[i] = checkBootstrapPeer(req.Context(), n, bp, timeout)
+			}(i, bp)
+		}
+		wg.Wait()
+
+		res.SetOutput(out)
+	},
+	Type: BootstrapHealthOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, ok := res.Output().(*BootstrapHealthOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			var reachable int
+			for _, h := range v.Peers {
+				if h.Error != "" {
+					fmt.Fprintf(buf, "failed %s: %s\n", h.Peer, h.Error)
+					continue
+				}
+				reachable++
+				fmt.Fprintf(buf, "ok %s %s\n", h.Peer, h.Latency)
+			}
+			fmt.Fprintf(buf, "%d of %d bootstrap peers reachable\n", reachable, len(v.Peers))
+			return buf, nil
+		},
+	},
+}
+
+func checkBootstrapPeer(ctx context.Context, n *core.IpfsNode, bp config.BootstrapPeer, timeout time.Duration) BootstrapPeerHealth {
+	h := BootstrapPeerHealth{Peer: bp.String()}
+	if bp.ID() == n.Identity {
+		h.Error = "the bootstrap peer is this node"
+		return h
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// the peer may have failed before: check it now
+	if snet, ok := n.PeerHost.Network().(*swarm.Network); ok {
+		snet.Swarm().Backoff().Clear(bp.ID())
+	}
+	pi := pstore.PeerInfo{ID: bp.ID(), Addrs: []ma.Multiaddr{bp.Transport()}}
+	if err := n.PeerHost.Connect(ctx, pi); err != nil {
+		h.Error = err.Error()
+		return h
+	}
+
+	// the ping tells the latency, whether the peer was connected already
+	// or not
+	pings, err := n.Ping.Ping(ctx, pi.ID)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	select {
+	case t, ok := <-pings:
+		if !ok {
+			h.Error = "no answer to ping"
+			return h
+		}
+		h.Latency = t
+	case <-ctx.Done():
+		h.Error = "no answer to ping: " + ctx.Err().Error()
+	}
+	return h
+}
@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
@@ -16,11 +18,25 @@ var CatCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline:          "Show IPFS object data.",
 		ShortDescription: "Displays the data contained by an IPFS or IPNS object(s) at the given path.",
+		LongDescription: `
+Displays the data contained by an IPFS or IPNS object(s) at the given path.
+
+With --verify-only, the objects are fetched and the hash of each of their
+blocks is checked, but their data isn't output. Their size and the number
+of blocks read are printed instead, and the command fails if an object
+can't be read entirely or a block doesn't match its hash:
+
+  > ipfs cat --verify-only QmVHvp8T6ftzoZq7FUFRfwzmSSdQZE6nsPYkN2vJR8DgEe
+  QmVHvp8T6ftzoZq7FUFRfwzmSSdQZE6nsPYkN2vJR8DgEe: verified 1048576 bytes in 5 blocks
+`,
 	},
 
 	Arguments: []cmds.Argument{
 		cmds.StringArg("ipfs-path", true, true, "The path to the IPFS object(s) to be outputted.").EnableStdin(),
 	},
+	Options: []cmds.Option{
+		cmds.BoolOption("verify-only", "Check the hashes of the objects without outputting their data.").Default(false),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		node, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -35,6 +51,21 @@ var CatCmd = &cmds.Command{
 			}
 		}
 
+		verify, _, _ := req.Option("verify-only").Bool()
+		if verify {
+			out := &CatVerifyOutput{}
+			for _, p := range req.Arguments() {
+				size, blocks, err := coreunix.VerifyCat(req.Context(), node, p)
+				if err != nil {
+					res.SetError(fmt.Errorf("%s: %s", p, err), cmds.ErrNormal)
+					return
+				}
+				out.Objects = append(out.Objects, CatVerified{Path: p, Size: size, Blocks: blocks})
+			}
+			res.SetOutput(out)
+			return
+		}
+
 		readers, length, err := cat(req.Context(), node, req.Arguments())
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -53,6 +84,21 @@ var CatCmd = &cmds.Command{
 		reader := io.MultiReader(readers...)
 		res.SetOutput(reader)
 	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*CatVerifyOutput)
+			if !ok {
+				return res.Output().(io.Reader), nil
+			}
+
+			buf := new(bytes.Buffer)
+			for _, o := range out.Objects {
+				fmt.Fprintf(buf, "%s: verified %d bytes in %d blocks\n", o.Path, o.Size, o.Blocks)
+			}
+			return buf, nil
+		},
+	},
+	Type: CatVerifyOutput{},
 	PostRun: func(req cmds.Request, res cmds.Response) {
 		if res.Length() < progressBarMinSize {
 			return
@@ -65,6 +111,18 @@ var CatCmd = &cmds.Command{
 	},
 }
 
+// CatVerifyOutput is the output of 'cat --verify-only'.
+type CatVerifyOutput struct {
+	Objects []CatVerified
+}
+
+// CatVerified is an object read entirely, with its blocks checked.
+type CatVerified struct {
+	Path   string
+	Size   uint64
+	Blocks int
+}
+
 func cat(ctx context.Context, node *core.IpfsNode, paths []string) ([]io.Reader, uint64, error) {
 	readers := make([]io.Reader, 0, len(paths))
 	length := uint64(0)
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
@@ -56,6 +57,9 @@ Set the value of the 'Datastore.Path' key:
 		cmds.BoolOption("bool", "Set a boolean value.").Default(false),
 		cmds.BoolOption("json", "Parse stringified JSON.").Default(false),
 	},
+	Mutates: func(req cmds.Request) bool {
+		return len(req.Arguments()) > 1
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		args := req.Arguments()
 		key := args[0]
@@ -131,6 +135,7 @@ Set the value of the 'Datastore.Path' key:
 		"show":    configShowCmd,
 		"edit":    configEditCmd,
 		"replace": configReplaceCmd,
+		"profile": configProfileCmd,
 	},
 }
 
@@ -229,6 +234,7 @@ variable set to your preferred text editor.
 `,
 	},
 
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		filename, err := config.Filename(req.InvocContext().ConfigRoot)
 		if err != nil {
@@ -255,6 +261,7 @@ can't be undone.
 	Arguments: []cmds.Argument{
 		cmds.FileArg("file", true, false, "The file to use as the new config."),
 	},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
 		if err != nil {
@@ -278,6 +285,165 @@ can't be undone.
 	},
 }
 
+var configProfileCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Apply profiles to the config.",
+		ShortDescription: `
+Profiles are named sets of config changes for common setups, such as running
+in a datacenter ('server') or in tests ('test').
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"apply": configProfileApplyCmd,
+		"ls":    configProfileLsCmd,
+	},
+}
+
+// ConfigProfile describes a config profile.
+type ConfigProfile struct {
+	Name        string
+	Description string
+}
+
+var configProfileLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the available config profiles.",
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		names := make([]string, 0, len(config.Profiles))
+		for name := range config.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		out := make([]ConfigProfile, len(names))
+		for i, name := range names {
+			out[i] = ConfigProfile{Name: name, Description: config.Profiles[name].Description}
+		}
+		res.SetOutput(&out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			profiles, ok := res.Output().(*[]ConfigProfile)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			for _, p := range *profiles {
+				fmt.Fprintf(buf, "%s:\n  %s\n", p.Name, p.Description)
+			}
+			return buf, nil
+		},
+	},
+	Type: []ConfigProfile{},
+}
+
+// ConfigProfileResult is the config before and after applying a profile,
+// without the private key.
+type ConfigProfileResult struct {
+	OldCfg map[string]interface{}
+	NewCfg map[string]interface{}
+}
+
+var configProfileApplyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Apply a profile to the config.",
+		ShortDescription: `
+'ipfs config profile apply' changes the config as described by the profile.
+The available profiles are listed by 'ipfs config profile ls'.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("profile", true, false, "The profile to apply to the config."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("dry-run", "Print the changes without saving them.").Default(false),
+	},
+	Mutates: cmds.AlwaysMutates,
+	Run: func(req cmds.Request, res cmds.Response) {
+		profile, ok := config.Profiles[req.Arguments()[0]]
+		if !ok {
+			res.SetError(fmt.Errorf("%s is not a profile", req.Arguments()[0]), cmds.ErrClient)
+			return
+		}
+
+		dryRun, _, _ := req.Option("dry-run").Bool()
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer r.Close()
+
+		out, err := transformConfig(r, profile.Transform, dryRun)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			if dryRun, _, _ := res.Request().Option("dry-run").Bool(); !dryRun {
+				return nil, nil
+			}
+
+			out, ok := res.Output().(*ConfigProfileResult)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf, err := config.HumanOutput(out.NewCfg)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, byte('\n'))
+			return bytes.NewReader(buf), nil
+		},
+	},
+	Type: ConfigProfileResult{},
+}
+
+func transformConfig(r repo.Repo, transform config.Transformer, dryRun bool) (*ConfigProfileResult, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	// the repo config is shared, change a copy
+	oldMap, err := config.ToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	newCfg, err := config.FromMap(oldMap)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := transform(newCfg); err != nil {
+		return nil, err
+	}
+	newMap, err := config.ToMap(newCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		if err := r.SetConfig(newCfg); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range []map[string]interface{}{oldMap, newMap} {
+		if err := scrubValue(m, []string{config.IdentityTag, config.PrivKeyTag}); err != nil {
+			return nil, err
+		}
+	}
+	return &ConfigProfileResult{OldCfg: oldMap, NewCfg: newMap}, nil
+}
+
 func getConfig(r repo.Repo, key string) (*ConfigField, error) {
 	value, err := r.GetConfigKey(key)
 	if err != nil {
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	corecrypt "github.com/ipfs/go-ipfs/core/corecrypt"
+	path "github.com/ipfs/go-ipfs/path"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+type CryptAddOutput struct {
+	Manifest string
+}
+
+var CryptCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Encrypt content before adding it, and decrypt it back.",
+		ShortDescription: `
+'ipfs crypt' encrypts content as the node adds it, so that it can be stored
+on and fetched from public nodes without them reading it. The node running
+the command sees the content and the secret: use a node you trust.
+
+The content is encrypted with AES-256-GCM, then added as a regular file. A
+manifest linking to it tells how to decrypt it, without the key: its CID is
+the one to share and to give to 'ipfs crypt cat'. Pinning the manifest
+recursively pins the encrypted content.
+
+The key of each file is derived from a secret and a random salt. The secret
+is either a key of the keystore, given with --key, or a 32 bytes key in hex,
+read from the file given with --secret-file. The key of the node, "self",
+can't be used: create one with 'ipfs key gen'. The secret file is read by
+the node, keep it out of the command line and of the shell history. Anyone
+holding the secret can decrypt the content. The size of the content is not
+hidden.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": cryptAddCmd,
+		"cat": cryptCatCmd,
+	},
+}
+
+var cryptSecretOptions = []cmds.Option{
+	cmds.StringOption("key", "k", "Name of the keystore key to derive the key from, as listed by 'ipfs key list'."),
+	cmds.StringOption("secret-file", "File holding a key of 32 bytes, in hex, to derive the key from instead of a keystore key."),
+}
+
+var cryptAddCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Encrypt content and add it.",
+		ShortDescription: `
+'ipfs crypt add' encrypts the given file, adds it, and prints the CID of the
+manifest linking to it. The manifest is pinned recursively, unless
+--pin=false is given.
+
+Example:
+
+  > ipfs key gen --type=ed25519 backups
+  > ipfs crypt add --key=backups secrets.tar
+  added QmYdDdmq4dLCkcqAWpN9Hh1U6UnqTa5XUu8grjB5Vyz2KG
+  > ipfs crypt cat --key=backups QmYdDdmq4dLCkcqAWpN9Hh1U6UnqTa5XUu8grjB5Vyz2KG >secrets.tar
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("data", true, false, "The data to encrypt and add.").EnableStdin(),
+	},
+	Options: append([]cmds.Option{
+		cmds.BoolOption("pin", "Pin the manifest recursively.").Default(true),
+	}, cryptSecretOptions...),
+	PreRun: cryptPreRun,
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		secret, err := cryptSecret(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		pin, _, _ := req.Option("pin").Bool()
+		c, err := corecrypt.Add(req.Context(), n, file, secret, pin)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(&CryptAddOutput{Manifest: c.String()})
+	},
+	Type: CryptAddOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*CryptAddOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			return strings.NewReader(fmt.Sprintf("added %s\n", out.Manifest)), nil
+		},
+	},
+}
+
+var cryptCatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Decrypt content added with 'ipfs crypt add'.",
+		ShortDescription: `
+'ipfs crypt cat' fetches the content the given manifest links to, and outputs
+it decrypted. It must be given the secret the content was encrypted with. The
+command fails if the content was altered or truncated.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("manifest", true, false, "The path of the manifest of the content."),
+	},
+	Options: cryptSecretOptions,
+	PreRun:  cryptPreRun,
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			if err := n.SetupOfflineRouting(); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		secret, err := cryptSecret(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		r, err := corecrypt.Cat(req.Context(), n, p, secret)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(r)
+	},
+}
+
+// cryptPreRun makes the path of --secret-file absolute, for the daemon to
+// read it.
+func cryptPreRun(req cmds.Request) error {
+	fname, found, _ := req.Option("secret-file").String()
+	if !found {
+		return nil
+	}
+	abs, err := filepath.Abs(fname)
+	if err != nil {
+		return err
+	}
+	req.SetOption("secret-file", abs)
+	return nil
+}
+
+// cryptSecret returns the secret of the --secret-file or --key options.
+func cryptSecret(req cmds.Request, n *core.IpfsNode) (*corecrypt.Secret, error) {
+	if fname, found, _ := req.Option("secret-file").String(); found {
+		b, err := ioutil.ReadFile(fname)
+		if err != nil {
+			return nil, err
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --secret-file: %s", err)
+		}
+		return corecrypt.UserSecret(key)
+	}
+
+	name, found, _ := req.Option("key").String()
+	if !found {
+		return nil, errors.New("give the secret with --key or --secret-file")
+	}
+	return corecrypt.KeystoreSecret(n, name)
+}
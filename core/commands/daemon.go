@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+type RestartServiceOutput struct {
+	Service string
+}
+
+// DaemonCmd holds the commands acting on a running daemon. The CLI runs
+// 'ipfs daemon' itself, with these as subcommands.
+var DaemonCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage a running daemon.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"restart-service": daemonRestartServiceCmd,
+	},
+}
+
+var daemonRestartServiceCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Restart a service of the running daemon.",
+		ShortDescription: `
+Tears a service of the daemon down and starts it again against the live node,
+without closing the connections to the peers, e.g. after a transient failure.
+The requests the service was handling fail.
+
+The services are:
+
+  bitswap      the block exchange
+  dht          the DHT; its routing table restarts from the connected peers
+  gateway      the HTTP gateway, listening again on the configured addresses
+  reprovider   the periodic announcement of the blocks
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("service", true, false, "The service to restart."),
+	},
+	Type: RestartServiceOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		name := req.Arguments()[0]
+		if err := n.RestartService(name); err != nil {
+			code := cmds.ErrNormal
+			if strings.HasPrefix(err.Error(), core.ErrUnknownService.Error()) {
+				code = cmds.ErrClient
+				err = fmt.Errorf("%s (services: %s)", err, strings.Join(n.Services(), ", "))
+			}
+			res.SetError(err, code)
+			return
+		}
+		res.SetOutput(&RestartServiceOutput{Service: name})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*RestartServiceOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			return strings.NewReader(fmt.Sprintf("restarted %s\n", out.Service)), nil
+		},
+	},
+}
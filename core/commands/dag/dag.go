@@ -1,15 +1,27 @@
 package dagcmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"sort"
 	"strings"
+	"time"
 
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	car "github.com/ipfs/go-ipfs/car"
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	path "github.com/ipfs/go-ipfs/path"
 
 	ipldcbor "gx/ipfs/QmNrbCt8j9DT5W9Pmjy2SdudT9k8GpaDr4sRuFix3BXhgR/go-ipld-cbor"
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
 )
@@ -25,8 +37,12 @@ to deprecate and replace the existing 'ipfs object' command moving forward.
 		`,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"put": DagPutCmd,
-		"get": DagGetCmd,
+		"put":     DagPutCmd,
+		"get":     DagGetCmd,
+		"resolve": DagResolveCmd,
+		"dot":     DagDotCmd,
+		"import":  DagImportCmd,
+		"ls":      DagLsCmd,
 	},
 }
 
@@ -48,6 +64,7 @@ into an object of the specified format.
 	Options: []cmds.Option{
 		cmds.StringOption("format", "f", "Format that the object will be added as.").Default("cbor"),
 		cmds.StringOption("input-enc", "Format that the input object will be.").Default("json"),
+		cmds.StringOption("hash", "Hash function to use, overrides the Import.HashFunction config.").Default("sha2-256"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -64,42 +81,41 @@ into an object of the specified format.
 
 		ienc, _, _ := req.Option("input-enc").String()
 		format, _, _ := req.Option("format").String()
+		hashFun, hfset, _ := req.Option("hash").String()
 
-		switch ienc {
-		case "json":
-			nd, err := convertJsonToType(fi, format)
+		if !hfset {
+			cfg, err := n.Repo.Config()
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
 			}
-
-			c, err := n.DAG.Add(nd)
-			if err != nil {
-				res.SetError(err, cmds.ErrNormal)
-				return
+			if cfg.Import.HashFunction != "" {
+				hashFun = cfg.Import.HashFunction
 			}
+		}
 
-			res.SetOutput(&OutputObject{Cid: c})
-			return
+		var nd node.Node
+		switch ienc {
+		case "json":
+			nd, err = convertJsonToType(fi, format)
 		case "raw":
-			nd, err := convertRawToType(fi, format)
-			if err != nil {
-				res.SetError(err, cmds.ErrNormal)
-				return
-			}
-
-			c, err := n.DAG.Add(nd)
-			if err != nil {
-				res.SetError(err, cmds.ErrNormal)
-				return
-			}
-
-			res.SetOutput(&OutputObject{Cid: c})
-			return
+			nd, err = convertRawToType(fi, format)
 		default:
 			res.SetError(fmt.Errorf("unrecognized input encoding: %s", ienc), cmds.ErrNormal)
 			return
 		}
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		c, err := addWithHash(n, nd, hashFun)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&OutputObject{Cid: c})
 	},
 	Type: OutputObject{},
 	Marshalers: cmds.MarshalerMap{
@@ -157,6 +173,115 @@ var DagGetCmd = &cmds.Command{
 	},
 }
 
+// ResolveOutput is the output type of 'ipfs dag resolve'
+type ResolveOutput struct {
+	Cid     *cid.Cid
+	RemPath string
+	Blocks  []*cid.Cid
+}
+
+var DagResolveCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Resolve ipld block",
+		ShortDescription: `
+'ipfs dag resolve' fetches a dag node from ipfs, prints its address and remaining path.
+`,
+		LongDescription: `
+'ipfs dag resolve' resolves an ipfs path through ipld blocks and prints the
+cid of the block the path ends in, followed by the path remaining to be
+resolved inside that block.
+
+With '--blocks', the cids of all blocks traversed while resolving the path
+are listed as well, starting with the root. Applications that want to verify
+content themselves can use this list to fetch and check every block on the
+way.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ref", true, false, "The path to resolve").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("blocks", "b", "List all blocks traversed during resolution.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p, err := coreapi.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		rp, err := coreapi.NewCoreAPI(n).Dag().Resolve(req.Context(), p)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&ResolveOutput{
+			Cid:     rp.Cid,
+			RemPath: path.Join(rp.RemPath),
+			Blocks:  rp.Blocks,
+		})
+	},
+	Type: ResolveOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*ResolveOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected a different object in marshaler")
+			}
+
+			showBlocks, _, _ := res.Request().Option("blocks").Bool()
+
+			buf := new(bytes.Buffer)
+			p := out.Cid.String()
+			if out.RemPath != "" {
+				p = p + "/" + out.RemPath
+			}
+			fmt.Fprintln(buf, p)
+			if showBlocks {
+				for _, c := range out.Blocks {
+					fmt.Fprintf(buf, "  %s\n", c)
+				}
+			}
+			return buf, nil
+		},
+	},
+}
+
+// addWithHash stores nd, hashed with the given function rather than the one
+// of its format if they differ.
+func addWithHash(n *core.IpfsNode, nd node.Node, hashFun string) (*cid.Cid, error) {
+	code, ok := mh.Names[strings.ToLower(hashFun)]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized hash function: %s", hashFun)
+	}
+
+	prefix := nd.Cid().Prefix()
+	if code == prefix.MhType {
+		return n.DAG.Add(nd)
+	}
+
+	prefix.Version = 1
+	prefix.MhType = code
+	prefix.MhLength = -1
+	c, err := prefix.Sum(nd.RawData())
+	if err != nil {
+		return nil, err
+	}
+
+	blk, err := blocks.NewBlockWithCid(nd.RawData(), c)
+	if err != nil {
+		return nil, err
+	}
+	return n.Blocks.AddBlock(blk)
+}
+
 func convertJsonToType(r io.Reader, format string) (node.Node, error) {
 	switch format {
 	case "cbor", "dag-cbor":
@@ -181,3 +306,225 @@ func convertRawToType(r io.Reader, format string) (node.Node, error) {
 		return nil, fmt.Errorf("unsupported target format for raw input: %s", format)
 	}
 }
+
+// DagImportRoot is a root of the imported archives.
+type DagImportRoot struct {
+	Cid     string
+	Blocks  uint64 // blocks of the dag stored locally
+	Missing uint64 `json:",omitempty"`
+	Pinned  bool
+}
+
+type DagImportOutput struct {
+	Blocks    int
+	BadBlocks []string `json:",omitempty"`
+	Roots     []DagImportRoot
+	Stats     *DagImportStats `json:",omitempty"`
+}
+
+// DagImportStats measures the speed of an import.
+type DagImportStats struct {
+	Bytes   uint64 // size of the blocks imported
+	Seconds float64
+}
+
+var DagImportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Import the blocks of CAR files.",
+		ShortDescription: `
+'ipfs dag import' stores the blocks of CAR files, and pins the dags of their
+roots recursively. The blocks whose data doesn't match their hash are
+skipped, and listed.
+
+--pin-roots selects the roots to pin, comma separated, among the roots of
+the archives. With --pin-all=false and no --pin-roots, nothing is pinned.
+
+For each root, the blocks of its dag now stored locally are counted, and the
+missing ones: the archives may hold only a part of a dag. Incomplete dags
+aren't pinned, as that would fetch the missing blocks.
+
+The data of the blocks is checked by one worker per CPU, and the blocks are
+written in batches. For large imports, --dup-check=false writes the batches
+without first looking for the blocks already stored (the blockstore still
+doesn't store them twice). --stats writes the size of the blocks imported and
+the speed of the import, and --silent writes neither the blocks skipped nor
+the roots.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("path", true, true, "The CAR files to import.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("pin-all", "Pin the dags of all the roots.").Default(true),
+		cmds.StringOption("pin-roots", "Comma separated roots to pin, instead of all of them."),
+		cmds.BoolOption("dup-check", "Look for the blocks already stored before writing.").Default(true),
+		cmds.BoolOption("stats", "Write the size and speed of the import.").Default(false),
+		cmds.BoolOption("silent", "Write neither the skipped blocks nor the roots.").Default(false),
+	},
+	Type: DagImportOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		pinAll, _, _ := req.Option("pin-all").Bool()
+		var selected []*cid.Cid
+		if s, found, _ := req.Option("pin-roots").String(); found {
+			pinAll = false
+			for _, r := range strings.Split(s, ",") {
+				c, err := cid.Decode(strings.TrimSpace(r))
+				if err != nil {
+					res.SetError(fmt.Errorf("invalid root %q: %s", r, err), cmds.ErrClient)
+					return
+				}
+				selected = append(selected, c)
+			}
+		}
+
+		bs := n.Blocks
+		if dupCheck, _, _ := req.Option("dup-check").Bool(); !dupCheck {
+			bs = bserv.NewWriteThrough(n.Blockstore, n.Exchange)
+		}
+
+		// keep the gc from removing the blocks before they are pinned
+		defer n.Blockstore.PinLock().Unlock()
+
+		start := time.Now()
+		out := &DagImportOutput{Stats: &DagImportStats{}}
+		roots := cid.NewSet()
+		var order []*cid.Cid
+		for {
+			file, err := req.Files().NextFile()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			rs, err := importCar(req.Context(), bs, file, out)
+			file.Close()
+			if err != nil {
+				res.SetError(fmt.Errorf("%s: %s", file.FileName(), err), cmds.ErrNormal)
+				return
+			}
+			for _, c := range rs {
+				if roots.Visit(c) {
+					order = append(order, c)
+				}
+			}
+		}
+
+		toPin := cid.NewSet()
+		for _, c := range selected {
+			if !roots.Has(c) {
+				res.SetError(fmt.Errorf("%s is not a root of the archives", c), cmds.ErrClient)
+				return
+			}
+			toPin.Add(c)
+		}
+
+		ctx := req.Context()
+		for _, c := range order {
+			stored, missing, err := corerepo.LocalBlocks(ctx, n, c)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			r := DagImportRoot{Cid: c.String(), Blocks: stored, Missing: missing}
+
+			if (pinAll || toPin.Has(c)) && missing == 0 {
+				nd, err := n.DAG.Get(ctx, c)
+				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+				if err := n.Pinning.Pin(ctx, nd, true); err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+				r.Pinned = true
+			}
+			out.Roots = append(out.Roots, r)
+		}
+
+		if err := n.Pinning.Flush(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		// the blocks are checked in parallel
+		sort.Strings(out.BadBlocks)
+
+		if stats, _, _ := req.Option("stats").Bool(); stats {
+			out.Stats.Seconds = time.Since(start).Seconds()
+		} else {
+			out.Stats = nil
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*DagImportOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected a different object in marshaler")
+			}
+
+			buf := new(bytes.Buffer)
+			if st := out.Stats; st != nil {
+				rate := float64(st.Bytes)
+				if st.Seconds > 0 {
+					rate /= st.Seconds
+				}
+				fmt.Fprintf(buf, "imported %d blocks, %s in %.2fs (%s/s)\n", out.Blocks,
+					humanize.Bytes(st.Bytes), st.Seconds, humanize.Bytes(uint64(rate)))
+			}
+			if silent, _, _ := res.Request().Option("silent").Bool(); silent {
+				return buf, nil
+			}
+
+			if out.Stats == nil {
+				fmt.Fprintf(buf, "imported %d blocks\n", out.Blocks)
+			}
+			for _, c := range out.BadBlocks {
+				fmt.Fprintf(buf, "skipped %s: data doesn't match its hash\n", c)
+			}
+			for _, r := range out.Roots {
+				state := "complete"
+				if r.Missing > 0 {
+					state = fmt.Sprintf("incomplete, %d blocks missing", r.Missing)
+				}
+				pinned := "not pinned"
+				if r.Pinned {
+					pinned = "pinned"
+				}
+				fmt.Fprintf(buf, "root %s %s (%d blocks), %s\n", r.Cid, state, r.Blocks, pinned)
+			}
+			return buf, nil
+		},
+	},
+}
+
+// importCar stores the blocks of the CAR file in r through bs, counting them
+// in out, and returns its roots. The blocks whose data doesn't match their
+// CID are skipped.
+func importCar(ctx context.Context, bs bserv.BlockService, r io.Reader, out *DagImportOutput) ([]*cid.Cid, error) {
+	cr, err := car.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := cr.Import(ctx, bs, true)
+	if err != nil {
+		return nil, err
+	}
+	out.Blocks += stats.Blocks
+	out.Stats.Bytes += stats.Bytes
+	for _, c := range stats.BadBlocks {
+		out.BadBlocks = append(out.BadBlocks, c.String())
+	}
+	return cr.Header.Roots, nil
+}
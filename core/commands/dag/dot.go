@@ -0,0 +1,214 @@
+package dagcmd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	path "github.com/ipfs/go-ipfs/path"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+var DagDotCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Export a dag as a graph to visualize.",
+		ShortDescription: `
+'ipfs dag dot' walks the dag under <ref> and writes it as a graph, in the DOT
+format of graphviz or in GraphML, with the size of each block and the names
+of the links.
+`,
+		LongDescription: `
+'ipfs dag dot' walks the dag under <ref> and writes it as a graph, in the DOT
+format of graphviz or in GraphML, with the size of each block and the names
+of the links. Blocks linked several times are drawn once.
+
+The walk goes breadth first, and stops at --max-depth links from the root or
+once --max-nodes blocks are in the graph. The blocks whose links weren't all
+followed are marked as truncated, and drawn dashed in DOT.
+
+Example:
+
+  > ipfs dag dot --max-depth=2 QmRoot | dot -Tsvg > dag.svg
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ref", true, false, "The root of the dag to export.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("format", "f", "Format of the graph, \"dot\" or \"graphml\".").Default("dot"),
+		cmds.IntOption("max-depth", "d", "Maximum depth of the walk, -1 for no limit.").Default(-1),
+		cmds.IntOption("max-nodes", "n", "Maximum number of blocks in the graph.").Default(1000),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		format, _, _ := req.Option("format").String()
+		maxDepth, _, _ := req.Option("max-depth").Int()
+		maxNodes, _, _ := req.Option("max-nodes").Int()
+
+		var write func(io.Writer, *dagGraph) error
+		switch format {
+		case "dot":
+			write = writeDot
+		case "graphml":
+			write = writeGraphML
+		default:
+			res.SetError(fmt.Errorf("unknown graph format %q, must be \"dot\" or \"graphml\"", format), cmds.ErrClient)
+			return
+		}
+		if maxNodes < 1 {
+			res.SetError(fmt.Errorf("--max-nodes must be at least 1"), cmds.ErrClient)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		root, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, p)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		g, err := walkGraph(req, n, root.Cid(), maxDepth, maxNodes)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		buf := new(bytes.Buffer)
+		if err := write(buf, g); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(buf)
+	},
+}
+
+type dagGraph struct {
+	nodes []*graphNode
+	edges []graphEdge
+}
+
+type graphNode struct {
+	cid       *cid.Cid
+	size      int
+	truncated bool
+}
+
+type graphEdge struct {
+	from, to *cid.Cid
+	name     string
+}
+
+// walkGraph walks the dag under root breadth first, up to maxDepth links
+// away (no limit if negative) and maxNodes blocks.
+func walkGraph(req cmds.Request, n *core.IpfsNode, root *cid.Cid, maxDepth, maxNodes int) (*dagGraph, error) {
+	g := new(dagGraph)
+	seen := map[string]bool{root.KeyString(): true}
+
+	level := []*cid.Cid{root}
+	for depth := 0; len(level) > 0; depth++ {
+		var next []*cid.Cid
+		for _, c := range level {
+			nd, err := n.DAG.Get(req.Context(), c)
+			if err != nil {
+				return nil, err
+			}
+
+			gn := &graphNode{cid: c, size: len(nd.RawData())}
+			g.nodes = append(g.nodes, gn)
+
+			for _, l := range nd.Links() {
+				k := l.Cid.KeyString()
+				if !seen[k] {
+					if (maxDepth >= 0 && depth >= maxDepth) || len(seen) >= maxNodes {
+						gn.truncated = true
+						continue
+					}
+					seen[k] = true
+					next = append(next, l.Cid)
+				}
+				g.edges = append(g.edges, graphEdge{from: c, to: l.Cid, name: l.Name})
+			}
+		}
+		level = next
+	}
+	return g, nil
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+func writeDot(w io.Writer, g *dagGraph) error {
+	fmt.Fprintf(w, "digraph %s {\n", dotQuote(g.nodes[0].cid.String()))
+	fmt.Fprintln(w, "\tnode [shape=box, fontname=monospace];")
+	for _, nd := range g.nodes {
+		c := nd.cid.String()
+		label := fmt.Sprintf("%s\n%s", shortCid(c), humanize.Bytes(uint64(nd.size)))
+		fmt.Fprintf(w, "\t%s [label=%s", dotQuote(c), dotQuote(label))
+		if nd.truncated {
+			fmt.Fprint(w, ", style=dashed")
+		}
+		fmt.Fprintln(w, "];")
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(w, "\t%s -> %s", dotQuote(e.from.String()), dotQuote(e.to.String()))
+		if e.name != "" {
+			fmt.Fprintf(w, " [label=%s]", dotQuote(e.name))
+		}
+		fmt.Fprintln(w, ";")
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+func writeGraphML(w io.Writer, g *dagGraph) error {
+	esc := func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	fmt.Fprint(w, xml.Header)
+	fmt.Fprintln(w, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(w, `  <key id="size" for="node" attr.name="size" attr.type="long"/>`)
+	fmt.Fprintln(w, `  <key id="truncated" for="node" attr.name="truncated" attr.type="boolean"/>`)
+	fmt.Fprintln(w, `  <key id="name" for="edge" attr.name="name" attr.type="string"/>`)
+	fmt.Fprintf(w, "  <graph id=\"%s\" edgedefault=\"directed\">\n", esc(g.nodes[0].cid.String()))
+	for _, nd := range g.nodes {
+		fmt.Fprintf(w, "    <node id=\"%s\"><data key=\"size\">%d</data><data key=\"truncated\">%t</data></node>\n",
+			esc(nd.cid.String()), nd.size, nd.truncated)
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(w, "    <edge source=\"%s\" target=\"%s\"><data key=\"name\">%s</data></edge>\n",
+			esc(e.from.String()), esc(e.to.String()), esc(e.name))
+	}
+	fmt.Fprintln(w, "  </graph>")
+	_, err := fmt.Fprintln(w, "</graphml>")
+	return err
+}
+
+// shortCid abbreviates a cid for labels.
+func shortCid(c string) string {
+	if len(c) <= 12 {
+		return c
+	}
+	return c[:6] + "…" + c[len(c)-4:]
+}
@@ -0,0 +1,131 @@
+package dagcmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	path "github.com/ipfs/go-ipfs/path"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+// LsLink is a link listed by 'ipfs dag ls'.
+type LsLink struct {
+	Name string `json:",omitempty"`
+	Cid  *cid.Cid
+	Size uint64
+}
+
+// LsOutput is the output type of 'ipfs dag ls'.
+type LsOutput struct {
+	Links []LsLink
+	// number of links of the node, to page through them
+	Total int
+}
+
+var DagLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the links of a dag node.",
+		ShortDescription: `
+'ipfs dag ls' lists the links of the dag node at <ref>, in any format, with
+their names, if any, and the cumulative size of the dags they point to.
+`,
+		LongDescription: `
+'ipfs dag ls' lists the links of the dag node at <ref>, in any format, with
+their names, if any, and the cumulative size of the dags they point to.
+
+Nodes with very many links, such as directory shards or large arrays of
+links, can be listed a page at a time with --offset and --count, so that
+clients can show part of them. The total number of links is part of the
+output. With --sorted, the links are sorted by name first. The links of
+directory shards are the ones of the shard node itself: 'ipfs ls' lists the
+entries of sharded directories.
+
+Example:
+
+  > ipfs dag ls --offset=1000 --count=100 QmBigNode
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ref", true, false, "The node to list the links of.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("sorted", "s", "Sort the links by name.").Default(false),
+		cmds.IntOption("offset", "o", "Number of links to skip.").Default(0),
+		cmds.IntOption("count", "n", "Maximum number of links to list, -1 for no limit.").Default(-1),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		sorted, _, _ := req.Option("sorted").Bool()
+		offset, _, _ := req.Option("offset").Int()
+		count, _, _ := req.Option("count").Int()
+		if offset < 0 {
+			res.SetError(errors.New("--offset can't be negative"), cmds.ErrClient)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		nd, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, p)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		links := nd.Links()
+		out := &LsOutput{Total: len(links)}
+		if sorted {
+			// don't reorder the links of the node itself
+			links = append([]*node.Link(nil), links...)
+			sort.Stable(dag.LinkSlice(links))
+		}
+
+		if offset > len(links) {
+			offset = len(links)
+		}
+		links = links[offset:]
+		if count >= 0 && count < len(links) {
+			links = links[:count]
+		}
+
+		out.Links = make([]LsLink, len(links))
+		for i, l := range links {
+			out.Links[i] = LsLink{Name: l.Name, Cid: l.Cid, Size: l.Size}
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*LsOutput)
+			if !ok {
+				return nil, fmt.Errorf("unexpected output type: %T", res.Output())
+			}
+
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			for _, l := range out.Links {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", l.Cid, l.Size, l.Name)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: LsOutput{},
+}
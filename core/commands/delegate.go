@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
+
+	crypto "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	routing "gx/ipfs/QmafuecpeZp3k3sHJ5mUARHd4795revuadECQMkmHB8LfW/go-libp2p-routing"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+type DelegationOutput struct {
+	Name       string
+	Delegate   string
+	Delegation string
+}
+
+var DelegateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Allow another key to publish a sub-name of an IPNS name.",
+		ShortDescription: `
+Signs a delegation allowing <delegate> to publish /ipns/<name>/<sub-name>,
+and prints it for the owner of <delegate> to publish with.
+`,
+		LongDescription: `
+Signs a delegation allowing <delegate> to publish /ipns/<name>/<sub-name>,
+where <name> is the name of --key, and prints it for the owner of <delegate>
+to publish with 'ipfs name publish --delegation'. This lets a team split the
+publishing of a site: the site key delegates sub-names, and each delegate
+publishes its own without ever holding the site key.
+
+<delegate> is the name of a key, as listed by 'ipfs key list', or a peer ID
+whose public key can be found on the network. Sub-names are a single path
+component.
+
+Sub-names are only looked up under names published with
+'ipfs name publish --sub-names'. There, once published, a sub-name takes
+precedence over what the name itself points to at that path, for as long as
+both the delegation and the sub-name's record are valid. The delegation is
+checked whenever the sub-name is resolved.
+
+Example:
+
+  > ipfs name publish --sub-names /ipfs/QmSite...
+  Published to QmSiteKey...: /ipfs/QmSite...
+  > ipfs name delegate --lifetime=8760h blog QmBlogTeamKey... > blog.delegation
+  (on the blog team's node)
+  > ipfs name publish --key=blog --delegation=$(cat blog.delegation) /ipfs/QmPosts...
+  Published to QmSiteKey.../blog: /ipfs/QmPosts...
+  > ipfs name resolve /ipns/QmSiteKey.../blog/index.html
+  /ipfs/QmPosts.../index.html
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("sub-name", true, false, "The sub-name to delegate."),
+		cmds.StringArg("delegate", true, false, "The key allowed to publish the sub-name."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("key", "k", "Name of the key owning the name, as listed by 'ipfs key list'. Default: <<default>>.").Default("self"),
+		cmds.StringOption("lifetime", "t", "Time duration that the delegation will be valid for. <<default>>").Default("8760h"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			if err := n.SetupOfflineRouting(); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		sub := req.Arguments()[0]
+		if err := namesys.ValidSubName(sub); err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		lifetime, _, _ := req.Option("lifetime").String()
+		d, err := time.ParseDuration(lifetime)
+		if err != nil {
+			res.SetError(fmt.Errorf("error parsing lifetime option: %s", err), cmds.ErrClient)
+			return
+		}
+
+		kname, _, _ := req.Option("key").String()
+		k, err := n.GetKey(kname)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		dk, err := delegatePublicKey(req.Context(), n, req.Arguments()[1])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		dl, err := namesys.CreateDelegation(k, sub, dk, time.Now().Add(d))
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		data, err := proto.Marshal(dl)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		pid, err := peer.IDFromPrivateKey(k)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		did, err := peer.IDFromPublicKey(dk)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&DelegationOutput{
+			Name:       pid.Pretty() + "/" + sub,
+			Delegate:   did.Pretty(),
+			Delegation: base64.RawURLEncoding.EncodeToString(data),
+		})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, ok := res.Output().(*DelegationOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected output type to be DelegationOutput")
+			}
+			return strings.NewReader(v.Delegation + "\n"), nil
+		},
+	},
+	Type: DelegationOutput{},
+}
+
+// delegatePublicKey returns the public key of the key with the given name in
+// the keystore, or of the given peer ID.
+func delegatePublicKey(ctx context.Context, n *core.IpfsNode, name string) (crypto.PubKey, error) {
+	k, err := n.GetKey(name)
+	switch err {
+	case nil:
+		return k.GetPublic(), nil
+	case keystore.ErrNoSuchKey:
+	default:
+		return nil, err
+	}
+
+	id, err := peer.IDB58Decode(name)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a key name nor a peer ID", name)
+	}
+	if pk := n.Peerstore.PubKey(id); pk != nil {
+		return pk, nil
+	}
+	pk, err := routing.GetPublicKey(n.Routing, ctx, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("could not find the public key of %s: %s", name, err)
+	}
+	return pk, nil
+}
+
+// decodeDelegation decodes a delegation printed by 'ipfs name delegate'.
+func decodeDelegation(s string) (*pb.IpnsDelegation, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid delegation: %s", err)
+	}
+	d := new(pb.IpnsDelegation)
+	if err := proto.Unmarshal(data, d); err != nil {
+		return nil, fmt.Errorf("invalid delegation: %s", err)
+	}
+	return d, nil
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 
@@ -59,8 +60,8 @@ var queryDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -153,8 +154,8 @@ var findProvidersDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -410,8 +411,8 @@ var findPeerDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -501,6 +502,14 @@ this context 'best' means the record that is most desirable. There is no one
 metric for 'best': it depends entirely on the key type. For IPNS, 'best' is
 the record that is both valid and has the highest sequence number (freshest).
 Different key types can specify other 'best' rules.
+
+The query stops once --quorum records were received, or when --attempt-timeout
+runs out, with the best of the records found so far. On small networks,
+lowering the quorum avoids waiting for records that don't exist. The global
+--timeout bounds the whole command, retries included.
+
+Only the keytypes this ipfs binary has a validator for can be queried, see
+'ipfs dht put --help'.
 `,
 	},
 
@@ -509,6 +518,9 @@ Different key types can specify other 'best' rules.
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("verbose", "v", "Print extra information.").Default(false),
+		cmds.IntOption("quorum", "q", "Number of records to collect before picking the best one.").Default(core.DefaultRecordQuorum),
+		cmds.StringOption("attempt-timeout", "Maximum duration of each attempt, e.g. \"30s\"."),
+		cmds.IntOption("retries", "Number of times to retry a failed attempt.").Default(0),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -517,17 +529,17 @@ Different key types can specify other 'best' rules.
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
 
-		outChan := make(chan interface{})
-		res.SetOutput((<-chan interface{})(outChan))
-
-		events := make(chan *notif.QueryEvent)
-		ctx := notif.RegisterForQueryEvents(req.Context(), events)
+		ropts, err := recordOptions(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
 
 		dhtkey, err := escapeDhtKey(req.Arguments()[0])
 		if err != nil {
@@ -535,6 +547,17 @@ Different key types can specify other 'best' rules.
 			return
 		}
 
+		if _, _, err := core.LookupRecordNamespace(dhtkey); err == core.ErrUnknownNamespace {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		outChan := make(chan interface{})
+		res.SetOutput((<-chan interface{})(outChan))
+
+		events := make(chan *notif.QueryEvent)
+		ctx := notif.RegisterForQueryEvents(req.Context(), events)
+
 		go func() {
 			defer close(outChan)
 			for e := range events {
@@ -544,7 +567,7 @@ Different key types can specify other 'best' rules.
 
 		go func() {
 			defer close(events)
-			val, err := dht.GetValue(ctx, dhtkey)
+			val, err := core.GetRecord(ctx, dht, dhtkey, ropts)
 			if err != nil {
 				notif.PublishQueryEvent(ctx, &notif.QueryEvent{
 					Type:  notif.QueryError,
@@ -610,14 +633,21 @@ Keys have two parts: a keytype (foo) and the key name (bar). IPNS uses the
 /ipns keytype, and expects the key name to be a Peer ID. IPNS entries are
 specifically formatted (protocol buffer).
 
-You may only use keytypes that are supported in your ipfs binary: currently
-this is only /ipns. Unless you have a relatively deep understanding of the
+You may only use keytypes that are supported in your ipfs binary: /ipns and
+/pk, plus the ones registered with core.RegisterRecordNamespace by programs
+embedding go-ipfs. Unless you have a relatively deep understanding of the
 go-ipfs DHT internals, you likely want to be using 'ipfs name publish' instead
 of this.
 
-Value is arbitrary text. Standard input can be used to provide value.
+Value is arbitrary text. Standard input can be used to provide value. It is
+checked by the validator of the keytype before being written, as other nodes
+drop the invalid records.
+
+NOTE: A value may not exceed 2048 bytes, unless its keytype sets another
+limit. Keytypes may also limit how many values are put per hour.
 
-NOTE: A value may not exceed 2048 bytes.
+With --quorum, the put fails unless the value was sent to at least that many
+peers, after --retries more attempts.
 `,
 	},
 
@@ -627,6 +657,9 @@ NOTE: A value may not exceed 2048 bytes.
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("verbose", "v", "Print extra information.").Default(false),
+		cmds.IntOption("quorum", "q", "Number of peers the value must be sent to.").Default(0),
+		cmds.StringOption("attempt-timeout", "Maximum duration of each attempt, e.g. \"30s\"."),
+		cmds.IntOption("retries", "Number of times to retry a failed attempt.").Default(0),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -635,17 +668,17 @@ NOTE: A value may not exceed 2048 bytes.
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
 
-		outChan := make(chan interface{})
-		res.SetOutput((<-chan interface{})(outChan))
-
-		events := make(chan *notif.QueryEvent)
-		ctx := notif.RegisterForQueryEvents(req.Context(), events)
+		ropts, err := recordOptions(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
 
 		key, err := escapeDhtKey(req.Arguments()[0])
 		if err != nil {
@@ -654,6 +687,16 @@ NOTE: A value may not exceed 2048 bytes.
 		}
 
 		data := req.Arguments()[1]
+		if err := core.CheckRecordPut(key, []byte(data)); err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		outChan := make(chan interface{})
+		res.SetOutput((<-chan interface{})(outChan))
+
+		events := make(chan *notif.QueryEvent)
+		ctx := notif.RegisterForQueryEvents(req.Context(), events)
 
 		go func() {
 			defer close(outChan)
@@ -664,7 +707,7 @@ NOTE: A value may not exceed 2048 bytes.
 
 		go func() {
 			defer close(events)
-			err := dht.PutValue(ctx, key, []byte(data))
+			err := core.PutRecord(ctx, dht, key, []byte(data), ropts)
 			if err != nil {
 				notif.PublishQueryEvent(ctx, &notif.QueryEvent{
 					Type:  notif.QueryError,
@@ -768,6 +811,39 @@ func printEvent(obj *notif.QueryEvent, out io.Writer, verbose bool, override pfu
 	}
 }
 
+// recordOptions reads the quorum, attempt-timeout and retries options of 'dht get'
+// and 'dht put'.
+func recordOptions(req cmds.Request) (core.RecordOptions, error) {
+	var opts core.RecordOptions
+
+	quorum, _, err := req.Option("quorum").Int()
+	if err != nil {
+		return opts, err
+	}
+	if quorum < 0 {
+		return opts, errors.New("quorum must not be negative")
+	}
+	opts.Quorum = quorum
+
+	if timeout, found, _ := req.Option("attempt-timeout").String(); found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return opts, fmt.Errorf("error parsing attempt-timeout option: %s", err)
+		}
+		opts.Timeout = d
+	}
+
+	retries, _, err := req.Option("retries").Int()
+	if err != nil {
+		return opts, err
+	}
+	if retries < 0 {
+		return opts, errors.New("retries must not be negative")
+	}
+	opts.Retries = retries
+	return opts, nil
+}
+
 func escapeDhtKey(s string) (string, error) {
 	parts := path.SplitList(s)
 	switch len(parts) {
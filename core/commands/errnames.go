@@ -0,0 +1,29 @@
+package commands
+
+import (
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	path "github.com/ipfs/go-ipfs/path"
+	pin "github.com/ipfs/go-ipfs/pin"
+)
+
+// the stable names of the errors of the packages the commands use
+func init() {
+	cmds.RegisterErrorName(cmds.ErrNameInvalidPath, func(err error) bool {
+		return err == path.ErrBadPath || err == path.ErrNoComponents
+	})
+	cmds.RegisterErrorName(cmds.ErrNamePathUnresolvable, func(err error) bool {
+		_, nolink := err.(path.ErrNoLink)
+		return nolink || err == namesys.ErrResolveFailed || err == core.ErrNoNamesys
+	})
+	cmds.RegisterErrorName(cmds.ErrNameBlockNotFound, func(err error) bool {
+		return err == dag.ErrNotFound || err == bserv.ErrNotFound || err == bstore.ErrNotFound
+	})
+	cmds.RegisterErrorName(cmds.ErrNamePinNotFound, func(err error) bool {
+		return err == pin.ErrNotPinned
+	})
+}
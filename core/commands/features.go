@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+)
+
+// FeatureOutput is a feature and whether the node has it enabled.
+type FeatureOutput struct {
+	Name        string
+	Description string
+	Stability   string
+	Enabled     bool
+}
+
+type featureList struct {
+	Features []FeatureOutput
+}
+
+var FeaturesCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List and toggle optional features.",
+		ShortDescription: `
+'ipfs features' manages the features listed in the Features section of the
+config. Experimental features are disabled by default. Changes apply the
+next time the daemon starts.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":      featuresLsCmd,
+		"enable":  featuresEnableCmd,
+		"disable": featuresDisableCmd,
+	},
+}
+
+var featuresLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the features and whether they are enabled.",
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		cfg, err := req.InvocContext().GetConfig()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(featuresOutput(cfg, config.Features))
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: featureListMarshaler,
+	},
+	Type: featureList{},
+}
+
+var featuresEnableCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Enable features.",
+		ShortDescription: `
+'ipfs features enable' enables features in the config. The daemon must be
+restarted for them to take effect.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, true, "Names of the features to enable."),
+	},
+	Mutates: cmds.AlwaysMutates,
+	Run: func(req cmds.Request, res cmds.Response) {
+		setFeatures(req, res, true)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: featureListMarshaler,
+	},
+	Type: featureList{},
+}
+
+var featuresDisableCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Disable features.",
+		ShortDescription: `
+'ipfs features disable' disables features in the config, including the ones
+enabled by their former Experimental option. The daemon must be restarted
+for them to take effect.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, true, "Names of the features to disable."),
+	},
+	Mutates: cmds.AlwaysMutates,
+	Run: func(req cmds.Request, res cmds.Response) {
+		setFeatures(req, res, false)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: featureListMarshaler,
+	},
+	Type: featureList{},
+}
+
+func setFeatures(req cmds.Request, res cmds.Response, enabled bool) {
+	var features []config.Feature
+	for _, name := range req.Arguments() {
+		f, err := config.FeatureByName(name)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+		features = append(features, f)
+	}
+
+	r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+	defer r.Close()
+	cfg, err := r.Config()
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+
+	if cfg.Features == nil {
+		cfg.Features = make(map[string]bool)
+	}
+	for _, f := range features {
+		cfg.Features[f.Name] = enabled
+	}
+	if err := r.SetConfig(cfg); err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+
+	res.SetOutput(featuresOutput(cfg, features))
+}
+
+func featuresOutput(cfg *config.Config, features []config.Feature) *featureList {
+	out := &featureList{Features: make([]FeatureOutput, 0, len(features))}
+	for _, f := range features {
+		out.Features = append(out.Features, FeatureOutput{
+			Name:        f.Name,
+			Description: f.Description,
+			Stability:   string(f.Stability),
+			Enabled:     cfg.FeatureEnabled(f.Name),
+		})
+	}
+	return out
+}
+
+func featureListMarshaler(res cmds.Response) (io.Reader, error) {
+	list, ok := res.Output().(*featureList)
+	if !ok {
+		return nil, fmt.Errorf("expected output type to be featureList")
+	}
+
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+	for _, f := range list.Features {
+		state := "disabled"
+		if f.Enabled {
+			state = "enabled"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Name, state, f.Stability, f.Description)
+	}
+	w.Flush()
+	return buf, nil
+}
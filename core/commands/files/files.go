@@ -10,6 +10,7 @@ import (
 	gopath "path"
 	"strings"
 
+	car "github.com/ipfs/go-ipfs/car"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	dag "github.com/ipfs/go-ipfs/merkledag"
@@ -19,6 +20,7 @@ import (
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
 )
 
@@ -39,21 +41,34 @@ of consistency guarantees. If the daemon is unexpectedly killed before running
 'ipfs files flush' on the files in question, then data may be lost. This also
 applies to running 'ipfs repo gc' concurrently with '--flush=false'
 operations.
+
+With '--flush=true', every operation recomputes all the directories between
+the changed path and the root, and republishes the root. To do many
+operations on a tree, run them with '--flush=false', then run a single
+'ipfs files flush' on their common ancestor:
+
+    $ ipfs files write --flush=false --create /dir/a < a
+    $ ipfs files write --flush=false --create /dir/b < b
+    $ ipfs files flush /dir
+
+Until the flush, the changes only exist in the memory of the daemon.
 `,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("f", "flush", "Flush target and ancestors after write.").Default(true),
 	},
 	Subcommands: map[string]*cmds.Command{
-		"read":  FilesReadCmd,
-		"write": FilesWriteCmd,
-		"mv":    FilesMvCmd,
-		"cp":    FilesCpCmd,
-		"ls":    FilesLsCmd,
-		"mkdir": FilesMkdirCmd,
-		"stat":  FilesStatCmd,
-		"rm":    FilesRmCmd,
-		"flush": FilesFlushCmd,
+		"read":   FilesReadCmd,
+		"write":  FilesWriteCmd,
+		"mv":     FilesMvCmd,
+		"cp":     FilesCpCmd,
+		"ls":     FilesLsCmd,
+		"mkdir":  FilesMkdirCmd,
+		"stat":   FilesStatCmd,
+		"rm":     FilesRmCmd,
+		"flush":  FilesFlushCmd,
+		"export": FilesExportCmd,
+		"import": FilesImportCmd,
 	},
 }
 
@@ -69,7 +84,7 @@ var FilesStatCmd = &cmds.Command{
 	},
 	Options: []cmds.Option{
 		cmds.StringOption("format", "Print statistics in given format. Allowed tokens: "+
-			"<hash> <size> <cumulsize> <type> <childs>. Conflicts with other format options.").Default(
+			"<hash> <size> <cumulsize> <type> <childs> <mime>. Conflicts with other format options.").Default(
 			`<hash>
 Size: <size>
 CumulativeSize: <cumulsize>
@@ -77,6 +92,7 @@ ChildBlocks: <childs>
 Type: <type>`),
 		cmds.BoolOption("hash", "Print only hash. Implies '--format=<hash>'. Conflicts with other format options.").Default(false),
 		cmds.BoolOption("size", "Print only size. Implies '--format=<cumulsize>'. Conflicts with other format options.").Default(false),
+		cmds.BoolOption("mime", "Detect the MIME type of files from their name and first bytes.").Default(false),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 
@@ -109,6 +125,21 @@ Type: <type>`),
 			return
 		}
 
+		withMime, _, _ := req.Option("mime").Bool()
+		if withMime && fsn.Type() == mfs.TFile {
+			nd, err := fsn.GetNode()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			o.MimeType, err = uio.ContentType(req.Context(), gopath.Base(path), nd, node.DAG)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
 		res.SetOutput(o)
 	},
 	Marshalers: cmds.MarshalerMap{
@@ -123,6 +154,12 @@ Type: <type>`),
 			s = strings.Replace(s, "<cumulsize>", fmt.Sprintf("%d", out.CumulativeSize), -1)
 			s = strings.Replace(s, "<childs>", fmt.Sprintf("%d", out.Blocks), -1)
 			s = strings.Replace(s, "<type>", out.Type, -1)
+			s = strings.Replace(s, "<mime>", out.MimeType, -1)
+
+			// the default format shows the detected type too
+			if isDefaultStatFormat(res.Request()) && out.MimeType != "" {
+				s += "\nMimeType: " + out.MimeType
+			}
 
 			fmt.Fprintln(buf, s)
 			return buf, nil
@@ -135,6 +172,13 @@ func moreThanOne(a, b, c bool) bool {
 	return a && b || b && c || a && c
 }
 
+func isDefaultStatFormat(req cmds.Request) bool {
+	hash, _, _ := req.Option("hash").Bool()
+	size, _, _ := req.Option("size").Bool()
+	_, found, _ := req.Option("format").String()
+	return !hash && !size && !found
+}
+
 func statGetFormatOptions(req cmds.Request) (string, error) {
 
 	hash, _, _ := req.Option("hash").Bool()
@@ -204,6 +248,7 @@ var FilesCpCmd = &cmds.Command{
 		cmds.StringArg("source", true, false, "Source object to copy."),
 		cmds.StringArg("dest", true, false, "Destination to copy object to."),
 	},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		node, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -282,6 +327,7 @@ type Object struct {
 	CumulativeSize uint64
 	Blocks         int
 	Type           string
+	MimeType       string `json:",omitempty"`
 }
 
 type FilesLsOutput struct {
@@ -526,6 +572,7 @@ Example:
 		cmds.StringArg("source", true, false, "Source file to move."),
 		cmds.StringArg("dest", true, false, "Destination path for file to be moved to."),
 	},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -566,6 +613,10 @@ If the '--flush' option is set to false, changes will not be propogated to the
 merkledag root. This can make operations much faster when doing a large number
 of writes to a deeper directory structure.
 
+New objects follow the CidVersion and HashFunction of the Import config. Its
+RawLeaves, Chunker and Layout don't apply: files are edited in place, in a
+trickle layout of unixfs leaves.
+
 EXAMPLE:
 
     echo "hello world" | ipfs files write --create /myfs/a/b/file
@@ -575,7 +626,7 @@ WARNING:
 
 Usage of the '--flush=false' option does not guarantee data durability until
 the tree has been flushed. This can be accomplished by running 'ipfs files
-stat' on the file or any of its ancestors.
+flush' on the file or any of its ancestors.
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -588,6 +639,7 @@ stat' on the file or any of its ancestors.
 		cmds.BoolOption("truncate", "t", "Truncate the file to size zero before writing."),
 		cmds.IntOption("count", "n", "Maximum number of bytes to read."),
 	},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		path, err := checkPath(req.Arguments()[0])
 		if err != nil {
@@ -700,6 +752,7 @@ Examples:
 	Options: []cmds.Option{
 		cmds.BoolOption("parents", "p", "No error if existing, make parent directories as needed."),
 	},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -725,17 +778,26 @@ Examples:
 	},
 }
 
+type FilesFlushOutput struct {
+	Hash string
+}
+
 var FilesFlushCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Flush a given path's data to disk.",
 		ShortDescription: `
 Flush a given path to disk. This is only useful when other commands
 are run with the '--flush=false'.
+
+All the unflushed changes below the path are written out, and the directories
+from the path up to the root are recomputed once. The hash of the flushed path
+is printed.
 `,
 	},
 	Arguments: []cmds.Argument{
 		cmds.StringArg("path", false, false, "Path to flush. Default: '/'."),
 	},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		nd, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -753,7 +815,205 @@ are run with the '--flush=false'.
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+
+		fsn, err := mfs.Lookup(nd.FilesRoot, path)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fnd, err := fsn.GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&FilesFlushOutput{Hash: fnd.Cid().String()})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*FilesFlushOutput)
+			return strings.NewReader(out.Hash + "\n"), nil
+		},
+	},
+	Type: FilesFlushOutput{},
+}
+
+var FilesExportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Export a tree of mfs to a CAR file.",
+		ShortDescription: `
+Writes the dag of the given path, with all its blocks, to a CAR file, or to
+stdout if no file is given. 'ipfs files import' restores it, on this node or
+another one, with the same hashes:
+
+    $ ipfs files export /site site.car
+    $ ipfs files import site.car /site
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path to export."),
+		cmds.StringArg("file", false, false, "The CAR file to write."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		path, err := checkPath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fsn, err := mfs.Lookup(n.FilesRoot, path)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		nd, err := fsn.GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		ctx := req.Context()
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(car.Write(ctx, n.DAG, nd.Cid(), pw))
+		}()
+		res.SetOutput(pr)
+	},
+	PostRun: func(req cmds.Request, res cmds.Response) {
+		if res.Output() == nil || len(req.Arguments()) < 2 {
+			return
+		}
+		outReader := res.Output().(io.Reader)
+		res.SetOutput(nil)
+
+		f, err := os.Create(req.Arguments()[1])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(f, outReader); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+type FilesImportOutput struct {
+	Hash   string
+	Blocks int
+}
+
+var FilesImportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Import a CAR file into mfs.",
+		ShortDescription: `
+Stores the blocks of a CAR file, as written by 'ipfs files export', and puts
+its root at the given path, like 'ipfs files cp' would. The hashes are kept:
+the imported tree is the same as the exported one.
+
+The archive must have a single root, and the blocks must match their hashes.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("file", true, false, "The CAR file to import, - for stdin."),
+		cmds.StringArg("path", true, false, "Path to put the root at."),
+	},
+	Mutates: cmds.AlwaysMutates,
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		flush, _, _ := req.Option("flush").Bool()
+
+		dst, err := checkPath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		// keep the gc from removing the blocks before they are linked in
+		defer n.Blockstore.PinLock().Unlock()
+
+		root, count, err := importCar(req.Context(), n, file)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		nd, err := n.DAG.Get(req.Context(), root)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := mfs.PutNode(n.FilesRoot, dst, nd); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if flush {
+			if err := mfs.FlushPath(n.FilesRoot, dst); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		res.SetOutput(&FilesImportOutput{Hash: root.String(), Blocks: count})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*FilesImportOutput)
+			return strings.NewReader(fmt.Sprintf("imported %s (%d blocks)\n", out.Hash, out.Blocks)), nil
+		},
 	},
+	Type: FilesImportOutput{},
+}
+
+// importCar stores the blocks of the CAR file in r, and returns its root and
+// the number of blocks read.
+func importCar(ctx context.Context, n *core.IpfsNode, r io.Reader) (*cid.Cid, int, error) {
+	cr, err := car.NewReader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(cr.Header.Roots) != 1 {
+		return nil, 0, fmt.Errorf("expected an archive with a single root, got %d roots", len(cr.Header.Roots))
+	}
+	root := cr.Header.Roots[0]
+
+	stats, err := cr.Import(ctx, n.Blocks, false)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	has, err := n.Blockstore.Has(root)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !has {
+		return nil, 0, fmt.Errorf("the archive doesn't hold its root %s", root)
+	}
+	return root, stats.Blocks, nil
 }
 
 var FilesRmCmd = &cmds.Command{
@@ -777,6 +1037,7 @@ Remove files or directories.
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Recursively remove directories."),
 	},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		nd, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -814,10 +1075,11 @@ Remove files or directories.
 		}
 
 		dashr, _, _ := req.Option("r").Bool()
+		flush, _, _ := req.Option("flush").Bool()
 
 		var success bool
 		defer func() {
-			if success {
+			if success && flush {
 				err := pdir.Flush()
 				if err != nil {
 					res.SetError(err, cmds.ErrNormal)
@@ -889,6 +1151,7 @@ func getFileHandle(r *mfs.Root, path string, create bool) (*mfs.File, error) {
 		}
 
 		nd := dag.NodeWithData(ft.FilePBData(nil, 0))
+		nd.SetPrefix(r.Prefix)
 		err = pdir.AddChild(fname, nd)
 		if err != nil {
 			return nil, err
@@ -21,6 +21,7 @@ var FileStoreCmd = &cmds.Command{
 		"verify": verifyFileStore,
 		"dups":   dupsFileStore,
 	},
+	Feature: "filestore",
 }
 
 var lsFileStore = &cmds.Command{
@@ -0,0 +1,242 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	path "github.com/ipfs/go-ipfs/path"
+
+	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
+	inet "gx/ipfs/QmVHSBsn8LEeay8m5ERebgUVuhzw838PsyTttCmP6GMJkg/go-libp2p-net"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+)
+
+// findProvidersSearchFactor is how many more providers than --num are
+// searched for, as some of them may not be reachable.
+const findProvidersSearchFactor = 5
+
+// ProviderOutput is a provider of some content, with what this node knows of
+// it. Agent and Latency are only known for connected peers.
+type ProviderOutput struct {
+	ID        string
+	Addrs     []string
+	Connected bool
+	Agent     string        `json:",omitempty"`
+	Latency   time.Duration `json:",omitempty"`
+}
+
+// reachable tells whether the provider can be fetched from: it's connected,
+// or has addresses to dial.
+func (p *ProviderOutput) reachable() bool {
+	return p.Connected || len(p.Addrs) > 0
+}
+
+var FindProvidersCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Find the peers providing some content.",
+		ShortDescription: `
+Searches the routing system for the peers providing <key>, a CID or an IPFS
+path, and prints their IDs, with their addresses, agent version and latency
+when known.
+`,
+		LongDescription: `
+Searches the routing system for the peers providing <key>, a CID or an IPFS
+path, and prints their IDs, with their addresses, agent version and latency
+when known. The agent version and latency are only known for the peers the
+node is connected to.
+
+The search stops once --num reachable providers are found, that is providers
+the node is connected to or has addresses for. Unreachable providers are
+printed too, but don't count. Results are sorted, connected providers first
+by latency, unless --streaming is given, in which case each provider is
+printed as soon as it is found.
+
+Example:
+
+  > ipfs find-providers -v --num=1 QmSomeContent...
+  QmProvider...
+    agent: go-ipfs/0.4.10/
+    latency: 23.1ms
+    /ip4/104.131.131.82/tcp/4001
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("key", true, false, "The CID or IPFS path of the content to find providers of."),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("num", "n", "Stop after finding this many reachable providers.").Default(20),
+		cmds.BoolOption("streaming", "s", "Print the providers as soon as they are found.").Default(false),
+		cmds.BoolOption("verbose", "v", "Print the addresses, agent and latency of the providers.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		num, _, _ := req.Option("num").Int()
+		if num <= 0 {
+			res.SetError(fmt.Errorf("--num must be positive"), cmds.ErrClient)
+			return
+		}
+		streaming, _, _ := req.Option("streaming").Bool()
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+		c, err := core.ResolveToCid(req.Context(), n, p)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		outChan := make(chan interface{})
+		res.SetOutput((<-chan interface{})(outChan))
+
+		go func() {
+			defer close(outChan)
+
+			ctx, cancel := context.WithCancel(req.Context())
+			defer cancel()
+
+			send := func(p *ProviderOutput) bool {
+				select {
+				case outChan <- p:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			}
+
+			var found []*ProviderOutput
+			reachable := 0
+			for pi := range n.Routing.FindProvidersAsync(ctx, c, num*findProvidersSearchFactor) {
+				prov := providerOutput(n, pi)
+				if streaming {
+					if !send(prov) {
+						return
+					}
+				} else {
+					found = append(found, prov)
+				}
+
+				if prov.reachable() {
+					reachable++
+				}
+				if reachable >= num {
+					break
+				}
+			}
+
+			sort.Stable(providersByLatency(found))
+			for _, prov := range found {
+				if !send(prov) {
+					return
+				}
+			}
+		}()
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			verbose, _, _ := res.Request().Option("v").Bool()
+			marshal := func(v interface{}) (io.Reader, error) {
+				prov, ok := v.(*ProviderOutput)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+
+				buf := new(bytes.Buffer)
+				fmt.Fprintln(buf, prov.ID)
+				if verbose {
+					if prov.Agent != "" {
+						fmt.Fprintf(buf, "  agent: %s\n", prov.Agent)
+					}
+					if prov.Latency != 0 {
+						fmt.Fprintf(buf, "  latency: %s\n", prov.Latency)
+					}
+					for _, a := range prov.Addrs {
+						fmt.Fprintf(buf, "  %s\n", a)
+					}
+				}
+				return buf, nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
+	},
+	Type: ProviderOutput{},
+}
+
+// providerOutput describes a provider with the addresses it was found with,
+// and what the peerstore knows of it.
+func providerOutput(n *core.IpfsNode, pi pstore.PeerInfo) *ProviderOutput {
+	out := &ProviderOutput{
+		ID:        pi.ID.Pretty(),
+		Connected: n.PeerHost.Network().Connectedness(pi.ID) == inet.Connected,
+	}
+
+	seen := make(map[string]bool)
+	for _, addrs := range [][]ma.Multiaddr{pi.Addrs, n.Peerstore.Addrs(pi.ID)} {
+		for _, a := range addrs {
+			if s := a.String(); !seen[s] {
+				seen[s] = true
+				out.Addrs = append(out.Addrs, s)
+			}
+		}
+	}
+
+	if out.Connected {
+		if v, err := n.Peerstore.Get(pi.ID, "AgentVersion"); err == nil {
+			if vs, ok := v.(string); ok {
+				out.Agent = vs
+			}
+		}
+		out.Latency = n.Peerstore.LatencyEWMA(pi.ID)
+	}
+	return out
+}
+
+// providersByLatency sorts connected providers first, by latency when known,
+// then the reachable ones.
+type providersByLatency []*ProviderOutput
+
+func (s providersByLatency) Len() int      { return len(s) }
+func (s providersByLatency) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s providersByLatency) Less(i, j int) bool {
+	a, b := s[i], s[j]
+	if a.Connected != b.Connected {
+		return a.Connected
+	}
+	if a.Connected && (a.Latency == 0) != (b.Latency == 0) {
+		return a.Latency != 0
+	}
+	if a.Latency != b.Latency {
+		return a.Latency < b.Latency
+	}
+	return a.reachable() && !b.reachable()
+}
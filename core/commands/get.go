@@ -34,6 +34,9 @@ To output a TAR archive instead of unpacked files, use '--archive' or '-a'.
 
 To compress the output with GZIP compression, use '--compress' or '-C'. You
 may also specify the level of compression by specifying '-l=<1-9>'.
+
+The entries of a directory are fetched '--parallel' at a time, and when the
+files are extracted, as many small files are written at the same time.
 `,
 	},
 
@@ -45,6 +48,7 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		cmds.BoolOption("archive", "a", "Output a TAR archive.").Default(false),
 		cmds.BoolOption("compress", "C", "Compress the output with GZIP compression.").Default(false),
 		cmds.IntOption("compression-level", "l", "The level of compression (1-9).").Default(-1),
+		cmds.IntOption("parallel", "j", "The number of files fetched and written at the same time.").Default(8),
 	},
 	PreRun: func(req cmds.Request) error {
 		_, err := getCompressOptions(req)
@@ -92,7 +96,8 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		}
 
 		archive, _, _ := req.Option("archive").Bool()
-		reader, err := uarchive.DagArchive(ctx, dn, p.String(), node.DAG, archive, cmplvl)
+		parallel, _, _ := req.Option("parallel").Int()
+		reader, err := uarchive.DagArchive(ctx, dn, p.String(), node.DAG, archive, cmplvl, parallel)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -119,6 +124,7 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		}
 
 		archive, _, _ := req.Option("archive").Bool()
+		parallel, _, _ := req.Option("parallel").Int()
 
 		gw := getWriter{
 			Out:         os.Stdout,
@@ -126,6 +132,7 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 			Archive:     archive,
 			Compression: cmplvl,
 			Size:        int64(res.Length()),
+			Workers:     parallel,
 		}
 
 		if err := gw.Write(outReader, outPath); err != nil {
@@ -178,6 +185,7 @@ type getWriter struct {
 	Archive     bool
 	Compression int
 	Size        int64
+	Workers     int // the number of files written at the same time
 }
 
 func (gw *getWriter) Write(r io.Reader, fpath string) error {
@@ -225,7 +233,7 @@ func (gw *getWriter) writeExtracted(r io.Reader, fpath string) error {
 	defer bar.Finish()
 	defer bar.Set64(gw.Size)
 
-	extractor := &tar.Extractor{fpath, bar.Add64}
+	extractor := &tar.Extractor{Path: fpath, Progress: bar.Add64, Workers: gw.Workers}
 	return extractor.Extract(r)
 }
 
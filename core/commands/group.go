@@ -0,0 +1,508 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	corecrypt "github.com/ipfs/go-ipfs/core/corecrypt"
+	path "github.com/ipfs/go-ipfs/path"
+	sharing "github.com/ipfs/go-ipfs/sharing"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var errSharingDisabled = errors.New("sharing groups need the experimental pubsub feature. Run daemon with --enable-pubsub-experiment, or enable it with 'ipfs features enable pubsub', to use.")
+
+type GroupsOutput struct {
+	Groups []*sharing.GroupStatus
+}
+
+type GroupInvitationsOutput struct {
+	Invitations []*sharing.Invitation
+}
+
+var GroupCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Share encrypted content with private groups of peers.",
+		ShortDescription: `
+A group is a set of peers sharing a secret key. The owner of a group, the
+peer which created it, invites members by sending them the key, encrypted to
+their public key. Invited peers join with 'ipfs group join'. The members
+publish encrypted content in the group, under
+names the other members resolve.
+
+The messages of a group are sent over pubsub, on a topic derived from the key,
+sealed with the key and signed by their sender: peers outside of the group
+can't read them, and only the members can publish. The content is encrypted
+with the key as in 'ipfs crypt', and can be fetched from any node without it
+being able to read it.
+
+Invitations need the members to have RSA keys. A member leaving a group keeps
+a copy of the key: the content published so far stays readable to it.
+
+This is an experimental feature. To use, the daemon must be run with
+'--enable-pubsub-experiment'.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"create":      groupCreateCmd,
+		"invite":      groupInviteCmd,
+		"invitations": groupInvitationsCmd,
+		"join":        groupJoinCmd,
+		"decline":     groupDeclineCmd,
+		"ls":          groupLsCmd,
+		"publish":     groupPublishCmd,
+		"resolve":     groupResolveCmd,
+		"cat":         groupCatCmd,
+		"leave":       groupLeaveCmd,
+	},
+}
+
+var groupCreateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Create a group, owned by this node.",
+		ShortDescription: `
+Creates a group with a new random key. Invite members with 'ipfs group
+invite'.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "The name of the group."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := sharingNode(req, res)
+		if !ok {
+			return
+		}
+
+		name := req.Arguments()[0]
+		if err := sharing.ValidateName(name); err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+		if err := n.Sharing.Create(name); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(nil)
+	},
+}
+
+var groupInviteCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Invite peers to a group.",
+		ShortDescription: `
+Sends the key of the group to the given peers, encrypted to their public key,
+which is looked up in the peerstore, then in the routing system. The peers
+list the invitation with 'ipfs group invitations', and join the group with
+'ipfs group join'. The invitation is resent every few minutes until they do.
+Only the owner of a group invites members.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("group", true, false, "The name of the group."),
+		cmds.StringArg("peer", true, true, "IDs of the peers to invite."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := sharingNode(req, res)
+		if !ok {
+			return
+		}
+
+		name := req.Arguments()[0]
+		for _, s := range req.Arguments()[1:] {
+			p, err := peer.IDB58Decode(s)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid peer ID %s: %s", s, err), cmds.ErrClient)
+				return
+			}
+
+			pk, err := delegatePublicKey(req.Context(), n, s)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			if err := n.Sharing.Invite(req.Context(), name, p, pk); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+		res.SetOutput(nil)
+	},
+}
+
+var groupInvitationsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the invitations to groups.",
+		ShortDescription: `
+Lists the invitations this node received and neither accepted with 'ipfs
+group join' nor declined, with the owner and the number of members of each
+group.
+`,
+	},
+	Type: GroupInvitationsOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := sharingNode(req, res)
+		if !ok {
+			return
+		}
+		res.SetOutput(&GroupInvitationsOutput{Invitations: n.Sharing.Invitations()})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*GroupInvitationsOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			for _, inv := range out.Invitations {
+				fmt.Fprintf(buf, "%s owner %s members %d\n", inv.Group, inv.Owner, inv.Members)
+			}
+			return buf, nil
+		},
+	},
+}
+
+var groupJoinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Join a group this node is invited to.",
+		ShortDescription: `
+Accepts the invitation to the group, as listed by 'ipfs group invitations',
+and tells its members. The owner is only needed when several peers invited
+this node to groups of the same name.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("group", true, false, "The name of the group."),
+		cmds.StringArg("owner", false, false, "The ID of the owner of the group."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := sharingNode(req, res)
+		if !ok {
+			return
+		}
+
+		name, owner := groupInvitationArgs(req)
+		if err := n.Sharing.Join(name, owner); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(nil)
+	},
+}
+
+var groupDeclineCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Decline an invitation to a group.",
+		ShortDescription: `
+Declines the invitation to the group, as listed by 'ipfs group invitations'.
+The invitation is ignored from then on, even when its owner sends it again.
+The owner is only needed when several peers invited this node to groups of
+the same name.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("group", true, false, "The name of the group."),
+		cmds.StringArg("owner", false, false, "The ID of the owner of the group."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := sharingNode(req, res)
+		if !ok {
+			return
+		}
+
+		name, owner := groupInvitationArgs(req)
+		if err := n.Sharing.Decline(name, owner); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(nil)
+	},
+}
+
+// groupInvitationArgs returns the group and the owner, possibly empty, of
+// the invitation given to 'ipfs group join' and 'decline'.
+func groupInvitationArgs(req cmds.Request) (string, string) {
+	args := req.Arguments()
+	if len(args) > 1 {
+		return args[0], args[1]
+	}
+	return args[0], ""
+}
+
+var groupLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the groups, with their members and entries.",
+		ShortDescription: `
+Lists the groups this node is a member of, or only the given group, with
+their owner, their members and whether they joined, and the entries
+published in them.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("group", false, false, "The name of the group to list."),
+	},
+	Type: GroupsOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := sharingNode(req, res)
+		if !ok {
+			return
+		}
+
+		out := &GroupsOutput{Groups: n.Sharing.Groups()}
+		if len(req.Arguments()) > 0 {
+			name := req.Arguments()[0]
+			var found []*sharing.GroupStatus
+			for _, g := range out.Groups {
+				if g.Name == name {
+					found = append(found, g)
+				}
+			}
+			if len(found) == 0 {
+				res.SetError(fmt.Errorf("no group named %s", name), cmds.ErrClient)
+				return
+			}
+			out.Groups = found
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*GroupsOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			for _, g := range out.Groups {
+				fmt.Fprintf(buf, "%s owner %s\n", g.Name, g.Owner)
+				for _, m := range g.Members {
+					state := "invited"
+					if m.Joined {
+						state = "joined"
+					}
+					fmt.Fprintf(buf, "  member %s %s\n", m.Peer, state)
+				}
+				for _, e := range g.Entries {
+					fmt.Fprintf(buf, "  entry %s %s by %s\n", e.Name, e.Manifest, e.Publisher)
+				}
+			}
+			return buf, nil
+		},
+	},
+}
+
+var groupPublishCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Encrypt content and publish it in a group.",
+		ShortDescription: `
+Encrypts the given file with the key of the group, adds and pins it as 'ipfs
+crypt add' does, and publishes the manifest under the given name. Publishing
+again under a name replaces the entry of this node, for all the members. The
+entries other members published under the name are kept.
+
+Example:
+
+  > ipfs group publish friends notes.txt ./notes.txt
+  published notes.txt QmYdDdmq4dLCkcqAWpN9Hh1U6UnqTa5XUu8grjB5Vyz2KG
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("group", true, false, "The name of the group."),
+		cmds.StringArg("name", true, false, "The name of the entry."),
+		cmds.FileArg("data", true, false, "The data to encrypt and publish.").EnableStdin(),
+	},
+	Type: sharing.Entry{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := sharingNode(req, res)
+		if !ok {
+			return
+		}
+
+		group, name := req.Arguments()[0], req.Arguments()[1]
+		if err := sharing.ValidateName(name); err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+		secret, err := groupSecret(n, group)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		c, err := corecrypt.Add(req.Context(), n, file, secret, true)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		e, err := n.Sharing.Publish(group, name, c)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(e)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			e, ok := res.Output().(*sharing.Entry)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			return strings.NewReader(fmt.Sprintf("published %s %s\n", e.Name, e.Manifest)), nil
+		},
+	},
+}
+
+var groupResolveCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Resolve an entry of a group to the manifest of its content.",
+		ShortDescription: `
+Prints the manifest of the content published under the given name in the
+group. Each member publishes its own entries: when several published under
+the name, give <publisher>/<name>, where <publisher> is the ID of the member.
+Use 'ipfs group cat' to read the content.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("group", true, false, "The name of the group."),
+		cmds.StringArg("name", true, false, "The name of the entry, or <publisher>/<name>."),
+	},
+	Type: sharing.Entry{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := sharingNode(req, res)
+		if !ok {
+			return
+		}
+
+		e, err := n.Sharing.Resolve(req.Arguments()[0], req.Arguments()[1])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(e)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			e, ok := res.Output().(*sharing.Entry)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			return strings.NewReader(e.Manifest + "\n"), nil
+		},
+	},
+}
+
+var groupCatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Output the decrypted content of an entry of a group.",
+		ShortDescription: `
+Fetches the content published under the given name in the group, and outputs
+it decrypted with the key of the group. The entry is named as in 'ipfs group
+resolve'.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("group", true, false, "The name of the group."),
+		cmds.StringArg("name", true, false, "The name of the entry, or <publisher>/<name>."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := sharingNode(req, res)
+		if !ok {
+			return
+		}
+
+		group := req.Arguments()[0]
+		e, err := n.Sharing.Resolve(group, req.Arguments()[1])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		secret, err := groupSecret(n, group)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p, err := path.ParsePath(e.Manifest)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		r, err := corecrypt.Cat(req.Context(), n, p, secret)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(r)
+	},
+}
+
+var groupLeaveCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Leave a group.",
+		ShortDescription: `
+Forgets the group and its entries. The content published in it stays pinned.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("group", true, false, "The name of the group."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := sharingNode(req, res)
+		if !ok {
+			return
+		}
+
+		if err := n.Sharing.Leave(req.Arguments()[0]); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(nil)
+	},
+}
+
+// groupSecret returns the secret the content of the group is encrypted with.
+func groupSecret(n *core.IpfsNode, group string) (*corecrypt.Secret, error) {
+	key, err := n.Sharing.Key(group)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", group, err)
+	}
+	return corecrypt.UserSecret(key)
+}
+
+// sharingNode returns the node if sharing groups run on it, or sets the
+// error on the response.
+func sharingNode(req cmds.Request, res cmds.Response) (*core.IpfsNode, bool) {
+	n, err := req.InvocContext().GetNode()
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return nil, false
+	}
+
+	if !n.OnlineMode() {
+		res.SetError(errNotOnline, cmds.ErrClient)
+		return nil, false
+	}
+
+	if n.Sharing == nil {
+		res.SetError(errSharingDisabled, cmds.ErrNormal)
+		return nil, false
+	}
+	return n, true
+}
@@ -55,8 +55,15 @@ If no peer is specified, prints out information for local peers.
 EXAMPLE:
 
     ipfs id Qmece2RkXhsKe5CRooNisBTh4SK119KrXXGmoK6V3kb8aH -f="<addrs>\n"
+
+'ipfs id rotate' replaces the node identity with a new one, and
+'ipfs id continuity' follows the rotations of a peer.
 `,
 	},
+	Subcommands: map[string]*cmds.Command{
+		"continuity": idContinuityCmd,
+		"rotate":     idRotateCmd,
+	},
 	Arguments: []cmds.Argument{
 		cmds.StringArg("peerid", false, false, "Peer.ID of node to look up."),
 	},
@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// maxContinuityHops bounds the rotations followed from a peer ID.
+const maxContinuityHops = 16
+
+// IdRotation is one rotation of a peer identity.
+type IdRotation struct {
+	Old     string
+	New     string
+	Rotated time.Time
+	OldKey  string `json:",omitempty"`
+}
+
+// IdRotationList is the chain of rotations of a peer identity, oldest first.
+type IdRotationList struct {
+	Rotations []IdRotation
+}
+
+var idRotateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Replace the node identity with a new one.",
+		ShortDescription: `
+'ipfs id rotate' generates a new private key and peer ID for the node, to
+retire a compromised or weak key without losing the trust of its peers. The
+old key signs a continuity record endorsing the new peer ID, which the new
+key signs back. The daemon puts the records of its past identities in the
+DHT under /continuity/<old peer id>, where peers find them with
+'ipfs id continuity <old peer id>'.
+
+The old private key is dropped, unless --oldkey names a keystore key to keep
+it as, e.g. to keep publishing the IPNS name of the old peer ID with
+'ipfs name publish --key=<oldkey>'. A compromised key shouldn't be kept.
+
+The daemon must be stopped to rotate, and reconnects with the new peer ID
+when restarted.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("type", "t", "Type of the new key [rsa, ed25519].").Default("rsa"),
+		cmds.IntOption("size", "s", "Size of the new RSA key.").Default(2048),
+		cmds.StringOption("oldkey", "Keystore name to keep the old key as."),
+	},
+	Mutates: cmds.AlwaysMutates,
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		typ, _, _ := req.Option("type").String()
+		size, _, _ := req.Option("size").Int()
+		oldKey, _, _ := req.Option("oldkey").String()
+		if oldKey == "self" {
+			res.SetError(fmt.Errorf("cannot keep the old key with name 'self'"), cmds.ErrClient)
+			return
+		}
+
+		var sk ci.PrivKey
+		switch typ {
+		case "rsa":
+			sk, _, err = ci.GenerateKeyPairWithReader(ci.RSA, size, rand.Reader)
+		case "ed25519":
+			sk, _, err = ci.GenerateEd25519Key(rand.Reader)
+		default:
+			err = fmt.Errorf("unrecognized key type: %s", typ)
+		}
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out, err := rotateIdentity(n, sk, oldKey)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(&IdRotationList{Rotations: []IdRotation{*out}})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: idRotationListMarshaler,
+	},
+	Type: IdRotationList{},
+}
+
+// rotateIdentity replaces the identity of the node with sk in the config, and
+// stores the continuity record of the rotation.
+func rotateIdentity(n *core.IpfsNode, sk ci.PrivKey, oldKey string) (*IdRotation, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if n.PrivateKey == nil {
+		if err := n.LoadPrivateKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	newID, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	skbytes, err := sk.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the old key first, it's gone once the config is written
+	if oldKey != "" {
+		if err := n.Repo.Keystore().Put(oldKey, n.PrivateKey); err != nil {
+			return nil, err
+		}
+	}
+
+	rec, err := core.NewContinuityRecord(n.PrivateKey, sk, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := core.AddContinuityRecord(n.Repo, rec); err != nil {
+		return nil, err
+	}
+
+	cfg.Identity.PeerID = newID.Pretty()
+	cfg.Identity.PrivKey = base64.StdEncoding.EncodeToString(skbytes)
+	if err := n.Repo.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	return &IdRotation{
+		Old:     n.Identity.Pretty(),
+		New:     newID.Pretty(),
+		Rotated: rec.Rotated,
+		OldKey:  oldKey,
+	}, nil
+}
+
+var idContinuityCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the rotations of a peer identity.",
+		ShortDescription: `
+'ipfs id continuity' lists the past identities of the node, from the
+continuity records written by 'ipfs id rotate'.
+
+Given a peer ID, it looks up its continuity record in the DHT, and follows
+the rotations to the current identity of the peer. Each record must be
+signed by both the old and the new key. The rotations found are added to
+the peerstore of the daemon, and the rotation known for an ID is the only
+one accepted from then on: a retired key may be compromised later. Records
+rotating an ID to different ones are rejected.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peerid", false, false, "Peer ID to follow the rotations of."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &IdRotationList{Rotations: []IdRotation{}}
+		if len(req.Arguments()) == 0 {
+			recs, err := core.GetContinuityRecords(n.Repo)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			for _, rec := range recs {
+				oldID, newID, err := rec.Verify()
+				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+				out.Rotations = append(out.Rotations, IdRotation{
+					Old:     oldID.Pretty(),
+					New:     newID.Pretty(),
+					Rotated: rec.Rotated,
+				})
+			}
+			res.SetOutput(out)
+			return
+		}
+
+		id, err := peer.IDB58Decode(req.Arguments()[0])
+		if err != nil {
+			res.SetError(cmds.ClientError("Invalid peer id"), cmds.ErrClient)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		for i := 0; i < maxContinuityHops; i++ {
+			rec, next, err := n.LookupRotation(req.Context(), id)
+			if err == core.ErrConflictingRotation || err == core.ErrAmbiguousRotation {
+				res.SetError(fmt.Errorf("%s: %s", id.Pretty(), err), cmds.ErrNormal)
+				return
+			}
+			if err != nil {
+				// the end of the chain, or a peer that never rotated
+				if i > 0 {
+					break
+				}
+				res.SetError(fmt.Errorf("no continuity record for %s: %s", id.Pretty(), err), cmds.ErrNormal)
+				return
+			}
+			out.Rotations = append(out.Rotations, IdRotation{
+				Old:     id.Pretty(),
+				New:     next.Pretty(),
+				Rotated: rec.Rotated,
+			})
+			id = next
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: idRotationListMarshaler,
+	},
+	Type: IdRotationList{},
+}
+
+func idRotationListMarshaler(res cmds.Response) (io.Reader, error) {
+	out, ok := res.Output().(*IdRotationList)
+	if !ok {
+		return nil, fmt.Errorf("expected output type to be IdRotationList")
+	}
+
+	buf := new(bytes.Buffer)
+	for _, r := range out.Rotations {
+		fmt.Fprintf(buf, "%s -> %s %s\n", r.Old, r.New, r.Rotated.Format(time.RFC3339))
+	}
+	return buf, nil
+}
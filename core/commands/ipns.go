@@ -88,7 +88,12 @@ Resolve the value of a dnslink:
 		}
 
 		if nocache {
-			resolver = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), 0)
+			opts, err := n.ResolveOptions()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			resolver = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), 0, 0, opts)
 		}
 
 		var name string
@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var NameStateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Move the publish state of IPNS names between nodes.",
+		ShortDescription: `
+A node remembers the sequence number of the last record it published for each
+name, so that its next record supersedes it. When a key moves to another node,
+export its state here and import it there along with the key, or records
+published by the new node may be ignored in favour of the old ones.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"export": nameStateExportCmd,
+		"import": nameStateImportCmd,
+	},
+}
+
+var nameStateExportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Print the publish state of an IPNS name.",
+		ShortDescription: `
+Prints the publish state of the name of --key, as JSON, to be imported on
+another node with 'ipfs name state import'.
+
+Example:
+
+  > ipfs name state export --key=site > site.state
+  (on the other node)
+  > ipfs name state import site.state
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("key", "k", "Name of the key, as listed by 'ipfs key list'. Default: <<default>>.").Default("self"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		kname, _, _ := req.Option("key").String()
+		k, err := n.GetKey(kname)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		pid, err := peer.IDFromPrivateKey(k)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		st, err := namesys.GetPublishState(n.Repo.Datastore(), pid.Pretty())
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if st == nil {
+			res.SetError(fmt.Errorf("%s was never published from this node", pid.Pretty()), cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(st)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			st, ok := res.Output().(*namesys.PublishState)
+			if !ok {
+				return nil, fmt.Errorf("expected output type to be PublishState")
+			}
+			b, err := json.MarshalIndent(st, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return strings.NewReader(string(b) + "\n"), nil
+		},
+	},
+	Type: namesys.PublishState{},
+}
+
+var nameStateImportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Import the publish state of an IPNS name.",
+		ShortDescription: `
+Imports a publish state printed by 'ipfs name state export'. A state older than
+the one of this node, with a lower sequence number, is ignored, so importing
+never makes the sequence go back.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("file", true, false, "The file holding the exported state."),
+	},
+	Mutates: cmds.AlwaysMutates,
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		st := new(namesys.PublishState)
+		if err := json.NewDecoder(file).Decode(st); err != nil {
+			res.SetError(fmt.Errorf("invalid publish state: %s", err), cmds.ErrClient)
+			return
+		}
+		if _, err := peer.IDB58Decode(strings.SplitN(st.Name, "/", 2)[0]); err != nil {
+			res.SetError(fmt.Errorf("invalid name in publish state: %q", st.Name), cmds.ErrClient)
+			return
+		}
+
+		if err := namesys.PutPublishState(n.Repo.Datastore(), st); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		cur, err := namesys.GetPublishState(n.Repo.Datastore(), st.Name)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(cur)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			st, ok := res.Output().(*namesys.PublishState)
+			if !ok {
+				return nil, fmt.Errorf("expected output type to be PublishState")
+			}
+			return strings.NewReader(fmt.Sprintf("%s: sequence %d, %s\n", st.Name, st.Sequence, st.Value)), nil
+		},
+	},
+	Type: namesys.PublishState{},
+}
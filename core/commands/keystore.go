@@ -3,16 +3,25 @@ package commands
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
 	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
 	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
 )
 
@@ -33,10 +42,11 @@ var KeyCmd = &cmds.Command{
 		`,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"gen":    keyGenCmd,
-		"list":   keyListCmd,
-		"rename": keyRenameCmd,
-		"rm":     keyRmCmd,
+		"export-identity": keyExportIdentityCmd,
+		"gen":             keyGenCmd,
+		"list":            keyListCmd,
+		"rename":          keyRenameCmd,
+		"rm":              keyRmCmd,
 	},
 }
 
@@ -68,6 +78,7 @@ var keyGenCmd = &cmds.Command{
 	Arguments: []cmds.Argument{
 		cmds.StringArg("name", true, false, "name of key to create"),
 	},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -223,6 +234,7 @@ var keyRenameCmd = &cmds.Command{
 	Options: []cmds.Option{
 		cmds.BoolOption("force", "f", "Allow to overwrite an existing key."),
 	},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -327,6 +339,7 @@ var keyRmCmd = &cmds.Command{
 	Options: []cmds.Option{
 		cmds.BoolOption("l", "Show extra information about keys."),
 	},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -376,6 +389,143 @@ var keyRmCmd = &cmds.Command{
 	Type: KeyOutputList{},
 }
 
+var keyExportIdentityCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Export the node identity to move it to a new repo.",
+		ShortDescription: `
+'ipfs key export-identity' writes the node's private key, the IPNS keys, their
+last published records and the Peering peers to <file>. Initializing a repo
+with 'ipfs init --import-identity=<file>' gives the new node the same peer ID
+and IPNS names.
+
+The file holds private keys, keep it safe. The daemon of the exported repo
+refuses to start afterwards, as two nodes with one identity disrupt each
+other. It must be stopped to export.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("file", true, false, "File to write the identity to."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("l", "Show extra information about keys."),
+	},
+	Mutates: cmds.AlwaysMutates,
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		bundle, list, err := exportIdentity(n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		data, err := json.MarshalIndent(bundle, "", "  ")
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		// never overwrite, it may be the export of another node
+		f, err := os.OpenFile(req.Arguments()[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if err := f.Close(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := fsrepo.MarkIdentityExported(req.InvocContext().ConfigRoot, bundle.Exported); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&KeyOutputList{list})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: keyOutputListMarshaler,
+	},
+	Type: KeyOutputList{},
+}
+
+func exportIdentity(n *core.IpfsNode) (*config.IdentityBundle, []KeyOutput, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if n.PrivateKey == nil {
+		if err := n.LoadPrivateKey(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	bundle := &config.IdentityBundle{
+		Identity: cfg.Identity,
+		Keys:     make(map[string]string),
+		Records:  make(map[string][]byte),
+		Peering:  cfg.Peering.Peers,
+		Exported: time.Now(),
+	}
+
+	keys := map[string]ci.PrivKey{"self": n.PrivateKey}
+	names, err := n.Repo.Keystore().List()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range names {
+		k, err := n.Repo.Keystore().Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		b, err := k.Bytes()
+		if err != nil {
+			return nil, nil, err
+		}
+		keys[name] = k
+		bundle.Keys[name] = base64.StdEncoding.EncodeToString(b)
+	}
+
+	var list []KeyOutput
+	for name, k := range keys {
+		pid, err := peer.IDFromPrivateKey(k)
+		if err != nil {
+			return nil, nil, err
+		}
+		list = append(list, KeyOutput{Name: name, Id: pid.Pretty()})
+
+		rec, err := n.Repo.Datastore().Get(namesys.IpnsDsKey(pid))
+		switch err {
+		case nil:
+		case ds.ErrNotFound:
+			continue
+		default:
+			return nil, nil, err
+		}
+		if b, ok := rec.([]byte); ok {
+			bundle.Records[name] = b
+		}
+	}
+	sort.Sort(keyOutputsByName(list))
+	return bundle, list, nil
+}
+
+type keyOutputsByName []KeyOutput
+
+func (s keyOutputsByName) Len() int           { return len(s) }
+func (s keyOutputsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s keyOutputsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
 func keyOutputListMarshaler(res cmds.Response) (io.Reader, error) {
 	withId, _, _ := res.Request().Option("l").Bool()
 
@@ -2,8 +2,11 @@ package commands
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"text/tabwriter"
 
 	blockservice "github.com/ipfs/go-ipfs/blockservice"
@@ -23,6 +26,7 @@ type LsLink struct {
 	Name, Hash string
 	Size       uint64
 	Type       unixfspb.Data_DataType
+	MimeType   string `json:",omitempty"`
 }
 
 type LsObject struct {
@@ -44,6 +48,24 @@ the following format:
   <link base58 hash> <link size in bytes> <link name>
 
 The JSON output contains type information.
+`,
+		LongDescription: `
+Displays the contents of an IPFS or IPNS object(s) at the given path, with
+the following format:
+
+  <link base58 hash> <link size in bytes> <link name>
+
+The JSON output contains type information.
+
+Very wide directories, such as sharded ones, can be listed a page at a time
+with --offset and --count. The links are listed in the order of the
+directory, and only the links up to the end of the page are read: the first
+pages come quickly. With --sorted, the links are sorted by name, which needs
+them all.
+
+Example:
+
+  > ipfs ls --sorted --offset=100 --count=50 QmBigDir
 `,
 	},
 
@@ -53,6 +75,10 @@ The JSON output contains type information.
 	Options: []cmds.Option{
 		cmds.BoolOption("headers", "v", "Print table headers (Hash, Size, Name).").Default(false),
 		cmds.BoolOption("resolve-type", "Resolve linked objects to find out their types.").Default(true),
+		cmds.BoolOption("long", "l", "Also print the MIME type of files, detected from their name and first bytes.").Default(false),
+		cmds.BoolOption("sorted", "s", "Sort the links by name.").Default(false),
+		cmds.IntOption("offset", "o", "Number of links to skip.").Default(0),
+		cmds.IntOption("count", "n", "Maximum number of links to list, -1 for no limit.").Default(-1),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		nd, err := req.InvocContext().GetNode()
@@ -73,6 +99,15 @@ The JSON output contains type information.
 			return
 		}
 
+		long, _, _ := req.Option("long").Bool()
+		sorted, _, _ := req.Option("sorted").Bool()
+		offset, _, _ := req.Option("offset").Int()
+		count, _, _ := req.Option("count").Int()
+		if offset < 0 {
+			res.SetError(errors.New("--offset can't be negative"), cmds.ErrClient)
+			return
+		}
+
 		dserv := nd.DAG
 		if !resolve {
 			offlineexch := offline.Exchange(nd.Blockstore)
@@ -111,7 +146,7 @@ The JSON output contains type information.
 				return
 			}
 
-			links, err := dir.Links(req.Context())
+			links, err := dirLinks(req.Context(), dir, sorted, offset, count)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
@@ -143,11 +178,23 @@ The JSON output contains type information.
 
 					t = d.GetType()
 				}
+				_, raw := linkNode.(*merkledag.RawNode)
+
+				var ctype string
+				if long && (t == unixfspb.Data_File || raw) {
+					ctype, err = uio.ContentType(req.Context(), link.Name, linkNode, dserv)
+					if err != nil {
+						res.SetError(err, cmds.ErrNormal)
+						return
+					}
+				}
+
 				output[i].Links[j] = LsLink{
-					Name: link.Name,
-					Hash: link.Cid.String(),
-					Size: link.Size,
-					Type: t,
+					Name:     link.Name,
+					Hash:     link.Cid.String(),
+					Size:     link.Size,
+					Type:     t,
+					MimeType: ctype,
 				}
 			}
 		}
@@ -158,6 +205,7 @@ The JSON output contains type information.
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
 
 			headers, _, _ := res.Request().Option("headers").Bool()
+			long, _, _ := res.Request().Option("long").Bool()
 			output := res.Output().(*LsOutput)
 			buf := new(bytes.Buffer)
 			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
@@ -166,13 +214,25 @@ The JSON output contains type information.
 					fmt.Fprintf(w, "%s:\n", object.Hash)
 				}
 				if headers {
-					fmt.Fprintln(w, "Hash\tSize\tName")
+					if long {
+						fmt.Fprintln(w, "Hash\tSize\tMimeType\tName")
+					} else {
+						fmt.Fprintln(w, "Hash\tSize\tName")
+					}
 				}
 				for _, link := range object.Links {
 					if link.Type == unixfspb.Data_Directory {
 						link.Name += "/"
 					}
-					fmt.Fprintf(w, "%s\t%v\t%s\n", link.Hash, link.Size, link.Name)
+					if long {
+						ctype := link.MimeType
+						if ctype == "" {
+							ctype = "-"
+						}
+						fmt.Fprintf(w, "%s\t%v\t%s\t%s\n", link.Hash, link.Size, ctype, link.Name)
+					} else {
+						fmt.Fprintf(w, "%s\t%v\t%s\n", link.Hash, link.Size, link.Name)
+					}
 				}
 				if len(output.Objects) > 1 {
 					fmt.Fprintln(w)
@@ -185,3 +245,48 @@ The JSON output contains type information.
 	},
 	Type: LsOutput{},
 }
+
+var errPageRead = errors.New("page read")
+
+// dirLinks returns the links of dir from offset on, at most count of them if
+// count isn't negative. Unsorted, the links are in the order of the
+// directory, and the walk stops at the end of the page, so that listing the
+// start of a very wide directory doesn't need all of its shards.
+func dirLinks(ctx context.Context, dir *uio.Directory, sorted bool, offset, count int) ([]*node.Link, error) {
+	if sorted {
+		all, err := dir.Links(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// the links of a plain directory are the ones of its node
+		links := make([]*node.Link, len(all))
+		copy(links, all)
+		sort.Stable(merkledag.LinkSlice(links))
+
+		if offset > len(links) {
+			offset = len(links)
+		}
+		links = links[offset:]
+		if count >= 0 && count < len(links) {
+			links = links[:count]
+		}
+		return links, nil
+	}
+
+	var links []*node.Link
+	i := 0
+	err := dir.ForEachLink(ctx, func(l *node.Link) error {
+		if count >= 0 && len(links) >= count {
+			return errPageRead
+		}
+		if i >= offset {
+			links = append(links, l)
+		}
+		i++
+		return nil
+	})
+	if err != nil && err != errPageRead {
+		return nil, err
+	}
+	return links, nil
+}
@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	blockservice "github.com/ipfs/go-ipfs/blockservice"
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	manifest "github.com/ipfs/go-ipfs/manifest"
+	merkledag "github.com/ipfs/go-ipfs/merkledag"
+	path "github.com/ipfs/go-ipfs/path"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+var ManifestCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Create and verify signed content manifests.",
+		ShortDescription: `
+A manifest lists a set of root objects and their sizes, signed with a key of
+the publisher. Mirror operators verify a manifest to check that they
+replicate exactly what the publisher intended.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"create": manifestCreateCmd,
+		"verify": manifestVerifyCmd,
+	},
+}
+
+var manifestCreateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Create a signed manifest of objects.",
+		ShortDescription: `
+Outputs a manifest of the given objects, signed with the given key:
+
+    $ ipfs manifest create --key=mirror QmRoot1 QmRoot2 > manifest.json
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, true, "Path to the objects to list in the manifest.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("key", "k", "Name of the key to sign with, as listed by 'ipfs key list'. Default: <<default>>.").Default("self"),
+	},
+	Type: manifest.Signed{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		kname, _, _ := req.Option("key").String()
+		sk, err := n.GetKey(kname)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var entries []manifest.Entry
+		for _, fpath := range req.Arguments() {
+			p, err := path.ParsePath(fpath)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			nd, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, p)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			size, err := nd.Size()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			entries = append(entries, manifest.Entry{Cid: nd.Cid().String(), Size: size})
+		}
+
+		out, err := manifest.Sign(sk, entries)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*manifest.Signed)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			b, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(append(b, '\n')), nil
+		},
+	},
+}
+
+type ManifestEntryStatus struct {
+	Cid    string
+	Size   uint64
+	Status string
+}
+
+type ManifestVerifyOutput struct {
+	Publisher string
+	Created   string
+	Entries   []ManifestEntryStatus
+
+	// Complete is true if every root of the manifest is pinned with the
+	// expected size.
+	Complete bool
+}
+
+var manifestVerifyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Verify a manifest against the local pins.",
+		ShortDescription: `
+Checks the signature of a manifest, then checks that every root it lists is
+pinned recursively on this node, with the size given by the manifest. Nothing
+is fetched from the network.
+
+    $ ipfs manifest verify --publisher=<peer-id> manifest.json
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("manifest", true, false, "The manifest to verify.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("publisher", "Fail unless the manifest is signed by this peer ID."),
+	},
+	Type: ManifestVerifyOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		var s manifest.Signed
+		if err := json.NewDecoder(file).Decode(&s); err != nil {
+			res.SetError(fmt.Errorf("invalid manifest: %s", err), cmds.ErrClient)
+			return
+		}
+
+		publisher, err := s.Verify()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if want, found, _ := req.Option("publisher").String(); found && want != publisher.Pretty() {
+			res.SetError(fmt.Errorf("manifest is signed by %s, not %s", publisher.Pretty(), want), cmds.ErrNormal)
+			return
+		}
+
+		out := &ManifestVerifyOutput{
+			Publisher: s.Manifest.Publisher,
+			Created:   s.Manifest.Created,
+			Complete:  true,
+		}
+
+		dserv := merkledag.NewDAGService(blockservice.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+		for _, e := range s.Manifest.Entries {
+			status, err := verifyManifestEntry(req, n, dserv, e)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if status != "ok" {
+				out.Complete = false
+			}
+			out.Entries = append(out.Entries, ManifestEntryStatus{
+				Cid:    e.Cid,
+				Size:   e.Size,
+				Status: status,
+			})
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*ManifestVerifyOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "manifest by %s, created %s\n", out.Publisher, out.Created)
+			for _, e := range out.Entries {
+				fmt.Fprintf(buf, "%s %d: %s\n", e.Cid, e.Size, e.Status)
+			}
+			if out.Complete {
+				fmt.Fprintln(buf, "all roots replicated")
+			} else {
+				fmt.Fprintln(buf, "some roots are not replicated")
+			}
+			return buf, nil
+		},
+	},
+}
+
+// verifyManifestEntry checks a root of a manifest against the local pins.
+func verifyManifestEntry(req cmds.Request, n *core.IpfsNode, dserv merkledag.DAGService, e manifest.Entry) (string, error) {
+	c, err := cid.Decode(e.Cid)
+	if err != nil {
+		return "", fmt.Errorf("invalid cid in manifest: %s", err)
+	}
+
+	_, pinned, err := n.Pinning.IsPinnedWithType(c, pin.Recursive)
+	if err != nil {
+		return "", err
+	}
+	if !pinned {
+		return "not pinned", nil
+	}
+
+	nd, err := dserv.Get(req.Context(), c)
+	if err != nil {
+		return "", err
+	}
+
+	size, err := nd.Size()
+	if err != nil {
+		return "", err
+	}
+	if size != e.Size {
+		return fmt.Sprintf("size mismatch, local size is %d", size), nil
+	}
+	return "ok", nil
+}
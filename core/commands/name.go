@@ -56,7 +56,9 @@ Resolve the value of a dnslink:
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"publish": PublishCmd,
-		"resolve": IpnsCmd,
+		"publish":  PublishCmd,
+		"resolve":  IpnsCmd,
+		"delegate": DelegateCmd,
+		"state":    NameStateCmd,
 	},
 }
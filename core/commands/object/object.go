@@ -2,6 +2,7 @@ package objectcmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
@@ -14,6 +15,8 @@ import (
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	ft "github.com/ipfs/go-ipfs/unixfs"
@@ -120,6 +123,8 @@ multihash.
 `,
 	},
 
+	Deprecated: "use 'ipfs dag get' instead",
+
 	Arguments: []cmds.Argument{
 		cmds.StringArg("key", true, false, "Key of the object to retrieve, in base58-encoded multihash format.").EnableStdin(),
 	},
@@ -139,8 +144,13 @@ multihash.
 			return
 		}
 
-		fpath := path.Path(req.Arguments()[0])
-		node, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, fpath)
+		fpath, err := coreapi.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		node, err := coreapi.NewCoreAPI(n).Dag().Get(req.Context(), fpath)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -192,6 +202,8 @@ This command outputs data in the following encodings:
 (Specified by the "--encoding" or "--enc" flag)`,
 	},
 
+	Deprecated: "use 'ipfs dag get' instead",
+
 	Arguments: []cmds.Argument{
 		cmds.StringArg("key", true, false, "Key of the object to retrieve, in base58-encoded multihash format.").EnableStdin(),
 	},
@@ -202,9 +214,13 @@ This command outputs data in the following encodings:
 			return
 		}
 
-		fpath := path.Path(req.Arguments()[0])
+		fpath, err := coreapi.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
 
-		object, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, fpath)
+		object, err := coreapi.NewCoreAPI(n).Dag().Get(req.Context(), fpath)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -265,6 +281,8 @@ var ObjectStatCmd = &cmds.Command{
 `,
 	},
 
+	Deprecated: "use 'ipfs dag get' and 'ipfs block stat' instead",
+
 	Arguments: []cmds.Argument{
 		cmds.StringArg("key", true, false, "Key of the object to retrieve, in base58-encoded multihash format.").EnableStdin(),
 	},
@@ -275,9 +293,13 @@ var ObjectStatCmd = &cmds.Command{
 			return
 		}
 
-		fpath := path.Path(req.Arguments()[0])
+		fpath, err := coreapi.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
 
-		object, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, fpath)
+		object, err := coreapi.NewCoreAPI(n).Dag().Get(req.Context(), fpath)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -349,6 +371,8 @@ And then run:
 `,
 	},
 
+	Deprecated: "use 'ipfs dag put' instead",
+
 	Arguments: []cmds.Argument{
 		cmds.FileArg("data", true, false, "Data to be stored as a DAG object.").EnableStdin(),
 	},
@@ -381,7 +405,19 @@ And then run:
 			return
 		}
 
-		output, err := objectPut(n, input, inputenc, datafieldenc)
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		prefix, err := core.ImportPrefix(&cfg.Import)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		output, err := objectPut(req.Context(), coreapi.NewCoreAPI(n), input, inputenc, datafieldenc, prefix)
 		if err != nil {
 			errType := cmds.ErrNormal
 			if err == ErrUnknownObjectEnc {
@@ -439,6 +475,21 @@ Available templates:
 			}
 		}
 
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		prefix, err := core.ImportPrefix(&cfg.Import)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if prefix != nil {
+			node.SetPrefix(prefix)
+		}
+
 		k, err := n.DAG.Add(node)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -468,7 +519,7 @@ func nodeFromTemplate(template string) (*dag.ProtoNode, error) {
 var ErrEmptyNode = errors.New("no data or links in this node")
 
 // objectPut takes a format option, serializes bytes from stdin and updates the dag with that data
-func objectPut(n *core.IpfsNode, input io.Reader, encoding string, dataFieldEncoding string) (*Object, error) {
+func objectPut(ctx context.Context, api coreiface.CoreAPI, input io.Reader, encoding string, dataFieldEncoding string, prefix *cid.Prefix) (*Object, error) {
 
 	data, err := ioutil.ReadAll(io.LimitReader(input, inputLimit+10))
 	if err != nil {
@@ -528,7 +579,11 @@ func objectPut(n *core.IpfsNode, input io.Reader, encoding string, dataFieldEnco
 		return nil, err
 	}
 
-	_, err = n.DAG.Add(dagnode)
+	if prefix != nil {
+		dagnode.SetPrefix(prefix)
+	}
+
+	_, err = api.Dag().Put(ctx, dagnode)
 	if err != nil {
 		return nil, err
 	}
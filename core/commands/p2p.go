@@ -0,0 +1,223 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+	manet "gx/ipfs/Qmf1Gq7N45Rpuw7ev47uWgH6dLPtdnvcMRNPkVBwqjLJg2/go-multiaddr-net"
+)
+
+// P2PHTTPOutput is the HTTP service a node exposes to other peers.
+type P2PHTTPOutput struct {
+	Target  string
+	Allowed []string
+}
+
+var P2PCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Reach the services of other nodes over libp2p.",
+		ShortDescription: `
+'ipfs p2p' lets applications talk to each other through their daemons, over
+the connections between the nodes, without the nodes needing public
+addresses.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"http": p2pHTTPCmd,
+	},
+	Feature: "p2p-http",
+}
+
+var p2pHTTPCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Proxy HTTP requests to other nodes.",
+		ShortDescription: `
+A node exposes a local HTTP service to other peers with 'ipfs p2p http
+expose'. Other nodes with the p2p-http feature enabled, see 'ipfs features',
+and a proxy address set in Addresses.P2PProxy then forward the requests for
+
+  /p2p/<peer>/http/<path>
+
+on that address to /<path> on that service, over libp2p. The proxy only
+listens on a loopback address, as it sends requests as its node. Peers are authenticated by libp2p
+on both ends: the service receives the ID of the requesting peer in the
+X-Libp2p-Peer header, and only the exposing peer can answer.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"expose": p2pHTTPExposeCmd,
+		"ls":     p2pHTTPLsCmd,
+		"close":  p2pHTTPCloseCmd,
+	},
+}
+
+var p2pHTTPExposeCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Expose a local HTTP service to other peers.",
+		ShortDescription: `
+'ipfs p2p http expose' forwards the HTTP requests other peers send to this
+node to <target>, an http URL or a multiaddr like /ip4/127.0.0.1/tcp/5000.
+It replaces the service exposed before, if any, and lasts until the daemon
+stops.
+
+Example:
+
+  > ipfs p2p http expose --allow=QmClient... http://127.0.0.1:5000
+  (on QmClient...'s node, with the p2p-http feature enabled and
+  Addresses.P2PProxy set to /ip4/127.0.0.1/tcp/8081)
+  > curl http://127.0.0.1:8081/p2p/QmServer.../http/api/status
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("target", true, false, "Address of the HTTP service."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("allow", "Comma separated IDs of the only peers allowed to send requests."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		target, err := parseHTTPTarget(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		var allowed []peer.ID
+		if s, found, _ := req.Option("allow").String(); found {
+			for _, id := range strings.Split(s, ",") {
+				p, err := peer.IDB58Decode(strings.TrimSpace(id))
+				if err != nil {
+					res.SetError(fmt.Errorf("invalid peer ID %q: %s", id, err), cmds.ErrClient)
+					return
+				}
+				allowed = append(allowed, p)
+			}
+		}
+
+		n.P2PHTTP.Expose(target, allowed)
+		res.SetOutput(p2pHTTPOutput(n))
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: p2pHTTPMarshaler,
+	},
+	Type: P2PHTTPOutput{},
+}
+
+var p2pHTTPLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the HTTP service exposed to other peers.",
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		res.SetOutput(p2pHTTPOutput(n))
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: p2pHTTPMarshaler,
+	},
+	Type: P2PHTTPOutput{},
+}
+
+var p2pHTTPCloseCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Stop exposing the HTTP service to other peers.",
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		if err := n.P2PHTTP.Close(); err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+	},
+}
+
+// parseHTTPTarget parses an http URL, or a multiaddr of the host serving it.
+func parseHTTPTarget(s string) (*url.URL, error) {
+	if strings.HasPrefix(s, "/") {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, err
+		}
+		na, err := manet.ToNetAddr(a)
+		if err != nil {
+			return nil, err
+		}
+		s = "http://" + na.String()
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, errors.New("target must be an http URL or a multiaddr")
+	}
+	return u, nil
+}
+
+func p2pHTTPOutput(n *core.IpfsNode) *P2PHTTPOutput {
+	target, allowed := n.P2PHTTP.Exposed()
+	out := new(P2PHTTPOutput)
+	if target != nil {
+		out.Target = target.String()
+	}
+	for _, p := range allowed {
+		out.Allowed = append(out.Allowed, p.Pretty())
+	}
+	return out
+}
+
+func p2pHTTPMarshaler(res cmds.Response) (io.Reader, error) {
+	out, ok := res.Output().(*P2PHTTPOutput)
+	if !ok {
+		return nil, fmt.Errorf("expected output type to be P2PHTTPOutput")
+	}
+
+	buf := new(bytes.Buffer)
+	if out.Target == "" {
+		return buf, nil
+	}
+	fmt.Fprintln(buf, out.Target)
+	for _, p := range out.Allowed {
+		fmt.Fprintf(buf, "  allowed: %s\n", p)
+	}
+	return buf, nil
+}
@@ -0,0 +1,282 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+	iaddr "github.com/ipfs/go-ipfs/thirdparty/ipfsaddr"
+
+	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// PeeringPeer is a peer the node stays connected to.
+type PeeringPeer struct {
+	ID        string
+	Addrs     []string
+	Connected bool
+}
+
+type peeringList struct {
+	Peers []PeeringPeer
+}
+
+var swarmPeeringCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the peers the node stays connected to.",
+		ShortDescription: `
+'ipfs swarm peering' manages the peers listed in the Peering section of the
+config. The daemon connects to them on start, and reconnects when their
+connections drop. Changes apply right away when the daemon is running.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": swarmPeeringAddCmd,
+		"ls":  swarmPeeringLsCmd,
+		"rm":  swarmPeeringRmCmd,
+	},
+}
+
+var swarmPeeringAddCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Add peers to stay connected to.",
+		ShortDescription: `
+'ipfs swarm peering add' adds peer addresses to the Peering section of the
+config. The address format is an IPFS multiaddr:
+
+ipfs swarm peering add /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("address", true, true, "Address of the peer to stay connected to.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		iaddrs, err := parseAddresses(req.Arguments())
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer r.Close()
+		cfg, err := r.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		added := make(map[peer.ID]bool)
+		for i, addr := range req.Arguments() {
+			added[iaddrs[i].ID()] = true
+			if !containsString(cfg.Peering.Peers, addr) {
+				cfg.Peering.Peers = append(cfg.Peering.Peers, addr)
+			}
+		}
+		if err := r.SetConfig(cfg); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		peers, err := core.PeeringPeers(cfg)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var out []pstore.PeerInfo
+		for _, pi := range peers {
+			if !added[pi.ID] {
+				continue
+			}
+			if n.Peering != nil {
+				n.Peering.AddPeer(pi)
+			}
+			out = append(out, pi)
+		}
+		res.SetOutput(peeringOutput(n, out))
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: peeringListMarshaler,
+	},
+	Type: peeringList{},
+}
+
+var swarmPeeringLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the peers the node stays connected to.",
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		peers, err := core.PeeringPeers(cfg)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(peeringOutput(n, peers))
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: peeringListMarshaler,
+	},
+	Type: peeringList{},
+}
+
+var swarmPeeringRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Stop staying connected to peers.",
+		ShortDescription: `
+'ipfs swarm peering rm' removes all the addresses of the given peers from the
+Peering section of the config. Open connections to them are not closed.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", true, true, "ID of the peer to remove.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		remove := make(map[peer.ID]bool)
+		for _, arg := range req.Arguments() {
+			id, err := peer.IDB58Decode(arg)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid peer id %q: %s", arg, err), cmds.ErrClient)
+				return
+			}
+			remove[id] = true
+		}
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer r.Close()
+		cfg, err := r.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		peers, err := core.PeeringPeers(cfg)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		keep := make([]string, 0, len(cfg.Peering.Peers))
+		for _, addr := range cfg.Peering.Peers {
+			ia, err := iaddr.ParseString(addr)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if !remove[ia.ID()] {
+				keep = append(keep, addr)
+			}
+		}
+		cfg.Peering.Peers = keep
+		if err := r.SetConfig(cfg); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var out []pstore.PeerInfo
+		for _, pi := range peers {
+			if !remove[pi.ID] {
+				continue
+			}
+			if n.Peering != nil {
+				n.Peering.RemovePeer(pi.ID)
+			}
+			out = append(out, pi)
+		}
+		res.SetOutput(peeringOutput(n, out))
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: peeringListMarshaler,
+	},
+	Type: peeringList{},
+}
+
+// peeringOutput lists the peers sorted by id, with their connection state
+// when the node is online.
+func peeringOutput(n *core.IpfsNode, peers []pstore.PeerInfo) *peeringList {
+	out := &peeringList{Peers: make([]PeeringPeer, 0, len(peers))}
+	for _, pi := range peers {
+		p := PeeringPeer{ID: pi.ID.Pretty()}
+		for _, a := range pi.Addrs {
+			p.Addrs = append(p.Addrs, a.String())
+		}
+		if n.Peering != nil {
+			p.Connected = n.Peering.Connected(pi.ID)
+		}
+		out.Peers = append(out.Peers, p)
+	}
+	sort.Sort(peeringPeersByID(out.Peers))
+	return out
+}
+
+type peeringPeersByID []PeeringPeer
+
+func (s peeringPeersByID) Len() int           { return len(s) }
+func (s peeringPeersByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s peeringPeersByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+func peeringListMarshaler(res cmds.Response) (io.Reader, error) {
+	list, ok := res.Output().(*peeringList)
+	if !ok {
+		return nil, u.ErrCast()
+	}
+
+	buf := new(bytes.Buffer)
+	for _, p := range list.Peers {
+		state := "disconnected"
+		if p.Connected {
+			state = "connected"
+		}
+		fmt.Fprintf(buf, "%s %s\n", p.ID, state)
+		for _, a := range p.Addrs {
+			fmt.Fprintf(buf, "\t%s\n", a)
+		}
+	}
+	return buf, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -2,20 +2,34 @@ package commands
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	gopath "path"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	pin "github.com/ipfs/go-ipfs/pin"
+	iaddr "github.com/ipfs/go-ipfs/thirdparty/ipfsaddr"
 
 	context "context"
+	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	inet "gx/ipfs/QmVHSBsn8LEeay8m5ERebgUVuhzw838PsyTttCmP6GMJkg/go-libp2p-net"
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
 )
 
 var PinCmd = &cmds.Command{
@@ -24,26 +38,93 @@ var PinCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"add":    addPinCmd,
-		"rm":     rmPinCmd,
-		"ls":     listPinCmd,
-		"update": updatePinCmd,
+		"add":     addPinCmd,
+		"rm":      rmPinCmd,
+		"ls":      listPinCmd,
+		"update":  updatePinCmd,
+		"info":    infoPinCmd,
+		"sync":    pinSyncCmd,
+		"verify":  verifyPinCmd,
+		"export":  exportPinCmd,
+		"import":  importPinCmd,
+		"status":  statusPinCmd,
+		"jobs":    jobsPinCmd,
+		"events":  eventsPinCmd,
+		"orphans": orphansPinCmd,
+		"stat":    statPinCmd,
+		"group":   groupPinCmd,
 	},
 }
 
 type PinOutput struct {
-	Pins []string
+	Pins     []string
+	Progress int `json:",omitempty"`
 }
 
 type AddPinOutput struct {
 	Pins     []string
-	Progress int `json:",omitempty"`
+	Progress int    `json:",omitempty"`
+	Job      uint64 `json:",omitempty"`
+
+	// Bytes is the size of the nodes fetched so far, and TotalBytes the size
+	// of the dags being pinned, as far as known.
+	Bytes      uint64 `json:",omitempty"`
+	TotalBytes uint64 `json:",omitempty"`
 }
 
 var addPinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
-		Tagline:          "Pin objects to local storage.",
-		ShortDescription: "Stores an IPFS object(s) from a given path locally to disk.",
+		Tagline: "Pin objects to local storage.",
+		ShortDescription: `
+Stores an IPFS object(s) from a given path locally to disk. Pins can be given
+a name with --name, shown by 'ipfs pin ls' and 'ipfs pin info', which can
+also filter pins by name.
+
+Pins can also be given metadata, comma separated key=value pairs, to tell
+e.g. which service created them. Pinning again sets the given keys, and keeps
+the other ones. 'ipfs pin info' shows the metadata of a pin, and
+'ipfs pin ls --meta' lists the pins with the given metadata.
+
+Example:
+
+  > ipfs pin add --meta=origin=backup-job-42,team=infra QmSomeHash...
+  pinned QmSomeHash... recursively
+  > ipfs pin ls --meta=origin=backup-job-42
+  QmSomeHash... recursive
+
+With --background, the pins are queued on the daemon, which makes them even
+if the client goes away, and tries them again when they fail. The ID of the
+job is printed, to be given to 'ipfs pin status'; 'ipfs pin jobs' lists the
+jobs.
+
+  > ipfs pin add --background QmSomeHash...
+  queued pin job 1
+
+When several paths are given, a failure partway leaves the ones before
+pinned. With --atomic, all of them are pinned or none is: the dags are all
+fetched before any pin is added, and the pins are only stored if all of them
+succeed.
+
+With --local-only, the paths are pinned only if all their blocks are stored
+locally already, and the command fails at the first missing block otherwise.
+Nothing is fetched from the network, which makes it a quick way to check that
+some content is fully present:
+
+  > ipfs pin add --local-only QmSomeHash...
+  Error: pin: block QmOtherHash... of QmSomeHash... is not local: ...
+
+All the paths are checked before any is pinned. /ipns paths are not accepted,
+resolving them may need the network.
+
+When some peers are known to have the objects, --from makes the node connect
+to them and ask them first, without looking for providers: the other peers
+are asked only when they don't send the blocks in time. With --only-from,
+the requests go to them only, also when bitswap resends its wantlist, and
+the pin fails once none of them is connected anymore.
+
+  > ipfs pin add --from=QmPeerId... QmSomeHash...
+  > ipfs pin add --from=/ip4/10.0.0.2/tcp/4001/ipfs/QmPeerId... --only-from QmSomeHash...
+`,
 	},
 
 	Arguments: []cmds.Argument{
@@ -52,8 +133,16 @@ var addPinCmd = &cmds.Command{
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Recursively pin the object linked to by the specified object(s).").Default(true),
 		cmds.BoolOption("progress", "Show progress"),
+		cmds.StringOption("name", "A name for the pins."),
+		cmds.StringOption("meta", "Comma separated key=value metadata for the pins."),
+		cmds.BoolOption("background", "b", "Queue the pins on the daemon and return the job ID.").Default(false),
+		cmds.BoolOption("atomic", "Pin all the paths or none of them.").Default(false),
+		cmds.BoolOption("local-only", "Pin only if all the blocks are local already, without fetching anything.").Default(false),
+		cmds.StringOption("from", "Comma separated peer IDs or addresses to fetch the objects from first."),
+		cmds.BoolOption("only-from", "Fetch the objects only from the --from peers.").Default(false),
 	},
-	Type: AddPinOutput{},
+	Type:    AddPinOutput{},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -71,12 +160,94 @@ var addPinCmd = &cmds.Command{
 		}
 		showProgress, _, _ := req.Option("progress").Bool()
 
+		name, _, _ := req.Option("name").String()
+		if strings.ContainsAny(name, "\r\n") {
+			res.SetError(fmt.Errorf("pin names can't contain line breaks"), cmds.ErrClient)
+			return
+		}
+		var meta map[string]string
+		if s, found, _ := req.Option("meta").String(); found {
+			meta, err = parsePinMeta(s, false)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+		}
+
+		pinPaths := corerepo.Pin
+		if atomic, _, _ := req.Option("atomic").Bool(); atomic {
+			pinPaths = corerepo.PinAtomic
+		}
+		localOnly, _, _ := req.Option("local-only").Bool()
+		if localOnly {
+			pinPaths = corerepo.PinLocal
+		}
+
+		var from []pstore.PeerInfo
+		if s, found, _ := req.Option("from").String(); found {
+			from, err = parsePinFrom(s)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+		}
+		onlyFrom, _, _ := req.Option("only-from").Bool()
+		switch {
+		case onlyFrom && len(from) == 0:
+			res.SetError(errors.New("--only-from needs the peers given with --from"), cmds.ErrClient)
+			return
+		case len(from) > 0 && localOnly:
+			res.SetError(errors.New("--from and --local-only can't be used together"), cmds.ErrClient)
+			return
+		case len(from) > 0 && !n.OnlineMode():
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+		if len(from) > 0 {
+			pinWith := pinPaths
+			pinPaths = func(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
+				ctx, err := pinFetchFrom(ctx, n, from, onlyFrom)
+				if err != nil {
+					return nil, err
+				}
+				cs, err := pinWith(n, ctx, paths, recursive)
+				if err != nil && onlyFrom && !pinFromConnected(n, from) {
+					return nil, fmt.Errorf("the --from peers disconnected: %s", err)
+				}
+				return cs, err
+			}
+		}
+
+		if background, _, _ := req.Option("background").Bool(); background {
+			if n.PinJobs == nil {
+				res.SetError(errNoPinQueue, cmds.ErrClient)
+				return
+			}
+
+			paths := req.Arguments()
+			job := n.PinJobs.Add(paths, recursive, func(ctx context.Context) ([]*cid.Cid, error) {
+				defer n.Blockstore.PinLock().Unlock()
+
+				added, err := pinPaths(n, ctx, paths, recursive)
+				if err != nil {
+					return nil, err
+				}
+				return added, recordPinInfo(n, req, added, paths, name, meta)
+			})
+			res.SetOutput(&AddPinOutput{Job: job.ID})
+			return
+		}
+
 		if !showProgress {
-			added, err := corerepo.Pin(n, req.Context(), req.Arguments(), recursive)
+			added, err := pinPaths(n, req.Context(), req.Arguments(), recursive)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
 			}
+			if err := recordPinInfo(n, req, added, req.Arguments(), name, meta); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
 			res.SetOutput(&AddPinOutput{Pins: cidsToStrings(added)})
 			return
 		}
@@ -87,11 +258,15 @@ var addPinCmd = &cmds.Command{
 		ch := make(chan []*cid.Cid)
 		go func() {
 			defer close(ch)
-			added, err := corerepo.Pin(n, ctx, req.Arguments(), recursive)
+			added, err := pinPaths(n, ctx, req.Arguments(), recursive)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
 			}
+			if err := recordPinInfo(n, req, added, req.Arguments(), name, meta); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
 			ch <- added
 		}()
 		out := make(chan interface{})
@@ -108,12 +283,12 @@ var addPinCmd = &cmds.Command{
 						return
 					}
 					if pv := v.Value(); pv != 0 {
-						out <- &AddPinOutput{Progress: v.Value()}
+						out <- pinProgress(v)
 					}
 					out <- &AddPinOutput{Pins: cidsToStrings(val)}
 					return
 				case <-ticker.C:
-					out <- &AddPinOutput{Progress: v.Value()}
+					out <- pinProgress(v)
 				case <-ctx.Done():
 					res.SetError(ctx.Err(), cmds.ErrNormal)
 					return
@@ -127,6 +302,9 @@ var addPinCmd = &cmds.Command{
 
 			switch out := res.Output().(type) {
 			case *AddPinOutput:
+				if out.Job != 0 {
+					return strings.NewReader(fmt.Sprintf("queued pin job %d\n", out.Job)), nil
+				}
 				added = out.Pins
 			case <-chan interface{}:
 				progressLine := false
@@ -139,6 +317,9 @@ var addPinCmd = &cmds.Command{
 							fmt.Fprintf(res.Stderr(), "\r")
 						}
 						fmt.Fprintf(res.Stderr(), "Fetched/Processed %d nodes", r.Progress)
+						if r.TotalBytes != 0 {
+							fmt.Fprintf(res.Stderr(), ", %s of %s", humanize.Bytes(r.Bytes), humanize.Bytes(r.TotalBytes))
+						}
 						progressLine = true
 					}
 				}
@@ -174,6 +355,24 @@ var rmPinCmd = &cmds.Command{
 		ShortDescription: `
 Removes the pin from the given object allowing it to be garbage
 collected if needed. (By default, recursively. Use -r=false for direct pins.)
+`,
+		LongDescription: `
+Removes the pin from the given object allowing it to be garbage
+collected if needed. (By default, recursively. Use -r=false for direct pins.)
+
+With --unpin-children=false, the recursive pin is replaced by recursive pins
+of the children of the object: only the object itself is left to the garbage
+collector. The new pins get the information of the removed one, such as its
+name, unless they had their own.
+
+'ipfs pin orphans' shows which blocks removing a pin would leave to the
+garbage collector, before removing it.
+
+With --progress, the number of nodes processed is streamed while the pins are
+removed, as objects like {"Progress": 1000} before the final {"Pins": [...]}
+with --enc=json. Removing a recursive pin is immediate, but finding whether an
+object is pinned indirectly, when it isn't pinned directly nor recursively,
+walks all the recursive pins.
 `,
 	},
 
@@ -182,8 +381,11 @@ collected if needed. (By default, recursively. Use -r=false for direct pins.)
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Recursively unpin the object linked to by the specified object(s).").Default(true),
+		cmds.BoolOption("unpin-children", "Unpin the children of recursive pins too. If false, they are pinned recursively in place of the object.").Default(true),
+		cmds.BoolOption("progress", "Stream the number of nodes processed."),
 	},
-	Type: PinOutput{},
+	Type:    PinOutput{},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -198,23 +400,105 @@ collected if needed. (By default, recursively. Use -r=false for direct pins.)
 			return
 		}
 
-		removed, err := corerepo.Unpin(n, req.Context(), req.Arguments(), recursive)
-		if err != nil {
-			res.SetError(err, cmds.ErrNormal)
+		unpinChildren, _, _ := req.Option("unpin-children").Bool()
+		if !unpinChildren && !recursive {
+			res.SetError(errors.New("--unpin-children=false only applies to recursive pins"), cmds.ErrClient)
+			return
+		}
+
+		unpin := func(ctx context.Context) ([]*cid.Cid, error) {
+			if unpinChildren {
+				return corerepo.Unpin(n, ctx, req.Arguments(), recursive)
+			}
+			return corerepo.UnpinRoots(n, ctx, req.Arguments())
+		}
+
+		showProgress, _, _ := req.Option("progress").Bool()
+		if !showProgress {
+			removed, err := unpin(req.Context())
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			res.SetOutput(&PinOutput{Pins: cidsToStrings(removed)})
 			return
 		}
 
-		res.SetOutput(&PinOutput{cidsToStrings(removed)})
+		v := new(dag.ProgressTracker)
+		ctx := v.DeriveContext(req.Context())
+
+		ch := make(chan []*cid.Cid)
+		go func() {
+			defer close(ch)
+			removed, err := unpin(ctx)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			ch <- removed
+		}()
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
+		go func() {
+			ticker := time.NewTicker(500 * time.Millisecond)
+			defer ticker.Stop()
+			defer close(out)
+			for {
+				select {
+				case val, ok := <-ch:
+					// the nodes walked are reported on errors too, the
+					// walk is what failing to unpin takes long on
+					if pv := v.Value(); pv != 0 {
+						out <- &PinOutput{Progress: pv}
+					}
+					if !ok {
+						// error already set just return
+						return
+					}
+					out <- &PinOutput{Pins: cidsToStrings(val)}
+					return
+				case <-ticker.C:
+					out <- &PinOutput{Progress: v.Value()}
+				case <-ctx.Done():
+					res.SetError(ctx.Err(), cmds.ErrNormal)
+					return
+				}
+			}
+		}()
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-			added, ok := res.Output().(*PinOutput)
-			if !ok {
+			var removed []string
+
+			switch out := res.Output().(type) {
+			case *PinOutput:
+				removed = out.Pins
+			case <-chan interface{}:
+				progressLine := false
+				for r0 := range out {
+					r := r0.(*PinOutput)
+					if r.Pins != nil {
+						removed = r.Pins
+					} else {
+						if progressLine {
+							fmt.Fprintf(res.Stderr(), "\r")
+						}
+						fmt.Fprintf(res.Stderr(), "Processed %d nodes", r.Progress)
+						progressLine = true
+					}
+				}
+				if progressLine {
+					fmt.Fprintf(res.Stderr(), "\n")
+				}
+				if res.Error() != nil {
+					return nil, res.Error()
+				}
+			default:
 				return nil, u.ErrCast()
 			}
 
 			buf := new(bytes.Buffer)
-			for _, k := range added.Pins {
+			for _, k := range removed {
 				fmt.Fprintf(buf, "unpinned %s\n", k)
 			}
 			return buf, nil
@@ -249,6 +533,63 @@ With arguments, the command fails if any of the arguments is not a pinned
 object. And if --type=<type> is additionally used, the command will also fail
 if any of the arguments is not of the specified type.
 
+Use --info to also list who created each direct and recursive pin, and when.
+Pins created before this was recorded have no such information.
+
+Use --provenance to also list the path each direct and recursive pin was
+created with, e.g. '/ipns/mysite' or '/ipfs/<cid>/docs', as 'from <path>',
+to tell why a bare CID is pinned once the path resolves to something else.
+'ipfs pin update' records the path of the new version. Pins created by
+'ipfs add' or before this was recorded have none.
+
+Use --before and --after to list only the direct and recursive pins created
+before, and at or after a time, given as an RFC 3339 time
+(2017-06-01T12:00:00Z), a date (2017-06-01, UTC), or a duration ago (90m,
+36h, 7d). They imply --info. Pins with no creation time are not listed.
+
+Use --cid-version, --codec and --prefix to list only the pins, of any type,
+whose CIDs have this version, this codec ("dag-pb", "dag-cbor" or "raw"), or
+start with this string. For instance, '--cid-version=0' lists the pins still
+using CIDv0, and '--codec=dag-cbor --type=recursive' the CBOR roots.
+
+Use --size to also list the size of each recursive pin: the size of the
+blocks of its dag stored locally, each counted once, including the ones shared
+with other pins. Direct pins are listed with the size of their block. The
+dags are walked, which takes a while with large pins.
+
+Use --explain with arguments to find out which recursive pins keep each of
+them pinned: all the recursive pins whose dags contain it are listed, not just
+the first one found. Unpinning all of them is needed to let the garbage
+collector remove the object, unless it is also pinned directly. All the
+recursive pins are walked.
+
+The arguments can be globs, to check which pins cover the objects under a
+path without knowing their CIDs: '*', '?' and '[...]' match within a segment
+of the path as in shell globs, and a '**' segment matches any number of
+segments. The segments up to the first one with wildcards are resolved, and
+the others are matched against the names of the links of the local dag
+under it. '/ipns/mysite/**' lists the site and everything it links to, and
+'/ipfs/*/docs/*.html' the HTML files in the docs directory of every
+recursive pin. Each object matched is listed with its path, its pin type
+and the recursive pins keeping it, as with --explain. The objects matched
+which aren't pinned are listed as "unpinned" rather than failing the
+command, or left out when --type is not "all". Quote the globs for the shell
+not to expand them.
+
+The pins are listed by CID. Use --sort to list them by "type", by "size"
+(implies --size) or by creation "time" (implies --info) instead, in
+increasing order, the ties by CID. The pins with no creation time come
+last. Use --offset and --limit to list a page of the
+sorted pins: --offset=100 --limit=50 lists the 101st to the 150th. With the
+JSON encoding, the Order field lists the CIDs of the page in order, when
+--sort, --offset or --limit is used.
+
+Listing all the pins waits for the dags of the recursive pins to be walked.
+Use --stream to write the pins as they are found instead, each in its own
+RefKeyList with the JSON encoding. The recursive pins come first, then the
+indirect and the direct ones. --stream doesn't sort the pins, and can't be
+used with --sort, --offset and --limit.
+
 Example:
 	$ echo "hello" | ipfs add -q
 	QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN
@@ -272,6 +613,21 @@ Example:
 	Options: []cmds.Option{
 		cmds.StringOption("type", "t", "The type of pinned keys to list. Can be \"direct\", \"indirect\", \"recursive\", or \"all\".").Default("all"),
 		cmds.BoolOption("quiet", "q", "Write just hashes of objects.").Default(false),
+		cmds.BoolOption("info", "Write who created each pin and when.").Default(false),
+		cmds.BoolOption("provenance", "Write the path each pin was created with.").Default(false),
+		cmds.StringOption("name", "List only the direct and recursive pins whose name contains this."),
+		cmds.StringOption("meta", "List only the direct and recursive pins with this comma separated key=value metadata. A key alone matches any value."),
+		cmds.StringOption("before", "List only the direct and recursive pins created before this time, date or duration ago. Implies --info."),
+		cmds.StringOption("after", "List only the direct and recursive pins created at or after this time, date or duration ago. Implies --info."),
+		cmds.IntOption("cid-version", "List only the pins whose CIDs have this version."),
+		cmds.StringOption("codec", "List only the pins whose CIDs have this codec: \"dag-pb\", \"dag-cbor\" or \"raw\"."),
+		cmds.StringOption("prefix", "List only the pins whose CIDs start with this."),
+		cmds.BoolOption("stream", "s", "Write the pins as they are found.").Default(false),
+		cmds.BoolOption("size", "Write the size of the recursive and direct pins.").Default(false),
+		cmds.BoolOption("explain", "Write all the recursive pins keeping the given objects pinned.").Default(false),
+		cmds.StringOption("sort", "Sort the pins by \"cid\", \"type\", \"size\" or \"time\". Default: cid."),
+		cmds.IntOption("offset", "Skip this many pins of the sorted pins."),
+		cmds.IntOption("limit", "List at most this many pins of the sorted pins."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -294,19 +650,141 @@ Example:
 			return
 		}
 
+		filter, err := newPinLsFilter(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		page, err := newPinLsPage(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		info, _, _ := req.Option("info").Bool()
+		info = info || filter.byTime() || page.sortBy == "time"
+		withSize, _, _ := req.Option("size").Bool()
+		withSize = withSize || page.sortBy == "size"
+		provenance, _, _ := req.Option("provenance").Bool()
+
+		explain, _, _ := req.Option("explain").Bool()
+		if explain && len(req.Arguments()) == 0 {
+			res.SetError(errors.New("--explain needs the objects to explain"), cmds.ErrClient)
+			return
+		}
+
+		if stream, _, _ := req.Option("stream").Bool(); stream {
+			if page.set {
+				res.SetError(errors.New("--stream can't be used with --sort, --offset and --limit"), cmds.ErrClient)
+				return
+			}
+
+			outChan := make(chan interface{})
+			res.SetOutput((<-chan interface{})(outChan))
+
+			go func() {
+				defer close(outChan)
+
+				emitObject := func(c *cid.Cid, v RefKeyObject) error {
+					v, err := withPinInfo(n, c, v, info, provenance)
+					if err != nil {
+						return err
+					}
+					if !filter.match(c, v) {
+						return nil
+					}
+					if withSize {
+						if v, err = withPinSize(req.Context(), n, c, v); err != nil {
+							return err
+						}
+					}
+					select {
+					case outChan <- &RefKeyList{Keys: map[string]RefKeyObject{c.String(): v}}:
+						return nil
+					case <-req.Context().Done():
+						return req.Context().Err()
+					}
+				}
+				emit := func(c *cid.Cid, typ string) error {
+					return emitObject(c, RefKeyObject{Type: typ})
+				}
+
+				var err error
+				if len(req.Arguments()) > 0 {
+					var keys map[string]RefKeyObject
+					keys, err = pinLsKeys(req.Arguments(), typeStr, explain, req.Context(), n)
+					for k, v := range keys {
+						c, _ := cid.Decode(k)
+						if err = emitObject(c, v); err != nil {
+							break
+						}
+					}
+				} else {
+					err = pinLsWalk(typeStr, req.Context(), n, emit)
+				}
+				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
+				}
+			}()
+			return
+		}
+
 		var keys map[string]RefKeyObject
 
 		if len(req.Arguments()) > 0 {
-			keys, err = pinLsKeys(req.Arguments(), typeStr, req.Context(), n)
+			keys, err = pinLsKeys(req.Arguments(), typeStr, explain, req.Context(), n)
 		} else {
 			keys, err = pinLsAll(typeStr, req.Context(), n)
 		}
 
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
-		} else {
-			res.SetOutput(&RefKeyList{Keys: keys})
+			return
+		}
+
+		if err := addPinInfo(n, keys, info, provenance); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		for k, v := range keys {
+			c, err := cid.Decode(k)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if !filter.match(c, v) {
+				delete(keys, k)
+			}
+		}
+
+		// the sizes are needed to sort by size, else only the ones of the
+		// page listed are
+		var order []string
+		if page.set && page.sortBy != "size" {
+			order = page.apply(keys)
+		}
+
+		if withSize {
+			for k, v := range keys {
+				c, err := cid.Decode(k)
+				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+				if keys[k], err = withPinSize(req.Context(), n, c, v); err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+			}
 		}
+
+		if page.sortBy == "size" {
+			order = page.apply(keys)
+		}
+
+		res.SetOutput(&RefKeyList{Keys: keys, Order: order})
 	},
 	Type: RefKeyList{},
 	Marshalers: cmds.MarshalerMap{
@@ -315,20 +793,69 @@ Example:
 			if err != nil {
 				return nil, err
 			}
+			withSize, _, _ := res.Request().Option("size").Bool()
 
-			keys, ok := res.Output().(*RefKeyList)
-			if !ok {
-				return nil, u.ErrCast()
-			}
-			out := new(bytes.Buffer)
-			for k, v := range keys.Keys {
-				if quiet {
-					fmt.Fprintf(out, "%s\n", k)
-				} else {
-					fmt.Fprintf(out, "%s %s\n", k, v.Type)
+			marshal := func(v interface{}) (io.Reader, error) {
+				keys, ok := v.(*RefKeyList)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+				order := keys.Order
+				if order == nil {
+					for k := range keys.Keys {
+						order = append(order, k)
+					}
+					sort.Strings(order)
+				}
+
+				out := new(bytes.Buffer)
+				for _, k := range order {
+					v := keys.Keys[k]
+					if quiet {
+						fmt.Fprintf(out, "%s\n", k)
+						continue
+					}
+
+					rest := new(bytes.Buffer)
+					if withSize && (v.Type == "recursive" || v.Type == "direct") {
+						fmt.Fprintf(rest, " %d", v.Size)
+					}
+					if v.Created != "" {
+						fmt.Fprintf(rest, " %s %s", v.Created, v.Requester)
+					}
+					if v.Source != "" {
+						fmt.Fprintf(rest, " from %s", v.Source)
+					}
+					if v.Name != "" {
+						fmt.Fprintf(rest, " %s", v.Name)
+					}
+					if len(v.PinnedBy) > 0 {
+						if v.Type != "indirect" {
+							fmt.Fprint(rest, ", indirect")
+						}
+						fmt.Fprintf(rest, " through %s", strings.Join(v.PinnedBy, " "))
+					}
+
+					if len(v.Paths) == 0 {
+						fmt.Fprintf(out, "%s %s%s\n", k, v.Type, rest)
+						continue
+					}
+					// a line for each path matched by the globs
+					for _, p := range v.Paths {
+						fmt.Fprintf(out, "%s %s %s%s\n", k, v.Type, p, rest)
+					}
 				}
+				return out, nil
+			}
+
+			if outChan, ok := res.Output().(<-chan interface{}); ok {
+				return &cmds.ChannelMarshaler{
+					Channel:   outChan,
+					Marshaler: marshal,
+					Res:       res,
+				}, nil
 			}
-			return out, nil
+			return marshal(res.Output())
 		},
 	},
 }
@@ -339,7 +866,13 @@ var updatePinCmd = &cmds.Command{
 		ShortDescription: `
 Updates one pin to another, making sure that all objects in the new pin are
 local.  Then removes the old pin. This is an optimized version of adding the
-new pin and removing the old one.
+new pin and removing the old one. The new pin keeps the name and metadata of
+the old one.
+
+Only the blocks of the new dag which differ from the old one are fetched: the
+subdags linked under the same name in both are compared, and the ones with
+the same CID are skipped, as they're already pinned. Updating a large dataset
+which changes slowly only fetches the changes.
 `,
 	},
 
@@ -350,7 +883,8 @@ new pin and removing the old one.
 	Options: []cmds.Option{
 		cmds.BoolOption("unpin", "Remove the old pin.").Default(true),
 	},
-	Type: PinOutput{},
+	Type:    PinOutput{},
+	Mutates: cmds.AlwaysMutates,
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -358,6 +892,9 @@ new pin and removing the old one.
 			return
 		}
 
+		// keep gc from removing the fetched blocks before they're pinned
+		defer n.Blockstore.PinLock().Unlock()
+
 		unpin, _, err := req.Option("unpin").Bool()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -388,12 +925,40 @@ new pin and removing the old one.
 			return
 		}
 
-		err = n.Pinning.Update(req.Context(), fromc, toc, unpin)
+		fromInfo, err := corerepo.GetPinInfo(n.Repo, fromc)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if fromInfo == nil {
+			fromInfo = new(corerepo.PinInfo)
+		}
+
+		// the blocks of the new version likely come from the same peers
+		ctx := exchange.WithSession(exchange.WithClass(req.Context(), exchange.Background))
+		ctx = n.PinProviders.Track(ctx)
+		err = n.Pinning.Update(ctx, fromc, toc, unpin)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
+		if err := n.Pinning.Flush(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := recordPinInfo(n, req, []*cid.Cid{toc}, []string{to.String()}, fromInfo.Name, fromInfo.Meta); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if unpin && !fromc.Equals(toc) {
+			if err := corerepo.RemovePinInfo(n.Repo, fromc); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
 		res.SetOutput(&PinOutput{Pins: []string{from.String(), to.String()}})
 	},
 	Marshalers: cmds.MarshalerMap{
@@ -410,24 +975,533 @@ new pin and removing the old one.
 	},
 }
 
-type RefKeyObject struct {
-	Type string
-}
-
-type RefKeyList struct {
-	Keys map[string]RefKeyObject
-}
+var verifyPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Verify that recursive pins are complete.",
+		ShortDescription: `
+'ipfs pin verify' walks the dag of every recursive pin, and checks that all
+its blocks are stored locally and match their hashes. It prints the broken
+pins, with the missing or corrupt blocks under them. Blocks lost silently
+would otherwise only be noticed once they fail to be served.
 
-func pinLsKeys(args []string, typeStr string, ctx context.Context, n *core.IpfsNode) (map[string]RefKeyObject, error) {
+The reads are paced as set by the Datastore.MaintenanceMaxRate and
+Datastore.MaintenanceMaxOps config keys, like 'ipfs repo verify'.
 
-	mode, ok := pin.StringToPinMode(typeStr)
-	if !ok {
+With --fix, the missing and corrupt blocks of the broken pins are fetched
+again from the network, the corrupt ones deleted first, along with the dags
+under them. The blocks fixed are printed, and the pins are printed as broken
+if some of their blocks couldn't be fetched.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("verbose", "Also print the pins which are ok.").Default(false),
+		cmds.BoolOption("quiet", "q", "Only print the CIDs of the broken pins.").Default(false),
+		cmds.BoolOption("fix", "Fetch the bad blocks of the broken pins again.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		pacer, err := corerepo.NewPacer(cfg.Datastore)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		verbose, _, _ := req.Option("verbose").Bool()
+		fix, _, _ := req.Option("fix").Bool()
+		v := corerepo.NewPinVerifier(n, pacer)
+
+		recursiveKeys, err := n.Pinning.RecursiveKeys()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			for _, c := range recursiveKeys {
+				st, err := v.Verify(req.Context(), c)
+				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+				if !st.Ok && fix {
+					st, err = v.Repair(req.Context(), st)
+					if err != nil {
+						res.SetError(err, cmds.ErrNormal)
+						return
+					}
+				}
+				if st.Ok && len(st.Fixed) == 0 && !verbose {
+					continue
+				}
+				select {
+				case out <- st:
+				case <-req.Context().Done():
+					return
+				}
+			}
+		}()
+
+		res.SetOutput((<-chan interface{})(out))
+	},
+	Type: corerepo.PinStatus{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			quiet, _, _ := res.Request().Option("quiet").Bool()
+
+			marshal := func(v interface{}) (io.Reader, error) {
+				st, ok := v.(*corerepo.PinStatus)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+
+				buf := new(bytes.Buffer)
+				switch {
+				case quiet:
+					if !st.Ok {
+						fmt.Fprintln(buf, st.Cid)
+					}
+				case st.Ok && len(st.Fixed) == 0:
+					fmt.Fprintf(buf, "%s ok\n", st.Cid)
+				case st.Ok:
+					fmt.Fprintf(buf, "%s fixed\n", st.Cid)
+				default:
+					fmt.Fprintf(buf, "%s broken\n", st.Cid)
+					for _, b := range st.BadNodes {
+						fmt.Fprintf(buf, "  %s: %s\n", b.Cid, b.Err)
+					}
+				}
+				if !quiet {
+					for _, c := range st.Fixed {
+						fmt.Fprintf(buf, "  %s: fixed\n", c)
+					}
+				}
+				return buf, nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   out,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
+	},
+}
+
+var exportPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Export the pin set.",
+		ShortDescription: `
+'ipfs pin export' writes the recursive and direct pins, with their names,
+metadata and who created them and when, as a JSON document. 'ipfs pin import'
+restores them on another node:
+
+  > ipfs pin export >pins.json
+  > ipfs pin import pins.json
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		exp, err := corerepo.ExportPins(n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(exp)
+	},
+	Type: corerepo.PinExport{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			exp, ok := res.Output().(*corerepo.PinExport)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			b, err := json.MarshalIndent(exp, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(append(b, '\n')), nil
+		},
+	},
+}
+
+var importPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Import pins exported by 'ipfs pin export'.",
+		ShortDescription: `
+'ipfs pin import' pins the pins of a file written by 'ipfs pin export', with
+the same types, and restores their names, metadata and who created them and
+when. The dags of the pins are fetched if they aren't stored locally.
+
+Direct pins of objects already pinned recursively are skipped. If a pin
+fails, the ones imported before it are kept, and importing again skips them.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("file", true, false, "The file written by 'ipfs pin export'.").EnableStdin(),
+	},
+	Type:    ExportedPinOutput{},
+	Mutates: cmds.AlwaysMutates,
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		exp := new(corerepo.PinExport)
+		if err := json.NewDecoder(file).Decode(exp); err != nil {
+			res.SetError(fmt.Errorf("invalid pin export: %s", err), cmds.ErrClient)
+			return
+		}
+
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
+
+		go func() {
+			defer close(out)
+			defer n.Blockstore.PinLock().Unlock()
+
+			err := corerepo.ImportPins(n, req.Context(), exp, func(p corerepo.ExportedPin) {
+				select {
+				case out <- &ExportedPinOutput{Cid: p.Cid, Type: p.Type}:
+				case <-req.Context().Done():
+				}
+			})
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+			}
+		}()
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			marshal := func(v interface{}) (io.Reader, error) {
+				p, ok := v.(*ExportedPinOutput)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+				if p.Type == "recursive" {
+					return strings.NewReader(fmt.Sprintf("pinned %s recursively\n", p.Cid)), nil
+				}
+				return strings.NewReader(fmt.Sprintf("pinned %s directly\n", p.Cid)), nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   out,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
+	},
+}
+
+// ExportedPinOutput is a pin imported by 'ipfs pin import'.
+type ExportedPinOutput struct {
+	Cid  string
+	Type string
+}
+
+type PinInfoOutput struct {
+	Cid       string
+	Type      string
+	Requester string            `json:",omitempty"`
+	Created   string            `json:",omitempty"`
+	Name      string            `json:",omitempty"`
+	Meta      map[string]string `json:",omitempty"`
+	Source    string            `json:",omitempty"`
+}
+
+var infoPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show who created a pin and when.",
+		ShortDescription: `
+Shows the type of the pin of an object, who created it and when, in UTC, the
+path it was pinned with, and its name and metadata if it was given some.
+Requests made through the API are credited to the address they came from.
+Commands run without a daemon are credited to "local".
+
+Pins created before this was recorded have no such information.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "Path to the pinned object."),
+	},
+	Type: PinInfoOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		pth, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		c, err := core.ResolveToCid(req.Context(), n, pth)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		pinType, pinned, err := n.Pinning.IsPinnedWithType(c, pin.Any)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !pinned || (pinType != "direct" && pinType != "recursive") {
+			res.SetError(cmds.WithName(cmds.ErrNamePinNotFound, fmt.Errorf("%s is not pinned directly or recursively", c)), cmds.ErrClient)
+			return
+		}
+
+		out := &PinInfoOutput{Cid: c.String(), Type: pinType}
+
+		info, err := corerepo.GetPinInfo(n.Repo, c)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if info != nil {
+			out.Requester = info.Requester
+			out.Created = info.Created.UTC().Format(time.RFC3339)
+			out.Name = info.Name
+			out.Meta = info.Meta
+			out.Source = info.Source
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*PinInfoOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "%s %s\n", out.Cid, out.Type)
+			if out.Created == "" {
+				fmt.Fprintln(buf, "no information recorded")
+				return buf, nil
+			}
+			fmt.Fprintf(buf, "created: %s\n", out.Created)
+			fmt.Fprintf(buf, "requester: %s\n", out.Requester)
+			if out.Source != "" {
+				fmt.Fprintf(buf, "source: %s\n", out.Source)
+			}
+			if out.Name != "" {
+				fmt.Fprintf(buf, "name: %s\n", out.Name)
+			}
+			keys := make([]string, 0, len(out.Meta))
+			for k := range out.Meta {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(buf, "meta: %s=%s\n", k, out.Meta[k])
+			}
+			return buf, nil
+		},
+	},
+}
+
+var errNoPinQueue = errors.New("background pins are made by the daemon, run 'ipfs daemon' first")
+
+var statusPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the status of a background pin.",
+		ShortDescription: `
+Shows the state of a job queued by 'ipfs pin add --background': queued,
+running, retrying after a failure, done or failed. The number of attempts,
+the error of the last failed one, and the pins made are shown too.
+
+The daemon keeps the last finished jobs, and forgets them when it stops.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("job", true, false, "ID of the job."),
+	},
+	Type: core.PinJobStatus{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if n.PinJobs == nil {
+			res.SetError(errNoPinQueue, cmds.ErrClient)
+			return
+		}
+
+		id, err := strconv.ParseUint(req.Arguments()[0], 10, 64)
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid job ID %q", req.Arguments()[0]), cmds.ErrClient)
+			return
+		}
+
+		job, ok := n.PinJobs.Job(id)
+		if !ok {
+			res.SetError(fmt.Errorf("no pin job %d", id), cmds.ErrClient)
+			return
+		}
+		res.SetOutput(&job)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			job, ok := res.Output().(*core.PinJobStatus)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "job %d: %s\n", job.ID, job.State)
+			for _, p := range job.Paths {
+				fmt.Fprintf(buf, "path: %s\n", p)
+			}
+			fmt.Fprintf(buf, "recursive: %t\n", job.Recursive)
+			fmt.Fprintf(buf, "attempts: %d\n", job.Attempts)
+			if job.Progress != 0 {
+				fmt.Fprintf(buf, "fetched: %d nodes", job.Progress)
+				if job.TotalSize != 0 {
+					fmt.Fprintf(buf, ", %s of %s", humanize.Bytes(job.Bytes), humanize.Bytes(job.TotalSize))
+				}
+				fmt.Fprintln(buf)
+			}
+			if job.Error != "" {
+				fmt.Fprintf(buf, "error: %s\n", job.Error)
+			}
+			for _, p := range job.Pins {
+				fmt.Fprintf(buf, "pinned: %s\n", p)
+			}
+			fmt.Fprintf(buf, "created: %s\n", job.Created.Format(time.RFC3339))
+			fmt.Fprintf(buf, "updated: %s\n", job.Updated.Format(time.RFC3339))
+			return buf, nil
+		},
+	},
+}
+
+type PinJobList struct {
+	Jobs []core.PinJobStatus
+}
+
+var jobsPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the background pins.",
+		ShortDescription: `
+Lists the jobs queued by 'ipfs pin add --background' which are still
+queued or running, and the last finished ones, with their ID, state, number
+of attempts and paths. 'ipfs pin status' shows the details of a job.
+`,
+	},
+
+	Type: PinJobList{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if n.PinJobs == nil {
+			res.SetError(errNoPinQueue, cmds.ErrClient)
+			return
+		}
+
+		res.SetOutput(&PinJobList{Jobs: n.PinJobs.Jobs()})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list, ok := res.Output().(*PinJobList)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			for _, job := range list.Jobs {
+				fmt.Fprintf(buf, "%d %s %d %s\n", job.ID, job.State, job.Attempts, strings.Join(job.Paths, " "))
+			}
+			return buf, nil
+		},
+	},
+}
+
+type RefKeyObject struct {
+	Type      string
+	Size      uint64            `json:",omitempty"`
+	Requester string            `json:",omitempty"`
+	Created   string            `json:",omitempty"`
+	Name      string            `json:",omitempty"`
+	Meta      map[string]string `json:",omitempty"`
+	PinnedBy  []string          `json:",omitempty"`
+	// Source is the path the pin was created with, listed with
+	// --provenance.
+	Source string `json:",omitempty"`
+	// Paths are the paths matched by the globs given to 'pin ls' which
+	// resolve to the object.
+	Paths []string `json:",omitempty"`
+}
+
+type RefKeyList struct {
+	Keys map[string]RefKeyObject
+	// Order lists the keys in the order of the --sort option of 'pin ls',
+	// when it or --offset and --limit are used.
+	Order []string `json:",omitempty"`
+}
+
+func pinLsKeys(args []string, typeStr string, explain bool, ctx context.Context, n *core.IpfsNode) (map[string]RefKeyObject, error) {
+
+	mode, ok := pin.StringToPinMode(typeStr)
+	if !ok {
 		return nil, fmt.Errorf("invalid pin mode '%s'", typeStr)
 	}
 
 	keys := make(map[string]RefKeyObject)
+	// the pins keeping the objects matched by globs, shared by the globs
+	explained := make(map[string]RefKeyObject)
+
+	for _, p := range args {
+		if isPinLsGlob(p) {
+			if err := pinLsGlob(p, typeStr, mode, explained, keys, ctx, n); err != nil {
+				return nil, err
+			}
+			continue
+		}
 
-	for _, p := range args {
 		pth, err := path.ParsePath(p)
 		if err != nil {
 			return nil, err
@@ -444,7 +1518,15 @@ func pinLsKeys(args []string, typeStr string, ctx context.Context, n *core.IpfsN
 		}
 
 		if !pinned {
-			return nil, fmt.Errorf("path '%s' is not pinned", p)
+			return nil, cmds.WithName(cmds.ErrNamePinNotFound, fmt.Errorf("path '%s' is not pinned", p))
+		}
+
+		if explain {
+			keys[c.String()], err = explainPin(ctx, n, c, pinType)
+			if err != nil {
+				return nil, err
+			}
+			continue
 		}
 
 		switch pinType {
@@ -464,32 +1546,743 @@ func pinLsAll(typeStr string, ctx context.Context, n *core.IpfsNode) (map[string
 
 	keys := make(map[string]RefKeyObject)
 
-	AddToResultKeys := func(keyList []*cid.Cid, typeStr string) {
-		for _, c := range keyList {
-			keys[c.String()] = RefKeyObject{
-				Type: typeStr,
+	err := pinLsWalk(typeStr, ctx, n, func(c *cid.Cid, typ string) error {
+		keys[c.String()] = RefKeyObject{
+			Type: typ,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// pinLsWalk calls emit for each pin of the given type, once. With "all", a
+// pin is only listed with the first of the types recursive, indirect and
+// direct it has. The indirect pins are kept in a VisitedSet, which spills to
+// disk when they're many.
+func pinLsWalk(typeStr string, ctx context.Context, n *core.IpfsNode, emit func(*cid.Cid, string) error) error {
+	all := typeStr == "all"
+
+	recursiveKeys, err := n.Pinning.RecursiveKeys()
+	if err != nil {
+		return err
+	}
+	recursive := cid.NewSet()
+	for _, c := range recursiveKeys {
+		recursive.Add(c)
+	}
+
+	if typeStr == "recursive" || all {
+		for _, c := range recursive.Keys() {
+			if err := emit(c, "recursive"); err != nil {
+				return err
+			}
+		}
+	}
+
+	set := dag.NewVisitedSet(dag.DefaultVisitedInMemory)
+	defer set.Close()
+
+	if typeStr == "indirect" || all {
+		var emitErr error
+		visit := func(c *cid.Cid) bool {
+			if emitErr != nil || !set.Visit(c) {
+				return false
+			}
+			if !all || !recursive.Has(c) {
+				emitErr = emit(c, "indirect")
+			}
+			return emitErr == nil
+		}
+		for _, k := range recursive.Keys() {
+			err := dag.EnumerateChildren(ctx, n.DAG.GetLinks, k, visit)
+			if emitErr != nil {
+				return emitErr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if typeStr == "direct" || all {
+		directKeys, err := n.Pinning.DirectKeys()
+		if err != nil {
+			return err
+		}
+		for _, c := range directKeys {
+			if set.Has(c) {
+				continue
+			}
+			if err := emit(c, "direct"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// recordPinInfo records that the request created the pins of cs, with the
+// given name and metadata, and the paths of sources they were resolved from,
+// in the order of cs. Without a name, pins keep the name they had, and
+// they keep the metadata keys not given, so that adding the same content
+// again doesn't lose them. Pins created without a path, e.g. by 'ipfs add',
+// keep their source too. The pin groups which pinned cs before don't own
+// them anymore: they stay pinned once they leave the groups.
+func recordPinInfo(n *core.IpfsNode, req cmds.Request, cs []*cid.Cid, sources []string, name string, meta map[string]string) error {
+	if err := corerepo.DisownPinGroupRoots(n.Repo, cs); err != nil {
+		return err
+	}
+
+	for i, c := range cs {
+		info := corerepo.PinInfo{
+			Requester: cmds.Requester(req),
+			Created:   time.Now(),
+			Name:      name,
+		}
+		if i < len(sources) {
+			info.Source = sources[i]
+			if p, err := path.ParsePath(sources[i]); err == nil {
+				info.Source = p.String()
+			}
+		}
+
+		old, err := corerepo.GetPinInfo(n.Repo, c)
+		if err != nil {
+			return err
+		}
+		if old == nil {
+			old = new(corerepo.PinInfo)
+		}
+		// pinning again doesn't make the pin newer, nor credit it to
+		// someone else
+		if !old.Created.IsZero() {
+			info.Created = old.Created
+			info.Requester = old.Requester
+		}
+		if info.Name == "" {
+			info.Name = old.Name
+		}
+		if info.Source == "" {
+			info.Source = old.Source
+		}
+		if len(old.Meta)+len(meta) > 0 {
+			info.Meta = make(map[string]string)
+			for k, v := range old.Meta {
+				info.Meta[k] = v
+			}
+			for k, v := range meta {
+				info.Meta[k] = v
+			}
+		}
+
+		if err := corerepo.SetPinInfo(n.Repo, c, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isPinLsGlob tells whether an argument of 'pin ls' is a glob, with *, ? or
+// [ wildcards in some of its segments.
+func isPinLsGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// pinLsGlob adds to keys the objects whose paths match the glob p, with the
+// recursive pins keeping them. The segments of p are matched as path.Match
+// does, and a "**" segment matches any number of segments. The segments
+// before the first one with wildcards are resolved as a path, and the ones
+// after it are matched against the names of the links of the local dag under
+// it. A wildcard in the first segment of an /ipfs path matches the CIDs of
+// the recursive pins.
+//
+// The objects matched which aren't pinned are listed as "unpinned" when
+// typeStr is "all", and left out otherwise.
+func pinLsGlob(p, typeStr string, mode pin.PinMode, explained, keys map[string]RefKeyObject, ctx context.Context, n *core.IpfsNode) error {
+	segs := strings.Split(strings.Trim(p, "/"), "/")
+	switch segs[0] {
+	case "ipfs", "ipns":
+	default:
+		segs = append([]string{"ipfs"}, segs...)
+	}
+	if len(segs) < 2 {
+		return fmt.Errorf("invalid glob %q", p)
+	}
+	matchSeg := func(pattern, name string) (bool, error) {
+		ok, err := gopath.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid glob %q: %s", p, err)
+		}
+		return ok, nil
+	}
+
+	first := 1
+	for !isPinLsGlob(segs[first]) {
+		first++
+	}
+
+	type match struct {
+		path string
+		c    *cid.Cid
+	}
+	var bases []match
+	if first == 1 {
+		if segs[0] != "ipfs" {
+			return fmt.Errorf("invalid glob %q: the name of an /ipns path can't have wildcards", p)
+		}
+		recursive, err := n.Pinning.RecursiveKeys()
+		if err != nil {
+			return err
+		}
+		for _, c := range recursive {
+			ok, err := matchSeg(segs[1], c.String())
+			if err != nil {
+				return err
+			}
+			if ok {
+				bases = append(bases, match{"/ipfs/" + c.String(), c})
+			}
+		}
+		first = 2
+	} else {
+		base := "/" + strings.Join(segs[:first], "/")
+		pth, err := path.ParsePath(base)
+		if err != nil {
+			return err
+		}
+		c, err := core.ResolveToCid(ctx, n, pth)
+		if err != nil {
+			return err
+		}
+		bases = append(bases, match{base, c})
+	}
+
+	// the dags are walked with the local blocks only: the pinned dags are
+	// stored, and the others needn't be fetched to tell they aren't pinned
+	links := n.DAG.GetOfflineLinkService()
+	var matches []match
+	seen := make(map[string]bool)
+	var walk func(c *cid.Cid, at string, pattern []string) error
+	walk = func(c *cid.Cid, at string, pattern []string) error {
+		if len(pattern) == 0 {
+			if !seen[at] {
+				seen[at] = true
+				matches = append(matches, match{at, c})
+			}
+			return nil
+		}
+		if pattern[0] == "**" {
+			// no segment
+			if err := walk(c, at, pattern[1:]); err != nil {
+				return err
+			}
+		}
+
+		lnks, err := links.GetLinks(ctx, c)
+		switch err {
+		case nil:
+		case dag.ErrNotFound:
+			return nil
+		default:
+			return err
+		}
+		for _, l := range lnks {
+			// the links of the chunks of files have no name
+			if l.Name == "" {
+				continue
+			}
+			if pattern[0] == "**" {
+				if err := walk(l.Cid, at+"/"+l.Name, pattern); err != nil {
+					return err
+				}
+				continue
+			}
+			ok, err := matchSeg(pattern[0], l.Name)
+			if err != nil {
+				return err
 			}
+			if ok {
+				if err := walk(l.Cid, at+"/"+l.Name, pattern[1:]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, b := range bases {
+		if err := walk(b.c, b.path, segs[first:]); err != nil {
+			return err
 		}
 	}
 
-	if typeStr == "direct" || typeStr == "all" {
-		AddToResultKeys(n.Pinning.DirectKeys(), "direct")
+	// the recursive pins keeping each match are found walking their dags
+	// once for all the matches
+	var unexplained []*cid.Cid
+	for _, m := range matches {
+		if _, ok := explained[m.c.String()]; !ok {
+			unexplained = append(unexplained, m.c)
+		}
+	}
+	pinnedBy, err := n.Pinning.PinnedByEach(ctx, unexplained)
+	if err != nil {
+		return err
 	}
-	if typeStr == "indirect" || typeStr == "all" {
-		set := cid.NewSet()
-		for _, k := range n.Pinning.RecursiveKeys() {
-			err := dag.EnumerateChildren(n.Context(), n.DAG.GetLinks, k, set.Visit)
+
+	for _, m := range matches {
+		k := m.c.String()
+		v, ok := explained[k]
+		if !ok {
+			by := pinnedBy[m.c.KeyString()]
+			pinType, pinned, err := pinTypeWith(n, m.c, mode, by)
 			if err != nil {
-				return nil, err
+				return err
 			}
+			switch {
+			case pinned:
+				v = explainPinWith(pinType, by)
+			case typeStr == "all":
+				v = RefKeyObject{Type: "unpinned"}
+			default:
+				continue
+			}
+			explained[k] = v
+		}
+
+		if prev, ok := keys[k]; ok {
+			v.Paths = prev.Paths
+		}
+		v.Paths = append(v.Paths, m.path)
+		keys[k] = v
+	}
+	return nil
+}
+
+// addPinInfo fills in the names and metadata of the direct and recursive pins
+// of keys, with withInfo, who created them and when, and with withSource, the
+// paths they were created with.
+func addPinInfo(n *core.IpfsNode, keys map[string]RefKeyObject, withInfo, withSource bool) error {
+	for k, v := range keys {
+		c, err := cid.Decode(k)
+		if err != nil {
+			return err
+		}
+		keys[k], err = withPinInfo(n, c, v, withInfo, withSource)
+		if err != nil {
+			return err
 		}
-		AddToResultKeys(set.Keys(), "indirect")
 	}
-	if typeStr == "recursive" || typeStr == "all" {
-		AddToResultKeys(n.Pinning.RecursiveKeys(), "recursive")
+	return nil
+}
+
+// withPinInfo adds the name and metadata of the pin of c to v, who created
+// it and when if withInfo is set, and the path it was created with if
+// withSource is. Only direct and recursive pins have some.
+func withPinInfo(n *core.IpfsNode, c *cid.Cid, v RefKeyObject, withInfo, withSource bool) (RefKeyObject, error) {
+	if v.Type != "direct" && v.Type != "recursive" {
+		return v, nil
+	}
+
+	info, err := corerepo.GetPinInfo(n.Repo, c)
+	if err != nil || info == nil {
+		return v, err
 	}
 
-	return keys, nil
+	v.Name = info.Name
+	v.Meta = info.Meta
+	if withInfo {
+		v.Requester = info.Requester
+		v.Created = info.Created.UTC().Format(time.RFC3339)
+	}
+	if withSource {
+		v.Source = info.Source
+	}
+	return v, nil
+}
+
+// explainPin lists the recursive pins keeping c pinned, pinType being the
+// type of pin found for c.
+func explainPin(ctx context.Context, n *core.IpfsNode, c *cid.Cid, pinType string) (RefKeyObject, error) {
+	switch pinType {
+	case "direct", "recursive", "internal":
+	default:
+		// the first recursive pin found containing c
+		pinType = "indirect"
+	}
+
+	by, err := n.Pinning.PinnedBy(ctx, c)
+	if err != nil {
+		return RefKeyObject{}, err
+	}
+	return explainPinWith(pinType, by), nil
+}
+
+// explainPinWith returns the explanation of a pin of type pinType, kept by
+// the recursive pins by.
+func explainPinWith(pinType string, by []*cid.Cid) RefKeyObject {
+	v := RefKeyObject{Type: pinType}
+	for _, rc := range by {
+		v.PinnedBy = append(v.PinnedBy, rc.String())
+	}
+	sort.Strings(v.PinnedBy)
+	return v
+}
+
+// pinTypeWith is IsPinnedWithType for the explanations, knowing the recursive
+// pins by keeping c, so that their dags aren't walked again: c is pinned
+// indirectly if there are some.
+func pinTypeWith(n *core.IpfsNode, c *cid.Cid, mode pin.PinMode, by []*cid.Cid) (string, bool, error) {
+	for _, m := range []pin.PinMode{pin.Recursive, pin.Direct} {
+		if mode != m && mode != pin.Any {
+			continue
+		}
+		pinType, pinned, err := n.Pinning.IsPinnedWithType(c, m)
+		if err != nil || pinned {
+			return pinType, pinned, err
+		}
+	}
+	if (mode == pin.Indirect || mode == pin.Any) && len(by) > 0 {
+		return "indirect", true, nil
+	}
+	return "", false, nil
+}
+
+// withPinSize adds to v the size of the dag of c if it's pinned recursively,
+// or of its block if it's pinned directly.
+func withPinSize(ctx context.Context, n *core.IpfsNode, c *cid.Cid, v RefKeyObject) (RefKeyObject, error) {
+	switch v.Type {
+	case "recursive":
+		size, err := corerepo.DagSize(ctx, n, c)
+		if err != nil {
+			return v, err
+		}
+		v.Size = size
+	case "direct":
+		blk, err := n.Blockstore.Get(c)
+		switch err {
+		case nil:
+			v.Size = uint64(len(blk.RawData()))
+		case bstore.ErrNotFound:
+		default:
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+// pinLsFilter is the selection of the --name, --meta, --before and --after
+// options of 'pin ls', and of the --cid-version, --codec and --prefix ones.
+type pinLsFilter struct {
+	name    string
+	hasName bool
+	meta    map[string]string
+
+	// zero when not set
+	before time.Time
+	after  time.Time
+
+	version    uint64
+	hasVersion bool
+	codec      uint64
+	hasCodec   bool
+	prefix     string
+}
+
+// pinLsCodecs are the codecs of the --codec option of 'pin ls', with the
+// names of the --format options of 'block put' and 'dag put'.
+var pinLsCodecs = map[string]uint64{
+	"dag-pb":   cid.DagProtobuf,
+	"protobuf": cid.DagProtobuf,
+	"dag-cbor": cid.DagCBOR,
+	"cbor":     cid.DagCBOR,
+	"raw":      cid.Raw,
+}
+
+func newPinLsFilter(req cmds.Request) (*pinLsFilter, error) {
+	f := new(pinLsFilter)
+	f.name, f.hasName, _ = req.Option("name").String()
+	f.prefix, _, _ = req.Option("prefix").String()
+
+	if v, found, _ := req.Option("cid-version").Int(); found {
+		if v != 0 && v != 1 {
+			return nil, fmt.Errorf("invalid --cid-version %d, must be 0 or 1", v)
+		}
+		f.version, f.hasVersion = uint64(v), true
+	}
+	if s, found, _ := req.Option("codec").String(); found {
+		codec, ok := pinLsCodecs[s]
+		if !ok {
+			return nil, fmt.Errorf("unknown codec %q, must be one of {dag-pb, dag-cbor, raw}", s)
+		}
+		f.codec, f.hasCodec = codec, true
+	}
+
+	if s, found, _ := req.Option("meta").String(); found {
+		meta, err := parsePinMeta(s, true)
+		if err != nil {
+			return nil, err
+		}
+		f.meta = meta
+	}
+
+	now := time.Now()
+	for _, opt := range []struct {
+		name string
+		t    *time.Time
+	}{{"before", &f.before}, {"after", &f.after}} {
+		s, found, _ := req.Option(opt.name).String()
+		if !found {
+			continue
+		}
+		t, err := parsePinTime(s, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %s", opt.name, err)
+		}
+		*opt.t = t
+	}
+	return f, nil
+}
+
+// pinLsPage is the order and the page of the --sort, --offset and --limit
+// options of 'pin ls'.
+type pinLsPage struct {
+	sortBy string
+	offset int
+	limit  int // -1 when not set
+
+	// set tells whether any of the options is used
+	set bool
+}
+
+func newPinLsPage(req cmds.Request) (*pinLsPage, error) {
+	p := &pinLsPage{sortBy: "cid", limit: -1}
+
+	sortBy, sortSet, _ := req.Option("sort").String()
+	if sortSet {
+		switch sortBy {
+		case "cid", "type", "size", "time":
+		default:
+			return nil, fmt.Errorf("invalid --sort %q, must be one of {cid, type, size, time}", sortBy)
+		}
+		p.sortBy = sortBy
+	}
+
+	offset, offsetSet, err := req.Option("offset").Int()
+	if err != nil {
+		return nil, err
+	}
+	limit, limitSet, err := req.Option("limit").Int()
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || limit < 0 {
+		return nil, errors.New("--offset and --limit must not be negative")
+	}
+	p.offset = offset
+	if limitSet {
+		p.limit = limit
+	}
+
+	p.set = sortSet || offsetSet || limitSet
+	return p, nil
+}
+
+// apply sorts the keys of the pins, and removes the ones out of the page from
+// keys. It returns the keys of the page, in order.
+func (p *pinLsPage) apply(keys map[string]RefKeyObject) []string {
+	order := make([]string, 0, len(keys))
+	for k := range keys {
+		order = append(order, k)
+	}
+	sort.Sort(pinKeysBy{order, pinLsLess(keys, p.sortBy)})
+
+	start := p.offset
+	if start > len(order) {
+		start = len(order)
+	}
+	end := len(order)
+	if p.limit >= 0 && start+p.limit < end {
+		end = start + p.limit
+	}
+	for _, k := range order[:start] {
+		delete(keys, k)
+	}
+	for _, k := range order[end:] {
+		delete(keys, k)
+	}
+	return order[start:end]
+}
+
+// pinLsLess compares the pins of keys by sortBy, and by CID when they're
+// equal.
+func pinLsLess(keys map[string]RefKeyObject, sortBy string) func(a, b string) bool {
+	switch sortBy {
+	case "type":
+		return func(a, b string) bool {
+			if ta, tb := keys[a].Type, keys[b].Type; ta != tb {
+				return ta < tb
+			}
+			return a < b
+		}
+	case "size":
+		return func(a, b string) bool {
+			if sa, sb := keys[a].Size, keys[b].Size; sa != sb {
+				return sa < sb
+			}
+			return a < b
+		}
+	case "time":
+		created := make(map[string]time.Time, len(keys))
+		for k, v := range keys {
+			if t, err := time.Parse(time.RFC3339, v.Created); err == nil {
+				created[k] = t
+			}
+		}
+		return func(a, b string) bool {
+			ta, oka := created[a]
+			tb, okb := created[b]
+			switch {
+			case oka != okb:
+				return oka
+			case !ta.Equal(tb):
+				return ta.Before(tb)
+			}
+			return a < b
+		}
+	}
+	return func(a, b string) bool { return a < b }
+}
+
+type pinKeysBy struct {
+	keys []string
+	less func(a, b string) bool
+}
+
+func (s pinKeysBy) Len() int           { return len(s.keys) }
+func (s pinKeysBy) Swap(i, j int)      { s.keys[i], s.keys[j] = s.keys[j], s.keys[i] }
+func (s pinKeysBy) Less(i, j int) bool { return s.less(s.keys[i], s.keys[j]) }
+
+// byTime tells whether the filter selects pins by creation time, which needs
+// the pin info.
+func (f *pinLsFilter) byTime() bool {
+	return !f.before.IsZero() || !f.after.IsZero()
+}
+
+func (f *pinLsFilter) match(c *cid.Cid, v RefKeyObject) bool {
+	if f.hasVersion && c.Version() != f.version {
+		return false
+	}
+	if f.hasCodec && c.Type() != f.codec {
+		return false
+	}
+	if f.prefix != "" && !strings.HasPrefix(c.String(), f.prefix) {
+		return false
+	}
+	if f.hasName && (v.Name == "" || !strings.Contains(v.Name, f.name)) {
+		return false
+	}
+	if f.byTime() {
+		created, err := time.Parse(time.RFC3339, v.Created)
+		if err != nil {
+			return false
+		}
+		if !f.before.IsZero() && !created.Before(f.before) {
+			return false
+		}
+		if !f.after.IsZero() && created.Before(f.after) {
+			return false
+		}
+	}
+	return f.meta == nil || matchPinMeta(v.Meta, f.meta)
+}
+
+// parsePinTime parses the time of the --before and --after options of 'pin
+// ls': an RFC 3339 time, a date, or a duration before now, in the units of
+// time.ParseDuration or in days.
+func parsePinTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseUint(strings.TrimSuffix(s, "d"), 10, 16)
+		if err == nil {
+			return now.AddDate(0, 0, -int(days)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("%q is not a time, a date or a duration", s)
+	}
+	return now.Add(-d), nil
+}
+
+func pinProgress(v *dag.ProgressTracker) *AddPinOutput {
+	fetched, total := v.Bytes()
+	return &AddPinOutput{Progress: v.Value(), Bytes: fetched, TotalBytes: total}
+}
+
+// parsePinFrom parses the comma separated peer IDs and addresses of --from.
+func parsePinFrom(s string) ([]pstore.PeerInfo, error) {
+	var pis []pstore.PeerInfo
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "/") {
+			a, err := iaddr.ParseString(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid peer address %s: %s", p, err)
+			}
+			pis = append(pis, pstore.PeerInfo{ID: a.ID(), Addrs: []ma.Multiaddr{a.Transport()}})
+			continue
+		}
+
+		id, err := peer.IDB58Decode(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer ID %s: %s", p, err)
+		}
+		pis = append(pis, pstore.PeerInfo{ID: id})
+	}
+	return pis, nil
+}
+
+// pinFetchFrom connects to the peers of --from, and returns a context making
+// the exchange ask them for the blocks first, or only them. When the peers
+// are only asked first, the ones which can't be reached are left to the
+// exchange, which asks the other peers when they don't answer.
+func pinFetchFrom(ctx context.Context, n *core.IpfsNode, from []pstore.PeerInfo, only bool) (context.Context, error) {
+	var ids []peer.ID
+	var errs []string
+	for _, pi := range from {
+		if err := n.PeerHost.Connect(ctx, pi); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", pi.ID.Pretty(), err))
+			continue
+		}
+		ids = append(ids, pi.ID)
+	}
+	if len(ids) == 0 && only {
+		return nil, fmt.Errorf("could not connect to the --from peers: %s", strings.Join(errs, ", "))
+	}
+	if len(ids) == 0 {
+		return ctx, nil
+	}
+	return exchange.WithPeerHints(ctx, ids, only), nil
+}
+
+// pinFromConnected tells whether some of the peers of --from are connected.
+func pinFromConnected(n *core.IpfsNode, from []pstore.PeerInfo) bool {
+	for _, pi := range from {
+		if n.PeerHost.Network().Connectedness(pi.ID) == inet.Connected {
+			return true
+		}
+	}
+	return false
 }
 
 func cidsToStrings(cs []*cid.Cid) []string {
@@ -499,3 +2292,40 @@ func cidsToStrings(cs []*cid.Cid) []string {
 	}
 	return out
 }
+
+// parsePinMeta parses comma separated key=value metadata. With keysOnly, keys
+// may come without a value, to match any value.
+func parsePinMeta(s string, keysOnly bool) (map[string]string, error) {
+	meta := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		k := strings.TrimSpace(parts[0])
+		if k == "" || strings.ContainsAny(k, " \t\r\n") {
+			return nil, fmt.Errorf("invalid metadata key %q", parts[0])
+		}
+		switch {
+		case len(parts) == 2:
+			if strings.ContainsAny(parts[1], "\r\n") {
+				return nil, fmt.Errorf("metadata values can't contain line breaks")
+			}
+			meta[k] = parts[1]
+		case keysOnly:
+			meta[k] = ""
+		default:
+			return nil, fmt.Errorf("metadata %q is not a key=value pair", kv)
+		}
+	}
+	return meta, nil
+}
+
+// matchPinMeta tells whether meta has all the keys of filter, with the same
+// values for the ones given one.
+func matchPinMeta(meta, filter map[string]string) bool {
+	for k, want := range filter {
+		v, ok := meta[k]
+		if !ok || (want != "" && v != want) {
+			return false
+		}
+	}
+	return true
+}
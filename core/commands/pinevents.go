@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+// PinEvent is a change of the pins, streamed by 'pin events'.
+type PinEvent struct {
+	Type string
+	Cid  string
+	Mode string
+	Time time.Time
+}
+
+var pinEventTypes = []string{pin.EventAdded, pin.EventRemoved, pin.EventFetched}
+
+var eventsPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Stream the changes of the pins.",
+		ShortDescription: `
+'ipfs pin events' streams the changes of the pins of the daemon as they
+happen, until it is stopped, so that tools can follow them without polling
+'ipfs pin ls'. The events are:
+
+  added    a pin was stored
+  removed  a pin was removed
+  fetched  the dag of a recursive pin is complete, before the pin is stored
+
+Each event has the CID and the mode of the pin, recursive or direct, and
+the time it happened. Pinning a direct pin recursively removes the direct
+pin and adds the recursive one. --type only streams the events of the given
+types, comma separated.
+
+Only the events following the subscription are streamed. A subscriber which
+can't keep up with the events is disconnected with an error, rather than
+losing some: it has to list the pins again before it subscribes again.
+
+Over the HTTP API, /api/v0/pin/events streams the events as JSON objects.
+`,
+	},
+
+	Options: []cmds.Option{
+		cmds.StringOption("type", "t", "Comma separated types of the events to stream: added, removed, fetched.").Default(""),
+	},
+	Type: PinEvent{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		typeStr, _, _ := req.Option("type").String()
+		types, err := parsePinEventTypes(typeStr)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		ctx := req.Context()
+		events := n.Pinning.Events().Subscribe(ctx)
+
+		outChan := make(chan interface{})
+		res.SetOutput((<-chan interface{})(outChan))
+
+		go func() {
+			defer close(outChan)
+
+			for ev := range events {
+				if !types[ev.Type] {
+					continue
+				}
+
+				out := &PinEvent{Type: ev.Type, Cid: ev.Cid.String(), Mode: ev.Mode, Time: ev.Time}
+				select {
+				case outChan <- out:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if ctx.Err() == nil {
+				res.SetError(errors.New("fell behind the pin events"), cmds.ErrNormal)
+			}
+		}()
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			marshal := func(v interface{}) (io.Reader, error) {
+				ev, ok := v.(*PinEvent)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+				return strings.NewReader(fmt.Sprintf("%s %s %s %s\n", ev.Time.Format(time.RFC3339), ev.Type, ev.Mode, ev.Cid)), nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
+	},
+}
+
+// parsePinEventTypes parses the --type option of 'pin events'. All the types
+// are streamed if s is empty.
+func parsePinEventTypes(s string) (map[string]bool, error) {
+	types := make(map[string]bool)
+	if s == "" {
+		for _, t := range pinEventTypes {
+			types[t] = true
+		}
+		return types, nil
+	}
+
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		valid := false
+		for _, vt := range pinEventTypes {
+			valid = valid || t == vt
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid pin event type %q, expected one of %s", t, strings.Join(pinEventTypes, ", "))
+		}
+		types[t] = true
+	}
+	return types, nil
+}
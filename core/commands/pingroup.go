@@ -0,0 +1,231 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+type PinGroupsOutput struct {
+	Groups []*corerepo.PinGroup
+}
+
+var groupPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage named groups of pins, replaced at once.",
+		ShortDescription: `
+A pin group is a named set of roots, pinned recursively, whose contents are
+replaced at once: e.g. the current version of a website, deployed with
+'ipfs pin group set website <new version>' and rolled back by setting the
+previous version again.
+
+Setting the roots of a group fetches all of them before anything changes,
+then pins the new roots and unpins the roots the group pinned which left it,
+in one step: the group is never left half updated. The roots which were
+pinned before joining the group, the ones pinned by hand since, and the ones
+other groups have, stay pinned.
+
+The roots a group pins get the metadata group=<name>, see 'ipfs pin ls
+--meta'.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"set": setGroupPinCmd,
+		"ls":  lsGroupPinCmd,
+		"rm":  rmGroupPinCmd,
+	},
+}
+
+var setGroupPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Replace the roots of a pin group.",
+		ShortDescription: `
+'ipfs pin group set' replaces the roots of a pin group by the given ones,
+creating the group if needed. The new roots are fetched and pinned
+recursively, and the roots the group pinned which are no longer in it are
+unpinned, unless another group has them.
+
+Example:
+
+  > ipfs pin group set website QmNewVersion
+  added QmNewVersion
+  removed QmOldVersion
+  unpinned QmOldVersion
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "The name of the group."),
+		cmds.StringArg("ipfs-path", true, true, "Paths to the roots of the group.").EnableStdin(),
+	},
+	Type:    corerepo.PinGroupChange{},
+	Mutates: cmds.AlwaysMutates,
+	Run: func(req cmds.Request, res cmds.Response) {
+		setPinGroup(req, res, req.Arguments()[0], req.Arguments()[1:])
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: pinGroupChangeMarshaler,
+	},
+}
+
+var rmGroupPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove a pin group.",
+		ShortDescription: `
+'ipfs pin group rm' removes a pin group, and unpins the roots it pinned,
+unless another group has them.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "The name of the group."),
+	},
+	Type:    corerepo.PinGroupChange{},
+	Mutates: cmds.AlwaysMutates,
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		name := req.Arguments()[0]
+		g, err := corerepo.GetPinGroup(n.Repo, name)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if g == nil {
+			res.SetError(fmt.Errorf("no pin group named %s", name), cmds.ErrClient)
+			return
+		}
+
+		setPinGroup(req, res, name, nil)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: pinGroupChangeMarshaler,
+	},
+}
+
+func setPinGroup(req cmds.Request, res cmds.Response, name string, paths []string) {
+	n, err := req.InvocContext().GetNode()
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+	if err := corerepo.ValidatePinGroupName(name); err != nil {
+		res.SetError(err, cmds.ErrClient)
+		return
+	}
+
+	defer n.Blockstore.PinLock().Unlock()
+
+	info := corerepo.PinInfo{
+		Requester: cmds.Requester(req),
+		Created:   time.Now(),
+		Meta:      map[string]string{"group": name},
+	}
+	change, err := corerepo.SetPinGroup(n, req.Context(), name, paths, info)
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+	res.SetOutput(change)
+}
+
+func pinGroupChangeMarshaler(res cmds.Response) (io.Reader, error) {
+	change, ok := res.Output().(*corerepo.PinGroupChange)
+	if !ok {
+		return nil, u.ErrCast()
+	}
+
+	buf := new(bytes.Buffer)
+	for _, l := range []struct {
+		what string
+		cids []string
+	}{
+		{"added", change.Added},
+		{"removed", change.Removed},
+		{"pinned", change.Pinned},
+		{"unpinned", change.Unpinned},
+	} {
+		for _, c := range l.cids {
+			fmt.Fprintf(buf, "%s %s\n", l.what, c)
+		}
+	}
+	return buf, nil
+}
+
+var lsGroupPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the pin groups and their roots.",
+		ShortDescription: `
+'ipfs pin group ls' lists the pin groups, with their roots and when they were
+last set, or only the given group.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", false, false, "The name of the group to list."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("quiet", "q", "Write just the roots.").Default(false),
+	},
+	Type: PinGroupsOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := new(PinGroupsOutput)
+		if len(req.Arguments()) > 0 {
+			name := req.Arguments()[0]
+			g, err := corerepo.GetPinGroup(n.Repo, name)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if g == nil {
+				res.SetError(fmt.Errorf("no pin group named %s", name), cmds.ErrClient)
+				return
+			}
+			out.Groups = append(out.Groups, g)
+		} else {
+			out.Groups, err = corerepo.ListPinGroups(n.Repo)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*PinGroupsOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			quiet, _, _ := res.Request().Option("quiet").Bool()
+
+			buf := new(bytes.Buffer)
+			for _, g := range out.Groups {
+				if !quiet {
+					fmt.Fprintf(buf, "%s %s\n", g.Name, g.Updated.Format(time.RFC3339))
+				}
+				for _, r := range g.Roots {
+					if quiet {
+						fmt.Fprintln(buf, r)
+					} else {
+						fmt.Fprintf(buf, "  %s\n", r)
+					}
+				}
+			}
+			return buf, nil
+		},
+	},
+}
@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+var orphansPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Preview the blocks removing pins would leave to the garbage collector.",
+		ShortDescription: `
+'ipfs pin orphans' lists the local blocks of the dags of the given pins which
+would become orphans once the pins are removed, and which the next garbage
+collection would remove. The pins are left as they are.
+
+Without arguments, it lists the blocks of the repo no pin keeps, and the
+blocks of the pins which aren't stored.
+`,
+		LongDescription: `
+'ipfs pin orphans' lists the local blocks of the dags of the given pins which
+would become orphans once the pins are removed, and which the next garbage
+collection would remove. The pins are left as they are.
+
+The blocks of the dags which would stay are protected by other pins, by the
+files API ('ipfs files') and the history of its roots, or by retained roots. With --kept, they are listed
+too, with what keeps them. The dags are walked as the garbage collection
+would, with the local blocks only.
+
+--unpin-children=false previews 'ipfs pin rm --unpin-children=false', which
+keeps the children of recursive pins pinned: only the root blocks are looked
+at then.
+
+The text output lists a block per line:
+
+  orphan <cid> <size>
+  kept <cid> <size> <how> <pin or root>
+
+followed by the total size of each.
+
+Without arguments, 'ipfs pin orphans' checks the pins against the repo: it
+lists the stored blocks which are neither in the dags of the pins, nor in the
+ones of the files API or of the retained roots, and which the next garbage
+collection would remove, along with their total size. It also lists the
+blocks of the dags of the recursive pins which aren't stored, a line each:
+
+  missing <cid> <how> <pin>
+
+followed by their count, and the error the garbage collection fails with
+until they are stored again. The blocks added while it runs may be listed as
+orphans.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", false, true, "Path to the pinned object(s). All the pins if none is given."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("unpin-children", "Preview the removal of the children of recursive pins too.").Default(true),
+		cmds.BoolOption("kept", "k", "List the blocks which stay too.").Default(false),
+		cmds.BoolOption("quiet", "q", "Write just the CIDs of the orphans.").Default(false),
+	},
+	Type: corerepo.UnpinPreview{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		unpinChildren, _, _ := req.Option("unpin-children").Bool()
+		withKept, _, _ := req.Option("kept").Bool()
+
+		var preview *corerepo.UnpinPreview
+		if len(req.Arguments()) == 0 {
+			preview, err = corerepo.PinCoverage(n, req.Context(), withKept)
+		} else {
+			preview, err = corerepo.PreviewUnpin(n, req.Context(), req.Arguments(), unpinChildren)
+		}
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !withKept {
+			preview.Kept = nil
+		}
+		res.SetOutput(preview)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			preview, ok := res.Output().(*corerepo.UnpinPreview)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			quiet, _, _ := res.Request().Option("quiet").Bool()
+
+			buf := new(bytes.Buffer)
+			for _, b := range preview.Orphans {
+				if quiet {
+					fmt.Fprintln(buf, b.Cid)
+					continue
+				}
+				fmt.Fprintf(buf, "orphan %s %d\n", b.Cid, b.Size)
+			}
+			if quiet {
+				return buf, nil
+			}
+
+			for _, b := range preview.Kept {
+				fmt.Fprintf(buf, "kept %s %d %s %s\n", b.Cid, b.Size, b.How, b.KeptBy)
+			}
+			for _, b := range preview.Missing {
+				fmt.Fprintf(buf, "missing %s %s %s\n", b.Cid, b.How, b.Pin)
+			}
+			fmt.Fprintf(buf, "orphans: %d blocks, %s\n", len(preview.Orphans), humanize.Bytes(preview.OrphanBytes))
+			fmt.Fprintf(buf, "kept: %s\n", humanize.Bytes(preview.KeptBytes))
+			if len(res.Request().Arguments()) == 0 {
+				fmt.Fprintf(buf, "missing: %d blocks\n", len(preview.Missing))
+			}
+			if preview.GCError != "" {
+				fmt.Fprintf(buf, "gc fails: %s\n", preview.GCError)
+			}
+			return buf, nil
+		},
+	},
+}
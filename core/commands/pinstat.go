@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+var statPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Sum up the pins: their number, size and the largest ones.",
+		ShortDescription: `
+'ipfs pin stat' counts the recursive, direct and indirect pins, as 'ipfs pin
+ls' lists them, and tells the size of the pinned blocks stored locally, each
+block counted once. It lists the pins using the most space too, without
+sending every pin to the client.
+
+The dags are only read from the local blocks: pinned blocks which are not
+stored locally are counted as missing, and their children are unknown.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("largest", "n", "Number of the largest pins to list, 0 for none.").Default(10),
+	},
+	Type: corerepo.PinStats{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		largest, _, err := req.Option("largest").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if largest < 0 {
+			res.SetError(fmt.Errorf("--largest can't be negative"), cmds.ErrClient)
+			return
+		}
+
+		st, err := corerepo.PinStat(req.Context(), n, largest)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(st)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			st, ok := res.Output().(*corerepo.PinStats)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "recursive: %d\n", st.Recursive)
+			fmt.Fprintf(buf, "direct: %d\n", st.Direct)
+			fmt.Fprintf(buf, "indirect: %d\n", st.Indirect)
+			fmt.Fprintf(buf, "size: %s\n", humanize.Bytes(st.Size))
+			if st.Missing > 0 {
+				fmt.Fprintf(buf, "missing: %d blocks\n", st.Missing)
+			}
+			if len(st.Largest) > 0 {
+				fmt.Fprintln(buf, "largest:")
+			}
+			for _, p := range st.Largest {
+				fmt.Fprintf(buf, "  %s %s %s\n", p.Cid, p.Type, humanize.Bytes(p.Size))
+			}
+			return buf, nil
+		},
+	},
+}
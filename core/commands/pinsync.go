@@ -0,0 +1,242 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	path "github.com/ipfs/go-ipfs/path"
+	pinsync "github.com/ipfs/go-ipfs/pin/pinsync"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var errPinSyncDisabled = errors.New("pinset sync needs the experimental pubsub feature. Run daemon with --enable-pubsub-experiment, or enable it with 'ipfs features enable pubsub', to use.")
+
+var pinSyncCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Publish pinsets and mirror the pinsets of other nodes.",
+		ShortDescription: `
+A node can publish a set of root objects it pins. The nodes following it pin
+and unpin the same roots as the set changes. Updates are signed with the key
+of the publisher, and sent over pubsub.
+
+Publishers also resend their whole set every few minutes, so new followers
+catch up, and followers that missed updates are repaired.
+
+This is an experimental feature. To use, the daemon must be run with
+'--enable-pubsub-experiment'.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"publish":  pinSyncPublishCmd,
+		"follow":   pinSyncFollowCmd,
+		"unfollow": pinSyncUnfollowCmd,
+		"status":   pinSyncStatusCmd,
+	},
+}
+
+var pinSyncPublishCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Add objects to the published pinset, or remove them from it.",
+		ShortDescription: `
+Adds the given objects to the pinset published to the followers of this node,
+pinning them locally. With '--rm', removes them from the pinset, unpinning
+them if they were pinned by 'ipfs pin sync publish'.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, true, "Path to object(s) to publish.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("rm", "Remove the objects from the published pinset.").Default(false),
+	},
+	Type: PinOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := pinSyncNode(req, res)
+		if !ok {
+			return
+		}
+
+		rm, _, _ := req.Option("rm").Bool()
+
+		var cids []*cid.Cid
+		for _, p := range req.Arguments() {
+			p, err := path.ParsePath(p)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			c, err := core.ResolveToCid(req.Context(), n, p)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			cids = append(cids, c)
+		}
+
+		var err error
+		if rm {
+			err = n.PinSync.Publish(req.Context(), nil, cids)
+		} else {
+			err = n.PinSync.Publish(req.Context(), cids, nil)
+		}
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&PinOutput{Pins: cidsToStrings(cids)})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			added, ok := res.Output().(*PinOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			verb := "published"
+			if rm, _, _ := res.Request().Option("rm").Bool(); rm {
+				verb = "unpublished"
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range added.Pins {
+				fmt.Fprintf(buf, "%s %s\n", verb, k)
+			}
+			return buf, nil
+		},
+	},
+}
+
+var pinSyncFollowCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Mirror the pinset published by a peer.",
+		ShortDescription: `
+Starts pinning the objects published by the given peer, and unpinning them
+when they are removed from its pinset. Followed peers are remembered across
+restarts.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", true, false, "ID of the peer to follow."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := pinSyncNode(req, res)
+		if !ok {
+			return
+		}
+
+		p, err := peer.IDB58Decode(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		if err := n.PinSync.Follow(p); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(nil)
+	},
+}
+
+var pinSyncUnfollowCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Stop mirroring the pinset published by a peer.",
+		ShortDescription: `
+Stops following the given peer, and unpins the objects pinned for it. With
+'--keep', the objects stay pinned.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", true, false, "ID of the peer to unfollow."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("keep", "Keep the objects pinned for the peer.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := pinSyncNode(req, res)
+		if !ok {
+			return
+		}
+
+		p, err := peer.IDB58Decode(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		keep, _, _ := req.Option("keep").Bool()
+		if err := n.PinSync.Unfollow(req.Context(), p, keep); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(nil)
+	},
+}
+
+var pinSyncStatusCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the published pinset and the followed peers.",
+	},
+	Type: pinsync.Status{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, ok := pinSyncNode(req, res)
+		if !ok {
+			return
+		}
+
+		res.SetOutput(n.PinSync.Status())
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*pinsync.Status)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "publishing %d objects on %s (seq %d)\n", out.Published, out.Topic, out.Seq)
+			for _, f := range out.Following {
+				last := "never"
+				if !f.LastUpdate.IsZero() {
+					last = f.LastUpdate.Format(time.RFC3339)
+				}
+				fmt.Fprintf(buf, "following %s: %d objects, seq %d, last update %s\n", f.Peer, f.Pins, f.Seq, last)
+				if f.Error != "" {
+					fmt.Fprintf(buf, "\terror: %s\n", f.Error)
+				}
+			}
+			return buf, nil
+		},
+	},
+}
+
+// pinSyncNode returns the node if pinset sync is running on it, or sets the
+// error on the response.
+func pinSyncNode(req cmds.Request, res cmds.Response) (*core.IpfsNode, bool) {
+	n, err := req.InvocContext().GetNode()
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return nil, false
+	}
+
+	if !n.OnlineMode() {
+		res.SetError(errNotOnline, cmds.ErrClient)
+		return nil, false
+	}
+
+	if n.PinSync == nil {
+		res.SetError(errPinSyncDisabled, cmds.ErrNormal)
+		return nil, false
+	}
+	return n, true
+}
@@ -10,6 +10,8 @@ import (
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
 	path "github.com/ipfs/go-ipfs/path"
 
 	crypto "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
@@ -35,6 +37,11 @@ which is the hash of its public key.
 
 You can use the 'ipfs key' commands to list and generate more names and their respective keys.
 
+Records expire after --lifetime unless published with --validity-type=perpetual,
+and are republished by the daemon in the meantime. Each record has a higher
+sequence number than the previous one, tracked by the node; when moving a key
+to another node, move its state too with 'ipfs name state'.
+
 Examples:
 
 Publish an <ipfs-path> with your default name:
@@ -48,6 +55,19 @@ Publish an <ipfs-path> with another name, added by an 'ipfs key' command:
   > ipfs name publish --key=mykey /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
   Published to QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
 
+Publish a name letting the keys its sub-names are delegated to with
+'ipfs name delegate' publish them. Resolvers only look sub-names up under
+names published with --sub-names:
+
+  > ipfs name publish --sub-names /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  Published to QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+
+Publish a sub-name of another name, delegated to --key by its owner with
+'ipfs name delegate':
+
+  > ipfs name publish --key=blog --delegation=$(cat blog.delegation) /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  Published to QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n/blog: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+
 `,
 	},
 
@@ -57,11 +77,15 @@ Publish an <ipfs-path> with another name, added by an 'ipfs key' command:
 	Options: []cmds.Option{
 		cmds.BoolOption("resolve", "Resolve given path before publishing.").Default(true),
 		cmds.StringOption("lifetime", "t",
-			`Time duration that the record will be valid for. <<default>>
+			`Time duration that the record will be valid for. Default: Ipns.RecordLifetime or 24h.
     This accepts durations such as "300s", "1.5h" or "2h45m". Valid time units are
-    "ns", "us" (or "µs"), "ms", "s", "m", "h".`).Default("24h"),
+    "ns", "us" (or "µs"), "ms", "s", "m", "h".`),
+		cmds.StringOption("validity-type", `Validity of the record, "eol" to expire after --lifetime, or "perpetual". Default: Ipns.RecordValidityType or eol.`),
+		cmds.BoolOption("v1-compat", "Publish perpetual records so that nodes predating them accept them, as valid until the year 9999.").Default(true),
 		cmds.StringOption("ttl", "Time duration this record should be cached for (caution: experimental)."),
 		cmds.StringOption("key", "k", "Name of the key to be used, as listed by 'ipfs key list'. Default: <<default>>.").Default("self"),
+		cmds.StringOption("delegation", "Publish the sub-name of another name delegated to the key, as printed by 'ipfs name delegate'."),
+		cmds.BoolOption("sub-names", "Let the keys the sub-names of the name are delegated to publish them.").Default(false),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		log.Debug("begin publish")
@@ -95,7 +119,19 @@ Publish an <ipfs-path> with another name, added by an 'ipfs key' command:
 
 		popts.verifyExists, _, _ = req.Option("resolve").Bool()
 
-		validtime, _, _ := req.Option("lifetime").String()
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		validtime, found, _ := req.Option("lifetime").String()
+		if !found {
+			validtime = cfg.Ipns.RecordLifetime
+		}
+		if validtime == "" {
+			validtime = "24h"
+		}
 		d, err := time.ParseDuration(validtime)
 		if err != nil {
 			res.SetError(fmt.Errorf("error parsing lifetime option: %s", err), cmds.ErrNormal)
@@ -104,6 +140,33 @@ Publish an <ipfs-path> with another name, added by an 'ipfs key' command:
 
 		popts.pubValidTime = d
 
+		vtype, found, _ := req.Option("validity-type").String()
+		if !found {
+			vtype = cfg.Ipns.RecordValidityType
+		}
+		switch vtype {
+		case "", "eol":
+		case "perpetual":
+			popts.perpetual = true
+		default:
+			res.SetError(fmt.Errorf("unknown validity type %q, must be \"eol\" or \"perpetual\"", vtype), cmds.ErrClient)
+			return
+		}
+		popts.v1Compat, _, _ = req.Option("v1-compat").Bool()
+
+		if dstr, found, _ := req.Option("delegation").String(); found {
+			popts.delegation, err = decodeDelegation(dstr)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+		}
+		popts.subNames, _, _ = req.Option("sub-names").Bool()
+		if popts.subNames && popts.delegation != nil {
+			res.SetError(errors.New("sub-names can't have sub-names of their own"), cmds.ErrClient)
+			return
+		}
+
 		ctx := req.Context()
 		if ttl, found, _ := req.Option("ttl").String(); found {
 			d, err := time.ParseDuration(ttl)
@@ -148,6 +211,17 @@ Publish an <ipfs-path> with another name, added by an 'ipfs key' command:
 type publishOpts struct {
 	verifyExists bool
 	pubValidTime time.Duration
+
+	// perpetual records don't expire. With v1Compat, they are published as
+	// records expiring in the far future instead, which older nodes accept.
+	perpetual bool
+	v1Compat  bool
+
+	// delegation of the sub-name to publish, if any
+	delegation *pb.IpnsDelegation
+
+	// subNames lets the delegates of the sub-names of the name publish them
+	subNames bool
 }
 
 func publish(ctx context.Context, n *core.IpfsNode, k crypto.PrivKey, ref path.Path, opts *publishOpts) (*IpnsEntry, error) {
@@ -161,6 +235,20 @@ func publish(ctx context.Context, n *core.IpfsNode, k crypto.PrivKey, ref path.P
 	}
 
 	eol := time.Now().Add(opts.pubValidTime)
+	if opts.perpetual {
+		eol = namesys.PerpetualEOL
+		if !opts.v1Compat {
+			ctx = context.WithValue(ctx, "ipns-publish-perpetual", true)
+		}
+	}
+
+	if opts.delegation != nil {
+		return publishDelegated(ctx, n, k, ref, eol, opts.delegation)
+	}
+	if opts.subNames {
+		ctx = context.WithValue(ctx, "ipns-publish-subnames", true)
+	}
+
 	err := n.Namesys.PublishWithEOL(ctx, k, ref, eol)
 	if err != nil {
 		return nil, err
@@ -176,3 +264,23 @@ func publish(ctx context.Context, n *core.IpfsNode, k crypto.PrivKey, ref path.P
 		Value: ref.String(),
 	}, nil
 }
+
+func publishDelegated(ctx context.Context, n *core.IpfsNode, k crypto.PrivKey, ref path.Path, eol time.Time, d *pb.IpnsDelegation) (*IpnsEntry, error) {
+	pub, ok := n.Namesys.(namesys.DelegatedPublisher)
+	if !ok {
+		return nil, errors.New("the name system can't publish sub-names")
+	}
+	if err := pub.PublishDelegated(ctx, k, d, ref, eol); err != nil {
+		return nil, err
+	}
+
+	parent, err := namesys.DelegationParent(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IpnsEntry{
+		Name:  parent.Pretty() + "/" + d.GetName(),
+		Value: ref.String(),
+	}, nil
+}
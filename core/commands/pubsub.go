@@ -30,7 +30,8 @@ subscribe to new messages on a given topic.
 This is an experimental feature. It is not intended in its current state
 to be used in a production environment.
 
-To use, the daemon must be run with '--enable-pubsub-experiment'.
+To use, the daemon must be run with '--enable-pubsub-experiment', or the
+pubsub feature enabled with 'ipfs features enable pubsub'.
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
@@ -50,7 +51,8 @@ ipfs pubsub sub subscribes to messages on a given topic.
 This is an experimental feature. It is not intended in its current state
 to be used in a production environment.
 
-To use, the daemon must be run with '--enable-pubsub-experiment'.
+To use, the daemon must be run with '--enable-pubsub-experiment', or the
+pubsub feature enabled with 'ipfs features enable pubsub'.
 `,
 		LongDescription: `
 ipfs pubsub sub subscribes to messages on a given topic.
@@ -58,7 +60,8 @@ ipfs pubsub sub subscribes to messages on a given topic.
 This is an experimental feature. It is not intended in its current state
 to be used in a production environment.
 
-To use, the daemon must be run with '--enable-pubsub-experiment'.
+To use, the daemon must be run with '--enable-pubsub-experiment', or the
+pubsub feature enabled with 'ipfs features enable pubsub'.
 
 This command outputs data in the following encodings:
   * "json"
@@ -85,7 +88,7 @@ This command outputs data in the following encodings:
 		}
 
 		if n.Floodsub == nil {
-			res.SetError(fmt.Errorf("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment to use."), cmds.ErrNormal)
+			res.SetError(fmt.Errorf("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment, or enable it with 'ipfs features enable pubsub', to use."), cmds.ErrNormal)
 			return
 		}
 
@@ -210,7 +213,8 @@ ipfs pubsub pub publishes a message to a specified topic.
 This is an experimental feature. It is not intended in its current state
 to be used in a production environment.
 
-To use, the daemon must be run with '--enable-pubsub-experiment'.
+To use, the daemon must be run with '--enable-pubsub-experiment', or the
+pubsub feature enabled with 'ipfs features enable pubsub'.
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -231,7 +235,7 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 		}
 
 		if n.Floodsub == nil {
-			res.SetError(fmt.Errorf("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment to use."), cmds.ErrNormal)
+			res.SetError(fmt.Errorf("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment, or enable it with 'ipfs features enable pubsub', to use."), cmds.ErrNormal)
 			return
 		}
 
@@ -255,7 +259,8 @@ ipfs pubsub ls lists out the names of topics you are currently subscribed to.
 This is an experimental feature. It is not intended in its current state
 to be used in a production environment.
 
-To use, the daemon must be run with '--enable-pubsub-experiment'.
+To use, the daemon must be run with '--enable-pubsub-experiment', or the
+pubsub feature enabled with 'ipfs features enable pubsub'.
 `,
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
@@ -272,7 +277,7 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 		}
 
 		if n.Floodsub == nil {
-			res.SetError(fmt.Errorf("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment to use."), cmds.ErrNormal)
+			res.SetError(fmt.Errorf("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment, or enable it with 'ipfs features enable pubsub', to use."), cmds.ErrNormal)
 			return
 		}
 
@@ -295,7 +300,8 @@ peers who are subscribed to the named topic.
 This is an experimental feature. It is not intended in its current state
 to be used in a production environment.
 
-To use, the daemon must be run with '--enable-pubsub-experiment'.
+To use, the daemon must be run with '--enable-pubsub-experiment', or the
+pubsub feature enabled with 'ipfs features enable pubsub'.
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -315,7 +321,7 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 		}
 
 		if n.Floodsub == nil {
-			res.SetError(fmt.Errorf("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment to use."), cmds.ErrNormal)
+			res.SetError(fmt.Errorf("experimental pubsub feature not enabled. Run daemon with --enable-pubsub-experiment, or enable it with 'ipfs features enable pubsub', to use."), cmds.ErrNormal)
 			return
 		}
 
@@ -42,6 +42,19 @@ with the following format:
   <link base58 hash>
 
 NOTE: List all references recursively by using the flag '-r'.
+`,
+		LongDescription: `
+Lists the hashes of all the links an IPFS or IPNS object(s) contains,
+with the following format:
+
+  <link base58 hash>
+
+NOTE: List all references recursively by using the flag '-r', or down to a
+given number of levels with '--max-depth'.
+
+With '--unique', the hashes already listed are kept in memory up to about a
+million of them, and in a temporary database on disk past that, so that large
+dags can be listed without running out of memory.
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
@@ -55,6 +68,7 @@ NOTE: List all references recursively by using the flag '-r'.
 		cmds.BoolOption("edges", "e", "Emit edge format: `<from> -> <to>`.").Default(false),
 		cmds.BoolOption("unique", "u", "Omit duplicate refs from output.").Default(false),
 		cmds.BoolOption("recursive", "r", "Recursively list links of child nodes.").Default(false),
+		cmds.IntOption("max-depth", "Only list links up to this many levels below the objects, -1 for no limit. Implies -r.").Default(-1),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		ctx := req.Context()
@@ -76,6 +90,15 @@ NOTE: List all references recursively by using the flag '-r'.
 			return
 		}
 
+		maxDepth, _, err := req.Option("max-depth").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if maxDepth >= 0 {
+			recursive = true
+		}
+
 		format, _, err := req.Option("format").String()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -116,7 +139,9 @@ NOTE: List all references recursively by using the flag '-r'.
 				Unique:    unique,
 				PrintFmt:  format,
 				Recursive: recursive,
+				MaxDepth:  maxDepth,
 			}
+			defer rw.Close()
 
 			for _, o := range objs {
 				if _, err := rw.WriteRefs(o); err != nil {
@@ -227,29 +252,47 @@ type RefWriter struct {
 	Recursive bool
 	PrintFmt  string
 
-	seen *cid.Set
+	// MaxDepth limits how many levels of links below the objects are listed
+	// when recursive, no limit if negative.
+	MaxDepth int
+
+	// seen holds the cids written so far when Unique is set. It goes to disk
+	// when too large, so that listing huge dags doesn't run out of memory.
+	seen *dag.VisitedSet
 }
 
 // WriteRefs writes refs of the given object to the underlying writer.
 func (rw *RefWriter) WriteRefs(n node.Node) (int, error) {
 	if rw.Recursive {
-		return rw.writeRefsRecursive(n)
+		return rw.writeRefsRecursive(n, 0)
 	}
 	return rw.writeRefsSingle(n)
 }
 
-func (rw *RefWriter) writeRefsRecursive(n node.Node) (int, error) {
+// Close frees the set of cids seen.
+func (rw *RefWriter) Close() error {
+	if rw.seen == nil {
+		return nil
+	}
+	return rw.seen.Close()
+}
+
+func (rw *RefWriter) writeRefsRecursive(n node.Node, depth int) (int, error) {
 	nc := n.Cid()
 
 	var count int
 	for i, ng := range dag.GetDAG(rw.Ctx, rw.DAG, n) {
 		lc := n.Links()[i].Cid
-		if rw.skip(lc) {
-			continue
-		}
+		write, recurse := rw.visit(lc, depth+1)
 
-		if err := rw.WriteEdge(nc, lc, n.Links()[i].Name); err != nil {
-			return count, err
+		if write {
+			if err := rw.WriteEdge(nc, lc, n.Links()[i].Name); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if !recurse {
+			continue
 		}
 
 		nd, err := ng.Get(rw.Ctx)
@@ -257,7 +300,7 @@ func (rw *RefWriter) writeRefsRecursive(n node.Node) (int, error) {
 			return count, err
 		}
 
-		c, err := rw.writeRefsRecursive(nd)
+		c, err := rw.writeRefsRecursive(nd, depth+1)
 		count += c
 		if err != nil {
 			return count, err
@@ -266,6 +309,32 @@ func (rw *RefWriter) writeRefsRecursive(n node.Node) (int, error) {
 	return count, nil
 }
 
+// visit returns whether to write the ref to c, depth levels below the
+// object, and whether to go through its links. With Unique, a ref is only
+// written once, but its links are gone through again when it is met closer
+// to the object, so that they aren't cut off by MaxDepth.
+func (rw *RefWriter) visit(c *cid.Cid, depth int) (write, recurse bool) {
+	recurse = rw.MaxDepth < 0 || depth < rw.MaxDepth
+	if !rw.Unique {
+		return true, recurse
+	}
+
+	if rw.seen == nil {
+		rw.seen = dag.NewVisitedSet(dag.DefaultVisitedInMemory)
+	}
+
+	if rw.MaxDepth < 0 {
+		first := rw.seen.Visit(c)
+		return first, first
+	}
+
+	seen := rw.seen.Has(c)
+	if !rw.seen.VisitDepth(c, depth) {
+		return false, false
+	}
+	return !seen, recurse
+}
+
 func (rw *RefWriter) writeRefsSingle(n node.Node) (int, error) {
 	c := n.Cid()
 
@@ -295,14 +364,9 @@ func (rw *RefWriter) skip(c *cid.Cid) bool {
 	}
 
 	if rw.seen == nil {
-		rw.seen = cid.NewSet()
-	}
-
-	has := rw.seen.Has(c)
-	if !has {
-		rw.seen.Add(c)
+		rw.seen = dag.NewVisitedSet(dag.DefaultVisitedInMemory)
 	}
-	return has
+	return !rw.seen.Visit(c)
 }
 
 // Write one edge
@@ -1,7 +1,10 @@
 package commands
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,11 +14,15 @@ import (
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	path "github.com/ipfs/go-ipfs/path"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	lockfile "github.com/ipfs/go-ipfs/repo/fsrepo/lock"
 
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 )
@@ -38,6 +45,8 @@ var RepoCmd = &cmds.Command{
 		"fsck":    RepoFsckCmd,
 		"version": repoVersionCmd,
 		"verify":  repoVerifyCmd,
+		"du":      repoDuCmd,
+		"changes": repoChangesCmd,
 	},
 }
 
@@ -54,11 +63,30 @@ var repoGcCmd = &cmds.Command{
 'ipfs repo gc' is a plumbing command that will sweep the local
 set of stored objects and remove ones that are not pinned in
 order to reclaim hard disk space.
+`,
+		LongDescription: `
+'ipfs repo gc' is a plumbing command that will sweep the local
+set of stored objects and remove ones that are not pinned in
+order to reclaim hard disk space.
+
+The collection can be restricted to some blocks: the ones whose CID starts
+with one of the comma separated prefixes given with --only, and the ones
+listed, one CID per line, in the candidates file. Only these blocks are
+removed, if no pin or the files root keeps them. The pinned dags are then
+walked only until all the candidates were reached, and the other blocks of
+the repo are not listed:
+
+  > ipfs repo gc --only=zdj7W
+  > ipfs repo gc obsolete-cids.txt
 `,
 	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("candidates", false, false, "File listing the CIDs of the blocks to collect, one per line."),
+	},
 	Options: []cmds.Option{
 		cmds.BoolOption("quiet", "q", "Write minimal output.").Default(false),
 		cmds.BoolOption("stream-errors", "Stream errors.").Default(false),
+		cmds.StringOption("only", "Only collect the blocks whose CID starts with one of these comma separated prefixes."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -69,7 +97,18 @@ order to reclaim hard disk space.
 
 		streamErrors, _, _ := res.Request().Option("stream-errors").Bool()
 
-		gcOutChan := corerepo.GarbageCollectAsync(n, req.Context())
+		candidates, targeted, err := gcCandidates(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var gcOutChan <-chan gc.Result
+		if targeted {
+			gcOutChan = corerepo.GarbageCollectOnly(n, req.Context(), candidates)
+		} else {
+			gcOutChan = corerepo.GarbageCollectAsync(n, req.Context())
+		}
 
 		outChan := make(chan interface{}, cap(gcOutChan))
 		res.SetOutput((<-chan interface{})(outChan))
@@ -139,6 +178,77 @@ order to reclaim hard disk space.
 	},
 }
 
+// gcCandidates returns the blocks a 'repo gc' is restricted to, and whether
+// it is restricted at all.
+func gcCandidates(req cmds.Request, n *core.IpfsNode) ([]*cid.Cid, bool, error) {
+	only, found, err := req.Option("only").String()
+	if err != nil {
+		return nil, false, err
+	}
+	var prefixes []string
+	if found {
+		for _, p := range strings.Split(only, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				prefixes = append(prefixes, p)
+			}
+		}
+		if len(prefixes) == 0 {
+			return nil, false, fmt.Errorf("no CID prefix given to --only")
+		}
+	}
+
+	var candidates []*cid.Cid
+	targeted := len(prefixes) > 0
+
+	if req.Files() != nil {
+		file, err := req.Files().NextFile()
+		if err != nil && err != io.EOF {
+			return nil, false, err
+		}
+		if file != nil {
+			defer file.Close()
+			targeted = true
+
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				c, err := cid.Decode(line)
+				if err != nil {
+					return nil, false, fmt.Errorf("invalid candidate %q: %s", line, err)
+				}
+				candidates = append(candidates, c)
+			}
+			if err := scanner.Err(); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	if len(prefixes) > 0 {
+		keys, err := n.Blockstore.AllKeysChan(req.Context())
+		if err != nil {
+			return nil, false, err
+		}
+		for c := range keys {
+			s := c.String()
+			for _, p := range prefixes {
+				if strings.HasPrefix(s, p) {
+					candidates = append(candidates, c)
+					break
+				}
+			}
+		}
+		if err := req.Context().Err(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return candidates, targeted, nil
+}
+
 var repoStatCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Get stats for the currently used repo.",
@@ -226,6 +336,7 @@ daemons are running.
 
 		dsLockFile := filepath.Join(dsPath, "LOCK") // TODO: get this lockfile programmatically
 		repoLockFile := filepath.Join(configRoot, lockfile.LockFile)
+		holderFile := filepath.Join(configRoot, lockfile.HolderFile)
 		apiFile := filepath.Join(configRoot, "api") // TODO: get this programmatically
 
 		log.Infof("Removing repo lockfile: %s", repoLockFile)
@@ -237,6 +348,11 @@ daemons are running.
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		err = os.Remove(holderFile)
+		if err != nil && !os.IsNotExist(err) {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
 		err = os.Remove(dsLockFile)
 		if err != nil && !os.IsNotExist(err) {
 			res.SetError(err, cmds.ErrNormal)
@@ -264,6 +380,11 @@ type VerifyProgress struct {
 var repoVerifyCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Verify all blocks in repo are not corrupted.",
+		ShortDescription: `
+'ipfs repo verify' reads every block of the repo and checks its hash. The
+reads are paced as set by the Datastore.MaintenanceMaxRate and
+Datastore.MaintenanceMaxOps config keys.
+`,
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		nd, err := req.InvocContext().GetNode()
@@ -272,6 +393,17 @@ var repoVerifyCmd = &cmds.Command{
 			return
 		}
 
+		cfg, err := nd.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		pacer, err := corerepo.NewPacer(cfg.Datastore)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		out := make(chan interface{})
 		go func() {
 			defer close(out)
@@ -288,12 +420,14 @@ var repoVerifyCmd = &cmds.Command{
 			var fails int
 			var i int
 			for k := range keys {
-				_, err := bs.Get(k)
+				blk, err := bs.Get(k)
 				if err != nil {
 					out <- &VerifyProgress{
 						Message: fmt.Sprintf("block %s was corrupt (%s)", k, err),
 					}
 					fails++
+				} else if err := pacer.Wait(req.Context(), len(blk.RawData())); err != nil {
+					return
 				}
 				i++
 				out <- &VerifyProgress{Progress: i}
@@ -343,6 +477,223 @@ var repoVerifyCmd = &cmds.Command{
 	},
 }
 
+type RepoDuOutput struct {
+	Roots []*corerepo.DiskUsage
+}
+
+var repoDuCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the local disk usage of objects.",
+		ShortDescription: `
+'ipfs repo du' reports the space the blocks of a dag use in the local repo.
+Only the blocks stored locally are counted, nothing is fetched from the
+network.
+
+The shared size is the part of it also used by other pins or by the files
+root ('ipfs files'): unpinning the object would not free it. Blocks of the dag
+that are not stored locally are reported as missing.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, true, "Path to the objects to measure.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("human", "Print sizes in human readable format.").Default(false),
+	},
+	Type: RepoDuOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &RepoDuOutput{}
+		for _, p := range req.Arguments() {
+			p, err := path.ParsePath(p)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			c, err := core.ResolveToCid(req.Context(), n, p)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			du, err := corerepo.RepoDiskUsage(req.Context(), n, c)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			out.Roots = append(out.Roots, du)
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*RepoDuOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			human, _, _ := res.Request().Option("human").Bool()
+			size := func(s uint64) string {
+				if human {
+					return humanize.Bytes(s)
+				}
+				return fmt.Sprint(s)
+			}
+
+			buf := new(bytes.Buffer)
+			wtr := tabwriter.NewWriter(buf, 0, 0, 1, ' ', 0)
+			for _, du := range out.Roots {
+				fmt.Fprintf(wtr, "%s\n", du.Root)
+				fmt.Fprintf(wtr, "  Size:\t%s\t(%d blocks)\n", size(du.Size), du.Blocks)
+				fmt.Fprintf(wtr, "  Shared:\t%s\t(%d blocks)\n", size(du.SharedSize), du.SharedBlocks)
+				fmt.Fprintf(wtr, "  Unique:\t%s\t(%d blocks)\n", size(du.Size-du.SharedSize), du.Blocks-du.SharedBlocks)
+				fmt.Fprintf(wtr, "  Missing:\t\t(%d blocks)\n", du.Missing)
+			}
+			wtr.Flush()
+			return buf, nil
+		},
+	},
+}
+
+// RepoChange is a block added to the repo, streamed by 'repo changes'.
+type RepoChange struct {
+	Seq  uint64
+	Cid  string
+	Data []byte `json:",omitempty"`
+}
+
+var errNoChangeFeed = errors.New("the change feed is disabled, enable it with 'ipfs config --bool Datastore.ChangeFeed true'")
+
+var repoChangesCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Stream the blocks added to the repo.",
+		ShortDescription: `
+'ipfs repo changes' lists the blocks added to the repo, in order, with the
+sequence number of each addition. Indexers and replicas mirror a repo
+incrementally by passing the last number they saw to --since, which lists
+the blocks added after it. With --follow, the command keeps running and
+streams the new blocks as they are added.
+
+Blocks which were already stored aren't listed again, and blocks removed
+since they were added are still listed. With --data, the content of each
+block still stored is included, base64 encoded in the text output.
+
+The change feed must be enabled in the config first, only the blocks added
+after that are listed:
+
+  > ipfs config --bool Datastore.ChangeFeed true
+`,
+	},
+	Options: []cmds.Option{
+		cmds.UintOption("since", "List the blocks added after this sequence number.").Default(uint(0)),
+		cmds.BoolOption("follow", "f", "Keep streaming the blocks as they are added.").Default(false),
+		cmds.BoolOption("data", "Include the content of the blocks.").Default(false),
+	},
+	Type: RepoChange{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if n.Changes == nil {
+			res.SetError(errNoChangeFeed, cmds.ErrNormal)
+			return
+		}
+
+		since, _, err := req.Option("since").Uint()
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+		follow, _, _ := req.Option("follow").Bool()
+		withData, _, _ := req.Option("data").Bool()
+
+		outChan := make(chan interface{})
+		res.SetOutput((<-chan interface{})(outChan))
+
+		go func() {
+			defer close(outChan)
+
+			ctx := req.Context()
+			after := uint64(since)
+			for {
+				// read the log in chunks, not to hold it all in memory
+				changes, err := n.Changes.Since(after, 1024)
+				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+
+				for _, c := range changes {
+					out := &RepoChange{Seq: c.Seq, Cid: c.Cid.String()}
+					if withData {
+						b, err := n.Blockstore.Get(c.Cid)
+						switch err {
+						case nil:
+							out.Data = b.RawData()
+						case bstore.ErrNotFound:
+						default:
+							res.SetError(err, cmds.ErrNormal)
+							return
+						}
+					}
+
+					select {
+					case outChan <- out:
+					case <-ctx.Done():
+						return
+					}
+					after = c.Seq
+				}
+
+				if len(changes) > 0 {
+					continue
+				}
+				if !follow {
+					return
+				}
+				if err := n.Changes.Wait(ctx, after); err != nil {
+					return
+				}
+			}
+		}()
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			marshal := func(v interface{}) (io.Reader, error) {
+				c, ok := v.(*RepoChange)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+
+				if c.Data != nil {
+					return strings.NewReader(fmt.Sprintf("%d %s %s\n", c.Seq, c.Cid, base64.StdEncoding.EncodeToString(c.Data))), nil
+				}
+				return strings.NewReader(fmt.Sprintf("%d %s\n", c.Seq, c.Cid)), nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
+	},
+}
+
 var repoVersionCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Show the repo version.",
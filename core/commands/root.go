@@ -30,6 +30,7 @@ BASIC COMMANDS
   get <ref>     Download IPFS objects
   ls <ref>      List links from an object
   refs <ref>    List hashes of links from an object
+  share <path>  Add a file and print links to share it
 
 DATA STRUCTURE COMMANDS
   block         Interact with raw blocks in the datastore
@@ -45,8 +46,11 @@ ADVANCED COMMANDS
   key           Create and list IPNS name keypairs
   dns           Resolve DNS links
   pin           Pin objects to local storage
+  manifest      Create and verify signed content manifests
   repo          Manipulate the IPFS repository
+  audit         Inspect the audit log
   stats         Various operational stats
+  benchmark     Measure the performance of ipfs on this machine
   filestore     Manage the filestore (experimental)
 
 NETWORK COMMANDS
@@ -54,11 +58,14 @@ NETWORK COMMANDS
   bootstrap     Add or remove bootstrap peers
   swarm         Manage connections to the p2p network
   dht           Query the DHT for values or peers
+  find-providers Find the peers providing some content
   ping          Measure the latency of a connection
+  p2p           Reach the services of other nodes (experimental)
   diag          Print diagnostics
 
 TOOL COMMANDS
   config        Manage configuration
+  features      List and toggle optional features
   version       Show ipfs version information
   update        Download and apply go-ipfs updates
   commands      List all available commands
@@ -92,41 +99,50 @@ The CLI will exit with one of the following values:
 var CommandsDaemonCmd = CommandsCmd(Root)
 
 var rootSubcommands = map[string]*cmds.Command{
-	"add":       AddCmd,
-	"block":     BlockCmd,
-	"bootstrap": BootstrapCmd,
-	"cat":       CatCmd,
-	"commands":  CommandsDaemonCmd,
-	"config":    ConfigCmd,
-	"dag":       dag.DagCmd,
-	"dht":       DhtCmd,
-	"diag":      DiagCmd,
-	"dns":       DNSCmd,
-	"files":     files.FilesCmd,
-	"get":       GetCmd,
-	"id":        IDCmd,
-	"key":       KeyCmd,
-	"log":       LogCmd,
-	"ls":        LsCmd,
-	"mount":     MountCmd,
-	"name":      NameCmd,
-	"object":    ocmd.ObjectCmd,
-	"pin":       PinCmd,
-	"ping":      PingCmd,
-	"pubsub":    PubsubCmd,
-	"refs":      RefsCmd,
-	"repo":      RepoCmd,
-	"resolve":   ResolveCmd,
-	"stats":     StatsCmd,
-	"swarm":     SwarmCmd,
-	"tar":       TarCmd,
-	"tour":      tourCmd,
-	"file":      unixfs.UnixFSCmd,
-	"update":    ExternalBinary(),
-	"version":   VersionCmd,
-	"bitswap":   BitswapCmd,
-	"filestore": FileStoreCmd,
-	"shutdown":  daemonShutdownCmd,
+	"add":            AddCmd,
+	"audit":          AuditCmd,
+	"block":          BlockCmd,
+	"bootstrap":      BootstrapCmd,
+	"cat":            CatCmd,
+	"commands":       CommandsDaemonCmd,
+	"config":         ConfigCmd,
+	"crypt":          CryptCmd,
+	"daemon":         DaemonCmd,
+	"dag":            dag.DagCmd,
+	"dht":            DhtCmd,
+	"diag":           DiagCmd,
+	"dns":            DNSCmd,
+	"features":       FeaturesCmd,
+	"files":          files.FilesCmd,
+	"find-providers": FindProvidersCmd,
+	"get":            GetCmd,
+	"group":          GroupCmd,
+	"id":             IDCmd,
+	"key":            KeyCmd,
+	"log":            LogCmd,
+	"ls":             LsCmd,
+	"manifest":       ManifestCmd,
+	"mount":          MountCmd,
+	"name":           NameCmd,
+	"object":         ocmd.ObjectCmd,
+	"pin":            PinCmd,
+	"p2p":            P2PCmd,
+	"ping":           PingCmd,
+	"pubsub":         PubsubCmd,
+	"refs":           RefsCmd,
+	"repo":           RepoCmd,
+	"resolve":        ResolveCmd,
+	"share":          ShareCmd,
+	"stats":          StatsCmd,
+	"swarm":          SwarmCmd,
+	"tar":            TarCmd,
+	"tour":           tourCmd,
+	"file":           unixfs.UnixFSCmd,
+	"update":         ExternalBinary(),
+	"version":        VersionCmd,
+	"bitswap":        BitswapCmd,
+	"filestore":      FileStoreCmd,
+	"shutdown":       daemonShutdownCmd,
 }
 
 // RootRO is the readonly version of Root
@@ -0,0 +1,246 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	files "github.com/ipfs/go-ipfs/commands/files"
+	core "github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/core/coreunix"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	"github.com/ipfs/go-ipfs/thirdparty/qrcode"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	multibase "gx/ipfs/QmcxkxTVuURV2Ptse8TvkqH5BQDwV62X1x19JqqvbBzwUM/go-multibase"
+)
+
+var errNoRemotePin = errors.New("no remote pinning node set, set one with 'ipfs config Share.RemotePin <api-url>'")
+
+type ShareOutput struct {
+	Cid           string
+	URLs          []string
+	AnnounceError string `json:",omitempty"`
+	RemotePinned  string `json:",omitempty"`
+}
+
+var ShareCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Add content and print links to share it.",
+		ShortDescription: `
+Adds and pins <path>, announces it to the network, and prints links to it on
+public gateways, in subdomain and path form, with a QR code of the first one.
+`,
+		LongDescription: `
+Adds and pins <path>, announces it to the network, and prints links to it on
+public gateways, in subdomain and path form, with a QR code of the first one.
+Use -r to share a directory.
+
+The gateways are set in the config by Share.SubdomainGateway and
+Share.Gateway. The content is only reachable through them while a node
+providing it is online. To keep it available once this node goes offline,
+set Share.RemotePin to the API url of a go-ipfs node pinning content for you,
+and use --remote:
+
+  > ipfs config Share.RemotePin http://pinner.example.com:5001
+  > ipfs share --remote photo.jpg
+
+This command needs a running daemon.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.FileArg("path", true, false, "The path to a file to be shared.").EnableRecursive(),
+	},
+	Options: []cmds.Option{
+		cmds.OptionRecursivePath, // a builtin option that allows recursive paths (-r, --recursive)
+		cmds.BoolOption("remote", "Also pin the content on the node set in Share.RemotePin.").Default(false),
+		cmds.BoolOption("qr", "Print a QR code of the first link.").Default(true),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		remote, _, _ := req.Option("remote").Bool()
+		if remote && cfg.Share.RemotePin == "" {
+			res.SetError(errNoRemotePin, cmds.ErrClient)
+			return
+		}
+
+		f, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		c, err := shareAdd(req.Context(), n, f)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if err := recordPinInfo(n, req, []*cid.Cid{c}, nil, "", nil); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &ShareOutput{Cid: c.String()}
+
+		// the reprovider announces the content later on if this fails, e.g.
+		// when no peers are connected yet
+		if err := n.Routing.Provide(req.Context(), c); err != nil {
+			out.AnnounceError = err.Error()
+		}
+
+		if remote {
+			if err := remotePin(req.Context(), cfg.Share.RemotePin, c); err != nil {
+				res.SetError(fmt.Errorf("pinning on %s: %s", cfg.Share.RemotePin, err), cmds.ErrNormal)
+				return
+			}
+			out.RemotePinned = cfg.Share.RemotePin
+		}
+
+		out.URLs, err = shareURLs(cfg.Share, c)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*ShareOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected output type to be ShareOutput")
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "shared %s\n", out.Cid)
+			if out.AnnounceError != "" {
+				fmt.Fprintf(buf, "could not announce it yet (%s), the links may take a while to work\n", out.AnnounceError)
+			}
+			if out.RemotePinned != "" {
+				fmt.Fprintf(buf, "pinned on %s\n", out.RemotePinned)
+			}
+			fmt.Fprintln(buf)
+			for _, u := range out.URLs {
+				fmt.Fprintln(buf, u)
+			}
+
+			showQR, _, _ := res.Request().Option("qr").Bool()
+			if showQR && len(out.URLs) > 0 {
+				code, err := qrcode.Encode([]byte(out.URLs[0]))
+				if err != nil {
+					return nil, err
+				}
+				fmt.Fprintln(buf)
+				buf.WriteString(code.Terminal())
+			}
+			return buf, nil
+		},
+	},
+	Type: ShareOutput{},
+}
+
+// shareAdd adds and pins f, and returns its cid.
+func shareAdd(ctx context.Context, n *core.IpfsNode, f files.File) (*cid.Cid, error) {
+	adder, err := coreunix.NewAdder(ctx, n.Pinning, n.Blockstore, n.DAG)
+	if err != nil {
+		return nil, err
+	}
+	adder.Silent = true
+
+	if err := adder.AddFile(f); err != nil {
+		return nil, err
+	}
+	root, err := adder.Finalize()
+	if err != nil {
+		return nil, err
+	}
+	if err := adder.PinRoot(); err != nil {
+		return nil, err
+	}
+	return root.Cid(), nil
+}
+
+// shareURLs returns the links to c on the gateways of the config, subdomain
+// form first.
+func shareURLs(cfg config.Share, c *cid.Cid) ([]string, error) {
+	var urls []string
+
+	sub := cfg.SubdomainGateway
+	if sub == "" {
+		sub = config.DefaultShareSubdomainGateway
+	}
+	u, err := url.Parse(sub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subdomain gateway %q: %s", sub, err)
+	}
+	// hostnames are case insensitive, so the cid goes in base32
+	enc, err := multibase.Encode(multibase.Base32, cid.NewCidV1(c.Type(), c.Hash()).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	u.Host = strings.ToLower(enc) + ".ipfs." + u.Host
+	u.Path = "/"
+	urls = append(urls, u.String())
+
+	gw := cfg.Gateway
+	if gw == "" {
+		gw = config.DefaultShareGateway
+	}
+	urls = append(urls, strings.TrimSuffix(gw, "/")+"/ipfs/"+c.String())
+
+	return urls, nil
+}
+
+// remotePin asks the go-ipfs node with the given API url to pin c.
+func remotePin(ctx context.Context, api string, c *cid.Cid) error {
+	u := strings.TrimSuffix(api, "/") + "/api/v0/pin/add?arg=" + url.QueryEscape("/ipfs/"+c.String())
+	req, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var e cmds.Error
+	if err := json.Unmarshal(body, &e); err == nil && e.Message != "" {
+		return errors.New(e.Message)
+	}
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
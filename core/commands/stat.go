@@ -10,6 +10,8 @@ import (
 	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	reprovide "github.com/ipfs/go-ipfs/exchange/reprovide"
 	metrics "gx/ipfs/QmVMbSdq6PbznPC83SENVhH7JZn3BqqxkKgrHJFN2RuARf/go-libp2p-metrics"
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
@@ -30,6 +32,9 @@ for your IPFS node.`,
 		"bw":      statBwCmd,
 		"repo":    repoStatCmd,
 		"bitswap": bitswapStatCmd,
+		"provide": statProvideCmd,
+		"gc":      statGcCmd,
+		"peers":   statPeersCmd,
 	},
 }
 
@@ -223,3 +228,171 @@ func printStats(out io.Writer, bs *metrics.Stats) {
 	fmt.Fprintf(out, "RateIn: %s/s\n", humanize.Bytes(uint64(bs.RateIn)))
 	fmt.Fprintf(out, "RateOut: %s/s\n", humanize.Bytes(uint64(bs.RateOut)))
 }
+
+var statProvideCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the progress of the reprovider.",
+		ShortDescription: `
+'ipfs stats provide' reports how far the node is in announcing the blocks it
+stores to the network: the number of CIDs to announce in the current cycle,
+how many were announced, the announce rate, and when the cycle is expected
+to complete. The CIDs are counted as they are announced: while a cycle runs,
+their number is estimated from the last complete cycle.
+
+Blocks skipped by the announce filters of the Reprovider config are
+counted apart.
+
+Provider records expire after 24 hours. If a cycle doesn't complete within
+that window, some of the content stops being discoverable until it is
+announced again.
+`,
+	},
+	Type: reprovide.Stat{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !nd.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		if nd.Reprovider == nil {
+			res.SetError(errors.New("reprovider is not running"), cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(nd.Reprovider.Stat())
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*reprovide.Stat)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			buf := new(bytes.Buffer)
+			if out.Started.IsZero() {
+				fmt.Fprintln(buf, "no reprovide cycle ran yet")
+				return buf, nil
+			}
+
+			state := "complete"
+			switch {
+			case out.InProgress:
+				state = "in progress"
+			case out.Provided+out.Skipped < out.Total:
+				state = "aborted"
+			}
+			fmt.Fprintf(buf, "reprovide cycle %s\n", state)
+			fmt.Fprintf(buf, "\tstarted: %s\n", out.Started.Format(time.RFC3339))
+			if !out.InProgress {
+				fmt.Fprintf(buf, "\tended: %s\n", out.Ended.Format(time.RFC3339))
+			}
+			switch {
+			case !out.InProgress:
+				fmt.Fprintf(buf, "\tprovided: %d / %d\n", out.Provided, out.Total)
+			case out.Provided+out.Skipped < out.Total:
+				fmt.Fprintf(buf, "\tprovided: %d / ~%d\n", out.Provided, out.Total)
+			default:
+				fmt.Fprintf(buf, "\tprovided: %d\n", out.Provided)
+			}
+			if out.Skipped > 0 {
+				fmt.Fprintf(buf, "\tskipped by announce filters: %d\n", out.Skipped)
+			}
+			fmt.Fprintf(buf, "\trate: %.2f/s\n", out.Rate)
+			if out.InProgress {
+				eta := "unknown"
+				if !out.EstimatedEnd.IsZero() {
+					eta = out.EstimatedEnd.Format(time.RFC3339)
+				}
+				fmt.Fprintf(buf, "\testimated completion: %s\n", eta)
+			}
+			if !out.EstimatedEnd.IsZero() {
+				fmt.Fprintf(buf, "\twithin record validity: %t\n", out.WithinValidity)
+			}
+			return buf, nil
+		},
+	},
+}
+
+var statGcCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the garbage collection history.",
+		ShortDescription: `
+'ipfs stats gc' lists the recent garbage collection runs: what triggered
+them, when they started and ended, how many blocks were removed, how much
+space was reclaimed, and the errors they hit.
+
+It also shows when the periodic garbage collection of the daemon last
+checked the repo size against the watermark. Runs only happen when the size
+exceeds the watermark.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("n", "Number of runs to show.").Default(10),
+	},
+	Type: corerepo.GCHistory{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		n, _, _ := req.Option("n").Int()
+		if n < 0 {
+			res.SetError(errors.New("number of runs must not be negative"), cmds.ErrClient)
+			return
+		}
+
+		h, err := corerepo.GetGCHistory(nd.Repo)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if len(h.Runs) > n {
+			h.Runs = h.Runs[:n]
+		}
+
+		res.SetOutput(h)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*corerepo.GCHistory)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			if out.LastCheck.IsZero() {
+				fmt.Fprintln(buf, "periodic gc: never checked")
+			} else {
+				fmt.Fprintf(buf, "periodic gc: last checked %s\n", out.LastCheck.Format(time.RFC3339))
+			}
+
+			if len(out.Runs) == 0 {
+				fmt.Fprintln(buf, "no gc run recorded")
+				return buf, nil
+			}
+
+			for _, r := range out.Runs {
+				fmt.Fprintf(buf, "%s gc at %s, took %s\n", r.Trigger, r.Start.Format(time.RFC3339), r.End.Sub(r.Start))
+				fmt.Fprintf(buf, "\tremoved: %d blocks\n", r.BlocksRemoved)
+				fmt.Fprintf(buf, "\treclaimed: %s\n", humanize.Bytes(r.BytesReclaimed))
+				if r.MarkCached {
+					fmt.Fprintln(buf, "\tmark: reused from a previous run")
+				}
+				if len(r.Errors) > 0 {
+					fmt.Fprintf(buf, "\terrors: %d\n", len(r.Errors))
+					for _, e := range r.Errors {
+						fmt.Fprintf(buf, "\t\t%s\n", e)
+					}
+				}
+			}
+			return buf, nil
+		},
+	},
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	metrics "gx/ipfs/QmVMbSdq6PbznPC83SENVhH7JZn3BqqxkKgrHJFN2RuARf/go-libp2p-metrics"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+// PeerStat is what the node knows of the traffic with a connected peer.
+type PeerStat struct {
+	Peer string
+	metrics.Stats
+
+	// the bytes of the blocks exchanged with bitswap, and the number of
+	// blocks
+	BitswapSent      uint64
+	BitswapRecv      uint64
+	BitswapExchanged uint64
+
+	Latency      time.Duration `json:",omitempty"`
+	ConnectedFor time.Duration `json:",omitempty"`
+}
+
+type PeerStatsOutput struct {
+	Peers []PeerStat
+}
+
+// peerStatsLess are the orders of 'ipfs stats peers --sort'.
+var peerStatsLess = map[string]func(a, b *PeerStat) bool{
+	"bandwidth": func(a, b *PeerStat) bool { return a.TotalIn+a.TotalOut > b.TotalIn+b.TotalOut },
+	"in":        func(a, b *PeerStat) bool { return a.TotalIn > b.TotalIn },
+	"out":       func(a, b *PeerStat) bool { return a.TotalOut > b.TotalOut },
+	"rate":      func(a, b *PeerStat) bool { return a.RateIn+a.RateOut > b.RateIn+b.RateOut },
+	"bitswap":   func(a, b *PeerStat) bool { return a.BitswapSent+a.BitswapRecv > b.BitswapSent+b.BitswapRecv },
+	"latency": func(a, b *PeerStat) bool {
+		// unknown latencies last
+		if a.Latency == 0 || b.Latency == 0 {
+			return a.Latency != 0
+		}
+		return a.Latency < b.Latency
+	},
+	"age": func(a, b *PeerStat) bool { return a.ConnectedFor > b.ConnectedFor },
+}
+
+var statPeersCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the traffic with each connected peer.",
+		ShortDescription: `
+'ipfs stats peers' prints, for each connected peer, the bytes sent and
+received with their rates, the bytes of the blocks exchanged with bitswap,
+the latency and how long the peer is connected.
+
+--sort orders the peers by:
+  bandwidth   the bytes sent and received, the most first (default)
+  in, out     the bytes received, or sent
+  rate        the current rate, in and out
+  bitswap     the bytes of the blocks exchanged with bitswap
+  latency     the latency, the lowest first
+  age         how long the peer is connected, the longest first
+
+--top only prints the first peers of the order.
+
+Bytes aren't counted when Swarm.DisableBandwidthMetrics is set in the config.
+
+Example:
+
+  > ipfs stats peers --sort=bandwidth --top 2
+  PEER                                            IN      OUT     RATE IN   RATE OUT  BITSWAP IN  BITSWAP OUT  LATENCY  CONNECTED
+  QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ  52 MB   1.2 MB  310 kB/s  12 kB/s   50 MB       1.0 MB       23ms     2h3m0s
+  QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM  1.1 MB  900 kB  2.3 kB/s  1.8 kB/s  0 B         0 B          85ms     2h4m10s
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("sort", "s", "The order of the peers.").Default("bandwidth"),
+		cmds.IntOption("top", "n", "Only print the first peers, all if 0.").Default(0),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		order, _, _ := req.Option("sort").String()
+		less, ok := peerStatsLess[order]
+		if !ok {
+			res.SetError(fmt.Errorf("unknown sort order %q", order), cmds.ErrClient)
+			return
+		}
+		top, _, _ := req.Option("top").Int()
+		if top < 0 {
+			res.SetError(fmt.Errorf("--top must not be negative"), cmds.ErrClient)
+			return
+		}
+
+		bs := n.Bitswap()
+		now := time.Now()
+		out := new(PeerStatsOutput)
+		for _, p := range n.PeerHost.Network().Peers() {
+			st := PeerStat{
+				Peer:    p.Pretty(),
+				Latency: n.Peerstore.LatencyEWMA(p),
+			}
+			if n.Reporter != nil {
+				st.Stats = n.Reporter.GetBandwidthForPeer(p)
+			}
+			if bs != nil {
+				r := bs.LedgerForPeer(p)
+				st.BitswapSent = r.Sent
+				st.BitswapRecv = r.Recv
+				st.BitswapExchanged = r.Exchanged
+			}
+			if since, ok := n.ProtoStats.ConnectedSince(p); ok {
+				st.ConnectedFor = now.Sub(since)
+			}
+			out.Peers = append(out.Peers, st)
+		}
+
+		sort.Stable(peerStatsBy{out.Peers, less})
+		if top > 0 && len(out.Peers) > top {
+			out.Peers = out.Peers[:top]
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*PeerStatsOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 2, ' ', 0)
+			fmt.Fprintln(w, "PEER\tIN\tOUT\tRATE IN\tRATE OUT\tBITSWAP IN\tBITSWAP OUT\tLATENCY\tCONNECTED")
+			for _, st := range out.Peers {
+				lat := "n/a"
+				if st.Latency != 0 {
+					lat = st.Latency.String()
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s/s\t%s/s\t%s\t%s\t%s\t%s\n", st.Peer,
+					humanize.Bytes(uint64(st.TotalIn)), humanize.Bytes(uint64(st.TotalOut)),
+					humanize.Bytes(uint64(st.RateIn)), humanize.Bytes(uint64(st.RateOut)),
+					humanize.Bytes(st.BitswapRecv), humanize.Bytes(st.BitswapSent),
+					lat, st.ConnectedFor-st.ConnectedFor%time.Second)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: PeerStatsOutput{},
+}
+
+type peerStatsBy struct {
+	peers []PeerStat
+	less  func(a, b *PeerStat) bool
+}
+
+func (s peerStatsBy) Len() int           { return len(s.peers) }
+func (s peerStatsBy) Swap(i, j int)      { s.peers[i], s.peers[j] = s.peers[j], s.peers[i] }
+func (s peerStatsBy) Less(i, j int) bool { return s.less(&s.peers[i], &s.peers[j]) }
@@ -42,7 +42,10 @@ ipfs peers in the internet.
 		"connect":    swarmConnectCmd,
 		"disconnect": swarmDisconnectCmd,
 		"filters":    swarmFiltersCmd,
+		"lan":        swarmLanCmd,
+		"peering":    swarmPeeringCmd,
 		"peers":      swarmPeersCmd,
+		"stats":      swarmStatsCmd,
 	},
 }
 
@@ -476,6 +479,74 @@ func peersWithAddresses(addrs []string) (pis []pstore.PeerInfo, err error) {
 	return pis, nil
 }
 
+// LanModeOutput is the state of the LAN mode.
+type LanModeOutput struct {
+	Enabled bool
+}
+
+var swarmLanCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show or toggle the LAN mode.",
+		ShortDescription: `
+'ipfs swarm lan' shows whether the node is in LAN mode, and 'ipfs swarm lan
+on|off' toggles it. In LAN mode, the node only dials and accepts connections
+with private, link-local and loopback addresses, and finds its peers with
+mDNS. Open connections to other peers are closed.
+
+The LAN mode adds address filters, listed by 'ipfs swarm filters'. Toggling
+it this way will not persist daemon reboots, to achieve that, set the
+"Swarm.LanMode" config key.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("mode", false, false, "'on' or 'off'."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if n.PeerHost == nil {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		if len(req.Arguments()) > 0 {
+			var on bool
+			switch req.Arguments()[0] {
+			case "on":
+				on = true
+			case "off":
+			default:
+				res.SetError(fmt.Errorf("invalid mode %q, expected 'on' or 'off'", req.Arguments()[0]), cmds.ErrClient)
+				return
+			}
+
+			if err := n.SetLanMode(on); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		res.SetOutput(&LanModeOutput{n.LanMode()})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*LanModeOutput)
+			if !ok {
+				return nil, errors.New("failed to cast LanModeOutput")
+			}
+			if out.Enabled {
+				return bytes.NewBufferString("on\n"), nil
+			}
+			return bytes.NewBufferString("off\n"), nil
+		},
+	},
+	Type: LanModeOutput{},
+}
+
 var swarmFiltersCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Manipulate address filters.",
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+)
+
+// SwarmStatsOutput is the traffic of the swarm, in total and by protocol.
+type SwarmStatsOutput struct {
+	Total     core.ProtocolStat
+	Protocols []core.ProtocolStat `json:",omitempty"`
+}
+
+var swarmStatsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the streams and bandwidth of the swarm.",
+		ShortDescription: `
+'ipfs swarm stats' prints the number of streams open and opened since the
+daemon started, and the bytes sent and received, with their rates. With
+--protocol, they are broken down by libp2p protocol ID, e.g. bitswap, the
+DHT, identify or pubsub, to see which protocols use the bandwidth.
+
+Bytes aren't counted when Swarm.DisableBandwidthMetrics is set in the config.
+The same figures are exported to Prometheus, as the
+ipfs_p2p_protocol_* metrics.
+
+Example:
+
+  > ipfs swarm stats --protocol
+  PROTOCOL             OPEN  STREAMS  IN      OUT     RATE IN  RATE OUT
+  /ipfs/bitswap/1.1.0  4     120      52 MB   1.2 MB  310 kB/s 12 kB/s
+  /ipfs/id/1.0.0       0     35       42 kB   40 kB   0 B/s    0 B/s
+  /ipfs/kad/1.0.0      2     410      1.1 MB  900 kB  2.3 kB/s 1.8 kB/s
+  total                6     565      53 MB   2.1 MB  312 kB/s 14 kB/s
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("protocol", "p", "Break the stats down by protocol.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		stats := n.ProtoStats.Stats(n.PeerHost.Network(), n.Reporter)
+
+		out := new(SwarmStatsOutput)
+		for _, st := range stats {
+			out.Total.StreamsOpen += st.StreamsOpen
+			out.Total.StreamsTotal += st.StreamsTotal
+		}
+		if n.Reporter != nil {
+			out.Total.Stats = n.Reporter.GetBandwidthTotals()
+		}
+		if byProto, _, _ := req.Option("protocol").Bool(); byProto {
+			out.Protocols = stats
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*SwarmStatsOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected output type to be SwarmStatsOutput")
+			}
+
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			fmt.Fprintln(w, "PROTOCOL\tOPEN\tSTREAMS\tIN\tOUT\tRATE IN\tRATE OUT")
+			row := func(name string, st core.ProtocolStat) {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s/s\t%s/s\n", name, st.StreamsOpen, st.StreamsTotal,
+					humanize.Bytes(uint64(st.TotalIn)), humanize.Bytes(uint64(st.TotalOut)),
+					humanize.Bytes(uint64(st.RateIn)), humanize.Bytes(uint64(st.RateOut)))
+			}
+			for _, st := range out.Protocols {
+				row(string(st.Protocol), st)
+			}
+			row("total", out.Total)
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: SwarmStatsOutput{},
+}
@@ -1,14 +1,19 @@
 package commands
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"runtime"
+	"sort"
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	versioncheck "github.com/ipfs/go-ipfs/core/versioncheck"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 )
 
 type VersionOutput struct {
@@ -85,4 +90,76 @@ var VersionCmd = &cmds.Command{
 		},
 	},
 	Type: VersionOutput{},
+	Subcommands: map[string]*cmds.Command{
+		"check": versionCheckCmd,
+	},
+}
+
+var versionCheckCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Compare the local version against the network.",
+		ShortDescription: `
+'ipfs version check' compares the version of go-ipfs against the versions run
+by the connected peers and, if Version.ReleaseName is set in the config, the
+latest release published under that IPNS name. It warns when a newer release
+is run by a significant share of peers, or when the latest release is newer.
+Only major and minor versions are compared.
+
+The daemon runs the check once a day, unless Version.NoCheck is set.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out, err := versioncheck.Check(req.Context(), n, cfg.Version)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*versioncheck.Result)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			versions := make([]string, 0, len(out.Versions))
+			for v := range out.Versions {
+				versions = append(versions, v)
+			}
+			sort.Strings(versions)
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "local version: %s\n", out.Local)
+			fmt.Fprintf(buf, "peers: %d (%d on a newer release)\n", out.Peers, out.Newer)
+			for _, v := range versions {
+				fmt.Fprintf(buf, "\t%s: %d\n", v, out.Versions[v])
+			}
+			if out.Release != "" {
+				fmt.Fprintf(buf, "latest release: %s\n", out.Release)
+			}
+			if out.Behind {
+				fmt.Fprintf(buf, "WARNING: %s\n", out.Warning)
+			}
+			return buf, nil
+		},
+	},
+	Type: versioncheck.Result{},
 }
@@ -0,0 +1,373 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	goprocessctx "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess/context"
+	record "gx/ipfs/QmWYCqr6UDqqD1bfRybaAPtbAqcN3TSJpveaBXMwbQ3ePZ/go-libp2p-record"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// ContinuityNamespace is the routing key namespace of the continuity
+// records, stored under "/continuity/<old peer id>".
+const ContinuityNamespace = "continuity"
+
+// Peerstore keys of the identity rotations learnt from continuity records.
+const (
+	RotatedToKey   = "RotatedTo"
+	RotatedFromKey = "RotatedFrom"
+)
+
+// ContinuityRepublishInterval is how often the daemon puts its continuity
+// records back in the DHT.
+var ContinuityRepublishInterval = 12 * time.Hour
+
+var continuityInitialDelay = time.Minute
+
+var continuityKey = ds.NewKey("/local/identity/continuity")
+
+var ErrContinuitySignature = errors.New("continuity record signature is invalid")
+
+// ErrConflictingRotation is returned when learning a rotation of a peer ID
+// already known to have rotated to another one.
+var ErrConflictingRotation = errors.New("peer ID was already rotated to another one")
+
+// ErrAmbiguousRotation is returned when the continuity records of a peer ID
+// rotate it to different IDs, and none of them is known already.
+var ErrAmbiguousRotation = errors.New("continuity records rotate the peer ID to different ones")
+
+// ContinuityRecord is written when a node rotates its identity: the retired
+// key signs the new one, and the new key signs back, so that peers trusting
+// the old peer ID can move their trust to the new one.
+type ContinuityRecord struct {
+	OldPubKey []byte
+	NewPubKey []byte
+	Rotated   time.Time
+
+	// OldSignature and NewSignature are the signatures of the old and new
+	// keys over the record payload.
+	OldSignature []byte
+	NewSignature []byte
+}
+
+// NewContinuityRecord creates the record of the rotation from oldSK to newSK.
+func NewContinuityRecord(oldSK, newSK ic.PrivKey, t time.Time) (*ContinuityRecord, error) {
+	oldPK, err := oldSK.GetPublic().Bytes()
+	if err != nil {
+		return nil, err
+	}
+	newPK, err := newSK.GetPublic().Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &ContinuityRecord{
+		OldPubKey: oldPK,
+		NewPubKey: newPK,
+		Rotated:   t.UTC(),
+	}
+	payload := rec.payload()
+	if rec.OldSignature, err = oldSK.Sign(payload); err != nil {
+		return nil, err
+	}
+	if rec.NewSignature, err = newSK.Sign(payload); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// payload is what both keys sign: the two public keys and the rotation time,
+// each prefixed with its length so that no two records share a payload.
+func (r *ContinuityRecord) payload() []byte {
+	var b bytes.Buffer
+	b.WriteString("ipfs-identity-continuity:")
+	for _, f := range [][]byte{
+		r.OldPubKey,
+		r.NewPubKey,
+		[]byte(r.Rotated.UTC().Format(time.RFC3339Nano)),
+	} {
+		var l [binary.MaxVarintLen64]byte
+		b.Write(l[:binary.PutUvarint(l[:], uint64(len(f)))])
+		b.Write(f)
+	}
+	return b.Bytes()
+}
+
+// Verify checks both signatures of the record, and returns the old and new
+// peer IDs it links.
+func (r *ContinuityRecord) Verify() (peer.ID, peer.ID, error) {
+	oldPK, err := ic.UnmarshalPublicKey(r.OldPubKey)
+	if err != nil {
+		return "", "", fmt.Errorf("old public key: %s", err)
+	}
+	newPK, err := ic.UnmarshalPublicKey(r.NewPubKey)
+	if err != nil {
+		return "", "", fmt.Errorf("new public key: %s", err)
+	}
+
+	payload := r.payload()
+	for _, s := range []struct {
+		pk  ic.PubKey
+		sig []byte
+	}{{oldPK, r.OldSignature}, {newPK, r.NewSignature}} {
+		ok, err := s.pk.Verify(payload, s.sig)
+		if err != nil {
+			return "", "", err
+		}
+		if !ok {
+			return "", "", ErrContinuitySignature
+		}
+	}
+
+	oldID, err := peer.IDFromPublicKey(oldPK)
+	if err != nil {
+		return "", "", err
+	}
+	newID, err := peer.IDFromPublicKey(newPK)
+	if err != nil {
+		return "", "", err
+	}
+	if oldID == newID {
+		return "", "", errors.New("continuity record rotates a key to itself")
+	}
+	return oldID, newID, nil
+}
+
+// ContinuityRecordKey is the routing key of the record of a retired ID.
+func ContinuityRecordKey(old peer.ID) string {
+	return "/" + ContinuityNamespace + "/" + string(old)
+}
+
+// UnmarshalContinuityRecord decodes and verifies a record, and checks that it
+// retires the ID old.
+func UnmarshalContinuityRecord(old peer.ID, val []byte) (*ContinuityRecord, peer.ID, error) {
+	rec := new(ContinuityRecord)
+	if err := json.Unmarshal(val, rec); err != nil {
+		return nil, "", err
+	}
+	oldID, newID, err := rec.Verify()
+	if err != nil {
+		return nil, "", err
+	}
+	if oldID != old {
+		return nil, "", fmt.Errorf("continuity record retires %s, not %s", oldID.Pretty(), old.Pretty())
+	}
+	return rec, newID, nil
+}
+
+func validateContinuityRecord(key string, val []byte) error {
+	id := strings.TrimPrefix(key, "/"+ContinuityNamespace+"/")
+	if id == key {
+		return fmt.Errorf("invalid continuity record key %q", key)
+	}
+	_, _, err := UnmarshalContinuityRecord(peer.ID(id), val)
+	return err
+}
+
+// selectContinuityRecord picks the valid rotation with the earliest time,
+// ordering rotations at the same time by their encoding, so that all peers
+// pick the same record. The time is set by the signers: this doesn't tell a
+// genuine rotation from one signed with a compromised retired key, which is
+// left to the peers following the records, see LookupRotation.
+func selectContinuityRecord(key string, vals [][]byte) (int, error) {
+	id := strings.TrimPrefix(key, "/"+ContinuityNamespace+"/")
+	best := -1
+	var bestTime time.Time
+	for i, val := range vals {
+		rec, _, err := UnmarshalContinuityRecord(peer.ID(id), val)
+		if err != nil {
+			continue
+		}
+		if best == -1 || rec.Rotated.Before(bestTime) ||
+			(rec.Rotated.Equal(bestTime) && bytes.Compare(val, vals[best]) < 0) {
+			best, bestTime = i, rec.Rotated
+		}
+	}
+	if best == -1 {
+		return 0, errors.New("no usable continuity record")
+	}
+	return best, nil
+}
+
+func init() {
+	err := RegisterRecordNamespace(ContinuityNamespace, RecordNamespace{
+		Validator: &record.ValidChecker{Func: validateContinuityRecord},
+		Selector:  selectContinuityRecord,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GetContinuityRecords returns the records of the rotations of the node's
+// identity, oldest first.
+func GetContinuityRecords(r repo.Repo) ([]*ContinuityRecord, error) {
+	val, err := r.Datastore().Get(continuityKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("continuity records are not stored as bytes")
+	}
+	var recs []*ContinuityRecord
+	if err := json.Unmarshal(b, &recs); err != nil {
+		return nil, err
+	}
+	return recs, nil
+}
+
+// AddContinuityRecord stores the record of a rotation of the node's identity.
+func AddContinuityRecord(r repo.Repo, rec *ContinuityRecord) error {
+	recs, err := GetContinuityRecords(r)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(append(recs, rec))
+	if err != nil {
+		return err
+	}
+	return r.Datastore().Put(continuityKey, b)
+}
+
+// LookupRotation looks up the continuity records of the peer old in the
+// routing system, and records the rotation they hold. The rotation already
+// known for old, if any, is the only one accepted.
+func (n *IpfsNode) LookupRotation(ctx context.Context, old peer.ID) (*ContinuityRecord, peer.ID, error) {
+	recvd, err := n.Routing.GetValues(ctx, ContinuityRecordKey(old), DefaultRecordQuorum)
+	if err != nil {
+		return nil, "", err
+	}
+	vals := make([][]byte, 0, len(recvd))
+	for _, v := range recvd {
+		if v.Val != nil {
+			vals = append(vals, v.Val)
+		}
+	}
+
+	var known peer.ID
+	if v, err := n.Peerstore.Get(old, RotatedToKey); err == nil {
+		known, _ = v.(peer.ID)
+	}
+	rec, next, err := chooseRotation(old, known, vals)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := n.RecordRotation(old, next); err != nil {
+		return nil, "", err
+	}
+	return rec, next, nil
+}
+
+// chooseRotation returns the rotation of old recorded in vals. Anyone
+// holding a retired key can sign rotations to keys of their own, at any
+// time: a rotation other than the known one, if any, is rejected, and so
+// are records rotating to different IDs when none is known.
+func chooseRotation(old, known peer.ID, vals [][]byte) (*ContinuityRecord, peer.ID, error) {
+	var rec *ContinuityRecord
+	var next peer.ID
+	conflicting := false
+	for _, val := range vals {
+		r, to, err := UnmarshalContinuityRecord(old, val)
+		if err != nil {
+			continue
+		}
+		switch {
+		case known != "" && to != known:
+			conflicting = true
+		case rec == nil:
+			rec, next = r, to
+		case to != next:
+			return nil, "", ErrAmbiguousRotation
+		}
+	}
+	if rec == nil {
+		if conflicting {
+			return nil, "", ErrConflictingRotation
+		}
+		return nil, "", errors.New("no usable continuity record")
+	}
+	return rec, next, nil
+}
+
+// RecordRotation notes in the peerstore that the peer old rotated its
+// identity to next. The first rotation learnt is final: it returns
+// ErrConflictingRotation if old is known to have rotated to another ID.
+func (n *IpfsNode) RecordRotation(old, next peer.ID) error {
+	if v, err := n.Peerstore.Get(old, RotatedToKey); err == nil {
+		if known, ok := v.(peer.ID); ok && known != next {
+			return ErrConflictingRotation
+		}
+	}
+	n.Peerstore.Put(old, RotatedToKey, next)
+	n.Peerstore.Put(next, RotatedFromKey, old)
+	return nil
+}
+
+// publishContinuityRecords puts the records of the node's past identities in
+// the routing system, so that peers still knowing them find the current one.
+func (n *IpfsNode) publishContinuityRecords(ctx context.Context) {
+	recs, err := GetContinuityRecords(n.Repo)
+	if err != nil {
+		log.Error("reading continuity records: ", err)
+		return
+	}
+
+	for _, rec := range recs {
+		old, _, err := rec.Verify()
+		if err != nil {
+			log.Error("invalid continuity record: ", err)
+			continue
+		}
+		b, err := json.Marshal(rec)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if err := n.Routing.PutValue(ctx, ContinuityRecordKey(old), b); err != nil {
+			log.Warningf("publishing continuity record of %s: %s", old.Pretty(), err)
+		}
+	}
+}
+
+// setupContinuityRepublisher republishes the continuity records of the node
+// until it closes, the first time once the node had time to bootstrap.
+func (n *IpfsNode) setupContinuityRepublisher() error {
+	recs, err := GetContinuityRecords(n.Repo)
+	if err != nil || len(recs) == 0 {
+		return err
+	}
+
+	n.Process().Go(func(proc goprocess.Process) {
+		ctx := goprocessctx.OnClosingContext(proc)
+		wait := continuityInitialDelay
+		for {
+			select {
+			case <-time.After(wait):
+			case <-proc.Closing():
+				return
+			}
+			n.publishContinuityRecords(ctx)
+			wait = ContinuityRepublishInterval
+		}
+	})
+	return nil
+}
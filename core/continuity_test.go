@@ -0,0 +1,129 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestContinuityRecord(t *testing.T) {
+	oldSK, oldPK, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSK, newPK, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID, _ := peer.IDFromPublicKey(oldPK)
+	newID, _ := peer.IDFromPublicKey(newPK)
+
+	rec, err := NewContinuityRecord(oldSK, newSK, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, next, err := UnmarshalContinuityRecord(oldID, val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != newID {
+		t.Fatalf("expected the record to rotate to %s, got %s", newID, next)
+	}
+	if err := validateContinuityRecord(ContinuityRecordKey(oldID), val); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateContinuityRecord(ContinuityRecordKey(newID), val); err == nil {
+		t.Fatal("expected a record stored under another peer ID to be rejected")
+	}
+
+	// a record the new key didn't sign
+	forged := *rec
+	forged.NewSignature = rec.OldSignature
+	if _, _, err := forged.Verify(); err != ErrContinuitySignature {
+		t.Fatalf("expected a signature error, got %v", err)
+	}
+
+	// the rotation time is signed
+	forged = *rec
+	forged.Rotated = rec.Rotated.Add(time.Hour)
+	if _, _, err := forged.Verify(); err != ErrContinuitySignature {
+		t.Fatalf("expected a signature error, got %v", err)
+	}
+
+	// fields can't be moved from one key to the other
+	forged = *rec
+	forged.OldPubKey = append(append([]byte{}, rec.OldPubKey...), rec.NewPubKey[0])
+	forged.NewPubKey = rec.NewPubKey[1:]
+	if bytes.Equal(forged.payload(), rec.payload()) {
+		t.Fatal("expected records with other keys to have another payload")
+	}
+
+	// a later rotation, signed by whoever compromised the old key, doesn't
+	// replace the first one
+	otherSK, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later, err := NewContinuityRecord(oldSK, otherSK, rec.Rotated.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	laterVal, _ := json.Marshal(later)
+	forgedVal, _ := json.Marshal(&forged)
+	i, err := selectContinuityRecord(ContinuityRecordKey(oldID), [][]byte{laterVal, forgedVal, val})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != 2 {
+		t.Fatalf("expected the first valid record to be selected, got %d", i)
+	}
+}
+
+func TestChooseRotationAnchorsOnKnownRotation(t *testing.T) {
+	oldSK, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID, _ := peer.IDFromPrivateKey(oldSK)
+
+	rotation := func(rotated time.Time) ([]byte, peer.ID) {
+		sk, _, err := testutil.RandTestKeyPair(512)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec, err := NewContinuityRecord(oldSK, sk, rotated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		val, _ := json.Marshal(rec)
+		id, _ := peer.IDFromPrivateKey(sk)
+		return val, id
+	}
+	now := time.Now()
+	genuine, genuineID := rotation(now)
+	// signed with the compromised retired key, and backdated
+	backdated, _ := rotation(now.Add(-time.Hour))
+
+	if _, next, err := chooseRotation(oldID, genuineID, [][]byte{backdated, genuine}); err != nil || next != genuineID {
+		t.Fatalf("expected the known rotation, got %s, %v", next, err)
+	}
+	if _, _, err := chooseRotation(oldID, genuineID, [][]byte{backdated}); err != ErrConflictingRotation {
+		t.Fatalf("expected %s, got %v", ErrConflictingRotation, err)
+	}
+	if _, _, err := chooseRotation(oldID, "", [][]byte{backdated, genuine}); err != ErrAmbiguousRotation {
+		t.Fatalf("expected %s, got %v", ErrAmbiguousRotation, err)
+	}
+	if _, next, err := chooseRotation(oldID, "", [][]byte{genuine, genuine}); err != nil || next != genuineID {
+		t.Fatalf("expected the only rotation, got %s, %v", next, err)
+	}
+}
@@ -24,8 +24,6 @@ import (
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
 	exchange "github.com/ipfs/go-ipfs/exchange"
-	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
-	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
 	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	mount "github.com/ipfs/go-ipfs/fuse/mount"
@@ -35,10 +33,13 @@ import (
 	ipnsrp "github.com/ipfs/go-ipfs/namesys/republisher"
 	path "github.com/ipfs/go-ipfs/path"
 	pin "github.com/ipfs/go-ipfs/pin"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+	pinsync "github.com/ipfs/go-ipfs/pin/pinsync"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	nilrouting "github.com/ipfs/go-ipfs/routing/none"
 	offroute "github.com/ipfs/go-ipfs/routing/offline"
+	sharing "github.com/ipfs/go-ipfs/sharing"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 
 	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
@@ -76,6 +77,7 @@ const IpnsValidatorTag = "ipns"
 const kSizeBlockstoreWriteCache = 100
 const kReprovideFrequency = time.Hour * 12
 const discoveryConnTimeout = time.Second * 30
+const kAccessTimeGranularity = time.Hour
 
 var log = logging.Logger("core")
 
@@ -120,9 +122,18 @@ type IpfsNode struct {
 	Discovery  discovery.Service
 	FilesRoot  *mfs.Root
 
+	AccessTimes *bstore.AccessTracker // block access times, nil unless enabled in the config
+	Changes     *bstore.ChangeFeed    // the log of added blocks, nil unless enabled in the config
+	GCMarks     *gc.MarkCache         // the marked set of the last gc run
+	PinJobs     *PinQueue             // background pins, nil unless running as a daemon
+
 	// Online
 	PeerHost     p2phost.Host        // the network host (server+client)
 	Bootstrapper io.Closer           // the periodic bootstrapper
+	Peering      *PeeringService     // the peers the node stays connected to
+	PinProviders *PinProviders       // the peers which served the pins
+	P2PHTTP      *P2PHTTPService     // the http service exposed to other peers
+	ProtoStats   *ProtocolStats      // the streams of each protocol
 	Routing      routing.IpfsRouting // the routing system. recommend ipfs-dht
 	Exchange     exchange.Interface  // the block exchange + strategy (bitswap)
 	Namesys      namesys.NameSystem  // the name system, resolves paths to hashes
@@ -130,13 +141,20 @@ type IpfsNode struct {
 	Reprovider   *rp.Reprovider // the value reprovider system
 	IpnsRepub    *ipnsrp.Republisher
 
+	// AnnounceFilter decides which blocks are announced, all if nil
+	AnnounceFilter exchange.AnnounceFilter
+
 	Floodsub *floodsub.PubSub
+	PinSync  *pinsync.Syncer // pinset publishing and mirroring, needs pubsub
+	Sharing  *sharing.Sharer // private sharing groups, needs pubsub
 
 	proc goprocess.Process
 	ctx  context.Context
 
 	mode         mode
 	localModeSet bool
+	lan          lanMode
+	services     services
 }
 
 // Mounts defines what the node's mount state is. This should
@@ -177,6 +195,18 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		n.Reporter = metrics.NewBandwidthCounter()
 	}
 
+	if len(cfg.Swarm.BandwidthSchedule) > 0 {
+		// the schedule is enforced by the reporter, it needs one
+		rep := n.Reporter
+		if rep == nil {
+			rep = metrics.NewBandwidthCounter()
+		}
+		n.Reporter, err = newBandwidthLimiter(cfg.Swarm.BandwidthSchedule, rep)
+		if err != nil {
+			return err
+		}
+	}
+
 	tpt := makeSmuxTransport(mplex)
 
 	swarmkey, err := n.Repo.SwarmKey()
@@ -211,8 +241,16 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		}()
 	}
 
+	addrsFactory, err := makeAddrsFactory(cfg.Addresses)
+	if err != nil {
+		return err
+	}
+
 	peerhost, err := hostOption(ctx, n.Identity, n.Peerstore, n.Reporter,
-		addrfilter, tpt, protec, &ConstructPeerHostOpts{DisableNatPortMap: cfg.Swarm.DisableNatPortMap})
+		addrfilter, tpt, protec, &ConstructPeerHostOpts{
+			DisableNatPortMap: cfg.Swarm.DisableNatPortMap,
+			AddrsFactory:      addrsFactory,
+		})
 	if err != nil {
 		return err
 	}
@@ -228,18 +266,12 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 
 	n.Reprovider = rp.NewReprovider(n.Routing, n.Blockstore)
 
-	if cfg.Reprovider.Interval != "0" {
-		interval := kReprovideFrequency
-		if cfg.Reprovider.Interval != "" {
-			dur, err := time.ParseDuration(cfg.Reprovider.Interval)
-			if err != nil {
-				return err
-			}
-
-			interval = dur
-		}
+	if err := n.setupAnnounceFilter(cfg.Reprovider); err != nil {
+		return err
+	}
 
-		go n.Reprovider.ProvideEvery(ctx, interval)
+	if err := n.startReprovider(ctx, cfg.Reprovider); err != nil {
+		return err
 	}
 
 	if pubsub {
@@ -257,6 +289,33 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		}
 	}
 
+	if cfg.Swarm.LanMode {
+		if err := n.SetLanMode(true); err != nil {
+			return err
+		}
+	}
+
+	peers, err := PeeringPeers(cfg)
+	if err != nil {
+		return err
+	}
+	n.Peering = NewPeeringService(n.PeerHost)
+	for _, pi := range peers {
+		n.Peering.AddPeer(pi)
+	}
+	n.Peering.Start(ctx)
+
+	// fetch the pins from the peers which had them before
+	n.PinProviders, err = LoadPinProviders(n.Repo, n.PeerHost)
+	if err != nil {
+		return err
+	}
+	if bs := n.Bitswap(); bs != nil {
+		bs.SetPreferredPeers(n.PinProviders.Preferred)
+	}
+	n.PinProviders.Start(ctx)
+	n.P2PHTTP = NewP2PHTTPService(n.PeerHost)
+
 	return n.Bootstrap(DefaultBootstrapConfig)
 }
 
@@ -316,29 +375,44 @@ func (n *IpfsNode) HandlePeerFound(p pstore.PeerInfo) {
 func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost.Host, routingOption RoutingOption) error {
 	// setup diagnostics service
 	n.Ping = ping.NewPingService(host)
+	n.ProtoStats = NewProtocolStats()
+	host.Network().Notify(n.ProtoStats)
 
 	// setup routing service
 	r, err := routingOption(ctx, host, n.Repo.Datastore())
 	if err != nil {
 		return err
 	}
-	n.Routing = r
+	// the routing and the exchange forward to the DHT and bitswap, which
+	// RestartService replaces
+	n.services.routing = newRestartableRouting(r)
+	n.services.routingOption = routingOption
+	n.Routing = n.services.routing
 
 	// Wrap standard peer host with routing system to allow unknown peer lookups
 	n.PeerHost = rhost.Wrap(host, n.Routing)
 
 	// setup exchange service
-	const alwaysSendToPeer = true // use YesManStrategy
-	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.Routing)
-	n.Exchange = bitswap.New(ctx, n.Identity, bitswapNetwork, n.Blockstore, alwaysSendToPeer)
+	n.services.exchange = newRestartableExchange(n.newBitswap(ctx))
+	n.Exchange = n.services.exchange
 
 	size, err := n.getCacheSize()
 	if err != nil {
 		return err
 	}
 
+	negTTL, err := n.getNegativeCacheTTL()
+	if err != nil {
+		return err
+	}
+
+	opts, err := n.ResolveOptions()
+	if err != nil {
+		return err
+	}
+
 	// setup name system
-	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), size)
+	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), size, negTTL, opts)
 
 	// setup ipns republishing
 	err = n.setupIpnsRepublisher()
@@ -346,6 +420,12 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 		return err
 	}
 
+	// keep the records of the retired identities findable
+	err = n.setupContinuityRepublisher()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -366,6 +446,52 @@ func (n *IpfsNode) getCacheSize() (int, error) {
 	return cs, nil
 }
 
+// getNegativeCacheTTL returns how long failed dns resolutions are cached
+func (n *IpfsNode) getNegativeCacheTTL() (time.Duration, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return 0, err
+	}
+
+	if cfg.Ipns.ResolveNegativeCacheTTL == "" {
+		return namesys.DefaultNegativeCacheTTL, nil
+	}
+	d, err := time.ParseDuration(cfg.Ipns.ResolveNegativeCacheTTL)
+	if err != nil {
+		return 0, fmt.Errorf("failure to parse config setting IPNS.ResolveNegativeCacheTTL: %s", err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("cannot specify negative resolve negative cache ttl")
+	}
+	return d, nil
+}
+
+// ResolveOptions returns the options of the resolution of ipns names set in
+// the config.
+func (n *IpfsNode) ResolveOptions() (namesys.ResolveOptions, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return namesys.ResolveOptions{}, err
+	}
+
+	if cfg.Ipns.ResolveQuorum < 0 {
+		return namesys.ResolveOptions{}, fmt.Errorf("cannot specify negative resolve quorum")
+	}
+	opts := namesys.ResolveOptions{Quorum: cfg.Ipns.ResolveQuorum}
+
+	if cfg.Ipns.ResolveTimeout != "" {
+		d, err := time.ParseDuration(cfg.Ipns.ResolveTimeout)
+		if err != nil {
+			return namesys.ResolveOptions{}, fmt.Errorf("failure to parse config setting IPNS.ResolveTimeout: %s", err)
+		}
+		if d < 0 {
+			return namesys.ResolveOptions{}, fmt.Errorf("cannot specify negative resolve timeout")
+		}
+		opts.Timeout = d
+	}
+	return opts, nil
+}
+
 func (n *IpfsNode) setupIpnsRepublisher() error {
 	cfg, err := n.Repo.Config()
 	if err != nil {
@@ -389,7 +515,7 @@ func (n *IpfsNode) setupIpnsRepublisher() error {
 	}
 
 	if cfg.Ipns.RecordLifetime != "" {
-		d, err := time.ParseDuration(cfg.Ipns.RepublishPeriod)
+		d, err := time.ParseDuration(cfg.Ipns.RecordLifetime)
 		if err != nil {
 			return fmt.Errorf("failure to parse config setting IPNS.RecordLifetime: %s", err)
 		}
@@ -440,6 +566,10 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, n.Exchange)
 	}
 
+	if n.PinProviders != nil {
+		closers = append(closers, n.PinProviders)
+	}
+
 	if n.Mounts.Ipfs != nil && !n.Mounts.Ipfs.IsActive() {
 		closers = append(closers, mount.Closer(n.Mounts.Ipfs))
 	}
@@ -447,8 +577,8 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, mount.Closer(n.Mounts.Ipns))
 	}
 
-	if dht, ok := n.Routing.(*dht.IpfsDHT); ok {
-		closers = append(closers, dht.Process())
+	if d := n.DHT(); d != nil {
+		closers = append(closers, d.Process())
 	}
 
 	if n.Blocks != nil {
@@ -605,13 +735,12 @@ func (n *IpfsNode) loadBootstrapPeers() ([]pstore.PeerInfo, error) {
 }
 
 func (n *IpfsNode) loadFilesRoot() error {
-	dsk := ds.NewKey("/local/filesroot")
 	pf := func(ctx context.Context, c *cid.Cid) error {
-		return n.Repo.Datastore().Put(dsk, c.Bytes())
+		return SetFilesRoot(n.Repo.Datastore(), c)
 	}
 
 	var nd *merkledag.ProtoNode
-	val, err := n.Repo.Datastore().Get(dsk)
+	val, err := n.Repo.Datastore().Get(FilesRootKey)
 
 	switch {
 	case err == ds.ErrNotFound || val == nil:
@@ -646,6 +775,15 @@ func (n *IpfsNode) loadFilesRoot() error {
 		return err
 	}
 
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+	mr.Prefix, err = ImportPrefix(&cfg.Import)
+	if err != nil {
+		return err
+	}
+
 	n.FilesRoot = mr
 	return nil
 }
@@ -670,7 +808,17 @@ func (n *IpfsNode) SetupOfflineRouting() error {
 		return err
 	}
 
-	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), size)
+	negTTL, err := n.getNegativeCacheTTL()
+	if err != nil {
+		return err
+	}
+
+	opts, err := n.ResolveOptions()
+	if err != nil {
+		return err
+	}
+
+	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), size, negTTL, opts)
 
 	return nil
 }
@@ -693,6 +841,20 @@ func loadPrivateKey(cfg *config.Identity, id peer.ID) (ic.PrivKey, error) {
 	return sk, nil
 }
 
+// CheckIdentity checks that the peer ID of ident is valid, and that it matches
+// its private key.
+func CheckIdentity(ident *config.Identity) error {
+	if ident.PeerID == "" {
+		return errors.New("identity was not set in config (was 'ipfs init' run?)")
+	}
+	id, err := peer.IDB58Decode(ident.PeerID)
+	if err != nil {
+		return fmt.Errorf("invalid peer ID in config: %s", err)
+	}
+	_, err = loadPrivateKey(ident, id)
+	return err
+}
+
 func listenAddresses(cfg *config.Config) ([]ma.Multiaddr, error) {
 	var listen []ma.Multiaddr
 	for _, addr := range cfg.Addresses.Swarm {
@@ -708,6 +870,7 @@ func listenAddresses(cfg *config.Config) ([]ma.Multiaddr, error) {
 
 type ConstructPeerHostOpts struct {
 	DisableNatPortMap bool
+	AddrsFactory      p2pbhost.AddrsFactory
 }
 
 type HostOption func(ctx context.Context, id peer.ID, ps pstore.Peerstore, bwr metrics.Reporter, fs []*net.IPNet, tpt smux.Transport, protc ipnet.Protector, opts *ConstructPeerHostOpts) (p2phost.Host, error)
@@ -733,6 +896,9 @@ func constructPeerHost(ctx context.Context, id peer.ID, ps pstore.Peerstore, bwr
 	if !opts.DisableNatPortMap {
 		hostOpts = append(hostOpts, p2pbhost.NATPortMap)
 	}
+	if opts.AddrsFactory != nil {
+		hostOpts = append(hostOpts, opts.AddrsFactory)
+	}
 
 	host := p2pbhost.New(network, hostOpts...)
 
@@ -770,15 +936,13 @@ func startListening(ctx context.Context, host p2phost.Host, cfg *config.Config)
 
 func constructDHTRouting(ctx context.Context, host p2phost.Host, dstore repo.Datastore) (routing.IpfsRouting, error) {
 	dhtRouting := dht.NewDHT(ctx, host, dstore)
-	dhtRouting.Validator[IpnsValidatorTag] = namesys.IpnsRecordValidator
-	dhtRouting.Selector[IpnsValidatorTag] = namesys.IpnsSelectorFunc
+	setRecordNamespaces(dhtRouting.Validator, dhtRouting.Selector)
 	return dhtRouting, nil
 }
 
 func constructClientDHTRouting(ctx context.Context, host p2phost.Host, dstore repo.Datastore) (routing.IpfsRouting, error) {
 	dhtRouting := dht.NewDHTClient(ctx, host, dstore)
-	dhtRouting.Validator[IpnsValidatorTag] = namesys.IpnsRecordValidator
-	dhtRouting.Selector[IpnsValidatorTag] = namesys.IpnsSelectorFunc
+	setRecordNamespaces(dhtRouting.Validator, dhtRouting.Selector)
 	return dhtRouting, nil
 }
 
@@ -5,6 +5,7 @@ import (
 
 	core "github.com/ipfs/go-ipfs/core"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
 	ipfspath "github.com/ipfs/go-ipfs/path"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
@@ -13,24 +14,62 @@ import (
 
 type CoreAPI struct {
 	node *core.IpfsNode
+
+	// dag, if set, is used instead of the DAG service of the node
+	dag mdag.DAGService
 }
 
 func NewCoreAPI(n *core.IpfsNode) coreiface.CoreAPI {
-	api := &CoreAPI{n}
+	api := &CoreAPI{node: n}
 	return api
 }
 
+// NewCoreAPIWithDAG returns an API reading and writing the objects through
+// dserv instead of the DAG service of the node, e.g. to limit what it
+// fetches.
+func NewCoreAPIWithDAG(n *core.IpfsNode, dserv mdag.DAGService) coreiface.CoreAPI {
+	return &CoreAPI{node: n, dag: dserv}
+}
+
+// dagService returns the DAG service the API reads and writes objects with.
+func (api *CoreAPI) dagService() mdag.DAGService {
+	if api.dag != nil {
+		return api.dag
+	}
+	return api.node.DAG
+}
+
+// resolver returns the resolver of the paths in the DAG service of the API.
+func (api *CoreAPI) resolver() *ipfspath.Resolver {
+	if api.dag != nil {
+		return ipfspath.NewBasicResolver(api.dag)
+	}
+	return api.node.Resolver
+}
+
 func (api *CoreAPI) Unixfs() coreiface.UnixfsAPI {
 	return (*UnixfsAPI)(api)
 }
 
+func (api *CoreAPI) Dag() coreiface.DagAPI {
+	return (*DagAPI)(api)
+}
+
+func (api *CoreAPI) Stream() coreiface.StreamAPI {
+	return (*StreamAPI)(api)
+}
+
+func (api *CoreAPI) Dht() coreiface.DhtAPI {
+	return (*DhtAPI)(api)
+}
+
 func (api *CoreAPI) ResolveNode(ctx context.Context, p coreiface.Path) (coreiface.Node, error) {
 	p, err := api.ResolvePath(ctx, p)
 	if err != nil {
 		return nil, err
 	}
 
-	node, err := api.node.DAG.Get(ctx, p.Cid())
+	node, err := api.dagService().Get(ctx, p.Cid())
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +83,7 @@ func (api *CoreAPI) ResolvePath(ctx context.Context, p coreiface.Path) (coreifac
 	}
 
 	r := &ipfspath.Resolver{
-		DAG:         api.node.DAG,
+		DAG:         api.dagService(),
 		ResolveOnce: uio.ResolveUnixfsOnce,
 	}
 
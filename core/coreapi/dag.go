@@ -0,0 +1,58 @@
+package coreapi
+
+import (
+	"context"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	ipfspath "github.com/ipfs/go-ipfs/path"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+type DagAPI CoreAPI
+
+func (api *DagAPI) Resolve(ctx context.Context, p coreiface.Path) (*coreiface.DagResolution, error) {
+	p2, err := core.ResolveIPNS(ctx, api.node.Namesys, ipfspath.FromString(p.String()))
+	if err == core.ErrNoNamesys {
+		return nil, coreiface.ErrOffline
+	} else if err != nil {
+		return nil, err
+	}
+
+	nodes, rem, err := (*CoreAPI)(api).resolver().ResolveBlocks(ctx, p2)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*cid.Cid, len(nodes))
+	for i, nd := range nodes {
+		blocks[i] = nd.Cid()
+	}
+
+	return &coreiface.DagResolution{
+		Cid:     blocks[len(blocks)-1],
+		RemPath: rem,
+		Blocks:  blocks,
+	}, nil
+}
+
+func (api *DagAPI) Get(ctx context.Context, p coreiface.Path) (coreiface.Node, error) {
+	res, err := api.Resolve(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.RemPath) > 0 {
+		return nil, coreiface.ErrNotBlock
+	}
+
+	return (*CoreAPI)(api).dagService().Get(ctx, res.Cid)
+}
+
+func (api *DagAPI) Put(ctx context.Context, nd coreiface.Node) (coreiface.Path, error) {
+	c, err := (*CoreAPI)(api).dagService().Add(nd)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCid(c), nil
+}
@@ -0,0 +1,55 @@
+package coreapi
+
+import (
+	"context"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+
+	dht "gx/ipfs/QmQcRLisUbREko56ThfgzdBorMGNfNjgqzvwuPPr1jFw6A/go-libp2p-kad-dht"
+)
+
+type DhtAPI CoreAPI
+
+func (api *DhtAPI) Get(ctx context.Context, key string, opts coreiface.DhtOptions) ([]byte, error) {
+	d, err := api.dht()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := core.LookupRecordNamespace(key); err != nil {
+		return nil, err
+	}
+	return core.GetRecord(ctx, d, key, recordOptions(opts))
+}
+
+func (api *DhtAPI) Put(ctx context.Context, key string, value []byte, opts coreiface.DhtOptions) error {
+	d, err := api.dht()
+	if err != nil {
+		return err
+	}
+
+	if err := core.CheckRecordPut(key, value); err != nil {
+		return err
+	}
+	return core.PutRecord(ctx, d, key, value, recordOptions(opts))
+}
+
+func (api *DhtAPI) dht() (*dht.IpfsDHT, error) {
+	if !api.node.OnlineMode() {
+		return nil, coreiface.ErrNotOnline
+	}
+	d := api.node.DHT()
+	if d == nil {
+		return nil, coreiface.ErrNotDHT
+	}
+	return d, nil
+}
+
+func recordOptions(opts coreiface.DhtOptions) core.RecordOptions {
+	return core.RecordOptions{
+		Quorum:  opts.Quorum,
+		Timeout: opts.Timeout,
+		Retries: opts.Retries,
+	}
+}
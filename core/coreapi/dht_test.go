@@ -0,0 +1,90 @@
+package coreapi_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	mock "github.com/ipfs/go-ipfs/core/mock"
+
+	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
+	mocknet "gx/ipfs/QmRai5yZNL67pWCoznW7sBdFnqZrFULuJ5w8KhmRyhdgN4/go-libp2p/p2p/net/mock"
+	record "gx/ipfs/QmWYCqr6UDqqD1bfRybaAPtbAqcN3TSJpveaBXMwbQ3ePZ/go-libp2p-record"
+)
+
+func TestDht(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := core.RegisterRecordNamespace("coreapi-test", core.RecordNamespace{
+		Validator: &record.ValidChecker{
+			Func: func(string, []byte) error { return nil },
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mn := mocknet.New(ctx)
+	var nodes []*core.IpfsNode
+	for i := 0; i < 3; i++ {
+		nd, err := core.NewNode(ctx, &core.BuildCfg{
+			Online: true,
+			Host:   mock.MockHostOption(mn),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, nd)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	bsinf := core.BootstrapConfigWithPeers([]pstore.PeerInfo{
+		nodes[0].Peerstore.PeerInfo(nodes[0].Identity),
+	})
+	for _, n := range nodes[1:] {
+		if err := n.Bootstrap(bsinf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writer := coreapi.NewCoreAPI(nodes[1]).Dht()
+	reader := coreapi.NewCoreAPI(nodes[2]).Dht()
+
+	if err := writer.Put(ctx, "/unknown/key", []byte("value"), coreiface.DhtOptions{}); err != core.ErrUnknownNamespace {
+		t.Fatalf("expected %q, got %v", core.ErrUnknownNamespace, err)
+	}
+
+	// the retries give the nodes time to fill their routing tables
+	opts := coreiface.DhtOptions{
+		Quorum:  1,
+		Timeout: 5 * time.Second,
+		Retries: 5,
+	}
+	if err := writer.Put(ctx, "/coreapi-test/key", []byte("value"), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := reader.Get(ctx, "/coreapi-test/key", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(val, []byte("value")) {
+		t.Fatalf("expected value, got %q", val)
+	}
+
+	offline, err := core.NewNode(ctx, &core.BuildCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = coreapi.NewCoreAPI(offline).Dht().Get(ctx, "/coreapi-test/key", coreiface.DhtOptions{})
+	if err != coreiface.ErrNotOnline {
+		t.Fatalf("expected %q, got %v", coreiface.ErrNotOnline, err)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	ipld "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
 )
 
 type Path interface {
@@ -28,6 +30,9 @@ type Reader interface {
 
 type CoreAPI interface {
 	Unixfs() UnixfsAPI
+	Dag() DagAPI
+	Stream() StreamAPI
+	Dht() DhtAPI
 	ResolvePath(context.Context, Path) (Path, error)
 	ResolveNode(context.Context, Path) (Node, error)
 }
@@ -38,6 +43,92 @@ type UnixfsAPI interface {
 	Ls(context.Context, Path) ([]*Link, error)
 }
 
+// DagAPI specifies the interface to IPLD
+type DagAPI interface {
+	// Resolve resolves the path through IPLD blocks, reporting the block it
+	// ends in, the path remaining inside that block and every block that was
+	// traversed on the way there.
+	Resolve(context.Context, Path) (*DagResolution, error)
+
+	// Get resolves the path and returns the node it points to. The path must
+	// end on a block boundary.
+	Get(context.Context, Path) (Node, error)
+
+	// Put stores the node and returns its path.
+	Put(context.Context, Node) (Path, error)
+}
+
+// DagResolution is the result of resolving a path through the DAG.
+type DagResolution struct {
+	// Cid is the block the path terminates in
+	Cid *cid.Cid
+
+	// RemPath is the path remaining to be resolved within the block
+	RemPath []string
+
+	// Blocks lists the blocks traversed, starting with the root
+	Blocks []*cid.Cid
+}
+
+// StreamAPI lets applications speak their own protocols with other peers,
+// over the node's libp2p connections. Application protocols live under
+// "/x/", e.g. "/x/chat/1.0.0", apart from the protocols of the node.
+type StreamAPI interface {
+	// SetHandler handles the streams other peers open for the protocol,
+	// replacing the handler set before, if any.
+	SetHandler(protocol string, handler StreamHandler) error
+
+	// RemoveHandler stops handling the protocol.
+	RemoveHandler(protocol string) error
+
+	// Open connects to the peer if needed, and opens a stream speaking the
+	// protocol.
+	Open(ctx context.Context, p peer.ID, protocol string) (Stream, error)
+
+	// Protocols lists the protocols the node handles, its own included.
+	Protocols() ([]string, error)
+}
+
+// Stream is a bidirectional channel to another peer.
+type Stream interface {
+	io.ReadWriteCloser
+
+	// Protocol is the protocol spoken on the stream.
+	Protocol() string
+
+	// RemotePeer is the peer at the other end of the stream.
+	RemotePeer() peer.ID
+}
+
+// StreamHandler handles a stream opened by another peer. It owns the stream
+// and must close it.
+type StreamHandler func(Stream)
+
+// DhtAPI reads and writes records in the DHT. Keys are of the form
+// /<keytype>/<key bytes>, e.g. "/ipns/" + string(peerID), and only the
+// keytypes the node has a validator for are allowed.
+type DhtAPI interface {
+	// Get returns the best record found for the key.
+	Get(ctx context.Context, key string, opts DhtOptions) ([]byte, error)
+
+	// Put checks the record against the validator of its keytype, and writes
+	// it to the peers closest to the key.
+	Put(ctx context.Context, key string, value []byte, opts DhtOptions) error
+}
+
+// DhtOptions tune DHT reads and writes. The zero value keeps the defaults.
+type DhtOptions struct {
+	// Quorum is how many records Get collects before picking the best one,
+	// 16 if 0. It is how many peers Put must send the record to, none if 0.
+	Quorum int
+
+	// Timeout bounds each attempt, 0 for the DHT defaults.
+	Timeout time.Duration
+
+	// Retries is how many times a failed attempt is retried.
+	Retries int
+}
+
 // type ObjectAPI interface {
 // 	New() (cid.Cid, Object)
 // 	Get(string) (Object, error)
@@ -62,3 +153,7 @@ type UnixfsAPI interface {
 
 var ErrIsDir = errors.New("object is a directory")
 var ErrOffline = errors.New("can't resolve, ipfs node is offline")
+var ErrNotBlock = errors.New("path does not end on a block boundary")
+var ErrNotOnline = errors.New("ipfs node is not online")
+var ErrProtocolName = errors.New("application protocols must start with /x/")
+var ErrNotDHT = errors.New("routing service is not a DHT")
@@ -0,0 +1,79 @@
+package coreapi
+
+import (
+	"context"
+	"strings"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+
+	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
+	inet "gx/ipfs/QmVHSBsn8LEeay8m5ERebgUVuhzw838PsyTttCmP6GMJkg/go-libp2p-net"
+	pro "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// appProtocolPrefix namespaces the protocols of applications, so that they
+// can't take over the protocols of the node.
+const appProtocolPrefix = "/x/"
+
+type StreamAPI CoreAPI
+
+func (api *StreamAPI) SetHandler(protocol string, handler coreiface.StreamHandler) error {
+	if err := api.check(protocol); err != nil {
+		return err
+	}
+
+	api.node.PeerHost.SetStreamHandler(pro.ID(protocol), func(s inet.Stream) {
+		handler(&stream{s})
+	})
+	return nil
+}
+
+func (api *StreamAPI) RemoveHandler(protocol string) error {
+	if err := api.check(protocol); err != nil {
+		return err
+	}
+
+	api.node.PeerHost.RemoveStreamHandler(pro.ID(protocol))
+	return nil
+}
+
+func (api *StreamAPI) Open(ctx context.Context, p peer.ID, protocol string) (coreiface.Stream, error) {
+	if err := api.check(protocol); err != nil {
+		return nil, err
+	}
+
+	if err := api.node.PeerHost.Connect(ctx, pstore.PeerInfo{ID: p}); err != nil {
+		return nil, err
+	}
+	s, err := api.node.PeerHost.NewStream(ctx, p, pro.ID(protocol))
+	if err != nil {
+		return nil, err
+	}
+	return &stream{s}, nil
+}
+
+func (api *StreamAPI) Protocols() ([]string, error) {
+	if api.node.PeerHost == nil {
+		return nil, coreiface.ErrNotOnline
+	}
+	return api.node.PeerHost.Mux().Protocols(), nil
+}
+
+func (api *StreamAPI) check(protocol string) error {
+	if api.node.PeerHost == nil {
+		return coreiface.ErrNotOnline
+	}
+	if !strings.HasPrefix(protocol, appProtocolPrefix) || len(protocol) == len(appProtocolPrefix) {
+		return coreiface.ErrProtocolName
+	}
+	return nil
+}
+
+// stream implements coreiface.Stream
+type stream struct {
+	inet.Stream
+}
+
+func (s *stream) Protocol() string    { return string(s.Stream.Protocol()) }
+func (s *stream) RemotePeer() peer.ID { return s.Conn().RemotePeer() }
@@ -0,0 +1,104 @@
+package coreapi_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	mock "github.com/ipfs/go-ipfs/core/mock"
+
+	mocknet "gx/ipfs/QmRai5yZNL67pWCoznW7sBdFnqZrFULuJ5w8KhmRyhdgN4/go-libp2p/p2p/net/mock"
+)
+
+func TestStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+	var nodes []*core.IpfsNode
+	for i := 0; i < 2; i++ {
+		nd, err := core.NewNode(ctx, &core.BuildCfg{
+			Online: true,
+			Host:   mock.MockHostOption(mn),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, nd)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := coreapi.NewCoreAPI(nodes[0]).Stream()
+	client := coreapi.NewCoreAPI(nodes[1]).Stream()
+
+	if err := server.SetHandler("/ipfs/bitswap", nil); err != coreiface.ErrProtocolName {
+		t.Fatalf("expected %q, got %v", coreiface.ErrProtocolName, err)
+	}
+
+	remote := make(chan string, 1)
+	err := server.SetHandler("/x/echo/1.0.0", func(s coreiface.Stream) {
+		defer s.Close()
+		remote <- s.RemotePeer().Pretty() + " " + s.Protocol()
+
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(s, buf); err != nil {
+			t.Error(err)
+			return
+		}
+		s.Write(buf)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protos, err := server.Protocols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, p := range protos {
+		found = found || p == "/x/echo/1.0.0"
+	}
+	if !found {
+		t.Fatalf("protocol not listed in %v", protos)
+	}
+
+	s, err := client.Open(ctx, nodes[0].Identity, "/x/echo/1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(s, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("expected the echo of hello, got %q", buf)
+	}
+
+	expected := nodes[1].Identity.Pretty() + " /x/echo/1.0.0"
+	if got := <-remote; got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	if err := server.RemoveHandler("/x/echo/1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	protos, err = server.Protocols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range protos {
+		if p == "/x/echo/1.0.0" {
+			t.Fatal("removed protocol still listed")
+		}
+	}
+}
@@ -32,7 +32,7 @@ func (api *UnixfsAPI) Cat(ctx context.Context, p coreiface.Path) (coreiface.Read
 		return nil, err
 	}
 
-	r, err := uio.NewDagReader(ctx, dagnode, api.node.DAG)
+	r, err := uio.NewDagReader(ctx, dagnode, (*CoreAPI)(api).dagService())
 	if err == uio.ErrIsDir {
 		return nil, coreiface.ErrIsDir
 	} else if err != nil {
@@ -48,7 +48,7 @@ func (api *UnixfsAPI) Ls(ctx context.Context, p coreiface.Path) ([]*coreiface.Li
 	}
 
 	var ndlinks []*node.Link
-	dir, err := uio.NewDirectoryFromNode(api.node.DAG, dagnode)
+	dir, err := uio.NewDirectoryFromNode((*CoreAPI)(api).dagService(), dagnode)
 	switch err {
 	case nil:
 		l, err := dir.Links(ctx)
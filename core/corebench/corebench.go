@@ -0,0 +1,429 @@
+// Package corebench measures the performance of ipfs on the local hardware.
+//
+// The benchmarks run nodes in memory, connected over a simulated network
+// (mocknet) with the given latency and bandwidth, and use content generated
+// from a seed. The same config gives the same topology and the same content
+// on every machine, so that the reports of two machines, or of two versions
+// of ipfs, compare.
+package corebench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	mock "github.com/ipfs/go-ipfs/core/mock"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	mocknet "gx/ipfs/QmRai5yZNL67pWCoznW7sBdFnqZrFULuJ5w8KhmRyhdgN4/go-libp2p/p2p/net/mock"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+)
+
+var log = logging.Logger("corebench")
+
+// The benchmarks.
+const (
+	// BenchAdd adds content to a node.
+	BenchAdd = "add"
+	// BenchPinFetch pins content the other nodes of the network have.
+	BenchPinFetch = "pin-fetch"
+	// BenchGC garbage collects the unpinned content of a node.
+	BenchGC = "gc"
+	// BenchGateway downloads content from the HTTP gateway of a node.
+	BenchGateway = "gateway"
+)
+
+// Benchmarks lists the benchmarks, in the order they run.
+var Benchmarks = []string{BenchAdd, BenchPinFetch, BenchGC, BenchGateway}
+
+var ErrUnknownBenchmark = errors.New("unknown benchmark")
+
+// Config describes the network and the content of the benchmarks.
+type Config struct {
+	// Nodes is the number of nodes of the network, all connected to each
+	// other. For pin-fetch, one of them fetches the content all the others
+	// have.
+	Nodes int
+
+	// Size is the size of the content of each run, in bytes.
+	Size int64
+	Runs int
+
+	// Latency and Bandwidth, in bytes per second, are the ones of the links
+	// between the nodes. A zero Bandwidth is unlimited.
+	Latency   time.Duration
+	Bandwidth float64
+
+	// Seed generates the content, each run adds different content.
+	Seed int64
+}
+
+// DefaultConfig is the config of 'ipfs benchmark' without options.
+var DefaultConfig = Config{
+	Nodes: 4,
+	Size:  1 << 20,
+	Runs:  5,
+	Seed:  1,
+}
+
+// Result are the timings of the runs of a benchmark.
+type Result struct {
+	Name string
+	Runs []time.Duration
+
+	Min    time.Duration
+	Median time.Duration
+	Max    time.Duration
+
+	// Throughput is the size of the content of a run over the median, in
+	// bytes per second.
+	Throughput float64
+}
+
+// Report is the outcome of Run, with the system the benchmarks ran on.
+type Report struct {
+	Version string
+	Commit  string
+	System  string
+	Golang  string
+	CPUs    int
+
+	Config  Config
+	Results []Result
+}
+
+// Change compares a result to the one of a baseline report.
+type Change struct {
+	Name     string
+	Baseline time.Duration
+	Median   time.Duration
+	// Change is the relative change of the median, e.g. 0.1 when the
+	// benchmark got 10% slower.
+	Change float64
+}
+
+// Validate returns an error if cfg can't be benchmarked.
+func (cfg Config) Validate() error {
+	switch {
+	case cfg.Nodes < 2:
+		return errors.New("the network needs at least 2 nodes")
+	case cfg.Size <= 0:
+		return errors.New("the size of the content must be positive")
+	case cfg.Runs <= 0:
+		return errors.New("the benchmarks need at least one run")
+	case cfg.Latency < 0 || cfg.Bandwidth < 0:
+		return errors.New("the latency and the bandwidth can't be negative")
+	}
+	return nil
+}
+
+// Run runs the named benchmarks, all of them if names is empty, in the order
+// of Benchmarks.
+func Run(ctx context.Context, cfg Config, names ...string) (*Report, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range names {
+		if benchmarks[name] == nil {
+			return nil, fmt.Errorf("%s: %s", ErrUnknownBenchmark, name)
+		}
+		selected[name] = true
+	}
+
+	rep := &Report{
+		Version: config.CurrentVersionNumber,
+		Commit:  config.CurrentCommit,
+		System:  runtime.GOARCH + "/" + runtime.GOOS,
+		Golang:  runtime.Version(),
+		CPUs:    runtime.NumCPU(),
+		Config:  cfg,
+	}
+	for _, name := range Benchmarks {
+		if len(selected) > 0 && !selected[name] {
+			continue
+		}
+
+		log.Debugf("running the %s benchmark", name)
+		runs, err := benchmarks[name](ctx, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		rep.Results = append(rep.Results, newResult(name, cfg.Size, runs))
+	}
+	return rep, nil
+}
+
+// Compare compares the results of rep to the ones of baseline with the same
+// name. It returns an error if the reports ran with different configs, as
+// they don't compare then.
+func (rep *Report) Compare(baseline *Report) ([]Change, error) {
+	if rep.Config != baseline.Config {
+		return nil, errors.New("the baseline ran with a different config")
+	}
+
+	base := make(map[string]Result)
+	for _, r := range baseline.Results {
+		base[r.Name] = r
+	}
+
+	var out []Change
+	for _, r := range rep.Results {
+		b, ok := base[r.Name]
+		if !ok || b.Median == 0 {
+			continue
+		}
+		out = append(out, Change{
+			Name:     r.Name,
+			Baseline: b.Median,
+			Median:   r.Median,
+			Change:   float64(r.Median-b.Median) / float64(b.Median),
+		})
+	}
+	return out, nil
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+func newResult(name string, size int64, runs []time.Duration) Result {
+	sorted := make(durations, len(runs))
+	copy(sorted, runs)
+	sort.Sort(sorted)
+
+	r := Result{
+		Name:   name,
+		Runs:   runs,
+		Min:    sorted[0],
+		Median: sorted[len(sorted)/2],
+		Max:    sorted[len(sorted)-1],
+	}
+	if r.Median > 0 {
+		r.Throughput = float64(size) / r.Median.Seconds()
+	}
+	return r
+}
+
+type benchmark func(ctx context.Context, cfg Config) ([]time.Duration, error)
+
+var benchmarks = map[string]benchmark{
+	BenchAdd:      benchAdd,
+	BenchPinFetch: benchPinFetch,
+	BenchGC:       benchGC,
+	BenchGateway:  benchGateway,
+}
+
+// content returns the content of the given run.
+func content(cfg Config, run int) []byte {
+	data := make([]byte, cfg.Size)
+	rand.New(rand.NewSource(cfg.Seed + int64(run))).Read(data)
+	return data
+}
+
+// network starts the nodes of the network, connected to each other.
+func network(ctx context.Context, cfg Config) ([]*core.IpfsNode, error) {
+	bw := cfg.Bandwidth
+	if bw == 0 {
+		bw = math.MaxInt32
+	}
+	mn := mocknet.New(ctx)
+	mn.SetLinkDefaults(mocknet.LinkOptions{
+		Latency:   cfg.Latency,
+		Bandwidth: bw,
+	})
+
+	var nodes []*core.IpfsNode
+	for i := 0; i < cfg.Nodes; i++ {
+		n, err := core.NewNode(ctx, &core.BuildCfg{
+			Online: true,
+			Host:   mock.MockHostOption(mn),
+		})
+		if err != nil {
+			closeAll(nodes)
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+
+	if err := mn.LinkAll(); err != nil {
+		closeAll(nodes)
+		return nil, err
+	}
+	for i, n := range nodes {
+		for _, o := range nodes[i+1:] {
+			if err := n.PeerHost.Connect(ctx, o.Peerstore.PeerInfo(o.Identity)); err != nil {
+				closeAll(nodes)
+				return nil, err
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// single starts a node alone on its network.
+func single(ctx context.Context) (*core.IpfsNode, error) {
+	return core.NewNode(ctx, &core.BuildCfg{
+		Online: true,
+		Host:   mock.MockHostOption(mocknet.New(ctx)),
+	})
+}
+
+func closeAll(nodes []*core.IpfsNode) {
+	for _, n := range nodes {
+		n.Close()
+	}
+}
+
+// benchAdd times adding the content of each run to a node.
+func benchAdd(ctx context.Context, cfg Config) ([]time.Duration, error) {
+	n, err := single(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer n.Close()
+
+	var runs []time.Duration
+	for i := 0; i < cfg.Runs; i++ {
+		data := content(cfg, i)
+
+		start := time.Now()
+		if _, err := coreunix.AddWithContext(ctx, n, bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		runs = append(runs, time.Since(start))
+	}
+	return runs, nil
+}
+
+// benchPinFetch times the first node of the network pinning the content all
+// the others added, as 'ipfs pin add' does.
+func benchPinFetch(ctx context.Context, cfg Config) ([]time.Duration, error) {
+	nodes, err := network(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(nodes)
+
+	var runs []time.Duration
+	for i := 0; i < cfg.Runs; i++ {
+		data := content(cfg, i)
+
+		var k string
+		for _, n := range nodes[1:] {
+			k, err = coreunix.AddWithContext(ctx, n, bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		start := time.Now()
+		if _, err := corerepo.Pin(nodes[0], ctx, []string{"/ipfs/" + k}, true); err != nil {
+			return nil, err
+		}
+		runs = append(runs, time.Since(start))
+	}
+	return runs, nil
+}
+
+// benchGC times the garbage collection of the content of each run, added
+// without being pinned.
+func benchGC(ctx context.Context, cfg Config) ([]time.Duration, error) {
+	n, err := single(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer n.Close()
+
+	var runs []time.Duration
+	for i := 0; i < cfg.Runs; i++ {
+		if _, err := coreunix.AddWithContext(ctx, n, bytes.NewReader(content(cfg, i))); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		if err := corerepo.GarbageCollect(n, ctx); err != nil {
+			return nil, err
+		}
+		runs = append(runs, time.Since(start))
+	}
+	return runs, nil
+}
+
+// benchGateway times downloading the content of each run from the gateway of
+// the node which has it, over the loopback interface.
+func benchGateway(ctx context.Context, cfg Config) ([]time.Duration, error) {
+	n, err := single(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer n.Close()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	// the server stops with the node
+	go func() {
+		if err := corehttp.Serve(n, lis, corehttp.GatewayOption(false, "/ipfs")); err != nil {
+			log.Error("serving the gateway: ", err)
+		}
+	}()
+
+	var runs []time.Duration
+	for i := 0; i < cfg.Runs; i++ {
+		k, err := coreunix.AddWithContext(ctx, n, bytes.NewReader(content(cfg, i)))
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		if err := download(ctx, fmt.Sprintf("http://%s/ipfs/%s", lis.Addr(), k), cfg.Size); err != nil {
+			return nil, err
+		}
+		runs = append(runs, time.Since(start))
+	}
+	return runs, nil
+}
+
+// download reads the body of url, which should be size long.
+func download(ctx context.Context, url string, size int64) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gateway: %s", resp.Status)
+	}
+	read, err := io.Copy(ioutil.Discard, resp.Body)
+	if err != nil {
+		return err
+	}
+	if read != size {
+		return fmt.Errorf("gateway: read %d bytes out of %d", read, size)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package corebench
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestContentIsDeterministic(t *testing.T) {
+	cfg := Config{Size: 1024, Seed: 42}
+	if !bytes.Equal(content(cfg, 0), content(cfg, 0)) {
+		t.Fatal("the same run generated different content")
+	}
+	if bytes.Equal(content(cfg, 0), content(cfg, 1)) {
+		t.Fatal("two runs generated the same content")
+	}
+}
+
+func TestRunAndCompare(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cfg := Config{Nodes: 2, Size: 256 * 1024, Runs: 2, Seed: 1}
+	rep, err := Run(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Results) != len(Benchmarks) {
+		t.Fatalf("expected %d results, got %d", len(Benchmarks), len(rep.Results))
+	}
+	for i, r := range rep.Results {
+		if r.Name != Benchmarks[i] {
+			t.Fatalf("expected %s, got %s", Benchmarks[i], r.Name)
+		}
+		if len(r.Runs) != cfg.Runs || r.Min > r.Median || r.Median > r.Max {
+			t.Fatalf("bad timings for %s: %+v", r.Name, r)
+		}
+	}
+
+	changes, err := rep.Compare(rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range changes {
+		if c.Change != 0 {
+			t.Fatalf("%s changed compared to itself: %f", c.Name, c.Change)
+		}
+	}
+
+	other := *rep
+	other.Config.Seed++
+	if _, err := rep.Compare(&other); err == nil {
+		t.Fatal("compared reports of different configs")
+	}
+
+	if _, err := Run(ctx, cfg, "nothing"); err == nil {
+		t.Fatal("ran an unknown benchmark")
+	}
+}
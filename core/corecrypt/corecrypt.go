@@ -0,0 +1,200 @@
+// Package corecrypt encrypts content as the node adds it, so that private
+// data can be stored on public IPFS nodes. The node encrypting the content
+// sees it in the clear, and holds the secret.
+//
+// The encrypted content is added as a regular file, linked by a manifest
+// telling how to decrypt it, but the key. The key of each file is derived
+// from a secret, either a key of the keystore or a key given by the user,
+// and a random salt, stored in the manifest.
+package corecrypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	path "github.com/ipfs/go-ipfs/path"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// ManifestVersion is the version of the manifests written.
+const ManifestVersion = 1
+
+// Cipher is the cipher of the envelopes written.
+const Cipher = "aes-256-gcm"
+
+// contentLink is the name of the link from a manifest to its content.
+const contentLink = "content"
+
+// Manifest tells how the content it links to was encrypted. It is the data
+// of a dag-pb node, encoded as JSON, with a link named "content" to the
+// encrypted file.
+type Manifest struct {
+	Version     int
+	Cipher      string
+	SegmentSize int
+	Salt        []byte
+	// KeyCheck tells whether a key is the one the content was encrypted
+	// with.
+	KeyCheck []byte
+	// Key is the name of the keystore key the content was encrypted with,
+	// empty for a key given by the user.
+	Key string `json:",omitempty"`
+}
+
+// Secret is what the keys of the files are derived from.
+type Secret struct {
+	// Name is the name of the key in the keystore, empty for a key given
+	// by the user.
+	Name  string
+	Bytes []byte
+}
+
+// ErrIdentitySecret is returned for the secret of "self", the identity key
+// of the node: it is meant for the network, and rotating it with 'ipfs id
+// rotate' would make the content unreadable.
+var ErrIdentitySecret = errors.New("the identity key of the node can't encrypt content, create a dedicated key with 'ipfs key gen'")
+
+// KeystoreSecret returns the secret of the key name of the keystore of n,
+// which can't be "self".
+func KeystoreSecret(n *core.IpfsNode, name string) (*Secret, error) {
+	if name == "self" {
+		return nil, ErrIdentitySecret
+	}
+	sk, err := n.GetKey(name)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %s", name, err)
+	}
+	b, err := sk.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &Secret{Name: name, Bytes: b}, nil
+}
+
+// UserSecret returns the secret of a key given by the user.
+func UserSecret(key []byte) (*Secret, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("the key must be %d bytes long, not %d", KeySize, len(key))
+	}
+	return &Secret{Bytes: key}, nil
+}
+
+// Add encrypts the content read from r with a key derived from secret, adds
+// it, and returns the manifest linking to it. The manifest is pinned
+// recursively with pin.
+func Add(ctx context.Context, n *core.IpfsNode, r io.Reader, secret *Secret, pin bool) (*cid.Cid, error) {
+	defer n.Blockstore.PinLock().Unlock()
+
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	key, check := FileKey(secret.Bytes, salt)
+
+	enc, err := NewEncrypter(r, key)
+	if err != nil {
+		return nil, err
+	}
+	s, err := coreunix.AddWithContext(ctx, n, enc)
+	if err != nil {
+		return nil, err
+	}
+	c, err := cid.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	content, err := n.DAG.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(&Manifest{
+		Version:     ManifestVersion,
+		Cipher:      Cipher,
+		SegmentSize: SegmentSize,
+		Salt:        salt,
+		KeyCheck:    check,
+		Key:         secret.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifest := dag.NodeWithData(data)
+	if err := manifest.AddNodeLink(contentLink, content); err != nil {
+		return nil, err
+	}
+	mc, err := n.DAG.Add(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if pin {
+		if err := n.Pinning.Pin(ctx, manifest, true); err != nil {
+			return nil, err
+		}
+		if err := n.Pinning.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return mc, nil
+}
+
+// GetManifest returns the manifest at p, and the path of its content.
+func GetManifest(ctx context.Context, n *core.IpfsNode, p path.Path) (*Manifest, path.Path, error) {
+	nd, err := core.Resolve(ctx, n.Namesys, n.Resolver, p)
+	if err != nil {
+		return nil, "", err
+	}
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil, "", fmt.Errorf("%s is not an encryption manifest", p)
+	}
+
+	m := new(Manifest)
+	if err := json.NewDecoder(bytes.NewReader(pn.Data())).Decode(m); err != nil || m.Version == 0 {
+		return nil, "", fmt.Errorf("%s is not an encryption manifest", p)
+	}
+	if m.Version != ManifestVersion || m.Cipher != Cipher || m.SegmentSize != SegmentSize {
+		return nil, "", fmt.Errorf("unsupported manifest: version %d, cipher %s, segments of %d bytes", m.Version, m.Cipher, m.SegmentSize)
+	}
+
+	lnk, err := pn.GetNodeLink(contentLink)
+	if err != nil {
+		return nil, "", fmt.Errorf("manifest %s has no content", p)
+	}
+	return m, path.FromCid(lnk.Cid), nil
+}
+
+// Cat returns a reader of the decrypted content of the manifest at p. It
+// fails with ErrWrongKey if secret isn't the one the content was encrypted
+// with.
+func Cat(ctx context.Context, n *core.IpfsNode, p path.Path, secret *Secret) (io.Reader, error) {
+	m, content, err := GetManifest(ctx, n, p)
+	if err != nil {
+		return nil, err
+	}
+
+	key, check := FileKey(secret.Bytes, m.Salt)
+	if !hmac.Equal(check, m.KeyCheck) {
+		if m.Key != "" && m.Key != secret.Name {
+			return nil, fmt.Errorf("%s: the content was encrypted with the key %s", ErrWrongKey, m.Key)
+		}
+		return nil, ErrWrongKey
+	}
+
+	r, err := coreunix.Cat(ctx, n, content.String())
+	if err != nil {
+		return nil, err
+	}
+	return NewDecrypter(r, key)
+}
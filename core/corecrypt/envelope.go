@@ -0,0 +1,200 @@
+package corecrypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SegmentSize is the size of the plaintext sealed in each segment of the
+// envelope. Each segment grows by the size of its authentication tag.
+const SegmentSize = 64 * 1024
+
+// KeySize is the size of the secret keys given on the command line, and of
+// the keys of the files.
+const KeySize = 32
+
+var (
+	// ErrWrongKey is returned when the key doesn't match the one the
+	// content was encrypted with.
+	ErrWrongKey = errors.New("wrong key for the encrypted content")
+
+	// ErrCorrupted is returned when the envelope was altered or truncated.
+	ErrCorrupted = errors.New("encrypted content is corrupted")
+)
+
+// FileKey derives the key of a file from a secret and the random salt of
+// the file, so that no two files share a key. The check is stored in the
+// manifest, to tell a wrong secret from a corrupted file.
+func FileKey(secret, salt []byte) (key, check []byte) {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("ipfs-crypt-v1 key:"))
+	mac.Write(salt)
+	key = mac.Sum(nil)
+
+	mac = hmac.New(sha256.New, key)
+	mac.Write([]byte("ipfs-crypt-v1 check"))
+	check = mac.Sum(nil)[:8]
+	return key, check
+}
+
+// The envelope is a sequence of segments, each of SegmentSize bytes of
+// plaintext sealed with AES-256-GCM, except the last one which may be
+// shorter, or empty. The nonce of a segment is its index, and its additional
+// data tells whether it is the last one, so that segments can't be
+// reordered, nor the envelope truncated. The key of a file is never reused.
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("the key must be %d bytes long, not %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func segmentNonce(aead cipher.AEAD, i uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], i)
+	return nonce
+}
+
+func segmentData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// encrypter reads the envelope of the plaintext it wraps.
+type encrypter struct {
+	aead cipher.AEAD
+	in   *bufio.Reader
+	buf  []byte
+	out  []byte
+	seg  uint64
+	done bool
+}
+
+// NewEncrypter returns a reader of the envelope of the plaintext read from r,
+// sealed with key.
+func NewEncrypter(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &encrypter{
+		aead: aead,
+		in:   bufio.NewReaderSize(r, SegmentSize),
+		buf:  make([]byte, SegmentSize),
+	}, nil
+}
+
+func (e *encrypter) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		if err := e.seal(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+// seal reads and seals the next segment.
+func (e *encrypter) seal() error {
+	n, err := io.ReadFull(e.in, e.buf)
+	switch err {
+	case nil:
+		// the segment is the last one if nothing follows
+		if _, err := e.in.Peek(1); err == io.EOF {
+			e.done = true
+		} else if err != nil {
+			return err
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		e.done = true
+	default:
+		return err
+	}
+
+	e.out = e.aead.Seal(e.out[:0], segmentNonce(e.aead, e.seg), e.buf[:n], segmentData(e.done))
+	e.seg++
+	return nil
+}
+
+// decrypter reads the plaintext of the envelope it wraps.
+type decrypter struct {
+	aead cipher.AEAD
+	in   *bufio.Reader
+	buf  []byte
+	out  []byte
+	seg  uint64
+	done bool
+}
+
+// NewDecrypter returns a reader of the plaintext of the envelope read from r,
+// opened with key. Reads fail with ErrCorrupted if the envelope was altered
+// or truncated.
+func NewDecrypter(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	sealed := SegmentSize + aead.Overhead()
+	return &decrypter{
+		aead: aead,
+		in:   bufio.NewReaderSize(r, sealed),
+		buf:  make([]byte, sealed),
+	}, nil
+}
+
+func (d *decrypter) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// open reads and opens the next segment.
+func (d *decrypter) open() error {
+	n, err := io.ReadFull(d.in, d.buf)
+	switch err {
+	case nil:
+		if _, err := d.in.Peek(1); err == io.EOF {
+			d.done = true
+		} else if err != nil {
+			return err
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		d.done = true
+	default:
+		return err
+	}
+
+	out, err := d.aead.Open(d.out[:0], segmentNonce(d.aead, d.seg), d.buf[:n], segmentData(d.done))
+	if err != nil {
+		return ErrCorrupted
+	}
+	d.out = out
+	d.seg++
+	return nil
+}
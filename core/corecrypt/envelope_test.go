@@ -0,0 +1,102 @@
+package corecrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func seal(t *testing.T, data, key []byte) []byte {
+	enc, err := NewEncrypter(bytes.NewReader(data), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := ioutil.ReadAll(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealed
+}
+
+func open(sealed, key []byte) ([]byte, error) {
+	dec, err := NewDecrypter(bytes.NewReader(sealed), key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(dec)
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	key := testKey(t)
+	for _, size := range []int{0, 1, SegmentSize - 1, SegmentSize, SegmentSize + 1, 3*SegmentSize + 100} {
+		data := make([]byte, size)
+		if _, err := io.ReadFull(rand.Reader, data); err != nil {
+			t.Fatal(err)
+		}
+
+		sealed := seal(t, data, key)
+		segments := size/SegmentSize + 1
+		if size > 0 && size%SegmentSize == 0 {
+			segments--
+		}
+		if len(sealed) != size+segments*16 {
+			t.Fatalf("%d bytes: expected %d segments, got %d bytes sealed", size, segments, len(sealed))
+		}
+
+		out, err := open(sealed, key)
+		if err != nil {
+			t.Fatalf("%d bytes: %s", size, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("%d bytes: the content changed", size)
+		}
+	}
+}
+
+func TestEnvelopeTampering(t *testing.T) {
+	key := testKey(t)
+	data := make([]byte, 2*SegmentSize+10)
+	sealed := seal(t, data, key)
+
+	if _, err := open(sealed, testKey(t)); err != ErrCorrupted {
+		t.Fatalf("expected a wrong key to fail, got %v", err)
+	}
+
+	flipped := append([]byte(nil), sealed...)
+	flipped[SegmentSize+20] ^= 1
+	if _, err := open(flipped, key); err != ErrCorrupted {
+		t.Fatalf("expected an altered segment to fail, got %v", err)
+	}
+
+	// dropping whole segments is caught too
+	truncated := sealed[:SegmentSize+16]
+	if _, err := open(truncated, key); err != ErrCorrupted {
+		t.Fatalf("expected a truncated envelope to fail, got %v", err)
+	}
+	if _, err := open(nil, key); err != ErrCorrupted {
+		t.Fatalf("expected an empty envelope to fail, got %v", err)
+	}
+}
+
+func TestFileKey(t *testing.T) {
+	secret := []byte("secret")
+	k1, c1 := FileKey(secret, []byte("salt 1"))
+	k2, c2 := FileKey(secret, []byte("salt 2"))
+	if len(k1) != KeySize || bytes.Equal(k1, k2) || bytes.Equal(c1, c2) {
+		t.Fatal("expected distinct keys for distinct salts")
+	}
+	k3, c3 := FileKey(secret, []byte("salt 1"))
+	if !bytes.Equal(k1, k3) || !bytes.Equal(c1, c3) {
+		t.Fatal("expected the same key for the same salt")
+	}
+}
@@ -5,30 +5,54 @@ import (
 	"net"
 	"net/http"
 
+	bserv "github.com/ipfs/go-ipfs/blockservice"
 	core "github.com/ipfs/go-ipfs/core"
 	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	id "gx/ipfs/QmRai5yZNL67pWCoznW7sBdFnqZrFULuJ5w8KhmRyhdgN4/go-libp2p/p2p/protocol/identify"
 )
 
 type GatewayConfig struct {
-	Headers      map[string][]string
-	Writable     bool
-	PathPrefixes []string
+	Headers        map[string][]string
+	Writable       bool
+	PathPrefixes   []string
+	MediaPlaylists bool
 }
 
 func GatewayOption(writable bool, paths ...string) ServeOption {
+	return gatewayOption(writable, false, paths...)
+}
+
+// NoFetchGatewayOption is like GatewayOption, except that the gateway only
+// serves content already in the local repo, and never fetches blocks from the
+// network.
+func NoFetchGatewayOption(writable bool, paths ...string) ServeOption {
+	return gatewayOption(writable, true, paths...)
+}
+
+func gatewayOption(writable, noFetch bool, paths ...string) ServeOption {
 	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
 		cfg, err := n.Repo.Config()
 		if err != nil {
 			return nil, err
 		}
 
+		api := coreapi.NewCoreAPI(n)
+		bsrv, dserv := n.Blocks, n.DAG
+		if noFetch {
+			bsrv = bserv.New(n.Blockstore, offline.Exchange(n.Blockstore))
+			dserv = dag.NewDAGService(bsrv)
+			api = coreapi.NewCoreAPIWithDAG(n, dserv)
+		}
+
 		gateway := newGatewayHandler(n, GatewayConfig{
-			Headers:      cfg.Gateway.HTTPHeaders,
-			Writable:     writable,
-			PathPrefixes: cfg.Gateway.PathPrefixes,
-		}, coreapi.NewCoreAPI(n))
+			Headers:        cfg.Gateway.HTTPHeaders,
+			Writable:       writable,
+			PathPrefixes:   cfg.Gateway.PathPrefixes,
+			MediaPlaylists: cfg.Gateway.MediaPlaylists,
+		}, api, bsrv, dserv)
 
 		for _, p := range paths {
 			mux.Handle(p+"/", gateway)
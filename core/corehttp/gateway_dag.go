@@ -0,0 +1,140 @@
+package corehttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	path "github.com/ipfs/go-ipfs/path"
+
+	ipldcbor "gx/ipfs/QmNrbCt8j9DT5W9Pmjy2SdudT9k8GpaDr4sRuFix3BXhgR/go-ipld-cbor"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+const (
+	jsonContentType    = "application/json"
+	cborContentType    = "application/cbor"
+	dagJsonContentType = "application/vnd.ipld.dag-json"
+	dagCborContentType = "application/vnd.ipld.dag-cbor"
+)
+
+// isDagPath returns true if the given gateway path is rooted in a structured
+// (non unixfs) IPLD node. Such paths may point into the node itself, which
+// the unixfs path resolver doesn't understand.
+func isDagPath(urlPath string) bool {
+	c, _, err := path.SplitAbsPath(path.Path(urlPath))
+	if err != nil {
+		return false
+	}
+	return c.Type() == cid.DagCBOR
+}
+
+// serveDagNode serves the IPLD value the given path points to as structured
+// data. The encoding is chosen from the Accept header by dagContentType, and
+// links are encoded as IPLD links in both JSON and CBOR: {"/": "<cid>"} in
+// JSON.
+func (i *gatewayHandler) serveDagNode(ctx context.Context, w http.ResponseWriter, r *http.Request, p coreiface.Path) {
+	urlPath := r.URL.Path
+
+	res, err := i.api.Dag().Resolve(ctx, p)
+	if err != nil {
+		webError(w, "ipfs dag resolve "+urlPath, err, http.StatusNotFound)
+		return
+	}
+
+	nd, err := i.dserv.Get(ctx, res.Cid)
+	if err != nil {
+		webError(w, "ipfs dag get "+urlPath, err, http.StatusNotFound)
+		return
+	}
+
+	var val interface{} = nd
+	if len(res.RemPath) > 0 {
+		val, _, err = nd.Resolve(res.RemPath)
+		if err != nil {
+			webError(w, "ipfs dag get "+urlPath, err, http.StatusNotFound)
+			return
+		}
+	}
+
+	// a path ending on a link serves the link itself
+	if lnk, ok := val.(*node.Link); ok {
+		val = lnk.Cid
+	}
+
+	ctype := dagContentType(r)
+	isCbor := ctype == cborContentType || ctype == dagCborContentType
+
+	etag := "\"" + res.Cid.String()
+	if len(res.RemPath) > 0 {
+		etag += "/" + path.Join(res.RemPath)
+	}
+	etag += "." + ctype[strings.Index(ctype, "/")+1:] + "\""
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var body []byte
+	switch {
+	case isCbor && len(res.RemPath) == 0:
+		body = nd.RawData()
+	case isCbor:
+		body, err = ipldcbor.DumpObject(val)
+	default:
+		body, err = json.Marshal(val)
+	}
+	if err != nil {
+		internalWebError(w, err)
+		return
+	}
+
+	i.addUserHeaders(w) // ok, _now_ write user's headers.
+	w.Header().Set("X-IPFS-Path", urlPath)
+	w.Header().Set("Etag", etag)
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Vary", "Accept")
+	if strings.HasPrefix(urlPath, ipfsPathPrefix) {
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	}
+
+	if r.Method == "HEAD" {
+		return
+	}
+	w.Write(body)
+}
+
+// dagContentType returns the media type a structured node is served as: the
+// one of the Accept header with the highest quality among JSON, CBOR, dag-json
+// and dag-cbor, the first one listed on ties. JSON is the default.
+func dagContentType(r *http.Request) string {
+	best, bestQ := jsonContentType, 0.0
+	for _, a := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(a, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		switch mt {
+		case jsonContentType, cborContentType, dagJsonContentType, dagCborContentType:
+		default:
+			continue
+		}
+
+		q := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if v, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		// q=0 means not acceptable
+		if q > bestQ {
+			best, bestQ = mt, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,111 @@
+package corehttp
+
+import (
+	"context"
+	"net/url"
+	gopath "path"
+	"strings"
+	"time"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+)
+
+// fileName returns the name of the file served under urlPath: the filename
+// query parameter if set, else the name of the last link of the path. It
+// returns "" for a path naming an object directly, such as /ipfs/<cid>.
+func fileName(urlPath string, q url.Values) string {
+	if name := q.Get("filename"); name != "" {
+		return name
+	}
+	if strings.Count(strings.Trim(urlPath, "/"), "/") < 2 {
+		return ""
+	}
+	return gopath.Base(urlPath)
+}
+
+// contentDisposition returns the Content-Disposition header of a file named
+// name: an attachment with ?download=true, so that browsers save it, and
+// inline otherwise. It returns "" if there is nothing to say.
+func contentDisposition(name string, q url.Values) string {
+	disp := "inline"
+	if q.Get("download") == "true" {
+		disp = "attachment"
+	}
+	if name == "" {
+		if disp == "inline" {
+			return ""
+		}
+		return disp
+	}
+	return disp + "; " + dispositionFilename(name)
+}
+
+// dispositionFilename returns the filename parameters of a Content-Disposition
+// header. Names that can't be quoted as they are also get a UTF-8 filename*
+// parameter (RFC 6266), after an ASCII approximation for older clients.
+func dispositionFilename(name string) string {
+	quotable := true
+	for i := 0; i < len(name); i++ {
+		if !isQuotable(name[i]) {
+			quotable = false
+			break
+		}
+	}
+	if quotable {
+		return `filename="` + name + `"`
+	}
+
+	var ascii []byte
+	for _, r := range name {
+		if r < 0x80 && isQuotable(byte(r)) {
+			ascii = append(ascii, byte(r))
+		} else {
+			ascii = append(ascii, '_')
+		}
+	}
+
+	const hex = "0123456789ABCDEF"
+	var enc []byte
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if isAttrChar(b) {
+			enc = append(enc, b)
+		} else {
+			enc = append(enc, '%', hex[b>>4], hex[b&15])
+		}
+	}
+	return `filename="` + string(ascii) + `"; filename*=UTF-8''` + string(enc)
+}
+
+func isQuotable(b byte) bool {
+	return b >= 0x20 && b < 0x7f && b != '"' && b != '\\'
+}
+
+// isAttrChar reports whether b can be left as it is in an RFC 5987 value.
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// modTime returns the modification time stored in the unixfs node at p, or
+// the zero time if it has none.
+func (i *gatewayHandler) modTime(ctx context.Context, p coreiface.Path) time.Time {
+	nd, err := i.api.ResolveNode(ctx, p)
+	if err != nil {
+		return time.Time{}
+	}
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return time.Time{}
+	}
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return time.Time{}
+	}
+	return fsn.ModTime
+}
@@ -12,9 +12,11 @@ import (
 	"strings"
 	"time"
 
+	bserv "github.com/ipfs/go-ipfs/blockservice"
 	core "github.com/ipfs/go-ipfs/core"
 	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	"github.com/ipfs/go-ipfs/importer"
 	chunk "github.com/ipfs/go-ipfs/importer/chunk"
 	dag "github.com/ipfs/go-ipfs/merkledag"
@@ -41,23 +43,38 @@ type gatewayHandler struct {
 	node   *core.IpfsNode
 	config GatewayConfig
 	api    coreiface.CoreAPI
+
+	// blocks is the block service of dserv, which the fetch quotas wrap the
+	// exchange of
+	blocks bserv.BlockService
+
+	// dserv is the DAG service the objects served are read from, and the
+	// objects written are added with
+	dserv dag.DAGService
 }
 
-func newGatewayHandler(n *core.IpfsNode, c GatewayConfig, api coreiface.CoreAPI) *gatewayHandler {
+func newGatewayHandler(n *core.IpfsNode, c GatewayConfig, api coreiface.CoreAPI, blocks bserv.BlockService, dserv dag.DAGService) *gatewayHandler {
 	i := &gatewayHandler{
 		node:   n,
 		config: c,
 		api:    api,
+		blocks: blocks,
+		dserv:  dserv,
 	}
 	return i
 }
 
+// resolver returns a resolver of the paths in the DAG service of the handler.
+func (i *gatewayHandler) resolver() *path.Resolver {
+	return path.NewBasicResolver(i.dserv)
+}
+
 // TODO(cryptix):  find these helpers somewhere else
 func (i *gatewayHandler) newDagFromReader(r io.Reader) (node.Node, error) {
 	// TODO(cryptix): change and remove this helper once PR1136 is merged
 	// return ufs.AddFromReader(i.node, r.Body)
 	return importer.BuildDagFromReader(
-		i.node.DAG,
+		i.dserv,
 		chunk.DefaultSplitter(r))
 }
 
@@ -66,6 +83,7 @@ func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(i.node.Context(), time.Hour)
 	// the hour is a hard fallback, we don't expect it to happen, but just in case
 	defer cancel()
+	ctx = exchange.WithSession(ctx)
 
 	if cn, ok := w.(http.CloseNotifier); ok {
 		clientGone := cn.CloseNotify()
@@ -101,6 +119,10 @@ func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method == "GET" || r.Method == "HEAD" {
+		if q := i.requestFetchQuota(); q != nil {
+			i.withFetchQuota(q).getOrHeadHandler(ctx, &quotaResponseWriter{ResponseWriter: w, quota: q}, r)
+			return
+		}
 		i.getOrHeadHandler(ctx, w, r)
 		return
 	}
@@ -131,6 +153,21 @@ func (i *gatewayHandler) optionsHandler(w http.ResponseWriter, r *http.Request)
 	i.addUserHeaders(w) // return all custom headers (including CORS ones, if set)
 }
 
+// pathPrefixes returns the path prefixes allowed for the request: the ones
+// configured for its hostname if any, the gateway-wide ones otherwise. The
+// config is looked up for every request so that changes apply right away.
+func (i *gatewayHandler) pathPrefixes(r *http.Request) []string {
+	cfg, err := i.node.Repo.Config()
+	if err != nil {
+		log.Errorf("could not get config: %s", err)
+		return i.config.PathPrefixes
+	}
+	if vhost, ok := cfg.Gateway.Hosts[requestHost(r)]; ok && vhost.PathPrefixes != nil {
+		return vhost.PathPrefixes
+	}
+	return cfg.Gateway.PathPrefixes
+}
+
 func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 
 	urlPath := r.URL.Path
@@ -141,7 +178,7 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 	prefix := ""
 	if prefixHdr := r.Header["X-Ipfs-Gateway-Prefix"]; len(prefixHdr) > 0 {
 		prfx := prefixHdr[0]
-		for _, p := range i.config.PathPrefixes {
+		for _, p := range i.pathPrefixes(r) {
 			if prfx == p || strings.HasPrefix(prfx, p+"/") {
 				prefix = prfx
 				break
@@ -167,6 +204,11 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		return
 	}
 
+	if isDagPath(urlPath) {
+		i.serveDagNode(ctx, w, r, parsedPath)
+		return
+	}
+
 	// Resolve path to the final DAG node for the ETag
 	resolvedPath, err := i.api.ResolvePath(ctx, parsedPath)
 	switch err {
@@ -191,6 +233,11 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 	case coreiface.ErrIsDir:
 		dir = true
 	default:
+		if resolvedPath.Cid().Type() == cid.DagCBOR {
+			// e.g. an ipns name pointing at a structured node
+			i.serveDagNode(ctx, w, r, coreapi.ParseCid(resolvedPath.Cid()))
+			return
+		}
 		webError(w, "ipfs cat "+urlPath, err, http.StatusNotFound)
 		return
 	}
@@ -259,6 +306,36 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 
 	if !dir {
 		name := gopath.Base(urlPath)
+		if fname := fileName(urlPath, r.URL.Query()); fname != "" {
+			name = fname
+		}
+
+		// the modification time stored with the file, if any, is its
+		// Last-Modified date
+		if mt := i.modTime(ctx, resolvedPath); !mt.IsZero() {
+			modtime = mt
+		}
+
+		if format := r.URL.Query().Get("playlist"); format != "" {
+			i.serveMediaPlaylist(w, format, name, dr)
+			return
+		}
+
+		// same detection as 'ipfs files stat --mime' and 'ipfs ls --long'
+		ctype, err := uio.DetectContentType(name, dr)
+		if err != nil {
+			internalWebError(w, err)
+			return
+		}
+		if _, err := dr.Seek(0, io.SeekStart); err != nil {
+			internalWebError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", ctype)
+		if disp := contentDisposition(fileName(urlPath, r.URL.Query()), r.URL.Query()); disp != "" {
+			w.Header().Set("Content-Disposition", disp)
+		}
+
 		http.ServeContent(w, r, name, modtime, dr)
 		return
 	}
@@ -269,7 +346,7 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		return
 	}
 
-	dirr, err := uio.NewDirectoryFromNode(i.node.DAG, nd)
+	dirr, err := uio.NewDirectoryFromNode(i.dserv, nd)
 	if err != nil {
 		internalWebError(w, err)
 		return
@@ -408,7 +485,7 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var newcid *cid.Cid
-	rnode, err := core.Resolve(ctx, i.node.Namesys, i.node.Resolver, rootPath)
+	rnode, err := core.Resolve(ctx, i.node.Namesys, i.resolver(), rootPath)
 	switch ev := err.(type) {
 	case path.ErrNoLink:
 		// ev.Node < node where resolve failed
@@ -420,7 +497,7 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		rnode, err := i.node.DAG.Get(ctx, c)
+		rnode, err := i.dserv.Get(ctx, c)
 		if err != nil {
 			webError(w, "putHandler: Could not create DAG from request", err, http.StatusInternalServerError)
 			return
@@ -432,14 +509,14 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		e := dagutils.NewDagEditor(pbnd, i.node.DAG)
+		e := dagutils.NewDagEditor(pbnd, i.dserv)
 		err = e.InsertNodeAtPath(ctx, newPath, newnode, ft.EmptyDirNode)
 		if err != nil {
 			webError(w, "putHandler: InsertNodeAtPath failed", err, http.StatusInternalServerError)
 			return
 		}
 
-		nnode, err := e.Finalize(i.node.DAG)
+		nnode, err := e.Finalize(i.dserv)
 		if err != nil {
 			webError(w, "putHandler: could not get node", err, http.StatusInternalServerError)
 			return
@@ -463,7 +540,7 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 		// object set-data case
 		pbnd.SetData(pbnewnode.Data())
 
-		newcid, err = i.node.DAG.Add(pbnd)
+		newcid, err = i.dserv.Add(pbnd)
 		if err != nil {
 			nnk := newnode.Cid()
 			rk := pbnd.Cid()
@@ -500,13 +577,13 @@ func (i *gatewayHandler) deleteHandler(w http.ResponseWriter, r *http.Request) {
 
 	tctx, cancel := context.WithTimeout(ctx, time.Minute)
 	defer cancel()
-	rootnd, err := i.node.Resolver.DAG.Get(tctx, c)
+	rootnd, err := i.dserv.Get(tctx, c)
 	if err != nil {
 		webError(w, "Could not resolve root object", err, http.StatusBadRequest)
 		return
 	}
 
-	pathNodes, err := i.node.Resolver.ResolveLinks(tctx, rootnd, components[:len(components)-1])
+	pathNodes, err := i.resolver().ResolveLinks(tctx, rootnd, components[:len(components)-1])
 	if err != nil {
 		webError(w, "Could not resolve parent object", err, http.StatusBadRequest)
 		return
@@ -527,7 +604,7 @@ func (i *gatewayHandler) deleteHandler(w http.ResponseWriter, r *http.Request) {
 
 	var newnode *dag.ProtoNode = pbnd
 	for j := len(pathNodes) - 2; j >= 0; j-- {
-		if _, err := i.node.DAG.Add(newnode); err != nil {
+		if _, err := i.dserv.Add(newnode); err != nil {
 			webError(w, "Could not add node", err, http.StatusInternalServerError)
 			return
 		}
@@ -545,7 +622,7 @@ func (i *gatewayHandler) deleteHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if _, err := i.node.DAG.Add(newnode); err != nil {
+	if _, err := i.dserv.Add(newnode); err != nil {
 		webError(w, "Could not add root node", err, http.StatusInternalServerError)
 		return
 	}
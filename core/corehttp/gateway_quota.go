@@ -0,0 +1,192 @@
+package corehttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// errRetrievalLimit is returned by the exchange of a gateway request that
+// fetched more from the network than Gateway.MaxFetchBlocks or
+// Gateway.MaxFetchBytes allow.
+var errRetrievalLimit = errors.New("retrieval limit exceeded")
+
+// fetchQuota is an exchange counting the blocks and bytes fetched through it,
+// and failing once they go over its limits. A limit of zero means none.
+type fetchQuota struct {
+	exchange.Interface
+
+	maxBlocks int
+	maxBytes  int64
+
+	mu       sync.Mutex
+	blocks   int
+	bytes    int64
+	exceeded bool
+}
+
+func newFetchQuota(ex exchange.Interface, maxBlocks int, maxBytes int64) *fetchQuota {
+	return &fetchQuota{
+		Interface: ex,
+		maxBlocks: maxBlocks,
+		maxBytes:  maxBytes,
+	}
+}
+
+// take counts b against the quota.
+func (q *fetchQuota) take(b blocks.Block) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.blocks++
+	q.bytes += int64(len(b.RawData()))
+	if (q.maxBlocks > 0 && q.blocks > q.maxBlocks) || (q.maxBytes > 0 && q.bytes > q.maxBytes) {
+		q.exceeded = true
+	}
+	if q.exceeded {
+		return errRetrievalLimit
+	}
+	return nil
+}
+
+// Exceeded returns whether a fetch went over the quota.
+func (q *fetchQuota) Exceeded() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.exceeded
+}
+
+func (q *fetchQuota) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	if q.Exceeded() {
+		return nil, errRetrievalLimit
+	}
+
+	b, err := q.Interface.GetBlock(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.take(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (q *fetchQuota) GetBlocks(ctx context.Context, ks []*cid.Cid) (<-chan blocks.Block, error) {
+	if q.Exceeded() {
+		return nil, errRetrievalLimit
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	in, err := q.Interface.GetBlocks(ctx, ks)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for b := range in {
+			if q.take(b) != nil {
+				return
+			}
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Close doesn't close the underlying exchange, which is shared with the rest
+// of the node.
+func (q *fetchQuota) Close() error {
+	return nil
+}
+
+// requestFetchQuota returns a quota for a request if the config limits what
+// the gateway fetches for one, nil otherwise. The config is looked up for
+// every request so that changes apply right away.
+func (i *gatewayHandler) requestFetchQuota() *fetchQuota {
+	cfg, err := i.node.Repo.Config()
+	if err != nil {
+		log.Errorf("could not get config: %s", err)
+		return nil
+	}
+	if cfg.Gateway.MaxFetchBlocks <= 0 && cfg.Gateway.MaxFetchBytes <= 0 {
+		return nil
+	}
+	return newFetchQuota(i.blocks.Exchange(), cfg.Gateway.MaxFetchBlocks, cfg.Gateway.MaxFetchBytes)
+}
+
+// withFetchQuota returns a copy of the handler fetching blocks through q,
+// with a DAG service of its own on top of the blockstore of the node.
+func (i *gatewayHandler) withFetchQuota(q *fetchQuota) *gatewayHandler {
+	dserv := dag.NewDAGService(bserv.New(i.node.Blockstore, q))
+	return &gatewayHandler{
+		node:   i.node,
+		config: i.config,
+		api:    coreapi.NewCoreAPIWithDAG(i.node, dserv),
+		blocks: i.blocks,
+		dserv:  dserv,
+	}
+}
+
+// retrievalLimitError is the body of the responses to requests which went
+// over their fetch quota.
+type retrievalLimitError struct {
+	Message   string
+	MaxBlocks int   `json:",omitempty"`
+	MaxBytes  int64 `json:",omitempty"`
+}
+
+// quotaResponseWriter turns the error responses to a request that went over
+// its fetch quota into 502 responses saying so, whatever error the fetch
+// failure surfaced as.
+type quotaResponseWriter struct {
+	http.ResponseWriter
+	quota    *fetchQuota
+	replaced bool
+}
+
+func (w *quotaResponseWriter) WriteHeader(code int) {
+	if code < 400 || !w.quota.Exceeded() {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.replaced = true
+	log.Errorf("gateway request went over its fetch quota of %d blocks, %d bytes", w.quota.maxBlocks, w.quota.maxBytes)
+
+	h := w.ResponseWriter.Header()
+	for k := range h {
+		delete(h, k)
+	}
+	h.Set("Content-Type", jsonContentType)
+	w.ResponseWriter.WriteHeader(http.StatusBadGateway)
+	json.NewEncoder(w.ResponseWriter).Encode(retrievalLimitError{
+		Message:   errRetrievalLimit.Error(),
+		MaxBlocks: w.quota.maxBlocks,
+		MaxBytes:  w.quota.maxBytes,
+	})
+}
+
+func (w *quotaResponseWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -2,6 +2,7 @@ package corehttp
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
@@ -10,15 +11,19 @@ import (
 	"testing"
 	"time"
 
+	bserv "github.com/ipfs/go-ipfs/blockservice"
 	core "github.com/ipfs/go-ipfs/core"
 	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	path "github.com/ipfs/go-ipfs/path"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+	ft "github.com/ipfs/go-ipfs/unixfs"
 
+	ipldcbor "gx/ipfs/QmNrbCt8j9DT5W9Pmjy2SdudT9k8GpaDr4sRuFix3BXhgR/go-ipld-cbor"
 	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
 	id "gx/ipfs/QmRai5yZNL67pWCoznW7sBdFnqZrFULuJ5w8KhmRyhdgN4/go-libp2p/p2p/protocol/identify"
 )
@@ -129,6 +134,14 @@ func TestGatewayGet(t *testing.T) {
 	}
 	ns["/ipns/example.com"] = path.FromString("/ipfs/" + k)
 
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gateway.Hosts = map[string]config.GatewayHost{
+		"vhost.example.net": {Root: "/ipns/example.com"},
+	}
+
 	t.Log(ts.URL)
 	for _, test := range []struct {
 		host   string
@@ -142,6 +155,8 @@ func TestGatewayGet(t *testing.T) {
 		{"localhost:5001", "/ipns/nxdomain.example.com", http.StatusNotFound, "ipfs resolve -r /ipns/nxdomain.example.com: " + namesys.ErrResolveFailed.Error() + "\n"},
 		{"localhost:5001", "/ipns/example.com", http.StatusOK, "fnord"},
 		{"example.com", "/", http.StatusOK, "fnord"},
+		{"vhost.example.net", "/", http.StatusOK, "fnord"},
+		{"VHost.example.net:8080", "/", http.StatusOK, "fnord"},
 	} {
 		var c http.Client
 		r, err := http.NewRequest("GET", ts.URL+test.path, nil)
@@ -172,6 +187,93 @@ func TestGatewayGet(t *testing.T) {
 	}
 }
 
+func TestGatewayGetDagNode(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	nd, err := ipldcbor.FromJson(strings.NewReader(`{"hello":"world","list":[1,"two"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := n.DAG.Add(nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path   string
+		accept string
+		ctype  string
+		text   string
+	}{
+		{"/ipfs/" + c.String() + "/hello", "", "application/json", `"world"`},
+		{"/ipfs/" + c.String() + "/list/1", "application/json", "application/json", `"two"`},
+		{"/ipfs/" + c.String(), "application/cbor", "application/cbor", string(nd.RawData())},
+		{"/ipfs/" + c.String() + "/hello", "application/vnd.ipld.dag-json", "application/vnd.ipld.dag-json", `"world"`},
+		{"/ipfs/" + c.String(), "application/vnd.ipld.dag-cbor", "application/vnd.ipld.dag-cbor", string(nd.RawData())},
+		{"/ipfs/" + c.String() + "/hello", "application/json;q=0.5, application/vnd.ipld.dag-cbor", "application/vnd.ipld.dag-cbor", "\x65world"},
+		{"/ipfs/" + c.String() + "/hello", "text/html, application/vnd.ipld.dag-json;q=0.9, application/cbor;q=0.1", "application/vnd.ipld.dag-json", `"world"`},
+		{"/ipfs/" + c.String() + "/hello", "application/cbor;q=0", "application/json", `"world"`},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: got status %d: %s", test.path, res.StatusCode, body)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != test.ctype {
+			t.Fatalf("%s: expected content type %q, got %q", test.path, test.ctype, ct)
+		}
+		if string(body) != test.text {
+			t.Fatalf("%s: expected %q, got %q", test.path, test.text, body)
+		}
+	}
+
+	// each encoding has its own ETag
+	etags := make(map[string]bool)
+	for _, accept := range []string{"application/json", "application/vnd.ipld.dag-json", "application/vnd.ipld.dag-cbor"} {
+		req, err := http.NewRequest("GET", ts.URL+"/ipfs/"+c.String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", accept)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		etag := res.Header.Get("Etag")
+		if etag == "" || etags[etag] {
+			t.Fatalf("%s: expected a distinct ETag, got %q", accept, etag)
+		}
+		etags[etag] = true
+
+		req.Header.Set("If-None-Match", etag)
+		res, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotModified {
+			t.Fatalf("%s: expected status 304 for a matching ETag, got %d", accept, res.StatusCode)
+		}
+	}
+}
+
 func TestIPNSHostnameRedirect(t *testing.T) {
 	ns := mockNamesys{}
 	ts, n := newTestServerAndNode(t, ns)
@@ -525,3 +627,237 @@ func TestVersion(t *testing.T) {
 		t.Fatalf("response doesn't contain protocol version:\n%s", s)
 	}
 }
+
+func TestNoFetchGateway(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+
+	dh.Handler, err = makeHandler(n,
+		ts.Listener,
+		NoFetchGatewayOption(false, "/ipfs", "/ipns"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := coreunix.Add(n, strings.NewReader("fnord"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.Get(ts.URL + "/ipfs/" + k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || string(body) != "fnord" {
+		t.Fatalf("expected local content to be served, got %d: %q", res.StatusCode, body)
+	}
+
+	// not in the repo, must fail right away instead of being fetched
+	missing := "QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD"
+	res, err = http.Get(ts.URL + "/ipfs/" + missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		t.Fatal("expected missing content not to be served")
+	}
+}
+
+func TestGatewayFetchQuota(t *testing.T) {
+	remote, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create /ipfs/<root>/foo/file.txt on a node the gateway fetches from
+	_, dagn1, err := coreunix.AddWrapped(remote, strings.NewReader("1"), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, dagn2, err := coreunix.AddWrapped(remote, strings.NewReader("2"), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dagn1.(*dag.ProtoNode).AddNodeLink("foo", dagn2)
+	if _, err := remote.DAG.Add(dagn1); err != nil {
+		t.Fatal(err)
+	}
+	url := "/ipfs/" + dagn1.Cid().String() + "/foo/file.txt"
+
+	ts, n := newTestServerAndNode(t, mockNamesys{})
+	defer ts.Close()
+	n.Blocks = bserv.New(n.Blockstore, offline.Exchange(remote.Blockstore))
+
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// resolving the path fetches three blocks
+	cfg.Gateway.MaxFetchBlocks = 2
+	res, err := http.Get(ts.URL + url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e retrievalLimitError
+	err = json.NewDecoder(res.Body).Decode(&e)
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected a bad gateway response, got %d", res.StatusCode)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Message != errRetrievalLimit.Error() || e.MaxBlocks != 2 {
+		t.Fatalf("unexpected error response: %+v", e)
+	}
+
+	cfg.Gateway.MaxFetchBlocks = 3
+	res, err = http.Get(ts.URL + url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || string(body) != "2" {
+		t.Fatalf("expected the file to be served, got %d: %q", res.StatusCode, body)
+	}
+}
+
+func TestGatewayRootRedirect(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	k, err := coreunix.Add(n, strings.NewReader("fnord"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns["/ipns/example.com"] = path.FromString("/ipfs/" + k)
+
+	// changes to the config after the handler was made apply right away
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gateway.RootRedirect = "https://ipfs.io/"
+	cfg.Gateway.Hosts = map[string]config.GatewayHost{
+		"app.example.net":  {Root: "/ipns/example.com", RootRedirect: "/index.html"},
+		"site.example.net": {Root: "/ipns/example.com"},
+	}
+
+	for _, test := range []struct {
+		host     string
+		location string
+	}{
+		{"localhost:5001", "https://ipfs.io/"},
+		{"app.example.net", "/index.html"},
+		{"site.example.net", ""},
+		{"example.com", ""},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+"/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = test.host
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if test.location == "" {
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("%s: expected no redirect, got %d", test.host, res.StatusCode)
+			}
+			continue
+		}
+		if res.StatusCode != http.StatusFound {
+			t.Errorf("%s: expected redirect, got %d", test.host, res.StatusCode)
+		} else if loc := res.Header.Get("Location"); loc != test.location {
+			t.Errorf("%s: expected redirect to %s, got %s", test.host, test.location, loc)
+		}
+	}
+}
+
+func TestGatewayDownloadHeaders(t *testing.T) {
+	ts, n := newTestServerAndNode(t, nil)
+	defer ts.Close()
+
+	mtime := time.Unix(1500000000, 0)
+	fsn := &ft.FSNode{Type: ft.TFile, Data: []byte("fnord"), ModTime: mtime}
+	data, err := fsn.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := dag.NodeWithData(data)
+	if _, err := n.DAG.Add(file); err != nil {
+		t.Fatal(err)
+	}
+	dir := ft.EmptyDirNode()
+	if err := dir.AddNodeLink("résumé.txt", file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.DAG.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+	filePath := "/ipfs/" + file.Cid().String()
+
+	for _, test := range []struct {
+		path        string
+		disposition string
+	}{
+		{filePath, ""},
+		{filePath + "?filename=fnord.txt", `inline; filename="fnord.txt"`},
+		{filePath + "?download=true", "attachment"},
+		{filePath + "?download=true&filename=fnord.txt", `attachment; filename="fnord.txt"`},
+		{"/ipfs/" + dir.Cid().String() + "/r%C3%A9sum%C3%A9.txt", `inline; filename="r_sum_.txt"; filename*=UTF-8''r%C3%A9sum%C3%A9.txt`},
+	} {
+		res, err := http.Get(ts.URL + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", test.path, res.StatusCode)
+			continue
+		}
+		if disp := res.Header.Get("Content-Disposition"); disp != test.disposition {
+			t.Errorf("%s: expected Content-Disposition %q, got %q", test.path, test.disposition, disp)
+		}
+		if lm := res.Header.Get("Last-Modified"); lm != mtime.UTC().Format(http.TimeFormat) {
+			t.Errorf("%s: expected the stored modification time, got Last-Modified %q", test.path, lm)
+		}
+	}
+
+	req, err := http.NewRequest("GET", ts.URL+filePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-Modified-Since", mtime.UTC().Format(http.TimeFormat))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected status 304 for an unmodified file, got %d", res.StatusCode)
+	}
+}
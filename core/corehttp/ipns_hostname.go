@@ -7,13 +7,16 @@ import (
 	"strings"
 
 	"github.com/ipfs/go-ipfs/core"
+	path "github.com/ipfs/go-ipfs/path"
 
 	isd "gx/ipfs/QmZmmuAXgX73UQmX1jRKjTGmjzq24Jinqkq8vzkBtno4uX/go-is-domain"
 )
 
 // IPNSHostnameOption rewrites an incoming request if its Host: header contains
-// an IPNS name.
-// The rewritten request points at the resolved name on the gateway handler.
+// an IPNS name, or a hostname configured in Gateway.Hosts.
+// The rewritten request points at the resolved name (or the configured root)
+// on the gateway handler.
+// It also redirects requests for "/" according to the RootRedirect settings.
 func IPNSHostnameOption() ServeOption {
 	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
 		childMux := http.NewServeMux()
@@ -21,16 +24,48 @@ func IPNSHostnameOption() ServeOption {
 			ctx, cancel := context.WithCancel(n.Context())
 			defer cancel()
 
-			host := strings.SplitN(r.Host, ":", 2)[0]
-			if len(host) > 0 && isd.IsDomain(host) {
-				name := "/ipns/" + host
-				if _, err := n.Namesys.Resolve(ctx, name); err == nil {
-					r.Header["X-Ipns-Original-Path"] = []string{r.URL.Path}
-					r.URL.Path = name + r.URL.Path
+			host := requestHost(r)
+
+			// the config is looked up for every request so that changes
+			// made while the daemon runs apply right away.
+			cfg, err := n.Repo.Config()
+			if err != nil {
+				internalWebError(w, err)
+				return
+			}
+
+			if vhost, ok := cfg.Gateway.Hosts[host]; ok {
+				if r.URL.Path == "/" && vhost.RootRedirect != "" {
+					http.Redirect(w, r, vhost.RootRedirect, http.StatusFound)
+					return
+				}
+				root, err := path.ParsePath(vhost.Root)
+				if err != nil {
+					webError(w, "invalid root for host "+host, err, http.StatusInternalServerError)
+					return
 				}
+				r.Header["X-Ipns-Original-Path"] = []string{r.URL.Path}
+				r.URL.Path = strings.TrimRight(root.String(), "/") + r.URL.Path
+			} else if len(host) > 0 && isd.IsDomain(host) && resolves(ctx, n, "/ipns/"+host) {
+				r.Header["X-Ipns-Original-Path"] = []string{r.URL.Path}
+				r.URL.Path = "/ipns/" + host + r.URL.Path
+			} else if r.URL.Path == "/" && cfg.Gateway.RootRedirect != "" {
+				http.Redirect(w, r, cfg.Gateway.RootRedirect, http.StatusFound)
+				return
 			}
 			childMux.ServeHTTP(w, r)
 		})
 		return childMux, nil
 	}
 }
+
+func resolves(ctx context.Context, n *core.IpfsNode, name string) bool {
+	_, err := n.Namesys.Resolve(ctx, name)
+	return err == nil
+}
+
+// requestHost returns the lowercased hostname a request was made to,
+// without port.
+func requestHost(r *http.Request) string {
+	return strings.ToLower(strings.SplitN(r.Host, ":", 2)[0])
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 
 	prometheus "gx/ipfs/QmX3QZ5jHEPidwUrymXV1iSCSUhdGxj15sm2gP4jKMef7B/client_golang/prometheus"
 )
@@ -30,6 +31,35 @@ var (
 	peersTotalMetric = prometheus.NewDesc(
 		prometheus.BuildFQName("ipfs", "p2p", "peers_total"),
 		"Number of connected peers", []string{"transport"}, nil)
+
+	protoStreamsOpenMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "p2p", "protocol_streams_open"),
+		"Number of open streams of each protocol", []string{"protocol"}, nil)
+	protoStreamsTotalMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "p2p", "protocol_streams_total"),
+		"Number of streams of each protocol opened since start", []string{"protocol"}, nil)
+	protoBytesTotalMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "p2p", "protocol_bytes_total"),
+		"Bytes sent and received with each protocol", []string{"protocol", "direction"}, nil)
+
+	gcLastCheckMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "gc_last_check_timestamp_seconds"),
+		"Time the periodic gc last checked the repo size", nil, nil)
+	gcLastRunMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "gc_last_run_timestamp_seconds"),
+		"End time of the last gc run", nil, nil)
+	gcLastDurationMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "gc_last_run_duration_seconds"),
+		"Duration of the last gc run", nil, nil)
+	gcLastBlocksMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "gc_last_run_blocks_removed"),
+		"Number of blocks removed by the last gc run", nil, nil)
+	gcLastBytesMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "gc_last_run_bytes_reclaimed"),
+		"Number of bytes reclaimed by the last gc run", nil, nil)
+	gcLastErrorsMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "gc_last_run_errors"),
+		"Number of errors hit by the last gc run", nil, nil)
 )
 
 type IpfsNodeCollector struct {
@@ -38,6 +68,15 @@ type IpfsNodeCollector struct {
 
 func (_ IpfsNodeCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- peersTotalMetric
+	ch <- protoStreamsOpenMetric
+	ch <- protoStreamsTotalMetric
+	ch <- protoBytesTotalMetric
+	ch <- gcLastCheckMetric
+	ch <- gcLastRunMetric
+	ch <- gcLastDurationMetric
+	ch <- gcLastBlocksMetric
+	ch <- gcLastBytesMetric
+	ch <- gcLastErrorsMetric
 }
 
 func (c IpfsNodeCollector) Collect(ch chan<- prometheus.Metric) {
@@ -49,6 +88,60 @@ func (c IpfsNodeCollector) Collect(ch chan<- prometheus.Metric) {
 			tr,
 		)
 	}
+
+	c.collectProtocols(ch)
+	c.collectGC(ch)
+}
+
+func (c IpfsNodeCollector) collectProtocols(ch chan<- prometheus.Metric) {
+	if c.Node.PeerHost == nil || c.Node.ProtoStats == nil {
+		return
+	}
+
+	for _, st := range c.Node.ProtoStats.Stats(c.Node.PeerHost.Network(), c.Node.Reporter) {
+		p := string(st.Protocol)
+		ch <- prometheus.MustNewConstMetric(protoStreamsOpenMetric, prometheus.GaugeValue,
+			float64(st.StreamsOpen), p)
+		ch <- prometheus.MustNewConstMetric(protoStreamsTotalMetric, prometheus.CounterValue,
+			float64(st.StreamsTotal), p)
+		if c.Node.Reporter != nil {
+			ch <- prometheus.MustNewConstMetric(protoBytesTotalMetric, prometheus.CounterValue,
+				float64(st.TotalIn), p, "in")
+			ch <- prometheus.MustNewConstMetric(protoBytesTotalMetric, prometheus.CounterValue,
+				float64(st.TotalOut), p, "out")
+		}
+	}
+}
+
+func (c IpfsNodeCollector) collectGC(ch chan<- prometheus.Metric) {
+	h, err := corerepo.GetGCHistory(c.Node.Repo)
+	if err != nil {
+		log.Error("reading gc history: ", err)
+		return
+	}
+
+	if !h.LastCheck.IsZero() {
+		ch <- prometheus.MustNewConstMetric(gcLastCheckMetric, prometheus.GaugeValue,
+			float64(h.LastCheck.Unix()))
+	}
+
+	if len(h.Runs) == 0 {
+		return
+	}
+	last := h.Runs[0]
+	gauges := []struct {
+		desc *prometheus.Desc
+		val  float64
+	}{
+		{gcLastRunMetric, float64(last.End.Unix())},
+		{gcLastDurationMetric, last.End.Sub(last.Start).Seconds()},
+		{gcLastBlocksMetric, float64(last.BlocksRemoved)},
+		{gcLastBytesMetric, float64(last.BytesReclaimed)},
+		{gcLastErrorsMetric, float64(len(last.Errors))},
+	}
+	for _, g := range gauges {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, g.val)
+	}
 }
 
 func (c IpfsNodeCollector) PeersTotalValues() map[string]float64 {
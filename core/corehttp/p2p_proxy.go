@@ -0,0 +1,117 @@
+package corehttp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// P2PProxyOption serves /p2p/<peer>/http/<path> by forwarding the requests
+// to the HTTP service the peer exposes with 'ipfs p2p http expose'.
+func P2PProxyOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		streams := coreapi.NewCoreAPI(n).Stream()
+		proxy := &httputil.ReverseProxy{
+			// the request is rewritten by the handler
+			Director:  func(*http.Request) {},
+			Transport: &p2pTransport{streams},
+		}
+
+		mux.HandleFunc("/p2p/", func(w http.ResponseWriter, r *http.Request) {
+			p, rest, err := parseP2PProxyPath(r.URL.Path)
+			if err != nil {
+				webErrorWithCode(w, "invalid p2p proxy path", err, http.StatusBadRequest)
+				return
+			}
+
+			out := new(http.Request)
+			*out = *r
+			u := *r.URL
+			u.Scheme = "http"
+			u.Host = p.Pretty()
+			u.Path = rest
+			u.RawPath = ""
+			out.URL = &u
+			out.Host = p.Pretty()
+			// this node speaks for itself on the other end
+			out.Header = make(http.Header, len(r.Header))
+			for k, v := range r.Header {
+				out.Header[k] = v
+			}
+			out.Header.Del(core.P2PHTTPPeerHeader)
+
+			proxy.ServeHTTP(w, out)
+		})
+		return mux, nil
+	}
+}
+
+// parseP2PProxyPath splits /p2p/<peer>/http/<path> in the peer and /<path>.
+func parseP2PProxyPath(p string) (peer.ID, string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(p, "/p2p/"), "/", 3)
+	if len(parts) < 2 || parts[1] != "http" {
+		return "", "", fmt.Errorf("%s is not of the form /p2p/<peer>/http/<path>", p)
+	}
+	id, err := peer.IDB58Decode(parts[0])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid peer ID %q: %s", parts[0], err)
+	}
+	rest := "/"
+	if len(parts) == 3 {
+		rest += parts[2]
+	}
+	return id, rest, nil
+}
+
+// p2pTransport sends each request on its own stream to the peer of its host.
+type p2pTransport struct {
+	streams coreiface.StreamAPI
+}
+
+func (t *p2pTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	p, err := peer.IDB58Decode(r.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := t.streams.Open(r.Context(), p, core.P2PHTTPProtocol)
+	if err != nil {
+		return nil, err
+	}
+
+	// one request per stream, the other end closes it once it responded
+	r.Close = true
+	if err := r.Write(s); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(s), r)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	resp.Body = &streamBody{resp.Body, s}
+	return resp, nil
+}
+
+// streamBody closes the stream a response was read from along with its body.
+type streamBody struct {
+	io.ReadCloser
+	s io.Closer
+}
+
+func (b *streamBody) Close() error {
+	b.ReadCloser.Close()
+	return b.s.Close()
+}
@@ -0,0 +1,96 @@
+package corehttp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	core "github.com/ipfs/go-ipfs/core"
+	mock "github.com/ipfs/go-ipfs/core/mock"
+
+	mocknet "gx/ipfs/QmRai5yZNL67pWCoznW7sBdFnqZrFULuJ5w8KhmRyhdgN4/go-libp2p/p2p/net/mock"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestP2PProxy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+	var nodes []*core.IpfsNode
+	for i := 0; i < 3; i++ {
+		nd, err := core.NewNode(ctx, &core.BuildCfg{
+			Online: true,
+			Host:   mock.MockHostOption(mn),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, nd)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	server, client, other := nodes[0], nodes[1], nodes[2]
+
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI() + " " + r.Header.Get(core.P2PHTTPPeerHeader)))
+	}))
+	defer service.Close()
+	target, err := url.Parse(service.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.P2PHTTP.Expose(target, []peer.ID{client.Identity})
+
+	get := func(n *core.IpfsNode, p string) (int, string) {
+		h, err := makeHandler(n, nil, P2PProxyOption())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts := httptest.NewServer(h)
+		defer ts.Close()
+
+		req, err := http.NewRequest("GET", ts.URL+p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the header can't be forged by the client
+		req.Header.Set(core.P2PHTTPPeerHeader, "QmForged")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, string(body)
+	}
+
+	code, body := get(client, "/p2p/"+server.Identity.Pretty()+"/http/api/status?full=1")
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", code, body)
+	}
+	if expected := "/api/status?full=1 " + client.Identity.Pretty(); body != expected {
+		t.Fatalf("expected %q, got %q", expected, body)
+	}
+
+	if code, _ := get(other, "/p2p/"+server.Identity.Pretty()+"/http/"); code != http.StatusBadGateway {
+		t.Fatalf("expected a peer not allowed to get status 502, got %d", code)
+	}
+	if code, _ := get(client, "/p2p/"+server.Identity.Pretty()+"/ftp/"); code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an invalid path, got %d", code)
+	}
+
+	if err := server.P2PHTTP.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := get(client, "/p2p/"+server.Identity.Pretty()+"/http/"); code != http.StatusBadGateway {
+		t.Fatalf("expected status 502 once the service is closed, got %d", code)
+	}
+}
@@ -0,0 +1,292 @@
+package corehttp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+)
+
+// tsPacketSize is the size of MPEG transport stream packets.
+const tsPacketSize = 188
+
+// tsSegmentSize is the size of the byte ranges listed in HLS playlists and
+// DASH manifests, about a megabyte of whole packets.
+var tsSegmentSize int64 = 5577 * tsPacketSize
+
+// tsScanPackets is how many packets are read at a segment boundary to find
+// a timestamp. Streams carry one at least every 100ms.
+var tsScanPackets = 2048
+
+// pcrWrap is the period of the 33 bit PCR base, in seconds.
+const pcrWrap = float64(1<<33) / 90000
+
+var errNotTransportStream = errors.New("not an MPEG transport stream")
+var errNoTimestamps = errors.New("transport stream carries no timestamps")
+
+// serveMediaPlaylist serves a playlist of the transport stream r in the given
+// format, "hls" or "dash".
+func (i *gatewayHandler) serveMediaPlaylist(w http.ResponseWriter, format, name string, r io.ReadSeeker) {
+	if !i.config.MediaPlaylists {
+		webErrorWithCode(w, "playlist", errors.New("media playlists are disabled on this gateway"), http.StatusNotImplemented)
+		return
+	}
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		internalWebError(w, err)
+		return
+	}
+
+	var pl []byte
+	var ctype string
+	switch format {
+	case "hls":
+		pl, err = hlsPlaylist(r, size, name)
+		ctype = "application/vnd.apple.mpegurl"
+	case "dash":
+		pl, err = dashManifest(r, size, name)
+		ctype = "application/dash+xml"
+	default:
+		webErrorWithCode(w, "playlist", fmt.Errorf("unknown playlist format %q, expected hls or dash", format), http.StatusBadRequest)
+		return
+	}
+	switch err {
+	case nil:
+	case errNotTransportStream, errNoTimestamps:
+		webErrorWithCode(w, "playlist", err, http.StatusUnsupportedMediaType)
+		return
+	default:
+		internalWebError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", ctype)
+	w.Write(pl)
+}
+
+// hlsPlaylist returns an HLS playlist splitting the transport stream r of the
+// given size in byte ranges of the file at uri.
+func hlsPlaylist(r io.ReadSeeker, size int64, uri string) ([]byte, error) {
+	offsets, durations, err := tsSegments(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var target float64
+	for _, d := range durations {
+		target = math.Max(target, d)
+	}
+
+	link := (&url.URL{Path: uri}).String()
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "#EXTM3U")
+	fmt.Fprintln(&buf, "#EXT-X-VERSION:4")
+	fmt.Fprintln(&buf, "#EXT-X-PLAYLIST-TYPE:VOD")
+	fmt.Fprintf(&buf, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(target)))
+	fmt.Fprintln(&buf, "#EXT-X-MEDIA-SEQUENCE:0")
+	for k, d := range durations {
+		fmt.Fprintf(&buf, "#EXTINF:%.3f,\n", d)
+		fmt.Fprintf(&buf, "#EXT-X-BYTERANGE:%d@%d\n", offsets[k+1]-offsets[k], offsets[k])
+		fmt.Fprintln(&buf, link)
+	}
+	fmt.Fprintln(&buf, "#EXT-X-ENDLIST")
+	return buf.Bytes(), nil
+}
+
+// dashManifest returns a static DASH manifest, in the MPEG-2 TS profile,
+// splitting the transport stream r of the given size in byte ranges of the
+// file at uri. The segment durations are in milliseconds, rounded so that
+// they add up to the length of the stream.
+func dashManifest(r io.ReadSeeker, size int64, uri string) ([]byte, error) {
+	offsets, durations, err := tsSegments(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var total float64
+	for _, d := range durations {
+		total += d
+	}
+
+	var link bytes.Buffer
+	xml.EscapeText(&link, []byte((&url.URL{Path: uri}).String()))
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintf(&buf, `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" profiles="urn:mpeg:dash:profile:mp2t-simple:2011" mediaPresentationDuration="PT%.3fS" minBufferTime="PT%dS">`+"\n",
+		total, int(math.Ceil(durations[0])))
+	fmt.Fprintln(&buf, `  <Period start="PT0S">`)
+	fmt.Fprintln(&buf, `    <AdaptationSet mimeType="video/mp2t" segmentAlignment="true">`)
+	fmt.Fprintf(&buf, `      <Representation id="0" bandwidth="%d">`+"\n", int64(float64(size*8)/total))
+	fmt.Fprintf(&buf, "        <BaseURL>%s</BaseURL>\n", link.Bytes())
+	fmt.Fprintln(&buf, `        <SegmentList timescale="1000">`)
+	fmt.Fprintln(&buf, `          <SegmentTimeline>`)
+	var elapsed float64
+	var at int64
+	for _, d := range durations {
+		elapsed += d
+		end := int64(math.Floor(elapsed*1000 + 0.5))
+		fmt.Fprintf(&buf, `            <S d="%d"/>`+"\n", end-at)
+		at = end
+	}
+	fmt.Fprintln(&buf, `          </SegmentTimeline>`)
+	for k := range durations {
+		fmt.Fprintf(&buf, `          <SegmentURL mediaRange="%d-%d"/>`+"\n", offsets[k], offsets[k+1]-1)
+	}
+	fmt.Fprintln(&buf, `        </SegmentList>`)
+	fmt.Fprintln(&buf, `      </Representation>`)
+	fmt.Fprintln(&buf, `    </AdaptationSet>`)
+	fmt.Fprintln(&buf, `  </Period>`)
+	fmt.Fprintln(&buf, `</MPD>`)
+	return buf.Bytes(), nil
+}
+
+// tsSegments splits the transport stream r of the given size in segments of
+// about tsSegmentSize, and returns their offsets, followed by the size, and
+// their durations in seconds. The durations come from the stream clock (PCR)
+// found at the segment boundaries, nothing is decoded.
+func tsSegments(r io.ReadSeeker, size int64) ([]int64, []float64, error) {
+	if size < tsPacketSize {
+		return nil, nil, errNotTransportStream
+	}
+
+	pcrPID := -1
+	var offsets []int64
+	var times []float64
+	for off := int64(0); off < size; off += tsSegmentSize {
+		t, pid, err := firstPCR(r, off, size, pcrPID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pcrPID < 0 {
+			pcrPID = pid
+		}
+		offsets = append(offsets, off)
+		times = append(times, t)
+	}
+	if pcrPID < 0 {
+		return nil, nil, errNoTimestamps
+	}
+
+	end, err := lastPCR(r, size, pcrPID)
+	if err != nil {
+		return nil, nil, err
+	}
+	times = append(times, end)
+	offsets = append(offsets, size)
+
+	// the average rate stands in where the clock is missing or jumps
+	var total float64
+	for k := 0; k+1 < len(times); k++ {
+		if d, ok := clockDiff(times[k], times[k+1]); ok {
+			total += d
+		}
+	}
+	rate := total / float64(size)
+	if rate == 0 {
+		return nil, nil, errNoTimestamps
+	}
+
+	durations := make([]float64, len(offsets)-1)
+	for k := range durations {
+		d, ok := clockDiff(times[k], times[k+1])
+		if !ok || d > 10*rate*float64(offsets[k+1]-offsets[k]) {
+			d = rate * float64(offsets[k+1]-offsets[k])
+		}
+		durations[k] = d
+	}
+	return offsets, durations, nil
+}
+
+// clockDiff returns the time between two PCRs, allowing for the clock to wrap
+// around once. Timestamps that are missing or going backwards aren't usable.
+func clockDiff(from, to float64) (float64, bool) {
+	if from < 0 || to < 0 {
+		return 0, false
+	}
+	d := to - from
+	if d < 0 {
+		d += pcrWrap
+	}
+	if d > pcrWrap/2 {
+		return 0, false
+	}
+	return d, true
+}
+
+// firstPCR returns the first PCR of the stream found at off, in seconds, or
+// -1 if there is none close by. Unless pid is negative, only the clock of
+// that pid is considered.
+func firstPCR(r io.ReadSeeker, off, size int64, pid int) (float64, int, error) {
+	if _, err := r.Seek(off, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+
+	pkt := make([]byte, tsPacketSize)
+	for n := 0; n < tsScanPackets && off < size; n++ {
+		if _, err := io.ReadFull(r, pkt); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				break
+			}
+			return 0, 0, err
+		}
+		off += tsPacketSize
+
+		if pkt[0] != 0x47 {
+			return 0, 0, errNotTransportStream
+		}
+		if t, p, ok := packetPCR(pkt); ok && (pid < 0 || p == pid) {
+			return t, p, nil
+		}
+	}
+	return -1, pid, nil
+}
+
+// lastPCR returns the last PCR of pid in the end of the stream, in seconds, or
+// -1 if there is none.
+func lastPCR(r io.ReadSeeker, size int64, pid int) (float64, error) {
+	off := size - int64(tsScanPackets)*tsPacketSize
+	if off < 0 {
+		off = 0
+	}
+	off -= off % tsPacketSize
+	if _, err := r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	last := -1.0
+	pkt := make([]byte, tsPacketSize)
+	for {
+		_, err := io.ReadFull(r, pkt)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return last, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if pkt[0] != 0x47 {
+			return 0, errNotTransportStream
+		}
+		if t, p, ok := packetPCR(pkt); ok && p == pid {
+			last = t
+		}
+	}
+}
+
+// packetPCR returns the PCR carried in the adaptation field of a packet.
+func packetPCR(pkt []byte) (float64, int, bool) {
+	pid := int(pkt[1]&0x1f)<<8 | int(pkt[2])
+	if pkt[3]&0x20 == 0 || pkt[4] < 7 || pkt[5]&0x10 == 0 {
+		return 0, 0, false
+	}
+
+	base := uint64(pkt[6])<<25 | uint64(pkt[7])<<17 | uint64(pkt[8])<<9 | uint64(pkt[9])<<1 | uint64(pkt[10])>>7
+	ext := uint64(pkt[10]&1)<<8 | uint64(pkt[11])
+	return float64(base*300+ext) / 27000000, pid, true
+}
@@ -0,0 +1,121 @@
+package corehttp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// tsStream returns n packets of pid 0x100, one in every ten carrying a PCR
+// advancing by step seconds.
+func tsStream(n int, step float64) []byte {
+	var buf bytes.Buffer
+	for k := 0; k < n; k++ {
+		pkt := make([]byte, tsPacketSize)
+		pkt[0] = 0x47
+		pkt[1] = 0x01
+		pkt[3] = 0x10
+		if k%10 == 0 {
+			base := uint64(float64(k/10) * step * 90000)
+			pkt[3] = 0x30
+			pkt[4] = 7
+			pkt[5] = 0x10
+			pkt[6] = byte(base >> 25)
+			pkt[7] = byte(base >> 17)
+			pkt[8] = byte(base >> 9)
+			pkt[9] = byte(base >> 1)
+			pkt[10] = byte(base<<7) | 0x7e
+		}
+		buf.Write(pkt)
+	}
+	return buf.Bytes()
+}
+
+func TestHLSPlaylist(t *testing.T) {
+	defer func(size int64) { tsSegmentSize = size }(tsSegmentSize)
+	tsSegmentSize = 100 * tsPacketSize
+
+	// 250 packets, a PCR every 10 packets 0.1s apart: 2.5s of stream
+	data := tsStream(250, 0.1)
+	pl, err := hlsPlaylist(bytes.NewReader(data), int64(len(data)), "video.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		"#EXTM3U",
+		"#EXT-X-VERSION:4",
+		"#EXT-X-PLAYLIST-TYPE:VOD",
+		"#EXT-X-TARGETDURATION:1",
+		"#EXT-X-MEDIA-SEQUENCE:0",
+		"#EXTINF:1.000,",
+		"#EXT-X-BYTERANGE:18800@0",
+		"video.ts",
+		"#EXTINF:1.000,",
+		"#EXT-X-BYTERANGE:18800@18800",
+		"video.ts",
+		"#EXTINF:0.400,",
+		"#EXT-X-BYTERANGE:9400@37600",
+		"video.ts",
+		"#EXT-X-ENDLIST",
+		"",
+	}, "\n")
+	if string(pl) != expected {
+		t.Fatalf("wrong playlist:\n%s\nexpected:\n%s", pl, expected)
+	}
+}
+
+func TestDASHManifest(t *testing.T) {
+	defer func(size int64) { tsSegmentSize = size }(tsSegmentSize)
+	tsSegmentSize = 100 * tsPacketSize
+
+	data := tsStream(250, 0.1)
+	mpd, err := dashManifest(bytes.NewReader(data), int64(len(data)), "a&b.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" profiles="urn:mpeg:dash:profile:mp2t-simple:2011" mediaPresentationDuration="PT2.400S" minBufferTime="PT1S">`,
+		`  <Period start="PT0S">`,
+		`    <AdaptationSet mimeType="video/mp2t" segmentAlignment="true">`,
+		`      <Representation id="0" bandwidth="156666">`,
+		`        <BaseURL>a&amp;b.ts</BaseURL>`,
+		`        <SegmentList timescale="1000">`,
+		`          <SegmentTimeline>`,
+		`            <S d="1000"/>`,
+		`            <S d="1000"/>`,
+		`            <S d="400"/>`,
+		`          </SegmentTimeline>`,
+		`          <SegmentURL mediaRange="0-18799"/>`,
+		`          <SegmentURL mediaRange="18800-37599"/>`,
+		`          <SegmentURL mediaRange="37600-46999"/>`,
+		`        </SegmentList>`,
+		`      </Representation>`,
+		`    </AdaptationSet>`,
+		`  </Period>`,
+		`</MPD>`,
+		"",
+	}, "\n")
+	if string(mpd) != expected {
+		t.Fatalf("wrong manifest:\n%s\nexpected:\n%s", mpd, expected)
+	}
+}
+
+func TestHLSPlaylistNotTransportStream(t *testing.T) {
+	data := bytes.Repeat([]byte("not a video "), 100)
+	_, err := hlsPlaylist(bytes.NewReader(data), int64(len(data)), "file.txt")
+	if err != errNotTransportStream {
+		t.Fatalf("expected %q, got %v", errNotTransportStream, err)
+	}
+
+	data = tsStream(20, 0)
+	for k := 0; k < len(data); k += tsPacketSize {
+		data[k+3] = 0x10
+	}
+	_, err = hlsPlaylist(bytes.NewReader(data), int64(len(data)), "video.ts")
+	if err != errNoTimestamps {
+		t.Fatalf("expected %q, got %v", errNoTimestamps, err)
+	}
+}
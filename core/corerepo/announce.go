@@ -0,0 +1,256 @@
+package corerepo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	pin "github.com/ipfs/go-ipfs/pin"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+// AnnounceRulesRefresh is how long the blocks matched by the announce rules
+// are cached. Pinning or unpinning clears the cache, blocks written to MFS
+// since are matched once it expires.
+var AnnounceRulesRefresh = 5 * time.Minute
+
+func init() {
+	err := core.RegisterAnnounceFilter(core.RulesAnnounceFilter, func(n *core.IpfsNode) (exchange.AnnounceFilter, error) {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		return NewAnnounceRules(n, cfg.Reprovider.Rules)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+type announceRule struct {
+	match    string
+	arg      string
+	announce bool
+}
+
+// AnnounceRules is the announce filter applying the rules of the Reprovider
+// config.
+type AnnounceRules struct {
+	node  *core.IpfsNode
+	rules []announceRule
+
+	// updateLk is held while collecting the blocks matched, so that they
+	// are only collected once at a time. lk only guards the fields below,
+	// and is never held while walking dags.
+	updateLk sync.Mutex
+
+	lk      sync.Mutex
+	sets    []*cid.Set // blocks matched by each rule, nil for "all"
+	updated time.Time
+	// pinsVersion is incremented on every pin event, setsVersion is its
+	// value when the sets were collected.
+	pinsVersion uint64
+	setsVersion uint64
+}
+
+// NewAnnounceRules parses the announce rules of the config.
+func NewAnnounceRules(n *core.IpfsNode, rules []config.AnnounceRule) (*AnnounceRules, error) {
+	ar := &AnnounceRules{node: n}
+	for _, r := range rules {
+		match, arg := r.Match, ""
+		if i := strings.Index(match, ":"); i >= 0 {
+			match, arg = match[:i], match[i+1:]
+		}
+
+		switch match {
+		case "all", "pinned", "pin-roots", "named-pins":
+			if arg != "" {
+				return nil, fmt.Errorf("announce rule %q takes no argument", r.Match)
+			}
+		case "mfs":
+			if !strings.HasPrefix(arg, "/") {
+				return nil, fmt.Errorf("announce rule %q needs an absolute MFS path", r.Match)
+			}
+		default:
+			return nil, fmt.Errorf("unknown announce rule %q", r.Match)
+		}
+		ar.rules = append(ar.rules, announceRule{match: match, arg: arg, announce: r.Announce})
+	}
+
+	if n.Pinning != nil {
+		go ar.watchPins(n.Context())
+	}
+	return ar, nil
+}
+
+// watchPins clears the cache of the blocks matched when pins are added or
+// removed, until ctx is done.
+func (ar *AnnounceRules) watchPins(ctx context.Context) {
+	for {
+		events := ar.node.Pinning.Events().Subscribe(ctx)
+		for ev := range events {
+			if ev.Type == pin.EventFetched {
+				continue
+			}
+			ar.lk.Lock()
+			ar.pinsVersion++
+			ar.lk.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		// the subscription fell behind and was dropped, events may have
+		// been missed
+		ar.lk.Lock()
+		ar.pinsVersion++
+		ar.lk.Unlock()
+	}
+}
+
+// Announce applies the first rule matching c, and announces c if none does.
+func (ar *AnnounceRules) Announce(ctx context.Context, c *cid.Cid) (bool, error) {
+	sets, err := ar.matched(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for i, r := range ar.rules {
+		if r.match == "all" || sets[i].Has(c) {
+			return r.announce, nil
+		}
+	}
+	return true, nil
+}
+
+// matched returns the blocks matched by each rule, collected again if pins
+// changed since, or if they are older than AnnounceRulesRefresh.
+func (ar *AnnounceRules) matched(ctx context.Context) ([]*cid.Set, error) {
+	ar.updateLk.Lock()
+	defer ar.updateLk.Unlock()
+
+	ar.lk.Lock()
+	sets, version := ar.sets, ar.pinsVersion
+	fresh := sets != nil && ar.setsVersion == version && time.Since(ar.updated) <= AnnounceRulesRefresh
+	ar.lk.Unlock()
+	if fresh {
+		return sets, nil
+	}
+
+	sets, err := ar.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// pins changed during the walk are collected on the next call
+	ar.lk.Lock()
+	ar.sets, ar.setsVersion, ar.updated = sets, version, time.Now()
+	ar.lk.Unlock()
+	return sets, nil
+}
+
+// collect collects the blocks matched by each rule. The dags are only read
+// from the local blockstore.
+func (ar *AnnounceRules) collect(ctx context.Context) ([]*cid.Set, error) {
+	n := ar.node
+	ls := n.DAG.GetOfflineLinkService()
+	getLinks := func(ctx context.Context, c *cid.Cid) ([]*node.Link, error) {
+		links, err := ls.GetLinks(ctx, c)
+		if err == dag.ErrNotFound {
+			return nil, nil
+		}
+		return links, err
+	}
+
+	directKeys, err := n.Pinning.DirectKeys()
+	if err != nil {
+		return nil, err
+	}
+	recursiveKeys, err := n.Pinning.RecursiveKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]*cid.Set, len(ar.rules))
+	for i, r := range ar.rules {
+		if r.match == "all" {
+			continue
+		}
+
+		set := cid.NewSet()
+		switch r.match {
+		case "pinned":
+			for _, c := range directKeys {
+				set.Add(c)
+			}
+			if err := gc.Descendants(ctx, getLinks, set, recursiveKeys); err != nil {
+				return nil, err
+			}
+		case "pin-roots":
+			for _, c := range directKeys {
+				set.Add(c)
+			}
+			for _, c := range recursiveKeys {
+				set.Add(c)
+			}
+		case "named-pins":
+			named := func(c *cid.Cid) (bool, error) {
+				info, err := GetPinInfo(n.Repo, c)
+				return info != nil && info.Name != "", err
+			}
+			for _, c := range directKeys {
+				ok, err := named(c)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					set.Add(c)
+				}
+			}
+			var roots []*cid.Cid
+			for _, c := range recursiveKeys {
+				ok, err := named(c)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					roots = append(roots, c)
+				}
+			}
+			if err := gc.Descendants(ctx, getLinks, set, roots); err != nil {
+				return nil, err
+			}
+		case "mfs":
+			if n.FilesRoot == nil {
+				break
+			}
+			fsn, err := mfs.Lookup(n.FilesRoot, r.arg)
+			if err != nil {
+				// nothing is written there yet
+				break
+			}
+			nd, err := fsn.GetNode()
+			if err != nil {
+				return nil, err
+			}
+			if err := gc.Descendants(ctx, getLinks, set, []*cid.Cid{nd.Cid()}); err != nil {
+				return nil, err
+			}
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
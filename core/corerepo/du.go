@@ -0,0 +1,195 @@
+package corerepo
+
+import (
+	"context"
+	"time"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/core"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+// DiskUsage is the local storage used by the dag under a root.
+type DiskUsage struct {
+	Root string
+
+	// Blocks and Size count the blocks of the dag stored locally.
+	Blocks uint64
+	Size   uint64
+
+	// SharedBlocks and SharedSize count the stored blocks that are also
+	// used by other pins or by the files root. They would not be freed if
+	// the root was unpinned.
+	SharedBlocks uint64
+	SharedSize   uint64
+
+	// Missing counts the blocks of the dag that are not stored locally.
+	// The children of missing blocks are unknown, and not counted.
+	Missing uint64
+}
+
+// RepoDiskUsage returns the local storage used by the dag under root. The
+// dag is only read from the local blockstore.
+func RepoDiskUsage(ctx context.Context, n *core.IpfsNode, root *cid.Cid) (*DiskUsage, error) {
+	ls := n.DAG.GetOfflineLinkService()
+
+	// missing blocks are counted below, don't fail on them
+	getLinks := func(ctx context.Context, c *cid.Cid) ([]*node.Link, error) {
+		links, err := ls.GetLinks(ctx, c)
+		if err == dag.ErrNotFound {
+			return nil, nil
+		}
+		return links, err
+	}
+
+	set := cid.NewSet()
+	if err := gc.Descendants(ctx, getLinks, set, []*cid.Cid{root}); err != nil {
+		return nil, err
+	}
+
+	shared, err := otherRoots(ctx, n, root, getLinks)
+	if err != nil {
+		return nil, err
+	}
+
+	du := &DiskUsage{Root: root.String()}
+	for _, c := range set.Keys() {
+		blk, err := n.Blockstore.Get(c)
+		switch err {
+		case nil:
+		case bstore.ErrNotFound:
+			du.Missing++
+			continue
+		default:
+			return nil, err
+		}
+
+		size := uint64(len(blk.RawData()))
+		du.Blocks++
+		du.Size += size
+		if shared.Has(c) {
+			du.SharedBlocks++
+			du.SharedSize += size
+		}
+	}
+	return du, nil
+}
+
+// DagSize returns the size of the blocks of the dag under root stored
+// locally, each block counted once. The dag is only read from the local
+// blockstore, its missing blocks are skipped.
+func DagSize(ctx context.Context, n *core.IpfsNode, root *cid.Cid) (uint64, error) {
+	var size uint64
+	err := walkLocalDag(ctx, n, root, cid.NewSet(), func(nd node.Node) {
+		size += uint64(len(nd.RawData()))
+	}, func(*cid.Cid) {})
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// LocalBlocks counts the blocks of the dag under root which are stored
+// locally, and the ones which are missing. The children of missing blocks
+// are unknown, and not counted.
+func LocalBlocks(ctx context.Context, n *core.IpfsNode, root *cid.Cid) (stored, missing uint64, err error) {
+	err = walkLocalDag(ctx, n, root, cid.NewSet(), func(node.Node) {
+		stored++
+	}, func(*cid.Cid) {
+		missing++
+	})
+	return stored, missing, err
+}
+
+// walkLocalDag calls found on each block of the dag under root stored
+// locally, and missing on the other ones, once each. The blocks of seen are
+// skipped, and the ones walked added to it, so that the dags of several roots
+// can be walked once.
+func walkLocalDag(ctx context.Context, n *core.IpfsNode, root *cid.Cid, seen *cid.Set, found func(node.Node), missing func(*cid.Cid)) error {
+	ds := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+
+	var walk func(c *cid.Cid) error
+	walk = func(c *cid.Cid) error {
+		// local traversals never block on ctx, check it so they can be canceled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		nd, err := ds.Get(ctx, c)
+		switch err {
+		case nil:
+		case dag.ErrNotFound:
+			missing(c)
+			return nil
+		default:
+			return err
+		}
+
+		found(nd)
+		for _, l := range nd.Links() {
+			if seen.Visit(l.Cid) {
+				if err := walk(l.Cid); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if !seen.Visit(root) {
+		return nil
+	}
+	return walk(root)
+}
+
+// otherRoots returns the blocks kept by the pins other than root, by the
+// files root and by the retained roots.
+func otherRoots(ctx context.Context, n *core.IpfsNode, root *cid.Cid, getLinks dag.GetLinks) (*cid.Set, error) {
+	recursiveKeys, err := n.Pinning.RecursiveKeys()
+	if err != nil {
+		return nil, err
+	}
+	directKeys, err := n.Pinning.DirectKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []*cid.Cid
+	for _, c := range recursiveKeys {
+		if !c.Equals(root) {
+			roots = append(roots, c)
+		}
+	}
+
+	if n.FilesRoot != nil {
+		best, err := BestEffortRoots(n.FilesRoot)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, best...)
+	}
+	roots = append(roots, filesRootHistory(n)...)
+
+	retained, err := RetainedRoots(n.Repo, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	roots = append(roots, retained...)
+
+	set := cid.NewSet()
+	if err := gc.Descendants(ctx, getLinks, set, roots); err != nil {
+		return nil, err
+	}
+	for _, c := range directKeys {
+		if !c.Equals(root) {
+			set.Add(c)
+		}
+	}
+	return set, nil
+}
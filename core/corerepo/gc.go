@@ -70,6 +70,34 @@ func NewGC(n *core.IpfsNode) (*GC, error) {
 	}, nil
 }
 
+// gcRoots returns the roots kept by the garbage collection on top of the
+// pins: the files root and its history, and the retained roots.
+func gcRoots(n *core.IpfsNode) ([]*cid.Cid, error) {
+	roots, err := BestEffortRoots(n.FilesRoot)
+	if err != nil {
+		return nil, err
+	}
+	roots = append(roots, filesRootHistory(n)...)
+
+	retained, err := RetainedRoots(n.Repo, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return append(roots, retained...), nil
+}
+
+// filesRootHistory returns the last roots of the files API, which are kept
+// for the files root to be restored from them if it is lost.
+func filesRootHistory(n *core.IpfsNode) []*cid.Cid {
+	hist, err := core.FilesRootHistory(n.Repo.Datastore())
+	if err != nil {
+		// as when the history is written, a broken one is started again
+		log.Warningf("cannot read the history of the files root: %s", err)
+		return nil
+	}
+	return hist
+}
+
 func BestEffortRoots(filesRoot *mfs.Root) ([]*cid.Cid, error) {
 	rootDag, err := filesRoot.GetValue().GetNode()
 	if err != nil {
@@ -80,13 +108,29 @@ func BestEffortRoots(filesRoot *mfs.Root) ([]*cid.Cid, error) {
 }
 
 func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
+	return garbageCollect(n, ctx, GCManual)
+}
+
+func garbageCollect(n *core.IpfsNode, ctx context.Context, trigger string) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel() // in case error occurs during operation
-	roots, err := BestEffortRoots(n.FilesRoot)
-	if err != nil {
-		return err
-	}
-	rmed := gc.GC(ctx, n.Blockstore, n.DAG, n.Pinning, roots)
+	rmed := garbageCollectAsync(n, ctx, trigger)
+
+	return CollectResult(ctx, rmed, nil)
+}
+
+// evictLRU removes unpinned blocks until target bytes were freed, the least
+// recently used first.
+func evictLRU(n *core.IpfsNode, ctx context.Context, target uint64, trigger string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // in case error occurs during operation
+	rmed := recordGC(n, ctx, trigger, func(roots []*cid.Cid) <-chan gc.Result {
+		pace, err := maintenancePacer(n)
+		if err != nil {
+			return errorResult(err)
+		}
+		return gc.Evict(ctx, n.Blockstore, n.DAG, n.Pinning, roots, n.GCMarks, pace, target, n.AccessTimes.SortLRU)
+	})
 
 	return CollectResult(ctx, rmed, nil)
 }
@@ -146,15 +190,102 @@ func (e *MultiError) Error() string {
 }
 
 func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) <-chan gc.Result {
-	roots, err := BestEffortRoots(n.FilesRoot)
+	return garbageCollectAsync(n, ctx, GCManual)
+}
+
+func garbageCollectAsync(n *core.IpfsNode, ctx context.Context, trigger string) <-chan gc.Result {
+	return recordGC(n, ctx, trigger, func(roots []*cid.Cid) <-chan gc.Result {
+		pace, err := maintenancePacer(n)
+		if err != nil {
+			return errorResult(err)
+		}
+		return gc.GCCached(ctx, n.Blockstore, n.DAG, n.Pinning, roots, n.GCMarks, pace)
+	})
+}
+
+// GarbageCollectOnly removes the candidates that are not kept by the pins or
+// the files root. See gc.GCOnly.
+func GarbageCollectOnly(n *core.IpfsNode, ctx context.Context, candidates []*cid.Cid) <-chan gc.Result {
+	return recordGC(n, ctx, GCManual, func(roots []*cid.Cid) <-chan gc.Result {
+		pace, err := maintenancePacer(n)
+		if err != nil {
+			return errorResult(err)
+		}
+		return gc.GCOnly(ctx, n.Blockstore, n.DAG, n.Pinning, roots, n.GCMarks, pace, candidates)
+	})
+}
+
+// maintenancePacer returns the pacer of the gc sweeps set in the config, nil
+// if they aren't paced.
+func maintenancePacer(n *core.IpfsNode) (gc.Pacer, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	p, err := NewPacer(cfg.Datastore)
+	if p == nil || err != nil {
+		// not a typed nil
+		return nil, err
+	}
+	return p, nil
+}
+
+func errorResult(err error) <-chan gc.Result {
+	out := make(chan gc.Result, 1)
+	out <- gc.Result{Error: err}
+	close(out)
+	return out
+}
+
+// recordGC starts a garbage collection run with the gc roots, and
+// records it in the gc history once all its results were read.
+func recordGC(n *core.IpfsNode, ctx context.Context, trigger string, start func([]*cid.Cid) <-chan gc.Result) <-chan gc.Result {
+	run := GCRun{Trigger: trigger, Start: time.Now()}
+	before, beforeErr := n.Repo.GetStorageUsage()
+	var hits uint64
+	if n.GCMarks != nil {
+		hits = n.GCMarks.Hits()
+	}
+
+	var rmed <-chan gc.Result
+	roots, err := gcRoots(n)
 	if err != nil {
-		out := make(chan gc.Result)
-		out <- gc.Result{Error: err}
-		close(out)
-		return out
+		rmed = errorResult(err)
+	} else {
+		rmed = start(roots)
 	}
 
-	return gc.GC(ctx, n.Blockstore, n.DAG, n.Pinning, roots)
+	out := make(chan gc.Result, cap(rmed))
+	go func() {
+		defer close(out)
+		defer func() {
+			run.End = time.Now()
+			after, err := n.Repo.GetStorageUsage()
+			if err == nil && beforeErr == nil && after < before {
+				run.BytesReclaimed = before - after
+			}
+			if n.GCMarks != nil {
+				run.MarkCached = n.GCMarks.Hits() > hits
+			}
+			recordGCRun(n.Repo, run)
+		}()
+
+		for res := range rmed {
+			if res.Error != nil {
+				run.Errors = append(run.Errors, res.Error.Error())
+			} else if res.KeyRemoved != nil {
+				run.BlocksRemoved++
+			}
+
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				run.Errors = append(run.Errors, ctx.Err().Error())
+				return
+			}
+		}
+	}()
+	return out
 }
 
 func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
@@ -186,8 +317,9 @@ func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
 		case <-ctx.Done():
 			return nil
 		case <-time.After(period):
+			recordGCCheck(node.Repo)
 			// the private func maybeGC doesn't compute storageMax, storageGC, slackGC so that they are not re-computed for every cycle
-			if err := gc.maybeGC(ctx, 0); err != nil {
+			if err := gc.maybeGC(ctx, 0, GCPeriodic); err != nil {
 				log.Error(err)
 			}
 		}
@@ -199,10 +331,10 @@ func ConditionalGC(ctx context.Context, node *core.IpfsNode, offset uint64) erro
 	if err != nil {
 		return err
 	}
-	return gc.maybeGC(ctx, offset)
+	return gc.maybeGC(ctx, offset, GCWatermark)
 }
 
-func (gc *GC) maybeGC(ctx context.Context, offset uint64) error {
+func (gc *GC) maybeGC(ctx context.Context, offset uint64, trigger string) error {
 	storage, err := gc.Repo.GetStorageUsage()
 	if err != nil {
 		return err
@@ -217,7 +349,14 @@ func (gc *GC) maybeGC(ctx context.Context, offset uint64) error {
 		log.Info("Watermark exceeded. Starting repo GC...")
 		defer log.EventBegin(ctx, "repoGC").Done()
 
-		if err := GarbageCollect(gc.Node, ctx); err != nil {
+		if gc.Node.AccessTimes != nil {
+			// only remove the least recently used blocks, until the repo
+			// is back under the watermark.
+			err = evictLRU(gc.Node, ctx, storage+offset-gc.StorageGC, trigger)
+		} else {
+			err = garbageCollect(gc.Node, ctx, trigger)
+		}
+		if err != nil {
 			return err
 		}
 		log.Infof("Repo GC done. See `ipfs repo stat` to see how much space got freed.\n")
@@ -0,0 +1,118 @@
+package corerepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+)
+
+// GCHistorySize is the number of garbage collection runs kept in the
+// history.
+var GCHistorySize = 32
+
+var gcHistoryKey = ds.NewKey("/local/gc/history")
+
+// GC run triggers.
+const (
+	GCManual    = "manual"
+	GCPeriodic  = "periodic"
+	GCWatermark = "watermark"
+)
+
+// GCRun is the record of a garbage collection run.
+type GCRun struct {
+	Trigger        string
+	Start          time.Time
+	End            time.Time
+	BlocksRemoved  uint64
+	BytesReclaimed uint64
+	MarkCached     bool     `json:",omitempty"` // the marked set of a previous run was reused
+	Errors         []string `json:",omitempty"`
+}
+
+// GCHistory is the garbage collection history of a repo.
+type GCHistory struct {
+	// LastCheck is when the periodic garbage collection last checked the
+	// storage usage against the watermark.
+	LastCheck time.Time
+
+	// Runs are the most recent runs, the last one first.
+	Runs []GCRun
+}
+
+// the history is read, modified and written back, runs ending at the same
+// time must not lose each other's records.
+var gcHistoryLk sync.Mutex
+
+// GetGCHistory returns the garbage collection history stored in the repo.
+func GetGCHistory(r repo.Repo) (*GCHistory, error) {
+	gcHistoryLk.Lock()
+	defer gcHistoryLk.Unlock()
+	return getGCHistory(r)
+}
+
+func getGCHistory(r repo.Repo) (*GCHistory, error) {
+	h := &GCHistory{Runs: []GCRun{}}
+
+	val, err := r.Datastore().Get(gcHistoryKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return h, nil
+	default:
+		return nil, err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("gc history is not stored as bytes")
+	}
+	if err := json.Unmarshal(b, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func updateGCHistory(r repo.Repo, update func(h *GCHistory)) error {
+	gcHistoryLk.Lock()
+	defer gcHistoryLk.Unlock()
+
+	h, err := getGCHistory(r)
+	if err != nil {
+		return err
+	}
+
+	update(h)
+
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return r.Datastore().Put(gcHistoryKey, b)
+}
+
+func recordGCRun(r repo.Repo, run GCRun) {
+	err := updateGCHistory(r, func(h *GCHistory) {
+		h.Runs = append([]GCRun{run}, h.Runs...)
+		if len(h.Runs) > GCHistorySize {
+			h.Runs = h.Runs[:GCHistorySize]
+		}
+	})
+	if err != nil {
+		log.Error("recording gc run: ", err)
+	}
+}
+
+func recordGCCheck(r repo.Repo) {
+	err := updateGCHistory(r, func(h *GCHistory) {
+		h.LastCheck = time.Now()
+	})
+	if err != nil {
+		log.Error("recording gc check: ", err)
+	}
+}
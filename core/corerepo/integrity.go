@@ -0,0 +1,227 @@
+package corerepo
+
+import (
+	"context"
+	"fmt"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/core"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	filestore "github.com/ipfs/go-ipfs/filestore"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+	repo "github.com/ipfs/go-ipfs/repo"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// RepoProblem is a critical part of a repo which can't be read, so that no
+// node can be built on it.
+type RepoProblem struct {
+	// the part of the repo, such as "pins"
+	Part string
+	Err  error
+	// what Repair does, or what has to be done by hand if it can't
+	Fix string
+
+	repair func(ctx context.Context) error
+}
+
+func (p *RepoProblem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Part, p.Err)
+}
+
+// Repairable tells whether Repair can fix the problem.
+func (p *RepoProblem) Repairable() bool {
+	return p.repair != nil
+}
+
+// Repair fixes the problem, using the local blocks only.
+func (p *RepoProblem) Repair(ctx context.Context) error {
+	if p.repair == nil {
+		return fmt.Errorf("%s can't be repaired automatically: %s", p.Part, p.Fix)
+	}
+	return p.repair(ctx)
+}
+
+// CheckRepo checks the parts of r without which no node can be built on it:
+// the identity, the pins and the root of the files API. The repo must not be
+// used by a node.
+func CheckRepo(ctx context.Context, r repo.Repo) ([]*RepoProblem, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	// the blocks of the repo, without caches nor network
+	base := bstore.NewBlockstore(r.Datastore())
+	var bs bstore.Blockstore = base
+	if cfg.FeatureEnabled("filestore") && r.FileManager() != nil {
+		bs = filestore.NewFilestore(base, r.FileManager())
+	}
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	var problems []*RepoProblem
+
+	if err := core.CheckIdentity(&cfg.Identity); err != nil {
+		problems = append(problems, &RepoProblem{
+			Part: "identity",
+			Err:  err,
+			Fix:  "restore the Identity section of the config from a backup, or make a new repo with 'ipfs init --import-identity' if it was exported",
+		})
+	}
+
+	if err := pin.CheckPins(r.Datastore(), dserv); err != nil {
+		problems = append(problems, &RepoProblem{
+			Part: "pins",
+			Err:  err,
+			Fix:  "set the broken pins aside and pin again the dags of the pins recorded with 'ipfs pin add'",
+			repair: func(ctx context.Context) error {
+				return rebuildPins(ctx, r, dserv)
+			},
+		})
+	}
+
+	if err := checkFilesRoot(ctx, r.Datastore(), dserv); err != nil {
+		problems = append(problems, &RepoProblem{
+			Part: "files root",
+			Err:  err,
+			Fix:  "restore the last root of the files API which can be loaded, or an empty directory",
+			repair: func(ctx context.Context) error {
+				return restoreFilesRoot(ctx, r.Datastore(), dserv)
+			},
+		})
+	}
+
+	return problems, nil
+}
+
+// rebuildPins sets the broken pins aside, and pins again the dags of the
+// pins whose information was recorded: recursively if the whole dag is
+// there, directly if only its root is. The pins of other dags are lost.
+func rebuildPins(ctx context.Context, r repo.Repo, dserv dag.DAGService) error {
+	if err := pin.SetAsideBrokenPins(r.Datastore()); err != nil {
+		return err
+	}
+
+	pinner, err := pin.LoadPinner(r.Datastore(), dserv, dserv)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.Datastore().Query(dsq.Query{Prefix: pinInfoPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	for {
+		e, ok := res.NextSync()
+		if !ok {
+			break
+		}
+		if e.Error != nil {
+			return e.Error
+		}
+		c, err := cid.Decode(ds.RawKey(e.Key).BaseNamespace())
+		if err != nil {
+			log.Warningf("bad pin info key %s: %s", e.Key, err)
+			continue
+		}
+
+		_, pinned, err := pinner.IsPinned(c)
+		if err != nil {
+			return err
+		}
+		if pinned {
+			continue
+		}
+
+		switch err := dag.FetchGraph(ctx, c, dserv); {
+		case err == nil:
+			pinner.PinWithMode(c, pin.Recursive)
+		case ctx.Err() != nil:
+			return ctx.Err()
+		default:
+			if _, err := dserv.Get(ctx, c); err != nil {
+				log.Warningf("cannot pin %s again, its root is missing: %s", c, err)
+				continue
+			}
+			log.Warningf("pinning %s again directly, its dag is incomplete", c)
+			pinner.PinWithMode(c, pin.Direct)
+		}
+	}
+
+	return pinner.Flush()
+}
+
+// checkFilesRoot checks that the root of the files API can be loaded.
+func checkFilesRoot(ctx context.Context, d ds.Datastore, dserv dag.DAGService) error {
+	val, err := d.Get(core.FilesRootKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return fmt.Errorf("%s is not stored as bytes", core.FilesRootKey)
+	}
+	c, err := cid.Cast(b)
+	if err != nil {
+		return fmt.Errorf("invalid root cid: %s", err)
+	}
+	return checkFilesRootNode(ctx, dserv, c)
+}
+
+func checkFilesRootNode(ctx context.Context, dserv dag.DAGService, c *cid.Cid) error {
+	nd, err := dserv.Get(ctx, c)
+	if err != nil {
+		return fmt.Errorf("cannot load root %s: %s", c, err)
+	}
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return dag.ErrNotProtobuf
+	}
+	if _, err := ft.FromBytes(pbnd.Data()); err != nil {
+		return fmt.Errorf("root %s is not unixfs: %s", c, err)
+	}
+	return nil
+}
+
+// restoreFilesRoot makes the newest root of the history whose whole dag is
+// stored the root of the files API again, or an empty directory if there is
+// none.
+func restoreFilesRoot(ctx context.Context, d ds.Datastore, dserv dag.DAGService) error {
+	hist, err := core.FilesRootHistory(d)
+	if err != nil {
+		log.Warningf("cannot read the history of the files root: %s", err)
+	}
+	for _, c := range hist {
+		err := checkFilesRootNode(ctx, dserv, c)
+		if err == nil {
+			// the dag service is offline, the dag is only walked
+			err = dag.FetchGraph(ctx, c, dserv)
+		}
+		if err != nil {
+			log.Warningf("cannot restore files root %s: %s", c, err)
+			continue
+		}
+		log.Infof("restoring files root %s", c)
+		return core.SetFilesRoot(d, c)
+	}
+
+	log.Warning("no files root to restore, starting with an empty directory")
+	c, err := dserv.Add(ft.EmptyDirNode())
+	if err != nil {
+		return err
+	}
+	return core.SetFilesRoot(d, c)
+}
@@ -0,0 +1,277 @@
+package corerepo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/core"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	path "github.com/ipfs/go-ipfs/path"
+	pin "github.com/ipfs/go-ipfs/pin"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// OrphanBlock is a block of the dag of a pin, in the preview of its removal.
+type OrphanBlock struct {
+	Cid  string
+	Size uint64
+	// for the blocks which stay: the pin or root keeping them, and how:
+	// "direct", "recursive", "internal", "files root", "files root history"
+	// or "retained"
+	KeptBy string `json:",omitempty"`
+	How    string `json:",omitempty"`
+}
+
+// UnpinPreview tells which local blocks of the dags of some pins would
+// become orphans once the pins are removed, left to the next garbage
+// collection, and which ones stay protected by other pins or roots.
+type UnpinPreview struct {
+	Orphans     []OrphanBlock
+	Kept        []OrphanBlock
+	OrphanBytes uint64
+	KeptBytes   uint64
+	// the blocks of the pins which aren't stored, and the error the garbage
+	// collection fails with because of them, see PinCoverage
+	Missing []MissingBlock `json:",omitempty"`
+	GCError string         `json:",omitempty"`
+}
+
+// PreviewUnpin tells what removing the pins of paths would leave to the
+// garbage collection, without removing them. With unpinChildren false, the
+// children of recursive pins would stay pinned, as with UnpinRoots, and only
+// the root blocks are looked at.
+func PreviewUnpin(n *core.IpfsNode, ctx context.Context, paths []string, unpinChildren bool) (*UnpinPreview, error) {
+	local := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+
+	unpinned := cid.NewSet()
+	candidates := cid.NewSet()
+	// the blocks in the order of the walks, with their sizes
+	var order []*cid.Cid
+	sizes := make(map[string]uint64)
+
+	for _, p := range paths {
+		pth, err := path.ParsePath(p)
+		if err != nil {
+			return nil, err
+		}
+		c, err := core.ResolveToCid(ctx, n, pth)
+		if err != nil {
+			return nil, err
+		}
+
+		mode, pinned, err := n.Pinning.IsPinnedWithType(c, pin.Any)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !pinned:
+			return nil, &wrappedError{fmt.Sprintf("%s is not pinned", c), pin.ErrNotPinned}
+		case mode != "recursive" && mode != "direct":
+			return nil, fmt.Errorf("%s is pinned indirectly under %s", c, mode)
+		}
+		unpinned.Add(c)
+
+		walkDag := mode == "recursive" && unpinChildren
+		var visit func(c *cid.Cid) error
+		visit = func(c *cid.Cid) error {
+			if !candidates.Visit(c) {
+				return nil
+			}
+			nd, err := local.Get(ctx, c)
+			switch err {
+			case nil:
+			case dag.ErrNotFound:
+				// not stored, nothing to remove
+				candidates.Remove(c)
+				return nil
+			default:
+				return err
+			}
+			order = append(order, c)
+			sizes[c.KeyString()] = uint64(len(nd.RawData()))
+
+			if !walkDag {
+				return nil
+			}
+			for _, l := range nd.Links() {
+				if err := visit(l.Cid); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := visit(c); err != nil {
+			return nil, err
+		}
+	}
+
+	roots, err := gcRoots(n)
+	if err != nil {
+		return nil, err
+	}
+	names, err := newGCRootNames(n)
+	if err != nil {
+		return nil, err
+	}
+
+	kept, err := gc.Orphans(ctx, n.Pinning, local, roots, unpinned, candidates)
+	if err != nil {
+		return nil, err
+	}
+	keptBy := make(map[string]gc.Kept, len(kept))
+	for _, k := range kept {
+		keptBy[k.Cid.KeyString()] = k
+	}
+
+	out := new(UnpinPreview)
+	for _, c := range order {
+		size := sizes[c.KeyString()]
+		if candidates.Has(c) {
+			out.Orphans = append(out.Orphans, OrphanBlock{Cid: c.String(), Size: size})
+			out.OrphanBytes += size
+			continue
+		}
+
+		k := keptBy[c.KeyString()]
+		out.Kept = append(out.Kept, OrphanBlock{Cid: c.String(), Size: size, KeptBy: k.Root.String(), How: names.how(k)})
+		out.KeptBytes += size
+	}
+	return out, nil
+}
+
+// MissingBlock is a block of the dag of a pin which isn't stored.
+type MissingBlock struct {
+	Cid string
+	// the pin it's missing from, and how it's pinned: "recursive" or
+	// "internal"
+	Pin string
+	How string
+}
+
+// PinCoverage compares the blocks of the blockstore to the ones kept by the
+// pins and the gc roots, the files root and its history and the retained
+// roots. The report is the preview of unpinning everything which isn't
+// pinned: the Orphans are the stored blocks the next garbage collection
+// would remove, the Kept ones, listed with withKept, stay. Missing lists the
+// blocks of the pins which aren't stored, and GCError tells that the garbage
+// collection fails until they are. The dags are walked by the walk of the
+// garbage collection, see gc.Coverage.
+func PinCoverage(n *core.IpfsNode, ctx context.Context, withKept bool) (*UnpinPreview, error) {
+	candidates := cid.NewSet()
+	sizes := make(map[string]uint64)
+	keys, err := n.Blockstore.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for c := range keys {
+		blk, err := n.Blockstore.Get(c)
+		switch err {
+		case nil:
+		case bstore.ErrNotFound:
+			// removed since
+			continue
+		default:
+			return nil, err
+		}
+		candidates.Add(c)
+		sizes[c.KeyString()] = uint64(len(blk.RawData()))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stored := candidates.Keys()
+
+	roots, err := gcRoots(n)
+	if err != nil {
+		return nil, err
+	}
+	names, err := newGCRootNames(n)
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(UnpinPreview)
+	kept, missing, err := gc.Coverage(ctx, n.Pinning, n.DAG, roots, candidates)
+	switch err {
+	case nil:
+	case gc.ErrCannotFetchAllLinks:
+		out.GCError = err.Error()
+	default:
+		return nil, err
+	}
+
+	for _, c := range stored {
+		if candidates.Has(c) {
+			size := sizes[c.KeyString()]
+			out.Orphans = append(out.Orphans, OrphanBlock{Cid: c.String(), Size: size})
+			out.OrphanBytes += size
+		}
+	}
+	for _, k := range kept {
+		size := sizes[k.Cid.KeyString()]
+		out.KeptBytes += size
+		if withKept {
+			out.Kept = append(out.Kept, OrphanBlock{Cid: k.Cid.String(), Size: size, KeptBy: k.Root.String(), How: names.how(k)})
+		}
+	}
+	for _, m := range missing {
+		out.Missing = append(out.Missing, MissingBlock{Cid: m.Cid.String(), Pin: m.Root.String(), How: m.How})
+	}
+
+	sort.Sort(orphanBlocks(out.Orphans))
+	sort.Sort(orphanBlocks(out.Kept))
+	sort.Sort(missingBlocks(out.Missing))
+	return out, nil
+}
+
+// gcRootNames tells the gc roots apart, which the walks of pin/gc all call
+// best effort roots.
+type gcRootNames struct {
+	filesRoot *cid.Cid
+	history   *cid.Set
+}
+
+func newGCRootNames(n *core.IpfsNode) (*gcRootNames, error) {
+	filesRoot, err := BestEffortRoots(n.FilesRoot)
+	if err != nil {
+		return nil, err
+	}
+	history := cid.NewSet()
+	for _, c := range filesRootHistory(n) {
+		history.Add(c)
+	}
+	return &gcRootNames{filesRoot: filesRoot[0], history: history}, nil
+}
+
+// how tells how k is kept: by a pin, "direct", "recursive" or "internal",
+// or by a gc root, "files root", "files root history" or "retained".
+func (r *gcRootNames) how(k gc.Kept) string {
+	switch {
+	case k.How != "best effort":
+		return k.How
+	case k.Root.Equals(r.filesRoot):
+		return "files root"
+	case r.history.Has(k.Root):
+		return "files root history"
+	default:
+		return "retained"
+	}
+}
+
+type orphanBlocks []OrphanBlock
+
+func (b orphanBlocks) Len() int           { return len(b) }
+func (b orphanBlocks) Less(i, j int) bool { return b[i].Cid < b[j].Cid }
+func (b orphanBlocks) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+type missingBlocks []MissingBlock
+
+func (b missingBlocks) Len() int           { return len(b) }
+func (b missingBlocks) Less(i, j int) bool { return b[i].Cid < b[j].Cid }
+func (b missingBlocks) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,99 @@
+package corerepo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+)
+
+// Pacer spaces out the disk operations of maintenance tasks, like gc sweeps
+// and repo verification, so that they leave the disk to the rest of the
+// node. A nil Pacer doesn't slow anything down.
+type Pacer struct {
+	rate float64 // bytes per second, 0 for no limit
+	ops  float64 // operations per second, 0 for no limit
+
+	lk   sync.Mutex
+	next time.Time // when the next operation may start
+}
+
+// NewPacer returns a Pacer for the MaintenanceMaxRate and MaintenanceMaxOps
+// of the config, nil if neither is set.
+func NewPacer(cfg config.Datastore) (*Pacer, error) {
+	return ParsePacer(cfg.MaintenanceMaxRate, cfg.MaintenanceMaxOps)
+}
+
+// ParsePacer returns a Pacer allowing rate bytes, like "10MB", and ops
+// operations per second. It returns nil if neither is set.
+func ParsePacer(rate string, ops int) (*Pacer, error) {
+	if ops < 0 {
+		return nil, fmt.Errorf("invalid maintenance operations rate %d", ops)
+	}
+
+	var r uint64
+	if rate != "" {
+		var err error
+		r, err = humanize.ParseBytes(rate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance rate %q: %s", rate, err)
+		}
+	}
+
+	if r == 0 && ops == 0 {
+		return nil, nil
+	}
+	return &Pacer{rate: float64(r), ops: float64(ops)}, nil
+}
+
+// Wait accounts for an operation on size bytes, and blocks until the pace
+// allows it.
+func (p *Pacer) Wait(ctx context.Context, size int) error {
+	d := p.Delay(size)
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Delay accounts for an operation on size bytes, and returns how long to
+// wait before it. It implements gc.Pacer: the gc sweeps wait without the gc
+// lock.
+func (p *Pacer) Delay(size int) time.Duration {
+	if p == nil {
+		return 0
+	}
+
+	var cost time.Duration
+	if p.ops > 0 {
+		cost = time.Duration(float64(time.Second) / p.ops)
+	}
+	if p.rate > 0 {
+		if c := time.Duration(float64(size) / p.rate * float64(time.Second)); c > cost {
+			cost = c
+		}
+	}
+
+	p.lk.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	start := p.next
+	p.next = start.Add(cost)
+	p.lk.Unlock()
+
+	return start.Sub(now)
+}
@@ -0,0 +1,133 @@
+package corerepo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ipfs/go-ipfs/core"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// PinExportVersion is the version of the format of PinExport.
+const PinExportVersion = 1
+
+// PinExport is the pin set of a node, with the information recorded for each
+// pin, to be imported in another one.
+type PinExport struct {
+	Version int
+	Pins    []ExportedPin
+}
+
+// ExportedPin is a recursive or direct pin.
+type ExportedPin struct {
+	Cid  string
+	Type string
+	Info *PinInfo `json:",omitempty"`
+}
+
+// ExportPins returns the recursive and direct pins of n, sorted by CID.
+func ExportPins(n *core.IpfsNode) (*PinExport, error) {
+	exp := &PinExport{Version: PinExportVersion}
+	add := func(cs []*cid.Cid, typ string) error {
+		var pins []ExportedPin
+		for _, c := range cs {
+			info, err := GetPinInfo(n.Repo, c)
+			if err != nil {
+				return err
+			}
+			pins = append(pins, ExportedPin{Cid: c.String(), Type: typ, Info: info})
+		}
+		sort.Sort(exportedPins(pins))
+		exp.Pins = append(exp.Pins, pins...)
+		return nil
+	}
+
+	recursiveKeys, err := n.Pinning.RecursiveKeys()
+	if err != nil {
+		return nil, err
+	}
+	if err := add(recursiveKeys, "recursive"); err != nil {
+		return nil, err
+	}
+	directKeys, err := n.Pinning.DirectKeys()
+	if err != nil {
+		return nil, err
+	}
+	if err := add(directKeys, "direct"); err != nil {
+		return nil, err
+	}
+	return exp, nil
+}
+
+type exportedPins []ExportedPin
+
+func (p exportedPins) Len() int           { return len(p) }
+func (p exportedPins) Less(i, j int) bool { return p[i].Cid < p[j].Cid }
+func (p exportedPins) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// ImportPins pins the exported pins on n, fetching their dags, and restores
+// their information. done is called after each pin. The pins are checked
+// before any is made, and the ones made before an error are kept.
+func ImportPins(n *core.IpfsNode, ctx context.Context, exp *PinExport, done func(ExportedPin)) error {
+	if exp.Version != PinExportVersion {
+		return fmt.Errorf("unsupported pin export version %d", exp.Version)
+	}
+
+	cids := make([]*cid.Cid, len(exp.Pins))
+	for i, p := range exp.Pins {
+		c, err := cid.Decode(p.Cid)
+		if err != nil {
+			return fmt.Errorf("invalid pin %q: %s", p.Cid, err)
+		}
+		if p.Type != "recursive" && p.Type != "direct" {
+			return fmt.Errorf("pin %s has an invalid type %q", p.Cid, p.Type)
+		}
+		cids[i] = c
+	}
+
+	// fetching the dags to pin shouldn't slow down interactive requests
+	ctx = exchange.WithClass(ctx, exchange.Background)
+
+	err := importPins(n, ctx, exp.Pins, cids, done)
+
+	// keep the pins made so far
+	if ferr := n.Pinning.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func importPins(n *core.IpfsNode, ctx context.Context, pins []ExportedPin, cids []*cid.Cid, done func(ExportedPin)) error {
+	for i, p := range pins {
+		if p.Type == "direct" {
+			// the node already keeps it, and more
+			_, recursive, err := n.Pinning.IsPinnedWithType(cids[i], pin.Recursive)
+			if err != nil {
+				return err
+			}
+			if recursive {
+				done(p)
+				continue
+			}
+		}
+
+		nd, err := n.DAG.Get(ctx, cids[i])
+		if err != nil {
+			return fmt.Errorf("pin %s: %s", p.Cid, err)
+		}
+		if err := n.Pinning.Pin(ctx, nd, p.Type == "recursive"); err != nil {
+			return fmt.Errorf("pin %s: %s", p.Cid, err)
+		}
+		if p.Info != nil {
+			if err := SetPinInfo(n.Repo, cids[i], *p.Info); err != nil {
+				return err
+			}
+		}
+		done(p)
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+var pinGroupPrefix = ds.NewKey("/local/pingroups")
+
+// pinGroupsLk serializes the changes of the pin groups, which look at all of
+// them.
+var pinGroupsLk sync.Mutex
+
+// PinGroup is a named set of roots, pinned recursively, whose contents are
+// replaced at once, e.g. the versions of a website.
+type PinGroup struct {
+	Name    string
+	Roots   []string
+	Updated time.Time
+
+	// Owned are the roots the group pinned, which it unpins when they drop
+	// out of it. The roots pinned before they joined the group stay pinned.
+	Owned []string `json:",omitempty"`
+}
+
+// PinGroupChange is what setting the roots of a group changed.
+type PinGroupChange struct {
+	Name string
+	// the roots which joined and left the group
+	Added   []string `json:",omitempty"`
+	Removed []string `json:",omitempty"`
+	// Pinned are the roots the group pinned, and Unpinned the ones it
+	// unpinned
+	Pinned   []string `json:",omitempty"`
+	Unpinned []string `json:",omitempty"`
+}
+
+// ValidatePinGroupName tells whether name can name a pin group.
+func ValidatePinGroupName(name string) error {
+	if name == "" || strings.ContainsAny(name, "/\r\n") {
+		return fmt.Errorf("invalid pin group name %q", name)
+	}
+	return nil
+}
+
+// GetPinGroup returns the group name, nil if there is none.
+func GetPinGroup(r repo.Repo, name string) (*PinGroup, error) {
+	val, err := r.Datastore().Get(pinGroupPrefix.ChildString(name))
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("pin group %s is not stored as bytes", name)
+	}
+	g := new(PinGroup)
+	if err := json.Unmarshal(b, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// ListPinGroups returns the pin groups, by name.
+func ListPinGroups(r repo.Repo) ([]*PinGroup, error) {
+	res, err := r.Datastore().Query(dsq.Query{Prefix: pinGroupPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*PinGroup
+	for _, e := range entries {
+		b, ok := e.Value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("pin group %s is not stored as bytes", e.Key)
+		}
+		g := new(PinGroup)
+		if err := json.Unmarshal(b, g); err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	sort.Sort(pinGroupsByName(out))
+	return out, nil
+}
+
+type pinGroupsByName []*PinGroup
+
+func (s pinGroupsByName) Len() int           { return len(s) }
+func (s pinGroupsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s pinGroupsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+func putPinGroup(b ds.Batch, g *PinGroup) error {
+	val, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	return b.Put(pinGroupPrefix.ChildString(g.Name), val)
+}
+
+// DisownPinGroupRoots takes the roots cs from the groups which pinned them:
+// they were pinned by hand since, and stay pinned once they leave the
+// groups.
+func DisownPinGroupRoots(r repo.Repo, cs []*cid.Cid) error {
+	pinGroupsLk.Lock()
+	defer pinGroupsLk.Unlock()
+
+	groups, err := ListPinGroups(r)
+	if err != nil {
+		return err
+	}
+	disowned := make(map[string]bool, len(cs))
+	for _, c := range cs {
+		disowned[c.String()] = true
+	}
+
+	b, err := r.Datastore().Batch()
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		var owned []string
+		for _, k := range g.Owned {
+			if !disowned[k] {
+				owned = append(owned, k)
+			}
+		}
+		if len(owned) == len(g.Owned) {
+			continue
+		}
+		g.Owned = owned
+		if err := putPinGroup(b, g); err != nil {
+			return err
+		}
+	}
+	return b.Commit()
+}
+
+// SetPinGroup replaces the roots of the group name by paths, creating the
+// group if needed, and removes it without paths. The new roots are fetched
+// before anything changes, then pinned recursively, and the roots the group
+// pinned which left it are unpinned, unless another group has them or they
+// were pinned by hand since: the pins are stored once, with all the changes
+// or none. The roots pinned are recorded with info, and the records of the
+// groups and the pins are written in one batch.
+func SetPinGroup(n *core.IpfsNode, ctx context.Context, name string, paths []string, info PinInfo) (*PinGroupChange, error) {
+	if err := ValidatePinGroupName(name); err != nil {
+		return nil, err
+	}
+
+	dagnodes, err := resolvePins(n, ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = fetchContext(n, ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, nd := range dagnodes {
+		if err := dag.FetchGraph(ctx, nd.Cid(), n.DAG); err != nil {
+			return nil, &wrappedError{fmt.Sprintf("pin: %s", err), err}
+		}
+	}
+
+	pinGroupsLk.Lock()
+	defer pinGroupsLk.Unlock()
+
+	groups, err := ListPinGroups(n.Repo)
+	if err != nil {
+		return nil, err
+	}
+	g := &PinGroup{Name: name}
+	var others []*PinGroup
+	for _, og := range groups {
+		if og.Name == name {
+			g = og
+		} else {
+			others = append(others, og)
+		}
+	}
+
+	oldRoots := stringSet(g.Roots)
+	owned := stringSet(g.Owned)
+	newRoots := make(map[string]bool)
+	change := &PinGroupChange{Name: name}
+	next := &PinGroup{Name: name, Updated: time.Now()}
+
+	// the pins added, to remove them again on failure
+	var pinned []*cid.Cid
+	rollback := func() {
+		for _, c := range pinned {
+			n.Pinning.RemovePinWithMode(c, pin.Recursive)
+		}
+	}
+
+	for _, nd := range dagnodes {
+		c := nd.Cid()
+		k := c.String()
+		if newRoots[k] {
+			continue
+		}
+		newRoots[k] = true
+		next.Roots = append(next.Roots, k)
+		if !oldRoots[k] {
+			change.Added = append(change.Added, k)
+		}
+
+		_, isPinned, err := n.Pinning.IsPinnedWithType(c, pin.Recursive)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		switch {
+		case !isPinned:
+			// the dags are local already
+			if err := n.Pinning.Pin(ctx, nd, true); err != nil {
+				rollback()
+				return nil, &wrappedError{fmt.Sprintf("pin: %s", err), err}
+			}
+			pinned = append(pinned, c)
+			change.Pinned = append(change.Pinned, k)
+			next.Owned = append(next.Owned, k)
+		case owned[k]:
+			next.Owned = append(next.Owned, k)
+		}
+	}
+
+	// the roots which left the group are unpinned if it pinned them and no
+	// other group has them, else they go to the other group
+	var unpinned []*cid.Cid
+	var inherited []*PinGroup
+	for _, k := range g.Roots {
+		if newRoots[k] {
+			continue
+		}
+		change.Removed = append(change.Removed, k)
+		if !owned[k] {
+			continue
+		}
+
+		if heir := pinGroupWithRoot(others, k); heir != nil {
+			heir.Owned = append(heir.Owned, k)
+			inherited = append(inherited, heir)
+			continue
+		}
+		c, err := cid.Decode(k)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		unpinned = append(unpinned, c)
+	}
+	for _, c := range unpinned {
+		// roots unpinned by hand are gone already
+		if err := n.Pinning.Unpin(ctx, c, true); err != nil && err != pin.ErrNotPinned {
+			rollback()
+			for _, c := range unpinned {
+				n.Pinning.PinWithMode(c, pin.Recursive)
+			}
+			return nil, err
+		}
+		change.Unpinned = append(change.Unpinned, c.String())
+	}
+
+	if err := n.Pinning.Flush(); err != nil {
+		return nil, err
+	}
+
+	b, err := n.Repo.Datastore().Batch()
+	if err != nil {
+		return nil, err
+	}
+	infoVal, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range pinned {
+		if err := b.Put(pinInfoKey(c), infoVal); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range unpinned {
+		if err := b.Delete(pinInfoKey(c)); err != nil && err != ds.ErrNotFound {
+			return nil, err
+		}
+	}
+	for _, heir := range inherited {
+		if err := putPinGroup(b, heir); err != nil {
+			return nil, err
+		}
+	}
+	if len(next.Roots) == 0 {
+		err = b.Delete(pinGroupPrefix.ChildString(name))
+	} else {
+		err = putPinGroup(b, next)
+	}
+	if err != nil && err != ds.ErrNotFound {
+		return nil, err
+	}
+	return change, b.Commit()
+}
+
+func pinGroupWithRoot(groups []*PinGroup, root string) *PinGroup {
+	for _, g := range groups {
+		for _, r := range g.Roots {
+			if r == root {
+				return g
+			}
+		}
+	}
+	return nil
+}
+
+func stringSet(ss []string) map[string]bool {
+	set := make(map[string]bool, len(ss))
+	for _, s := range ss {
+		set[s] = true
+	}
+	return set
+}
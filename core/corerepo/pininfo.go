@@ -0,0 +1,73 @@
+package corerepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+var pinInfoPrefix = ds.NewKey("/local/pininfo")
+
+// PinInfo tells who created a pin and when, as an audit trail of the pins of
+// nodes shared by several users or services, and the name and metadata it was
+// given, if any.
+type PinInfo struct {
+	Requester string
+	Created   time.Time
+	Name      string            `json:",omitempty"`
+	Meta      map[string]string `json:",omitempty"`
+	// Source is the path the pin was created with, e.g. an /ipns name or
+	// a path under another object, before it was resolved to the CID.
+	Source string `json:",omitempty"`
+}
+
+// SetPinInfo records the information of the pin of c, replacing the one
+// recorded before.
+func SetPinInfo(r repo.Repo, c *cid.Cid, info PinInfo) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return r.Datastore().Put(pinInfoKey(c), b)
+}
+
+// GetPinInfo returns the information recorded for the pin of c, nil for pins
+// created before it was recorded.
+func GetPinInfo(r repo.Repo, c *cid.Cid) (*PinInfo, error) {
+	val, err := r.Datastore().Get(pinInfoKey(c))
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("pin info of %s is not stored as bytes", c)
+	}
+	info := new(PinInfo)
+	if err := json.Unmarshal(b, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// RemovePinInfo forgets the information of the pin of c.
+func RemovePinInfo(r repo.Repo, c *cid.Cid) error {
+	err := r.Datastore().Delete(pinInfoKey(c))
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func pinInfoKey(c *cid.Cid) ds.Key {
+	return pinInfoPrefix.ChildString(c.String())
+}
@@ -16,48 +16,227 @@ package corerepo
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	bserv "github.com/ipfs/go-ipfs/blockservice"
 	"github.com/ipfs/go-ipfs/core"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
+	pin "github.com/ipfs/go-ipfs/pin"
 
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
 )
 
 func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
-	dagnodes := make([]node.Node, 0)
+	dagnodes, err := resolvePins(n, ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = fetchContext(n, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*cid.Cid
+	for _, dagnode := range dagnodes {
+		c := dagnode.Cid()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		err := n.Pinning.Pin(ctx, dagnode, recursive)
+		if err != nil {
+			return nil, &wrappedError{fmt.Sprintf("pin: %s", err), err}
+		}
+		out = append(out, c)
+	}
+
+	err = n.Pinning.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// PinAtomic pins all of paths, or none of them. The dags are all fetched
+// before any pin is added, and the pins added are removed again if one of
+// them fails, before the pins are stored. The blocks fetched for the pins
+// which were not added are left to the garbage collector.
+func PinAtomic(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
+	dagnodes, err := resolvePins(n, ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = fetchContext(n, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dagnode := range dagnodes {
+		c := dagnode.Cid()
+		if recursive {
+			err = dag.FetchGraph(ctx, c, n.DAG)
+		} else {
+			_, err = n.DAG.Get(ctx, c)
+		}
+		if err != nil {
+			return nil, &wrappedError{fmt.Sprintf("pin: %s", err), err}
+		}
+	}
+
+	// the modes of the pins before, to put them back
+	type before struct {
+		c                 *cid.Cid
+		direct, recursive bool
+	}
+	var pinned []before
+	rollback := func() {
+		for i := len(pinned) - 1; i >= 0; i-- {
+			b := pinned[i]
+			if recursive && !b.recursive {
+				n.Pinning.RemovePinWithMode(b.c, pin.Recursive)
+			}
+			if b.direct {
+				n.Pinning.PinWithMode(b.c, pin.Direct)
+			} else if !recursive {
+				n.Pinning.RemovePinWithMode(b.c, pin.Direct)
+			}
+		}
+	}
+
+	var out []*cid.Cid
+	for _, dagnode := range dagnodes {
+		c := dagnode.Cid()
+		b := before{c: c}
+		_, b.direct, err = n.Pinning.IsPinnedWithType(c, pin.Direct)
+		if err == nil {
+			_, b.recursive, err = n.Pinning.IsPinnedWithType(c, pin.Recursive)
+		}
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+
+		// the dags are local already
+		if err := n.Pinning.Pin(ctx, dagnode, recursive); err != nil {
+			rollback()
+			return nil, &wrappedError{fmt.Sprintf("pin: %s", err), err}
+		}
+		pinned = append(pinned, b)
+		out = append(out, c)
+	}
+
+	if err := n.Pinning.Flush(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PinLocal pins paths only if all their blocks are in the blockstore
+// already, and fails at the first missing block otherwise. Nothing is
+// fetched: the paths are resolved and the dags walked in the blockstore,
+// all of them before any pin is added. /ipns paths are not accepted, their
+// resolution may need the network.
+func PinLocal(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
+	local := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+	r := &path.Resolver{DAG: local, ResolveOnce: n.Resolver.ResolveOnce}
+
+	var dagnodes []node.Node
 	for _, fpath := range paths {
 		p, err := path.ParsePath(fpath)
 		if err != nil {
 			return nil, err
 		}
+		if strings.HasPrefix(p.String(), "/ipns/") {
+			return nil, fmt.Errorf("pin: %s: /ipns paths can't be resolved without the network", p)
+		}
 
-		dagnode, err := core.Resolve(ctx, n.Namesys, n.Resolver, p)
+		dagnode, err := r.ResolvePath(ctx, p)
 		if err != nil {
-			return nil, fmt.Errorf("pin: %s", err)
+			return nil, &wrappedError{fmt.Sprintf("pin: %s is not local: %s", p, err), err}
+		}
+		if recursive {
+			if err := checkLocal(ctx, local, dagnode); err != nil {
+				return nil, err
+			}
 		}
 		dagnodes = append(dagnodes, dagnode)
 	}
 
 	var out []*cid.Cid
 	for _, dagnode := range dagnodes {
-		c := dagnode.Cid()
+		// the dags are local already
+		if err := n.Pinning.Pin(ctx, dagnode, recursive); err != nil {
+			return nil, &wrappedError{fmt.Sprintf("pin: %s", err), err}
+		}
+		out = append(out, dagnode.Cid())
+	}
 
-		ctx, cancel := context.WithCancel(ctx)
-		defer cancel()
-		err := n.Pinning.Pin(ctx, dagnode, recursive)
+	if err := n.Pinning.Flush(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkLocal walks the dag under root in the local dag service, and fails
+// with the first block missing.
+func checkLocal(ctx context.Context, local dag.DAGService, root node.Node) error {
+	seen := cid.NewSet()
+	var visit func(nd node.Node) error
+	visit = func(nd node.Node) error {
+		for _, l := range nd.Links() {
+			if !seen.Visit(l.Cid) {
+				continue
+			}
+			child, err := local.Get(ctx, l.Cid)
+			if err != nil {
+				return &wrappedError{fmt.Sprintf("pin: block %s of %s is not local", l.Cid, root.Cid()), err}
+			}
+			if err := visit(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(root)
+}
+
+// resolvePins resolves the paths to pin, before anything is pinned.
+func resolvePins(n *core.IpfsNode, ctx context.Context, paths []string) ([]node.Node, error) {
+	dagnodes := make([]node.Node, 0)
+	for _, fpath := range paths {
+		p, err := path.ParsePath(fpath)
 		if err != nil {
-			return nil, fmt.Errorf("pin: %s", err)
+			return nil, err
 		}
-		out = append(out, c)
+
+		dagnode, err := core.Resolve(ctx, n.Namesys, n.Resolver, p)
+		if err != nil {
+			return nil, &wrappedError{fmt.Sprintf("pin: %s", err), err}
+		}
+		dagnodes = append(dagnodes, dagnode)
 	}
+	return dagnodes, nil
+}
 
-	err := n.Pinning.Flush()
+// fetchContext returns the context to fetch the dags of pins with.
+func fetchContext(n *core.IpfsNode, ctx context.Context) (context.Context, error) {
+	// fetching the dags to pin shouldn't slow down interactive requests
+	ctx = exchange.WithClass(ctx, exchange.Background)
+	// remember the peers which had the dags, to fetch them again quickly
+	ctx = n.PinProviders.Track(ctx)
+
+	cfg, err := n.Repo.Config()
 	if err != nil {
 		return nil, err
 	}
-
-	return out, nil
+	if cfg.Exchange.PinFetchWorkers > 0 {
+		ctx = dag.WithFetchWorkers(ctx, cfg.Exchange.PinFetchWorkers)
+	}
+	return ctx, nil
 }
 
 func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
@@ -80,6 +259,9 @@ func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool
 		if err != nil {
 			return nil, err
 		}
+		if err := RemovePinInfo(n.Repo, k); err != nil {
+			return nil, err
+		}
 		unpinned = append(unpinned, k)
 	}
 
@@ -89,3 +271,95 @@ func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool
 	}
 	return unpinned, nil
 }
+
+// UnpinRoots removes the recursive pins of paths, and pins the dags of their
+// children recursively in their place: only the root blocks are left to the
+// garbage collector. The new pins get the information of the pins they come
+// from, unless they had their own.
+func UnpinRoots(n *core.IpfsNode, ctx context.Context, paths []string) ([]*cid.Cid, error) {
+	// the children are pinned before the root is unpinned, the dag is never
+	// left unpinned
+	var unpinned []*cid.Cid
+	for _, p := range paths {
+		p, err := path.ParsePath(p)
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := core.ResolveToCid(ctx, n, p)
+		if err != nil {
+			return nil, err
+		}
+
+		_, recursive, err := n.Pinning.IsPinnedWithType(k, pin.Recursive)
+		if err != nil {
+			return nil, err
+		}
+		if !recursive {
+			return nil, &wrappedError{fmt.Sprintf("%s is not pinned recursively, it has no children to keep", k), pin.ErrNotPinned}
+		}
+
+		// the dag is complete, as it was pinned recursively
+		nd, err := n.DAG.Get(ctx, k)
+		if err != nil {
+			return nil, err
+		}
+		info, err := GetPinInfo(n.Repo, k)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range nd.Links() {
+			_, has, err := n.Pinning.IsPinnedWithType(l.Cid, pin.Recursive)
+			if err != nil {
+				return nil, err
+			}
+			if has {
+				continue
+			}
+			n.Pinning.PinWithMode(l.Cid, pin.Recursive)
+
+			if info == nil {
+				continue
+			}
+			own, err := GetPinInfo(n.Repo, l.Cid)
+			if err != nil {
+				return nil, err
+			}
+			if own == nil {
+				if err := SetPinInfo(n.Repo, l.Cid, *info); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if err := n.Pinning.Unpin(ctx, k, true); err != nil {
+			return nil, err
+		}
+		if err := RemovePinInfo(n.Repo, k); err != nil {
+			return nil, err
+		}
+		unpinned = append(unpinned, k)
+	}
+
+	err := n.Pinning.Flush()
+	if err != nil {
+		return nil, err
+	}
+	return unpinned, nil
+}
+
+// wrappedError is an error with its own message, caused by another one. The
+// commands name it after its cause.
+type wrappedError struct {
+	msg   string
+	cause error
+}
+
+func (e *wrappedError) Error() string {
+	return e.msg
+}
+
+func (e *wrappedError) Cause() error {
+	return e.cause
+}
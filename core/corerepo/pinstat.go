@@ -0,0 +1,139 @@
+package corerepo
+
+import (
+	"context"
+	"sort"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	"github.com/ipfs/go-ipfs/core"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+// PinStats sums the pins of a node up.
+type PinStats struct {
+	// The pins of each type, counted as 'ipfs pin ls' lists them: the
+	// indirect pins are the blocks under recursive pins which aren't
+	// recursive pins themselves, and the direct pins the ones which aren't
+	// under recursive pins.
+	Recursive uint64
+	Direct    uint64
+	Indirect  uint64
+
+	// Size is the size of the pinned blocks stored locally, each block
+	// counted once.
+	Size uint64
+
+	// Missing counts the pinned blocks which are not stored locally. Their
+	// children are unknown, and not counted.
+	Missing uint64
+
+	// Largest are the recursive and direct pins using the most space,
+	// largest first.
+	Largest []PinSize `json:",omitempty"`
+}
+
+// PinSize is the size of the local blocks of the dag of a pin.
+type PinSize struct {
+	Cid  string
+	Type string
+	Size uint64
+}
+
+// PinStat sums the pins of n up, with the largest pins. The dags are only
+// read from the local blockstore. They are walked once for all the pins, or
+// once for each recursive pin, for its size, with the largest pins.
+func PinStat(ctx context.Context, n *core.IpfsNode, largest int) (*PinStats, error) {
+	recursiveKeys, err := n.Pinning.RecursiveKeys()
+	if err != nil {
+		return nil, err
+	}
+	directKeys, err := n.Pinning.DirectKeys()
+	if err != nil {
+		return nil, err
+	}
+	recursive := cid.NewSet()
+	for _, c := range recursiveKeys {
+		recursive.Add(c)
+	}
+
+	// counted are the blocks summed up already, each is counted once even
+	// when the dags are walked for each pin
+	st := &PinStats{Recursive: uint64(len(recursiveKeys))}
+	counted := cid.NewSet()
+	perPin := largest > 0
+	found := func(nd node.Node) {
+		if perPin && !counted.Visit(nd.Cid()) {
+			return
+		}
+		st.Size += uint64(len(nd.RawData()))
+		if !recursive.Has(nd.Cid()) {
+			st.Indirect++
+		}
+	}
+	missing := func(c *cid.Cid) {
+		if perPin && !counted.Visit(c) {
+			return
+		}
+		st.Missing++
+	}
+
+	var sizes []PinSize
+	for _, c := range recursiveKeys {
+		if !perPin {
+			if err := walkLocalDag(ctx, n, c, counted, found, missing); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var size uint64
+		err := walkLocalDag(ctx, n, c, cid.NewSet(), func(nd node.Node) {
+			size += uint64(len(nd.RawData()))
+			found(nd)
+		}, missing)
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, PinSize{Cid: c.String(), Type: "recursive", Size: size})
+	}
+
+	for _, c := range directKeys {
+		if counted.Has(c) {
+			continue
+		}
+		st.Direct++
+
+		blk, err := n.Blockstore.Get(c)
+		switch err {
+		case nil:
+		case bstore.ErrNotFound:
+			st.Missing++
+			continue
+		default:
+			return nil, err
+		}
+		size := uint64(len(blk.RawData()))
+		st.Size += size
+		if perPin {
+			sizes = append(sizes, PinSize{Cid: c.String(), Type: "direct", Size: size})
+		}
+	}
+
+	if !perPin {
+		return st, nil
+	}
+	sort.Stable(pinsBySize(sizes))
+	if len(sizes) > largest {
+		sizes = sizes[:largest]
+	}
+	st.Largest = sizes
+	return st, nil
+}
+
+type pinsBySize []PinSize
+
+func (s pinsBySize) Len() int           { return len(s) }
+func (s pinsBySize) Less(i, j int) bool { return s[i].Size > s[j].Size }
+func (s pinsBySize) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,176 @@
+package corerepo
+
+import (
+	"context"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/core"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	filestore "github.com/ipfs/go-ipfs/filestore"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// PinStatus is the result of the verification of a pin.
+type PinStatus struct {
+	Cid      string
+	Ok       bool
+	BadNodes []BadNode `json:",omitempty"`
+	// the bad blocks fetched again by Repair
+	Fixed []string `json:",omitempty"`
+}
+
+// BadNode is a block of a pinned dag which is missing or corrupt. Its
+// children are not checked.
+type BadNode struct {
+	Cid string
+	Err string
+}
+
+// PinVerifier checks that the dags of pins are complete in the local
+// blockstore, and that their blocks match their hashes. Dags shared by
+// several pins are only checked once.
+type PinVerifier struct {
+	node  *core.IpfsNode
+	dag   dag.DAGService
+	pacer *Pacer
+
+	good *cid.Set
+	bad  map[string][]BadNode
+}
+
+// NewPinVerifier creates a verifier reading the blocks of n, paced by pacer.
+func NewPinVerifier(n *core.IpfsNode, pacer *Pacer) *PinVerifier {
+	// bypass the caches, the blocks must be read and hashed
+	bs := bstore.NewBlockstore(n.Repo.Datastore())
+	bs.HashOnRead(true)
+
+	var vbs bstore.Blockstore = bs
+	if n.Filestore != nil {
+		vbs = filestore.NewFilestore(bs, n.Repo.FileManager())
+	}
+
+	return &PinVerifier{
+		node:  n,
+		dag:   dag.NewDAGService(bserv.New(vbs, offline.Exchange(vbs))),
+		pacer: pacer,
+		good:  cid.NewSet(),
+		bad:   make(map[string][]BadNode),
+	}
+}
+
+// Verify checks the dag under root.
+func (v *PinVerifier) Verify(ctx context.Context, root *cid.Cid) (*PinStatus, error) {
+	bad, err := v.check(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &PinStatus{Cid: root.String(), Ok: len(bad) == 0}
+	seen := make(map[string]bool)
+	for _, b := range bad {
+		if !seen[b.Cid] {
+			seen[b.Cid] = true
+			st.BadNodes = append(st.BadNodes, b)
+		}
+	}
+	return st, nil
+}
+
+// Repair fetches the bad blocks of the broken pin st from the network, with
+// the dags under them, the corrupt ones deleted first, and verifies the pin
+// again. The status returned lists the blocks fixed, and the ones still bad.
+func (v *PinVerifier) Repair(ctx context.Context, st *PinStatus) (*PinStatus, error) {
+	root, err := cid.Decode(st.Cid)
+	if err != nil {
+		return nil, err
+	}
+
+	defer v.node.Blockstore.PinLock().Unlock()
+	ctx = exchange.WithClass(ctx, exchange.Bulk)
+	ctx = v.node.PinProviders.Track(ctx)
+
+	for _, b := range st.BadNodes {
+		c, err := cid.Decode(b.Cid)
+		if err != nil {
+			return nil, err
+		}
+
+		// deleting the missing blocks too clears them from the caches of
+		// the blockstore, which could skip writing them once fetched
+		err = v.node.Blockstore.DeleteBlock(c)
+		if err != nil && err != bstore.ErrNotFound && err != bstore.ErrBlockInUse {
+			return nil, err
+		}
+
+		if err := dag.FetchGraph(ctx, c, v.node.DAG); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Debugf("pin verify: could not fetch %s: %s", c, err)
+		}
+	}
+
+	// the blocks checked are rechecked, the good ones stay good
+	v.bad = make(map[string][]BadNode)
+	nst, err := v.Verify(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	still := make(map[string]bool)
+	for _, b := range nst.BadNodes {
+		still[b.Cid] = true
+	}
+	for _, b := range st.BadNodes {
+		if !still[b.Cid] {
+			nst.Fixed = append(nst.Fixed, b.Cid)
+		}
+	}
+	return nst, nil
+}
+
+func (v *PinVerifier) check(ctx context.Context, c *cid.Cid) ([]BadNode, error) {
+	if v.good.Has(c) {
+		return nil, nil
+	}
+	if bad, ok := v.bad[c.KeyString()]; ok {
+		return bad, nil
+	}
+
+	nd, err := v.dag.Get(ctx, c)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		msg := err.Error()
+		if err == dag.ErrNotFound {
+			msg = "missing"
+		}
+		bad := []BadNode{{Cid: c.String(), Err: msg}}
+		v.bad[c.KeyString()] = bad
+		return bad, nil
+	}
+	if err := v.pacer.Wait(ctx, len(nd.RawData())); err != nil {
+		return nil, err
+	}
+
+	var bad []BadNode
+	for _, l := range nd.Links() {
+		b, err := v.check(ctx, l.Cid)
+		if err != nil {
+			return nil, err
+		}
+		bad = append(bad, b...)
+	}
+
+	if len(bad) == 0 {
+		v.good.Add(c)
+	} else {
+		v.bad[c.KeyString()] = bad
+	}
+	return bad, nil
+}
@@ -0,0 +1,102 @@
+package corerepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+var retainedKey = ds.NewKey("/local/gc/retained")
+
+// retainedLk guards the read, modify and write of the retained roots.
+var retainedLk sync.Mutex
+
+// Retain keeps the unpinned DAG under c from being garbage collected until
+// the given time. After that, its blocks are collected like any other
+// unpinned ones. Retaining a root again only extends its deadline.
+func Retain(r repo.Repo, c *cid.Cid, until time.Time) error {
+	retainedLk.Lock()
+	defer retainedLk.Unlock()
+
+	roots, err := getRetained(r)
+	if err != nil {
+		return err
+	}
+
+	k := c.String()
+	if until.After(roots[k]) {
+		roots[k] = until
+	}
+	return putRetained(r, roots)
+}
+
+// RetainedRoots returns the roots retained past now, and forgets the ones
+// that expired.
+func RetainedRoots(r repo.Repo, now time.Time) ([]*cid.Cid, error) {
+	retainedLk.Lock()
+	defer retainedLk.Unlock()
+
+	roots, err := getRetained(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*cid.Cid
+	expired := false
+	for k, until := range roots {
+		if !until.After(now) {
+			delete(roots, k)
+			expired = true
+			continue
+		}
+
+		c, err := cid.Decode(k)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+
+	if expired {
+		if err := putRetained(r, roots); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func getRetained(r repo.Repo) (map[string]time.Time, error) {
+	roots := make(map[string]time.Time)
+
+	val, err := r.Datastore().Get(retainedKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return roots, nil
+	default:
+		return nil, err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("retained roots are not stored as bytes")
+	}
+	if err := json.Unmarshal(b, &roots); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+func putRetained(r repo.Repo, roots map[string]time.Time) error {
+	b, err := json.Marshal(roots)
+	if err != nil {
+		return err
+	}
+	return r.Datastore().Put(retainedKey, b)
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/ipfs/go-ipfs/pin"
 	posinfo "github.com/ipfs/go-ipfs/thirdparty/posinfo"
 	unixfs "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
 	syncds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
@@ -36,6 +37,9 @@ var log = logging.Logger("coreunix")
 // how many bytes of progress to wait before sending a progress update message
 const progressReaderIncrement = 1024 * 256
 
+// liveCacheSize is the number of files and directories added between two
+// flushes of the in-progress tree, after which its directories are dropped
+// from memory.
 var liveCacheSize = uint64(256 << 10)
 
 type Link struct {
@@ -66,8 +70,9 @@ func (e *ignoreFileError) Error() string {
 
 type AddedObject struct {
 	Name  string
-	Hash  string `json:",omitempty"`
-	Bytes int64  `json:",omitempty"`
+	Hash  string      `json:",omitempty"`
+	Bytes int64       `json:",omitempty"`
+	Dedup *DedupStats `json:",omitempty"`
 }
 
 func NewAdder(ctx context.Context, p pin.Pinner, bs bstore.GCBlockstore, ds dag.DAGService) (*Adder, error) {
@@ -107,6 +112,24 @@ type Adder struct {
 	tempRoot   *cid.Cid
 	Prefix     *cid.Prefix
 	liveNodes  uint64
+
+	// open are the directories being read, outermost first. They are built
+	// outside of the in-progress tree, and put in it once complete.
+	open []*openDir
+
+	// Retain, if set, is called instead of pinning the root when Pin is
+	// false. The blockstore is locked against gc as when pinning.
+	Retain func(*cid.Cid) error
+}
+
+// openDir is a directory being added.
+type openDir struct {
+	path string
+	dir  *uio.Directory
+
+	// stashed is set once the directory was put in its parent as it was, to
+	// keep its children from the gc.
+	stashed bool
 }
 
 func (adder *Adder) mfsRoot() (*mfs.Root, error) {
@@ -184,7 +207,7 @@ func (adder *Adder) PinRoot() error {
 	if err != nil {
 		return err
 	}
-	if !adder.Pin {
+	if !adder.Pin && adder.Retain == nil {
 		return nil
 	}
 
@@ -193,6 +216,10 @@ func (adder *Adder) PinRoot() error {
 		return err
 	}
 
+	if !adder.Pin {
+		return adder.Retain(rnk)
+	}
+
 	if adder.tempRoot != nil {
 		err := adder.pinning.Unpin(adder.ctx, adder.tempRoot, true)
 		if err != nil {
@@ -384,18 +411,7 @@ func (adder *Adder) addNode(node node.Node, path string) error {
 		node = pi.Node
 	}
 
-	mr, err := adder.mfsRoot()
-	if err != nil {
-		return err
-	}
-	dir := gopath.Dir(path)
-	if dir != "." {
-		if err := mfs.Mkdir(mr, dir, true, false); err != nil {
-			return err
-		}
-	}
-
-	if err := mfs.PutNode(mr, path, node); err != nil {
+	if err := adder.putNode(node, path); err != nil {
 		return err
 	}
 
@@ -407,7 +423,7 @@ func (adder *Adder) addNode(node node.Node, path string) error {
 
 // AddFile adds the given file while respecting the adder.
 func (adder *Adder) AddFile(file files.File) error {
-	if adder.Pin {
+	if adder.Pin || adder.Retain != nil {
 		adder.unlocker = adder.blockstore.PinLock()
 	}
 	defer func() {
@@ -434,6 +450,13 @@ func (adder *Adder) addFile(file files.File) error {
 		if err := mr.Flush(); err != nil {
 			return err
 		}
+		// the flushed directories are in the blockstore now, they are
+		// read back from it if more files are added to them.
+		if dir, ok := mr.GetValue().(*mfs.Directory); ok {
+			if err := dir.UncacheAll(); err != nil {
+				return err
+			}
+		}
 		adder.liveNodes = 0
 	}
 	adder.liveNodes++
@@ -481,6 +504,43 @@ func (adder *Adder) addFile(file files.File) error {
 	return adder.addNode(dagnode, file.FileName())
 }
 
+// putNode puts the node at path, in the directory being read it belongs to
+// if any. As in the in-progress tree, the node is stored with the tree's DAG
+// service, for the directories to be walked once added.
+func (adder *Adder) putNode(node node.Node, path string) error {
+	if len(adder.open) == 0 {
+		return adder.putInTree(node, path)
+	}
+
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return err
+	}
+	if _, err := mr.DAGService().Add(node); err != nil {
+		return err
+	}
+	parent := adder.open[len(adder.open)-1]
+	return parent.dir.AddChild(adder.ctx, gopath.Base(path), node)
+}
+
+// putInTree puts the node at path in the in-progress tree.
+func (adder *Adder) putInTree(node node.Node, path string) error {
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return err
+	}
+	dir := gopath.Dir(path)
+	if dir != "." {
+		if err := mfs.Mkdir(mr, dir, true, false); err != nil {
+			return err
+		}
+	}
+	return mfs.PutNode(mr, path, node)
+}
+
+// addDir adds the directory as it is read. The directories below it are
+// stored as soon as they are complete, and only their links are kept, so
+// that the memory used doesn't grow with the size of the tree.
 func (adder *Adder) addDir(dir files.File) error {
 	log.Infof("adding directory: %s", dir.FileName())
 
@@ -488,11 +548,30 @@ func (adder *Adder) addDir(dir files.File) error {
 	if err != nil {
 		return err
 	}
-	err = mfs.Mkdir(mr, dir.FileName(), true, false)
+	// the directories are read back from the tree's DAG service, which isn't
+	// the one the files are added with for --only-hash
+	d := &openDir{path: dir.FileName(), dir: uio.NewDirectory(mr.DAGService())}
+	d.dir.SetPrefix(adder.Prefix)
+	adder.open = append(adder.open, d)
+
+	if err := adder.readDir(dir); err != nil {
+		return err
+	}
+	adder.open = adder.open[:len(adder.open)-1]
+
+	nd, err := d.dir.GetNode()
 	if err != nil {
 		return err
 	}
+	if d.stashed && len(adder.open) == 0 {
+		if err := adder.unlinkStashed(d.path); err != nil {
+			return err
+		}
+	}
+	return adder.putNode(nd, d.path)
+}
 
+func (adder *Adder) readDir(dir files.File) error {
 	for {
 		file, err := dir.NextFile()
 		if err != nil && err != io.EOF {
@@ -516,8 +595,65 @@ func (adder *Adder) addDir(dir files.File) error {
 	return nil
 }
 
+// stashOpenDirs puts the directories being read in their parents as they
+// are, and the outermost one in the in-progress tree, for the files already
+// read to be kept by the pin of its root.
+func (adder *Adder) stashOpenDirs() error {
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return err
+	}
+	for i := len(adder.open) - 1; i >= 0; i-- {
+		d := adder.open[i]
+		nd, err := d.dir.GetNode()
+		if err != nil {
+			return err
+		}
+		if _, err := mr.DAGService().Add(nd); err != nil {
+			return err
+		}
+
+		if i > 0 {
+			err = adder.open[i-1].dir.AddChild(adder.ctx, gopath.Base(d.path), nd)
+		} else {
+			if d.stashed {
+				if err := adder.unlinkStashed(d.path); err != nil {
+					return err
+				}
+			}
+			err = adder.putInTree(nd, d.path)
+		}
+		if err != nil {
+			return err
+		}
+		d.stashed = true
+	}
+	return nil
+}
+
+// unlinkStashed removes a directory stashed in the in-progress tree.
+func (adder *Adder) unlinkStashed(path string) error {
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return err
+	}
+	dirp, name := gopath.Split(path)
+	fsn, err := mfs.Lookup(mr, dirp)
+	if err != nil {
+		return err
+	}
+	parent, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return fmt.Errorf("%s is not a directory", dirp)
+	}
+	return parent.Unlink(name)
+}
+
 func (adder *Adder) maybePauseForGC() error {
 	if adder.unlocker != nil && adder.blockstore.GCRequested() {
+		if err := adder.stashOpenDirs(); err != nil {
+			return err
+		}
 		err := adder.PinRoot()
 		if err != nil {
 			return err
@@ -16,11 +16,14 @@ import (
 	"github.com/ipfs/go-ipfs/commands/files"
 	"github.com/ipfs/go-ipfs/core"
 	dag "github.com/ipfs/go-ipfs/merkledag"
+	dagtest "github.com/ipfs/go-ipfs/merkledag/test"
+	mfs "github.com/ipfs/go-ipfs/mfs"
 	"github.com/ipfs/go-ipfs/pin/gc"
 	"github.com/ipfs/go-ipfs/repo"
 	"github.com/ipfs/go-ipfs/repo/config"
 	pi "github.com/ipfs/go-ipfs/thirdparty/posinfo"
 	"github.com/ipfs/go-ipfs/thirdparty/testutil"
+	unixfs "github.com/ipfs/go-ipfs/unixfs"
 
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 )
@@ -215,6 +218,52 @@ func testAddWPosInfo(t *testing.T, rawLeaves bool) {
 	}
 }
 
+func TestAddRetain(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: "Qmfoo", // required by offline node
+			},
+		},
+		D: testutil.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Pin = false
+
+	var retained []*cid.Cid
+	adder.Retain = func(c *cid.Cid) error {
+		retained = append(retained, c)
+		return nil
+	}
+
+	data := ioutil.NopCloser(bytes.NewBufferString("retained data"))
+	if err := adder.AddFile(files.NewReaderFile("a", "a", data, nil)); err != nil {
+		t.Fatal(err)
+	}
+	root, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := adder.PinRoot(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(retained) != 1 || !retained[0].Equals(root.Cid()) {
+		t.Fatalf("expected the root %s to be retained, got %v", root.Cid(), retained)
+	}
+	if _, pinned, _ := node.Pinning.IsPinned(root.Cid()); pinned {
+		t.Fatal("expected the root not to be pinned")
+	}
+}
+
 func TestAddWPosInfo(t *testing.T) {
 	testAddWPosInfo(t, false)
 }
@@ -271,3 +320,53 @@ func (fi *dummyFileInfo) Mode() os.FileMode  { return 0 }
 func (fi *dummyFileInfo) ModTime() time.Time { return fi.modTime }
 func (fi *dummyFileInfo) IsDir() bool        { return false }
 func (fi *dummyFileInfo) Sys() interface{}   { return nil }
+
+func TestAddWithOwnTree(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: "Qmfoo", // required by offline node
+			},
+		},
+		D: testutil.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree := func() files.File {
+		data := ioutil.NopCloser(bytes.NewBufferString("nested data"))
+		c := files.NewReaderFile("a/b/c", "a/b/c", data, nil)
+		b := files.NewSliceFile("a/b", "a/b", []files.File{c})
+		return files.NewSliceFile("a", "a", []files.File{b})
+	}
+
+	add := func(mr *mfs.Root) *cid.Cid {
+		adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mr != nil {
+			adder.SetMfsRoot(mr)
+		}
+		if err := adder.AddFile(tree()); err != nil {
+			t.Fatal(err)
+		}
+		root, err := adder.Finalize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return root.Cid()
+	}
+
+	// as with --only-hash, the directories are kept in the tree's own DAG
+	// service
+	mr, err := mfs.NewRoot(context.Background(), dagtest.Mock(), unixfs.EmptyDirNode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if own, c := add(mr), add(nil); !own.Equals(c) {
+		t.Fatalf("expected the same root, got %s and %s", own, c)
+	}
+}
@@ -2,13 +2,27 @@ package coreunix
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
 
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	blockstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
 	core "github.com/ipfs/go-ipfs/core"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 )
 
 func Cat(ctx context.Context, n *core.IpfsNode, pstr string) (uio.DagReader, error) {
+	// the blocks of a file are likely to come from the same peers
+	ctx = exchange.WithSession(ctx)
+
 	r := &path.Resolver{
 		DAG:         n.DAG,
 		ResolveOnce: uio.ResolveUnixfsOnce,
@@ -21,3 +35,118 @@ func Cat(ctx context.Context, n *core.IpfsNode, pstr string) (uio.DagReader, err
 
 	return uio.NewDagReader(ctx, dagNode, n.DAG)
 }
+
+// VerifyCat reads the whole file at pstr, checking the hash of each of its
+// blocks, wherever they come from. It returns the size of the file and the
+// number of blocks read, without keeping the data.
+func VerifyCat(ctx context.Context, n *core.IpfsNode, pstr string) (uint64, int, error) {
+	ctx = exchange.WithSession(ctx)
+
+	r := &path.Resolver{
+		DAG:         n.DAG,
+		ResolveOnce: uio.ResolveUnixfsOnce,
+	}
+
+	resolved, err := core.Resolve(ctx, n.Namesys, r, path.Path(pstr))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	vbs := &verifyingBlockService{BlockService: n.Blocks}
+	ds := dag.NewDAGService(vbs)
+
+	// get the root again, so that it's checked too
+	root, err := ds.Get(ctx, resolved.Cid())
+	if err != nil {
+		return 0, 0, vbs.error(err)
+	}
+
+	dr, err := uio.NewDagReader(ctx, root, ds)
+	if err != nil {
+		return 0, 0, vbs.error(err)
+	}
+	defer dr.Close()
+
+	read, err := io.Copy(ioutil.Discard, dr)
+	if err != nil {
+		return 0, 0, vbs.error(err)
+	}
+	if uint64(read) != dr.Size() {
+		return 0, 0, fmt.Errorf("read %d bytes, but the file is %d bytes long", read, dr.Size())
+	}
+	return uint64(read), vbs.count(), nil
+}
+
+// verifyingBlockService hashes the blocks it gets, and fails if one doesn't
+// match its CID.
+type verifyingBlockService struct {
+	bserv.BlockService
+
+	lk     sync.Mutex
+	blocks int
+	err    error
+}
+
+func (s *verifyingBlockService) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	b, err := s.BlockService.GetBlock(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verify(c, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *verifyingBlockService) GetBlocks(ctx context.Context, ks []*cid.Cid) <-chan blocks.Block {
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		for b := range s.BlockService.GetBlocks(ctx, ks) {
+			// stop there, the reader fails on the missing block
+			if err := s.verify(b.Cid(), b); err != nil {
+				return
+			}
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (s *verifyingBlockService) verify(c *cid.Cid, b blocks.Block) error {
+	sum, err := c.Prefix().Sum(b.RawData())
+	if err == nil && !sum.Equals(c) {
+		err = fmt.Errorf("block %s: %s", c, blockstore.ErrHashMismatch)
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if err != nil {
+		if s.err == nil {
+			s.err = err
+		}
+		return err
+	}
+	s.blocks++
+	return nil
+}
+
+func (s *verifyingBlockService) count() int {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.blocks
+}
+
+// error returns the first verification error, or err if there was none.
+func (s *verifyingBlockService) error(err error) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
@@ -0,0 +1,133 @@
+package coreunix
+
+import (
+	"context"
+	"sync"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+
+	metrics "gx/ipfs/QmRg1gKTHzc3CZXSKzem8aR4E3TubFhbgXwfVuWnSK5CC5/go-metrics-interface"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// DedupStats counts the blocks an add wrote, and the ones that were already
+// in the blockstore.
+type DedupStats struct {
+	NewBlocks uint64
+	NewBytes  uint64
+	DupBlocks uint64
+	DupBytes  uint64
+}
+
+type dedupMetrics struct {
+	newBlocks metrics.Counter
+	newBytes  metrics.Counter
+	dupBlocks metrics.Counter
+	dupBytes  metrics.Counter
+}
+
+// the metrics are registered once per process, by the first add
+var (
+	dedupMetricsOnce sync.Once
+	addDedupMetrics  *dedupMetrics
+)
+
+func getDedupMetrics(ctx context.Context) *dedupMetrics {
+	dedupMetricsOnce.Do(func() {
+		ctx = metrics.CtxSubScope(ctx, "add")
+		addDedupMetrics = &dedupMetrics{
+			newBlocks: metrics.NewCtx(ctx, "new_blocks_total", "Number of blocks written by adds").Counter(),
+			newBytes:  metrics.NewCtx(ctx, "new_bytes_total", "Size of the blocks written by adds").Counter(),
+			dupBlocks: metrics.NewCtx(ctx, "dup_blocks_total", "Number of blocks added which were already stored").Counter(),
+			dupBytes:  metrics.NewCtx(ctx, "dup_bytes_total", "Size of the blocks added which were already stored").Counter(),
+		}
+	})
+	return addDedupMetrics
+}
+
+// DedupCounter is a BlockService counting the blocks added through it which
+// were already in its blockstore.
+type DedupCounter struct {
+	bserv.BlockService
+	metrics *dedupMetrics
+
+	lk    sync.Mutex
+	stats DedupStats
+}
+
+// NewDedupCounter wraps bs. The counts are also reported as metrics, in the
+// scope of ctx.
+func NewDedupCounter(ctx context.Context, bs bserv.BlockService) *DedupCounter {
+	return &DedupCounter{
+		BlockService: bs,
+		metrics:      getDedupMetrics(ctx),
+	}
+}
+
+// Stats returns the counts of the blocks added so far.
+func (d *DedupCounter) Stats() DedupStats {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	return d.stats
+}
+
+func (d *DedupCounter) AddBlock(b blocks.Block) (*cid.Cid, error) {
+	has, err := d.Blockstore().Has(b.Cid())
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := d.BlockService.AddBlock(b)
+	if err != nil {
+		return nil, err
+	}
+	d.count(b, has)
+	return c, nil
+}
+
+func (d *DedupCounter) AddBlocks(bs []blocks.Block) ([]*cid.Cid, error) {
+	present := make([]bool, len(bs))
+	seen := make(map[string]bool, len(bs))
+	for i, b := range bs {
+		k := b.Cid().KeyString()
+		if seen[k] {
+			present[i] = true
+			continue
+		}
+		seen[k] = true
+
+		has, err := d.Blockstore().Has(b.Cid())
+		if err != nil {
+			return nil, err
+		}
+		present[i] = has
+	}
+
+	cs, err := d.BlockService.AddBlocks(bs)
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range bs {
+		d.count(b, present[i])
+	}
+	return cs, nil
+}
+
+func (d *DedupCounter) count(b blocks.Block, dup bool) {
+	size := uint64(len(b.RawData()))
+
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	if dup {
+		d.stats.DupBlocks++
+		d.stats.DupBytes += size
+		d.metrics.dupBlocks.Inc()
+		d.metrics.dupBytes.Add(float64(size))
+	} else {
+		d.stats.NewBlocks++
+		d.stats.NewBytes += size
+		d.metrics.newBlocks.Inc()
+		d.metrics.newBytes.Add(float64(size))
+	}
+}
@@ -0,0 +1,41 @@
+package coreunix
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/blocks"
+	"github.com/ipfs/go-ipfs/blocks/blockstore"
+	"github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	syncds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+)
+
+func TestDedupCounter(t *testing.T) {
+	bs := blockstore.NewBlockstore(syncds.MutexWrap(ds.NewMapDatastore()))
+	d := NewDedupCounter(context.Background(), blockservice.New(bs, offline.Exchange(bs)))
+
+	a := blocks.NewBlock([]byte("a block"))
+	b := blocks.NewBlock([]byte("another block"))
+
+	if _, err := d.AddBlock(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.AddBlocks([]blocks.Block{a, b, b}); err != nil {
+		t.Fatal(err)
+	}
+
+	st := d.Stats()
+	if st.NewBlocks != 2 || st.DupBlocks != 2 {
+		t.Fatalf("expected 2 new and 2 duplicate blocks, got %+v", st)
+	}
+	size := uint64(len(a.RawData()) + len(b.RawData()))
+	if st.NewBytes != size {
+		t.Fatalf("expected %d new bytes, got %d", size, st.NewBytes)
+	}
+	if st.DupBytes != size {
+		t.Fatalf("expected %d duplicate bytes, got %d", size, st.DupBytes)
+	}
+}
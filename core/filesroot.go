@@ -0,0 +1,75 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// FilesRootKey is where the root of the files API is stored, as the bytes of
+// its CID.
+var FilesRootKey = ds.NewKey("/local/filesroot")
+
+// filesRootHistoryKey keeps the last roots of the files API, newest first, one
+// CID per line, so that the files can be restored if the root is lost.
+var filesRootHistoryKey = ds.NewKey("/local/filesroot/history")
+
+// FilesRootHistoryLen is the number of roots kept in the history.
+var FilesRootHistoryLen = 16
+
+// SetFilesRoot stores c as the root of the files API, and adds it to the
+// history of the roots.
+func SetFilesRoot(d ds.Datastore, c *cid.Cid) error {
+	if err := d.Put(FilesRootKey, c.Bytes()); err != nil {
+		return err
+	}
+
+	hist, err := FilesRootHistory(d)
+	if err != nil {
+		// a broken history must not stop the files from being saved
+		log.Warningf("cannot read the history of the files root, starting it again: %s", err)
+		hist = nil
+	}
+	if len(hist) > 0 && hist[0].Equals(c) {
+		return nil
+	}
+
+	lines := []string{c.String()}
+	for _, h := range hist {
+		if len(lines) == FilesRootHistoryLen {
+			break
+		}
+		lines = append(lines, h.String())
+	}
+	return d.Put(filesRootHistoryKey, []byte(strings.Join(lines, "\n")))
+}
+
+// FilesRootHistory returns the last roots of the files API, newest first.
+// Lines of the history which aren't CIDs are skipped.
+func FilesRootHistory(d ds.Datastore) ([]*cid.Cid, error) {
+	val, err := d.Get(filesRootHistoryKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%s is not stored as bytes", filesRootHistoryKey)
+	}
+
+	var out []*cid.Cid
+	for _, l := range strings.Split(string(b), "\n") {
+		c, err := cid.Decode(l)
+		if err != nil {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
@@ -0,0 +1,26 @@
+package core
+
+import (
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// ImportPrefix returns the CID prefix of new protobuf objects set by the
+// Import config, or nil for the default one.
+func ImportPrefix(cfg *config.Import) (*cid.Prefix, error) {
+	hashFun := cfg.HashFunction
+	if hashFun == "" {
+		hashFun = config.DefaultHashFunction
+	}
+
+	prefix, err := dag.PrefixForHash(cfg.CidVersion, hashFun)
+	if err != nil {
+		return nil, err
+	}
+	if prefix.Version == 0 {
+		return nil, nil
+	}
+	return &prefix, nil
+}
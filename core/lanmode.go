@@ -0,0 +1,172 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	discovery "gx/ipfs/QmRai5yZNL67pWCoznW7sBdFnqZrFULuJ5w8KhmRyhdgN4/go-libp2p/p2p/discovery"
+	swarm "gx/ipfs/Qmeo7oJxR65PLPx68KPFi8rjzcEmmWN2dL66fPuq9nVMv8/go-libp2p-swarm"
+)
+
+// LanNetworks are the address ranges a node in LAN mode talks to: private,
+// link-local and loopback addresses.
+var LanNetworks = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"169.254.0.0/16",
+	"127.0.0.0/8",
+	"fc00::/7",
+	"fe80::/10",
+	"::1/128",
+}
+
+var (
+	ErrNotSwarm      = errors.New("peerhost network is not a swarm")
+	ErrLanModeOnline = errors.New("LAN mode needs the node to be online")
+)
+
+// lanMode is the state of the LAN mode of a node.
+type lanMode struct {
+	lk      sync.Mutex
+	filters []*net.IPNet
+
+	// discovery is set when mDNS was started for the LAN mode
+	discovery bool
+}
+
+// LanMode returns whether the node only talks to peers on the local network.
+func (n *IpfsNode) LanMode() bool {
+	n.lan.lk.Lock()
+	defer n.lan.lk.Unlock()
+	return n.lan.filters != nil
+}
+
+// SetLanMode turns the LAN mode on or off. In LAN mode, the node neither
+// dials nor accepts connections with addresses outside of LanNetworks, and
+// finds its peers with mDNS. Its DHT is made of the peers of the local
+// network. Connections to other peers are closed when it is turned on.
+func (n *IpfsNode) SetLanMode(on bool) error {
+	if n.PeerHost == nil {
+		return ErrLanModeOnline
+	}
+	snet, ok := n.PeerHost.Network().(*swarm.Network)
+	if !ok {
+		return ErrNotSwarm
+	}
+
+	n.lan.lk.Lock()
+	defer n.lan.lk.Unlock()
+
+	if on == (n.lan.filters != nil) {
+		return nil
+	}
+
+	if !on {
+		for _, f := range n.lan.filters {
+			snet.Filters.Remove(f)
+		}
+		n.lan.filters = nil
+
+		if n.lan.discovery {
+			n.Discovery.Close()
+			n.Discovery = nil
+			n.lan.discovery = false
+		}
+		return nil
+	}
+
+	filters, err := wanNetworks()
+	if err != nil {
+		return err
+	}
+	for _, f := range filters {
+		snet.Filters.AddDialFilter(f)
+	}
+	n.lan.filters = filters
+
+	for _, c := range n.PeerHost.Network().Conns() {
+		if snet.Filters.AddrBlocked(c.RemoteMultiaddr()) {
+			c.Close()
+		}
+	}
+
+	if n.Discovery == nil {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return err
+		}
+		if err := n.startLanDiscovery(cfg.Discovery.MDNS); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *IpfsNode) startLanDiscovery(cfg config.MDNS) error {
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = 5
+	}
+
+	service, err := discovery.NewMdnsService(n.Context(), n.PeerHost, time.Duration(interval)*time.Second)
+	if err != nil {
+		return err
+	}
+	service.RegisterNotifee(n)
+	n.Discovery = service
+	n.lan.discovery = true
+	return nil
+}
+
+// wanNetworks returns the address ranges outside of LanNetworks.
+func wanNetworks() ([]*net.IPNet, error) {
+	var lan []*net.IPNet
+	for _, s := range LanNetworks {
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		lan = append(lan, ipnet)
+	}
+
+	_, all4, _ := net.ParseCIDR("0.0.0.0/0")
+	_, all6, _ := net.ParseCIDR("::/0")
+	return append(complementNets(all4, lan), complementNets(all6, lan)...), nil
+}
+
+// complementNets returns the smallest set of networks covering the addresses
+// of universe that aren't in any of nets.
+func complementNets(universe *net.IPNet, nets []*net.IPNet) []*net.IPNet {
+	ones, bits := universe.Mask.Size()
+
+	overlaps := false
+	for _, n := range nets {
+		nones, nbits := n.Mask.Size()
+		if nbits != bits {
+			continue
+		}
+		if nones <= ones && n.Contains(universe.IP) {
+			return nil
+		}
+		if universe.Contains(n.IP) {
+			overlaps = true
+		}
+	}
+	if !overlaps {
+		return []*net.IPNet{universe}
+	}
+
+	mask := net.CIDRMask(ones+1, bits)
+	lo := &net.IPNet{IP: make(net.IP, len(universe.IP)), Mask: mask}
+	copy(lo.IP, universe.IP)
+	hi := &net.IPNet{IP: make(net.IP, len(universe.IP)), Mask: mask}
+	copy(hi.IP, universe.IP)
+	hi.IP[ones/8] |= 0x80 >> uint(ones%8)
+
+	return append(complementNets(lo, nets), complementNets(hi, nets)...)
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"net"
+	"testing"
+)
+
+func TestWanNetworks(t *testing.T) {
+	wan, err := wanNetworks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocked := func(s string) bool {
+		ip := net.ParseIP(s)
+		for _, n := range wan {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, s := range []string{"8.8.8.8", "11.0.0.1", "172.32.0.1", "192.169.0.1", "2001:db8::1", "::2"} {
+		if !blocked(s) {
+			t.Errorf("expected %s to be blocked", s)
+		}
+	}
+	for _, s := range []string{"10.1.2.3", "172.16.5.4", "192.168.1.1", "127.0.0.1", "169.254.0.1", "fd00::1", "fe80::1", "::1"} {
+		if blocked(s) {
+			t.Errorf("expected %s not to be blocked", s)
+		}
+	}
+}
+
+func TestComplementNets(t *testing.T) {
+	_, universe, _ := net.ParseCIDR("10.0.0.0/8")
+	_, hole, _ := net.ParseCIDR("10.128.0.0/9")
+
+	out := complementNets(universe, []*net.IPNet{hole})
+	if len(out) != 1 || out[0].String() != "10.0.0.0/9" {
+		t.Fatalf("expected [10.0.0.0/9], got %v", out)
+	}
+
+	if out := complementNets(hole, []*net.IPNet{universe}); len(out) != 0 {
+		t.Fatalf("expected no networks, got %v", out)
+	}
+}
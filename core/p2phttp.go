@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	inet "gx/ipfs/QmVHSBsn8LEeay8m5ERebgUVuhzw838PsyTttCmP6GMJkg/go-libp2p-net"
+	pro "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	p2phost "gx/ipfs/QmcyNeWPsoFGxThGpV8JnJdfUNankKhWCTrbrcFRQda4xR/go-libp2p-host"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// P2PHTTPProtocol is the libp2p protocol HTTP requests are proxied over, one
+// request per stream.
+const P2PHTTPProtocol = "/x/http/1.0.0"
+
+// P2PHTTPPeerHeader is set on the requests forwarded to an exposed service to
+// the ID of the peer which sent them. The peer is authenticated by libp2p, so
+// services can rely on it to authorize requests.
+const P2PHTTPPeerHeader = "X-Libp2p-Peer"
+
+var ErrNotExposed = errors.New("no http service is exposed")
+
+// P2PHTTPService exposes a local HTTP service to other peers over
+// P2PHTTPProtocol.
+type P2PHTTPService struct {
+	host p2phost.Host
+
+	lk      sync.Mutex
+	target  *url.URL
+	allowed map[peer.ID]struct{} // nil allows any peer
+	lis     *streamListener
+}
+
+// NewP2PHTTPService returns a P2PHTTPService for h, exposing nothing.
+func NewP2PHTTPService(h p2phost.Host) *P2PHTTPService {
+	return &P2PHTTPService{host: h}
+}
+
+// Expose forwards the requests of other peers to target, replacing the
+// service exposed before, if any. If allowed isn't empty, the requests of
+// other peers are refused.
+func (ps *P2PHTTPService) Expose(target *url.URL, allowed []peer.ID) {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	if ps.lis != nil {
+		ps.lis.Close()
+	}
+
+	ps.target = target
+	ps.allowed = nil
+	if len(allowed) > 0 {
+		ps.allowed = make(map[peer.ID]struct{}, len(allowed))
+		for _, p := range allowed {
+			ps.allowed[p] = struct{}{}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ps.lis = &streamListener{
+		local:  ps.host.ID(),
+		conns:  make(chan net.Conn),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the remote address of the streams is the peer ID
+		r.Header.Set(P2PHTTPPeerHeader, r.RemoteAddr)
+		proxy.ServeHTTP(w, r)
+	})
+	go (&http.Server{Handler: handler}).Serve(ps.lis)
+
+	lis, allow := ps.lis, ps.allowed
+	ps.host.SetStreamHandler(pro.ID(P2PHTTPProtocol), func(s inet.Stream) {
+		if allow != nil {
+			if _, ok := allow[s.Conn().RemotePeer()]; !ok {
+				log.Debugf("refused http request of %s", s.Conn().RemotePeer())
+				s.Close()
+				return
+			}
+		}
+		lis.push(&streamConn{s})
+	})
+}
+
+// Exposed returns the exposed service and the peers allowed to use it, nil
+// if none is.
+func (ps *P2PHTTPService) Exposed() (*url.URL, []peer.ID) {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	var allowed []peer.ID
+	for p := range ps.allowed {
+		allowed = append(allowed, p)
+	}
+	return ps.target, allowed
+}
+
+// Close stops exposing the service. Requests in progress are completed.
+func (ps *P2PHTTPService) Close() error {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	if ps.lis == nil {
+		return ErrNotExposed
+	}
+	ps.host.RemoveStreamHandler(pro.ID(P2PHTTPProtocol))
+	ps.lis.Close()
+	ps.lis = nil
+	ps.target = nil
+	ps.allowed = nil
+	return nil
+}
+
+// streamListener is a net.Listener accepting the streams pushed to it.
+type streamListener struct {
+	local  peer.ID
+	conns  chan net.Conn
+	ctx    context.Context
+	cancel func()
+}
+
+func (l *streamListener) push(c net.Conn) {
+	select {
+	case l.conns <- c:
+	case <-l.ctx.Done():
+		c.Close()
+	}
+}
+
+func (l *streamListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.ctx.Done():
+		return nil, l.ctx.Err()
+	}
+}
+
+func (l *streamListener) Close() error {
+	l.cancel()
+	return nil
+}
+
+func (l *streamListener) Addr() net.Addr {
+	return peerAddr(l.local)
+}
+
+// streamConn makes a stream a net.Conn. Its addresses are peer IDs.
+type streamConn struct {
+	inet.Stream
+}
+
+func (c *streamConn) LocalAddr() net.Addr  { return peerAddr(c.Conn().LocalPeer()) }
+func (c *streamConn) RemoteAddr() net.Addr { return peerAddr(c.Conn().RemotePeer()) }
+
+// Streams don't time out, the requests are bounded by the services instead.
+func (c *streamConn) SetDeadline(t time.Time) error      { return nil }
+func (c *streamConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *streamConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type peerAddr peer.ID
+
+func (a peerAddr) Network() string { return "libp2p" }
+func (a peerAddr) String() string  { return peer.ID(a).Pretty() }
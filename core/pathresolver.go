@@ -21,42 +21,49 @@ var ErrNoNamesys = errors.New(
 // entries (e.g. /ipns/<node-key>) and then going through the /ipfs/
 // entries and returning the final node.
 func Resolve(ctx context.Context, nsys namesys.NameSystem, r *path.Resolver, p path.Path) (node.Node, error) {
-	if strings.HasPrefix(p.String(), "/ipns/") {
-		// resolve ipns paths
-
-		// TODO(cryptix): we sould be able to query the local cache for the path
-		if nsys == nil {
-			return nil, ErrNoNamesys
-		}
-
-		seg := p.Segments()
-
-		if len(seg) < 2 || seg[1] == "" { // just "/<protocol/>" without further segments
-			return nil, path.ErrNoComponents
-		}
-
-		extensions := seg[2:]
-		resolvable, err := path.FromSegments("/", seg[0], seg[1])
-		if err != nil {
-			return nil, err
-		}
-
-		respath, err := nsys.Resolve(ctx, resolvable.String())
-		if err != nil {
-			return nil, err
-		}
-
-		segments := append(respath.Segments(), extensions...)
-		p, err = path.FromSegments("/", segments...)
-		if err != nil {
-			return nil, err
-		}
+	p, err := ResolveIPNS(ctx, nsys, p)
+	if err != nil {
+		return nil, err
 	}
 
 	// ok, we have an IPFS path now (or what we'll treat as one)
 	return r.ResolvePath(ctx, p)
 }
 
+// ResolveIPNS resolves /ipns paths. If the path is not an /ipns path it is
+// returned unchanged, otherwise the name is resolved through the given
+// name system and the remaining segments are appended to the result.
+func ResolveIPNS(ctx context.Context, nsys namesys.NameSystem, p path.Path) (path.Path, error) {
+	if !strings.HasPrefix(p.String(), "/ipns/") {
+		return p, nil
+	}
+
+	// TODO(cryptix): we sould be able to query the local cache for the path
+	if nsys == nil {
+		return "", ErrNoNamesys
+	}
+
+	seg := p.Segments()
+
+	if len(seg) < 2 || seg[1] == "" { // just "/<protocol/>" without further segments
+		return "", path.ErrNoComponents
+	}
+
+	extensions := seg[2:]
+	resolvable, err := path.FromSegments("/", seg[0], seg[1])
+	if err != nil {
+		return "", err
+	}
+
+	respath, err := nsys.Resolve(ctx, resolvable.String())
+	if err != nil {
+		return "", err
+	}
+
+	segments := append(respath.Segments(), extensions...)
+	return path.FromSegments("/", segments...)
+}
+
 // ResolveToKey resolves a path to a key.
 //
 // It first checks if the path is already in the form of just a key (<key> or
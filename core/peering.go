@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
+	inet "gx/ipfs/QmVHSBsn8LEeay8m5ERebgUVuhzw838PsyTttCmP6GMJkg/go-libp2p-net"
+	p2phost "gx/ipfs/QmcyNeWPsoFGxThGpV8JnJdfUNankKhWCTrbrcFRQda4xR/go-libp2p-host"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// PeeringInterval is how often the connections to the peering peers are
+// checked, and the dropped ones reopened.
+var PeeringInterval = 30 * time.Second
+
+// PeeringService keeps the node connected to a set of peers, reconnecting to
+// them when their connections drop.
+type PeeringService struct {
+	host p2phost.Host
+
+	lk    sync.Mutex
+	peers map[peer.ID]pstore.PeerInfo
+}
+
+// NewPeeringService returns a PeeringService for h, without any peers.
+func NewPeeringService(h p2phost.Host) *PeeringService {
+	return &PeeringService{
+		host:  h,
+		peers: make(map[peer.ID]pstore.PeerInfo),
+	}
+}
+
+// AddPeer adds a peer to stay connected to, replacing its addresses if it was
+// already added.
+func (ps *PeeringService) AddPeer(pi pstore.PeerInfo) {
+	ps.lk.Lock()
+	ps.peers[pi.ID] = pi
+	ps.lk.Unlock()
+
+	ps.host.Peerstore().AddAddrs(pi.ID, pi.Addrs, pstore.PermanentAddrTTL)
+}
+
+// RemovePeer stops reconnecting to a peer. The current connection is kept.
+func (ps *PeeringService) RemovePeer(id peer.ID) bool {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	_, ok := ps.peers[id]
+	delete(ps.peers, id)
+	return ok
+}
+
+// Peers returns the peers the node stays connected to.
+func (ps *PeeringService) Peers() []pstore.PeerInfo {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	out := make([]pstore.PeerInfo, 0, len(ps.peers))
+	for _, pi := range ps.peers {
+		out = append(out, pi)
+	}
+	return out
+}
+
+// Connected returns whether the node is currently connected to a peer.
+func (ps *PeeringService) Connected(id peer.ID) bool {
+	return ps.host.Network().Connectedness(id) == inet.Connected
+}
+
+// Start connects to the peers, then checks the connections every
+// PeeringInterval until ctx is done.
+func (ps *PeeringService) Start(ctx context.Context) {
+	go func() {
+		tick := time.NewTicker(PeeringInterval)
+		defer tick.Stop()
+
+		for {
+			ps.reconnect(ctx)
+
+			select {
+			case <-tick.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (ps *PeeringService) reconnect(ctx context.Context) {
+	for _, pi := range ps.Peers() {
+		if ps.Connected(pi.ID) {
+			continue
+		}
+
+		go func(pi pstore.PeerInfo) {
+			ctx, cancel := context.WithTimeout(ctx, PeeringInterval)
+			defer cancel()
+			if err := ps.host.Connect(ctx, pi); err != nil {
+				log.Debugf("connecting to peering peer %s: %s", pi.ID, err)
+			}
+		}(pi)
+	}
+}
+
+// PeeringPeers parses the peers listed in the Peering section of the config.
+func PeeringPeers(cfg *config.Config) ([]pstore.PeerInfo, error) {
+	parsed, err := config.ParseBootstrapPeers(cfg.Peering.Peers)
+	if err != nil {
+		return nil, err
+	}
+	return toPeerInfos(parsed), nil
+}
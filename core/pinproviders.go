@@ -0,0 +1,278 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	inet "gx/ipfs/QmVHSBsn8LEeay8m5ERebgUVuhzw838PsyTttCmP6GMJkg/go-libp2p-net"
+	p2phost "gx/ipfs/QmcyNeWPsoFGxThGpV8JnJdfUNankKhWCTrbrcFRQda4xR/go-libp2p-host"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var pinProvidersKey = ds.NewKey("/local/pinproviders")
+
+var (
+	// PinProvidersMax is how many of the peers which served pinned blocks
+	// are remembered. The ones which served the longest ago are forgotten
+	// first.
+	PinProvidersMax = 64
+
+	// PinProvidersKeep is how many of the peers which served the most
+	// pinned blocks the node reconnects to, after starting and when their
+	// connections drop.
+	PinProvidersKeep = 8
+)
+
+// PinProvider is what the node remembers of a peer which served blocks of
+// its pins.
+type PinProvider struct {
+	Peer       string
+	Addrs      []string `json:",omitempty"`
+	Blocks     uint64
+	Data       uint64
+	LastServed time.Time
+}
+
+// PinProviders tracks the peers which served the blocks fetched for pins,
+// and keeps the node connected to the ones which served the most. Fetching
+// favors them, so that pins are fetched again quickly, e.g. after a restart,
+// from the peers which had them before. The peers are saved in the repo.
+type PinProviders struct {
+	repo repo.Repo
+	host p2phost.Host
+
+	lk    sync.Mutex
+	peers map[peer.ID]*PinProvider
+	dirty bool
+}
+
+// LoadPinProviders returns the tracker of the peers which served the pins
+// of the node of h, with the peers saved in r.
+func LoadPinProviders(r repo.Repo, h p2phost.Host) (*PinProviders, error) {
+	pp := &PinProviders{
+		repo:  r,
+		host:  h,
+		peers: make(map[peer.ID]*PinProvider),
+	}
+
+	val, err := r.Datastore().Get(pinProvidersKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return pp, nil
+	default:
+		return nil, err
+	}
+	b, ok := val.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("pin providers are not stored as bytes")
+	}
+	var saved []*PinProvider
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return nil, err
+	}
+	for _, prov := range saved {
+		id, err := peer.IDB58Decode(prov.Peer)
+		if err != nil {
+			log.Warningf("forgetting invalid pin provider %q: %s", prov.Peer, err)
+			continue
+		}
+		pp.peers[id] = prov
+	}
+	return pp, nil
+}
+
+// Observe credits p with a block of size bytes fetched for a pin. It is a
+// BlockObserver.
+func (pp *PinProviders) Observe(p peer.ID, size int) {
+	pp.lk.Lock()
+	defer pp.lk.Unlock()
+
+	prov, ok := pp.peers[p]
+	if !ok {
+		prov = &PinProvider{Peer: p.Pretty()}
+		pp.peers[p] = prov
+	}
+	prov.Blocks++
+	prov.Data += uint64(size)
+	prov.LastServed = time.Now()
+	pp.dirty = true
+
+	if !ok {
+		pp.evict()
+	}
+}
+
+// evict forgets the peers which served the longest ago, past
+// PinProvidersMax. It is called with lk held.
+func (pp *PinProviders) evict() {
+	for len(pp.peers) > PinProvidersMax {
+		var oldest peer.ID
+		var served time.Time
+		for id, prov := range pp.peers {
+			if oldest == "" || prov.LastServed.Before(served) {
+				oldest, served = id, prov.LastServed
+			}
+		}
+		delete(pp.peers, oldest)
+	}
+}
+
+// Track returns a context crediting the peers sending the blocks requested
+// with it. pp may be nil, for the nodes which don't track the providers.
+func (pp *PinProviders) Track(ctx context.Context) context.Context {
+	if pp == nil {
+		return ctx
+	}
+	return exchange.WithBlockObserver(ctx, pp.Observe)
+}
+
+// Preferred tells whether p served pinned blocks before.
+func (pp *PinProviders) Preferred(p peer.ID) bool {
+	pp.lk.Lock()
+	defer pp.lk.Unlock()
+	_, ok := pp.peers[p]
+	return ok
+}
+
+// Top returns the n peers which served the most pinned data, the most first.
+// It returns all of them if n <= 0.
+func (pp *PinProviders) Top(n int) []PinProvider {
+	pp.lk.Lock()
+	defer pp.lk.Unlock()
+
+	provs := pp.sorted()
+	if n > 0 && len(provs) > n {
+		provs = provs[:n]
+	}
+	out := make([]PinProvider, 0, len(provs))
+	for _, prov := range provs {
+		out = append(out, *prov)
+	}
+	return out
+}
+
+// sorted returns the peers, the ones which served the most data first. It is
+// called with lk held.
+func (pp *PinProviders) sorted() []*PinProvider {
+	provs := make(pinProvidersByData, 0, len(pp.peers))
+	for _, prov := range pp.peers {
+		provs = append(provs, prov)
+	}
+	sort.Sort(provs)
+	return provs
+}
+
+type pinProvidersByData []*PinProvider
+
+func (s pinProvidersByData) Len() int      { return len(s) }
+func (s pinProvidersByData) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s pinProvidersByData) Less(i, j int) bool {
+	if s[i].Data != s[j].Data {
+		return s[i].Data > s[j].Data
+	}
+	return s[i].LastServed.After(s[j].LastServed)
+}
+
+// Flush saves the peers in the repo, with their current addresses, if they
+// changed since the last save.
+func (pp *PinProviders) Flush() error {
+	pp.lk.Lock()
+	if !pp.dirty {
+		pp.lk.Unlock()
+		return nil
+	}
+	provs := pp.sorted()
+	for _, prov := range provs {
+		id, _ := peer.IDB58Decode(prov.Peer)
+		if addrs := pp.host.Peerstore().Addrs(id); len(addrs) > 0 {
+			prov.Addrs = prov.Addrs[:0]
+			for _, a := range addrs {
+				prov.Addrs = append(prov.Addrs, a.String())
+			}
+		}
+	}
+	b, err := json.Marshal(provs)
+	pp.dirty = false
+	pp.lk.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return pp.repo.Datastore().Put(pinProvidersKey, b)
+}
+
+// Close saves the peers.
+func (pp *PinProviders) Close() error {
+	return pp.Flush()
+}
+
+// Start connects to the PinProvidersKeep peers which served the most, then
+// reconnects to them and saves the peers every PeeringInterval until ctx is
+// done.
+func (pp *PinProviders) Start(ctx context.Context) {
+	go func() {
+		tick := time.NewTicker(PeeringInterval)
+		defer tick.Stop()
+
+		for {
+			pp.reconnect(ctx)
+			if err := pp.Flush(); err != nil {
+				log.Error("saving pin providers: ", err)
+			}
+
+			select {
+			case <-tick.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (pp *PinProviders) reconnect(ctx context.Context) {
+	for _, prov := range pp.Top(PinProvidersKeep) {
+		pi, err := prov.peerInfo()
+		if err != nil {
+			log.Warningf("pin provider %s: %s", prov.Peer, err)
+			continue
+		}
+		if pi.ID == pp.host.ID() || pp.host.Network().Connectedness(pi.ID) == inet.Connected {
+			continue
+		}
+
+		go func(pi pstore.PeerInfo) {
+			ctx, cancel := context.WithTimeout(ctx, PeeringInterval)
+			defer cancel()
+			if err := pp.host.Connect(ctx, pi); err != nil {
+				log.Debugf("connecting to pin provider %s: %s", pi.ID, err)
+			}
+		}(pi)
+	}
+}
+
+func (prov *PinProvider) peerInfo() (pstore.PeerInfo, error) {
+	id, err := peer.IDB58Decode(prov.Peer)
+	if err != nil {
+		return pstore.PeerInfo{}, err
+	}
+	pi := pstore.PeerInfo{ID: id}
+	for _, s := range prov.Addrs {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return pstore.PeerInfo{}, err
+		}
+		pi.Addrs = append(pi.Addrs, a)
+	}
+	return pi, nil
+}
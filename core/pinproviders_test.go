@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	mocknet "gx/ipfs/QmRai5yZNL67pWCoznW7sBdFnqZrFULuJ5w8KhmRyhdgN4/go-libp2p/p2p/net/mock"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestPinProviders(t *testing.T) {
+	h, err := mocknet.New(context.Background()).GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &repo.Mock{D: testutil.ThreadSafeCloserMapDatastore()}
+
+	defer func(max int) { PinProvidersMax = max }(PinProvidersMax)
+	PinProvidersMax = 2
+
+	pp, err := LoadPinProviders(r, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, c := peer.ID("a"), peer.ID("b"), peer.ID("c")
+	pp.Observe(a, 10)
+	pp.Observe(b, 100)
+	pp.Observe(a, 10)
+
+	top := pp.Top(0)
+	if len(top) != 2 || top[0].Peer != b.Pretty() || top[1].Peer != a.Pretty() || top[1].Blocks != 2 || top[1].Data != 20 {
+		t.Fatalf("unexpected providers %+v", top)
+	}
+
+	// b served the longest ago, and is forgotten first
+	pp.Observe(c, 1)
+	if pp.Preferred(b) || !pp.Preferred(a) || !pp.Preferred(c) {
+		t.Fatalf("expected b to be forgotten, got %+v", pp.Top(0))
+	}
+
+	if err := pp.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPinProviders(r, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top = loaded.Top(1)
+	if len(top) != 1 || top[0].Peer != a.Pretty() || top[0].Data != 20 {
+		t.Fatalf("unexpected providers loaded %+v", top)
+	}
+	if !loaded.Preferred(c) {
+		t.Fatal("expected c to be loaded")
+	}
+}
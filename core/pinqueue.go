@@ -0,0 +1,239 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// PinQueueWorkers is the number of background pins made at the same time.
+var PinQueueWorkers = 2
+
+// PinJobRetries is how many times a failed background pin is tried again.
+var PinJobRetries = 3
+
+// PinJobRetryDelay is the delay before the first retry of a failed
+// background pin. It doubles at each retry.
+var PinJobRetryDelay = 30 * time.Second
+
+// PinJobsKept is the number of finished background pins kept to be
+// inspected.
+var PinJobsKept = 100
+
+// The states of a background pin.
+const (
+	PinJobQueued   = "queued"
+	PinJobRunning  = "running"
+	PinJobRetrying = "retrying"
+	PinJobDone     = "done"
+	PinJobFailed   = "failed"
+)
+
+// PinJobFunc makes the pins of a background pin, and returns the pinned
+// roots.
+type PinJobFunc func(ctx context.Context) ([]*cid.Cid, error)
+
+// PinJobStatus describes a background pin.
+type PinJobStatus struct {
+	ID        uint64
+	Paths     []string
+	Recursive bool
+	State     string
+	Attempts  int
+	Progress  int      `json:",omitempty"` // nodes fetched by the current attempt
+	Bytes     uint64   `json:",omitempty"` // size of the nodes fetched
+	TotalSize uint64   `json:",omitempty"` // size of the dags, as far as known
+	Error     string   `json:",omitempty"` // the error of the last attempt
+	Pins      []string `json:",omitempty"`
+	Created   time.Time
+	Updated   time.Time
+}
+
+// PinQueue makes pins in the background, retrying the ones that fail, so
+// that long fetches don't depend on the client that asked for them. The jobs
+// are kept in memory: the ones still running when the node stops fail.
+type PinQueue struct {
+	ctx context.Context
+	sem chan struct{}
+
+	lk       sync.Mutex
+	nextID   uint64
+	jobs     map[uint64]*pinJob
+	finished []uint64
+}
+
+type pinJob struct {
+	status   PinJobStatus
+	run      PinJobFunc
+	progress *dag.ProgressTracker
+}
+
+// NewPinQueue creates a PinQueue, running its jobs until ctx is done.
+func NewPinQueue(ctx context.Context) *PinQueue {
+	return &PinQueue{
+		ctx:    ctx,
+		sem:    make(chan struct{}, PinQueueWorkers),
+		nextID: 1,
+		jobs:   make(map[uint64]*pinJob),
+	}
+}
+
+// Add queues the pin of paths, made by run, and returns its status.
+func (q *PinQueue) Add(paths []string, recursive bool, run PinJobFunc) PinJobStatus {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	now := time.Now()
+	j := &pinJob{
+		status: PinJobStatus{
+			ID:        q.nextID,
+			Paths:     paths,
+			Recursive: recursive,
+			State:     PinJobQueued,
+			Created:   now,
+			Updated:   now,
+		},
+		run: run,
+	}
+	q.nextID++
+	q.jobs[j.status.ID] = j
+
+	go q.process(j)
+	return j.status
+}
+
+// Job returns the status of the job with the given ID.
+func (q *PinQueue) Job(id uint64) (PinJobStatus, bool) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return PinJobStatus{}, false
+	}
+	return j.current(), true
+}
+
+// Jobs returns the status of the queued, running and recently finished
+// jobs, by ID.
+func (q *PinQueue) Jobs() []PinJobStatus {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	out := make([]PinJobStatus, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		out = append(out, j.current())
+	}
+	sort.Sort(pinJobsByID(out))
+	return out
+}
+
+type pinJobsByID []PinJobStatus
+
+func (s pinJobsByID) Len() int           { return len(s) }
+func (s pinJobsByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+func (s pinJobsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// current returns the status of j, with the progress of the running attempt.
+func (j *pinJob) current() PinJobStatus {
+	st := j.status
+	if st.State == PinJobRunning && j.progress != nil {
+		st.Progress = j.progress.Value()
+		st.Bytes, st.TotalSize = j.progress.Bytes()
+	}
+	return st
+}
+
+func (q *PinQueue) process(j *pinJob) {
+	delay := PinJobRetryDelay
+	for {
+		select {
+		case q.sem <- struct{}{}:
+		case <-q.ctx.Done():
+			q.finish(j, nil, q.ctx.Err())
+			return
+		}
+
+		pins, err := q.attempt(j)
+		<-q.sem
+
+		if err == nil {
+			q.finish(j, pins, nil)
+			return
+		}
+
+		q.lk.Lock()
+		attempts := j.status.Attempts
+		q.lk.Unlock()
+		if attempts > PinJobRetries || q.ctx.Err() != nil {
+			q.finish(j, nil, err)
+			return
+		}
+
+		log.Debugf("background pin %d failed, retrying in %s: %s", j.status.ID, delay, err)
+		q.update(j, PinJobRetrying, err)
+
+		select {
+		case <-time.After(delay):
+		case <-q.ctx.Done():
+			q.finish(j, nil, q.ctx.Err())
+			return
+		}
+		delay *= 2
+	}
+}
+
+func (q *PinQueue) attempt(j *pinJob) ([]*cid.Cid, error) {
+	v := new(dag.ProgressTracker)
+	ctx := v.DeriveContext(q.ctx)
+
+	q.lk.Lock()
+	j.progress = v
+	j.status.Attempts++
+	j.status.State = PinJobRunning
+	j.status.Updated = time.Now()
+	q.lk.Unlock()
+
+	return j.run(ctx)
+}
+
+func (q *PinQueue) update(j *pinJob, state string, err error) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	j.status.State = state
+	j.status.Updated = time.Now()
+	if err != nil {
+		j.status.Error = err.Error()
+	}
+}
+
+// finish records the result of j, and forgets the oldest finished jobs.
+func (q *PinQueue) finish(j *pinJob, pins []*cid.Cid, err error) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	j.progress = nil
+	j.status.Updated = time.Now()
+	if err != nil {
+		j.status.State = PinJobFailed
+		j.status.Error = err.Error()
+	} else {
+		j.status.State = PinJobDone
+		j.status.Error = ""
+		for _, c := range pins {
+			j.status.Pins = append(j.status.Pins, c.String())
+		}
+	}
+
+	q.finished = append(q.finished, j.status.ID)
+	for len(q.finished) > PinJobsKept {
+		delete(q.jobs, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	inet "gx/ipfs/QmVHSBsn8LEeay8m5ERebgUVuhzw838PsyTttCmP6GMJkg/go-libp2p-net"
+	metrics "gx/ipfs/QmVMbSdq6PbznPC83SENVhH7JZn3BqqxkKgrHJFN2RuARf/go-libp2p-metrics"
+	pro "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// ProtocolStat is the traffic of one protocol since the node started.
+type ProtocolStat struct {
+	Protocol     pro.ID
+	StreamsOpen  int
+	StreamsTotal uint64
+	metrics.Stats
+}
+
+// ProtocolStats counts the streams of each protocol, to tell with the
+// bandwidth reporter which protocols use the bandwidth of the node. It also
+// tells since when the node is connected to each peer.
+type ProtocolStats struct {
+	lk     sync.Mutex
+	closed map[pro.ID]uint64
+	since  map[peer.ID]time.Time
+}
+
+func NewProtocolStats() *ProtocolStats {
+	return &ProtocolStats{
+		closed: make(map[pro.ID]uint64),
+		since:  make(map[peer.ID]time.Time),
+	}
+}
+
+// ConnectedSince returns since when the node is connected to p, without
+// interruption, and false if it isn't connected.
+func (ps *ProtocolStats) ConnectedSince(p peer.ID) (time.Time, bool) {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	t, ok := ps.since[p]
+	return t, ok
+}
+
+// Stats returns the traffic of each protocol which had streams on network,
+// by protocol ID. Bytes are only counted with a reporter.
+func (ps *ProtocolStats) Stats(network inet.Network, rep metrics.Reporter) []ProtocolStat {
+	open := make(map[pro.ID]int)
+	for _, c := range network.Conns() {
+		streams, err := c.GetStreams()
+		if err != nil {
+			continue
+		}
+		for _, s := range streams {
+			if p := s.Protocol(); p != "" {
+				open[p]++
+			}
+		}
+	}
+
+	stats := make(map[pro.ID]*ProtocolStat)
+	get := func(p pro.ID) *ProtocolStat {
+		st, ok := stats[p]
+		if !ok {
+			st = &ProtocolStat{Protocol: p}
+			stats[p] = st
+		}
+		return st
+	}
+
+	ps.lk.Lock()
+	for p, n := range ps.closed {
+		get(p).StreamsTotal += n
+	}
+	ps.lk.Unlock()
+	for p, n := range open {
+		st := get(p)
+		st.StreamsOpen = n
+		st.StreamsTotal += uint64(n)
+	}
+
+	out := make([]ProtocolStat, 0, len(stats))
+	for p, st := range stats {
+		if rep != nil {
+			st.Stats = rep.GetBandwidthForProtocol(p)
+		}
+		out = append(out, *st)
+	}
+	sort.Sort(protocolStatsByID(out))
+	return out
+}
+
+type protocolStatsByID []ProtocolStat
+
+func (s protocolStatsByID) Len() int           { return len(s) }
+func (s protocolStatsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s protocolStatsByID) Less(i, j int) bool { return s[i].Protocol < s[j].Protocol }
+
+// ClosedStream counts the streams of each protocol once they're closed, the
+// open ones are counted from the network. Streams closed before a protocol
+// was negotiated aren't counted.
+func (ps *ProtocolStats) ClosedStream(n inet.Network, s inet.Stream) {
+	p := s.Protocol()
+	if p == "" {
+		return
+	}
+
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	ps.closed[p]++
+}
+
+func (ps *ProtocolStats) Connected(n inet.Network, c inet.Conn) {
+	p := c.RemotePeer()
+
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	if _, ok := ps.since[p]; !ok {
+		ps.since[p] = time.Now()
+	}
+}
+
+// Disconnected forgets when the node connected to a peer once its last
+// connection to it is closed.
+func (ps *ProtocolStats) Disconnected(n inet.Network, c inet.Conn) {
+	p := c.RemotePeer()
+	if n.Connectedness(p) == inet.Connected {
+		return
+	}
+
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	delete(ps.since, p)
+}
+
+func (ps *ProtocolStats) OpenedStream(n inet.Network, s inet.Stream) {}
+func (ps *ProtocolStats) Listen(n inet.Network, a ma.Multiaddr)      {}
+func (ps *ProtocolStats) ListenClose(n inet.Network, a ma.Multiaddr) {}
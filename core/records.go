@@ -0,0 +1,291 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	dht "gx/ipfs/QmQcRLisUbREko56ThfgzdBorMGNfNjgqzvwuPPr1jFw6A/go-libp2p-kad-dht"
+	record "gx/ipfs/QmWYCqr6UDqqD1bfRybaAPtbAqcN3TSJpveaBXMwbQ3ePZ/go-libp2p-record"
+	routing "gx/ipfs/QmafuecpeZp3k3sHJ5mUARHd4795revuadECQMkmHB8LfW/go-libp2p-routing"
+	notif "gx/ipfs/QmafuecpeZp3k3sHJ5mUARHd4795revuadECQMkmHB8LfW/go-libp2p-routing/notifications"
+)
+
+// DefaultMaxRecordSize is the size limit of the values this node puts in the
+// routing system, for namespaces without their own limit.
+const DefaultMaxRecordSize = 2048
+
+// DefaultRecordQuorum is how many records GetRecord collects before picking
+// the best one, the quorum the DHT uses on its own.
+const DefaultRecordQuorum = 16
+
+// RecordNamespace holds the rules of the records stored under a routing key
+// namespace, e.g. "ipns" for the "/ipns/<peer id>" keys.
+type RecordNamespace struct {
+	// Validator checks the records of the namespace. Nodes drop the records
+	// it rejects.
+	Validator *record.ValidChecker
+
+	// Selector picks the best of several valid records for a key. The DHT
+	// default is used when nil.
+	Selector record.SelectorFunc
+
+	// MaxSize is the size limit of the values this node puts, 0 for
+	// DefaultMaxRecordSize.
+	MaxSize int
+
+	// MaxPutsPerHour limits how many values this node puts in the namespace,
+	// 0 for no limit.
+	MaxPutsPerHour int
+}
+
+var (
+	ErrUnknownNamespace = errors.New("no validator is registered for the key namespace")
+	ErrRecordTooLarge   = errors.New("record value is too large")
+	ErrRecordRateLimit  = errors.New("too many records put in the namespace, try again later")
+	ErrNoQuorum         = errors.New("record could not be written to enough peers")
+)
+
+var recordsLk sync.Mutex
+
+var recordNamespaces = map[string]RecordNamespace{
+	"pk": {
+		Validator: record.PublicKeyValidator,
+	},
+	IpnsValidatorTag: {
+		Validator: namesys.IpnsRecordValidator,
+		Selector:  namesys.IpnsSelectorFunc,
+	},
+}
+
+// recordPuts keeps the times of the puts of the last hour by namespace.
+var recordPuts = make(map[string][]time.Time)
+
+// RegisterRecordNamespace adds a record namespace to the routing systems of
+// the nodes constructed afterwards, and allows 'ipfs dht put' in it. It is
+// meant to be called from init functions, before any node is built.
+func RegisterRecordNamespace(name string, ns RecordNamespace) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid record namespace %q", name)
+	}
+	if ns.Validator == nil {
+		return fmt.Errorf("record namespace %s needs a validator", name)
+	}
+
+	recordsLk.Lock()
+	defer recordsLk.Unlock()
+
+	if _, ok := recordNamespaces[name]; ok {
+		return fmt.Errorf("record namespace %s is already registered", name)
+	}
+	recordNamespaces[name] = ns
+	return nil
+}
+
+// RecordNamespaces lists the names of the registered record namespaces.
+func RecordNamespaces() []string {
+	recordsLk.Lock()
+	defer recordsLk.Unlock()
+
+	names := make([]string, 0, len(recordNamespaces))
+	for name := range recordNamespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupRecordNamespace returns the namespace of a routing key, like
+// "/ipns/<peer id>".
+func LookupRecordNamespace(key string) (string, RecordNamespace, error) {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) != 3 || parts[0] != "" {
+		return "", RecordNamespace{}, fmt.Errorf("invalid record key %q", key)
+	}
+
+	recordsLk.Lock()
+	defer recordsLk.Unlock()
+
+	ns, ok := recordNamespaces[parts[1]]
+	if !ok {
+		return "", RecordNamespace{}, ErrUnknownNamespace
+	}
+	return parts[1], ns, nil
+}
+
+// CheckRecordPut checks that a value may be put under key: its namespace is
+// registered, it is small enough and valid, and the namespace is under its
+// put rate. Allowed puts count against the rate.
+func CheckRecordPut(key string, value []byte) error {
+	name, ns, err := LookupRecordNamespace(key)
+	if err != nil {
+		return err
+	}
+
+	max := ns.MaxSize
+	if max == 0 {
+		max = DefaultMaxRecordSize
+	}
+	if len(value) > max {
+		return ErrRecordTooLarge
+	}
+
+	// don't spread records other nodes are going to drop
+	if err := ns.Validator.Func(key, value); err != nil {
+		return fmt.Errorf("invalid record: %s", err)
+	}
+
+	if ns.MaxPutsPerHour == 0 {
+		return nil
+	}
+
+	recordsLk.Lock()
+	defer recordsLk.Unlock()
+
+	now := time.Now()
+	var recent []time.Time
+	for _, t := range recordPuts[name] {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= ns.MaxPutsPerHour {
+		recordPuts[name] = recent
+		return ErrRecordRateLimit
+	}
+	recordPuts[name] = append(recent, now)
+	return nil
+}
+
+// setRecordNamespaces configures the validators and selectors of the
+// registered namespaces in a DHT.
+func setRecordNamespaces(v record.Validator, s record.Selector) {
+	recordsLk.Lock()
+	defer recordsLk.Unlock()
+
+	for name, ns := range recordNamespaces {
+		v[name] = ns.Validator
+		if ns.Selector != nil {
+			s[name] = ns.Selector
+		}
+	}
+}
+
+// RecordOptions tune how records are read from and written to the DHT.
+type RecordOptions struct {
+	// Quorum is how many records are collected before picking the best one
+	// on reads, DefaultRecordQuorum if 0. On writes, it is how many peers the
+	// record must be sent to, none if 0. Small networks may never reach the
+	// default read quorum, and wait for the timeout on every read.
+	Quorum int
+
+	// Timeout bounds each attempt, 0 for the DHT defaults.
+	Timeout time.Duration
+
+	// Retries is how many times a failed attempt is retried.
+	Retries int
+}
+
+// GetRecord reads the best record for key from the DHT.
+func GetRecord(ctx context.Context, d *dht.IpfsDHT, key string, opts RecordOptions) ([]byte, error) {
+	quorum := opts.Quorum
+	if quorum <= 0 {
+		quorum = DefaultRecordQuorum
+	}
+
+	var err error
+	for i := 0; i <= opts.Retries; i++ {
+		var val []byte
+		val, err = getRecordOnce(ctx, d, key, quorum, opts.Timeout)
+		if err == nil {
+			return val, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+func getRecordOnce(ctx context.Context, d *dht.IpfsDHT, key string, quorum int, timeout time.Duration) ([]byte, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// the DHT returns the records found so far when the query ends early
+	vals, err := d.GetValues(ctx, key, quorum)
+	if err != nil {
+		return nil, err
+	}
+
+	recs := make([][]byte, 0, len(vals))
+	for _, v := range vals {
+		if v.Val != nil {
+			recs = append(recs, v.Val)
+		}
+	}
+	if len(recs) == 0 {
+		return nil, routing.ErrNotFound
+	}
+
+	i, err := d.Selector.BestRecord(key, recs)
+	if err != nil {
+		return nil, err
+	}
+	return recs[i], nil
+}
+
+// PutRecord writes a record to the DHT, retrying while it reached less than
+// opts.Quorum peers. The query events of the writes are published to ctx.
+func PutRecord(ctx context.Context, d *dht.IpfsDHT, key string, value []byte, opts RecordOptions) error {
+	var err error
+	for i := 0; i <= opts.Retries; i++ {
+		var sent int
+		sent, err = putRecordOnce(ctx, d, key, value, opts.Timeout)
+		if err == nil && sent < opts.Quorum {
+			err = fmt.Errorf("%s: sent to %d of %d peers", ErrNoQuorum, sent, opts.Quorum)
+		}
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+// putRecordOnce writes a record to the DHT, and returns to how many peers it
+// was sent.
+func putRecordOnce(ctx context.Context, d *dht.IpfsDHT, key string, value []byte, timeout time.Duration) (int, error) {
+	qctx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		qctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// count the peers the DHT sends the record to, passing the events on
+	events := make(chan *notif.QueryEvent)
+	qctx = notif.RegisterForQueryEvents(qctx, events)
+	sent := make(chan int)
+	go func() {
+		var n int
+		for e := range events {
+			if e.Type == notif.Value {
+				n++
+			}
+			notif.PublishQueryEvent(ctx, e)
+		}
+		sent <- n
+	}()
+
+	err := d.PutValue(qctx, key, value)
+	close(events)
+	return <-sent, err
+}
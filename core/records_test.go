@@ -0,0 +1,64 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	record "gx/ipfs/QmWYCqr6UDqqD1bfRybaAPtbAqcN3TSJpveaBXMwbQ3ePZ/go-libp2p-record"
+)
+
+func TestRecordNamespaces(t *testing.T) {
+	ns := RecordNamespace{
+		Validator: &record.ValidChecker{
+			Func: func(k string, v []byte) error {
+				if !strings.HasPrefix(string(v), "ok") {
+					return errors.New("bad value")
+				}
+				return nil
+			},
+		},
+		MaxSize:        8,
+		MaxPutsPerHour: 2,
+	}
+	if err := RegisterRecordNamespace("test", ns); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterRecordNamespace("test", ns); err == nil {
+		t.Fatal("expected registering a namespace twice to fail")
+	}
+	if err := RegisterRecordNamespace("a/b", ns); err == nil {
+		t.Fatal("expected an invalid namespace name to fail")
+	}
+
+	if err := CheckRecordPut("/other/key", []byte("ok")); err != ErrUnknownNamespace {
+		t.Fatalf("expected ErrUnknownNamespace, got %v", err)
+	}
+	if err := CheckRecordPut("/test/key", []byte("ok too long")); err != ErrRecordTooLarge {
+		t.Fatalf("expected ErrRecordTooLarge, got %v", err)
+	}
+	if err := CheckRecordPut("/test/key", []byte("bad")); err == nil {
+		t.Fatal("expected an invalid record to be refused")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := CheckRecordPut("/test/key", []byte("ok")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := CheckRecordPut("/test/key", []byte("ok")); err != ErrRecordRateLimit {
+		t.Fatalf("expected ErrRecordRateLimit, got %v", err)
+	}
+
+	v := make(record.Validator)
+	s := make(record.Selector)
+	setRecordNamespaces(v, s)
+	for _, name := range []string{"ipns", "pk", "test"} {
+		if v[name] == nil {
+			t.Fatalf("no validator set for %s", name)
+		}
+	}
+	if s["test"] != nil {
+		t.Fatal("expected no selector for the test namespace")
+	}
+}
@@ -0,0 +1,350 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
+	dht "gx/ipfs/QmQcRLisUbREko56ThfgzdBorMGNfNjgqzvwuPPr1jFw6A/go-libp2p-kad-dht"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	routing "gx/ipfs/QmafuecpeZp3k3sHJ5mUARHd4795revuadECQMkmHB8LfW/go-libp2p-routing"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// The services of the node RestartService restarts, besides the ones
+// registered with RegisterService.
+const (
+	ServiceBitswap    = "bitswap"
+	ServiceDHT        = "dht"
+	ServiceReprovider = "reprovider"
+)
+
+var (
+	ErrUnknownService    = errors.New("unknown service")
+	ErrRestartOffline    = errors.New("services can only be restarted when the node is online")
+	ErrServiceNotUsed    = errors.New("the node doesn't run this service")
+	ErrServiceRegistered = errors.New("service already registered")
+)
+
+// services is the state of the restartable services of a node.
+type services struct {
+	// lk serializes the restarts
+	lk    sync.Mutex
+	hooks map[string]func() error
+
+	// exchange is the exchange of the node when it uses bitswap, which
+	// forwards to the current bitswap
+	exchange *restartableExchange
+
+	// routing is the routing of the node when it is online, which
+	// forwards to the current DHT
+	routing       *restartableRouting
+	routingOption RoutingOption
+
+	reproviderCancel context.CancelFunc
+}
+
+// Bitswap returns the bitswap the node currently runs, nil if it doesn't use
+// bitswap. The node keeps forwarding to the same exchange across the
+// restarts of bitswap, the instance returned is replaced.
+func (n *IpfsNode) Bitswap() *bitswap.Bitswap {
+	if n.services.exchange == nil {
+		return nil
+	}
+	bs, _ := n.services.exchange.get().(*bitswap.Bitswap)
+	return bs
+}
+
+// DHT returns the DHT the node currently runs, nil if its routing is not a
+// DHT. As with Bitswap, the instance returned is replaced by the restarts.
+func (n *IpfsNode) DHT() *dht.IpfsDHT {
+	var r routing.IpfsRouting = n.Routing
+	if n.services.routing != nil {
+		r = n.services.routing.get()
+	}
+	d, _ := r.(*dht.IpfsDHT)
+	return d
+}
+
+// RegisterService registers a service RestartService restarts with restart,
+// e.g. the gateway of the daemon.
+func (n *IpfsNode) RegisterService(name string, restart func() error) error {
+	n.services.lk.Lock()
+	defer n.services.lk.Unlock()
+
+	if _, ok := n.services.hooks[name]; ok || n.builtinService(name) {
+		return fmt.Errorf("%s: %s", ErrServiceRegistered, name)
+	}
+	if n.services.hooks == nil {
+		n.services.hooks = make(map[string]func() error)
+	}
+	n.services.hooks[name] = restart
+	return nil
+}
+
+// Services returns the names of the services RestartService restarts.
+func (n *IpfsNode) Services() []string {
+	n.services.lk.Lock()
+	defer n.services.lk.Unlock()
+
+	var out []string
+	for _, name := range []string{ServiceBitswap, ServiceDHT, ServiceReprovider} {
+		if n.builtinService(name) {
+			out = append(out, name)
+		}
+	}
+	for name := range n.services.hooks {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// builtinService returns whether the node runs the given service of its own.
+func (n *IpfsNode) builtinService(name string) bool {
+	switch name {
+	case ServiceBitswap:
+		return n.Bitswap() != nil
+	case ServiceDHT:
+		return n.services.routing != nil && n.DHT() != nil
+	case ServiceReprovider:
+		return n.Reprovider != nil
+	}
+	return false
+}
+
+// RestartService tears a service of the node down and starts it again
+// against the live node, without closing the connections to the peers. The
+// requests the service was handling fail.
+//
+// The fields of the node are not replaced: the exchange and the routing of
+// the node forward to the current bitswap and DHT, and the reprovider is
+// restarted on the same instance. The rest of the node, such as the name
+// system, keeps using them through those.
+func (n *IpfsNode) RestartService(name string) error {
+	n.services.lk.Lock()
+	defer n.services.lk.Unlock()
+
+	if !n.OnlineMode() {
+		return ErrRestartOffline
+	}
+
+	if restart, ok := n.services.hooks[name]; ok {
+		log.Warningf("restarting %s", name)
+		return restart()
+	}
+
+	switch name {
+	case ServiceBitswap, ServiceDHT, ServiceReprovider:
+	default:
+		return fmt.Errorf("%s: %s", ErrUnknownService, name)
+	}
+	if !n.builtinService(name) {
+		return fmt.Errorf("%s: %s", name, ErrServiceNotUsed)
+	}
+
+	log.Warningf("restarting %s", name)
+	switch name {
+	case ServiceBitswap:
+		return n.restartBitswap()
+	case ServiceDHT:
+		return n.restartDHT()
+	default:
+		return n.restartReprovider()
+	}
+}
+
+// newBitswap starts bitswap on the peer host, using the routing of the node.
+func (n *IpfsNode) newBitswap(ctx context.Context) *bitswap.Bitswap {
+	const alwaysSendToPeer = true // use YesManStrategy
+	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.Routing)
+	return bitswap.New(ctx, n.Identity, bitswapNetwork, n.Blockstore, alwaysSendToPeer).(*bitswap.Bitswap)
+}
+
+func (n *IpfsNode) restartBitswap() error {
+	old := n.Bitswap()
+
+	bs := n.newBitswap(n.Context())
+	if n.PinProviders != nil {
+		bs.SetPreferredPeers(n.PinProviders.Preferred)
+	}
+	if n.AnnounceFilter != nil {
+		bs.SetAnnounceFilter(n.AnnounceFilter)
+	}
+
+	// the streams of the new bitswap replace the ones of the old one, the
+	// new one only hears of the peers connecting from now on
+	n.services.exchange.set(bs)
+	for _, p := range n.PeerHost.Network().Peers() {
+		bs.PeerConnected(p)
+	}
+
+	return old.Close()
+}
+
+func (n *IpfsNode) restartDHT() error {
+	old := n.DHT()
+	ctx := n.Context()
+
+	// the new DHT takes over the protocol handler of the old one, which
+	// keeps serving the lookups until the new one is in the forwarder, so
+	// a failed restart leaves the node with its old DHT
+	r, err := n.services.routingOption(ctx, n.PeerHost, n.Repo.Datastore())
+	if err != nil {
+		return err
+	}
+	if d, ok := r.(*dht.IpfsDHT); ok {
+		for _, p := range n.PeerHost.Network().Peers() {
+			d.Update(ctx, p)
+		}
+	}
+	n.services.routing.set(r)
+
+	if err := old.Process().Close(); err != nil {
+		log.Warning("closing the dht: ", err)
+	}
+
+	return r.Bootstrap(ctx)
+}
+
+// startReprovider runs the reprovider of the node at the interval of the
+// config, until ctx is done or the reprovider restarts.
+func (n *IpfsNode) startReprovider(ctx context.Context, cfg config.Reprovider) error {
+	if cfg.Interval == "0" {
+		return nil
+	}
+
+	interval := kReprovideFrequency
+	if cfg.Interval != "" {
+		dur, err := time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return err
+		}
+
+		interval = dur
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	n.services.reproviderCancel = cancel
+	go n.Reprovider.ProvideEvery(ctx, interval)
+	return nil
+}
+
+// restartReprovider stops the reprovide loop, interrupting the reprovide
+// running if any, and starts it again with the config of the node.
+func (n *IpfsNode) restartReprovider() error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	if n.services.reproviderCancel != nil {
+		n.services.reproviderCancel()
+		n.services.reproviderCancel = nil
+	}
+	return n.startReprovider(n.Context(), cfg.Reprovider)
+}
+
+// restartableRouting is the routing of an online node, forwarding to the
+// current DHT, or whichever routing the node was built with.
+type restartableRouting struct {
+	lk sync.RWMutex
+	r  routing.IpfsRouting
+}
+
+func newRestartableRouting(r routing.IpfsRouting) *restartableRouting {
+	return &restartableRouting{r: r}
+}
+
+func (rr *restartableRouting) get() routing.IpfsRouting {
+	rr.lk.RLock()
+	defer rr.lk.RUnlock()
+	return rr.r
+}
+
+func (rr *restartableRouting) set(r routing.IpfsRouting) {
+	rr.lk.Lock()
+	defer rr.lk.Unlock()
+	rr.r = r
+}
+
+func (rr *restartableRouting) PutValue(ctx context.Context, k string, v []byte) error {
+	return rr.get().PutValue(ctx, k, v)
+}
+
+func (rr *restartableRouting) GetValue(ctx context.Context, k string) ([]byte, error) {
+	return rr.get().GetValue(ctx, k)
+}
+
+func (rr *restartableRouting) GetValues(ctx context.Context, k string, count int) ([]routing.RecvdVal, error) {
+	return rr.get().GetValues(ctx, k, count)
+}
+
+func (rr *restartableRouting) Provide(ctx context.Context, c *cid.Cid) error {
+	return rr.get().Provide(ctx, c)
+}
+
+func (rr *restartableRouting) FindProvidersAsync(ctx context.Context, c *cid.Cid, count int) <-chan pstore.PeerInfo {
+	return rr.get().FindProvidersAsync(ctx, c, count)
+}
+
+func (rr *restartableRouting) FindPeer(ctx context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	return rr.get().FindPeer(ctx, p)
+}
+
+func (rr *restartableRouting) Bootstrap(ctx context.Context) error {
+	return rr.get().Bootstrap(ctx)
+}
+
+// restartableExchange is the exchange of a node using bitswap, forwarding to
+// the current bitswap.
+type restartableExchange struct {
+	lk sync.RWMutex
+	ex exchange.Interface
+}
+
+func newRestartableExchange(ex exchange.Interface) *restartableExchange {
+	return &restartableExchange{ex: ex}
+}
+
+func (e *restartableExchange) get() exchange.Interface {
+	e.lk.RLock()
+	defer e.lk.RUnlock()
+	return e.ex
+}
+
+func (e *restartableExchange) set(ex exchange.Interface) {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+	e.ex = ex
+}
+
+func (e *restartableExchange) GetBlock(ctx context.Context, k *cid.Cid) (blocks.Block, error) {
+	return e.get().GetBlock(ctx, k)
+}
+
+func (e *restartableExchange) GetBlocks(ctx context.Context, ks []*cid.Cid) (<-chan blocks.Block, error) {
+	return e.get().GetBlocks(ctx, ks)
+}
+
+func (e *restartableExchange) HasBlock(b blocks.Block) error {
+	return e.get().HasBlock(b)
+}
+
+func (e *restartableExchange) IsOnline() bool {
+	return e.get().IsOnline()
+}
+
+func (e *restartableExchange) Close() error {
+	return e.get().Close()
+}
@@ -0,0 +1,77 @@
+package core_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	core "github.com/ipfs/go-ipfs/core"
+	coremock "github.com/ipfs/go-ipfs/core/mock"
+)
+
+func TestRestartService(t *testing.T) {
+	n, err := coremock.NewMockNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	expected := []string{core.ServiceBitswap, core.ServiceDHT, core.ServiceReprovider}
+	if s := n.Services(); !reflect.DeepEqual(s, expected) {
+		t.Fatalf("expected services %v, got %v", expected, s)
+	}
+
+	// the fields of the node stay, the instances behind them are replaced
+	exchange, routing, reprovider := n.Exchange, n.Routing, n.Reprovider
+
+	old := n.Bitswap()
+	if err := n.RestartService(core.ServiceBitswap); err != nil {
+		t.Fatal(err)
+	}
+	if n.Bitswap() == old {
+		t.Fatal("bitswap not replaced")
+	}
+	// the block service uses the new bitswap, the old one is closed
+	if _, err := n.Blocks.AddBlock(blocks.NewBlock([]byte("after the restart"))); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDHT := n.DHT()
+	if err := n.RestartService(core.ServiceDHT); err != nil {
+		t.Fatal(err)
+	}
+	if n.DHT() == oldDHT {
+		t.Fatal("dht not replaced")
+	}
+
+	if err := n.RestartService(core.ServiceReprovider); err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Exchange != exchange || n.Routing != routing || n.Reprovider != reprovider {
+		t.Fatal("the restarts replaced fields of the node")
+	}
+
+	err = n.RestartService("nothing")
+	if err == nil || !strings.HasPrefix(err.Error(), core.ErrUnknownService.Error()) {
+		t.Fatalf("expected %s, got %v", core.ErrUnknownService, err)
+	}
+
+	restarted := false
+	if err := n.RegisterService("gateway", func() error {
+		restarted = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.RestartService("gateway"); err != nil || !restarted {
+		t.Fatal("registered service not restarted", err)
+	}
+	if err := n.RegisterService(core.ServiceDHT, nil); err == nil {
+		t.Fatal("the builtin services can't be registered again")
+	}
+	if s := n.Services(); len(s) != 4 || s[2] != "gateway" {
+		t.Fatalf("registered service not listed: %v", s)
+	}
+}
@@ -0,0 +1,226 @@
+// Package versioncheck compares the local version of go-ipfs against the
+// versions seen on the network, to warn users running a release too old to
+// speak the protocols most peers use.
+package versioncheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+)
+
+var log = logging.Logger("versioncheck")
+
+// NewerPeersThreshold is the share of peers running a newer release above
+// which the node is considered behind.
+var NewerPeersThreshold = 0.1
+
+// MinPeers is how many peers with a known version are needed to compare
+// against them. Less than that says nothing about the network.
+var MinPeers = 5
+
+// CheckInterval is the time between the daemon's checks. The first check is
+// done after StartDelay, once the node had time to find peers.
+var (
+	CheckInterval = 24 * time.Hour
+	StartDelay    = 5 * time.Minute
+)
+
+const agentPrefix = "go-ipfs/"
+
+// maxRecordSize is the largest release record read.
+const maxRecordSize = 4096
+
+// Result is the outcome of a version check.
+type Result struct {
+	Local string
+
+	// Peers counts the connected go-ipfs peers with a known version, and
+	// Newer the ones among them running a newer release. Versions counts
+	// the peers by version.
+	Peers    int
+	Newer    int
+	Versions map[string]int
+
+	// Release is the latest release, from the release record.
+	Release string `json:",omitempty"`
+
+	// Behind is set when the local release is older than the latest one, or
+	// than the one run by a significant share of the peers.
+	Behind  bool
+	Warning string `json:",omitempty"`
+}
+
+// ReleaseRecord is the document published under Version.ReleaseName.
+type ReleaseRecord struct {
+	Version string
+}
+
+// Check compares the local version against the versions of the connected
+// peers and the release record named in cfg, if any.
+func Check(ctx context.Context, n *core.IpfsNode, cfg config.Version) (*Result, error) {
+	var agents []string
+	for _, p := range n.PeerHost.Network().Peers() {
+		v, err := n.Peerstore.Get(p, "AgentVersion")
+		if err != nil {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			agents = append(agents, s)
+		}
+	}
+
+	var release string
+	if cfg.ReleaseName != "" {
+		rec, err := fetchRelease(ctx, n, cfg.ReleaseName)
+		if err != nil {
+			return nil, fmt.Errorf("fetching release record: %s", err)
+		}
+		release = rec.Version
+	}
+
+	return evaluate(config.CurrentVersionNumber, agents, release), nil
+}
+
+// Start runs Check periodically until ctx is done, writing a warning to w
+// when the node is behind.
+func Start(ctx context.Context, n *core.IpfsNode, cfg config.Version, w io.Writer) {
+	timer := time.NewTimer(StartDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+
+		res, err := Check(ctx, n, cfg)
+		switch {
+		case err != nil:
+			log.Warning("version check: ", err)
+		case res.Behind:
+			fmt.Fprintf(w, "WARNING: %s\n", res.Warning)
+		}
+		timer.Reset(CheckInterval)
+	}
+}
+
+func fetchRelease(ctx context.Context, n *core.IpfsNode, name string) (*ReleaseRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if !strings.HasPrefix(name, "/ipns/") {
+		name = "/ipns/" + name
+	}
+	r, err := coreunix.Cat(ctx, n, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	rec := new(ReleaseRecord)
+	if err := json.NewDecoder(io.LimitReader(r, maxRecordSize)).Decode(rec); err != nil {
+		return nil, err
+	}
+	if _, ok := parseVersion(rec.Version); !ok {
+		return nil, fmt.Errorf("invalid version %q", rec.Version)
+	}
+	return rec, nil
+}
+
+func evaluate(local string, agents []string, release string) *Result {
+	res := &Result{
+		Local:    local,
+		Release:  release,
+		Versions: make(map[string]int),
+	}
+	lv, ok := parseVersion(local)
+	if !ok {
+		return res
+	}
+
+	for _, a := range agents {
+		v, ok := agentVersion(a)
+		if !ok {
+			continue
+		}
+		pv, ok := parseVersion(v)
+		if !ok {
+			continue
+		}
+
+		res.Peers++
+		res.Versions[v]++
+		if lv.olderRelease(pv) {
+			res.Newer++
+		}
+	}
+
+	if rv, ok := parseVersion(release); ok && lv.olderRelease(rv) {
+		res.Behind = true
+		res.Warning = fmt.Sprintf("go-ipfs %s is out of date, the latest release is %s", local, release)
+		return res
+	}
+
+	if res.Peers >= MinPeers && float64(res.Newer) >= NewerPeersThreshold*float64(res.Peers) {
+		res.Behind = true
+		res.Warning = fmt.Sprintf("go-ipfs %s is out of date, %d of %d connected peers run a newer release", local, res.Newer, res.Peers)
+	}
+	return res
+}
+
+// agentVersion returns the go-ipfs version in an agent version string, e.g.
+// "0.4.9" for "go-ipfs/0.4.9/abcdef".
+func agentVersion(agent string) (string, bool) {
+	if !strings.HasPrefix(agent, agentPrefix) {
+		return "", false
+	}
+	return strings.Split(strings.TrimPrefix(agent, agentPrefix), "/")[0], true
+}
+
+type version struct {
+	major, minor, patch int
+}
+
+// parseVersion parses "major.minor.patch", ignoring a pre-release suffix
+// like "-dev".
+func parseVersion(s string) (version, bool) {
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return version{}, false
+	}
+
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return version{}, false
+		}
+		nums[i] = n
+	}
+	return version{nums[0], nums[1], nums[2]}, true
+}
+
+// olderRelease returns whether v is from an older major or minor release than
+// o. Patch releases don't change the protocols, they aren't compared.
+func (v version) olderRelease(o version) bool {
+	if v.major != o.major {
+		return v.major < o.major
+	}
+	return v.minor < o.minor
+}
@@ -0,0 +1,54 @@
+package versioncheck
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	cases := map[string]bool{
+		"0.4.9":     true,
+		"0.4.10-rc": true,
+		"1.2":       false,
+		"a.b.c":     false,
+		"":          false,
+	}
+	for s, ok := range cases {
+		if _, parsed := parseVersion(s); parsed != ok {
+			t.Errorf("parseVersion(%q): expected %t", s, ok)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	agents := []string{
+		"go-ipfs/0.4.9/abcdef",
+		"go-ipfs/0.4.9/",
+		"go-ipfs/0.4.10/",
+		"go-ipfs/0.5.0-dev/",
+		"js-ipfs/0.9.0",
+		"go-ipfs/garbage",
+	}
+
+	res := evaluate("0.4.9", agents, "")
+	if res.Peers != 4 || res.Newer != 1 {
+		t.Fatalf("expected 1 of 4 peers to be newer, got %d of %d", res.Newer, res.Peers)
+	}
+	if res.Versions["0.4.9"] != 2 {
+		t.Fatalf("expected 2 peers running 0.4.9, got %d", res.Versions["0.4.9"])
+	}
+	if res.Behind {
+		t.Fatal("too few peers to be behind")
+	}
+
+	res = evaluate("0.4.9", append(agents, "go-ipfs/0.4.9/", "go-ipfs/0.4.8/"), "")
+	if !res.Behind {
+		t.Fatal("expected to be behind with a newer release on 1 of 6 peers")
+	}
+
+	res = evaluate("0.4.9", nil, "0.4.12")
+	if res.Behind {
+		t.Fatal("patch releases shouldn't count")
+	}
+	res = evaluate("0.4.9", nil, "0.5.0")
+	if !res.Behind || res.Warning == "" {
+		t.Fatal("expected to be behind the release")
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	watchdog "github.com/ipfs/go-ipfs/core/watchdog"
+)
+
+// WatchdogChecks returns the watchdog checks of the subsystems of the node
+// which run their own event loops: bitswap and the DHT, when the node uses
+// them. The checks probe the current instances, and restart them with
+// RestartService.
+func (n *IpfsNode) WatchdogChecks() []watchdog.Check {
+	var checks []watchdog.Check
+	if n.Bitswap() != nil {
+		checks = append(checks, watchdog.Check{
+			Name: ServiceBitswap,
+			Probe: func(ctx context.Context) error {
+				bs := n.Bitswap()
+				if bs == nil {
+					return ErrServiceNotUsed
+				}
+				return bs.Alive(ctx)
+			},
+			Restart: func() error { return n.RestartService(ServiceBitswap) },
+		})
+	}
+	if n.DHT() != nil {
+		checks = append(checks, watchdog.Check{
+			Name:    ServiceDHT,
+			Probe:   n.probeDHT,
+			Restart: func() error { return n.RestartService(ServiceDHT) },
+		})
+	}
+	return checks
+}
+
+// dhtProbeTimeout is how long the DHT probe waits for a peer to answer
+// before it pings the next one, so that a slow peer isn't taken for a stall.
+const dhtProbeTimeout = 10 * time.Second
+
+// probeDHT sends a DHT ping to the connected peers in turn, until one
+// answers. The ping goes through the message senders of the DHT, and its
+// answer through the routing table update of the DHT, where a wedged DHT
+// blocks: then no peer answers before ctx is done. There is nothing to probe
+// without peers.
+func (n *IpfsNode) probeDHT(ctx context.Context) error {
+	d := n.DHT()
+	if d == nil {
+		return ErrServiceNotUsed
+	}
+
+	var err error
+	for _, p := range n.PeerHost.Network().Peers() {
+		pctx, cancel := context.WithTimeout(ctx, dhtProbeTimeout)
+		err = d.Ping(pctx, p)
+		cancel()
+		if err == nil || ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
@@ -0,0 +1,325 @@
+// Package watchdog detects the stalls of the subsystems of a node. It probes
+// them periodically, and when one doesn't answer in time, dumps the stacks of
+// all the goroutines to a file, to find the deadlock, and optionally
+// restarts it.
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+)
+
+var log = logging.Logger("watchdog")
+
+// MaxDumps is how many dumps are kept in the directory, the oldest ones are
+// removed.
+var MaxDumps = 20
+
+// dumpSuffix is the extension of the dump files.
+const dumpSuffix = ".stacks"
+
+// StalledError is returned by Run when a subsystem which can't be restarted
+// stalled, and the watchdog is asked to restart the stalled subsystems.
+type StalledError struct {
+	Name string
+}
+
+func (e *StalledError) Error() string {
+	return fmt.Sprintf("watchdog: %s stalled and can't be restarted in place", e.Name)
+}
+
+// Probe returns once the subsystem it probes answered, e.g. after a round
+// trip through its event loop. It should return the error of ctx when ctx is
+// done, though the watchdog also copes with probes which stay blocked.
+type Probe func(ctx context.Context) error
+
+// Check is a subsystem watched.
+type Check struct {
+	Name  string
+	Probe Probe
+
+	// Restart restarts the subsystem after a stall, nil if it can't be
+	// restarted in place.
+	Restart func() error
+}
+
+// Config tells how the watchdog watches.
+type Config struct {
+	// Interval is how often the subsystems are probed, and Timeout how long
+	// they have to answer before they are considered stalled.
+	Interval time.Duration
+	Timeout  time.Duration
+
+	// Dir is the directory the stacks are dumped to.
+	Dir string
+
+	// Restart makes the watchdog restart the stalled subsystems. Run
+	// returns a StalledError for the ones which can't be restarted, for the
+	// daemon to shut down and its supervisor to restart it.
+	Restart bool
+}
+
+// Status describes the health of a subsystem.
+type Status struct {
+	Name    string
+	Healthy bool
+	LastOK  time.Time
+	// StalledSince is when the probe which didn't answer was sent, zero if
+	// the subsystem isn't stalled.
+	StalledSince time.Time
+	Stalls       int
+	LastDump     string `json:",omitempty"`
+	Error        string `json:",omitempty"`
+}
+
+// Watchdog probes the subsystems of a node.
+type Watchdog struct {
+	cfg    Config
+	checks []*check
+
+	lk sync.Mutex
+}
+
+type check struct {
+	Check
+
+	lastOK       time.Time
+	stalledSince time.Time
+	stalls       int
+	lastDump     string
+	err          error
+}
+
+// New returns a watchdog of the given subsystems.
+func New(cfg Config, checks ...Check) *Watchdog {
+	w := &Watchdog{cfg: cfg}
+	for _, c := range checks {
+		w.checks = append(w.checks, &check{Check: c})
+	}
+	return w
+}
+
+// Run watches the subsystems until ctx is done. It returns a StalledError
+// when a subsystem which can't be restarted stalled, if the watchdog
+// restarts them.
+func (w *Watchdog) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fatal := make(chan error, len(w.checks))
+	var wg sync.WaitGroup
+	for _, c := range w.checks {
+		wg.Add(1)
+		go func(c *check) {
+			defer wg.Done()
+			w.watch(ctx, c, fatal)
+		}(c)
+	}
+
+	var err error
+	select {
+	case err = <-fatal:
+	case <-ctx.Done():
+	}
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// Status returns the health of the subsystems.
+func (w *Watchdog) Status() []Status {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	out := make([]Status, 0, len(w.checks))
+	for _, c := range w.checks {
+		st := Status{
+			Name:         c.Name,
+			Healthy:      c.stalledSince.IsZero() && c.err == nil,
+			LastOK:       c.lastOK,
+			StalledSince: c.stalledSince,
+			Stalls:       c.stalls,
+			LastDump:     c.lastDump,
+		}
+		if c.err != nil {
+			st.Error = c.err.Error()
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// watch probes c every interval. A probe still running after a stall is
+// waited for, instead of piling up new ones.
+func (w *Watchdog) watch(ctx context.Context, c *check, fatal chan<- error) {
+	var pending chan error
+	var sent time.Time
+	for {
+		if pending == nil {
+			pending = make(chan error, 1)
+			sent = time.Now()
+			pctx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+			go func(res chan<- error) {
+				res <- c.Probe(pctx)
+				cancel()
+			}(pending)
+		}
+
+		timer := time.NewTimer(w.cfg.Timeout)
+		var err error
+		stalled := false
+		select {
+		case err = <-pending:
+			pending = nil
+			stalled = err == context.DeadlineExceeded
+		case <-timer.C:
+			stalled = true
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+
+		if ctx.Err() != nil {
+			return
+		}
+		if stalled {
+			if err := w.stalled(c, sent); err != nil {
+				fatal <- err
+				return
+			}
+		} else {
+			w.answered(c, err)
+		}
+
+		select {
+		case <-time.After(w.cfg.Interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// answered records the answer of a probe.
+func (w *Watchdog) answered(c *check, err error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	if !c.stalledSince.IsZero() {
+		log.Warningf("watchdog: %s recovered after %s", c.Name, time.Since(c.stalledSince))
+		c.stalledSince = time.Time{}
+	}
+	c.err = err
+	if err != nil {
+		log.Warningf("watchdog: probing %s: %s", c.Name, err)
+		return
+	}
+	c.lastOK = time.Now()
+}
+
+// stalled dumps the stacks when c stalls, and restarts it if asked to. A
+// stall is only handled once, until c answers again. It returns a
+// StalledError if c should be restarted but can't be.
+func (w *Watchdog) stalled(c *check, since time.Time) error {
+	w.lk.Lock()
+	if !c.stalledSince.IsZero() {
+		w.lk.Unlock()
+		return nil
+	}
+	c.stalledSince = since
+	c.stalls++
+	w.lk.Unlock()
+
+	log.Errorf("watchdog: %s didn't answer for %s", c.Name, time.Since(since))
+
+	dump, err := w.dump(c, since)
+	if err != nil {
+		log.Error("watchdog: dumping the stacks: ", err)
+	} else {
+		log.Errorf("watchdog: goroutine stacks dumped to %s", dump)
+		w.lk.Lock()
+		c.lastDump = dump
+		w.lk.Unlock()
+	}
+
+	if !w.cfg.Restart {
+		return nil
+	}
+	if c.Restart == nil {
+		return &StalledError{Name: c.Name}
+	}
+	log.Warningf("watchdog: restarting %s", c.Name)
+	if err := c.Restart(); err != nil {
+		log.Errorf("watchdog: restarting %s: %s", c.Name, err)
+	}
+	return nil
+}
+
+// dump writes the stacks of all the goroutines to a new file of the dump
+// directory, and returns its path.
+func (w *Watchdog) dump(c *check, since time.Time) (string, error) {
+	if err := os.MkdirAll(w.cfg.Dir, 0755); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s%s", now.UTC().Format("20060102T150405.000Z"), c.Name, dumpSuffix)
+	p := filepath.Join(w.cfg.Dir, name)
+	f, err := os.Create(p)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(f, "subsystem: %s\n", c.Name)
+	fmt.Fprintf(f, "probe sent: %s\n", since.Format(time.RFC3339Nano))
+	fmt.Fprintf(f, "stalled for: %s (timeout %s)\n", now.Sub(since), w.cfg.Timeout)
+	fmt.Fprintf(f, "goroutines: %d\n\n", runtime.NumGoroutine())
+	err = pprof.Lookup("goroutine").WriteTo(f, 2)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := pruneDumps(w.cfg.Dir, MaxDumps); err != nil {
+		log.Warning("watchdog: removing old dumps: ", err)
+	}
+	return p, nil
+}
+
+// pruneDumps removes the oldest dumps of dir beyond keep. The names of the
+// dumps start with their time, which sorts them.
+func pruneDumps(dir string, keep int) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var dumps []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), dumpSuffix) {
+			dumps = append(dumps, e.Name())
+		}
+	}
+	if len(dumps) <= keep {
+		return nil
+	}
+	sort.Strings(dumps)
+	for _, name := range dumps[:len(dumps)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
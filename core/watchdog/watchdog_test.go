@@ -0,0 +1,170 @@
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig(t *testing.T) (Config, func()) {
+	dir, err := ioutil.TempDir("", "watchdog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{
+		Interval: 10 * time.Millisecond,
+		Timeout:  50 * time.Millisecond,
+		Dir:      dir,
+	}
+	return cfg, func() { os.RemoveAll(dir) }
+}
+
+func TestStallDumpsStacks(t *testing.T) {
+	cfg, cleanup := testConfig(t)
+	defer cleanup()
+
+	unblock := make(chan struct{})
+	w := New(cfg, Check{
+		Name: "stuck",
+		Probe: func(ctx context.Context) error {
+			// ignores ctx, like a wedged event loop
+			<-unblock
+			return nil
+		},
+	}, Check{
+		Name:  "fine",
+		Probe: func(ctx context.Context) error { return nil },
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	var st []Status
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		st = w.Status()
+		if st[0].LastDump != "" {
+			break
+		}
+	}
+	if st[0].Healthy || st[0].Stalls != 1 || st[0].LastDump == "" {
+		t.Fatalf("stall not detected: %#v", st[0])
+	}
+	if !st[1].Healthy || st[1].LastOK.IsZero() {
+		t.Fatalf("healthy check reported wrong: %#v", st[1])
+	}
+
+	data, err := ioutil.ReadFile(st[0].LastDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "subsystem: stuck\n") {
+		t.Fatalf("dump has no header:\n%s", data)
+	}
+	if !strings.Contains(string(data), "TestStallDumpsStacks") {
+		t.Fatal("dump doesn't hold the stacks of the stuck probe")
+	}
+
+	// a stall is dumped once
+	time.Sleep(5 * cfg.Timeout)
+	dumps, err := filepath.Glob(filepath.Join(cfg.Dir, "*"+dumpSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) != 1 {
+		t.Fatalf("expected one dump, got %d", len(dumps))
+	}
+
+	close(unblock)
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if w.Status()[0].Healthy {
+			break
+		}
+	}
+	if st := w.Status()[0]; !st.Healthy || st.Stalls != 1 {
+		t.Fatalf("recovery not detected: %#v", st)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStallRestart(t *testing.T) {
+	cfg, cleanup := testConfig(t)
+	defer cleanup()
+	cfg.Restart = true
+
+	restarted := make(chan struct{}, 1)
+	w := New(cfg, Check{
+		Name:  "restartable",
+		Probe: func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() },
+		Restart: func() error {
+			select {
+			case restarted <- struct{}{}:
+			default:
+			}
+			return nil
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+	select {
+	case <-restarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled subsystem not restarted")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// without a restart hook, Run gives up
+	w = New(cfg, Check{
+		Name:  "wedged",
+		Probe: func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() },
+	})
+	err := w.Run(context.Background())
+	if serr, ok := err.(*StalledError); !ok || serr.Name != "wedged" {
+		t.Fatalf("expected the wedged subsystem to stall, got %v", err)
+	}
+}
+
+func TestPruneDumps(t *testing.T) {
+	cfg, cleanup := testConfig(t)
+	defer cleanup()
+
+	for i := 0; i < 5; i++ {
+		p := filepath.Join(cfg.Dir, fmt.Sprintf("2017010%d-x%s", i, dumpSuffix))
+		if err := ioutil.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := filepath.Join(cfg.Dir, "notes")
+	if err := ioutil.WriteFile(other, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pruneDumps(cfg.Dir, 2); err != nil {
+		t.Fatal(err)
+	}
+	dumps, err := filepath.Glob(filepath.Join(cfg.Dir, "*"+dumpSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) != 2 || filepath.Base(dumps[0]) != "20170103-x"+dumpSuffix {
+		t.Fatalf("wrong dumps kept: %v", dumps)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Fatal("other files should be kept")
+	}
+}
@@ -0,0 +1,34 @@
+package exchange
+
+import (
+	"context"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// AnnounceFilter decides which of the blocks stored locally are announced to
+// the routing system, when they're added and when they're reprovided.
+type AnnounceFilter interface {
+	// Announce tells whether c is announced.
+	Announce(ctx context.Context, c *cid.Cid) (bool, error)
+}
+
+// AnnounceFilterFunc is a function used as an AnnounceFilter.
+type AnnounceFilterFunc func(ctx context.Context, c *cid.Cid) (bool, error)
+
+func (f AnnounceFilterFunc) Announce(ctx context.Context, c *cid.Cid) (bool, error) {
+	return f(ctx, c)
+}
+
+// AllAnnounceFilters allows the blocks all of filters allow.
+func AllAnnounceFilters(filters ...AnnounceFilter) AnnounceFilter {
+	return AnnounceFilterFunc(func(ctx context.Context, c *cid.Cid) (bool, error) {
+		for _, f := range filters {
+			ok, err := f.Announce(ctx, c)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+}
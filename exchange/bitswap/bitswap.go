@@ -100,6 +100,7 @@ func New(parent context.Context, p peer.ID, network bsnet.BitSwapNetwork,
 		newBlocks:     make(chan *cid.Cid, HasBlockBufferSize),
 		provideKeys:   make(chan *cid.Cid, provideKeysBufferSize),
 		wm:            NewWantManager(ctx, network),
+		sessions:      make(map[uint64]*fetchSession),
 
 		dupMetric: dupHist,
 		allMetric: allHist,
@@ -150,8 +151,18 @@ type Bitswap struct {
 	// provideKeys directly feeds provide workers
 	provideKeys chan *cid.Cid
 
+	// announceFilter decides which new blocks are provided, all if nil
+	filterLk       sync.Mutex
+	announceFilter exchange.AnnounceFilter
+
 	process process.Process
 
+	// sessions are the fetch sessions with requests in flight, or not over
+	sessLk   sync.Mutex
+	sessions map[uint64]*fetchSession
+	// preferred tells the peers the new sessions favor, nil for none
+	preferred func(peer.ID) bool
+
 	// Counters for various statistics
 	counterLk      sync.Mutex
 	blocksRecvd    int
@@ -252,7 +263,16 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 		log.Event(ctx, "Bitswap.GetBlockRequest.Start", k)
 	}
 
-	bs.wm.WantBlocks(ctx, keys)
+	// the first blocks of a session are raced from all the peers, the next
+	// ones are asked to the fastest of them
+	sess, release := bs.sessionFor(ctx)
+	targets := sess.targets()
+	sess.want(keys)
+	if sess.hintsOnly {
+		bs.wm.WantBlocksOnly(ctx, keys, targets)
+	} else {
+		bs.wm.WantBlocks(ctx, keys, targets)
+	}
 
 	// NB: Optimization. Assumes that providers of key[0] are likely to
 	// be able to provide for all keys. This currently holds true in most
@@ -276,6 +296,20 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 			// can't just defer this call on its own, arguments are resolved *when* the defer is created
 			bs.CancelWants(remaining.Keys())
 		}()
+		defer release()
+		defer sess.forget(keys)
+
+		// ask all the peers when the fastest ones of the session don't
+		// send the blocks in time, unless it is restricted to its hinted
+		// peers
+		var timer *time.Timer
+		var widen <-chan time.Time
+		if targets != nil && !sess.hintsOnly {
+			timer = time.NewTimer(sessionTargetDelay.Get())
+			defer timer.Stop()
+			widen = timer.C
+		}
+
 		for {
 			select {
 			case blk, ok := <-promise:
@@ -284,17 +318,36 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 				}
 
 				remaining.Remove(blk.Cid())
+				if widen != nil {
+					if !timer.Stop() {
+						<-timer.C
+					}
+					timer.Reset(sessionTargetDelay.Get())
+				}
+
 				select {
 				case out <- blk:
 				case <-ctx.Done():
 					return
 				}
+			case <-widen:
+				widen = nil
+				bs.broadcastWants(ctx, sess, remaining.Keys())
+			case <-sess.lost:
+				// the peers the session is restricted to are gone
+				return
 			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 
+	// the fastest peers of the session, or the peers having the blocks, are
+	// known already, there is no need to look for providers
+	if targets != nil {
+		return out, nil
+	}
+
 	select {
 	case bs.findKeys <- req:
 		return out, nil
@@ -341,6 +394,31 @@ func (bs *Bitswap) HasBlock(blk blocks.Block) error {
 	return nil
 }
 
+// SetAnnounceFilter sets the filter deciding which of the blocks added to
+// bitswap are provided to the network. Blocks it skips are still served.
+func (bs *Bitswap) SetAnnounceFilter(f exchange.AnnounceFilter) {
+	bs.filterLk.Lock()
+	defer bs.filterLk.Unlock()
+	bs.announceFilter = f
+}
+
+// shouldProvide tells whether the announce filter allows providing c.
+func (bs *Bitswap) shouldProvide(ctx context.Context, c *cid.Cid) bool {
+	bs.filterLk.Lock()
+	f := bs.announceFilter
+	bs.filterLk.Unlock()
+	if f == nil {
+		return true
+	}
+
+	ok, err := f.Announce(ctx, c)
+	if err != nil {
+		log.Warningf("announce filter failed on %s: %s", c, err)
+		return false
+	}
+	return ok
+}
+
 func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg.BitSwapMessage) {
 	// This call records changes to wantlists, blocks received,
 	// and number of bytes transfered.
@@ -354,6 +432,8 @@ func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg
 		return
 	}
 
+	bs.sessionsReceive(p, iblocks)
+
 	// quickly send out cancels, reduces chances of duplicate block receives
 	var keys []*cid.Cid
 	for _, block := range iblocks {
@@ -421,6 +501,7 @@ func (bs *Bitswap) PeerConnected(p peer.ID) {
 func (bs *Bitswap) PeerDisconnected(p peer.ID) {
 	bs.wm.Disconnected(p)
 	bs.engine.PeerDisconnected(p)
+	bs.sessionsRemovePeer(p)
 }
 
 func (bs *Bitswap) ReceiveError(err error) {
@@ -444,3 +525,34 @@ func (bs *Bitswap) GetWantlist() []*cid.Cid {
 func (bs *Bitswap) IsOnline() bool {
 	return true
 }
+
+// Alive returns once the want manager and the decision engine answered, or
+// with the error of ctx when they don't in time. It lets the daemon detect a
+// wedged bitswap.
+func (bs *Bitswap) Alive(ctx context.Context) error {
+	// buffered, the want manager doesn't block on the answer once ctx is
+	// done
+	resp := make(chan []peer.ID, 1)
+	select {
+	case bs.wm.peerReqs <- resp:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-resp:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		bs.engine.Peers()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -652,3 +652,29 @@ func TestBitswapLedgerTwoWay(t *testing.T) {
 		}
 	}
 }
+
+func TestAlive(t *testing.T) {
+	vnet := getVirtualNetwork()
+	sesgen := NewTestSessionGenerator(vnet)
+	defer sesgen.Close()
+
+	bitswap := sesgen.Next()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := bitswap.Exchange.Alive(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a closed bitswap doesn't answer
+	bitswap.Exchange.Close()
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		err = bitswap.Exchange.Alive(ctx)
+		cancel()
+	}
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected %s, got %v", context.DeadlineExceeded, err)
+	}
+}
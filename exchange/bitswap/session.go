@@ -0,0 +1,408 @@
+package bitswap
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	"github.com/ipfs/go-ipfs/thirdparty/delay"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+const (
+	// sessionRaceBlocks is how many blocks a session receives from all the
+	// peers having them before it asks only the fastest ones.
+	sessionRaceBlocks = 4
+	// sessionMaxPeers is how many of its fastest peers a session asks for
+	// blocks once the race is over.
+	sessionMaxPeers = 3
+	// sessionExploreEvery is how often a session asks all the peers again,
+	// in requests, to find faster ones.
+	sessionExploreEvery = 16
+	// throughputAlpha is the weight of the latest block in the moving
+	// averages of the throughput and latency of peers.
+	throughputAlpha = 0.3
+	// preferredPeerBonus multiplies the throughput of the preferred peers
+	// when a session picks its fastest peers.
+	preferredPeerBonus = 1.5
+)
+
+// sessionTargetDelay is how long a session waits for blocks from its fastest
+// peers before asking all the peers for them.
+var sessionTargetDelay = delay.Fixed(time.Second)
+
+// SessionStat is what a fetch session learnt of the peers it received
+// blocks from.
+type SessionStat struct {
+	ID              uint64
+	BlocksReceived  int
+	DupBlksReceived int
+	DataReceived    uint64
+	Peers           []SessionPeerStat
+}
+
+// SessionPeerStat is what a fetch session learnt of one of its peers.
+// Throughput is in bytes per second.
+type SessionPeerStat struct {
+	Peer           string
+	BlocksReceived int
+	DataReceived   uint64
+	Throughput     uint64
+	Latency        time.Duration
+}
+
+// fetchSession tracks how fast the peers answer the requests of a session,
+// to shift them toward the fastest peers. Its first blocks are raced from
+// all the peers.
+type fetchSession struct {
+	id uint64
+
+	// observer is told of the blocks of the session, and preferred tells
+	// the peers favored over equally fast ones. Both may be nil.
+	observer  exchange.BlockObserver
+	preferred func(peer.ID) bool
+
+	// hints are the peers asked first, or only them with hintsOnly. The
+	// other peers are asked once the session widened, when the hinted
+	// peers didn't send blocks in time.
+	hints     []peer.ID
+	hintsOnly bool
+	widened   bool
+
+	// live are the hinted peers still connected, when the session is
+	// restricted to them, and lost is closed once none of them is.
+	live map[peer.ID]bool
+	lost chan struct{}
+
+	lk       sync.Mutex
+	wants    map[string]*sessionWant
+	peers    map[peer.ID]*sessionPeer
+	requests int
+	raceLeft int
+
+	blocksRecvd    int
+	dupBlocksRecvd int
+	dataRecvd      uint64
+}
+
+type sessionWant struct {
+	sent     time.Time
+	received bool
+}
+
+type sessionPeer struct {
+	blocks     int
+	data       uint64
+	throughput float64
+	latency    time.Duration
+}
+
+func newFetchSession(id uint64) *fetchSession {
+	return &fetchSession{
+		id:       id,
+		wants:    make(map[string]*sessionWant),
+		peers:    make(map[peer.ID]*sessionPeer),
+		raceLeft: sessionRaceBlocks,
+	}
+}
+
+// targets returns the peers to ask for the blocks of the next request, nil
+// for all the peers.
+func (s *fetchSession) targets() []peer.ID {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.requests++
+	if len(s.hints) > 0 && (s.hintsOnly || !s.widened) {
+		return append([]peer.ID(nil), s.hints...)
+	}
+	if s.raceLeft > 0 || len(s.peers) == 0 || s.requests%sessionExploreEvery == 0 {
+		return nil
+	}
+
+	ps := make(peersByThroughput, 0, len(s.peers))
+	for p, sp := range s.peers {
+		throughput := sp.throughput
+		if s.isPreferred(p) {
+			throughput *= preferredPeerBonus
+		}
+		ps = append(ps, peerThroughput{p, throughput})
+	}
+	sort.Sort(ps)
+	if len(ps) > sessionMaxPeers {
+		ps = ps[:sessionMaxPeers]
+	}
+
+	targets := make([]peer.ID, 0, len(ps))
+	for _, p := range ps {
+		targets = append(targets, p.id)
+	}
+	return targets
+}
+
+// isPreferred tells whether p is favored over equally fast peers: the
+// preferred and the hinted peers are. It is called with the lock held.
+func (s *fetchSession) isPreferred(p peer.ID) bool {
+	if s.preferred != nil && s.preferred(p) {
+		return true
+	}
+	for _, h := range s.hints {
+		if h == p {
+			return true
+		}
+	}
+	return false
+}
+
+type peerThroughput struct {
+	id         peer.ID
+	throughput float64
+}
+
+// peersByThroughput sorts the fastest peers first.
+type peersByThroughput []peerThroughput
+
+func (s peersByThroughput) Len() int           { return len(s) }
+func (s peersByThroughput) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s peersByThroughput) Less(i, j int) bool { return s[i].throughput > s[j].throughput }
+
+// want records that the session requested ks.
+func (s *fetchSession) want(ks []*cid.Cid) {
+	now := time.Now()
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	for _, k := range ks {
+		if _, ok := s.wants[k.KeyString()]; !ok {
+			s.wants[k.KeyString()] = &sessionWant{sent: now}
+		}
+	}
+}
+
+// forget forgets the requests of ks, once the session doesn't wait for them
+// anymore.
+func (s *fetchSession) forget(ks []*cid.Cid) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	for _, k := range ks {
+		delete(s.wants, k.KeyString())
+	}
+}
+
+// receive credits p with blk, if the session requested it.
+func (s *fetchSession) receive(p peer.ID, blk blocks.Block) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	w, ok := s.wants[blk.Cid().KeyString()]
+	if !ok {
+		return
+	}
+	size := len(blk.RawData())
+	if w.received {
+		s.dupBlocksRecvd++
+		return
+	}
+	w.received = true
+	s.blocksRecvd++
+	s.dataRecvd += uint64(size)
+	if s.raceLeft > 0 {
+		s.raceLeft--
+	}
+
+	latency := time.Since(w.sent)
+	if latency <= 0 {
+		latency = time.Microsecond
+	}
+	throughput := float64(size) / latency.Seconds()
+
+	sp, ok := s.peers[p]
+	if !ok {
+		sp = &sessionPeer{throughput: throughput, latency: latency}
+		s.peers[p] = sp
+	} else {
+		sp.throughput = throughputAlpha*throughput + (1-throughputAlpha)*sp.throughput
+		sp.latency = time.Duration(throughputAlpha*float64(latency) + (1-throughputAlpha)*float64(sp.latency))
+	}
+	sp.blocks++
+	sp.data += uint64(size)
+
+	if s.observer != nil {
+		s.observer(p, size)
+	}
+}
+
+// fallBack makes the session race its next blocks from all the peers again,
+// when its fastest ones, or the hinted ones, didn't answer.
+func (s *fetchSession) fallBack() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.raceLeft = sessionRaceBlocks
+	s.widened = true
+}
+
+// removePeer forgets p, e.g. once disconnected.
+func (s *fetchSession) removePeer(p peer.ID) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	delete(s.peers, p)
+
+	if s.live[p] {
+		delete(s.live, p)
+		if len(s.live) == 0 {
+			close(s.lost)
+		}
+	}
+}
+
+// watchHints makes the session restricted to its hinted peers end once none
+// of them is connected anymore, as they can't send the blocks then. connected
+// are the peers connected when the session starts.
+func (s *fetchSession) watchHints(connected []peer.ID) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.live = make(map[peer.ID]bool)
+	s.lost = make(chan struct{})
+	for _, p := range connected {
+		for _, h := range s.hints {
+			if h == p {
+				s.live[p] = true
+			}
+		}
+	}
+	if len(s.live) == 0 {
+		close(s.lost)
+	}
+}
+
+func (s *fetchSession) stat() SessionStat {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	st := SessionStat{
+		ID:              s.id,
+		BlocksReceived:  s.blocksRecvd,
+		DupBlksReceived: s.dupBlocksRecvd,
+		DataReceived:    s.dataRecvd,
+	}
+	for p, sp := range s.peers {
+		st.Peers = append(st.Peers, SessionPeerStat{
+			Peer:           p.Pretty(),
+			BlocksReceived: sp.blocks,
+			DataReceived:   sp.data,
+			Throughput:     uint64(sp.throughput),
+			Latency:        sp.latency,
+		})
+	}
+	sort.Sort(sessionPeersByThroughput(st.Peers))
+	return st
+}
+
+type sessionPeersByThroughput []SessionPeerStat
+
+func (s sessionPeersByThroughput) Len() int           { return len(s) }
+func (s sessionPeersByThroughput) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sessionPeersByThroughput) Less(i, j int) bool { return s[i].Throughput > s[j].Throughput }
+
+// sessionFor returns the session of the requests made with ctx, and the
+// function to call once a request is over. Requests made without a session
+// get one of their own.
+func (bs *Bitswap) sessionFor(ctx context.Context) (*fetchSession, func()) {
+	es := exchange.SessionFromContext(ctx)
+
+	bs.sessLk.Lock()
+	defer bs.sessLk.Unlock()
+
+	if es == nil {
+		s := bs.newFetchSession(ctx, exchange.NewSessionID())
+		bs.sessions[s.id] = s
+		return s, func() { bs.removeSession(s.id) }
+	}
+
+	s, ok := bs.sessions[es.ID]
+	if !ok {
+		s = bs.newFetchSession(ctx, es.ID)
+		bs.sessions[s.id] = s
+		go func() {
+			select {
+			case <-es.Done():
+			case <-bs.process.Closing():
+			}
+			bs.removeSession(s.id)
+		}()
+	}
+	return s, func() {}
+}
+
+// newFetchSession returns a session observed as ctx asks, asking first the
+// peers ctx hints, and preferring the peers bitswap prefers. It is called
+// with sessLk held.
+func (bs *Bitswap) newFetchSession(ctx context.Context, id uint64) *fetchSession {
+	s := newFetchSession(id)
+	s.observer = exchange.BlockObserverFromContext(ctx)
+	s.preferred = bs.preferred
+	if h := exchange.PeerHintsFromContext(ctx); h != nil {
+		s.hints = h.Peers
+		s.hintsOnly = h.Only
+		if h.Only {
+			s.watchHints(bs.wm.ConnectedPeers())
+		}
+	}
+	return s
+}
+
+// SetPreferredPeers sets the function telling the peers the sessions favor
+// over equally fast ones, e.g. the peers which served the node's pins
+// before. It applies to the sessions started afterwards.
+func (bs *Bitswap) SetPreferredPeers(preferred func(peer.ID) bool) {
+	bs.sessLk.Lock()
+	defer bs.sessLk.Unlock()
+	bs.preferred = preferred
+}
+
+func (bs *Bitswap) removeSession(id uint64) {
+	bs.sessLk.Lock()
+	defer bs.sessLk.Unlock()
+	delete(bs.sessions, id)
+}
+
+// sessionsReceive credits p with the blocks it sent to the sessions which
+// requested them.
+func (bs *Bitswap) sessionsReceive(p peer.ID, blks []blocks.Block) {
+	bs.sessLk.Lock()
+	defer bs.sessLk.Unlock()
+	for _, s := range bs.sessions {
+		for _, b := range blks {
+			s.receive(p, b)
+		}
+	}
+}
+
+func (bs *Bitswap) sessionsRemovePeer(p peer.ID) {
+	bs.sessLk.Lock()
+	defer bs.sessLk.Unlock()
+	for _, s := range bs.sessions {
+		s.removePeer(p)
+	}
+}
+
+// broadcastWants asks all the peers for the keys of ks the fastest peers of
+// s didn't send in time, and looks for more providers.
+func (bs *Bitswap) broadcastWants(ctx context.Context, s *fetchSession, ks []*cid.Cid) {
+	if len(ks) == 0 {
+		return
+	}
+	s.fallBack()
+	bs.wm.BroadcastWants(ctx, ks)
+
+	select {
+	case bs.findKeys <- &blockRequest{Cid: ks[0], Ctx: ctx}:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,282 @@
+package bitswap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	blocksutil "github.com/ipfs/go-ipfs/blocks/blocksutil"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestSessionTargetsFastestPeers(t *testing.T) {
+	s := newFetchSession(1)
+	bgen := blocksutil.NewBlockGenerator()
+	fast, slow := peer.ID("fast"), peer.ID("slow")
+
+	// receive blk from p, requested the given time ago
+	receive := func(p peer.ID, blk blocks.Block, ago time.Duration) {
+		s.want([]*cid.Cid{blk.Cid()})
+		s.wants[blk.Cid().KeyString()].sent = time.Now().Add(-ago)
+		s.receive(p, blk)
+	}
+
+	for i := 0; i < sessionRaceBlocks; i++ {
+		if targets := s.targets(); targets != nil {
+			t.Fatalf("expected the first blocks to be raced, got targets %v", targets)
+		}
+
+		// the slow peer's copies are duplicates
+		blk := bgen.Next()
+		receive(fast, blk, time.Millisecond)
+		receive(slow, blk, time.Second)
+	}
+
+	targets := s.targets()
+	if len(targets) != 1 || targets[0] != fast {
+		t.Fatalf("expected to target the fastest peer, got %v", targets)
+	}
+
+	// the slow peer alone sends the next block, and stays the slowest
+	receive(slow, bgen.Next(), 100*time.Millisecond)
+	targets = s.targets()
+	if len(targets) != 2 || targets[0] != fast || targets[1] != slow {
+		t.Fatalf("expected to target both peers, fastest first, got %v", targets)
+	}
+
+	st := s.stat()
+	if st.BlocksReceived != sessionRaceBlocks+1 || st.DupBlksReceived != sessionRaceBlocks {
+		t.Fatalf("unexpected session stat %+v", st)
+	}
+	if len(st.Peers) != 2 || st.Peers[0].Peer != fast.Pretty() || st.Peers[0].BlocksReceived != sessionRaceBlocks {
+		t.Fatalf("unexpected session peers %+v", st.Peers)
+	}
+
+	s.fallBack()
+	if targets := s.targets(); targets != nil {
+		t.Fatalf("expected to race blocks again after falling back, got targets %v", targets)
+	}
+
+	s.removePeer(fast)
+	s.removePeer(slow)
+	if st := s.stat(); len(st.Peers) != 0 {
+		t.Fatalf("expected no peers left, got %+v", st.Peers)
+	}
+}
+
+func TestSessionDupBlocks(t *testing.T) {
+	s := newFetchSession(1)
+	blk := blocks.NewBlock([]byte("dup"))
+
+	s.want([]*cid.Cid{blk.Cid()})
+	s.receive(peer.ID("a"), blk)
+	s.receive(peer.ID("b"), blk)
+	s.receive(peer.ID("c"), blocks.NewBlock([]byte("not wanted")))
+
+	st := s.stat()
+	if st.BlocksReceived != 1 || st.DupBlksReceived != 1 || len(st.Peers) != 1 {
+		t.Fatalf("unexpected session stat %+v", st)
+	}
+
+	s.forget([]*cid.Cid{blk.Cid()})
+	s.receive(peer.ID("b"), blk)
+	if st := s.stat(); st.DupBlksReceived != 1 {
+		t.Fatalf("expected blocks received once forgotten not to count, got %+v", st)
+	}
+}
+
+func TestSessionPreferredPeers(t *testing.T) {
+	s := newFetchSession(1)
+	bgen := blocksutil.NewBlockGenerator()
+	fast, kept := peer.ID("fast"), peer.ID("kept")
+	s.preferred = func(p peer.ID) bool { return p == kept }
+
+	observed := make(map[peer.ID]int)
+	s.observer = func(p peer.ID, size int) { observed[p] += size }
+
+	receive := func(p peer.ID, blk blocks.Block, ago time.Duration) {
+		s.want([]*cid.Cid{blk.Cid()})
+		s.wants[blk.Cid().KeyString()].sent = time.Now().Add(-ago)
+		s.receive(p, blk)
+	}
+
+	// the preferred peer is a little slower, and still picked first
+	sizes := make(map[peer.ID]int)
+	var last blocks.Block
+	for i := 0; i < sessionRaceBlocks; i++ {
+		s.targets()
+		for p, ago := range map[peer.ID]time.Duration{fast: 10 * time.Millisecond, kept: 12 * time.Millisecond} {
+			last = bgen.Next()
+			receive(p, last, ago)
+			sizes[p] += len(last.RawData())
+		}
+	}
+	targets := s.targets()
+	if len(targets) != 2 || targets[0] != kept || targets[1] != fast {
+		t.Fatalf("expected the preferred peer first, got %v", targets)
+	}
+
+	// duplicates and blocks not wanted are not observed
+	s.receive(fast, last)
+	s.receive(kept, last)
+	s.receive(fast, bgen.Next())
+	if observed[fast] != sizes[fast] || observed[kept] != sizes[kept] {
+		t.Fatalf("expected observed sizes %v, got %v", sizes, observed)
+	}
+}
+
+func TestSessionPeerHints(t *testing.T) {
+	bgen := blocksutil.NewBlockGenerator()
+	hinted, other := peer.ID("hinted"), peer.ID("other")
+
+	receive := func(s *fetchSession, p peer.ID, ago time.Duration) {
+		blk := bgen.Next()
+		s.want([]*cid.Cid{blk.Cid()})
+		s.wants[blk.Cid().KeyString()].sent = time.Now().Add(-ago)
+		s.receive(p, blk)
+	}
+
+	// the hinted peers are asked first, then all the peers once they
+	// didn't send the blocks in time
+	s := newFetchSession(1)
+	s.hints = []peer.ID{hinted}
+	for i := 0; i < 2*sessionExploreEvery; i++ {
+		if targets := s.targets(); len(targets) != 1 || targets[0] != hinted {
+			t.Fatalf("expected the hinted peer only, got %v", targets)
+		}
+	}
+	s.fallBack()
+	if targets := s.targets(); targets != nil {
+		t.Fatalf("expected all the peers once widened, got %v", targets)
+	}
+
+	// the hinted peers are then favored as the preferred ones
+	for i := 0; i < sessionRaceBlocks; i++ {
+		receive(s, other, 10*time.Millisecond)
+		receive(s, hinted, 12*time.Millisecond)
+	}
+	targets := s.targets()
+	if len(targets) != 2 || targets[0] != hinted || targets[1] != other {
+		t.Fatalf("expected the hinted peer first, got %v", targets)
+	}
+
+	// restricted sessions never ask the other peers
+	s = newFetchSession(2)
+	s.hints = []peer.ID{hinted}
+	s.hintsOnly = true
+	s.fallBack()
+	for i := 0; i < sessionRaceBlocks; i++ {
+		receive(s, other, 10*time.Millisecond)
+	}
+	for i := 0; i < 2*sessionExploreEvery; i++ {
+		if targets := s.targets(); len(targets) != 1 || targets[0] != hinted {
+			t.Fatalf("expected the hinted peer only, got %v", targets)
+		}
+	}
+
+	// and end once the hinted peers are gone
+	s.watchHints([]peer.ID{hinted, other})
+	s.removePeer(other)
+	select {
+	case <-s.lost:
+		t.Fatal("expected the session to go on while the hinted peer is connected")
+	default:
+	}
+	s.removePeer(hinted)
+	select {
+	case <-s.lost:
+	default:
+		t.Fatal("expected the session to end once the hinted peer is gone")
+	}
+}
+
+func TestWantScopes(t *testing.T) {
+	wm := NewWantManager(context.Background(), nil)
+	defer wm.cancel()
+	bgen := blocksutil.NewBlockGenerator()
+	hinted, other := peer.ID("hinted"), peer.ID("other")
+
+	// an entry wanted from some peers only is sent to them only
+	c := bgen.Next().Cid()
+	wm.scope(c, []peer.ID{hinted}, true)
+	if !wm.inScope(c, hinted) || wm.inScope(c, other) || !wm.scoped(c) {
+		t.Fatal("expected the entry to be sent to the hinted peer only")
+	}
+
+	// until it is wanted from any peer
+	wm.unscope(c)
+	if !wm.inScope(c, other) || wm.scoped(c) {
+		t.Fatal("expected the entry to be sent to all the peers")
+	}
+
+	// an entry wanted from any peer first stays unrestricted
+	wm.scope(c, []peer.ID{hinted}, false)
+	if !wm.inScope(c, other) {
+		t.Fatal("expected the entry to stay unrestricted")
+	}
+}
+
+func TestSessionStat(t *testing.T) {
+	net := getVirtualNetwork()
+	g := NewTestSessionGenerator(net)
+	defer g.Close()
+	bgen := blocksutil.NewBlockGenerator()
+
+	instances := g.Instances(3)
+	fetcher := instances[2]
+	var blks []blocks.Block
+	for i := 0; i < 2*sessionRaceBlocks; i++ {
+		blk := bgen.Next()
+		for _, inst := range instances[:2] {
+			if err := inst.Exchange.HasBlock(blk); err != nil {
+				t.Fatal(err)
+			}
+		}
+		blks = append(blks, blk)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	sctx := exchange.WithSession(ctx)
+	for _, blk := range blks {
+		if _, err := fetcher.Exchange.GetBlock(sctx, blk.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := fetcher.Exchange.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := exchange.SessionFromContext(sctx).ID
+	var sst *SessionStat
+	for i := range st.Sessions {
+		if st.Sessions[i].ID == id {
+			sst = &st.Sessions[i]
+		}
+	}
+	if sst == nil {
+		t.Fatalf("session %d not in stat %+v", id, st.Sessions)
+	}
+	if sst.BlocksReceived != len(blks) || len(sst.Peers) == 0 {
+		t.Fatalf("unexpected session stat %+v", sst)
+	}
+
+	cancel()
+	for i := 0; ; i++ {
+		st, err := fetcher.Exchange.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(st.Sessions) == 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("expected the session to end with its context, got %+v", st.Sessions)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
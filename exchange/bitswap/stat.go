@@ -16,6 +16,7 @@ type Stat struct {
 	DataSent        uint64
 	DupBlksReceived int
 	DupDataReceived uint64
+	Sessions        []SessionStat
 }
 
 func (bs *Bitswap) Stat() (*Stat, error) {
@@ -36,5 +37,18 @@ func (bs *Bitswap) Stat() (*Stat, error) {
 	}
 	sort.Strings(st.Peers)
 
+	bs.sessLk.Lock()
+	for _, s := range bs.sessions {
+		st.Sessions = append(st.Sessions, s.stat())
+	}
+	bs.sessLk.Unlock()
+	sort.Sort(sessionsByID(st.Sessions))
+
 	return st, nil
 }
+
+type sessionsByID []SessionStat
+
+func (s sessionsByID) Len() int           { return len(s) }
+func (s sessionsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sessionsByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
@@ -17,7 +17,7 @@ import (
 
 type WantManager struct {
 	// sync channels for Run loop
-	incoming   chan []*bsmsg.Entry
+	incoming   chan *wantSet
 	connect    chan peer.ID        // notification channel for new peers connecting
 	disconnect chan peer.ID        // notification channel for peers disconnecting
 	peerReqs   chan chan []peer.ID // channel to request connected peers on
@@ -26,6 +26,11 @@ type WantManager struct {
 	peers map[peer.ID]*msgQueue
 	wl    *wantlist.ThreadSafe
 
+	// scopes are the peers the entries wanted with WantBlocksOnly are
+	// restricted to, by key, also when the wantlist is sent again.
+	scopeLk sync.Mutex
+	scopes  map[string][]peer.ID
+
 	network bsnet.BitSwapNetwork
 	ctx     context.Context
 	cancel  func()
@@ -41,12 +46,13 @@ func NewWantManager(ctx context.Context, network bsnet.BitSwapNetwork) *WantMana
 	sentHistogram := metrics.NewCtx(ctx, "sent_all_blocks_bytes", "Histogram of blocks sent by"+
 		" this bitswap").Histogram(metricsBuckets)
 	return &WantManager{
-		incoming:      make(chan []*bsmsg.Entry, 10),
+		incoming:      make(chan *wantSet, 10),
 		connect:       make(chan peer.ID, 10),
 		disconnect:    make(chan peer.ID, 10),
 		peerReqs:      make(chan chan []peer.ID),
 		peers:         make(map[peer.ID]*msgQueue),
 		wl:            wantlist.NewThreadSafe(),
+		scopes:        make(map[string][]peer.ID),
 		network:       network,
 		ctx:           ctx,
 		cancel:        cancel,
@@ -55,6 +61,21 @@ func NewWantManager(ctx context.Context, network bsnet.BitSwapNetwork) *WantMana
 	}
 }
 
+// wantSet is a change to the wantlist, sent to the given peers, or to all the
+// peers when none are given.
+type wantSet struct {
+	entries []*bsmsg.Entry
+	targets []peer.ID
+
+	// resend sends the entries still in the wantlist again, leaving it
+	// unchanged.
+	resend bool
+
+	// only restricts the entries to targets, which are then the only peers
+	// they are sent to when the wantlist is sent again.
+	only bool
+}
+
 type msgPair struct {
 	to  peer.ID
 	msg bsmsg.BitSwapMessage
@@ -80,17 +101,33 @@ type msgQueue struct {
 	done chan struct{}
 }
 
-func (pm *WantManager) WantBlocks(ctx context.Context, ks []*cid.Cid) {
+// WantBlocks adds ks to the wantlist, and asks targets for them, or all the
+// peers when targets is empty.
+func (pm *WantManager) WantBlocks(ctx context.Context, ks []*cid.Cid, targets []peer.ID) {
 	log.Infof("want blocks: %s", ks)
-	pm.addEntries(ctx, ks, false)
+	pm.addEntries(ctx, ks, targets, false, false, false)
+}
+
+// WantBlocksOnly adds ks to the wantlist, and asks targets only for them:
+// the other peers aren't sent them, even when the whole wantlist is.
+func (pm *WantManager) WantBlocksOnly(ctx context.Context, ks []*cid.Cid, targets []peer.ID) {
+	log.Infof("want blocks from %s: %s", targets, ks)
+	pm.addEntries(ctx, ks, targets, false, false, true)
+}
+
+// BroadcastWants asks all the peers for the keys of ks still in the
+// wantlist, e.g. when the peers they were first asked to didn't answer.
+func (pm *WantManager) BroadcastWants(ctx context.Context, ks []*cid.Cid) {
+	log.Infof("broadcast wants: %s", ks)
+	pm.addEntries(ctx, ks, nil, false, true, false)
 }
 
 func (pm *WantManager) CancelWants(ks []*cid.Cid) {
 	log.Infof("cancel wants: %s", ks)
-	pm.addEntries(context.TODO(), ks, true)
+	pm.addEntries(context.TODO(), ks, nil, true, false, false)
 }
 
-func (pm *WantManager) addEntries(ctx context.Context, ks []*cid.Cid, cancel bool) {
+func (pm *WantManager) addEntries(ctx context.Context, ks []*cid.Cid, targets []peer.ID, cancel, resend, only bool) {
 	var entries []*bsmsg.Entry
 	for i, k := range ks {
 		entries = append(entries, &bsmsg.Entry{
@@ -103,7 +140,7 @@ func (pm *WantManager) addEntries(ctx context.Context, ks []*cid.Cid, cancel boo
 		})
 	}
 	select {
-	case pm.incoming <- entries:
+	case pm.incoming <- &wantSet{entries: entries, targets: targets, resend: resend, only: only}:
 	case <-pm.ctx.Done():
 	case <-ctx.Done():
 	}
@@ -143,7 +180,9 @@ func (pm *WantManager) startPeerHandler(p peer.ID) *msgQueue {
 	// new peer, we will want to give them our full wantlist
 	fullwantlist := bsmsg.New(true)
 	for _, e := range pm.wl.Entries() {
-		fullwantlist.AddEntry(e.Cid, e.Priority)
+		if pm.inScope(e.Cid, p) {
+			fullwantlist.AddEntry(e.Cid, e.Priority)
+		}
 	}
 	mq.out = fullwantlist
 	mq.work <- struct{}{}
@@ -288,37 +327,61 @@ func (pm *WantManager) Run() {
 	defer tock.Stop()
 	for {
 		select {
-		case entries := <-pm.incoming:
+		case ws := <-pm.incoming:
 
 			// add changes to our wantlist
 			var filtered []*bsmsg.Entry
-			for _, e := range entries {
-				if e.Cancel {
+			for _, e := range ws.entries {
+				switch {
+				case ws.resend:
+					if _, ok := pm.wl.Contains(e.Cid); ok {
+						filtered = append(filtered, e)
+					}
+				case e.Cancel:
 					if pm.wl.Remove(e.Cid) {
 						pm.wantlistGauge.Dec()
+						pm.unscope(e.Cid)
 						filtered = append(filtered, e)
 					}
-				} else {
-					if pm.wl.AddEntry(e.Entry) {
+				default:
+					added := pm.wl.AddEntry(e.Entry)
+					if added {
 						pm.wantlistGauge.Inc()
 						filtered = append(filtered, e)
 					}
+					if ws.only {
+						pm.scope(e.Cid, ws.targets, added)
+					} else {
+						pm.unscope(e.Cid)
+					}
 				}
 			}
 
-			// broadcast those wantlist changes
-			for _, p := range pm.peers {
-				p.addMessage(filtered)
+			// broadcast those wantlist changes, or send them to the
+			// targets which are connected
+			if len(ws.targets) == 0 {
+				for _, p := range pm.peers {
+					p.addMessage(filtered)
+				}
+			} else {
+				for _, t := range ws.targets {
+					if p, ok := pm.peers[t]; ok {
+						p.addMessage(filtered)
+					}
+				}
 			}
 
 		case <-tock.C:
 			// resend entire wantlist every so often (REALLY SHOULDNT BE NECESSARY)
-			var es []*bsmsg.Entry
-			for _, e := range pm.wl.Entries() {
-				es = append(es, &bsmsg.Entry{Entry: e})
-			}
-
+			entries := pm.wl.Entries()
 			for _, p := range pm.peers {
+				var es []*bsmsg.Entry
+				for _, e := range entries {
+					if pm.inScope(e.Cid, p.p) {
+						es = append(es, &bsmsg.Entry{Entry: e})
+					}
+				}
+
 				p.outlk.Lock()
 				p.out = bsmsg.New(true)
 				p.outlk.Unlock()
@@ -341,6 +404,56 @@ func (pm *WantManager) Run() {
 	}
 }
 
+// scope restricts the wantlist entry of c to targets, once wanted from them
+// only. An entry added by an earlier want from any peer stays unrestricted,
+// and the targets of all the wants of a restricted one are kept. It is
+// called from the Run loop.
+func (pm *WantManager) scope(c *cid.Cid, targets []peer.ID, added bool) {
+	pm.scopeLk.Lock()
+	defer pm.scopeLk.Unlock()
+
+	k := c.KeyString()
+	if added {
+		pm.scopes[k] = append([]peer.ID(nil), targets...)
+	} else if scope, ok := pm.scopes[k]; ok {
+		pm.scopes[k] = append(scope, targets...)
+	}
+}
+
+// unscope lets the wantlist entry of c be sent to all the peers, once wanted
+// from any peer, or removed. It is called from the Run loop.
+func (pm *WantManager) unscope(c *cid.Cid) {
+	pm.scopeLk.Lock()
+	defer pm.scopeLk.Unlock()
+	delete(pm.scopes, c.KeyString())
+}
+
+// inScope tells whether the wantlist entry of c may be sent to p.
+func (pm *WantManager) inScope(c *cid.Cid, p peer.ID) bool {
+	pm.scopeLk.Lock()
+	defer pm.scopeLk.Unlock()
+
+	scope, ok := pm.scopes[c.KeyString()]
+	if !ok {
+		return true
+	}
+	for _, s := range scope {
+		if s == p {
+			return true
+		}
+	}
+	return false
+}
+
+// scoped tells whether the wantlist entry of c may only be sent to some
+// peers, which are known to have it: there is no need to look for providers.
+func (pm *WantManager) scoped(c *cid.Cid) bool {
+	pm.scopeLk.Lock()
+	defer pm.scopeLk.Unlock()
+	_, ok := pm.scopes[c.KeyString()]
+	return ok
+}
+
 func (wm *WantManager) newMsgQueue(p peer.ID) *msgQueue {
 	return &msgQueue{
 		done:    make(chan struct{}),
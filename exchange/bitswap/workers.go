@@ -7,6 +7,7 @@ import (
 	"time"
 
 	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
+	wantlist "github.com/ipfs/go-ipfs/exchange/bitswap/wantlist"
 
 	process "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
 	procctx "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess/context"
@@ -99,6 +100,10 @@ func (bs *Bitswap) provideWorker(px process.Process) {
 		ctx := procctx.OnClosingContext(px) // derive ctx from px
 		defer log.EventBegin(ctx, "Bitswap.ProvideWorker.Work", ev, k).Done()
 
+		if !bs.shouldProvide(ctx, k) {
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(ctx, provideTimeout) // timeout ctx
 		defer cancel()
 
@@ -184,11 +189,17 @@ func (bs *Bitswap) rebroadcastWorker(parent context.Context) {
 			}
 		case <-broadcastSignal.C: // resend unfulfilled wantlist keys
 			log.Event(ctx, "Bitswap.Rebroadcast.active")
-			entries := bs.wm.wl.Entries()
+			var entries []*wantlist.Entry
+			for _, e := range bs.wm.wl.Entries() {
+				if !bs.wm.scoped(e.Cid) {
+					entries = append(entries, e)
+				}
+			}
 			if len(entries) == 0 {
 				continue
 			}
 
+			// the entries wanted from some peers only are left to them
 			// TODO: come up with a better strategy for determining when to search
 			// for new providers for blocks.
 			i := rand.Intn(len(entries))
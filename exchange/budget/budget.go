@@ -0,0 +1,96 @@
+// Package budget implements an exchange limiting the number of concurrent
+// block requests of each priority class.
+package budget
+
+import (
+	"context"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// DefaultLimits are the default numbers of concurrent requests of each class.
+// Interactive requests are not limited.
+var DefaultLimits = map[exchange.Class]int{
+	exchange.Background: 16,
+	exchange.Bulk:       4,
+}
+
+type budgetExchange struct {
+	exchange.Interface
+
+	slots map[exchange.Class]chan struct{}
+}
+
+// New returns an exchange forwarding requests to ex, with at most limits[c]
+// requests of class c in flight at once. Classes without a positive limit are
+// not limited. A GetBlocks call takes a single slot until all its blocks were
+// received.
+func New(ex exchange.Interface, limits map[exchange.Class]int) exchange.Interface {
+	slots := make(map[exchange.Class]chan struct{})
+	for c, n := range limits {
+		if n > 0 {
+			slots[c] = make(chan struct{}, n)
+		}
+	}
+	return &budgetExchange{
+		Interface: ex,
+		slots:     slots,
+	}
+}
+
+// acquire waits for a slot for the class of ctx, and returns the function
+// giving it back.
+func (e *budgetExchange) acquire(ctx context.Context) (func(), error) {
+	slots, ok := e.slots[exchange.ClassFromContext(ctx)]
+	if !ok {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// GetBlock implements exchange.Interface.
+func (e *budgetExchange) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	release, err := e.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return e.Interface.GetBlock(ctx, c)
+}
+
+// GetBlocks implements exchange.Interface.
+func (e *budgetExchange) GetBlocks(ctx context.Context, ks []*cid.Cid) (<-chan blocks.Block, error) {
+	release, err := e.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := e.Interface.GetBlocks(ctx, ks)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer release()
+		for b := range in {
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
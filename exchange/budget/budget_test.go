@@ -0,0 +1,63 @@
+package budget
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// slowExchange counts the requests in flight and never finds a block.
+type slowExchange struct {
+	exchange.Interface
+
+	inflight, max int32
+}
+
+func (e *slowExchange) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	n := atomic.AddInt32(&e.inflight, 1)
+	defer atomic.AddInt32(&e.inflight, -1)
+	for {
+		m := atomic.LoadInt32(&e.max)
+		if n <= m || atomic.CompareAndSwapInt32(&e.max, m, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return nil, context.DeadlineExceeded
+}
+
+func TestBudget(t *testing.T) {
+	slow := &slowExchange{}
+	ex := New(slow, map[exchange.Class]int{exchange.Background: 2})
+	c := blocks.NewBlock([]byte("foo")).Cid()
+
+	run := func(ctx context.Context, n int) {
+		done := make(chan struct{})
+		for i := 0; i < n; i++ {
+			go func() {
+				ex.GetBlock(ctx, c)
+				done <- struct{}{}
+			}()
+		}
+		for i := 0; i < n; i++ {
+			<-done
+		}
+	}
+
+	run(exchange.WithClass(context.Background(), exchange.Background), 10)
+	if slow.max > 2 {
+		t.Fatalf("expected at most 2 background requests at once, got %d", slow.max)
+	}
+
+	slow.max = 0
+	run(context.Background(), 10)
+	if slow.max <= 2 {
+		t.Fatalf("expected interactive requests not to be limited, got %d at most", slow.max)
+	}
+}
@@ -0,0 +1,181 @@
+// Package httpfetch implements an exchange that fetches blocks from HTTP
+// gateways, in addition to another exchange (usually bitswap).
+//
+// Blocks are requested as raw blocks, following the trustless gateway
+// protocol:
+//
+//   GET <url>/ipfs/<cid>?format=raw
+//   Accept: application/vnd.ipld.raw
+//
+// The gateways don't have to be trusted, every block received is checked
+// against its CID before being used.
+package httpfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+var log = logging.Logger("httpfetch")
+
+// MaxBlockSize is the largest block accepted from a gateway.
+const MaxBlockSize = 2 << 20
+
+// FetchConcurrency is the number of blocks fetched over HTTP at the same time
+// for one GetBlocks call.
+const FetchConcurrency = 8
+
+// RequestTimeout bounds every HTTP request made to a gateway.
+const RequestTimeout = 30 * time.Second
+
+const rawContentType = "application/vnd.ipld.raw"
+
+// ErrNotFound is returned when no gateway could provide a block.
+var ErrNotFound = errors.New("block not found on any http source")
+
+type httpExchange struct {
+	exchange.Interface
+
+	urls   []string
+	client *http.Client
+}
+
+// New returns an exchange getting blocks from ex and from the gateways at the
+// given urls at the same time. Blocks fetched over HTTP are handed to ex with
+// HasBlock, which stores them and completes the pending requests for them.
+func New(ex exchange.Interface, urls []string) exchange.Interface {
+	trimmed := make([]string, len(urls))
+	for i, u := range urls {
+		trimmed[i] = strings.TrimRight(u, "/")
+	}
+	return &httpExchange{
+		Interface: ex,
+		urls:      trimmed,
+		client:    &http.Client{Timeout: RequestTimeout},
+	}
+}
+
+// GetBlock implements exchange.Interface.
+func (e *httpExchange) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go e.fetchAndAdd(ctx, c)
+	return e.Interface.GetBlock(ctx, c)
+}
+
+// GetBlocks implements exchange.Interface.
+func (e *httpExchange) GetBlocks(ctx context.Context, ks []*cid.Cid) (<-chan blocks.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	in, err := e.Interface.GetBlocks(ctx, ks)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go func() {
+		sem := make(chan struct{}, FetchConcurrency)
+		for _, c := range ks {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(c *cid.Cid) {
+				defer func() { <-sem }()
+				e.fetchAndAdd(ctx, c)
+			}(c)
+		}
+	}()
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for b := range in {
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// fetchAndAdd fetches c over HTTP and hands it to the wrapped exchange.
+func (e *httpExchange) fetchAndAdd(ctx context.Context, c *cid.Cid) {
+	b, err := e.fetch(ctx, c)
+	if err != nil {
+		log.Debugf("fetching %s over http: %s", c, err)
+		return
+	}
+	if err := e.Interface.HasBlock(b); err != nil {
+		log.Debugf("adding %s fetched over http: %s", c, err)
+	}
+}
+
+// fetch tries every gateway in turn until one returns a valid block for c.
+func (e *httpExchange) fetch(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	for _, u := range e.urls {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		b, err := e.fetchFrom(ctx, u, c)
+		if err == nil {
+			return b, nil
+		}
+		log.Debugf("fetching %s from %s: %s", c, u, err)
+	}
+	return nil, ErrNotFound
+}
+
+func (e *httpExchange) fetchFrom(ctx context.Context, url string, c *cid.Cid) (blocks.Block, error) {
+	req, err := http.NewRequest("GET", url+"/ipfs/"+c.String()+"?format=raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", rawContentType)
+
+	res, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", res.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, MaxBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxBlockSize {
+		return nil, errors.New("block too large")
+	}
+
+	// never trust the gateway
+	chk, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !chk.Equals(c) {
+		return nil, blocks.ErrWrongHash
+	}
+
+	return blocks.NewBlockWithCid(data, c)
+}
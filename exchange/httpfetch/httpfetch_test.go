@@ -0,0 +1,62 @@
+package httpfetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	"github.com/ipfs/go-ipfs/blocks/blockstore"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	ds_sync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+)
+
+func TestFetch(t *testing.T) {
+	good := blocks.NewBlock([]byte("good block"))
+	bad := blocks.NewBlock([]byte("bad block"))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "raw" || r.Header.Get("Accept") != rawContentType {
+			http.Error(w, "expected a raw block request", http.StatusBadRequest)
+			return
+		}
+		switch strings.TrimPrefix(r.URL.Path, "/ipfs/") {
+		case good.Cid().String():
+			w.Write(good.RawData())
+		case bad.Cid().String():
+			w.Write([]byte("not the bad block"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	bs := blockstore.NewBlockstore(ds_sync.MutexWrap(ds.NewMapDatastore()))
+	ex := New(offline.Exchange(bs), []string{"http://127.0.0.1:1/", ts.URL + "/"}).(*httpExchange)
+	ctx := context.Background()
+
+	b, err := ex.fetch(ctx, good.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b.RawData()) != "good block" {
+		t.Fatal("got the wrong data")
+	}
+
+	if _, err := ex.fetch(ctx, bad.Cid()); err != ErrNotFound {
+		t.Fatalf("expected a block not matching its cid to be rejected, got %v", err)
+	}
+
+	ex.fetchAndAdd(ctx, good.Cid())
+	has, err := bs.Has(good.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has {
+		t.Fatal("expected the fetched block to be stored")
+	}
+}
@@ -0,0 +1,34 @@
+package exchange
+
+import (
+	"context"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// BlockObserver is told of the blocks received for the requests of a
+// context, with the peer which sent each of them first. It is called from
+// the exchange's receiving path, and must not block.
+type BlockObserver func(p peer.ID, size int)
+
+type observerKey struct{}
+
+// WithBlockObserver returns a context telling o of the blocks received for
+// the requests made with it. The observers of ctx are still told.
+func WithBlockObserver(ctx context.Context, o BlockObserver) context.Context {
+	if prev := BlockObserverFromContext(ctx); prev != nil {
+		next := o
+		o = func(p peer.ID, size int) {
+			prev(p, size)
+			next(p, size)
+		}
+	}
+	return context.WithValue(ctx, observerKey{}, o)
+}
+
+// BlockObserverFromContext returns the observer of the blocks received for
+// the requests made with ctx, nil if there is none.
+func BlockObserverFromContext(ctx context.Context) BlockObserver {
+	o, _ := ctx.Value(observerKey{}).(BlockObserver)
+	return o
+}
@@ -0,0 +1,34 @@
+package exchange
+
+import (
+	"context"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// PeerHints are the peers known to have the blocks requested with a context,
+// e.g. the node the user fetches a dag from.
+type PeerHints struct {
+	Peers []peer.ID
+
+	// Only restricts the requests to Peers. Otherwise Peers are asked
+	// first, and the other peers only when Peers don't send the blocks in
+	// time.
+	Only bool
+}
+
+type peerHintsKey struct{}
+
+// WithPeerHints returns a context making the exchange ask peers for the
+// blocks requested with it, before any other peer, or only them with only.
+// The peers must be connected to be asked.
+func WithPeerHints(ctx context.Context, peers []peer.ID, only bool) context.Context {
+	return context.WithValue(ctx, peerHintsKey{}, &PeerHints{Peers: peers, Only: only})
+}
+
+// PeerHintsFromContext returns the peers to ask for the blocks requested
+// with ctx, nil if there are none.
+func PeerHintsFromContext(ctx context.Context) *PeerHints {
+	h, _ := ctx.Value(peerHintsKey{}).(*PeerHints)
+	return h
+}
@@ -0,0 +1,51 @@
+package exchange
+
+import (
+	"context"
+)
+
+// Class is the priority class of a block request. Requests of each class
+// have their own concurrency budget, so that e.g. pinning a large DAG in the
+// background doesn't starve reads a user is waiting for.
+type Class int
+
+const (
+	// Interactive requests have a user waiting on them, e.g. gateway
+	// requests or 'ipfs cat'. This is the class of requests without one.
+	Interactive Class = iota
+	// Background requests fetch content ahead of its use, e.g. pinning.
+	Background
+	// Bulk requests walk large parts of the repo, e.g. the repairs of
+	// 'ipfs pin verify --fix'.
+	Bulk
+)
+
+func (c Class) String() string {
+	switch c {
+	case Interactive:
+		return "interactive"
+	case Background:
+		return "background"
+	case Bulk:
+		return "bulk"
+	default:
+		return "unknown"
+	}
+}
+
+type classKey struct{}
+
+// WithClass returns a context marking the block requests made with it as
+// being of the given class.
+func WithClass(ctx context.Context, c Class) context.Context {
+	return context.WithValue(ctx, classKey{}, c)
+}
+
+// ClassFromContext returns the class of the block requests made with ctx.
+func ClassFromContext(ctx context.Context) Class {
+	c, ok := ctx.Value(classKey{}).(Class)
+	if !ok {
+		return Interactive
+	}
+	return c
+}
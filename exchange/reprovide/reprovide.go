@@ -3,9 +3,11 @@ package reprovide
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	blocks "github.com/ipfs/go-ipfs/blocks/blockstore"
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	backoff "gx/ipfs/QmPJUtEJsm5YLUWhF6imvyCH8KZXRJa9Wup7FDMwTy5Ufz/backoff"
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
 	routing "gx/ipfs/QmafuecpeZp3k3sHJ5mUARHd4795revuadECQMkmHB8LfW/go-libp2p-routing"
@@ -19,6 +21,23 @@ type Reprovider struct {
 
 	// The backing store for blocks to be provided
 	bstore blocks.Blockstore
+
+	// Filter decides which blocks are provided, all if nil. It must be set
+	// before the reprovider runs.
+	Filter exchange.AnnounceFilter
+
+	// progress of the current (or last) reprovide cycle, see Stat. The
+	// keys are counted as they are provided: total is the count of the last
+	// complete cycle until the keys of the current one are all listed.
+	lk        sync.Mutex
+	running   bool
+	total     int
+	lastTotal int
+	listed    int
+	provided  int
+	skipped   int
+	started   time.Time
+	ended     time.Time
 }
 
 func NewReprovider(rsys routing.ContentRouting, bstore blocks.Blockstore) *Reprovider {
@@ -52,7 +71,32 @@ func (rp *Reprovider) Reprovide(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("Failed to get key chan from blockstore: %s", err)
 	}
+
+	rp.startCycle()
+	complete := false
+	defer func() { rp.endCycle(complete) }()
+
 	for c := range keychan {
+		rp.lk.Lock()
+		rp.listed++
+		if rp.listed > rp.total {
+			rp.total = rp.listed
+		}
+		rp.lk.Unlock()
+
+		if rp.Filter != nil {
+			ok, err := rp.Filter.Announce(ctx, c)
+			if err != nil {
+				log.Debugf("Announce filter failed on %s: %s", c, err)
+			}
+			if err != nil || !ok {
+				rp.lk.Lock()
+				rp.skipped++
+				rp.lk.Unlock()
+				continue
+			}
+		}
+
 		op := func() error {
 			err := rp.rsys.Provide(ctx, c)
 			if err != nil {
@@ -68,6 +112,35 @@ func (rp *Reprovider) Reprovide(ctx context.Context) error {
 			log.Debugf("Providing failed after number of retries: %s", err)
 			return err
 		}
+		rp.lk.Lock()
+		rp.provided++
+		rp.lk.Unlock()
 	}
+
+	// the keys stop coming early when ctx is done
+	complete = ctx.Err() == nil
 	return nil
 }
+
+func (rp *Reprovider) startCycle() {
+	rp.lk.Lock()
+	defer rp.lk.Unlock()
+	rp.running = true
+	rp.total = rp.lastTotal
+	rp.listed = 0
+	rp.provided = 0
+	rp.skipped = 0
+	rp.started = time.Now()
+}
+
+// endCycle ends the current cycle, with the keys all listed if complete.
+func (rp *Reprovider) endCycle(complete bool) {
+	rp.lk.Lock()
+	defer rp.lk.Unlock()
+	rp.running = false
+	rp.ended = time.Now()
+	if complete {
+		rp.total = rp.listed
+		rp.lastTotal = rp.listed
+	}
+}
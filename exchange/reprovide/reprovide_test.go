@@ -6,11 +6,13 @@ import (
 	context "context"
 	blocks "github.com/ipfs/go-ipfs/blocks"
 	blockstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	mock "github.com/ipfs/go-ipfs/routing/mock"
 	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
 	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
 	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
 	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 
 	. "github.com/ipfs/go-ipfs/exchange/reprovide"
 )
@@ -38,6 +40,14 @@ func TestReprovide(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	st := reprov.Stat()
+	if st.InProgress || st.Total != 1 || st.Provided != 1 {
+		t.Fatalf("unexpected reprovide stat: %+v", st)
+	}
+	if !st.WithinValidity {
+		t.Fatal("expected the cycle to complete within record validity")
+	}
+
 	var providers []pstore.PeerInfo
 	maxProvs := 100
 
@@ -54,3 +64,46 @@ func TestReprovide(t *testing.T) {
 		t.Fatal("Somehow got the wrong peer back as a provider.")
 	}
 }
+
+func TestReprovideFilter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mrserv := mock.NewServer()
+	idA := testutil.RandIdentityOrFatal(t)
+	idB := testutil.RandIdentityOrFatal(t)
+	clA := mrserv.Client(idA)
+	clB := mrserv.Client(idB)
+
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	announced := blocks.NewBlock([]byte("announced"))
+	skipped := blocks.NewBlock([]byte("skipped"))
+	bstore.Put(announced)
+	bstore.Put(skipped)
+
+	reprov := NewReprovider(clA, bstore)
+	reprov.Filter = exchange.AnnounceFilterFunc(func(ctx context.Context, c *cid.Cid) (bool, error) {
+		return !c.Equals(skipped.Cid()), nil
+	})
+	if err := reprov.Reprovide(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	st := reprov.Stat()
+	if st.Total != 2 || st.Provided != 1 || st.Skipped != 1 || !st.EstimatedEnd.Equal(st.Ended) {
+		t.Fatalf("unexpected reprovide stat: %+v", st)
+	}
+
+	for _, c := range []struct {
+		blk       blocks.Block
+		announced bool
+	}{{announced, true}, {skipped, false}} {
+		var providers []pstore.PeerInfo
+		for p := range clB.FindProvidersAsync(ctx, c.blk.Cid(), 1) {
+			providers = append(providers, p)
+		}
+		if (len(providers) > 0) != c.announced {
+			t.Fatalf("expected %s to be announced: %t, got providers %v", c.blk.Cid(), c.announced, providers)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package reprovide
+
+import (
+	"time"
+)
+
+// ProvideValidity is how long provider records are kept by the DHT. Every
+// block has to be announced again before that or it stops being
+// discoverable.
+const ProvideValidity = 24 * time.Hour
+
+// Stat describes the progress of the current reprovide cycle, or of the
+// last one if none is running.
+type Stat struct {
+	InProgress bool
+	// Total is the number of CIDs to announce during the cycle. While it
+	// runs, it is estimated from the last complete cycle, or the number of
+	// CIDs listed so far if more: the CIDs are counted as they are
+	// announced.
+	Total int
+	// Provided is the number of CIDs announced so far.
+	Provided int
+	// Skipped is the number of CIDs the announce filter skipped so far.
+	Skipped int
+	// Rate is the number of CIDs announced or skipped per second.
+	Rate    float64
+	Started time.Time
+	Ended   time.Time
+	// EstimatedEnd is when the cycle is expected to complete, zero if
+	// unknown.
+	EstimatedEnd time.Time
+	// WithinValidity tells whether the cycle completes (or is expected to)
+	// before the provider records announced at its start expire.
+	WithinValidity bool
+}
+
+// Stat returns the progress of the reprovider.
+func (rp *Reprovider) Stat() *Stat {
+	rp.lk.Lock()
+	defer rp.lk.Unlock()
+
+	st := &Stat{
+		InProgress: rp.running,
+		Total:      rp.total,
+		Provided:   rp.provided,
+		Skipped:    rp.skipped,
+		Started:    rp.started,
+	}
+	if rp.started.IsZero() {
+		// no cycle ran yet
+		return st
+	}
+
+	done := rp.provided + rp.skipped
+	end := time.Now()
+	if !rp.running {
+		end = rp.ended
+		st.Ended = rp.ended
+	}
+	if elapsed := end.Sub(rp.started).Seconds(); elapsed > 0 {
+		st.Rate = float64(done) / elapsed
+	}
+
+	switch {
+	case !rp.running && done < rp.total:
+		// the cycle was aborted
+	case !rp.running:
+		st.EstimatedEnd = rp.ended
+	case done >= rp.total:
+		// past the estimate, or no cycle completed before
+	case st.Rate > 0:
+		left := float64(rp.total-done) / st.Rate
+		st.EstimatedEnd = end.Add(time.Duration(left * float64(time.Second)))
+	}
+	if !st.EstimatedEnd.IsZero() {
+		st.WithinValidity = st.EstimatedEnd.Sub(rp.started) < ProvideValidity
+	}
+	return st
+}
@@ -0,0 +1,51 @@
+package exchange
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Session groups the block requests of one fetch, e.g. of the blocks of a
+// file, so that the exchange can learn from the first blocks which peers to
+// ask for the next ones.
+type Session struct {
+	// ID identifies the session among the ones of this process.
+	ID uint64
+
+	done <-chan struct{}
+}
+
+// Done returns a channel closed when the session ends.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+var lastSessionID uint64
+
+type sessionKey struct{}
+
+// WithSession returns a context grouping the block requests made with it in
+// a new session, which ends with ctx. Contexts which are never done, like
+// context.Background(), don't start a session.
+func WithSession(ctx context.Context) context.Context {
+	if ctx.Done() == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionKey{}, &Session{
+		ID:   atomic.AddUint64(&lastSessionID, 1),
+		done: ctx.Done(),
+	})
+}
+
+// SessionFromContext returns the session of the block requests made with
+// ctx, nil if there is none.
+func SessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+// NewSessionID returns an ID for a session not created with WithSession,
+// distinct from theirs.
+func NewSessionID() uint64 {
+	return atomic.AddUint64(&lastSessionID, 1)
+}
@@ -124,7 +124,7 @@ func setupIpnsTest(t *testing.T, node *core.IpfsNode) (*core.IpfsNode, *mountWra
 		}
 
 		node.Routing = offroute.NewOfflineRouter(node.Repo.Datastore(), node.PrivateKey)
-		node.Namesys = namesys.NewNameSystem(node.Routing, node.Repo.Datastore(), 0)
+		node.Namesys = namesys.NewNameSystem(node.Routing, node.Repo.Datastore(), 0, 0, namesys.ResolveOptions{})
 
 		err = InitializeKeyspace(node, node.PrivateKey)
 		if err != nil {
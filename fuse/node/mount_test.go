@@ -51,7 +51,7 @@ func TestExternalUnmount(t *testing.T) {
 	}
 
 	node.Routing = offroute.NewOfflineRouter(node.Repo.Datastore(), node.PrivateKey)
-	node.Namesys = namesys.NewNameSystem(node.Routing, node.Repo.Datastore(), 0)
+	node.Namesys = namesys.NewNameSystem(node.Routing, node.Repo.Datastore(), 0, 0, namesys.ResolveOptions{})
 
 	err = ipns.InitializeKeyspace(node, node.PrivateKey)
 	if err != nil {
@@ -0,0 +1,117 @@
+// Package manifest implements signed content manifests. A manifest lists a
+// set of root cids and their sizes, and is signed by a publisher so that
+// mirror operators can check they replicate what the publisher intended.
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var (
+	ErrBadSignature = errors.New("manifest signature is invalid")
+	ErrWrongKey     = errors.New("manifest was not signed by its publisher")
+)
+
+// Entry is a root of a manifest.
+type Entry struct {
+	Cid string
+
+	// Size is the cumulative size of the dag under the root.
+	Size uint64
+}
+
+// Manifest is the signed part of a manifest.
+type Manifest struct {
+	Publisher string
+	Created   string
+	Entries   []Entry
+}
+
+// Signed is a manifest with its signature, and the public key to check it.
+type Signed struct {
+	Manifest  Manifest
+	PubKey    []byte
+	Signature []byte
+}
+
+// Sign creates a manifest of the given entries, signed by sk.
+func Sign(sk ic.PrivKey, entries []Entry) (*Signed, error) {
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := sk.GetPublic().Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.Sort(byCid(sorted))
+
+	s := &Signed{
+		Manifest: Manifest{
+			Publisher: id.Pretty(),
+			Created:   time.Now().UTC().Format(time.RFC3339),
+			Entries:   sorted,
+		},
+		PubKey: pk,
+	}
+
+	data, err := json.Marshal(&s.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Signature, err = sk.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Verify checks the signature of the manifest, and that it was made with
+// the key of the publisher. It returns the publisher.
+func (s *Signed) Verify() (peer.ID, error) {
+	pk, err := ic.UnmarshalPublicKey(s.PubKey)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return "", err
+	}
+	if id.Pretty() != s.Manifest.Publisher {
+		return "", ErrWrongKey
+	}
+
+	// the signature covers the canonical encoding of the manifest, so that
+	// reformatting the file doesn't invalidate it.
+	data, err := json.Marshal(&s.Manifest)
+	if err != nil {
+		return "", err
+	}
+
+	ok, err := pk.Verify(data, s.Signature)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrBadSignature
+	}
+	return id, nil
+}
+
+type byCid []Entry
+
+func (es byCid) Len() int           { return len(es) }
+func (es byCid) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
+func (es byCid) Less(i, j int) bool { return es[i].Cid < es[j].Cid }
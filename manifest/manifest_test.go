@@ -0,0 +1,59 @@
+package manifest
+
+import (
+	"encoding/json"
+	"testing"
+
+	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+func TestSignVerify(t *testing.T) {
+	sk, _, err := ic.GenerateKeyPair(ic.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Sign(sk, []Entry{{"QmB", 20}, {"QmA", 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Manifest.Entries[0].Cid != "QmA" {
+		t.Fatal("entries should be sorted")
+	}
+
+	// the manifest survives being reformatted
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Signed
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := out.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Pretty() != s.Manifest.Publisher {
+		t.Fatalf("expected publisher %s, got %s", s.Manifest.Publisher, id.Pretty())
+	}
+
+	out.Manifest.Entries[1].Size++
+	if _, err := out.Verify(); err != ErrBadSignature {
+		t.Fatalf("expected %s, got %v", ErrBadSignature, err)
+	}
+
+	other, _, err := ic.GenerateKeyPair(ic.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := Sign(other, s.Manifest.Entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.Manifest.Publisher = s.Manifest.Publisher
+	if _, err := forged.Verify(); err != ErrWrongKey {
+		t.Fatalf("expected %s, got %v", ErrWrongKey, err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	blocks "github.com/ipfs/go-ipfs/blocks"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	offline "github.com/ipfs/go-ipfs/exchange/offline"
 
 	ipldcbor "gx/ipfs/QmNrbCt8j9DT5W9Pmjy2SdudT9k8GpaDr4sRuFix3BXhgR/go-ipld-cbor"
@@ -163,22 +164,114 @@ func GetLinksDirect(serv node.NodeGetter) GetLinks {
 	}
 }
 
-// FetchGraph fetches all nodes that are children of the given node
+// FetchGraphWorkers is the number of blocks FetchGraph keeps requested at
+// once, unless the context sets another number with WithFetchWorkers.
+var FetchGraphWorkers = 32
+
+type fetchWorkersKey struct{}
+
+// WithFetchWorkers returns a context making FetchGraph keep n blocks
+// requested at once.
+func WithFetchWorkers(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, fetchWorkersKey{}, n)
+}
+
+// FetchGraph fetches all nodes that are children of the given node, breadth
+// first. The links of the nodes received are requested in batches, keeping up
+// to FetchGraphWorkers blocks requested at once, all in one exchange session:
+// the exchange learns of many wanted blocks at once, instead of a few at a
+// time, which makes wide dags much faster to fetch.
 func FetchGraph(ctx context.Context, root *cid.Cid, serv DAGService) error {
-	v, _ := ctx.Value("progress").(*ProgressTracker)
-	if v == nil {
-		return EnumerateChildrenAsync(ctx, GetLinksDirect(serv), root, cid.NewSet().Visit)
+	ctx, cancel := context.WithCancel(exchange.WithSession(ctx))
+	defer cancel()
+
+	workers := FetchGraphWorkers
+	if n, ok := ctx.Value(fetchWorkersKey{}).(int); ok && n > 0 {
+		workers = n
 	}
-	set := cid.NewSet()
-	visit := func(c *cid.Cid) bool {
-		if set.Visit(c) {
-			v.Increment()
+	v, _ := ctx.Value("progress").(*ProgressTracker)
+
+	results := make(chan *NodeOption)
+	send := func(opt *NodeOption) bool {
+		select {
+		case results <- opt:
 			return true
-		} else {
+		case <-ctx.Done():
 			return false
 		}
 	}
-	return EnumerateChildrenAsync(ctx, GetLinksDirect(serv), root, visit)
+	fetch := func(batch []*cid.Cid) {
+		got := cid.NewSet()
+		for opt := range serv.GetMany(ctx, batch) {
+			if opt.Err != nil {
+				break
+			}
+			got.Add(opt.Node.Cid())
+			if !send(opt) {
+				return
+			}
+		}
+		// get the nodes GetMany failed to get one by one, for their errors
+		for _, c := range batch {
+			if got.Has(c) {
+				continue
+			}
+			nd, err := serv.Get(ctx, c)
+			if !send(&NodeOption{Node: nd, Err: err}) {
+				return
+			}
+		}
+	}
+
+	seen := cid.NewSet()
+	seen.Add(root)
+	queue := []*cid.Cid{root}
+	inFlight := 0
+	for {
+		// request the queued nodes in batches, rather than one by one as
+		// the previous ones arrive
+		free := workers - inFlight
+		if len(queue) > 0 && (free >= (workers+3)/4 || inFlight == 0) {
+			n := free
+			if n > len(queue) {
+				n = len(queue)
+			}
+			go fetch(queue[:n])
+			queue = queue[n:]
+			inFlight += n
+		}
+		if inFlight == 0 {
+			return nil
+		}
+
+		select {
+		case opt := <-results:
+			inFlight--
+			if opt.Err != nil {
+				return opt.Err
+			}
+
+			nd := opt.Node
+			if v != nil {
+				// the cumulative size of the root is the size of the whole
+				// dag, less the blocks it links to more than once
+				if nd.Cid().Equals(root) {
+					if size, err := nd.Size(); err == nil {
+						v.AddTotalBytes(size)
+					}
+				}
+				v.AddFetched(uint64(len(nd.RawData())))
+			}
+
+			for _, lnk := range nd.Links() {
+				if seen.Visit(lnk.Cid) {
+					queue = append(queue, lnk.Cid)
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 }
 
 // FindLinks searches this nodes links for the given key,
@@ -412,6 +505,11 @@ type GetLinks func(context.Context, *cid.Cid) ([]*node.Link, error)
 // unseen children to the passed in set.
 // TODO: parallelize to avoid disk latency perf hits?
 func EnumerateChildren(ctx context.Context, getLinks GetLinks, root *cid.Cid, visit func(*cid.Cid) bool) error {
+	// local traversals never block on ctx, check it so they can be canceled
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	links, err := getLinks(ctx, root)
 	if err != nil {
 		return err
@@ -428,9 +526,13 @@ func EnumerateChildren(ctx context.Context, getLinks GetLinks, root *cid.Cid, vi
 	return nil
 }
 
+// ProgressTracker counts the nodes fetched by FetchGraph, and their size.
 type ProgressTracker struct {
 	Total int
 	lk    sync.Mutex
+
+	bytes      uint64
+	totalBytes uint64
 }
 
 func (p *ProgressTracker) DeriveContext(ctx context.Context) context.Context {
@@ -443,12 +545,36 @@ func (p *ProgressTracker) Increment() {
 	p.Total++
 }
 
+// AddFetched counts a node of the given size.
+func (p *ProgressTracker) AddFetched(size uint64) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.Total++
+	p.bytes += size
+}
+
+// AddTotalBytes adds to the size of the dags being fetched.
+func (p *ProgressTracker) AddTotalBytes(size uint64) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.totalBytes += size
+}
+
 func (p *ProgressTracker) Value() int {
 	p.lk.Lock()
 	defer p.lk.Unlock()
 	return p.Total
 }
 
+// Bytes returns the size of the nodes fetched, and the size of the dags
+// being fetched as far as it is known. The fetched size may not reach the
+// total one: a block linked to several times is only fetched once.
+func (p *ProgressTracker) Bytes() (fetched, total uint64) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	return p.bytes, p.totalBytes
+}
+
 // FetchGraphConcurrency is total number of concurrent fetches that
 // 'fetchNodes' will start at a time
 var FetchGraphConcurrency = 8
@@ -255,6 +255,72 @@ func TestFetchGraph(t *testing.T) {
 	}
 }
 
+func TestFetchGraphWide(t *testing.T) {
+	var dservs []DAGService
+	bsis := bstest.Mocks(2)
+	for _, bsi := range bsis {
+		dservs = append(dservs, NewDAGService(bsi))
+	}
+
+	// more links than blocks requested at once, over two levels
+	root := NodeWithData([]byte("root"))
+	for i := 0; i < 10; i++ {
+		dir := NodeWithData([]byte(fmt.Sprintf("dir %d", i)))
+		for j := 0; j < 10; j++ {
+			leaf := NodeWithData([]byte(fmt.Sprintf("leaf %d %d", i, j)))
+			if _, err := dservs[0].Add(leaf); err != nil {
+				t.Fatal(err)
+			}
+			if err := dir.AddNodeLink(fmt.Sprint(j), leaf); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := dservs[0].Add(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.AddNodeLink(fmt.Sprint(i), dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dservs[0].Add(root); err != nil {
+		t.Fatal(err)
+	}
+
+	v := new(ProgressTracker)
+	ctx := WithFetchWorkers(v.DeriveContext(context.Background()), 7)
+	if err := FetchGraph(ctx, root.Cid(), dservs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if v.Value() != 111 {
+		t.Fatalf("expected 111 nodes fetched, got %d", v.Value())
+	}
+
+	bs := bserv.New(bsis[1].Blockstore(), offline.Exchange(bsis[1].Blockstore()))
+	err := EnumerateChildren(context.Background(), NewDAGService(bs).GetLinks, root.Cid(), func(_ *cid.Cid) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchGraphMissing(t *testing.T) {
+	bs := bstest.Mocks(1)[0].Blockstore()
+	ds := NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	missing := NodeWithData([]byte("missing"))
+	root := NodeWithData([]byte("root"))
+	if err := root.AddNodeLink("missing", missing); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.Add(root); err != nil {
+		t.Fatal(err)
+	}
+
+	err := FetchGraph(context.Background(), root.Cid(), ds)
+	if err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
 func TestEnumerateChildren(t *testing.T) {
 	bsi := bstest.Mocks(1)
 	ds := NewDAGService(bsi[0])
@@ -574,6 +640,22 @@ func testProgressIndicator(t *testing.T, depth int) {
 		t.Errorf("wrong number of children reported in progress indicator, expected %d, got %d",
 			numChildren+1, v.Value())
 	}
+
+	nd, err := ds.Get(context.Background(), top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := nd.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, total := v.Bytes()
+	if total != size {
+		t.Errorf("expected a total of %d bytes, got %d", size, total)
+	}
+	if fetched != size {
+		t.Errorf("expected %d bytes fetched, got %d", size, fetched)
+	}
 }
 
 func mkDag(ds DAGService, depth int) (*cid.Cid, int) {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
@@ -54,6 +55,27 @@ func PrefixForCidVersion(version int) (cid.Prefix, error) {
 	}
 }
 
+// PrefixForHash returns the Protobuf prefix for a given CID version and hash
+// function name. Hash functions other than sha2-256 need CID version 1, which
+// is used for them.
+func PrefixForHash(version int, hashFun string) (cid.Prefix, error) {
+	hashFun = strings.ToLower(hashFun)
+	code, ok := mh.Names[hashFun]
+	if !ok {
+		return cid.Prefix{}, fmt.Errorf("unrecognized hash function: %s", hashFun)
+	}
+	if code != mh.SHA2_256 && version == 0 {
+		version = 1
+	}
+
+	prefix, err := PrefixForCidVersion(version)
+	if err != nil {
+		return cid.Prefix{}, err
+	}
+	prefix.MhType = code
+	return prefix, nil
+}
+
 // SetPrefix sets the CID prefix if it is non nil, if prefix is nil then
 // it resets it the default value
 func (n *ProtoNode) SetPrefix(prefix *cid.Prefix) {
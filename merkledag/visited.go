@@ -0,0 +1,185 @@
+package merkledag
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	levelds "gx/ipfs/QmaHHmfEozrrotyhyN44omJouyuEtx6ahddqV6W5yRaUSQ/go-ds-leveldb"
+	ldbopts "gx/ipfs/QmbBhyDKsY4mbY6xsKt3qu9Y7FPvMJ6qbD8AMjYYvPRw1g/goleveldb/leveldb/opt"
+	bloom "gx/ipfs/QmeiMCBkYHxkDkDfnDadzz4YxY5ruL5Pj499essE4vRsGM/bbloom"
+)
+
+// DefaultVisitedInMemory is how many cids a VisitedSet holds in memory
+// before spilling them to disk.
+const DefaultVisitedInMemory = 1 << 20
+
+// size of the bloom filter in front of the spilled cids, in bits, and its
+// number of hash functions. It keeps false positives around 1% up to about
+// 25 million spilled cids, and gets slowly worse past that.
+const (
+	visitedBloomSize   = 1 << 28
+	visitedBloomHashes = 7
+)
+
+// VisitedSet is the set of cids visited by a traversal, for dags too large
+// for their cids to fit in memory. It holds up to a given number of cids in
+// memory, and spills them to a temporary leveldb when it's full, with a bloom
+// filter in front to spare disk reads for the cids it hasn't seen, which are
+// most of them in a traversal.
+//
+// Like cid.Set, a VisitedSet isn't safe for concurrent use. It must be closed
+// to remove its files.
+type VisitedSet struct {
+	mem    map[string]int // key to depth
+	memMax int
+
+	dir    string
+	disk   ds.Batching
+	filter *bloom.Bloom
+}
+
+// NewVisitedSet returns a VisitedSet holding up to inMemory cids in memory.
+func NewVisitedSet(inMemory int) *VisitedSet {
+	if inMemory < 1 {
+		inMemory = DefaultVisitedInMemory
+	}
+	return &VisitedSet{
+		mem:    make(map[string]int),
+		memMax: inMemory,
+	}
+}
+
+// Has returns whether c is in the set.
+func (s *VisitedSet) Has(c *cid.Cid) bool {
+	_, ok := s.get(c.KeyString())
+	return ok
+}
+
+// Visit adds c to the set, and returns whether it wasn't there, to be used
+// as the visit function of EnumerateChildren.
+func (s *VisitedSet) Visit(c *cid.Cid) bool {
+	return s.VisitDepth(c, 0)
+}
+
+// VisitDepth adds c, met depth links away from the root, to the set. It
+// returns whether c wasn't met before at that depth or less, in which case
+// its children may be within a depth limit they weren't before.
+func (s *VisitedSet) VisitDepth(c *cid.Cid, depth int) bool {
+	k := c.KeyString()
+	if d, ok := s.get(k); ok && d <= depth {
+		return false
+	}
+
+	s.mem[k] = depth
+	if len(s.mem) >= s.memMax {
+		s.spill()
+	}
+	return true
+}
+
+// Close removes the files of the set.
+func (s *VisitedSet) Close() error {
+	if s.disk == nil {
+		return nil
+	}
+	if c, ok := s.disk.(io.Closer); ok {
+		c.Close()
+	}
+	s.disk = nil
+	return os.RemoveAll(s.dir)
+}
+
+func (s *VisitedSet) get(k string) (int, bool) {
+	if d, ok := s.mem[k]; ok {
+		return d, true
+	}
+	if s.disk == nil || !s.filter.Has([]byte(k)) {
+		return 0, false
+	}
+
+	v, err := s.disk.Get(diskKey(k))
+	if err != nil {
+		if err != ds.ErrNotFound {
+			log.Errorf("visited set: %s", err)
+		}
+		return 0, false
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return 0, false
+	}
+	d, n := binary.Varint(b)
+	if n <= 0 {
+		return 0, false
+	}
+	return int(d), true
+}
+
+// spill moves the cids in memory to disk. If the disk fails, they stay in
+// memory.
+func (s *VisitedSet) spill() {
+	if err := s.writeDisk(); err != nil {
+		log.Errorf("visited set: keeping cids in memory: %s", err)
+		s.memMax *= 2
+		return
+	}
+
+	for k := range s.mem {
+		s.filter.Add([]byte(k))
+	}
+	s.mem = make(map[string]int)
+}
+
+func (s *VisitedSet) writeDisk() error {
+	if s.disk == nil {
+		if err := s.openDisk(); err != nil {
+			return err
+		}
+	}
+
+	batch, err := s.disk.Batch()
+	if err != nil {
+		return err
+	}
+	for k, d := range s.mem {
+		buf := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutVarint(buf, int64(d))
+		if err := batch.Put(diskKey(k), buf[:n]); err != nil {
+			return err
+		}
+	}
+	return batch.Commit()
+}
+
+func (s *VisitedSet) openDisk() error {
+	dir, err := ioutil.TempDir("", "ipfs-visited")
+	if err != nil {
+		return err
+	}
+	disk, err := levelds.NewDatastore(dir, &levelds.Options{
+		Compression: ldbopts.NoCompression,
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	filter, err := bloom.New(visitedBloomSize, visitedBloomHashes)
+	if err != nil {
+		disk.Close()
+		os.RemoveAll(dir)
+		return err
+	}
+
+	s.dir, s.disk, s.filter = dir, disk, filter
+	return nil
+}
+
+// diskKey returns the datastore key of the binary key of a cid.
+func diskKey(k string) ds.Key {
+	return ds.NewKey(hex.EncodeToString([]byte(k)))
+}
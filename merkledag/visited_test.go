@@ -0,0 +1,60 @@
+package merkledag_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/ipfs/go-ipfs/merkledag"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+func testCid(i int) *cid.Cid {
+	return cid.NewCidV0(u.Hash([]byte(fmt.Sprint(i))))
+}
+
+func TestVisitedSetSpills(t *testing.T) {
+	s := NewVisitedSet(10)
+	defer s.Close()
+
+	for i := 0; i < 100; i++ {
+		if !s.Visit(testCid(i)) {
+			t.Fatalf("cid %d visited before being added", i)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if s.Visit(testCid(i)) {
+			t.Fatalf("cid %d not in the set", i)
+		}
+		if !s.Has(testCid(i)) {
+			t.Fatalf("set doesn't have cid %d", i)
+		}
+	}
+	if s.Has(testCid(100)) {
+		t.Fatal("set has a cid never added")
+	}
+}
+
+func TestVisitedSetDepth(t *testing.T) {
+	s := NewVisitedSet(2)
+	defer s.Close()
+
+	c := testCid(0)
+	if !s.VisitDepth(c, 3) {
+		t.Fatal("first visit should be new")
+	}
+	// push c to disk
+	s.Visit(testCid(1))
+	s.Visit(testCid(2))
+
+	if s.VisitDepth(c, 4) {
+		t.Fatal("visit deeper than before should not be new")
+	}
+	if !s.VisitDepth(c, 1) {
+		t.Fatal("visit closer than before should be new")
+	}
+	if s.VisitDepth(c, 1) {
+		t.Fatal("visit at the same depth should not be new")
+	}
+}
@@ -194,6 +194,23 @@ func (d *Directory) Uncache(name string) {
 	delete(d.childDirs, name)
 }
 
+// UncacheAll drops all the children of the directory from memory. Their
+// changes are synced to the directory's node first, and they are loaded back
+// from the dag when accessed again. This bounds the memory used by large
+// trees that are being built.
+func (d *Directory) UncacheAll() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err := d.sync(); err != nil {
+		return err
+	}
+
+	d.childDirs = make(map[string]*Directory)
+	d.files = make(map[string]*File)
+	return nil
+}
+
 // childFromDag searches through this directories dag node for a child link
 // with the given name
 func (d *Directory) childFromDag(name string) (node.Node, error) {
@@ -1127,3 +1127,96 @@ func TestFileDescriptors(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestUncacheAll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ds, rt := setupRoot(ctx, t)
+
+	rootdir := rt.GetValue().(*Directory)
+	d := mkdirP(t, rootdir, "a/b/c")
+
+	fi := getRandFile(t, ds, 1000)
+	if err := d.AddChild("afile", fi); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rootdir.UncacheAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the children were dropped, and are loaded back from the dag
+	if len(rootdir.childDirs) != 0 || len(rootdir.files) != 0 {
+		t.Fatal("expected no cached children")
+	}
+	if err := assertFileAtPath(ds, rootdir, fi, "a/b/c/afile"); err != nil {
+		t.Fatal(err)
+	}
+
+	// changes made after reloading are kept
+	mkdirP(t, rootdir, "a/b/d")
+	if err := rootdir.UncacheAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := assertDirAtPath(rootdir, "a/b", []string{"c", "d"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeferredFlush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data := []byte("this is a test\n")
+	write := func(rt *Root, dir, name string, flush bool) {
+		d := rt.GetValue().(*Directory)
+		if dir != "" {
+			d = mkdirP(t, d, dir)
+		}
+		if err := d.AddChild(name, dag.NodeWithData(ft.FilePBData(nil, 0))); err != nil {
+			t.Fatal(err)
+		}
+		fsn, err := d.Child(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fd, err := fsn.(*File).Open(OpenWriteOnly, flush)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fd.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := fd.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := [][2]string{{"a/b", "c"}, {"a/b", "d"}, {"a", "e"}, {"", "f"}}
+
+	_, eager := setupRoot(ctx, t)
+	for _, f := range files {
+		write(eager, f[0], f[1], true)
+	}
+
+	_, deferred := setupRoot(ctx, t)
+	for _, f := range files {
+		write(deferred, f[0], f[1], false)
+	}
+	if err := FlushPath(deferred, "/"); err != nil {
+		t.Fatal(err)
+	}
+
+	exp, err := eager.GetValue().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := deferred.GetValue().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exp.Cid().Equals(out.Cid()) {
+		t.Fatalf("expected root %s after flush, got %s", exp.Cid(), out.Cid())
+	}
+}
@@ -114,6 +114,11 @@ func (kr *Root) GetValue() FSNode {
 	return kr.val
 }
 
+// DAGService returns the DAG service the nodes of the tree are stored with.
+func (kr *Root) DAGService() dag.DAGService {
+	return kr.dserv
+}
+
 func (kr *Root) Flush() error {
 	nd, err := kr.GetValue().GetNode()
 	if err != nil {
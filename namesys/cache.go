@@ -0,0 +1,88 @@
+package namesys
+
+import (
+	"time"
+
+	path "github.com/ipfs/go-ipfs/path"
+
+	lru "gx/ipfs/QmVYxfoJQiZijTgPNHCHgHELvQpbsJNTg6Crmc3dQkj3yy/golang-lru"
+)
+
+// resolveCache is an LRU cache of name resolutions, each entry valid until
+// its own end of life. It is shared by all the resolvers of a NameSystem so
+// that /ipns and dnslink names resolved by the gateway, the commands and the
+// core API are only looked up once per TTL.
+//
+// A nil *resolveCache is valid and caches nothing.
+type resolveCache struct {
+	lru *lru.Cache
+}
+
+type cacheEntry struct {
+	val path.Path
+	eol time.Time
+
+	// subNames is set for routing names whose record delegates sub-names
+	subNames bool
+}
+
+// newResolveCache returns a cache holding at most size entries, or nil if
+// size is not positive.
+func newResolveCache(size int) *resolveCache {
+	if size <= 0 {
+		return nil
+	}
+	c, _ := lru.New(size)
+	return &resolveCache{lru: c}
+}
+
+func (c *resolveCache) get(name string) (path.Path, bool) {
+	e, ok := c.getEntry(name)
+	return e.val, ok
+}
+
+func (c *resolveCache) getEntry(name string) (cacheEntry, bool) {
+	if c == nil {
+		return cacheEntry{}, false
+	}
+
+	ientry, ok := c.lru.Get(name)
+	if !ok {
+		return cacheEntry{}, false
+	}
+
+	entry, ok := ientry.(cacheEntry)
+	if !ok {
+		// should never happen, purely for sanity
+		log.Panicf("unexpected type %T in cache for %q.", ientry, name)
+	}
+
+	if time.Now().Before(entry.eol) {
+		return entry, true
+	}
+
+	c.lru.Remove(name)
+
+	return cacheEntry{}, false
+}
+
+func (c *resolveCache) set(name string, val path.Path, eol time.Time) {
+	c.setEntry(name, cacheEntry{
+		val: val,
+		eol: eol,
+	})
+}
+
+func (c *resolveCache) setEntry(name string, e cacheEntry) {
+	if c == nil {
+		return
+	}
+	c.lru.Add(name, e)
+}
+
+func (c *resolveCache) remove(name string) {
+	if c == nil {
+		return
+	}
+	c.lru.Remove(name)
+}
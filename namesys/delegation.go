@@ -0,0 +1,322 @@
+package namesys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
+	path "github.com/ipfs/go-ipfs/path"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// ErrInvalidDelegation is returned for sub-name entries whose delegation
+// isn't signed by the owner of the parent name, or isn't for the sub-name
+// or the key of the entry.
+var ErrInvalidDelegation = errors.New("invalid ipns delegation")
+
+// ErrExpiredDelegation is returned for sub-name entries whose delegation
+// is past its end of life.
+var ErrExpiredDelegation = errors.New("expired ipns delegation")
+
+// DelegatedPublisher is a Publisher which can also publish the sub-names
+// of other names, under a delegation from their owner.
+type DelegatedPublisher interface {
+	// PublishDelegated publishes value as the sub-name d is for, signed by
+	// k, the key d delegates it to.
+	PublishDelegated(ctx context.Context, k ci.PrivKey, d *pb.IpnsDelegation, value path.Path, eol time.Time) error
+}
+
+// ValidSubName returns an error if name can't be delegated: sub-names are a
+// single, non empty path component.
+func ValidSubName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00") {
+		return fmt.Errorf("invalid ipns sub-name %q", name)
+	}
+	return nil
+}
+
+// CreateDelegation returns a delegation, signed by parent, allowing the
+// owner of delegate to publish the sub-name name of parent's name until eol.
+func CreateDelegation(parent ci.PrivKey, name string, delegate ci.PubKey, eol time.Time) (*pb.IpnsDelegation, error) {
+	if err := ValidSubName(name); err != nil {
+		return nil, err
+	}
+
+	pk, err := parent.GetPublic().Bytes()
+	if err != nil {
+		return nil, err
+	}
+	dk, err := delegate.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &pb.IpnsDelegation{
+		Name:        proto.String(name),
+		ParentKey:   pk,
+		DelegateKey: dk,
+		Validity:    []byte(u.FormatRFC3339(eol)),
+	}
+	d.Signature, err = parent.Sign(delegationDataForSig(d))
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func delegationDataForSig(d *pb.IpnsDelegation) []byte {
+	return bytes.Join([][]byte{
+		[]byte("ipns-delegation"),
+		[]byte(d.GetName()),
+		d.GetValidity(),
+		d.GetDelegateKey(),
+	},
+		[]byte{0})
+}
+
+// DelegationParent returns the name d is a delegation of a sub-name of.
+func DelegationParent(d *pb.IpnsDelegation) (peer.ID, error) {
+	pk, err := ci.UnmarshalPublicKey(d.GetParentKey())
+	if err != nil {
+		return "", err
+	}
+	return peer.IDFromPublicKey(pk)
+}
+
+// verifyDelegation checks that d is a valid delegation of sub-name name of
+// parent, and returns the public key it delegates to.
+func verifyDelegation(parent peer.ID, name string, d *pb.IpnsDelegation) (ci.PubKey, error) {
+	if d == nil || d.GetName() != name {
+		return nil, ErrInvalidDelegation
+	}
+
+	pk, err := ci.UnmarshalPublicKey(d.GetParentKey())
+	if err != nil {
+		return nil, err
+	}
+	if !parent.MatchesPublicKey(pk) {
+		return nil, ErrInvalidDelegation
+	}
+	if ok, err := pk.Verify(delegationDataForSig(d), d.GetSignature()); err != nil || !ok {
+		return nil, ErrInvalidDelegation
+	}
+
+	eol, err := u.ParseRFC3339(string(d.GetValidity()))
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(eol) {
+		return nil, ErrExpiredDelegation
+	}
+
+	return ci.UnmarshalPublicKey(d.GetDelegateKey())
+}
+
+// validateDelegatedEntry checks that e is a valid entry for sub-name name
+// of parent: published under a valid delegation, and signed by the key it
+// delegates to.
+func validateDelegatedEntry(parent peer.ID, name string, e *pb.IpnsEntry) error {
+	dk, err := verifyDelegation(parent, name, e.GetDelegation())
+	if err != nil {
+		return err
+	}
+	if ok, err := dk.Verify(ipnsEntryDataForSig(e), e.GetSignature()); err != nil || !ok {
+		return fmt.Errorf("Invalid value. Not signed by PrivateKey corresponding to %v", dk)
+	}
+	return nil
+}
+
+// IpnsKeyForSubName returns the routing key of the entries of sub-name name
+// of id.
+func IpnsKeyForSubName(id peer.ID, name string) string {
+	_, ipnskey := IpnsKeysForID(id)
+	return ipnskey + "/" + name
+}
+
+// splitSubNameKey returns the parent name and the sub-name of the routing
+// key of a sub-name entry, ok is false for other keys. Names are binary
+// multihashes which may contain slashes, so the key is split at the last
+// one, and only if what comes before it is a whole multihash.
+func splitSubNameKey(k string) (parent peer.ID, name string, ok bool) {
+	if !strings.HasPrefix(k, "/ipns/") {
+		return "", "", false
+	}
+	rest := strings.TrimPrefix(k, "/ipns/")
+	if _, err := mh.Cast([]byte(rest)); err == nil {
+		return "", "", false
+	}
+
+	i := strings.LastIndex(rest, "/")
+	if i < 0 {
+		return "", "", false
+	}
+	if _, err := mh.Cast([]byte(rest[:i])); err != nil {
+		return "", "", false
+	}
+	return peer.ID(rest[:i]), rest[i+1:], true
+}
+
+// PublishDelegated implements DelegatedPublisher.
+func (p *ipnsPublisher) PublishDelegated(ctx context.Context, k ci.PrivKey, d *pb.IpnsDelegation, value path.Path, eol time.Time) error {
+	parent, err := DelegationParent(d)
+	if err != nil {
+		return err
+	}
+	dk, err := verifyDelegation(parent, d.GetName(), d)
+	if err != nil {
+		return err
+	}
+	if !dk.Equals(k.GetPublic()) {
+		return fmt.Errorf("the delegation of %s/%s is for another key", parent.Pretty(), d.GetName())
+	}
+
+	ipnskey := IpnsKeyForSubName(parent, d.GetName())
+	name := subNameCacheKey(parent.Pretty(), d.GetName())
+	seqnum, err := p.nextSeqNo(ctx, ipnskey, name)
+	if err != nil {
+		return err
+	}
+
+	typ := pb.IpnsEntry_EOL
+	if checkCtxPerpetual(ctx) {
+		typ = pb.IpnsEntry_PERPETUAL
+	}
+	entry, err := createRoutingEntry(k, value, seqnum, typ, eol)
+	if err != nil {
+		return err
+	}
+	entry.Delegation = d
+	if ttl, ok := checkCtxTTL(ctx); ok {
+		entry.Ttl = proto.Uint64(uint64(ttl.Nanoseconds()))
+	}
+
+	if err := PublishEntry(ctx, p.routing, ipnskey, entry); err != nil {
+		return err
+	}
+	return p.putState(ctx, name, seqnum, value, eol)
+}
+
+// PublishDelegated implements DelegatedPublisher.
+func (ns *mpns) PublishDelegated(ctx context.Context, k ci.PrivKey, d *pb.IpnsDelegation, value path.Path, eol time.Time) error {
+	pub, ok := ns.publishers["/ipns/"].(DelegatedPublisher)
+	if !ok {
+		return errors.New("publisher can't publish sub-names")
+	}
+	if err := pub.PublishDelegated(ctx, k, d, value, eol); err != nil {
+		return err
+	}
+
+	if ns.cache != nil {
+		parent, err := DelegationParent(d)
+		if err != nil {
+			return nil
+		}
+		if time.Now().Add(DefaultResolverCacheTTL).Before(eol) {
+			eol = time.Now().Add(DefaultResolverCacheTTL)
+		}
+		ns.cache.set(subNameCacheKey(parent.Pretty(), d.GetName()), value, eol)
+	}
+	return nil
+}
+
+// subNameCacheKey returns the key of the resolution of a sub-name in the
+// resolution cache. Sub-names with no entry are cached as an empty path.
+func subNameCacheKey(name, sub string) string {
+	return name + "/" + sub
+}
+
+// resolveSubName resolves sub-name sub of name, a routing name. It returns
+// an empty path if sub wasn't delegated, or its entry isn't valid.
+func (r *routingResolver) resolveSubName(ctx context.Context, name, sub string) path.Path {
+	ckey := subNameCacheKey(name, sub)
+	if p, ok := r.cache.get(ckey); ok {
+		return p
+	}
+
+	hash, err := mh.FromB58String(name)
+	if err != nil {
+		return ""
+	}
+	parent := peer.ID(hash)
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	val, err := r.getValue(ctx, IpnsKeyForSubName(parent, sub))
+	if err != nil {
+		if ctx.Err() == nil {
+			r.cache.set(ckey, "", time.Now().Add(DefaultResolverCacheTTL))
+		}
+		return ""
+	}
+
+	entry := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(val, entry); err != nil {
+		return ""
+	}
+	if err := validateDelegatedEntry(parent, sub, entry); err != nil {
+		log.Warningf("ignoring entry of %s/%s: %s", name, sub, err)
+		return ""
+	}
+
+	p, err := path.ParsePath(string(entry.GetValue()))
+	if err != nil {
+		return ""
+	}
+
+	r.cacheSet(ckey, p, entry)
+	return p
+}
+
+// subNameResolver returns the resolver of name if it's a routing name,
+// which may delegate sub-names.
+func (ns *mpns) subNameResolver(name string) (*routingResolver, bool) {
+	r, ok := ns.resolvers["dht"].(*routingResolver)
+	if !ok {
+		return nil, false
+	}
+	if _, err := mh.FromB58String(name); err != nil {
+		return nil, false
+	}
+	return r, true
+}
+
+// resolveWithSubName resolves the routing name segments[2], and if its
+// record delegates sub-names, the sub-name starting the rest of the path,
+// which takes precedence over the name's own value. Sub-names are only
+// looked up for the names which delegate them, so that resolving paths
+// under other names doesn't cost a lookup per path.
+func (ns *mpns) resolveWithSubName(ctx context.Context, r *routingResolver, name string, segments []string) (path.Path, error) {
+	p, subNames, err := r.resolveEntry(ctx, segments[2])
+	if err != nil {
+		log.Warningf("No resolver found for %s", name)
+		return "", ErrResolveFailed
+	}
+
+	sub := strings.SplitN(segments[3], "/", 2)[0]
+	if subNames && ValidSubName(sub) == nil {
+		if sp := r.resolveSubName(ctx, segments[2], sub); sp != "" {
+			return withSubNameRest(sp, segments[3])
+		}
+	}
+	return withRest(p, segments)
+}
+
+// withSubNameRest appends the path following the sub-name starting rest to
+// the value of the sub-name.
+func withSubNameRest(p path.Path, rest string) (path.Path, error) {
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) < 2 || parts[1] == "" {
+		return p, nil
+	}
+	return path.FromSegments("", strings.TrimRight(p.String(), "/"), parts[1])
+}
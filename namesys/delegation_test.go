@@ -0,0 +1,119 @@
+package namesys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	path "github.com/ipfs/go-ipfs/path"
+	offroute "github.com/ipfs/go-ipfs/routing/offline"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestDelegatedSubName(t *testing.T) {
+	ctx := context.Background()
+	dst := dssync.MutexWrap(ds.NewMapDatastore())
+
+	parentk, parentpub, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegatek, delegatepub, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherk, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := peer.IDFromPublicKey(parentpub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nsys := NewNameSystem(offroute.NewOfflineRouter(dst, parentk), dst, 0, 0, ResolveOptions{})
+	pub := nsys.(DelegatedPublisher)
+
+	site := path.Path("/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD")
+	blog := path.Path("/ipfs/Qmcqtw8FfrVSBaRmbWwHxt3AuySBhJLcvmFYi3Lbc4xnwj")
+	if err := nsys.Publish(ctx, parentk, site); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := CreateDelegation(parentk, "blog", delegatepub, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pub.PublishDelegated(ctx, otherk, d, blog, time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("expected publishing with a key the sub-name isn't delegated to to fail")
+	}
+	if err := pub.PublishDelegated(ctx, delegatek, d, blog, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// sub-names are only looked up under names which delegate them
+	name := "/ipns/" + parent.Pretty()
+	testResolution(t, nsys, name+"/blog", DefaultDepthLimit, site.String()+"/blog", nil)
+
+	subctx := context.WithValue(ctx, "ipns-publish-subnames", true)
+	if err := nsys.Publish(subctx, parentk, site); err != nil {
+		t.Fatal(err)
+	}
+	testResolution(t, nsys, name+"/blog", DefaultDepthLimit, blog.String(), nil)
+	testResolution(t, nsys, name+"/blog/post.html", DefaultDepthLimit, blog.String()+"/post.html", nil)
+	testResolution(t, nsys, name+"/about.html", DefaultDepthLimit, site.String()+"/about.html", nil)
+}
+
+func TestValidateDelegatedRecord(t *testing.T) {
+	parentk, parentpub, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegatek, delegatepub, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherk, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := peer.IDFromPublicKey(parentpub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := CreateDelegation(parentk, "blog", delegatepub, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := func(k ci.PrivKey, name string) (string, []byte) {
+		e, err := CreateRoutingEntryData(k, path.Path("/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD"), 1, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Delegation = d
+		data, err := proto.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return IpnsKeyForSubName(parent, name), data
+	}
+
+	if err := ValidateIpnsRecord(record(delegatek, "blog")); err != nil {
+		t.Fatalf("expected a valid record, got: %s", err)
+	}
+	if err := ValidateIpnsRecord(record(otherk, "blog")); err == nil {
+		t.Fatal("expected a record not signed by the delegate to be invalid")
+	}
+	if err := ValidateIpnsRecord(record(delegatek, "news")); err != ErrInvalidDelegation {
+		t.Fatalf("expected a record for another sub-name to be invalid, got: %v", err)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"net"
 	"strings"
+	"sync"
+	"time"
 
 	path "github.com/ipfs/go-ipfs/path"
 
@@ -13,22 +15,48 @@ import (
 
 type LookupTXTFunc func(name string) (txt []string, err error)
 
+// DefaultNegativeCacheTTL is how long a domain that could not be resolved
+// is remembered as such, unless configured otherwise.
+const DefaultNegativeCacheTTL = 30 * time.Second
+
 // DNSResolver implements a Resolver on DNS domains
+//
+// Concurrent lookups of the same TXT record are collapsed into one, and
+// domains without a valid dnslink are not looked up again for negativeTTL.
+// Successful resolutions are cached by the NameSystem.
 type DNSResolver struct {
-	lookupTXT LookupTXTFunc
-	// TODO: maybe some sort of caching?
-	// cache would need a timeout
+	lookupTXT   LookupTXTFunc
+	negativeTTL time.Duration
+
+	lk       sync.Mutex
+	inflight map[string]*txtLookup
+	failed   map[string]time.Time
+}
+
+// txtLookup is a TXT lookup in progress, shared by all callers asking for
+// the same name while it runs.
+type txtLookup struct {
+	done chan struct{}
+	txt  []string
+	err  error
 }
 
 // NewDNSResolver constructs a name resolver using DNS TXT records.
 func NewDNSResolver() Resolver {
-	return &DNSResolver{lookupTXT: net.LookupTXT}
+	return &DNSResolver{
+		lookupTXT:   net.LookupTXT,
+		negativeTTL: DefaultNegativeCacheTTL,
+	}
 }
 
 // newDNSResolver constructs a name resolver using DNS TXT records,
-// returning a resolver instead of NewDNSResolver's Resolver.
-func newDNSResolver() resolver {
-	return &DNSResolver{lookupTXT: net.LookupTXT}
+// returning a resolver instead of NewDNSResolver's Resolver. Failed
+// resolutions are cached for negativeTTL; zero disables negative caching.
+func newDNSResolver(negativeTTL time.Duration) resolver {
+	return &DNSResolver{
+		lookupTXT:   net.LookupTXT,
+		negativeTTL: negativeTTL,
+	}
 }
 
 // Resolve implements Resolver.
@@ -56,6 +84,10 @@ func (r *DNSResolver) resolveOnce(ctx context.Context, name string) (path.Path,
 	if !isd.IsDomain(domain) {
 		return "", errors.New("not a valid domain name")
 	}
+	if r.recentlyFailed(domain) {
+		log.Debugf("DNSResolver: %s recently failed to resolve", domain)
+		return "", ErrResolveFailed
+	}
 	log.Infof("DNSResolver resolving %s", domain)
 
 	rootChan := make(chan lookupRes, 1)
@@ -84,6 +116,7 @@ func (r *DNSResolver) resolveOnce(ctx context.Context, name string) (path.Path,
 		if rootRes.error == nil {
 			p = rootRes.path
 		} else {
+			r.setFailed(domain)
 			return "", ErrResolveFailed
 		}
 	}
@@ -94,8 +127,72 @@ func (r *DNSResolver) resolveOnce(ctx context.Context, name string) (path.Path,
 	}
 }
 
+// lookup performs a TXT lookup, joining an identical lookup already in
+// flight instead of starting a new one.
+func (r *DNSResolver) lookup(name string) ([]string, error) {
+	r.lk.Lock()
+	if r.inflight == nil {
+		r.inflight = make(map[string]*txtLookup)
+	}
+	if l, ok := r.inflight[name]; ok {
+		r.lk.Unlock()
+		<-l.done
+		return l.txt, l.err
+	}
+	l := &txtLookup{done: make(chan struct{})}
+	r.inflight[name] = l
+	r.lk.Unlock()
+
+	l.txt, l.err = r.lookupTXT(name)
+
+	r.lk.Lock()
+	delete(r.inflight, name)
+	r.lk.Unlock()
+	close(l.done)
+
+	return l.txt, l.err
+}
+
+// recentlyFailed returns true if domain failed to resolve less than
+// negativeTTL ago.
+func (r *DNSResolver) recentlyFailed(domain string) bool {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	until, ok := r.failed[domain]
+	if !ok {
+		return false
+	}
+	if time.Now().Before(until) {
+		return true
+	}
+	delete(r.failed, domain)
+	return false
+}
+
+func (r *DNSResolver) setFailed(domain string) {
+	if r.negativeTTL <= 0 {
+		return
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if r.failed == nil {
+		r.failed = make(map[string]time.Time)
+	}
+	// drop expired entries so that the map doesn't grow unbounded
+	now := time.Now()
+	for d, until := range r.failed {
+		if now.After(until) {
+			delete(r.failed, d)
+		}
+	}
+	r.failed[domain] = now.Add(r.negativeTTL)
+}
+
 func workDomain(r *DNSResolver, name string, res chan lookupRes) {
-	txt, err := r.lookupTXT(name)
+	txt, err := r.lookup(name)
 
 	if err != nil {
 		// Error is != nil
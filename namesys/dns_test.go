@@ -2,7 +2,9 @@ package namesys
 
 import (
 	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type mockDNS struct {
@@ -158,3 +160,32 @@ func TestDNSResolution(t *testing.T) {
 	testResolution(t, r, "double.example.com", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", nil)
 	testResolution(t, r, "conflict.example.com", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjE", nil)
 }
+
+func TestDNSNegativeCache(t *testing.T) {
+	var lookups int32
+	mock := newMockDNS()
+	r := &DNSResolver{
+		lookupTXT: func(name string) ([]string, error) {
+			atomic.AddInt32(&lookups, 1)
+			return mock.lookupTXT(name)
+		},
+		negativeTTL: time.Minute,
+	}
+
+	for i := 0; i < 3; i++ {
+		testResolution(t, r, "nonexistent.example.com", DefaultDepthLimit, "", ErrResolveFailed)
+	}
+	// one resolution looks up both the domain and its _dnslink subdomain
+	if lookups != 2 {
+		t.Fatalf("expected failure to be cached, got %d lookups", lookups)
+	}
+
+	r.negativeTTL = 0
+	r.failed = nil
+	for i := 0; i < 2; i++ {
+		testResolution(t, r, "nonexistent.example.com", DefaultDepthLimit, "", ErrResolveFailed)
+	}
+	if lookups != 6 {
+		t.Fatalf("expected no negative caching, got %d lookups", lookups)
+	}
+}
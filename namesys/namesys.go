@@ -25,19 +25,31 @@ import (
 type mpns struct {
 	resolvers  map[string]resolver
 	publishers map[string]Publisher
+
+	// cache holds the resolutions of all resolvers. Routing entries are
+	// cached for the TTL of their record, dns entries for
+	// DefaultResolverCacheTTL.
+	cache *resolveCache
 }
 
-// NewNameSystem will construct the IPFS naming system based on Routing
-func NewNameSystem(r routing.ValueStore, ds ds.Datastore, cachesize int) NameSystem {
+// NewNameSystem will construct the IPFS naming system based on Routing.
+// cachesize is the limit of the number of entries in the resolution cache
+// shared by all resolvers. Setting it to '0' will disable caching.
+// negativeTTL is how long dns names that failed to resolve are not looked up
+// again. Setting it to '0' will disable negative caching. opts tune the
+// resolution of ipns names through the routing system.
+func NewNameSystem(r routing.ValueStore, ds ds.Datastore, cachesize int, negativeTTL time.Duration, opts ResolveOptions) NameSystem {
+	cache := newResolveCache(cachesize)
 	return &mpns{
 		resolvers: map[string]resolver{
-			"dns":      newDNSResolver(),
+			"dns":      newDNSResolver(negativeTTL),
 			"proquint": new(ProquintResolver),
-			"dht":      NewRoutingResolver(r, cachesize),
+			"dht":      newRoutingResolver(r, cache, opts),
 		},
 		publishers: map[string]Publisher{
 			"/ipns/": NewRoutingPublisher(r, ds),
 		},
+		cache: cache,
 	}
 }
 
@@ -72,28 +84,58 @@ func (ns *mpns) resolveOnce(ctx context.Context, name string) (path.Path, error)
 		return "", ErrResolveFailed
 	}
 
+	if len(segments) > 3 {
+		// the first component of the rest may be a sub-name delegated to
+		// another key, if the name is a routing name delegating sub-names
+		if r, ok := ns.subNameResolver(segments[2]); ok {
+			return ns.resolveWithSubName(ctx, r, name, segments)
+		}
+	}
+
+	return ns.resolveName(ctx, name, segments)
+}
+
+// resolveName resolves the name in segments, a split /ipns/ path, and
+// appends the rest of the path to its value.
+func (ns *mpns) resolveName(ctx context.Context, name string, segments []string) (path.Path, error) {
+	p, ok := ns.cache.get(segments[2])
+	if ok {
+		log.Debugf("Resolved %s from cache", segments[2])
+		return withRest(p, segments)
+	}
+
 	for protocol, resolver := range ns.resolvers {
 		log.Debugf("Attempting to resolve %s with %s", segments[2], protocol)
 		p, err := resolver.resolveOnce(ctx, segments[2])
 		if err == nil {
-			if len(segments) > 3 {
-				return path.FromSegments("", strings.TrimRight(p.String(), "/"), segments[3])
-			} else {
-				return p, err
+			if protocol == "dns" {
+				// the dht resolver caches with the record's TTL itself,
+				// and proquints are cheap to decode.
+				ns.cache.set(segments[2], p, time.Now().Add(DefaultResolverCacheTTL))
 			}
+			return withRest(p, segments)
 		}
 	}
 	log.Warningf("No resolver found for %s", name)
 	return "", ErrResolveFailed
 }
 
+// withRest appends the path remainder of a split name, if any, to its
+// resolved value.
+func withRest(p path.Path, segments []string) (path.Path, error) {
+	if len(segments) > 3 {
+		return path.FromSegments("", strings.TrimRight(p.String(), "/"), segments[3])
+	}
+	return p, nil
+}
+
 // Publish implements Publisher
 func (ns *mpns) Publish(ctx context.Context, name ci.PrivKey, value path.Path) error {
 	err := ns.publishers["/ipns/"].Publish(ctx, name, value)
 	if err != nil {
 		return err
 	}
-	ns.addToDHTCache(name, value, time.Now().Add(DefaultRecordTTL))
+	ns.updateCache(name, value, time.Now().Add(DefaultRecordTTL), checkCtxSubNames(ctx))
 	return nil
 }
 
@@ -102,39 +144,38 @@ func (ns *mpns) PublishWithEOL(ctx context.Context, name ci.PrivKey, value path.
 	if err != nil {
 		return err
 	}
-	ns.addToDHTCache(name, value, eol)
+	ns.updateCache(name, value, eol, checkCtxSubNames(ctx))
 	return nil
 }
 
-func (ns *mpns) addToDHTCache(key ci.PrivKey, value path.Path, eol time.Time) {
-	rr, ok := ns.resolvers["dht"].(*routingResolver)
-	if !ok {
-		// should never happen, purely for sanity
-		log.Panicf("unexpected type %T as DHT resolver.", ns.resolvers["dht"])
-	}
-	if rr.cache == nil {
-		// resolver has no caching
+// updateCache replaces the cached resolution of the name owned by key with
+// the value just published, so that this node sees its own updates right
+// away instead of once the previous entry expires.
+func (ns *mpns) updateCache(key ci.PrivKey, value path.Path, eol time.Time, subNames bool) {
+	if ns.cache == nil {
+		// no caching
 		return
 	}
 
-	var err error
-	value, err = path.ParsePath(value.String())
+	name, err := peer.IDFromPrivateKey(key)
 	if err != nil {
-		log.Error("could not parse path")
+		log.Error("while adding to cache, could not get peerid from private key")
 		return
 	}
 
-	name, err := peer.IDFromPrivateKey(key)
+	value, err = path.ParsePath(value.String())
 	if err != nil {
-		log.Error("while adding to cache, could not get peerid from private key")
+		log.Error("could not parse path")
+		ns.cache.remove(name.Pretty())
 		return
 	}
 
 	if time.Now().Add(DefaultResolverCacheTTL).Before(eol) {
 		eol = time.Now().Add(DefaultResolverCacheTTL)
 	}
-	rr.cache.Add(name.Pretty(), cacheEntry{
-		val: value,
-		eol: eol,
+	ns.cache.setEntry(name.Pretty(), cacheEntry{
+		val:      value,
+		eol:      eol,
+		subNames: subNames,
 	})
 }
@@ -2,6 +2,7 @@ package namesys
 
 import (
 	"fmt"
+	"sync/atomic"
 	"testing"
 
 	context "context"
@@ -12,6 +13,7 @@ import (
 
 	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
 	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
 )
 
 type mockResolver struct {
@@ -83,10 +85,70 @@ func TestPublishWithCache0(t *testing.T) {
 	}
 	routing := offroute.NewOfflineRouter(dst, priv)
 
-	nsys := NewNameSystem(routing, dst, 0)
+	nsys := NewNameSystem(routing, dst, 0, 0, ResolveOptions{})
 	p, err := path.ParsePath(unixfs.EmptyDirNode().Cid().String())
 	if err != nil {
 		t.Fatal(err)
 	}
 	nsys.Publish(context.Background(), priv, p)
 }
+
+func TestResolutionCache(t *testing.T) {
+	var lookups int32
+	dns := &mockDNS{
+		entries: map[string][]string{
+			"_dnslink.example.com": []string{"dnslink=/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD"},
+		},
+	}
+	r := &mpns{
+		resolvers: map[string]resolver{
+			"dns": &DNSResolver{lookupTXT: func(name string) ([]string, error) {
+				atomic.AddInt32(&lookups, 1)
+				return dns.lookupTXT(name)
+			}},
+		},
+		cache: newResolveCache(10),
+	}
+
+	for i := 0; i < 3; i++ {
+		testResolution(t, r, "/ipns/example.com/foo", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD/foo", nil)
+	}
+	// one resolution looks up both example.com and _dnslink.example.com
+	if lookups > 2 {
+		t.Fatalf("expected cached resolutions, got %d lookups", lookups)
+	}
+
+	r.cache.remove("example.com")
+	testResolution(t, r, "/ipns/example.com", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", nil)
+	if lookups <= 2 {
+		t.Fatal("expected a new lookup after invalidation")
+	}
+}
+
+func TestPublishUpdatesCache(t *testing.T) {
+	dst := ds.NewMapDatastore()
+	priv, _, err := ci.GenerateKeyPair(ci.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routing := offroute.NewOfflineRouter(dst, priv)
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nsys := NewNameSystem(routing, dst, 128, 0, ResolveOptions{})
+	ctx := context.Background()
+
+	p1 := path.FromCid(unixfs.EmptyDirNode().Cid())
+	if err := nsys.Publish(ctx, priv, p1); err != nil {
+		t.Fatal(err)
+	}
+	testResolution(t, nsys, "/ipns/"+id.Pretty(), DefaultDepthLimit, p1.String(), nil)
+
+	p2 := path.Path("/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD")
+	if err := nsys.Publish(ctx, priv, p2); err != nil {
+		t.Fatal(err)
+	}
+	testResolution(t, nsys, "/ipns/"+id.Pretty(), DefaultDepthLimit, p2.String(), nil)
+}
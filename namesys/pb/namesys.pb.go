@@ -10,6 +10,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	IpnsEntry
+	IpnsDelegation
 */
 package namesys_pb
 
@@ -25,13 +26,18 @@ type IpnsEntry_ValidityType int32
 const (
 	// setting an EOL says "this record is valid until..."
 	IpnsEntry_EOL IpnsEntry_ValidityType = 0
+	// a perpetual record is valid until replaced by one with a higher
+	// sequence number. Nodes predating it reject it.
+	IpnsEntry_PERPETUAL IpnsEntry_ValidityType = 1
 )
 
 var IpnsEntry_ValidityType_name = map[int32]string{
 	0: "EOL",
+	1: "PERPETUAL",
 }
 var IpnsEntry_ValidityType_value = map[string]int32{
-	"EOL": 0,
+	"EOL":       0,
+	"PERPETUAL": 1,
 }
 
 func (x IpnsEntry_ValidityType) Enum() *IpnsEntry_ValidityType {
@@ -58,6 +64,8 @@ type IpnsEntry struct {
 	Validity         []byte                  `protobuf:"bytes,4,opt,name=validity" json:"validity,omitempty"`
 	Sequence         *uint64                 `protobuf:"varint,5,opt,name=sequence" json:"sequence,omitempty"`
 	Ttl              *uint64                 `protobuf:"varint,6,opt,name=ttl" json:"ttl,omitempty"`
+	Delegation       *IpnsDelegation         `protobuf:"bytes,7,opt,name=delegation" json:"delegation,omitempty"`
+	SubNames         *bool                   `protobuf:"varint,8,opt,name=subNames" json:"subNames,omitempty"`
 	XXX_unrecognized []byte                  `json:"-"`
 }
 
@@ -107,6 +115,68 @@ func (m *IpnsEntry) GetTtl() uint64 {
 	return 0
 }
 
+func (m *IpnsEntry) GetDelegation() *IpnsDelegation {
+	if m != nil {
+		return m.Delegation
+	}
+	return nil
+}
+
+func (m *IpnsEntry) GetSubNames() bool {
+	if m != nil && m.SubNames != nil {
+		return *m.SubNames
+	}
+	return false
+}
+
+type IpnsDelegation struct {
+	Name             *string `protobuf:"bytes,1,req,name=name" json:"name,omitempty"`
+	ParentKey        []byte  `protobuf:"bytes,2,req,name=parentKey" json:"parentKey,omitempty"`
+	DelegateKey      []byte  `protobuf:"bytes,3,req,name=delegateKey" json:"delegateKey,omitempty"`
+	Validity         []byte  `protobuf:"bytes,4,req,name=validity" json:"validity,omitempty"`
+	Signature        []byte  `protobuf:"bytes,5,req,name=signature" json:"signature,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *IpnsDelegation) Reset()         { *m = IpnsDelegation{} }
+func (m *IpnsDelegation) String() string { return proto.CompactTextString(m) }
+func (*IpnsDelegation) ProtoMessage()    {}
+
+func (m *IpnsDelegation) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *IpnsDelegation) GetParentKey() []byte {
+	if m != nil {
+		return m.ParentKey
+	}
+	return nil
+}
+
+func (m *IpnsDelegation) GetDelegateKey() []byte {
+	if m != nil {
+		return m.DelegateKey
+	}
+	return nil
+}
+
+func (m *IpnsDelegation) GetValidity() []byte {
+	if m != nil {
+		return m.Validity
+	}
+	return nil
+}
+
+func (m *IpnsDelegation) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("namesys.pb.IpnsEntry_ValidityType", IpnsEntry_ValidityType_name, IpnsEntry_ValidityType_value)
 }
@@ -69,15 +69,51 @@ func (p *ipnsPublisher) PublishWithEOL(ctx context.Context, k ci.PrivKey, value
 	_, ipnskey := IpnsKeysForID(id)
 
 	// get previous records sequence number
-	seqnum, err := p.getPreviousSeqNo(ctx, ipnskey)
+	seqnum, err := p.nextSeqNo(ctx, ipnskey, id.Pretty())
+	if err != nil {
+		return err
+	}
+
+	err = PutRecordToRouting(ctx, k, value, seqnum, eol, p.routing, id)
 	if err != nil {
 		return err
 	}
+	return p.putState(ctx, id.Pretty(), seqnum, value, eol)
+}
+
+// nextSeqNo returns the sequence number of the next record of name, with the
+// given routing key: one more than the last record found locally, on the
+// network, or in the publish state of the name.
+func (p *ipnsPublisher) nextSeqNo(ctx context.Context, ipnskey, name string) (uint64, error) {
+	seqnum, err := p.getPreviousSeqNo(ctx, ipnskey)
+	if err != nil {
+		return 0, err
+	}
+
+	st, err := GetPublishState(p.ds, name)
+	if err != nil {
+		return 0, err
+	}
+	if st != nil && st.Sequence > seqnum {
+		seqnum = st.Sequence
+	}
 
 	// increment it
-	seqnum++
+	return seqnum + 1, nil
+}
 
-	return PutRecordToRouting(ctx, k, value, seqnum, eol, p.routing, id)
+// putState records the publish state of name after a record was published.
+func (p *ipnsPublisher) putState(ctx context.Context, name string, seqnum uint64, value path.Path, eol time.Time) error {
+	compat := !checkCtxPerpetual(ctx) && eol.Equal(PerpetualEOL)
+	return PutPublishState(p.ds, &PublishState{
+		Name:      name,
+		Sequence:  seqnum,
+		Value:     value,
+		Perpetual: checkCtxPerpetual(ctx) || compat,
+		V1Compat:  compat,
+		SubNames:  checkCtxSubNames(ctx),
+		Published: time.Now(),
+	})
 }
 
 func (p *ipnsPublisher) getPreviousSeqNo(ctx context.Context, ipnskey string) (uint64, error) {
@@ -135,12 +171,30 @@ func checkCtxTTL(ctx context.Context) (time.Duration, bool) {
 	return d, ok
 }
 
+// checkCtxPerpetual returns whether to publish a perpetual record, instead
+// of one valid until its EOL. Like the TTL, it is wired through the context.
+func checkCtxPerpetual(ctx context.Context) bool {
+	v, _ := ctx.Value("ipns-publish-perpetual").(bool)
+	return v
+}
+
+// checkCtxSubNames returns whether the record lets the delegates of the
+// sub-names of the name publish them, wired through the context as well.
+func checkCtxSubNames(ctx context.Context) bool {
+	v, _ := ctx.Value("ipns-publish-subnames").(bool)
+	return v
+}
+
 func PutRecordToRouting(ctx context.Context, k ci.PrivKey, value path.Path, seqnum uint64, eol time.Time, r routing.ValueStore, id peer.ID) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	namekey, ipnskey := IpnsKeysForID(id)
-	entry, err := CreateRoutingEntryData(k, value, seqnum, eol)
+	typ := pb.IpnsEntry_EOL
+	if checkCtxPerpetual(ctx) {
+		typ = pb.IpnsEntry_PERPETUAL
+	}
+	entry, err := createRoutingEntry(k, value, seqnum, typ, eol)
 	if err != nil {
 		return err
 	}
@@ -149,6 +203,9 @@ func PutRecordToRouting(ctx context.Context, k ci.PrivKey, value path.Path, seqn
 	if ok {
 		entry.Ttl = proto.Uint64(uint64(ttl.Nanoseconds()))
 	}
+	if checkCtxSubNames(ctx) {
+		entry.SubNames = proto.Bool(true)
+	}
 
 	errs := make(chan error, 2)
 
@@ -219,13 +276,20 @@ func PublishEntry(ctx context.Context, r routing.ValueStore, ipnskey string, rec
 }
 
 func CreateRoutingEntryData(pk ci.PrivKey, val path.Path, seq uint64, eol time.Time) (*pb.IpnsEntry, error) {
+	return createRoutingEntry(pk, val, seq, pb.IpnsEntry_EOL, eol)
+}
+
+// createRoutingEntry creates an entry with the given validity type, eol is
+// ignored for perpetual entries.
+func createRoutingEntry(pk ci.PrivKey, val path.Path, seq uint64, typ pb.IpnsEntry_ValidityType, eol time.Time) (*pb.IpnsEntry, error) {
 	entry := new(pb.IpnsEntry)
 
 	entry.Value = []byte(val)
-	typ := pb.IpnsEntry_EOL
 	entry.ValidityType = &typ
 	entry.Sequence = proto.Uint64(seq)
-	entry.Validity = []byte(u.FormatRFC3339(eol))
+	if typ == pb.IpnsEntry_EOL {
+		entry.Validity = []byte(u.FormatRFC3339(eol))
+	}
 
 	sig, err := pk.Sign(ipnsEntryDataForSig(entry))
 	if err != nil {
@@ -277,13 +341,13 @@ func selectRecord(recs []*pb.IpnsEntry, vals [][]byte) (int, error) {
 			best_seq = r.GetSequence()
 			best_i = i
 		} else if r.GetSequence() == best_seq {
-			rt, err := u.ParseRFC3339(string(r.GetValidity()))
-			if err != nil {
+			rt, ok := recordEOL(r)
+			if !ok {
 				continue
 			}
 
-			bestt, err := u.ParseRFC3339(string(recs[best_i].GetValidity()))
-			if err != nil {
+			bestt, ok := recordEOL(recs[best_i])
+			if !ok {
 				continue
 			}
 
@@ -303,6 +367,22 @@ func selectRecord(recs []*pb.IpnsEntry, vals [][]byte) (int, error) {
 	return best_i, nil
 }
 
+// PerpetualEOL stands for the end of life of perpetual records. Perpetual
+// records are published as valid until then for nodes which predate them.
+var PerpetualEOL = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// recordEOL returns the end of life of e, ok is false if it can't be parsed.
+func recordEOL(e *pb.IpnsEntry) (time.Time, bool) {
+	if e.GetValidityType() == pb.IpnsEntry_PERPETUAL {
+		return PerpetualEOL, true
+	}
+	t, err := u.ParseRFC3339(string(e.GetValidity()))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
 // ValidateIpnsRecord implements ValidatorFunc and verifies that the
 // given 'val' is an IpnsEntry and that that entry is valid.
 func ValidateIpnsRecord(k string, val []byte) error {
@@ -311,6 +391,13 @@ func ValidateIpnsRecord(k string, val []byte) error {
 	if err != nil {
 		return err
 	}
+	if parent, name, ok := splitSubNameKey(k); ok {
+		// sub-name entries carry all the keys needed to check them, which
+		// keeps anyone but the delegate from taking the sub-name over
+		if err := validateDelegatedEntry(parent, name, entry); err != nil {
+			return err
+		}
+	}
 	switch entry.GetValidityType() {
 	case pb.IpnsEntry_EOL:
 		t, err := u.ParseRFC3339(string(entry.GetValidity()))
@@ -321,6 +408,7 @@ func ValidateIpnsRecord(k string, val []byte) error {
 		if time.Now().After(t) {
 			return ErrExpiredRecord
 		}
+	case pb.IpnsEntry_PERPETUAL:
 	default:
 		return ErrUnrecognizedValidity
 	}
@@ -363,3 +451,10 @@ func IpnsKeysForID(id peer.ID) (name, ipns string) {
 
 	return namekey, ipnskey
 }
+
+// IpnsDsKey returns the key of the last IPNS record of id in the local
+// datastore.
+func IpnsDsKey(id peer.ID) ds.Key {
+	_, ipnskey := IpnsKeysForID(id)
+	return dshelp.NewKeyFromBinary([]byte(ipnskey))
+}
@@ -17,6 +17,7 @@ import (
 	gpctx "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess/context"
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
 	recpb "gx/ipfs/QmWYCqr6UDqqD1bfRybaAPtbAqcN3TSJpveaBXMwbQ3ePZ/go-libp2p-record/pb"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
 	routing "gx/ipfs/QmafuecpeZp3k3sHJ5mUARHd4795revuadECQMkmHB8LfW/go-libp2p-routing"
 	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
@@ -88,17 +89,37 @@ func (rp *Republisher) republishEntries(p goprocess.Process) error {
 
 		// Look for it locally only
 		_, ipnskey := namesys.IpnsKeysForID(id)
-		p, seq, err := rp.getLastVal(ipnskey)
+		p, seq, perpetual, compat, subNames, err := rp.getLastVal(ipnskey)
+		if err != nil && err != errNoEntry {
+			return err
+		}
+
+		// the publish state outlives the record, and may have been
+		// imported from another node along with the key
+		st, err := namesys.GetPublishState(rp.ds, id.Pretty())
 		if err != nil {
-			if err == errNoEntry {
-				continue
-			}
 			return err
 		}
+		if st != nil && (p == "" || st.Sequence > seq) {
+			p, seq, perpetual, compat, subNames = st.Value, st.Sequence, st.Perpetual, st.V1Compat, st.SubNames
+		}
+		if p == "" {
+			continue
+		}
 
 		// update record with same sequence number
 		eol := time.Now().Add(rp.RecordLifetime)
-		err = namesys.PutRecordToRouting(ctx, priv, p, seq, eol, rp.r, id)
+		pctx := ctx
+		if perpetual {
+			eol = namesys.PerpetualEOL
+			if !compat {
+				pctx = context.WithValue(ctx, "ipns-publish-perpetual", true)
+			}
+		}
+		if subNames {
+			pctx = context.WithValue(pctx, "ipns-publish-subnames", true)
+		}
+		err = namesys.PutRecordToRouting(pctx, priv, p, seq, eol, rp.r, id)
 		if err != nil {
 			return err
 		}
@@ -107,25 +128,37 @@ func (rp *Republisher) republishEntries(p goprocess.Process) error {
 	return nil
 }
 
-func (rp *Republisher) getLastVal(k string) (path.Path, uint64, error) {
+// getLastVal returns the value and sequence number of the last record
+// published with the routing key k, whether it's perpetual, and if so,
+// whether it was published in the form older nodes accept, and whether it
+// delegates sub-names.
+func (rp *Republisher) getLastVal(k string) (p path.Path, seq uint64, perpetual, compat, subNames bool, err error) {
 	ival, err := rp.ds.Get(dshelp.NewKeyFromBinary([]byte(k)))
 	if err != nil {
 		// not found means we dont have a previously published entry
-		return "", 0, errNoEntry
+		return "", 0, false, false, false, errNoEntry
 	}
 
 	val := ival.([]byte)
 	dhtrec := new(recpb.Record)
 	err = proto.Unmarshal(val, dhtrec)
 	if err != nil {
-		return "", 0, err
+		return "", 0, false, false, false, err
 	}
 
 	// extract published data from record
 	e := new(pb.IpnsEntry)
 	err = proto.Unmarshal(dhtrec.GetValue(), e)
 	if err != nil {
-		return "", 0, err
+		return "", 0, false, false, false, err
+	}
+
+	switch e.GetValidityType() {
+	case pb.IpnsEntry_PERPETUAL:
+		perpetual = true
+	case pb.IpnsEntry_EOL:
+		compat = string(e.GetValidity()) == u.FormatRFC3339(namesys.PerpetualEOL)
+		perpetual = compat
 	}
-	return path.Path(e.Value), e.GetSequence(), nil
+	return path.Path(e.Value), e.GetSequence(), perpetual, compat, e.GetSubNames(), nil
 }
@@ -50,6 +50,41 @@ func TestRoutingResolve(t *testing.T) {
 	}
 }
 
+func TestRoutingResolveQuorum(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	serv := mockrouting.NewServer()
+	id := testutil.RandIdentityOrFatal(t)
+	d := serv.ClientWithDatastore(context.Background(), id, dstore)
+
+	opts := ResolveOptions{Quorum: 1, Timeout: 10 * time.Second}
+	resolver := newRoutingResolver(d, nil, opts)
+	publisher := NewRoutingPublisher(d, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	err = publisher.Publish(context.Background(), privk, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := resolver.Resolve(context.Background(), pid.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != h {
+		t.Fatal("Got back incorrect value.")
+	}
+}
+
 func TestPrexistingExpiredRecord(t *testing.T) {
 	dstore := dssync.MutexWrap(ds.NewMapDatastore())
 	d := mockrouting.NewServer().ClientWithDatastore(context.Background(), testutil.RandIdentityOrFatal(t), dstore)
@@ -12,7 +12,6 @@ import (
 	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
 	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
-	lru "gx/ipfs/QmVYxfoJQiZijTgPNHCHgHELvQpbsJNTg6Crmc3dQkj3yy/golang-lru"
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
@@ -24,40 +23,23 @@ var log = logging.Logger("namesys")
 // routingResolver implements NSResolver for the main IPFS SFS-like naming
 type routingResolver struct {
 	routing routing.ValueStore
+	opts    ResolveOptions
 
-	cache *lru.Cache
+	cache *resolveCache
 }
 
-func (r *routingResolver) cacheGet(name string) (path.Path, bool) {
-	if r.cache == nil {
-		return "", false
-	}
-
-	ientry, ok := r.cache.Get(name)
-	if !ok {
-		return "", false
-	}
-
-	entry, ok := ientry.(cacheEntry)
-	if !ok {
-		// should never happen, purely for sanity
-		log.Panicf("unexpected type %T in cache for %q.", ientry, name)
-	}
+// ResolveOptions tune how ipns records are read from the routing system.
+type ResolveOptions struct {
+	// Quorum is how many records are collected before picking the best
+	// one, the default of the routing system if 0. Small networks may never
+	// reach the default, and wait for the timeout on every resolution.
+	Quorum int
 
-	if time.Now().Before(entry.eol) {
-		return entry.val, true
-	}
-
-	r.cache.Remove(name)
-
-	return "", false
+	// Timeout bounds each resolution, none if 0.
+	Timeout time.Duration
 }
 
 func (r *routingResolver) cacheSet(name string, val path.Path, rec *pb.IpnsEntry) {
-	if r.cache == nil {
-		return
-	}
-
 	// if completely unspecified, just use one minute
 	ttl := DefaultResolverCacheTTL
 	if rec.Ttl != nil {
@@ -73,37 +55,73 @@ func (r *routingResolver) cacheSet(name string, val path.Path, rec *pb.IpnsEntry
 		cacheTil = eol
 	}
 
-	r.cache.Add(name, cacheEntry{
-		val: val,
-		eol: cacheTil,
+	r.cache.setEntry(name, cacheEntry{
+		val:      val,
+		eol:      cacheTil,
+		subNames: rec.GetSubNames(),
 	})
 }
 
-type cacheEntry struct {
-	val path.Path
-	eol time.Time
-}
-
 // NewRoutingResolver constructs a name resolver using the IPFS Routing system
 // to implement SFS-like naming on top.
 // cachesize is the limit of the number of entries in the lru cache. Setting it
 // to '0' will disable caching.
 func NewRoutingResolver(route routing.ValueStore, cachesize int) *routingResolver {
+	return newRoutingResolver(route, newResolveCache(cachesize), ResolveOptions{})
+}
+
+// newRoutingResolver constructs a routing resolver storing its resolutions
+// in the given (possibly shared, possibly nil) cache.
+func newRoutingResolver(route routing.ValueStore, cache *resolveCache, opts ResolveOptions) *routingResolver {
 	if route == nil {
 		panic("attempt to create resolver with nil routing system")
 	}
 
-	var cache *lru.Cache
-	if cachesize > 0 {
-		cache, _ = lru.New(cachesize)
-	}
-
 	return &routingResolver{
 		routing: route,
+		opts:    opts,
 		cache:   cache,
 	}
 }
 
+// withTimeout bounds ctx by the resolution timeout, if set.
+func (r *routingResolver) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.opts.Timeout > 0 {
+		return context.WithTimeout(ctx, r.opts.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// getValue gets the best record of key out of the quorum, or the default
+// number of records of the routing system.
+func (r *routingResolver) getValue(ctx context.Context, key string) ([]byte, error) {
+	if r.opts.Quorum <= 0 {
+		return r.routing.GetValue(ctx, key)
+	}
+
+	// the records found so far are returned when the query ends early
+	vals, err := r.routing.GetValues(ctx, key, r.opts.Quorum)
+	if err != nil {
+		return nil, err
+	}
+
+	recs := make([][]byte, 0, len(vals))
+	for _, v := range vals {
+		if v.Val != nil {
+			recs = append(recs, v.Val)
+		}
+	}
+	if len(recs) == 0 {
+		return nil, routing.ErrNotFound
+	}
+
+	i, err := IpnsSelectorFunc(key, recs)
+	if err != nil {
+		return nil, err
+	}
+	return recs[i], nil
+}
+
 // Resolve implements Resolver.
 func (r *routingResolver) Resolve(ctx context.Context, name string) (path.Path, error) {
 	return r.ResolveN(ctx, name, DefaultDepthLimit)
@@ -117,10 +135,17 @@ func (r *routingResolver) ResolveN(ctx context.Context, name string, depth int)
 // resolveOnce implements resolver. Uses the IPFS routing system to
 // resolve SFS-like names.
 func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Path, error) {
+	p, _, err := r.resolveEntry(ctx, name)
+	return p, err
+}
+
+// resolveEntry resolves name like resolveOnce, and also returns whether its
+// record delegates sub-names.
+func (r *routingResolver) resolveEntry(ctx context.Context, name string) (path.Path, bool, error) {
 	log.Debugf("RoutingResolve: '%s'", name)
-	cached, ok := r.cacheGet(name)
+	cached, ok := r.cache.getEntry(name)
 	if ok {
-		return cached, nil
+		return cached.val, cached.subNames, nil
 	}
 
 	name = strings.TrimPrefix(name, "/ipns/")
@@ -128,9 +153,12 @@ func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Pa
 	if err != nil {
 		// name should be a multihash. if it isn't, error out here.
 		log.Warningf("RoutingResolve: bad input hash: [%s]\n", name)
-		return "", err
+		return "", false, err
 	}
 
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	// use the routing system to get the name.
 	// /ipns/<name>
 	h := []byte("/ipns/" + string(hash))
@@ -141,7 +169,7 @@ func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Pa
 	resp := make(chan error, 2)
 	go func() {
 		ipnsKey := string(h)
-		val, err := r.routing.GetValue(ctx, ipnsKey)
+		val, err := r.getValue(ctx, ipnsKey)
 		if err != nil {
 			log.Warning("RoutingResolve get failed.")
 			resp <- err
@@ -173,13 +201,13 @@ func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Pa
 	for i := 0; i < 2; i++ {
 		err = <-resp
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
 	}
 
 	// check sig with pk
 	if ok, err := pubkey.Verify(ipnsEntryDataForSig(entry), entry.GetSignature()); err != nil || !ok {
-		return "", fmt.Errorf("Invalid value. Not signed by PrivateKey corresponding to %v", pubkey)
+		return "", false, fmt.Errorf("Invalid value. Not signed by PrivateKey corresponding to %v", pubkey)
 	}
 
 	// ok sig checks out. this is a valid name.
@@ -190,17 +218,17 @@ func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Pa
 		// Not a multihash, probably a new record
 		p, err := path.ParsePath(string(entry.GetValue()))
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
 
 		r.cacheSet(name, p, entry)
-		return p, nil
+		return p, entry.GetSubNames(), nil
 	} else {
 		// Its an old style multihash record
 		log.Warning("Detected old style multihash record")
 		p := path.FromCid(cid.NewCidV0(valh))
 		r.cacheSet(name, p, entry)
-		return p, nil
+		return p, entry.GetSubNames(), nil
 	}
 }
 
@@ -0,0 +1,75 @@
+package namesys
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	path "github.com/ipfs/go-ipfs/path"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+)
+
+// PublishState is what a node remembers of the last record it published
+// for a name. Unlike the record itself, which the routing system may drop,
+// it is kept for good, so that the next record of the name gets a higher
+// sequence number, and it can be moved along with the key to another node.
+// Records with a lower sequence number than one already published are
+// ignored, which would leave the name stuck on its old value.
+type PublishState struct {
+	// Name is the base58 peer ID of the name, followed by /<sub-name> for
+	// delegated sub-names.
+	Name      string
+	Sequence  uint64
+	Value     path.Path
+	Perpetual bool `json:",omitempty"`
+	// V1Compat is set for perpetual names published as records valid
+	// until PerpetualEOL, which nodes predating perpetual records accept.
+	V1Compat bool `json:",omitempty"`
+	// SubNames is set for names letting the delegates of their sub-names
+	// publish them.
+	SubNames  bool `json:",omitempty"`
+	Published time.Time
+}
+
+var publishStatePrefix = ds.NewKey("/local/ipns/state")
+
+// GetPublishState returns the publish state of name, nil if there's none.
+func GetPublishState(d ds.Datastore, name string) (*PublishState, error) {
+	v, err := d.Get(publishStatePrefix.ChildString(name))
+	if err == ds.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type returned from datastore: %#v", v)
+	}
+	st := new(PublishState)
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// PutPublishState stores st, unless the stored state of its name has a
+// higher sequence number, so that importing an old state never makes the
+// sequence go back.
+func PutPublishState(d ds.Datastore, st *PublishState) error {
+	prev, err := GetPublishState(d, st.Name)
+	if err != nil {
+		return err
+	}
+	if prev != nil && prev.Sequence > st.Sequence {
+		return nil
+	}
+
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return d.Put(publishStatePrefix.ChildString(st.Name), b)
+}
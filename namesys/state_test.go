@@ -0,0 +1,107 @@
+package namesys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
+	path "github.com/ipfs/go-ipfs/path"
+	offroute "github.com/ipfs/go-ipfs/routing/offline"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestSequenceFromImportedState(t *testing.T) {
+	ctx := context.Background()
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a node which never saw the records published from the old one
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	publisher := NewRoutingPublisher(offroute.NewOfflineRouter(dstore, privk), dstore)
+
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	err = PutPublishState(dstore, &PublishState{Name: id.Pretty(), Sequence: 41, Value: h})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an older state doesn't lower the sequence
+	err = PutPublishState(dstore, &PublishState{Name: id.Pretty(), Sequence: 3, Value: h})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := publisher.Publish(ctx, privk, h); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := GetPublishState(dstore, id.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st == nil || st.Sequence != 42 {
+		t.Fatalf("expected the publish state to be at sequence 42, got %#v", st)
+	}
+
+	_, ipnskey := IpnsKeysForID(id)
+	seq, err := publisher.getPreviousSeqNo(ctx, ipnskey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 42 {
+		t.Fatalf("expected the record to have sequence 42, got %d", seq)
+	}
+}
+
+func TestPerpetualRecord(t *testing.T) {
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ipnskey := IpnsKeysForID(id)
+
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	e, err := createRoutingEntry(privk, h, 1, pb.IpnsEntry_PERPETUAL, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proto.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateIpnsRecord(ipnskey, data); err != nil {
+		t.Fatalf("expected a valid perpetual record, got: %s", err)
+	}
+
+	// a newer record expiring soon still wins over a perpetual one
+	e2, err := CreateRoutingEntryData(privk, h, 2, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data2, err := proto.Marshal(e2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := selectRecord([]*pb.IpnsEntry{e, e2}, [][]byte{data, data2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != 1 {
+		t.Fatal("expected the record with the higher sequence to be selected")
+	}
+}
@@ -74,6 +74,20 @@ func SplitAbsPath(fpath Path) (*cid.Cid, []string, error) {
 }
 
 func (r *Resolver) ResolveToLastNode(ctx context.Context, fpath Path) (node.Node, []string, error) {
+	nodes, rest, err := r.ResolveBlocks(ctx, fpath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nodes[len(nodes)-1], rest, nil
+}
+
+// ResolveBlocks walks the given path through the IPLD graph, crossing block
+// boundaries whenever the path hits a link. It returns every block that was
+// traversed (starting with the root) along with the part of the path which
+// remains to be resolved inside the last block. The returned list of nodes is
+// never empty when err is nil.
+func (r *Resolver) ResolveBlocks(ctx context.Context, fpath Path) ([]node.Node, []string, error) {
 	c, p, err := SplitAbsPath(fpath)
 	if err != nil {
 		return nil, nil, err
@@ -84,6 +98,7 @@ func (r *Resolver) ResolveToLastNode(ctx context.Context, fpath Path) (node.Node
 		return nil, nil, err
 	}
 
+	nodes := []node.Node{nd}
 	for len(p) > 0 {
 		val, rest, err := nd.Resolve(p)
 		if err != nil {
@@ -97,13 +112,14 @@ func (r *Resolver) ResolveToLastNode(ctx context.Context, fpath Path) (node.Node
 				return nil, nil, err
 			}
 			nd = next
+			nodes = append(nodes, nd)
 			p = rest
 		default:
-			return nd, p, nil
+			return nodes, p, nil
 		}
 	}
 
-	return nd, nil, nil
+	return nodes, nil, nil
 }
 
 // ResolvePath fetches the node for given path. It returns the last item
@@ -67,3 +67,44 @@ func TestRecurivePathResolution(t *testing.T) {
 			p.String(), key.String(), cKey.String()))
 	}
 }
+
+func TestResolveBlocks(t *testing.T) {
+	ctx := context.Background()
+	dagService := dagmock.Mock()
+
+	a := randNode()
+	b := randNode()
+
+	err := a.AddNodeLink("child", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []node.Node{a, b} {
+		_, err = dagService.Add(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := path.FromSegments("/ipfs/", a.Cid().String(), "child")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := path.NewBasicResolver(dagService)
+	nodes, rest, err := resolver.ResolveBlocks(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 blocks, got %d", len(nodes))
+	}
+	if !nodes[0].Cid().Equals(a.Cid()) || !nodes[1].Cid().Equals(b.Cid()) {
+		t.Fatal("traversed blocks do not match the path")
+	}
+	if len(rest) != 0 {
+		t.Fatalf("unexpected remainder path: %v", rest)
+	}
+}
@@ -0,0 +1,108 @@
+package pin
+
+import (
+	"fmt"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	dshelp "github.com/ipfs/go-ipfs/thirdparty/ds-help"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+)
+
+// brokenPinsKey is where SetAsideBrokenPins keeps the value of the root of
+// the pins stored as a dag, so that they can still be looked into later.
+var brokenPinsKey = ds.NewKey("/local/pins.broken")
+
+// brokenPinStorePrefix is where SetAsideBrokenPins moves the keys of the pin
+// store which aren't pins, under their own key.
+var brokenPinStorePrefix = ds.NewKey("/local/pinstore.broken")
+
+// CheckPins checks that the pins can be loaded: the pins stored as a dag by
+// earlier versions, if any, as LoadPinner fails if they can't, and the keys
+// of the pin store, as the pins of the keys which aren't pins are lost.
+func CheckPins(d ds.Datastore, internal mdag.DAGService) error {
+	if _, _, _, err := loadLegacyPins(d, internal); err != nil {
+		return err
+	}
+
+	broken, err := brokenPinStoreKeys(d)
+	if err != nil {
+		return fmt.Errorf("cannot list the pins: %v", err)
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("%d keys of the pin store are not pins, such as %s", len(broken), broken[0])
+	}
+	return nil
+}
+
+// SetAsideBrokenPins moves the root of the pins stored as a dag by earlier
+// versions out of the way of LoadPinner, and the keys of the pin store which
+// aren't pins out of the store, so that a node can be loaded when they are
+// broken. Their pins are lost: they have to be pinned again.
+func SetAsideBrokenPins(d ds.Datastore) error {
+	broken, err := brokenPinStoreKeys(d)
+	if err != nil {
+		return err
+	}
+	for _, k := range broken {
+		val, err := d.Get(k)
+		if err != nil {
+			return err
+		}
+		if err := d.Put(brokenPinStorePrefix.Child(k), val); err != nil {
+			return err
+		}
+		if err := d.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	val, err := d.Get(pinDatastoreKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+
+	if err := d.Put(brokenPinsKey, val); err != nil {
+		return err
+	}
+	return d.Delete(pinDatastoreKey)
+}
+
+// brokenPinStoreKeys lists the keys of the pin store which aren't the key of
+// a recursive or direct pin.
+func brokenPinStoreKeys(d ds.Datastore) ([]ds.Key, error) {
+	res, err := d.Query(dsq.Query{Prefix: pinStorePrefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	recursive := pinStorePrefix.ChildString(linkRecursive)
+	direct := pinStorePrefix.ChildString(linkDirect)
+
+	var broken []ds.Key
+	for {
+		e, ok := res.NextSync()
+		if !ok {
+			break
+		}
+		if e.Error != nil {
+			return nil, e.Error
+		}
+
+		k := ds.RawKey(e.Key)
+		if parent := k.Parent(); !parent.Equal(recursive) && !parent.Equal(direct) {
+			broken = append(broken, k)
+			continue
+		}
+		if _, err := dshelp.DsKeyToCid(ds.NewKey(k.BaseNamespace())); err != nil {
+			broken = append(broken, k)
+		}
+	}
+	return broken, nil
+}
@@ -0,0 +1,152 @@
+package pin
+
+import (
+	dshelp "github.com/ipfs/go-ipfs/thirdparty/ds-help"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// pinStorePrefix is where the pins are stored: one key per pin, under the
+// prefix of its mode, such as /local/pinstore/recursive/<cid>. Adding or
+// removing a pin touches its key only, and the pins of a mode are listed by
+// a query on its prefix.
+var pinStorePrefix = ds.NewKey("/local/pinstore")
+
+// dsSet is a set of pins stored in the datastore. The changes are kept in
+// memory until they are flushed, as the pins used to be.
+type dsSet struct {
+	dstore ds.Datastore
+	prefix ds.Key
+
+	// changes not flushed yet
+	added   *cid.Set
+	removed *cid.Set
+}
+
+func newDsSet(d ds.Datastore, mode string) *dsSet {
+	return &dsSet{
+		dstore:  d,
+		prefix:  pinStorePrefix.ChildString(mode),
+		added:   cid.NewSet(),
+		removed: cid.NewSet(),
+	}
+}
+
+func (s *dsSet) key(c *cid.Cid) ds.Key {
+	return s.prefix.Child(dshelp.CidToDsKey(c))
+}
+
+func (s *dsSet) Has(c *cid.Cid) (bool, error) {
+	if s.added.Has(c) {
+		return true, nil
+	}
+	if s.removed.Has(c) {
+		return false, nil
+	}
+	return s.dstore.Has(s.key(c))
+}
+
+func (s *dsSet) Add(c *cid.Cid) {
+	s.removed.Remove(c)
+	s.added.Add(c)
+}
+
+func (s *dsSet) Remove(c *cid.Cid) {
+	s.added.Remove(c)
+	s.removed.Add(c)
+}
+
+// Keys lists the pins of the set, the ones not flushed yet included.
+func (s *dsSet) Keys() ([]*cid.Cid, error) {
+	res, err := s.dstore.Query(dsq.Query{Prefix: s.prefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	seen := cid.NewSet()
+	var out []*cid.Cid
+	for {
+		e, ok := res.NextSync()
+		if !ok {
+			break
+		}
+		if e.Error != nil {
+			return nil, e.Error
+		}
+
+		c, err := dshelp.DsKeyToCid(ds.NewKey(ds.RawKey(e.Key).BaseNamespace()))
+		if err != nil {
+			log.Warningf("pin: bad key %s: %s", e.Key, err)
+			continue
+		}
+		if !s.removed.Has(c) && seen.Visit(c) {
+			out = append(out, c)
+		}
+	}
+
+	for _, c := range s.added.Keys() {
+		if seen.Visit(c) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// write writes the changes to b. They are kept until done is called, once
+// b is committed.
+func (s *dsSet) write(b ds.Batch) error {
+	for _, c := range s.added.Keys() {
+		if err := b.Put(s.key(c), []byte{}); err != nil {
+			return err
+		}
+	}
+	for _, c := range s.removed.Keys() {
+		if err := b.Delete(s.key(c)); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// changes returns the pins which the changes not flushed yet add and remove
+// for good: the ones already in the store aren't added, and the ones not in
+// it aren't removed.
+func (s *dsSet) changes() (added, removed []*cid.Cid, err error) {
+	for _, c := range s.added.Keys() {
+		has, err := s.dstore.Has(s.key(c))
+		if err != nil {
+			return nil, nil, err
+		}
+		if !has {
+			added = append(added, c)
+		}
+	}
+	for _, c := range s.removed.Keys() {
+		has, err := s.dstore.Has(s.key(c))
+		if err != nil {
+			return nil, nil, err
+		}
+		if has {
+			removed = append(removed, c)
+		}
+	}
+	return added, removed, nil
+}
+
+func (s *dsSet) done() {
+	s.added = cid.NewSet()
+	s.removed = cid.NewSet()
+}
+
+// unbatched applies the changes of a batch directly, for datastores without
+// batches.
+type unbatched struct {
+	ds.Datastore
+}
+
+func (u unbatched) Commit() error {
+	return nil
+}
@@ -0,0 +1,87 @@
+package pin
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// The types of pin events.
+const (
+	// EventAdded is sent once a pin is stored
+	EventAdded = "added"
+	// EventRemoved is sent once the removal of a pin is stored
+	EventRemoved = "removed"
+	// EventFetched is sent once the dag of a recursive pin is complete in
+	// the blockstore, before the pin is stored
+	EventFetched = "fetched"
+)
+
+// EventBufferSize is the number of events a subscriber can fall behind by
+// before it is dropped.
+var EventBufferSize = 256
+
+// Event is a change of the pins of a pinner.
+type Event struct {
+	Type string
+	Cid  *cid.Cid
+	// "recursive" or "direct"
+	Mode string
+	Time time.Time
+}
+
+// EventBus sends the events of a pinner to its subscribers.
+type EventBus struct {
+	lk   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func newEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel receiving the events sent from now on, until
+// ctx is done. The channel is closed then, or when the subscriber falls
+// behind by more than EventBufferSize events, as the events are never
+// dropped silently.
+func (b *EventBus) Subscribe(ctx context.Context) <-chan Event {
+	ch := make(chan Event, EventBufferSize)
+
+	b.lk.Lock()
+	b.subs[ch] = struct{}{}
+	b.lk.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(ch)
+	}()
+	return ch
+}
+
+func (b *EventBus) unsubscribe(ch chan Event) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// send sends an event to the subscribers, without waiting for them.
+func (b *EventBus) send(typ string, c *cid.Cid, mode string) {
+	ev := Event{Type: typ, Cid: c, Mode: mode, Time: time.Now()}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			log.Warningf("pin events: dropping a subscriber which fell behind")
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
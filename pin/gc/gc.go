@@ -31,19 +31,33 @@ type Result struct {
 // - all blocks utilized internally by the pinner
 //
 // The routine then iterates over every block in the blockstore and
-// deletes any block that is not found in the marked set.
+// deletes any block that is not found in the marked set. The pins in
+// progress don't hold it up: the blocks they use are left to the next run.
 //
 func GC(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.Pinner, bestEffortRoots []*cid.Cid) <-chan Result {
-	unlocker := bs.GCLock()
-	ls = ls.GetOfflineLinkService()
+	return GCCached(ctx, bs, ls, pn, bestEffortRoots, nil, nil)
+}
 
+// GCCached is like GC, but reuses the marked set cached in mc by a previous
+// run when the pins and bestEffortRoots are the same, and caches the one it
+// computes otherwise. mc may be nil. The deletions are paced by pace, if not
+// nil: the gc lock is let go while waiting, and the blocks are marked again
+// if the pins changed meanwhile.
+func GCCached(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.Pinner, bestEffortRoots []*cid.Cid, mc *MarkCache, pace Pacer) <-chan Result {
 	output := make(chan Result, 128)
+	s := newSweeper(ctx, bs, pn, bestEffortRoots, pace, output)
+	ls = ls.GetOfflineLinkService()
 
 	go func() {
 		defer close(output)
-		defer unlocker.Unlock()
+		defer s.unlock()
 
-		gcs, err := ColoredSet(ctx, pn, ls, bestEffortRoots, output)
+		var gcs *cid.Set
+		err := s.start(func() error {
+			var err error
+			gcs, err = cachedColoredSet(ctx, pn, ls, bestEffortRoots, mc, output)
+			return err
+		})
 		if err != nil {
 			output <- Result{Error: err}
 			return
@@ -65,7 +79,19 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.
 					break loop
 				}
 				if !gcs.Has(k) {
+					// the blocks may have been marked again while waiting
+					if !s.wait(0) {
+						break loop
+					}
+					if gcs.Has(k) {
+						continue loop
+					}
+
 					err := bs.DeleteBlock(k)
+					if err == bstore.ErrBlockInUse {
+						// a pin in progress uses it, the next gc will see
+						continue loop
+					}
 					if err != nil {
 						errors = true
 						output <- Result{Error: &CannotDeleteBlockError{k, err}}
@@ -91,6 +117,294 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.
 	return output
 }
 
+// Order sorts the blocks that can be collected, the ones to remove first
+// at the start.
+type Order func([]*cid.Cid)
+
+// Evict is like GCCached, but stops once the removed blocks add up to target
+// bytes. The unpinned blocks are removed in the given order, so that the
+// blocks that are most likely to be useful are kept.
+func Evict(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.Pinner, bestEffortRoots []*cid.Cid, mc *MarkCache, pace Pacer, target uint64, order Order) <-chan Result {
+	output := make(chan Result, 128)
+	s := newSweeper(ctx, bs, pn, bestEffortRoots, pace, output)
+	ls = ls.GetOfflineLinkService()
+
+	go func() {
+		defer close(output)
+		defer s.unlock()
+
+		var gcs *cid.Set
+		err := s.start(func() error {
+			var err error
+			gcs, err = cachedColoredSet(ctx, pn, ls, bestEffortRoots, mc, output)
+			return err
+		})
+		if err != nil {
+			output <- Result{Error: err}
+			return
+		}
+
+		keychan, err := bs.AllKeysChan(ctx)
+		if err != nil {
+			output <- Result{Error: err}
+			return
+		}
+
+		var candidates []*cid.Cid
+		for k := range keychan {
+			if !gcs.Has(k) {
+				candidates = append(candidates, k)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		order(candidates)
+
+		errors := false
+		var removed uint64
+		for _, k := range candidates {
+			if removed >= target {
+				break
+			}
+
+			blk, err := bs.Get(k)
+			if err == nil {
+				if !s.wait(len(blk.RawData())) {
+					return
+				}
+				if gcs.Has(k) {
+					continue
+				}
+				err = bs.DeleteBlock(k)
+			}
+			if err == bstore.ErrBlockInUse {
+				continue
+			}
+			if err != nil {
+				errors = true
+				output <- Result{Error: &CannotDeleteBlockError{k, err}}
+				// continue as error is non-fatal
+				continue
+			}
+			removed += uint64(len(blk.RawData()))
+
+			select {
+			case output <- Result{KeyRemoved: k}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if errors {
+			output <- Result{Error: ErrCannotDeleteSomeBlocks}
+		}
+	}()
+
+	return output
+}
+
+// GCOnly is like GC, but only the given candidates can be removed. The pinned
+// dags are walked until all the candidates were reached, and the other blocks
+// of the blockstore are never listed. The walk is skipped if mc holds the
+// marked set of the same pins and bestEffortRoots. The deletions are paced
+// as in GCCached.
+func GCOnly(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.Pinner, bestEffortRoots []*cid.Cid, mc *MarkCache, pace Pacer, candidates []*cid.Cid) <-chan Result {
+	output := make(chan Result, 128)
+	s := newSweeper(ctx, bs, pn, bestEffortRoots, pace, output)
+	ls = ls.GetOfflineLinkService()
+
+	go func() {
+		defer close(output)
+		defer s.unlock()
+
+		unmarked := cid.NewSet()
+		for _, k := range candidates {
+			has, err := bs.Has(k)
+			if err != nil {
+				output <- Result{Error: err}
+				return
+			}
+			if has {
+				unmarked.Add(k)
+			}
+		}
+
+		err := s.start(func() error {
+			var marked *cid.Set
+			if mc != nil {
+				digest, err := markDigest(pn, bestEffortRoots)
+				if err != nil {
+					return err
+				}
+				marked = mc.get(digest)
+			}
+			if marked != nil {
+				for _, k := range unmarked.Keys() {
+					if marked.Has(k) {
+						unmarked.Remove(k)
+					}
+				}
+				return nil
+			}
+			sendErr := func(err error) { output <- Result{Error: err} }
+			return unmarkReachable(ctx, pn, ls, bestEffortRoots, unmarked, reachOpts{onError: sendErr})
+		})
+		if err != nil {
+			output <- Result{Error: err}
+			return
+		}
+
+		errors := false
+		for _, k := range unmarked.Keys() {
+			// the blocks may have been marked again while waiting
+			if !s.wait(0) {
+				return
+			}
+			if !unmarked.Has(k) {
+				continue
+			}
+
+			err := bs.DeleteBlock(k)
+			if err == bstore.ErrBlockInUse {
+				continue
+			}
+			if err != nil {
+				errors = true
+				output <- Result{Error: &CannotDeleteBlockError{k, err}}
+				// continue as error is non-fatal
+				continue
+			}
+			select {
+			case output <- Result{KeyRemoved: k}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if errors {
+			output <- Result{Error: ErrCannotDeleteSomeBlocks}
+		}
+	}()
+
+	return output
+}
+
+// errAllReached stops the walk of unmarkReachable once no candidate is left.
+var errAllReached = errors.New("all candidates reached")
+
+// reachFunc is called when a walk reaches the candidate c from root, a pin
+// of the given mode or a best effort root.
+type reachFunc func(c, root *cid.Cid, how string)
+
+// reachOpts tune the walk of unmarkReachable.
+type reachOpts struct {
+	// the pins left out, if any
+	skip *cid.Set
+	// called, if not nil, for each candidate removed
+	reached reachFunc
+	// called, if not nil, for each block of the pins whose links can't be
+	// fetched, after onError
+	missing reachFunc
+	onError func(error)
+	// walk the whole dags, rather than stop as soon as no candidate is left
+	all bool
+}
+
+// unmarkReachable removes from the candidates set the blocks kept by the
+// pins and the bestEffortRoots, as ColoredSet would mark them. The walk
+// stops as soon as the set is empty, unless opts.all is set.
+func unmarkReachable(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffortRoots []*cid.Cid, candidates *cid.Set, opts reachOpts) error {
+	skip, reached, onError := opts.skip, opts.reached, opts.onError
+
+	directKeys, err := pn.DirectKeys()
+	if err != nil {
+		return err
+	}
+	recursiveKeys, err := pn.RecursiveKeys()
+	if err != nil {
+		return err
+	}
+
+	var root *cid.Cid
+	var how string
+	unmark := func(c *cid.Cid) {
+		if candidates.Has(c) {
+			candidates.Remove(c)
+			if reached != nil {
+				reached(c, root, how)
+			}
+		}
+	}
+
+	how = "direct"
+	for _, k := range directKeys {
+		if skip == nil || !skip.Has(k) {
+			root = k
+			unmark(k)
+		}
+	}
+	if candidates.Len() == 0 && !opts.all {
+		return nil
+	}
+
+	errors := false
+	seen := cid.NewSet()
+	visit := func(c *cid.Cid) bool {
+		unmark(c)
+		return seen.Visit(c)
+	}
+	walk := func(roots []*cid.Cid, mode string, bestEffort bool) error {
+		how = mode
+		getLinks := func(ctx context.Context, c *cid.Cid) ([]*node.Link, error) {
+			if candidates.Len() == 0 && !opts.all {
+				return nil, errAllReached
+			}
+			links, err := ls.GetLinks(ctx, c)
+			if err != nil && !(bestEffort && err == dag.ErrNotFound) {
+				errors = true
+				onError(&CannotFetchLinksError{c, err})
+				if opts.missing != nil {
+					opts.missing(c, root, how)
+				}
+			}
+			return links, nil
+		}
+		for _, c := range roots {
+			if skip != nil && skip.Has(c) {
+				continue
+			}
+			root = c
+			if !visit(c) {
+				continue
+			}
+			err := dag.EnumerateChildren(ctx, getLinks, c, visit)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err = walk(recursiveKeys, "recursive", false)
+	if err == nil {
+		err = walk(bestEffortRoots, "best effort", true)
+	}
+	if err == nil {
+		err = walk(pn.InternalPins(), "internal", false)
+	}
+	if err == errAllReached {
+		return nil
+	}
+	if err != nil {
+		errors = true
+		onError(err)
+	}
+
+	if errors {
+		return ErrCannotFetchAllLinks
+	}
+	return nil
+}
+
 func Descendants(ctx context.Context, getLinks dag.GetLinks, set *cid.Set, roots []*cid.Cid) error {
 	for _, c := range roots {
 		set.Add(c)
@@ -120,7 +434,11 @@ func ColoredSet(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffo
 		}
 		return links, nil
 	}
-	err := Descendants(ctx, getLinks, gcs, pn.RecursiveKeys())
+	recursiveKeys, err := pn.RecursiveKeys()
+	if err != nil {
+		return nil, err
+	}
+	err = Descendants(ctx, getLinks, gcs, recursiveKeys)
 	if err != nil {
 		errors = true
 		output <- Result{Error: err}
@@ -140,7 +458,11 @@ func ColoredSet(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffo
 		output <- Result{Error: err}
 	}
 
-	for _, k := range pn.DirectKeys() {
+	directKeys, err := pn.DirectKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range directKeys {
 		gcs.Add(k)
 	}
 
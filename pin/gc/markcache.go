@@ -0,0 +1,151 @@
+package gc
+
+import (
+	"context"
+	"crypto/sha256"
+	"sort"
+	"sync"
+	"time"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+// MarkCacheTTL is how long the marked set of a run is kept for the next
+// ones.
+var MarkCacheTTL = 10 * time.Minute
+
+// MarkCache keeps the marked set of the last garbage collection run, so that
+// the runs following it skip the mark phase if the pins and the best effort
+// roots didn't change. Dags are immutable, the same roots always mark the
+// same blocks, unless some of them were missing during the walk: such sets
+// aren't cached.
+type MarkCache struct {
+	lk     sync.Mutex
+	digest []byte
+	set    *cid.Set
+	timer  *time.Timer
+	hits   uint64
+}
+
+// NewMarkCache creates an empty MarkCache.
+func NewMarkCache() *MarkCache {
+	return &MarkCache{}
+}
+
+// Hits is the number of runs that reused a cached marked set.
+func (mc *MarkCache) Hits() uint64 {
+	mc.lk.Lock()
+	defer mc.lk.Unlock()
+	return mc.hits
+}
+
+// Clear drops the cached set.
+func (mc *MarkCache) Clear() {
+	mc.lk.Lock()
+	defer mc.lk.Unlock()
+	mc.clear()
+}
+
+func (mc *MarkCache) clear() {
+	if mc.timer != nil {
+		mc.timer.Stop()
+		mc.timer = nil
+	}
+	mc.digest = nil
+	mc.set = nil
+}
+
+func (mc *MarkCache) get(digest []byte) *cid.Set {
+	mc.lk.Lock()
+	defer mc.lk.Unlock()
+	if mc.set == nil || string(mc.digest) != string(digest) {
+		return nil
+	}
+	mc.hits++
+	return mc.set
+}
+
+func (mc *MarkCache) put(digest []byte, set *cid.Set) {
+	mc.lk.Lock()
+	defer mc.lk.Unlock()
+	mc.clear()
+	mc.digest = digest
+	mc.set = set
+
+	// the set can be huge, don't hold it longer than needed
+	mc.timer = time.AfterFunc(MarkCacheTTL, mc.Clear)
+}
+
+// markDigest hashes the roots a marked set is computed from.
+func markDigest(pn pin.Pinner, bestEffortRoots []*cid.Cid) ([]byte, error) {
+	recursiveKeys, err := pn.RecursiveKeys()
+	if err != nil {
+		return nil, err
+	}
+	directKeys, err := pn.DirectKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	for _, keys := range [][]*cid.Cid{recursiveKeys, directKeys, pn.InternalPins(), bestEffortRoots} {
+		sorted := make([]string, len(keys))
+		for i, c := range keys {
+			sorted[i] = c.KeyString()
+		}
+		sort.Strings(sorted)
+
+		for _, k := range sorted {
+			h.Write([]byte(k))
+		}
+		// separate the lists
+		h.Write([]byte{0})
+	}
+	return h.Sum(nil), nil
+}
+
+// cachedColoredSet is ColoredSet, returning the set cached in mc if its
+// roots are unchanged. mc may be nil.
+func cachedColoredSet(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffortRoots []*cid.Cid, mc *MarkCache, output chan<- Result) (*cid.Set, error) {
+	if mc == nil {
+		return ColoredSet(ctx, pn, ls, bestEffortRoots, output)
+	}
+
+	digest, err := markDigest(pn, bestEffortRoots)
+	if err != nil {
+		return nil, err
+	}
+	if set := mc.get(digest); set != nil {
+		return set, nil
+	}
+
+	complete := true
+	ls = &missingLinksTracker{LinkService: ls, missing: func() { complete = false }}
+	gcs, err := ColoredSet(ctx, pn, ls, bestEffortRoots, output)
+	if err != nil {
+		return nil, err
+	}
+	if complete {
+		mc.put(digest, gcs)
+	}
+	return gcs, nil
+}
+
+// missingLinksTracker calls missing when the links of a block that isn't in
+// the blockstore are asked for.
+type missingLinksTracker struct {
+	dag.LinkService
+	missing func()
+}
+
+func (t *missingLinksTracker) GetLinks(ctx context.Context, c *cid.Cid) ([]*node.Link, error) {
+	links, err := t.LinkService.GetLinks(ctx, c)
+	if err == dag.ErrNotFound {
+		t.missing()
+	}
+	return links, err
+}
@@ -0,0 +1,78 @@
+package gc
+
+import (
+	"context"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// Kept is a block which stays pinned, and what keeps it: a pin or one of the
+// best effort roots.
+type Kept struct {
+	Cid  *cid.Cid
+	Root *cid.Cid
+	// "direct", "recursive", "best effort" or "internal"
+	How string
+}
+
+// Orphans tells which of the candidates the garbage collection would remove
+// once the pins in unpinned are removed: the ones left in candidates. The
+// other ones are returned with what keeps them. The dags are walked as the
+// garbage collection would, with the local blocks only, until all the
+// candidates were reached.
+func Orphans(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffortRoots []*cid.Cid, unpinned *cid.Set, candidates *cid.Set) ([]Kept, error) {
+	var kept []Kept
+	reached := func(c, root *cid.Cid, how string) {
+		kept = append(kept, Kept{Cid: c, Root: root, How: how})
+	}
+
+	var firstErr error
+	onError := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	opts := reachOpts{skip: unpinned, reached: reached, onError: onError}
+	err := unmarkReachable(ctx, pn, ls.GetOfflineLinkService(), bestEffortRoots, candidates, opts)
+	if err == ErrCannotFetchAllLinks && firstErr != nil {
+		// the missing block is more useful than the generic error
+		return nil, firstErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kept, nil
+}
+
+// Coverage tells which of the candidates, usually all the blocks of the
+// blockstore, the garbage collection would remove: the ones left in
+// candidates. The other ones are returned in kept with what keeps them, and
+// the blocks of the pins which aren't stored in missing, with the pin they
+// are missing from. The whole dags are walked as the garbage collection
+// would, with the local blocks only. If blocks are missing, the error the
+// garbage collection would fail with is returned too, ErrCannotFetchAllLinks.
+func Coverage(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffortRoots []*cid.Cid, candidates *cid.Set) (kept, missing []Kept, err error) {
+	opts := reachOpts{
+		reached: func(c, root *cid.Cid, how string) {
+			kept = append(kept, Kept{Cid: c, Root: root, How: how})
+		},
+		missing: func(c, root *cid.Cid, how string) {
+			missing = append(missing, Kept{Cid: c, Root: root, How: how})
+		},
+		onError: func(error) {},
+		all:     true,
+	}
+
+	err = unmarkReachable(ctx, pn, ls.GetOfflineLinkService(), bestEffortRoots, candidates, opts)
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	if err != nil && err != ErrCannotFetchAllLinks {
+		return nil, nil, err
+	}
+	return kept, missing, err
+}
@@ -0,0 +1,105 @@
+package gc
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// Pacer spaces out the deletions of a sweep, so that they leave the disk to
+// the rest of the node.
+type Pacer interface {
+	// Delay accounts for the deletion of a block of size bytes, 0 if the
+	// size isn't known, and returns how long to wait before deleting it.
+	Delay(size int) time.Duration
+}
+
+// sweeper holds the gc lock for a run, and lets go of it while the pacer
+// holds up the deletions. The pins that ended meanwhile weren't marked: the
+// blocks are marked again, with mark, if the pins changed once the lock is
+// taken back.
+type sweeper struct {
+	ctx   context.Context
+	bs    bstore.GCBlockstore
+	pn    pin.Pinner
+	roots []*cid.Cid
+	pace  Pacer
+
+	output   chan<- Result
+	unlocker bstore.Unlocker
+	digest   []byte
+	mark     func() error
+}
+
+// newSweeper takes the gc lock. It must be called before the blocks are
+// marked, for the pins of the mark to be known.
+func newSweeper(ctx context.Context, bs bstore.GCBlockstore, pn pin.Pinner, bestEffortRoots []*cid.Cid, pace Pacer, output chan<- Result) *sweeper {
+	return &sweeper{
+		ctx:      ctx,
+		bs:       bs,
+		pn:       pn,
+		roots:    bestEffortRoots,
+		pace:     pace,
+		output:   output,
+		unlocker: bs.GCLock(),
+	}
+}
+
+// start records the pins the blocks are about to be marked for, if the
+// deletions are paced.
+func (s *sweeper) start(mark func() error) error {
+	s.mark = mark
+	if s.pace != nil {
+		digest, err := markDigest(s.pn, s.roots)
+		if err != nil {
+			return err
+		}
+		s.digest = digest
+	}
+	return mark()
+}
+
+// wait waits until the pacer allows deleting size bytes, without the gc
+// lock. It returns false if the run must stop: the context is done, or the
+// blocks couldn't be marked again, which is sent to the output.
+func (s *sweeper) wait(size int) bool {
+	if s.pace == nil {
+		return true
+	}
+	d := s.pace.Delay(size)
+	if d <= 0 {
+		return true
+	}
+
+	s.unlocker.Unlock()
+	t := time.NewTimer(d)
+	select {
+	case <-t.C:
+	case <-s.ctx.Done():
+		t.Stop()
+	}
+	s.unlocker = s.bs.GCLock()
+	if s.ctx.Err() != nil {
+		return false
+	}
+
+	digest, err := markDigest(s.pn, s.roots)
+	if err == nil && !bytes.Equal(digest, s.digest) {
+		s.digest = digest
+		err = s.mark()
+	}
+	if err != nil {
+		s.output <- Result{Error: err}
+		return false
+	}
+	return true
+}
+
+func (s *sweeper) unlock() {
+	s.unlocker.Unlock()
+}
@@ -0,0 +1,43 @@
+package pin
+
+import (
+	"fmt"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+)
+
+// MigrateLegacyPins stores the pins kept as a dag by earlier versions under
+// their own datastore keys, for the migration of the repo to version 6. The
+// dag is kept: the migration forgets it with ForgetLegacyPins once the repo
+// version is written, so that an interrupted migration can be run again and
+// the repo still works with the version it was made by until then.
+func MigrateLegacyPins(d ds.Datastore, internal mdag.DAGService) (recursive, direct int, err error) {
+	recurseKeys, directKeys, found, err := loadLegacyPins(d, internal)
+	if err != nil || !found {
+		return 0, 0, err
+	}
+
+	p := NewPinner(d, internal, internal).(*pinner)
+	for _, c := range recurseKeys {
+		p.recursePin.Add(c)
+	}
+	for _, c := range directKeys {
+		p.directPin.Add(c)
+	}
+	if err := p.Flush(); err != nil {
+		return 0, 0, fmt.Errorf("cannot move pins to the datastore: %v", err)
+	}
+	return len(recurseKeys), len(directKeys), nil
+}
+
+// ForgetLegacyPins forgets the root of the pins stored as a dag, for the
+// garbage collector to remove its objects.
+func ForgetLegacyPins(d ds.Datastore) error {
+	err := d.Delete(pinDatastoreKey)
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	return err
+}
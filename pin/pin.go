@@ -20,6 +20,7 @@ import (
 
 var log = logging.Logger("pin")
 
+// pinDatastoreKey is the root of the pins stored as a dag by earlier versions.
 var pinDatastoreKey = ds.NewKey("/local/pins")
 
 var emptyKey *cid.Cid
@@ -96,6 +97,14 @@ type Pinner interface {
 	// calling IsPinned for each key
 	CheckIfPinned(cids ...*cid.Cid) ([]Pinned, error)
 
+	// PinnedBy returns the recursive pins, other than c itself, whose dags
+	// contain c: the pins keeping c indirectly pinned
+	PinnedBy(ctx context.Context, c *cid.Cid) ([]*cid.Cid, error)
+
+	// PinnedByEach returns PinnedBy for each of cs, by key, walking the
+	// dags of the recursive pins once for all of them
+	PinnedByEach(ctx context.Context, cs []*cid.Cid) (map[string][]*cid.Cid, error)
+
 	// PinWithMode is for manually editing the pin structure. Use with
 	// care! If used improperly, garbage collection may not be
 	// successful.
@@ -107,8 +116,12 @@ type Pinner interface {
 	RemovePinWithMode(*cid.Cid, PinMode)
 
 	Flush() error
-	DirectKeys() []*cid.Cid
-	RecursiveKeys() []*cid.Cid
+
+	// Events returns the bus the changes of the pins are sent on
+	Events() *EventBus
+
+	DirectKeys() ([]*cid.Cid, error)
+	RecursiveKeys() ([]*cid.Cid, error)
 	InternalPins() []*cid.Cid
 }
 
@@ -141,30 +154,24 @@ func (p Pinned) String() string {
 // pinner implements the Pinner interface
 type pinner struct {
 	lock       sync.RWMutex
-	recursePin *cid.Set
-	directPin  *cid.Set
-
-	// Track the keys used for storing the pinning state, so gc does
-	// not delete them.
-	internalPin *cid.Set
-	dserv       mdag.DAGService
-	internal    mdag.DAGService // dagservice used to store internal objects
-	dstore      ds.Datastore
+	recursePin *dsSet
+	directPin  *dsSet
+
+	dserv    mdag.DAGService
+	internal mdag.DAGService // dagservice the pins were stored in as a dag
+	dstore   ds.Datastore
+	events   *EventBus
 }
 
 // NewPinner creates a new pinner using the given datastore as a backend
 func NewPinner(dstore ds.Datastore, serv, internal mdag.DAGService) Pinner {
-
-	rcset := cid.NewSet()
-	dirset := cid.NewSet()
-
 	return &pinner{
-		recursePin:  rcset,
-		directPin:   dirset,
-		dserv:       serv,
-		dstore:      dstore,
-		internal:    internal,
-		internalPin: cid.NewSet(),
+		recursePin: newDsSet(dstore, linkRecursive),
+		directPin:  newDsSet(dstore, linkDirect),
+		dserv:      serv,
+		dstore:     dstore,
+		internal:   internal,
+		events:     newEventBus(),
 	}
 }
 
@@ -175,19 +182,22 @@ func (p *pinner) Pin(ctx context.Context, node node.Node, recurse bool) error {
 	c := node.Cid()
 
 	if recurse {
-		if p.recursePin.Has(c) {
+		has, err := p.recursePin.Has(c)
+		if err != nil {
+			return err
+		}
+		if has {
 			return nil
 		}
 
-		if p.directPin.Has(c) {
-			p.directPin.Remove(c)
-		}
+		p.directPin.Remove(c)
 
 		// fetch entire graph
-		err := mdag.FetchGraph(ctx, c, p.dserv)
+		err = mdag.FetchGraph(ctx, c, p.dserv)
 		if err != nil {
 			return err
 		}
+		p.events.send(EventFetched, c, linkRecursive)
 
 		p.recursePin.Add(c)
 	} else {
@@ -195,7 +205,11 @@ func (p *pinner) Pin(ctx context.Context, node node.Node, recurse bool) error {
 			return err
 		}
 
-		if p.recursePin.Has(c) {
+		has, err := p.recursePin.Has(c)
+		if err != nil {
+			return err
+		}
+		if has {
 			return fmt.Errorf("%s already pinned recursively", c.String())
 		}
 
@@ -206,11 +220,13 @@ func (p *pinner) Pin(ctx context.Context, node node.Node, recurse bool) error {
 
 var ErrNotPinned = fmt.Errorf("not pinned")
 
-// Unpin a given key
+// Unpin a given key. Finding whether a key which isn't pinned directly nor
+// recursively is pinned indirectly walks the recursive pins: the nodes walked
+// are counted by the merkledag.ProgressTracker of ctx, if any.
 func (p *pinner) Unpin(ctx context.Context, c *cid.Cid, recursive bool) error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	reason, pinned, err := p.isPinnedWithType(c, Any)
+	reason, pinned, err := p.isPinnedWithType(ctx, c, Any)
 	if err != nil {
 		return err
 	}
@@ -233,27 +249,23 @@ func (p *pinner) Unpin(ctx context.Context, c *cid.Cid, recursive bool) error {
 	}
 }
 
-func (p *pinner) isInternalPin(c *cid.Cid) bool {
-	return p.internalPin.Has(c)
-}
-
 // IsPinned returns whether or not the given key is pinned
 // and an explanation of why its pinned
 func (p *pinner) IsPinned(c *cid.Cid) (string, bool, error) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
-	return p.isPinnedWithType(c, Any)
+	return p.isPinnedWithType(context.Background(), c, Any)
 }
 
 func (p *pinner) IsPinnedWithType(c *cid.Cid, mode PinMode) (string, bool, error) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
-	return p.isPinnedWithType(c, mode)
+	return p.isPinnedWithType(context.Background(), c, mode)
 }
 
 // isPinnedWithType is the implementation of IsPinnedWithType that does not lock.
 // intended for use by other pinned methods that already take locks
-func (p *pinner) isPinnedWithType(c *cid.Cid, mode PinMode) (string, bool, error) {
+func (p *pinner) isPinnedWithType(ctx context.Context, c *cid.Cid, mode PinMode) (string, bool, error) {
 	switch mode {
 	case Any, Direct, Indirect, Recursive, Internal:
 	default:
@@ -261,31 +273,58 @@ func (p *pinner) isPinnedWithType(c *cid.Cid, mode PinMode) (string, bool, error
 			mode, Direct, Indirect, Recursive, Internal, Any)
 		return "", false, err
 	}
-	if (mode == Recursive || mode == Any) && p.recursePin.Has(c) {
-		return linkRecursive, true, nil
+	if mode == Recursive || mode == Any {
+		has, err := p.recursePin.Has(c)
+		if err != nil {
+			return "", false, err
+		}
+		if has {
+			return linkRecursive, true, nil
+		}
 	}
 	if mode == Recursive {
 		return "", false, nil
 	}
 
-	if (mode == Direct || mode == Any) && p.directPin.Has(c) {
-		return linkDirect, true, nil
+	if mode == Direct || mode == Any {
+		has, err := p.directPin.Has(c)
+		if err != nil {
+			return "", false, err
+		}
+		if has {
+			return linkDirect, true, nil
+		}
 	}
 	if mode == Direct {
 		return "", false, nil
 	}
 
-	if (mode == Internal || mode == Any) && p.isInternalPin(c) {
-		return linkInternal, true, nil
-	}
+	// the pins don't use internal objects anymore
 	if mode == Internal {
 		return "", false, nil
 	}
 
 	// Default is Indirect
+	recursiveKeys, err := p.recursePin.Keys()
+	if err != nil {
+		return "", false, err
+	}
+	v, _ := ctx.Value("progress").(*mdag.ProgressTracker)
 	visitedSet := cid.NewSet()
-	for _, rc := range p.recursePin.Keys() {
-		has, err := hasChild(p.dserv, rc, c, visitedSet.Visit)
+	visit := func(c *cid.Cid) bool {
+		if !visitedSet.Visit(c) {
+			return false
+		}
+		if v != nil {
+			v.Increment()
+		}
+		return true
+	}
+	for _, rc := range recursiveKeys {
+		if v != nil {
+			v.Increment()
+		}
+		has, err := hasChild(ctx, p.dserv, rc, c, visit)
 		if err != nil {
 			return "", false, err
 		}
@@ -296,6 +335,94 @@ func (p *pinner) isPinnedWithType(c *cid.Cid, mode PinMode) (string, bool, error
 	return "", false, nil
 }
 
+func (p *pinner) PinnedBy(ctx context.Context, c *cid.Cid) ([]*cid.Cid, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	// whether the dag under each visited node contains c, so that the
+	// branches shared by several pins are walked once
+	contains := make(map[string]bool)
+	var walk func(*cid.Cid) (bool, error)
+	walk = func(k *cid.Cid) (bool, error) {
+		if has, ok := contains[k.KeyString()]; ok {
+			return has, nil
+		}
+
+		links, err := p.dserv.GetLinks(ctx, k)
+		if err != nil {
+			return false, err
+		}
+		has := false
+		for _, lnk := range links {
+			if lnk.Cid.Equals(c) {
+				has = true
+				break
+			}
+			if has, err = walk(lnk.Cid); err != nil {
+				return false, err
+			}
+			if has {
+				break
+			}
+		}
+		contains[k.KeyString()] = has
+		return has, nil
+	}
+
+	recursiveKeys, err := p.recursePin.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*cid.Cid
+	for _, rk := range recursiveKeys {
+		if rk.Equals(c) {
+			continue
+		}
+		has, err := walk(rk)
+		if err != nil {
+			return nil, err
+		}
+		if has {
+			out = append(out, rk)
+		}
+	}
+	return out, nil
+}
+
+func (p *pinner) PinnedByEach(ctx context.Context, cs []*cid.Cid) (map[string][]*cid.Cid, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	wanted := cid.NewSet()
+	for _, c := range cs {
+		wanted.Add(c)
+	}
+
+	recursiveKeys, err := p.recursePin.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]*cid.Cid)
+	for _, rk := range recursiveKeys {
+		visited := cid.NewSet()
+		visit := func(c *cid.Cid) bool {
+			if !visited.Visit(c) {
+				return false
+			}
+			if wanted.Has(c) && !c.Equals(rk) {
+				out[c.KeyString()] = append(out[c.KeyString()], rk)
+			}
+			return true
+		}
+		if err := mdag.EnumerateChildren(ctx, p.dserv.GetLinks, rk, visit); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
 func (p *pinner) CheckIfPinned(cids ...*cid.Cid) ([]Pinned, error) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
@@ -304,12 +431,21 @@ func (p *pinner) CheckIfPinned(cids ...*cid.Cid) ([]Pinned, error) {
 
 	// First check for non-Indirect pins directly
 	for _, c := range cids {
-		if p.recursePin.Has(c) {
+		recursive, err := p.recursePin.Has(c)
+		if err != nil {
+			return nil, err
+		}
+		if recursive {
 			pinned = append(pinned, Pinned{Key: c, Mode: Recursive})
-		} else if p.directPin.Has(c) {
+			continue
+		}
+
+		direct, err := p.directPin.Has(c)
+		if err != nil {
+			return nil, err
+		}
+		if direct {
 			pinned = append(pinned, Pinned{Key: c, Mode: Direct})
-		} else if p.isInternalPin(c) {
-			pinned = append(pinned, Pinned{Key: c, Mode: Internal})
 		} else {
 			toCheck.Add(c)
 		}
@@ -343,7 +479,11 @@ func (p *pinner) CheckIfPinned(cids ...*cid.Cid) ([]Pinned, error) {
 		return nil
 	}
 
-	for _, rk := range p.recursePin.Keys() {
+	recursiveKeys, err := p.recursePin.Keys()
+	if err != nil {
+		return nil, err
+	}
+	for _, rk := range recursiveKeys {
 		err := checkChildren(rk, rk)
 		if err != nil {
 			return nil, err
@@ -375,30 +515,46 @@ func (p *pinner) RemovePinWithMode(c *cid.Cid, mode PinMode) {
 	}
 }
 
-func cidSetWithValues(cids []*cid.Cid) *cid.Set {
-	out := cid.NewSet()
-	for _, c := range cids {
-		out.Add(c)
+// LoadPinner loads a pinner from the given datastore, which must have been
+// migrated to repo version 6: see MigrateLegacyPins.
+func LoadPinner(d ds.Datastore, dserv, internal mdag.DAGService) (Pinner, error) {
+	// the repo migration to version 6 moves the pins stored as a dag to
+	// the datastore, and forgets the dag once the repo version is written.
+	// If it was interrupted in between, the pins are moved again, which
+	// does no harm, and the dag forgotten now.
+	recursive, direct, err := MigrateLegacyPins(d, internal)
+	if err != nil {
+		return nil, err
+	}
+	if err := ForgetLegacyPins(d); err != nil {
+		return nil, err
+	}
+	if recursive+direct > 0 {
+		log.Infof("moved %d recursive and %d direct pins to the datastore", recursive, direct)
 	}
-	return out
-}
 
-// LoadPinner loads a pinner and its keysets from the given datastore
-func LoadPinner(d ds.Datastore, dserv, internal mdag.DAGService) (Pinner, error) {
-	p := new(pinner)
+	return NewPinner(d, dserv, internal), nil
+}
 
+// loadLegacyPins loads the pins stored as a dag by earlier versions. found is
+// false if there are none.
+func loadLegacyPins(d ds.Datastore, internal mdag.DAGService) (recurseKeys, directKeys []*cid.Cid, found bool, err error) {
 	rootKeyI, err := d.Get(pinDatastoreKey)
-	if err != nil {
-		return nil, fmt.Errorf("cannot load pin state: %v", err)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil, nil, false, nil
+	default:
+		return nil, nil, false, fmt.Errorf("cannot load pin state: %v", err)
 	}
 	rootKeyBytes, ok := rootKeyI.([]byte)
 	if !ok {
-		return nil, fmt.Errorf("cannot load pin state: %s was not bytes", pinDatastoreKey)
+		return nil, nil, false, fmt.Errorf("cannot load pin state: %s was not bytes", pinDatastoreKey)
 	}
 
 	rootCid, err := cid.Cast(rootKeyBytes)
 	if err != nil {
-		return nil, err
+		return nil, nil, false, fmt.Errorf("cannot load pin state: %v", err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
@@ -406,51 +562,38 @@ func LoadPinner(d ds.Datastore, dserv, internal mdag.DAGService) (Pinner, error)
 
 	root, err := internal.Get(ctx, rootCid)
 	if err != nil {
-		return nil, fmt.Errorf("cannot find pinning root object: %v", err)
+		return nil, nil, false, fmt.Errorf("cannot find pinning root object: %v", err)
 	}
 
 	rootpb, ok := root.(*mdag.ProtoNode)
 	if !ok {
-		return nil, mdag.ErrNotProtobuf
+		return nil, nil, false, mdag.ErrNotProtobuf
 	}
 
-	internalset := cid.NewSet()
-	internalset.Add(rootCid)
-	recordInternal := internalset.Add
+	recordInternal := func(*cid.Cid) {}
 
-	{ // load recursive set
-		recurseKeys, err := loadSet(ctx, internal, rootpb, linkRecursive, recordInternal)
-		if err != nil {
-			return nil, fmt.Errorf("cannot load recursive pins: %v", err)
-		}
-		p.recursePin = cidSetWithValues(recurseKeys)
+	recurseKeys, err = loadSet(ctx, internal, rootpb, linkRecursive, recordInternal)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("cannot load recursive pins: %v", err)
 	}
-
-	{ // load direct set
-		directKeys, err := loadSet(ctx, internal, rootpb, linkDirect, recordInternal)
-		if err != nil {
-			return nil, fmt.Errorf("cannot load direct pins: %v", err)
-		}
-		p.directPin = cidSetWithValues(directKeys)
+	directKeys, err = loadSet(ctx, internal, rootpb, linkDirect, recordInternal)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("cannot load direct pins: %v", err)
 	}
-
-	p.internalPin = internalset
-
-	// assign services
-	p.dserv = dserv
-	p.dstore = d
-	p.internal = internal
-
-	return p, nil
+	return recurseKeys, directKeys, true, nil
 }
 
 // DirectKeys returns a slice containing the directly pinned keys
-func (p *pinner) DirectKeys() []*cid.Cid {
+func (p *pinner) DirectKeys() ([]*cid.Cid, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	return p.directPin.Keys()
 }
 
 // RecursiveKeys returns a slice containing the recursively pinned keys
-func (p *pinner) RecursiveKeys() []*cid.Cid {
+func (p *pinner) RecursiveKeys() ([]*cid.Cid, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	return p.recursePin.Keys()
 }
 
@@ -458,15 +601,27 @@ func (p *pinner) Update(ctx context.Context, from, to *cid.Cid, unpin bool) erro
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	if !p.recursePin.Has(from) {
+	has, err := p.recursePin.Has(from)
+	if err != nil {
+		return err
+	}
+	if !has {
 		return fmt.Errorf("'from' cid was not recursively pinned already")
 	}
 
-	err := dutils.DiffEnumerate(ctx, p.dserv, from, to)
+	// unpinning would drop the only pin
+	if from.Equals(to) {
+		return nil
+	}
+
+	// only fetch the parts of the new dag which changed
+	err = dutils.DiffEnumerate(ctx, p.dserv, from, to)
 	if err != nil {
 		return err
 	}
+	p.events.send(EventFetched, to, linkRecursive)
 
+	p.directPin.Remove(to)
 	p.recursePin.Add(to)
 	if unpin {
 		p.recursePin.Remove(from)
@@ -474,64 +629,66 @@ func (p *pinner) Update(ctx context.Context, from, to *cid.Cid, unpin bool) erro
 	return nil
 }
 
-// Flush encodes and writes pinner keysets to the datastore
+// Flush writes the pins added and removed since the last flush to the
+// datastore, in a single batch when the datastore supports them.
 func (p *pinner) Flush() error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	ctx := context.TODO()
-
-	internalset := cid.NewSet()
-	recordInternal := internalset.Add
-
-	root := &mdag.ProtoNode{}
-	{
-		n, err := storeSet(ctx, p.internal, p.directPin.Keys(), recordInternal)
-		if err != nil {
-			return err
-		}
-		if err := root.AddNodeLink(linkDirect, n); err != nil {
-			return err
-		}
-	}
-
-	{
-		n, err := storeSet(ctx, p.internal, p.recursePin.Keys(), recordInternal)
-		if err != nil {
-			return err
-		}
-		if err := root.AddNodeLink(linkRecursive, n); err != nil {
+	var b ds.Batch = unbatched{p.dstore}
+	if bds, ok := p.dstore.(ds.Batching); ok {
+		var err error
+		if b, err = bds.Batch(); err != nil {
 			return err
 		}
 	}
 
-	// add the empty node, its referenced by the pin sets but never created
-	_, err := p.internal.Add(new(mdag.ProtoNode))
+	// the events are sent once the changes are stored
+	recAdded, recRemoved, err := p.recursePin.changes()
 	if err != nil {
 		return err
 	}
-
-	k, err := p.internal.Add(root)
+	dirAdded, dirRemoved, err := p.directPin.changes()
 	if err != nil {
 		return err
 	}
 
-	internalset.Add(k)
-	if err := p.dstore.Put(pinDatastoreKey, k.Bytes()); err != nil {
+	if err := p.recursePin.write(b); err != nil {
+		return err
+	}
+	if err := p.directPin.write(b); err != nil {
+		return err
+	}
+	if err := b.Commit(); err != nil {
 		return fmt.Errorf("cannot store pin state: %v", err)
 	}
-	p.internalPin = internalset
+
+	p.recursePin.done()
+	p.directPin.done()
+
+	for _, c := range recRemoved {
+		p.events.send(EventRemoved, c, linkRecursive)
+	}
+	for _, c := range dirRemoved {
+		p.events.send(EventRemoved, c, linkDirect)
+	}
+	for _, c := range dirAdded {
+		p.events.send(EventAdded, c, linkDirect)
+	}
+	for _, c := range recAdded {
+		p.events.send(EventAdded, c, linkRecursive)
+	}
 	return nil
 }
 
+func (p *pinner) Events() *EventBus {
+	return p.events
+}
+
+// InternalPins returns nil: the pins are stored in the datastore, not in
+// objects that the garbage collector would have to keep.
 func (p *pinner) InternalPins() []*cid.Cid {
-	p.lock.Lock()
-	defer p.lock.Unlock()
-	var out []*cid.Cid
-	for _, c := range p.internalPin.Keys() {
-		out = append(out, c)
-	}
-	return out
+	return nil
 }
 
 // PinWithMode allows the user to have fine grained control over pin
@@ -549,8 +706,8 @@ func (p *pinner) PinWithMode(c *cid.Cid, mode PinMode) {
 
 // hasChild recursively looks for a Cid among the children of a root Cid.
 // The visit function can be used to shortcut already-visited branches.
-func hasChild(ds mdag.LinkService, root *cid.Cid, child *cid.Cid, visit func(*cid.Cid) bool) (bool, error) {
-	links, err := ds.GetLinks(context.Background(), root)
+func hasChild(ctx context.Context, ds mdag.LinkService, root *cid.Cid, child *cid.Cid, visit func(*cid.Cid) bool) (bool, error) {
+	links, err := ds.GetLinks(ctx, root)
 	if err != nil {
 		return false, err
 	}
@@ -560,7 +717,7 @@ func hasChild(ds mdag.LinkService, root *cid.Cid, child *cid.Cid, visit func(*ci
 			return true, nil
 		}
 		if visit(c) {
-			has, err := hasChild(ds, c, child, visit)
+			has, err := hasChild(ctx, ds, c, child, visit)
 			if err != nil {
 				return false, err
 			}
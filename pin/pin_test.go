@@ -46,6 +46,39 @@ func assertUnpinned(t *testing.T, p Pinner, c *cid.Cid, failmsg string) {
 	}
 }
 
+func assertPinnedBy(t *testing.T, p Pinner, c *cid.Cid, roots ...*cid.Cid) {
+	by, err := p.PinnedBy(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := cid.NewSet()
+	for _, r := range roots {
+		expected.Add(r)
+	}
+	if len(by) != expected.Len() {
+		t.Fatalf("%s: expected %d pins, got %v", c, expected.Len(), by)
+	}
+	for _, r := range by {
+		if !expected.Has(r) {
+			t.Fatalf("%s: unexpected pin %s", c, r)
+		}
+	}
+
+	each, err := p.PinnedByEach(context.Background(), []*cid.Cid{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(each[c.KeyString()]) != expected.Len() {
+		t.Fatalf("%s: expected %d pins for each, got %v", c, expected.Len(), each)
+	}
+	for _, r := range each[c.KeyString()] {
+		if !expected.Has(r) {
+			t.Fatalf("%s: unexpected pin %s for each", c, r)
+		}
+	}
+}
+
 func TestPinnerBasic(t *testing.T) {
 	ctx := context.Background()
 
@@ -255,6 +288,11 @@ func TestIsPinnedLookup(t *testing.T) {
 	assertPinned(t, p, ck, "C should be pinned")
 	assertPinned(t, p, bk, "B should be pinned")
 
+	assertPinnedBy(t, p, aKeys[0], aKeys[5], bk, ck)
+	assertPinnedBy(t, p, aKeys[4], aKeys[5])
+	assertPinnedBy(t, p, ck, bk)
+	assertPinnedBy(t, p, bk)
+
 	// Unpin A5 recursively
 	if err := p.Unpin(ctx, aKeys[5], true); err != nil {
 		t.Fatal(err)
@@ -399,4 +437,308 @@ func TestPinUpdate(t *testing.T) {
 
 	assertPinned(t, p, c2, "c2 should be pinned still")
 	assertPinned(t, p, c1, "c1 should be pinned now")
+
+	if err := p.Update(ctx, c1, c1, true); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, p, c1, "c1 should stay pinned when updated to itself")
+
+	n3, c3 := randNode()
+	dserv.Add(n3)
+	if err := p.Pin(ctx, n3, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(ctx, c1, c3, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, pinned, _ := p.IsPinnedWithType(c3, Recursive); !pinned {
+		t.Fatal("c3 should be pinned recursively now")
+	}
+	if _, pinned, _ := p.IsPinnedWithType(c3, Direct); pinned {
+		t.Fatal("c3 should no longer be pinned directly")
+	}
+}
+
+func TestLoadDagPins(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	// store the pins as a dag, as earlier versions did
+	_, rk := randNode()
+	_, dk := randNode()
+	root := &mdag.ProtoNode{}
+	for name, keys := range map[string][]*cid.Cid{linkRecursive: {rk}, linkDirect: {dk}} {
+		n, err := storeSet(ctx, dserv, keys, ignoreCids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := root.AddNodeLink(name, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dserv.Add(new(mdag.ProtoNode)); err != nil {
+		t.Fatal(err)
+	}
+	k, err := dserv.Add(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dstore.Put(pinDatastoreKey, k.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the migration keeps the dag until the repo version is written
+	recursive, direct, err := MigrateLegacyPins(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recursive != 1 || direct != 1 {
+		t.Fatalf("expected 1 recursive and 1 direct pin moved, got %d and %d", recursive, direct)
+	}
+	if has, _ := dstore.Has(pinDatastoreKey); !has {
+		t.Fatal("the dag of the pins was forgotten before the migration finished")
+	}
+	assertPinned(t, NewPinner(dstore, dserv, dserv), rk, "recursive pin wasn't migrated")
+
+	// loading the pinner after an interrupted migration finishes it
+	p, err := LoadPinner(dstore, dserv, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, pinned, _ := p.IsPinnedWithType(rk, Recursive); !pinned {
+		t.Fatal("recursive pin wasn't moved")
+	}
+	if _, pinned, _ := p.IsPinnedWithType(dk, Direct); !pinned {
+		t.Fatal("direct pin wasn't moved")
+	}
+	if has, _ := dstore.Has(pinDatastoreKey); has {
+		t.Fatal("the dag of the pins should be forgotten")
+	}
+
+	// the pins are in the datastore now
+	np, err := LoadPinner(dstore, dserv, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, np, rk, "recursive pin lost after reload")
+	assertPinned(t, np, dk, "direct pin lost after reload")
+}
+
+func TestPinsStoredOnFlush(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv, dserv)
+	_, a := randNode()
+	_, b := randNode()
+	p.PinWithMode(a, Recursive)
+	p.PinWithMode(b, Direct)
+
+	np, err := LoadPinner(dstore, dserv, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertUnpinned(t, np, a, "pins shouldn't be stored before a flush")
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	p.RemovePinWithMode(a, Recursive)
+	keys, err := p.RecursiveKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("removed pin still listed: %v", keys)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	np, err = LoadPinner(dstore, dserv, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertUnpinned(t, np, a, "removed pin still stored")
+	keys, err = np.DirectKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !keys[0].Equals(b) {
+		t.Fatalf("expected the direct pin %s, got %v", b, keys)
+	}
+}
+
+func TestPinEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv, dserv)
+	events := p.Events().Subscribe(ctx)
+
+	a, ak := randNode()
+	if _, err := dserv.Add(a); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(typ string, c *cid.Cid, mode string) {
+		select {
+		case ev := <-events:
+			if ev.Type != typ || !ev.Cid.Equals(c) || ev.Mode != mode {
+				t.Fatalf("expected %s %s %s, got %s %s %s", typ, mode, c, ev.Type, ev.Mode, ev.Cid)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s event for %s", typ, c)
+		}
+	}
+
+	if err := p.Pin(ctx, a, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expect(EventAdded, ak, "direct")
+
+	// pinning a direct pin recursively replaces it
+	if err := p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	expect(EventFetched, ak, "recursive")
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expect(EventRemoved, ak, "direct")
+	expect(EventAdded, ak, "recursive")
+
+	if err := p.Unpin(ctx, ak, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expect(EventRemoved, ak, "recursive")
+
+	// a flush without changes sends nothing
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %s %s", ev.Type, ev.Cid)
+	default:
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("unexpected event after the subscription ended")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the channel of the events was not closed")
+	}
+}
+
+func TestUnpinProgress(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv, dserv)
+
+	// root -> mid -> leaf
+	leaf, lk := randNode()
+	if _, err := dserv.Add(leaf); err != nil {
+		t.Fatal(err)
+	}
+	mid, _ := randNode()
+	if err := mid.AddNodeLink("leaf", leaf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dserv.Add(mid); err != nil {
+		t.Fatal(err)
+	}
+	root, rk := randNode()
+	if err := root.AddNodeLink("mid", mid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dserv.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, root, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// finding that the leaf is pinned indirectly walks the pin
+	v := new(mdag.ProgressTracker)
+	if err := p.Unpin(v.DeriveContext(ctx), lk, true); err == nil {
+		t.Fatal("indirect pin should not be unpinned")
+	}
+	if v.Value() != 2 {
+		t.Fatalf("expected 2 nodes processed, got %d", v.Value())
+	}
+
+	// a recursive pin is removed without walking it
+	v = new(mdag.ProgressTracker)
+	if err := p.Unpin(v.DeriveContext(ctx), rk, true); err != nil {
+		t.Fatal(err)
+	}
+	if v.Value() != 0 {
+		t.Fatalf("expected no node processed, got %d", v.Value())
+	}
+}
+
+func TestCheckPinStoreKeys(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv, dserv)
+	_, a := randNode()
+	p.PinWithMode(a, Recursive)
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPins(dstore, dserv); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := pinStorePrefix.ChildString(linkRecursive).ChildString("notacid")
+	if err := dstore.Put(bad, []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPins(dstore, dserv); err == nil {
+		t.Fatal("expected the key which isn't a pin to be reported")
+	}
+
+	if err := SetAsideBrokenPins(dstore); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPins(dstore, dserv); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := dstore.Has(brokenPinStorePrefix.Child(bad)); !has {
+		t.Fatal("the broken key wasn't set aside")
+	}
+
+	np, err := LoadPinner(dstore, dserv, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, np, a, "the valid pin was set aside")
 }
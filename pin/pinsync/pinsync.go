@@ -0,0 +1,580 @@
+// Package pinsync mirrors pinsets between nodes. A node publishes signed
+// updates of its published pinset on a pubsub topic, and the nodes following
+// it pin and unpin the same roots.
+package pinsync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
+	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	floodsub "gx/ipfs/QmYPKo97ssdv3Bsk9sRAS5ZjahGg9Stzys3vybu3r7VuB5/floodsub"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	p2phost "gx/ipfs/QmcyNeWPsoFGxThGpV8JnJdfUNankKhWCTrbrcFRQda4xR/go-libp2p-host"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var log = logging.Logger("pinsync")
+
+// SnapshotInterval is how often a publisher sends its whole pinset, so that
+// new followers catch up and followers that missed updates are repaired.
+var SnapshotInterval = time.Minute * 5
+
+var (
+	publishedKey = ds.NewKey("/local/pinsync/published")
+	followPrefix = ds.NewKey("/local/pinsync/follow")
+)
+
+// Topic returns the pubsub topic the given peer publishes its pinset on.
+func Topic(p peer.ID) string {
+	return "/ipfs/pinsync/" + p.Pretty()
+}
+
+// Syncer publishes the pinset of the local node, and mirrors the pins of
+// the publishers it follows.
+type Syncer struct {
+	ctx     context.Context
+	ps      *floodsub.PubSub
+	host    p2phost.Host
+	pinning pin.Pinner
+	dag     dag.DAGService
+	locker  bstore.GCLocker
+	ds      ds.Datastore
+	sk      ic.PrivKey
+
+	lk        sync.Mutex
+	published pinset
+	following map[peer.ID]*follow
+}
+
+// pinset is the state persisted for the local pinset and for each followed
+// publisher.
+type pinset struct {
+	Seq uint64
+
+	// Pins maps the roots of the pinset to whether they were pinned by the
+	// syncer. Roots that were already pinned are not unpinned when they are
+	// removed from the pinset.
+	Pins map[string]bool
+}
+
+type follow struct {
+	pinset
+	cancel     func()
+	lastUpdate time.Time
+	err        error
+}
+
+// FollowStatus describes the mirroring of a followed publisher.
+type FollowStatus struct {
+	Peer       string
+	Seq        uint64
+	Pins       int
+	LastUpdate time.Time
+	Error      string `json:",omitempty"`
+}
+
+// Status describes the published pinset and the followed publishers.
+type Status struct {
+	Topic     string
+	Seq       uint64
+	Published int
+	Following []FollowStatus
+}
+
+// New creates a Syncer, and resumes following the publishers followed
+// before the node was restarted.
+func New(ctx context.Context, ps *floodsub.PubSub, host p2phost.Host, pinning pin.Pinner, dserv dag.DAGService, locker bstore.GCLocker, dstore ds.Datastore, sk ic.PrivKey) (*Syncer, error) {
+	s := &Syncer{
+		ctx:       ctx,
+		ps:        ps,
+		host:      host,
+		pinning:   pinning,
+		dag:       dserv,
+		locker:    locker,
+		ds:        dstore,
+		sk:        sk,
+		published: pinset{Pins: make(map[string]bool)},
+		following: make(map[peer.ID]*follow),
+	}
+
+	if err := s.load(publishedKey, &s.published); err != nil {
+		return nil, err
+	}
+
+	res, err := dstore.Query(dsq.Query{Prefix: followPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		p, err := peer.IDB58Decode(ds.RawKey(e.Key).BaseNamespace())
+		if err != nil {
+			log.Warningf("pinsync: bad followed peer %s: %s", e.Key, err)
+			continue
+		}
+
+		f := &follow{pinset: pinset{Pins: make(map[string]bool)}}
+		if err := s.load(followKey(p), &f.pinset); err != nil {
+			return nil, err
+		}
+		if err := s.subscribe(p, f); err != nil {
+			return nil, err
+		}
+		s.following[p] = f
+	}
+
+	go s.snapshotLoop()
+	return s, nil
+}
+
+// Publish adds and removes roots of the local pinset. The added roots are
+// pinned locally, then the update is sent to the followers.
+func (s *Syncer) Publish(ctx context.Context, add, rm []*cid.Cid) error {
+	for _, c := range add {
+		if err := s.fetch(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	// the roots changed before an error are stored and sent all the same,
+	// as they were pinned or unpinned already
+	u := &Update{Seq: s.published.Seq + 1}
+	updateErr := s.updatePublished(ctx, u, add, rm)
+	if len(u.Add) == 0 && len(u.Rm) == 0 {
+		return updateErr
+	}
+
+	s.published.Seq = u.Seq
+	if err := s.store(publishedKey, &s.published); err != nil {
+		return err
+	}
+	if err := s.send(u); err != nil {
+		return err
+	}
+	return updateErr
+}
+
+// updatePublished adds and removes roots of the published pinset, recording
+// the changes in u. It must be called with the lock held.
+func (s *Syncer) updatePublished(ctx context.Context, u *Update, add, rm []*cid.Cid) error {
+	for _, c := range add {
+		k := c.String()
+		if _, ok := s.published.Pins[k]; ok {
+			continue
+		}
+
+		pinned, err := s.pin(ctx, c)
+		if err != nil {
+			return err
+		}
+		s.published.Pins[k] = pinned
+		u.Add = append(u.Add, k)
+	}
+
+	for _, c := range rm {
+		k := c.String()
+		owned, ok := s.published.Pins[k]
+		if !ok {
+			continue
+		}
+
+		// a followed publisher may have the root as well
+		delete(s.published.Pins, k)
+		u.Rm = append(u.Rm, k)
+		if owned {
+			if err := s.release(ctx, k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Follow starts mirroring the pinset of the given publisher.
+func (s *Syncer) Follow(p peer.ID) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if _, ok := s.following[p]; ok {
+		return fmt.Errorf("already following %s", p.Pretty())
+	}
+
+	f := &follow{pinset: pinset{Pins: make(map[string]bool)}}
+	if err := s.store(followKey(p), &f.pinset); err != nil {
+		return err
+	}
+	if err := s.subscribe(p, f); err != nil {
+		return err
+	}
+	s.following[p] = f
+	return nil
+}
+
+// Unfollow stops mirroring the pinset of the given publisher. Unless keep
+// is set, the roots pinned for it are unpinned.
+func (s *Syncer) Unfollow(ctx context.Context, p peer.ID, keep bool) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	f, ok := s.following[p]
+	if !ok {
+		return fmt.Errorf("not following %s", p.Pretty())
+	}
+
+	f.cancel()
+	delete(s.following, p)
+
+	if !keep {
+		for k, owned := range f.Pins {
+			if !owned {
+				continue
+			}
+			if err := s.release(ctx, k); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.ds.Delete(followKey(p))
+}
+
+// Status returns the state of the published pinset and of the followed
+// publishers.
+func (s *Syncer) Status() *Status {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	st := &Status{
+		Topic:     Topic(s.self()),
+		Seq:       s.published.Seq,
+		Published: len(s.published.Pins),
+		Following: []FollowStatus{},
+	}
+	var peers []string
+	for p := range s.following {
+		peers = append(peers, string(p))
+	}
+	sort.Strings(peers)
+
+	for _, p := range peers {
+		f := s.following[peer.ID(p)]
+		fs := FollowStatus{
+			Peer:       peer.ID(p).Pretty(),
+			Seq:        f.Seq,
+			Pins:       len(f.Pins),
+			LastUpdate: f.lastUpdate,
+		}
+		if f.err != nil {
+			fs.Error = f.err.Error()
+		}
+		st.Following = append(st.Following, fs)
+	}
+	return st
+}
+
+func (s *Syncer) self() peer.ID {
+	id, _ := peer.IDFromPrivateKey(s.sk)
+	return id
+}
+
+// subscribe starts applying the updates of p, it must be called with the
+// lock held.
+func (s *Syncer) subscribe(p peer.ID, f *follow) error {
+	sub, err := s.ps.Subscribe(Topic(p))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	f.cancel = func() {
+		cancel()
+		sub.Cancel()
+	}
+
+	go func() {
+		// pubsub only reaches connected peers
+		if err := s.host.Connect(ctx, pstore.PeerInfo{ID: p}); err != nil {
+			log.Debugf("pinsync: connecting to %s: %s", p.Pretty(), err)
+		}
+
+		for {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				return
+			}
+
+			u, err := openUpdate(p, msg.Data)
+			if err != nil {
+				log.Warningf("pinsync: dropping update from %s: %s", p.Pretty(), err)
+				continue
+			}
+
+			s.apply(ctx, p, u)
+		}
+	}()
+	return nil
+}
+
+// apply mirrors an update of the publisher p. The roots it adds are fetched
+// first, without holding the lock, so that the syncer isn't blocked on the
+// network.
+func (s *Syncer) apply(ctx context.Context, p peer.ID, u *Update) {
+	s.lk.Lock()
+	f, ok := s.following[p]
+	if !ok || u.Seq <= f.Seq {
+		s.lk.Unlock()
+		return
+	}
+	var fetch []string
+	for _, k := range u.Add {
+		if _, ok := f.Pins[k]; !ok {
+			fetch = append(fetch, k)
+		}
+	}
+	s.lk.Unlock()
+
+	var err error
+	for _, k := range fetch {
+		var c *cid.Cid
+		c, err = cid.Decode(k)
+		if err == nil {
+			err = s.fetch(ctx, c)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	// p may have been unfollowed meanwhile
+	if s.following[p] != f || u.Seq <= f.Seq {
+		return
+	}
+
+	if err == nil {
+		err = s.applyUpdate(ctx, f, u)
+	}
+	f.err = err
+	f.lastUpdate = time.Now()
+	if f.err != nil {
+		log.Errorf("pinsync: applying update %d from %s: %s", u.Seq, p.Pretty(), f.err)
+		return
+	}
+
+	f.Seq = u.Seq
+	if err := s.store(followKey(p), &f.pinset); err != nil {
+		log.Error("pinsync: ", err)
+	}
+}
+
+func (s *Syncer) applyUpdate(ctx context.Context, f *follow, u *Update) error {
+	rm := u.Rm
+	if u.Full {
+		keep := make(map[string]bool, len(u.Add))
+		for _, k := range u.Add {
+			keep[k] = true
+		}
+		rm = nil
+		for k := range f.Pins {
+			if !keep[k] {
+				rm = append(rm, k)
+			}
+		}
+	}
+
+	for _, k := range rm {
+		owned, ok := f.Pins[k]
+		if !ok {
+			continue
+		}
+		delete(f.Pins, k)
+		if owned {
+			if err := s.release(ctx, k); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, k := range u.Add {
+		if _, ok := f.Pins[k]; ok {
+			continue
+		}
+
+		c, err := cid.Decode(k)
+		if err != nil {
+			return err
+		}
+
+		pinned, err := s.pin(ctx, c)
+		if err != nil {
+			return err
+		}
+		f.Pins[k] = pinned
+	}
+	return nil
+}
+
+// release unpins a root pinned by the syncer, unless another followed
+// publisher has it as well, in which case it takes over the pin, and the
+// record of that publisher is stored.
+func (s *Syncer) release(ctx context.Context, k string) error {
+	for p, f := range s.following {
+		if _, ok := f.Pins[k]; ok {
+			f.Pins[k] = true
+			return s.store(followKey(p), &f.pinset)
+		}
+	}
+	if _, ok := s.published.Pins[k]; ok {
+		s.published.Pins[k] = true
+		return s.store(publishedKey, &s.published)
+	}
+
+	c, err := cid.Decode(k)
+	if err != nil {
+		return err
+	}
+	return s.unpin(ctx, c)
+}
+
+// fetch fetches the dag of c, before it is pinned: pinning holds the pin
+// lock, which blocks the garbage collector and the other pins, and mustn't
+// be held while waiting on the network.
+func (s *Syncer) fetch(ctx context.Context, c *cid.Cid) error {
+	// mirroring shouldn't slow down interactive requests
+	ctx = exchange.WithClass(ctx, exchange.Background)
+	return dag.FetchGraph(ctx, c, s.dag)
+}
+
+// pin pins c recursively, and returns whether it was pinned by this call.
+// The dag of c must have been fetched: it is only fetched again if the
+// garbage collector removed it since.
+func (s *Syncer) pin(ctx context.Context, c *cid.Cid) (bool, error) {
+	defer s.locker.PinLock().Unlock()
+
+	_, pinned, err := s.pinning.IsPinnedWithType(c, pin.Recursive)
+	if err != nil || pinned {
+		return false, err
+	}
+
+	ctx = exchange.WithClass(ctx, exchange.Background)
+	nd, err := s.dag.Get(ctx, c)
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.pinning.Pin(ctx, nd, true); err != nil {
+		return false, err
+	}
+	return true, s.pinning.Flush()
+}
+
+func (s *Syncer) unpin(ctx context.Context, c *cid.Cid) error {
+	if err := s.pinning.Unpin(ctx, c, true); err != nil && err != pin.ErrNotPinned {
+		return err
+	}
+	return s.pinning.Flush()
+}
+
+func (s *Syncer) send(u *Update) error {
+	msg, err := signUpdate(s.sk, u)
+	if err != nil {
+		return err
+	}
+	return s.ps.Publish(Topic(s.self()), msg)
+}
+
+// snapshotLoop periodically sends the whole published pinset.
+func (s *Syncer) snapshotLoop() {
+	tick := time.NewTicker(SnapshotInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-tick.C:
+		}
+
+		s.lk.Lock()
+		if s.published.Seq == 0 {
+			s.lk.Unlock()
+			continue
+		}
+
+		// snapshots take a sequence number too, so that followers don't
+		// apply an older snapshot after a newer update.
+		s.published.Seq++
+		u := &Update{Seq: s.published.Seq, Full: true, Add: []string{}}
+		for k := range s.published.Pins {
+			u.Add = append(u.Add, k)
+		}
+
+		err := s.store(publishedKey, &s.published)
+		if err == nil {
+			err = s.send(u)
+		}
+		s.lk.Unlock()
+
+		if err != nil {
+			log.Error("pinsync: sending snapshot: ", err)
+		}
+	}
+}
+
+func (s *Syncer) load(k ds.Key, v *pinset) error {
+	val, err := s.ds.Get(k)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return fmt.Errorf("pinsync: stored value for %s is not bytes", k)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return err
+	}
+	if v.Pins == nil {
+		v.Pins = make(map[string]bool)
+	}
+	return nil
+}
+
+func (s *Syncer) store(k ds.Key, v *pinset) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(k, b)
+}
+
+func followKey(p peer.ID) ds.Key {
+	return followPrefix.ChildString(p.Pretty())
+}
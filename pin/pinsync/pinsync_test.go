@@ -0,0 +1,273 @@
+package pinsync
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func testKey(t *testing.T) (ic.PrivKey, peer.ID) {
+	sk, _, err := ic.GenerateKeyPair(ic.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sk, id
+}
+
+func TestSignedUpdate(t *testing.T) {
+	sk, id := testKey(t)
+	_, other := testKey(t)
+
+	msg, err := signUpdate(sk, &Update{Seq: 3, Add: []string{"a"}, Rm: []string{"b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := openUpdate(id, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Seq != 3 || len(u.Add) != 1 || u.Add[0] != "a" || len(u.Rm) != 1 || u.Rm[0] != "b" {
+		t.Fatalf("update decoded wrong: %#v", u)
+	}
+
+	if _, err := openUpdate(other, msg); err == nil {
+		t.Fatal("update from another peer should be rejected")
+	}
+
+	// the signature doesn't cover another update
+	var su signedUpdate
+	if err := json.Unmarshal(msg, &su); err != nil {
+		t.Fatal(err)
+	}
+	su.Update = []byte(`{"Seq":4,"Add":["c"]}`)
+	tampered, err := json.Marshal(&su)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openUpdate(id, tampered); err != ErrBadSignature {
+		t.Fatalf("expected %s, got %v", ErrBadSignature, err)
+	}
+}
+
+func TestApplyUpdates(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := bstore.NewBlockstore(dstore)
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	pinner := pin.NewPinner(dstore, dserv, dserv)
+
+	sk, _ := testKey(t)
+	_, pub := testKey(t)
+
+	s := &Syncer{
+		ctx:       ctx,
+		pinning:   pinner,
+		dag:       dserv,
+		locker:    bstore.NewGCLocker(),
+		ds:        dstore,
+		sk:        sk,
+		published: pinset{Pins: make(map[string]bool)},
+		following: map[peer.ID]*follow{
+			pub: {pinset: pinset{Pins: make(map[string]bool)}},
+		},
+	}
+
+	var keys []string
+	for i := 0; i < 3; i++ {
+		nd := dag.NodeWithData([]byte{byte(i)})
+		c, err := dserv.Add(nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, c.String())
+	}
+
+	// keys[2] is pinned by the user, it must survive its removal from the
+	// followed pinset.
+	nd2, err := dserv.Get(ctx, dag.NodeWithData([]byte{2}).Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pinner.Pin(ctx, nd2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	isPinned := func(k string) bool {
+		keys, err := pinner.RecursiveKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range keys {
+			if c.String() == k {
+				return true
+			}
+		}
+		return false
+	}
+
+	s.apply(ctx, pub, &Update{Seq: 1, Add: keys})
+	for _, k := range keys {
+		if !isPinned(k) {
+			t.Fatalf("%s should be pinned", k)
+		}
+	}
+
+	// stale updates are ignored
+	s.apply(ctx, pub, &Update{Seq: 1, Rm: keys[:1]})
+	if !isPinned(keys[0]) {
+		t.Fatal("stale update was applied")
+	}
+
+	s.apply(ctx, pub, &Update{Seq: 2, Rm: keys[:1]})
+	if isPinned(keys[0]) {
+		t.Fatal("removed root is still pinned")
+	}
+
+	// a snapshot drops everything missing from it
+	s.apply(ctx, pub, &Update{Seq: 5, Full: true, Add: []string{}})
+	if isPinned(keys[1]) {
+		t.Fatal("root missing from snapshot is still pinned")
+	}
+	if !isPinned(keys[2]) {
+		t.Fatal("root pinned by the user was unpinned")
+	}
+
+	st := s.Status()
+	if len(st.Following) != 1 || st.Following[0].Seq != 5 || st.Following[0].Pins != 0 {
+		t.Fatalf("unexpected status: %#v", st.Following)
+	}
+}
+
+func TestReleaseStoresTakeover(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := bstore.NewBlockstore(dstore)
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	pinner := pin.NewPinner(dstore, dserv, dserv)
+
+	sk, _ := testKey(t)
+	_, pub1 := testKey(t)
+	_, pub2 := testKey(t)
+
+	s := &Syncer{
+		ctx:       ctx,
+		pinning:   pinner,
+		dag:       dserv,
+		locker:    bstore.NewGCLocker(),
+		ds:        dstore,
+		sk:        sk,
+		published: pinset{Pins: make(map[string]bool)},
+		following: map[peer.ID]*follow{
+			pub1: {pinset: pinset{Pins: make(map[string]bool)}, cancel: func() {}},
+			pub2: {pinset: pinset{Pins: make(map[string]bool)}, cancel: func() {}},
+		},
+	}
+
+	c, err := dserv.Add(dag.NodeWithData([]byte("shared")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := c.String()
+
+	// both publishers have the root, the first one pinned it
+	s.apply(ctx, pub1, &Update{Seq: 1, Add: []string{k}})
+	s.apply(ctx, pub2, &Update{Seq: 1, Add: []string{k}})
+	if !s.following[pub1].Pins[k] || s.following[pub2].Pins[k] {
+		t.Fatal("expected the first publisher to own the pin")
+	}
+
+	if err := s.Unfollow(ctx, pub1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second publisher took over the pin, which must survive a restart
+	var stored pinset
+	if err := s.load(followKey(pub2), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Pins[k] {
+		t.Fatal("the takeover of the pin wasn't stored")
+	}
+	if _, pinned, err := pinner.IsPinnedWithType(c, pin.Recursive); err != nil || !pinned {
+		t.Fatalf("the root was unpinned: %v", err)
+	}
+}
+
+func TestUnpublishKeepsFollowedRoots(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := bstore.NewBlockstore(dstore)
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	pinner := pin.NewPinner(dstore, dserv, dserv)
+
+	sk, _ := testKey(t)
+	_, pub := testKey(t)
+
+	s := &Syncer{
+		ctx:       ctx,
+		pinning:   pinner,
+		dag:       dserv,
+		locker:    bstore.NewGCLocker(),
+		ds:        dstore,
+		sk:        sk,
+		published: pinset{Pins: make(map[string]bool)},
+		following: map[peer.ID]*follow{
+			pub: {pinset: pinset{Pins: make(map[string]bool)}},
+		},
+	}
+
+	c, err := dserv.Add(dag.NodeWithData([]byte("published and followed")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := c.String()
+
+	// the root is published first, so the followed publisher doesn't own it
+	if err := s.updatePublished(ctx, &Update{}, []*cid.Cid{c}, nil); err != nil {
+		t.Fatal(err)
+	}
+	s.apply(ctx, pub, &Update{Seq: 1, Add: []string{k}})
+	if !s.published.Pins[k] || s.following[pub].Pins[k] {
+		t.Fatal("expected the published pinset to own the pin")
+	}
+
+	u := &Update{}
+	if err := s.updatePublished(ctx, u, nil, []*cid.Cid{c}); err != nil {
+		t.Fatal(err)
+	}
+	if len(u.Rm) != 1 || u.Rm[0] != k {
+		t.Fatalf("expected the root to be removed, got %v", u.Rm)
+	}
+
+	// the followed publisher took over the pin
+	if _, pinned, err := pinner.IsPinnedWithType(c, pin.Recursive); err != nil || !pinned {
+		t.Fatalf("the followed root was unpinned: %v", err)
+	}
+	var stored pinset
+	if err := s.load(followKey(pub), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Pins[k] {
+		t.Fatal("the takeover of the pin wasn't stored")
+	}
+}
@@ -0,0 +1,94 @@
+package pinsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var ErrBadSignature = errors.New("pinsync: invalid update signature")
+
+// Update is a change to the pinset of a publisher.
+type Update struct {
+	// Seq orders the updates of a publisher, followers ignore updates that
+	// are not newer than the last one they applied.
+	Seq uint64
+
+	// Add and Rm are the root cids pinned and unpinned by the update.
+	Add []string
+	Rm  []string
+
+	// Full marks a snapshot of the whole pinset: Add holds every root, and
+	// any mirrored root missing from it is unpinned.
+	Full bool
+}
+
+// signedUpdate is the message sent on the pubsub topic of a publisher.
+type signedUpdate struct {
+	Update    []byte
+	PubKey    []byte
+	Signature []byte
+}
+
+// signUpdate encodes u, signed with sk.
+func signUpdate(sk ic.PrivKey, u *Update) ([]byte, error) {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := sk.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := sk.GetPublic().Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&signedUpdate{
+		Update:    data,
+		PubKey:    pk,
+		Signature: sig,
+	})
+}
+
+// openUpdate decodes an update, and checks that it was signed by the key of
+// the given publisher.
+func openUpdate(publisher peer.ID, msg []byte) (*Update, error) {
+	var su signedUpdate
+	if err := json.Unmarshal(msg, &su); err != nil {
+		return nil, err
+	}
+
+	pk, err := ic.UnmarshalPublicKey(su.PubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return nil, err
+	}
+	if id != publisher {
+		return nil, fmt.Errorf("pinsync: update key does not match publisher %s", publisher.Pretty())
+	}
+
+	ok, err := pk.Verify(su.Update, su.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrBadSignature
+	}
+
+	u := new(Update)
+	if err := json.Unmarshal(su.Update, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
@@ -0,0 +1,173 @@
+// Package audit implements the audit log of a repo, an append-only record of
+// the requests changing the state of the node.
+//
+// The log is a file of JSON entries, one per line. When hash chaining is on,
+// each entry carries the hash of its content and of the entry before it, so
+// that editing or removing entries, other than the last ones, breaks the
+// chain and is found by Verify.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// File is the name of the audit log in the repo directory.
+const File = "audit.log"
+
+// Entry records a request.
+type Entry struct {
+	Time      time.Time
+	Requester string
+	Command   string
+	Arguments []string `json:",omitempty"`
+	Error     string   `json:",omitempty"`
+
+	// Prev is the hash of the entry before, and Hash the hash of this
+	// entry, Prev included. They are only set when chaining.
+	Prev string `json:",omitempty"`
+	Hash string `json:",omitempty"`
+}
+
+// hash returns the hash of the entry, without its Hash field.
+func (e Entry) hash() (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+var (
+	lk sync.Mutex
+	// last caches the hash of the last entry of the logs appended to
+	last = make(map[string]string)
+)
+
+// Append adds the entry at the end of the log at path, chained to the entry
+// before if chain is set.
+func Append(path string, chain bool, e Entry) error {
+	lk.Lock()
+	defer lk.Unlock()
+
+	e.Prev, e.Hash = "", ""
+	if chain {
+		prev, ok := last[path]
+		if !ok {
+			entries, err := Read(path)
+			if err != nil {
+				return err
+			}
+			if len(entries) > 0 {
+				prev = entries[len(entries)-1].Hash
+			}
+		}
+
+		e.Prev = prev
+		h, err := e.hash()
+		if err != nil {
+			return err
+		}
+		e.Hash = h
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	last[path] = e.Hash
+	return nil
+}
+
+// Read returns the entries of the log at path, none if it doesn't exist.
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("audit log entry %d: %s", len(entries)+1, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Tail returns the last n entries of the log at path.
+func Tail(path string, n int) ([]Entry, error) {
+	entries, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return entries, nil
+}
+
+// Verify checks the hashes of the chained entries of the log at path, and
+// that each of them follows the entry before. It returns how many entries
+// the log has, and how many of them are chained.
+func Verify(path string) (total, chained int, err error) {
+	entries, err := Read(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	prev := ""
+	for i, e := range entries {
+		if e.Hash == "" {
+			prev = ""
+			continue
+		}
+
+		h, err := e.hash()
+		if err != nil {
+			return 0, 0, err
+		}
+		if h != e.Hash {
+			return 0, 0, fmt.Errorf("audit log entry %d was modified", i+1)
+		}
+		if e.Prev != prev {
+			return 0, 0, fmt.Errorf("audit log entry %d doesn't follow entry %d, entries were removed", i+1, i)
+		}
+
+		prev = e.Hash
+		chained++
+	}
+	return len(entries), chained, nil
+}
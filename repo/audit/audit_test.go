@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testLog(t *testing.T, chain bool, n int) (string, func()) {
+	dir, err := ioutil.TempDir("", "audit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, File)
+
+	for i := 0; i < n; i++ {
+		err := Append(path, chain, Entry{
+			Time:      time.Unix(int64(i), 0).UTC(),
+			Requester: "local",
+			Command:   "pin add",
+			Arguments: []string{"QmPin"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestVerify(t *testing.T) {
+	path, done := testLog(t, true, 5)
+	defer done()
+
+	total, chained, err := Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || chained != 5 {
+		t.Fatalf("expected 5 chained entries, got %d of %d", chained, total)
+	}
+
+	tail, err := Tail(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tail) != 2 || !tail[1].Time.Equal(time.Unix(4, 0)) {
+		t.Fatalf("unexpected tail %v", tail)
+	}
+}
+
+func TestVerifyModified(t *testing.T) {
+	path, done := testLog(t, true, 3)
+	defer done()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = bytes.Replace(b, []byte("pin add"), []byte("pin rm"), 1)
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Verify(path); err == nil {
+		t.Fatal("expected a modified entry to be found")
+	}
+}
+
+func TestVerifyRemoved(t *testing.T) {
+	path, done := testLog(t, true, 3)
+	defer done()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	b = append(lines[0], lines[2]...)
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Verify(path); err == nil {
+		t.Fatal("expected a removed entry to be found")
+	}
+}
+
+func TestUnchained(t *testing.T) {
+	path, done := testLog(t, false, 2)
+	defer done()
+
+	if err := Append(path, true, Entry{Command: "key gen"}); err != nil {
+		t.Fatal(err)
+	}
+
+	total, chained, err := Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || chained != 1 {
+		t.Fatalf("expected 1 chained entry of 3, got %d of %d", chained, total)
+	}
+}
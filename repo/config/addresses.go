@@ -2,7 +2,12 @@ package config
 
 // Addresses stores the (string) multiaddr addresses for the node.
 type Addresses struct {
-	Swarm   []string // addresses for the swarm network
-	API     string   // address for the local API (RPC)
-	Gateway string   // address to listen on for IPFS HTTP object gateway
+	Swarm                []string // addresses for the swarm network
+	Announce             []string // swarm addresses to announce instead of the listen addresses
+	AppendAnnounce       []string // swarm addresses to announce in addition to the listen addresses
+	NoAnnounce           []string // swarm addresses or /ipcidr subnets not to announce
+	NoAnnounceInterfaces []string // network interfaces whose addresses are not announced
+	API                  string   // address for the local API (RPC)
+	Gateway              Strings  // addresses to listen on for IPFS HTTP object gateway
+	P2PProxy             string   // loopback address for the /p2p/ HTTP proxy, off if empty
 }
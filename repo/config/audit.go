@@ -0,0 +1,11 @@
+package config
+
+// Audit contains the options of the audit log, which records the requests
+// changing the state of the node in the audit.log file of the repo.
+type Audit struct {
+	Enabled bool
+
+	// HashChain chains the entries of the log with their hashes, so that
+	// 'ipfs audit verify' finds entries that were modified or removed.
+	HashChain bool
+}
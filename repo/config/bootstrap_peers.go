@@ -33,6 +33,22 @@ var DefaultBootstrapAddresses = []string{
 	"/ip6/2604:a880:1:20::1d9:6001/tcp/4001/ipfs/QmSoLju6m7xTh3DuokvT3886QRYqxAzb1kShaanJgW36yx",  // jupiter.i.ipfs.io
 }
 
+// DefaultBootstrapSet is the set of default bootstrap peers of 'ipfs init'.
+const DefaultBootstrapSet = "public"
+
+// DefaultBootstrapSets are the default bootstrap peers of the environments a
+// node may run in, added with 'ipfs bootstrap add default --set'.
+var DefaultBootstrapSets = map[string][]string{
+	// the public network
+	"public": DefaultBootstrapAddresses,
+	// LAN mode: the peers of the local network are found with mDNS, and the
+	// public peers can't be dialed
+	"lan": {},
+	// private networks: the public peers don't have the swarm key, the peers
+	// of the network have to be added by hand
+	"pnet": {},
+}
+
 // BootstrapPeer is a peer used to bootstrap the network.
 type BootstrapPeer iaddr.IPFSAddr
 
@@ -47,7 +63,17 @@ func (c *Config) BootstrapPeers() ([]BootstrapPeer, error) {
 // if it fails, it returns a meaningful error for the user.
 // This is here (and not inside cmd/ipfs/init) because of module dependency problems.
 func DefaultBootstrapPeers() ([]BootstrapPeer, error) {
-	ps, err := ParseBootstrapPeers(DefaultBootstrapAddresses)
+	return DefaultBootstrapSetPeers(DefaultBootstrapSet)
+}
+
+// DefaultBootstrapSetPeers returns the (parsed) default bootstrap peers of the
+// given set of DefaultBootstrapSets.
+func DefaultBootstrapSetPeers(set string) ([]BootstrapPeer, error) {
+	addrs, ok := DefaultBootstrapSets[set]
+	if !ok {
+		return nil, fmt.Errorf("unknown set of bootstrap peers %q, expected public, lan or pnet", set)
+	}
+	ps, err := ParseBootstrapPeers(addrs)
 	if err != nil {
 		return nil, fmt.Errorf(`failed to parse hardcoded bootstrap peers: %s
 This is a problem with the ipfs codebase. Please report it to the dev team.`, err)
@@ -28,10 +28,21 @@ type Config struct {
 	Gateway          Gateway               // local node's gateway server options
 	SupernodeRouting SupernodeClientConfig // local node's routing servers (if SupernodeRouting enabled)
 	API              API                   // local node's API settings
+	Audit            Audit                 // local node's audit log options
+	Exchange         Exchange              // local node's block exchange options
 	Swarm            SwarmConfig
+	Import           Import
+	Peering          Peering
+	Share            Share
+	Version          Version
+	Watchdog         Watchdog
 
 	Reprovider   Reprovider
 	Experimental Experiments
+
+	// Features turns features on or off by name, overriding their
+	// Experimental options. See Features for the known ones.
+	Features map[string]bool `json:",omitempty"`
 }
 
 const (
@@ -19,6 +19,20 @@ type Datastore struct {
 	NoSync          bool
 	HashOnRead      bool
 	BloomFilterSize int
+
+	// TrackAccessTimes records when blocks were last used, so that the
+	// automatic gc evicts the least recently used blocks first.
+	TrackAccessTimes      bool
+	AccessTimeGranularity string // in s, m, h
+
+	// ChangeFeed logs the blocks added to the repo, for 'ipfs repo changes'.
+	ChangeFeed bool `json:",omitempty"`
+
+	// MaintenanceMaxRate and MaintenanceMaxOps pace the deletions of gc
+	// sweeps and the reads of repo and pin verification, so that they
+	// don't slow down the rest of the node.
+	MaintenanceMaxRate string `json:",omitempty"` // in B, kB, MB, ... per second
+	MaintenanceMaxOps  int    `json:",omitempty"` // blocks per second
 }
 
 func (d *Datastore) ParamData() []byte {
@@ -0,0 +1,16 @@
+package config
+
+// Exchange contains options for fetching blocks from other nodes.
+type Exchange struct {
+	// HTTPSources are urls of gateways to fetch blocks from, in addition to
+	// bitswap.
+	HTTPSources []string
+
+	// MaxConcurrentRequests limits the number of block requests in flight
+	// for each priority class: "interactive", "background" or "bulk".
+	MaxConcurrentRequests map[string]int
+
+	// PinFetchWorkers is the number of blocks a recursive pin keeps
+	// requested at once. 0 means the default.
+	PinFetchWorkers int
+}
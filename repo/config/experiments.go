@@ -1,6 +1,9 @@
 package config
 
+// Experiments are the options features were turned on with before the
+// Features section. They are still honoured, unless overridden there.
 type Experiments struct {
 	FilestoreEnabled bool
 	ShardingEnabled  bool
+	P2pHttpProxy     bool
 }
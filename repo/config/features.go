@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Stability tells how far along a feature is.
+type Stability string
+
+const (
+	// Experimental features may change or go away, and aren't enabled by
+	// default.
+	Experimental Stability = "experimental"
+	// Beta features are complete, but still gathering feedback.
+	Beta Stability = "beta"
+	// Deprecated features are going away.
+	Deprecated Stability = "deprecated"
+)
+
+// Feature is a subsystem which can be turned on or off with the Features
+// section of the config, or 'ipfs features enable/disable'.
+type Feature struct {
+	Name        string
+	Description string
+	Stability   Stability
+	Default     bool
+
+	// legacy returns the value of the Experimental option the feature used
+	// to be set with, if any.
+	legacy func(*Config) bool
+}
+
+// Features lists the features known to this version.
+var Features = []Feature{
+	{
+		Name:        "filestore",
+		Description: "Add files without copying them into the repo, with 'ipfs add --nocopy'.",
+		Stability:   Experimental,
+		legacy:      func(c *Config) bool { return c.Experimental.FilestoreEnabled },
+	},
+	{
+		Name:        "sharding",
+		Description: "Shard large directories over several blocks.",
+		Stability:   Experimental,
+		legacy:      func(c *Config) bool { return c.Experimental.ShardingEnabled },
+	},
+	{
+		Name:        "pubsub",
+		Description: "Publish-subscribe messaging between peers, 'ipfs pubsub' and 'ipfs pin sync'.",
+		Stability:   Experimental,
+	},
+	{
+		Name:        "p2p-http",
+		Description: "Proxy HTTP requests to other nodes over libp2p, 'ipfs p2p http' and the /p2p/ proxy on Addresses.P2PProxy.",
+		Stability:   Experimental,
+		legacy:      func(c *Config) bool { return c.Experimental.P2pHttpProxy },
+	},
+}
+
+// FeatureByName returns the feature with the given name.
+func FeatureByName(name string) (Feature, error) {
+	for _, f := range Features {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+
+	names := make([]string, 0, len(Features))
+	for _, f := range Features {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return Feature{}, fmt.Errorf("unknown feature %q, known features are %v", name, names)
+}
+
+// FeatureEnabled returns whether the named feature is enabled, in the
+// Features section, or else by the Experimental option it used to be set
+// with. Unknown features are disabled.
+func (c *Config) FeatureEnabled(name string) bool {
+	f, err := FeatureByName(name)
+	if err != nil {
+		return false
+	}
+	if on, ok := c.Features[name]; ok {
+		return on
+	}
+	if f.legacy != nil && f.legacy(c) {
+		return true
+	}
+	return f.Default
+}
@@ -6,4 +6,46 @@ type Gateway struct {
 	RootRedirect string
 	Writable     bool
 	PathPrefixes []string
+
+	// NoFetch makes the gateway serve only content already in the local
+	// repo instead of fetching it from the network.
+	NoFetch bool
+
+	// MaxFetchBlocks and MaxFetchBytes cap the blocks and bytes the gateway
+	// fetches from the network to serve a single request, so that deep or
+	// huge dags can't be used to exhaust it. Zero means no limit.
+	MaxFetchBlocks int
+	MaxFetchBytes  int64
+
+	// MediaPlaylists enables serving HLS playlists and DASH manifests of MPEG
+	// transport streams with ?playlist=hls or ?playlist=dash, made of byte
+	// ranges of the stored file.
+	MediaPlaylists bool
+
+	// Listeners maps gateway addresses (from Addresses.Gateway) to options
+	// overriding the ones above for that address.
+	Listeners map[string]GatewayListener
+
+	// Hosts maps hostnames to the way the gateway serves them.
+	Hosts map[string]GatewayHost
+}
+
+// GatewayListener contains options for the HTTP gateway server, specific to
+// a listening address.
+type GatewayListener struct {
+	NoFetch bool
+}
+
+// GatewayHost contains options for the HTTP gateway server, specific to a
+// hostname.
+type GatewayHost struct {
+	// Root is the /ipfs/ or /ipns/ path served at the root of the hostname,
+	// e.g. "/ipns/example.com".
+	Root string
+
+	// RootRedirect is a url to redirect requests for "/" to.
+	RootRedirect string
+
+	// PathPrefixes replaces Gateway.PathPrefixes for the hostname if set.
+	PathPrefixes []string
 }
@@ -2,6 +2,8 @@ package config
 
 import (
 	"encoding/base64"
+	"time"
+
 	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
 )
 
@@ -26,3 +28,22 @@ func (i *Identity) DecodePrivateKey(passphrase string) (ic.PrivKey, error) {
 	// TODO(security)
 	return ic.UnmarshalPrivateKey(pkb)
 }
+
+// IdentityBundle is a node identity exported with 'ipfs key export-identity'
+// to move it to a new repo with 'ipfs init --import-identity'.
+type IdentityBundle struct {
+	Identity Identity
+
+	// Keys are the IPNS keys of the keystore by name, encoded like the
+	// identity private key.
+	Keys map[string]string
+
+	// Records are the last IPNS records published with the identity and the
+	// keys, by name, so that the new node continues their sequence numbers.
+	Records map[string][]byte
+
+	// Peering are the addresses of Peering.Peers.
+	Peering []string
+
+	Exported time.Time
+}
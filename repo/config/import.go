@@ -0,0 +1,33 @@
+package config
+
+// Import sets the defaults used to turn data into objects by add, files
+// write, dag put and object put, so that the same data gets the same CIDs
+// whatever tool adds it. The options of the commands take precedence. Files
+// write only follows CidVersion and HashFunction: it edits files in place, in
+// its own layout.
+type Import struct {
+	// CidVersion is the CID version of new objects. Version 0 only allows
+	// sha2-256, other hash functions switch to version 1.
+	CidVersion int
+
+	// HashFunction is the multihash function of new objects.
+	HashFunction string
+
+	// RawLeaves stores the data of files in raw blocks rather than unixfs
+	// leaves. It is implied by CID version 1.
+	RawLeaves bool
+
+	// Chunker is the chunking algorithm used to split files, as taken by
+	// 'ipfs add --chunker'.
+	Chunker string
+
+	// Layout is the dag layout of files, "balanced" or "trickle".
+	Layout string
+}
+
+// Default import settings.
+const (
+	DefaultHashFunction = "sha2-256"
+	DefaultChunker      = "size-262144"
+	DefaultLayout       = "balanced"
+)
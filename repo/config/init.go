@@ -15,7 +15,12 @@ func Init(out io.Writer, nBitsForKeypair int) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	return InitWithIdentity(identity)
+}
 
+// InitWithIdentity returns the default config for a node with an existing
+// identity.
+func InitWithIdentity(identity Identity) (*Config, error) {
 	bootstrapPeers, err := DefaultBootstrapPeers()
 	if err != nil {
 		return nil, err
@@ -36,8 +41,11 @@ func Init(out io.Writer, nBitsForKeypair int) (*Config, error) {
 				// "/ip4/0.0.0.0/udp/4002/utp", // disabled for now.
 				"/ip6/::/tcp/4001",
 			},
-			API:     "/ip4/127.0.0.1/tcp/5001",
-			Gateway: "/ip4/127.0.0.1/tcp/8080",
+			Announce:       []string{},
+			AppendAnnounce: []string{},
+			NoAnnounce:     []string{},
+			API:            "/ip4/127.0.0.1/tcp/5001",
+			Gateway:        Strings{"/ip4/127.0.0.1/tcp/8080"},
 		},
 
 		Datastore: datastore,
@@ -62,14 +70,36 @@ func Init(out io.Writer, nBitsForKeypair int) (*Config, error) {
 			RootRedirect: "",
 			Writable:     false,
 			PathPrefixes: []string{},
+			NoFetch:      false,
+			Listeners:    map[string]GatewayListener{},
+			Hosts:        map[string]GatewayHost{},
 			HTTPHeaders: map[string][]string{
 				"Access-Control-Allow-Origin":  []string{"*"},
 				"Access-Control-Allow-Methods": []string{"GET"},
 				"Access-Control-Allow-Headers": []string{"X-Requested-With"},
 			},
 		},
+		Peering: Peering{
+			Peers: []string{},
+		},
+		Exchange: Exchange{
+			HTTPSources:           []string{},
+			MaxConcurrentRequests: map[string]int{},
+		},
 		Reprovider: Reprovider{
 			Interval: "12h",
+			Filters:  []string{},
+			Rules:    []AnnounceRule{},
+		},
+		Watchdog: Watchdog{
+			Interval: DefaultWatchdogInterval,
+			Timeout:  DefaultWatchdogTimeout,
+		},
+		Import: Import{
+			CidVersion:   0,
+			HashFunction: DefaultHashFunction,
+			Chunker:      DefaultChunker,
+			Layout:       DefaultLayout,
 		},
 	}
 
@@ -4,5 +4,21 @@ type Ipns struct {
 	RepublishPeriod string
 	RecordLifetime  string
 
+	// RecordValidityType is the validity of the records published, "eol"
+	// (the default) for records expiring after RecordLifetime, or
+	// "perpetual".
+	RecordValidityType string `json:",omitempty"`
+
 	ResolveCacheSize int
+
+	// ResolveNegativeCacheTTL is how long a dns name that failed to resolve
+	// is not looked up again, as a duration string. Defaults to 30s, "0"
+	// disables negative caching.
+	ResolveNegativeCacheTTL string
+
+	// ResolveQuorum is how many records are collected before picking the
+	// best one, the DHT default if 0. ResolveTimeout bounds each resolution,
+	// as a duration string.
+	ResolveQuorum  int    `json:",omitempty"`
+	ResolveTimeout string `json:",omitempty"`
 }
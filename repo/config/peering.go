@@ -0,0 +1,9 @@
+package config
+
+// Peering contains the peers the node stays connected to.
+type Peering struct {
+	// Peers are the addresses of the peers, in the same format as the
+	// bootstrap peers, e.g. "/ip4/1.2.3.4/tcp/4001/ipfs/Qm...". A peer may
+	// be listed with several addresses.
+	Peers []string
+}
@@ -0,0 +1,108 @@
+package config
+
+// Transformer changes a config in place.
+type Transformer func(c *Config) error
+
+// Profile is a named set of config changes, applied with 'ipfs config profile
+// apply'.
+type Profile struct {
+	Description string
+	Transform   Transformer
+}
+
+// defaultServerFilters are the private and reserved address ranges a node
+// running in a datacenter shouldn't dial, as hosting providers may take
+// scanning them for an attack.
+var defaultServerFilters = []string{
+	"/ip4/10.0.0.0/ipcidr/8",
+	"/ip4/100.64.0.0/ipcidr/10",
+	"/ip4/169.254.0.0/ipcidr/16",
+	"/ip4/172.16.0.0/ipcidr/12",
+	"/ip4/192.0.0.0/ipcidr/24",
+	"/ip4/192.0.0.0/ipcidr/29",
+	"/ip4/192.0.0.8/ipcidr/32",
+	"/ip4/192.0.0.170/ipcidr/32",
+	"/ip4/192.0.0.171/ipcidr/32",
+	"/ip4/192.0.2.0/ipcidr/24",
+	"/ip4/192.168.0.0/ipcidr/16",
+	"/ip4/198.18.0.0/ipcidr/15",
+	"/ip4/198.51.100.0/ipcidr/24",
+	"/ip4/203.0.113.0/ipcidr/24",
+	"/ip4/240.0.0.0/ipcidr/4",
+}
+
+// Profiles maps the profile names to the profiles.
+var Profiles = map[string]Profile{
+	"server": {
+		Description: "Disables local host discovery and dialing private addresses, for nodes in datacenters.",
+		Transform: func(c *Config) error {
+			c.Swarm.AddrFilters = appendSingle(c.Swarm.AddrFilters, defaultServerFilters)
+			c.Discovery.MDNS.Enabled = false
+			return nil
+		},
+	},
+	"local-discovery": {
+		Description: "Enables local host discovery, undoing the 'server' profile.",
+		Transform: func(c *Config) error {
+			c.Swarm.AddrFilters = deleteEntries(c.Swarm.AddrFilters, defaultServerFilters)
+			c.Discovery.MDNS.Enabled = true
+			return nil
+		},
+	},
+	"test": {
+		Description: "Listens on random local ports only and connects to no one, for tests.",
+		Transform: func(c *Config) error {
+			c.Addresses.API = "/ip4/127.0.0.1/tcp/0"
+			c.Addresses.Gateway = Strings{"/ip4/127.0.0.1/tcp/0"}
+			c.Addresses.Swarm = []string{"/ip4/127.0.0.1/tcp/0"}
+			c.Bootstrap = []string{}
+			c.Discovery.MDNS.Enabled = false
+			return nil
+		},
+	},
+	"default-networking": {
+		Description: "Restores the default addresses and bootstrap peers, undoing the 'test' profile.",
+		Transform: func(c *Config) error {
+			c.Addresses.API = "/ip4/127.0.0.1/tcp/5001"
+			c.Addresses.Gateway = Strings{"/ip4/127.0.0.1/tcp/8080"}
+			c.Addresses.Swarm = []string{"/ip4/0.0.0.0/tcp/4001", "/ip6/::/tcp/4001"}
+
+			bootstrapPeers, err := DefaultBootstrapPeers()
+			if err != nil {
+				return err
+			}
+			c.SetBootstrapPeers(bootstrapPeers)
+			c.Discovery.MDNS.Enabled = true
+			return nil
+		},
+	},
+}
+
+func appendSingle(a []string, b []string) []string {
+	out := make([]string, 0, len(a)+len(b))
+	seen := make(map[string]bool)
+	for _, list := range [][]string{a, b} {
+		for _, s := range list {
+			if !seen[s] {
+				out = append(out, s)
+				seen[s] = true
+			}
+		}
+	}
+	return out
+}
+
+func deleteEntries(arr []string, del []string) []string {
+	m := make(map[string]bool, len(del))
+	for _, s := range del {
+		m[s] = true
+	}
+
+	out := make([]string, 0, len(arr))
+	for _, s := range arr {
+		if !m[s] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
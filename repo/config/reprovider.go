@@ -2,4 +2,22 @@ package config
 
 type Reprovider struct {
 	Interval string // Time period to reprovide locally stored objects to the network
+
+	// Filters are the names of the announce filters registered by plugins
+	// deciding which blocks are announced, along with Rules.
+	Filters []string
+
+	// Rules decide which blocks are announced: the first rule matching a
+	// block applies, blocks no rule matches are announced.
+	Rules []AnnounceRule
+}
+
+// AnnounceRule announces or skips the blocks matching a selector.
+type AnnounceRule struct {
+	// Match is the selector of the blocks the rule applies to: "all",
+	// "pinned", "pin-roots", "named-pins" or "mfs:<path>".
+	Match string
+
+	// Announce tells whether the blocks matched are announced or skipped.
+	Announce bool
 }
@@ -0,0 +1,22 @@
+package config
+
+// Share contains the options of 'ipfs share'.
+type Share struct {
+	// Gateway is the url of the gateway the path form links point at.
+	Gateway string
+
+	// SubdomainGateway is the url of the gateway serving content at
+	// <cid>.ipfs.<host>, which the subdomain form links point at.
+	SubdomainGateway string
+
+	// RemotePin is the API url of a go-ipfs node pinning shared content,
+	// e.g. "http://pinner.example.com:5001". 'ipfs share --remote' asks it to
+	// pin what is shared.
+	RemotePin string
+}
+
+// Default share settings.
+const (
+	DefaultShareGateway          = "https://ipfs.io"
+	DefaultShareSubdomainGateway = "https://dweb.link"
+)
@@ -4,4 +4,29 @@ type SwarmConfig struct {
 	AddrFilters             []string
 	DisableBandwidthMetrics bool
 	DisableNatPortMap       bool
+
+	// LanMode restricts the node to the peers of the local network.
+	LanMode bool
+
+	// BandwidthSchedule caps the transfer rates during parts of the day.
+	BandwidthSchedule []BandwidthLimit `json:",omitempty"`
+}
+
+// BandwidthLimit caps the transfer rates of some protocols during a daily
+// time window.
+type BandwidthLimit struct {
+	// Start and End are local times of the day, like "08:00". The window
+	// wraps around midnight when End is before Start, and lasts all day when
+	// they are equal.
+	Start string
+	End   string
+
+	// Protocols are the prefixes of the protocols limited, like
+	// "/ipfs/bitswap". All protocols are limited when empty.
+	Protocols []string `json:",omitempty"`
+
+	// MaxIn and MaxOut are the rates allowed per second, like "1MB", for all
+	// the limited protocols together. Empty for no limit.
+	MaxIn  string `json:",omitempty"`
+	MaxOut string `json:",omitempty"`
 }
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"encoding/json"
+)
+
+// Strings is a list of strings that is (un)marshalled from/to a single JSON
+// string when it holds one value, and a JSON array otherwise. It allows
+// settings that used to hold a single value to hold several.
+type Strings []string
+
+// UnmarshalJSON conforms to the json.Unmarshaler interface.
+func (o *Strings) UnmarshalJSON(data []byte) error {
+	switch {
+	case len(data) > 0 && data[0] == '[':
+		var value []string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*o = value
+	case string(data) == "null":
+		*o = nil
+	default:
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*o = nil
+		if value != "" {
+			*o = []string{value}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON conforms to the json.Marshaler interface.
+func (o Strings) MarshalJSON() ([]byte, error) {
+	switch len(o) {
+	case 0:
+		return json.Marshal("")
+	case 1:
+		return json.Marshal(o[0])
+	default:
+		return json.Marshal([]string(o))
+	}
+}
@@ -7,3 +7,15 @@ var CurrentCommit string
 const CurrentVersionNumber = "0.4.9"
 
 const ApiVersion = "/go-ipfs/" + CurrentVersionNumber + "/"
+
+// Version contains options for checking the local version against the
+// network.
+type Version struct {
+	// NoCheck disables the daemon's periodic version check.
+	NoCheck bool
+
+	// ReleaseName is the IPNS name of a release record, a JSON document like
+	// {"Version": "0.4.10"} describing the latest release. When empty, only
+	// the versions of connected peers are compared.
+	ReleaseName string
+}
@@ -0,0 +1,25 @@
+package config
+
+// Watchdog contains the options of the daemon's watchdog, which probes the
+// subsystems of the node and dumps the goroutine stacks when one stalls.
+type Watchdog struct {
+	// Disabled turns the watchdog off.
+	Disabled bool
+
+	// Interval is how often the subsystems are probed, and Timeout how long
+	// they have to answer before they are considered stalled, e.g. "30s".
+	// The defaults apply when empty.
+	Interval string
+	Timeout  string
+
+	// Restart restarts the stalled subsystems, as 'ipfs daemon
+	// restart-service' does. The daemon shuts down when one can't be
+	// restarted in place, for its supervisor to restart it.
+	Restart bool
+}
+
+// Default watchdog settings.
+const (
+	DefaultWatchdogInterval = "30s"
+	DefaultWatchdogTimeout  = "1m"
+)
@@ -32,7 +32,7 @@ import (
 var log = logging.Logger("fsrepo")
 
 // version number that we are currently expecting to see
-var RepoVersion = 5
+var RepoVersion = 6
 
 var migrationInstructions = `See https://github.com/ipfs/fs-repo-migrations/blob/master/run.md
 Sorry for the inconvenience. In the future, these will run automatically.`
@@ -116,6 +116,15 @@ func Open(repoPath string) (repo.Repo, error) {
 }
 
 func open(repoPath string) (repo.Repo, error) {
+	r, err := openVersion(repoPath, RepoVersion)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// openVersion opens the repo at repoPath, which must be at the given version.
+func openVersion(repoPath string, version int) (*FSRepo, error) {
 	packageLock.Lock()
 	defer packageLock.Unlock()
 
@@ -150,11 +159,11 @@ func open(repoPath string) (repo.Repo, error) {
 		return nil, err
 	}
 
-	if RepoVersion > ver {
+	if version > ver {
 		return nil, ErrNeedMigration
-	} else if ver > RepoVersion {
+	} else if ver > version {
 		// program version too low for existing repo
-		return nil, fmt.Errorf(programTooLowMessage, RepoVersion, ver)
+		return nil, fmt.Errorf(programTooLowMessage, version, ver)
 	}
 
 	// check repo path, then check all constituent parts.
@@ -174,7 +183,7 @@ func open(repoPath string) (repo.Repo, error) {
 		return nil, err
 	}
 
-	if r.config.Experimental.FilestoreEnabled {
+	if r.config.FeatureEnabled("filestore") {
 		r.filemgr = filestore.NewFileManager(r.ds, filepath.Dir(r.path))
 	}
 
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ipfs/go-ipfs/repo/config"
 	"github.com/ipfs/go-ipfs/thirdparty/assert"
@@ -117,3 +118,21 @@ func TestOpenMoreThanOnceInSameProcess(t *testing.T) {
 	assert.Nil(r1.Close(), t)
 	assert.Nil(r2.Close(), t)
 }
+
+func TestIdentityExported(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("exported", t)
+	defer Remove(path)
+
+	_, exported, err := IdentityExported(path)
+	assert.Nil(err, t)
+	assert.False(exported, t, "fresh repo should not be marked")
+
+	at := time.Now().Truncate(time.Second)
+	assert.Nil(MarkIdentityExported(path, at), t)
+
+	got, exported, err := IdentityExported(path)
+	assert.Nil(err, t)
+	assert.True(exported, t, "repo should be marked")
+	assert.True(got.Equal(at), t, "export time should be recorded")
+}
@@ -0,0 +1,37 @@
+package fsrepo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// IdentityExportedFile marks a repo whose identity was exported to move it
+// to another repo. It is relative to the repo root.
+const IdentityExportedFile = "identity.exported"
+
+// MarkIdentityExported records in the repo at repoPath that its identity was
+// exported, so that it isn't run next to the repo it was imported in.
+func MarkIdentityExported(repoPath string, at time.Time) error {
+	p := filepath.Join(filepath.Clean(repoPath), IdentityExportedFile)
+	return ioutil.WriteFile(p, []byte(at.Format(time.RFC3339)+"\n"), 0644)
+}
+
+// IdentityExported returns when the identity of the repo at repoPath was
+// exported, or false if it wasn't.
+func IdentityExported(repoPath string) (time.Time, bool, error) {
+	p := filepath.Join(filepath.Clean(repoPath), IdentityExportedFile)
+	b, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	// an unreadable time still marks the repo
+	at, _ := time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+	return at, true, nil
+}
@@ -0,0 +1,13 @@
+// +build !windows
+
+package lock
+
+import (
+	"syscall"
+)
+
+// processAlive returns false if no process with the given pid exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err != syscall.ESRCH
+}
@@ -0,0 +1,163 @@
+package lock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+// HolderFile is the filename of the lock holder record, relative to config
+// dir. It is a separate file as rewriting the lock file itself would drop the
+// lock on some systems.
+const HolderFile = "repo.lock.holder"
+
+// LeaseDuration is how long a lock holder is considered alive after it last
+// renewed its lease. Holders renew every third of it.
+var LeaseDuration = 30 * time.Second
+
+// Holder describes the process holding the repo lock.
+type Holder struct {
+	Pid     int
+	Host    string
+	Start   time.Time
+	Renewed time.Time
+}
+
+// HeldError is returned when the repo lock is held by another process.
+type HeldError struct {
+	Path   string
+	Holder *Holder
+}
+
+func (e *HeldError) Error() string {
+	if e.Holder == nil {
+		return fmt.Sprintf("repo at %s is locked by another process", e.Path)
+	}
+
+	h := e.Holder
+	msg := fmt.Sprintf("repo at %s is locked by pid %d on %s since %s", e.Path, h.Pid, h.Host, h.Start.Format(time.RFC3339))
+	if !h.leaseValid() {
+		msg += fmt.Sprintf(" (lease expired at %s, the holder may have crashed)", h.Renewed.Add(LeaseDuration).Format(time.RFC3339))
+	}
+	return msg
+}
+
+func (h *Holder) leaseValid() bool {
+	return time.Since(h.Renewed) < LeaseDuration
+}
+
+// dead returns true only if the holder is provably gone: it ran on this host,
+// its process does not exist anymore and it stopped renewing its lease.
+func (h *Holder) dead() bool {
+	host, err := os.Hostname()
+	if err != nil || host != h.Host {
+		return false
+	}
+	return !h.leaseValid() && !processAlive(h.Pid)
+}
+
+func newHolder() (*Holder, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &Holder{
+		Pid:     os.Getpid(),
+		Host:    host,
+		Start:   now,
+		Renewed: now,
+	}, nil
+}
+
+// ReadHolder returns the holder recorded in confdir, or nil if there is none.
+func ReadHolder(confdir string) (*Holder, error) {
+	b, err := ioutil.ReadFile(path.Join(confdir, HolderFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	h := new(Holder)
+	if err := json.Unmarshal(b, h); err != nil {
+		return nil, fmt.Errorf("invalid lock holder file: %s", err)
+	}
+	return h, nil
+}
+
+// writeHolder replaces the holder file atomically, so that it is never read
+// half written.
+func writeHolder(confdir string, h *Holder) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	p := path.Join(confdir, HolderFile)
+	tmp := p + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// lease keeps the holder record of a taken lock fresh until it is closed.
+type lease struct {
+	confdir string
+	holder  *Holder
+	lk      io.Closer
+	done    chan struct{}
+}
+
+func newLease(confdir string, lk io.Closer) (*lease, error) {
+	h, err := newHolder()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeHolder(confdir, h); err != nil {
+		return nil, err
+	}
+
+	l := &lease{
+		confdir: confdir,
+		holder:  h,
+		lk:      lk,
+		done:    make(chan struct{}),
+	}
+	go l.renew()
+	return l, nil
+}
+
+func (l *lease) renew() {
+	tick := time.NewTicker(LeaseDuration / 3)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			l.holder.Renewed = time.Now()
+			if err := writeHolder(l.confdir, l.holder); err != nil {
+				log.Error("renewing repo lock lease: ", err)
+			}
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *lease) Close() error {
+	close(l.done)
+
+	err := os.Remove(path.Join(l.confdir, HolderFile))
+	if err != nil && !os.IsNotExist(err) {
+		log.Error("removing repo lock holder: ", err)
+	}
+	return l.lk.Close()
+}
@@ -24,8 +24,79 @@ func errPerm(path string) error {
 	return fmt.Errorf("failed to take lock at %s: permission denied", path)
 }
 
+// Lock takes the repo lock and records this process as its holder. When the
+// kernel reports the lock held, a live process holds it, and a *HeldError
+// naming the holder is returned. Only a lock file left behind where locks
+// are emulated by creating the file, by a holder that is provably dead, is
+// taken over.
 func Lock(confdir string) (io.Closer, error) {
-	return lock.Lock(path.Join(confdir, LockFile))
+	lk, err := lock.Lock(path.Join(confdir, LockFile))
+	switch {
+	case err == nil:
+	case isLockedByKernel(err):
+		return nil, heldError(confdir)
+	case isLeftOver(err):
+		lk, err = takeOver(confdir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := newLease(confdir, lk)
+	if err != nil {
+		lk.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// heldError returns the *HeldError of the lock in confdir, with its holder
+// if it can be read.
+func heldError(confdir string) error {
+	h, err := ReadHolder(confdir)
+	if err != nil {
+		log.Warningf("reading repo lock holder: %s", err)
+	}
+	return &HeldError{Path: confdir, Holder: h}
+}
+
+// takeOver removes the lock file left by a dead holder and takes it again.
+// It must only be called when nothing but the existence of the file keeps
+// the lock from being taken.
+func takeOver(confdir string) (io.Closer, error) {
+	h, err := ReadHolder(confdir)
+	if err != nil {
+		log.Warningf("reading repo lock holder: %s", err)
+	}
+	if h == nil || !h.dead() {
+		return nil, &HeldError{Path: confdir, Holder: h}
+	}
+
+	log.Warningf("taking over repo lock from dead process %d", h.Pid)
+	if err := os.Remove(path.Join(confdir, LockFile)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	lk, err := lock.Lock(path.Join(confdir, LockFile))
+	if err != nil && (isLockedByKernel(err) || isLeftOver(err)) {
+		// someone else was faster
+		return nil, heldError(confdir)
+	}
+	return lk, err
+}
+
+// isLockedByKernel returns true if the kernel refused the lock: a live
+// process holds it.
+func isLockedByKernel(err error) bool {
+	return err == syscall.EAGAIN || strings.Contains(err.Error(), "resource temporarily unavailable")
+}
+
+// isLeftOver returns true if the lock failed because its file exists, where
+// locks are emulated by creating the file. The file is left behind by a
+// holder that crashed.
+func isLeftOver(err error) bool {
+	s := err.Error()
+	return strings.Contains(s, "Lock Create of") && strings.Contains(s, "file exists")
 }
 
 func Locked(confdir string) (bool, error) {
@@ -35,13 +106,8 @@ func Locked(confdir string) (bool, error) {
 		return false, nil
 	}
 	if lk, err := Lock(confdir); err != nil {
-		// EAGAIN == someone else has the lock
-		if err == syscall.EAGAIN {
-			log.Debugf("Someone else has the lock: %s", path.Join(confdir, LockFile))
-			return true, nil
-		}
-		if strings.Contains(err.Error(), "resource temporarily unavailable") {
-			log.Debugf("Can't lock file: %s.\n reason: %s", path.Join(confdir, LockFile), err.Error())
+		if _, ok := err.(*HeldError); ok {
+			log.Debugf("Someone else has the lock: %s", err)
 			return true, nil
 		}
 
@@ -0,0 +1,93 @@
+package lock
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLockRecordsHolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lk, err := Lock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := ReadHolder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h == nil || h.Pid != os.Getpid() {
+		t.Fatalf("expected this process to be the holder, got %v", h)
+	}
+
+	if err := lk.Close(); err != nil {
+		t.Fatal(err)
+	}
+	h, err = ReadHolder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h != nil {
+		t.Fatal("holder should be removed on unlock")
+	}
+}
+
+func TestHolderDead(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip("cannot run a process: ", err)
+	}
+	gone := cmd.Process.Pid
+	stale := time.Now().Add(-2 * LeaseDuration)
+
+	cases := []struct {
+		holder Holder
+		dead   bool
+	}{
+		{Holder{Pid: gone, Host: host, Renewed: stale}, true},
+		{Holder{Pid: gone, Host: host, Renewed: time.Now()}, false},
+		{Holder{Pid: gone, Host: host + "-other", Renewed: stale}, false},
+		{Holder{Pid: os.Getpid(), Host: host, Renewed: stale}, false},
+	}
+	for i, c := range cases {
+		if c.holder.dead() != c.dead {
+			t.Errorf("case %d: expected dead to be %t", i, c.dead)
+		}
+	}
+}
+
+func TestOnlyLeftOverLocksAreTakenOver(t *testing.T) {
+	cases := []struct {
+		err      error
+		kernel   bool
+		leftOver bool
+	}{
+		{syscall.EAGAIN, true, false},
+		{errors.New("lock /repo/repo.lock: resource temporarily unavailable"), true, false},
+		{errors.New("Lock Create of /repo/repo.lock failed: open /repo/repo.lock: file exists"), false, true},
+		{errors.New("Lock Create of /repo/repo.lock failed: permission denied"), false, false},
+	}
+	for i, c := range cases {
+		if isLockedByKernel(c.err) != c.kernel {
+			t.Errorf("case %d: expected locked by the kernel to be %t", i, c.kernel)
+		}
+		if isLeftOver(c.err) != c.leftOver {
+			t.Errorf("case %d: expected left over to be %t", i, c.leftOver)
+		}
+	}
+}
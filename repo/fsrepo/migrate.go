@@ -0,0 +1,68 @@
+package fsrepo
+
+import (
+	"fmt"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
+)
+
+// externalRepoVersion is the last repo version the fs-repo-migrations tool
+// migrates repos to. The migrations after it ship with ipfs.
+const externalRepoVersion = 5
+
+// Migrate migrates the repo at repoPath to RepoVersion. Repos older than
+// version 5 are migrated to it with the fs-repo-migrations tool first.
+func Migrate(repoPath string) error {
+	ver, err := mfsr.RepoPath(repoPath).Version()
+	if err != nil {
+		return err
+	}
+
+	if ver < externalRepoVersion {
+		if err := mfsr.RunMigration(externalRepoVersion); err != nil {
+			return err
+		}
+	}
+	if ver < 6 {
+		if err := migratePinStore(repoPath); err != nil {
+			return fmt.Errorf("migration to version 6 failed: %s", err)
+		}
+	}
+	return nil
+}
+
+// migratePinStore migrates the repo from version 5 to 6, moving the pins
+// stored as a dag to a datastore key each. The dag is forgotten once the
+// version is written only: until then, the repo is still a valid version 5
+// repo, and the migration can be run again.
+func migratePinStore(repoPath string) error {
+	fmt.Println("  => Running: 5-to-6 migration, moving the pins to the datastore")
+
+	r, err := openVersion(repoPath, 5)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	bs := bstore.NewBlockstore(r.Datastore())
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	recursive, direct, err := pin.MigrateLegacyPins(r.Datastore(), dserv)
+	if err != nil {
+		return err
+	}
+	if err := mfsr.RepoPath(r.path).WriteVersion(6); err != nil {
+		return err
+	}
+	if err := pin.ForgetLegacyPins(r.Datastore()); err != nil {
+		return err
+	}
+
+	fmt.Printf("  => Success: moved %d recursive and %d direct pins, fs-repo has been migrated to version 6.\n", recursive, direct)
+	return nil
+}
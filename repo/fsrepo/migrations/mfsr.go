@@ -33,7 +33,11 @@ func (rp RepoPath) Version() (int, error) {
 	}
 
 	s := strings.TrimSpace(string(c))
-	return strconv.Atoi(s)
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid repo version %q in %s: it should hold the version number of the repo", s, fn)
+	}
+	return v, nil
 }
 
 func (rp RepoPath) CheckVersion(version int) error {
@@ -0,0 +1,298 @@
+package sharing
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// KeySize is the size of the group keys.
+const KeySize = 32
+
+var (
+	ErrBadSignature = errors.New("sharing: invalid message signature")
+
+	// ErrNotSealed is returned for messages which were not sealed with the
+	// key of the group, e.g. sent by peers outside of it.
+	ErrNotSealed = errors.New("sharing: message not sealed with the group key")
+)
+
+// Message kinds sent on the topic of a group.
+const (
+	// kindMembers carries the members of the group, sent by its owner.
+	kindMembers = "members"
+	// kindJoined tells that the sender accepted the invitation to the group.
+	kindJoined = "joined"
+	// kindEntries carries the entries published by the sender.
+	kindEntries = "entries"
+)
+
+// message is sent on the topic of a group, signed by its sender and sealed
+// with the group key.
+type message struct {
+	Kind string
+
+	// Members and Seq are set for kindMembers, the members are the peers
+	// allowed to publish, and whether they joined.
+	Members map[string]bool `json:",omitempty"`
+	Seq     uint64          `json:",omitempty"`
+
+	Entries []*Entry `json:",omitempty"`
+}
+
+// invite is sent on the invitation topic of a peer, signed by the owner of
+// the group. The key is encrypted to the public key of the invited peer, and
+// the rest is sealed with the key.
+type invite struct {
+	To    string
+	Key   []byte
+	Group []byte
+}
+
+// groupInfo is what an invited peer learns about the group, with its key.
+type groupInfo struct {
+	Name    string
+	Owner   string
+	Members map[string]bool
+	Seq     uint64
+}
+
+// signed is a payload signed by the key of its sender.
+type signed struct {
+	Payload   []byte
+	PubKey    []byte
+	Signature []byte
+}
+
+// GroupTopic returns the pubsub topic of the group with the given key. The
+// topic is derived from the key, so that only the members know it.
+func GroupTopic(key []byte) string {
+	return "/ipfs/sharing/" + hex.EncodeToString(derive(key, "topic")[:16])
+}
+
+// derive returns a key for the given use, derived from a group key.
+func derive(key []byte, use string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("ipfs-sharing-v1 " + use))
+	return mac.Sum(nil)
+}
+
+// sign encodes v, signed with sk.
+func sign(sk ic.PrivKey, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := sk.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := sk.GetPublic().Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&signed{
+		Payload:   data,
+		PubKey:    pk,
+		Signature: sig,
+	})
+}
+
+// verify decodes a signed payload into v, and returns the peer which signed
+// it.
+func verify(msg []byte, v interface{}) (peer.ID, error) {
+	var sm signed
+	if err := json.Unmarshal(msg, &sm); err != nil {
+		return "", err
+	}
+
+	pk, err := ic.UnmarshalPublicKey(sm.PubKey)
+	if err != nil {
+		return "", err
+	}
+
+	ok, err := pk.Verify(sm.Payload, sm.Signature)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrBadSignature
+	}
+
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(sm.Payload, v); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// seal encrypts data with the group key, with AES-256-GCM and a random
+// nonce, prepended to the sealed data.
+func seal(key, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// unseal decrypts data sealed with the group key.
+func unseal(key, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, ErrNotSealed
+	}
+	nonce, data := data[:aead.NonceSize()], data[aead.NonceSize():]
+	out, err := aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return nil, ErrNotSealed
+	}
+	return out, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("sharing: the group key must be %d bytes long, not %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(derive(key, "seal"))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealMessage signs m with sk, and seals it with the group key.
+func sealMessage(sk ic.PrivKey, key []byte, m *message) ([]byte, error) {
+	data, err := sign(sk, m)
+	if err != nil {
+		return nil, err
+	}
+	return seal(key, data)
+}
+
+// openMessage opens a message sealed with the group key, and returns it
+// with its sender.
+func openMessage(key, data []byte) (peer.ID, *message, error) {
+	data, err := unseal(key, data)
+	if err != nil {
+		return "", nil, err
+	}
+	m := new(message)
+	from, err := verify(data, m)
+	if err != nil {
+		return "", nil, err
+	}
+	return from, m, nil
+}
+
+// keyEncrypter and keyDecrypter are implemented by the keys which can
+// encrypt, RSA keys in particular.
+type keyEncrypter interface {
+	Encrypt([]byte) ([]byte, error)
+}
+
+type keyDecrypter interface {
+	Decrypt([]byte) ([]byte, error)
+}
+
+// makeInvite returns the invitation of the peer to, whose public key is pk,
+// to the group, signed by the owner key sk.
+func makeInvite(sk ic.PrivKey, to peer.ID, pk ic.PubKey, key []byte, info *groupInfo) ([]byte, error) {
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return nil, err
+	}
+	if id != to {
+		return nil, fmt.Errorf("sharing: the public key is not the one of %s", to.Pretty())
+	}
+	enc, ok := pk.(keyEncrypter)
+	if !ok {
+		return nil, fmt.Errorf("sharing: the key of %s can't encrypt, only RSA keys can", to.Pretty())
+	}
+	ekey, err := enc.Encrypt(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(key, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return sign(sk, &invite{
+		To:    to.Pretty(),
+		Key:   ekey,
+		Group: sealed,
+	})
+}
+
+// openInvite opens an invitation sent to the peer of sk, and returns its
+// sender, with the key and the description of the group. The invitation
+// must be signed by the owner of the group.
+func openInvite(sk ic.PrivKey, data []byte) (peer.ID, []byte, *groupInfo, error) {
+	inv := new(invite)
+	from, err := verify(data, inv)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	self, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if inv.To != self.Pretty() {
+		return "", nil, nil, fmt.Errorf("sharing: invitation sent to %s", inv.To)
+	}
+
+	dec, ok := sk.(keyDecrypter)
+	if !ok {
+		return "", nil, nil, errors.New("sharing: the key of the node can't decrypt invitations, only RSA keys can")
+	}
+	key, err := dec.Decrypt(inv.Key)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if len(key) != KeySize {
+		return "", nil, nil, fmt.Errorf("sharing: invalid group key of %d bytes", len(key))
+	}
+
+	data, err = unseal(key, inv.Group)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	info := new(groupInfo)
+	if err := json.Unmarshal(data, info); err != nil {
+		return "", nil, nil, err
+	}
+	if info.Owner != from.Pretty() {
+		return "", nil, nil, fmt.Errorf("sharing: invitation to %s sent by %s, not by its owner", info.Name, from.Pretty())
+	}
+	return from, key, info, nil
+}
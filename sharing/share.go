@@ -0,0 +1,931 @@
+// Package sharing implements private sharing groups over pubsub. The owner of
+// a group invites peers by sending them the group key, encrypted to their
+// public key. The members publish entries naming encrypted content on the
+// topic of the group, which only the members can read: the messages are
+// sealed with the group key, and signed by their sender.
+package sharing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	pstore "gx/ipfs/QmNUVzEjq3XWJ89hegahPvyfJbTXgTaom48pLb7YBD9gHQ/go-libp2p-peerstore"
+	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	floodsub "gx/ipfs/QmYPKo97ssdv3Bsk9sRAS5ZjahGg9Stzys3vybu3r7VuB5/floodsub"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	p2phost "gx/ipfs/QmcyNeWPsoFGxThGpV8JnJdfUNankKhWCTrbrcFRQda4xR/go-libp2p-host"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var log = logging.Logger("sharing")
+
+// AnnounceInterval is how often the owner of a group resends the members and
+// the pending invitations, and the members resend their entries, so that
+// the peers which missed messages catch up.
+var AnnounceInterval = time.Minute * 5
+
+// MaxPendingInvitations is how many invitations the node keeps until they
+// are accepted or declined, so that peers sending many can't fill the repo.
+var MaxPendingInvitations = 64
+
+var (
+	groupsPrefix  = ds.NewKey("/local/sharing/groups")
+	invitesPrefix = ds.NewKey("/local/sharing/invites")
+)
+
+var ErrNoGroup = errors.New("no such group")
+
+// InviteTopic returns the pubsub topic the given peer receives its
+// invitations on.
+func InviteTopic(p peer.ID) string {
+	return "/ipfs/sharing/invite/" + p.Pretty()
+}
+
+// Entry names content published in a group: the manifest of the encrypted
+// content, see the corecrypt package.
+type Entry struct {
+	Name      string
+	Manifest  string
+	Publisher string
+	Published time.Time
+}
+
+// Member is a peer of a group.
+type Member struct {
+	Peer string
+	// Joined tells whether the peer accepted the invitation.
+	Joined bool
+}
+
+// Invitation describes an invitation to a group the node didn't join.
+type Invitation struct {
+	Group    string
+	Owner    string
+	Members  int
+	Received time.Time
+}
+
+// GroupStatus describes a group, without its key.
+type GroupStatus struct {
+	Name    string
+	Owner   string
+	Topic   string
+	Members []Member
+	Entries []*Entry
+}
+
+// Sharer keeps the groups the node is a member of, and the entries
+// published in them.
+type Sharer struct {
+	ctx  context.Context
+	ps   *floodsub.PubSub
+	host p2phost.Host
+	ds   ds.Datastore
+	sk   ic.PrivKey
+
+	lk     sync.Mutex
+	groups map[string]*group
+	// invites are the invitations received, by owner and group name
+	invites map[string]*pendingInvite
+}
+
+// group is the state persisted for each group.
+type group struct {
+	Name  string
+	Owner string
+	Key   []byte
+
+	// Members maps the members of the group, but the owner, to whether
+	// they joined. Seq is the version of the members, set by the owner.
+	Members map[string]bool
+	Seq     uint64
+
+	// Entries are keyed by publisher and name, see entryKey: members only
+	// replace their own entries.
+	Entries map[string]*Entry
+
+	// Invites are the invitations of the members which didn't join yet,
+	// resent until they do. Only the owner has them.
+	Invites map[string][]byte `json:",omitempty"`
+
+	cancel func()
+}
+
+// pendingInvite is the state persisted for each invitation received. Declined
+// invitations are kept, so that the owner resending them doesn't bring
+// them back.
+type pendingInvite struct {
+	Key      []byte
+	Info     *groupInfo
+	Received time.Time
+	Declined bool `json:",omitempty"`
+}
+
+// New creates a Sharer, which receives the invitations of the node, and
+// rejoins the groups joined before the node was restarted.
+func New(ctx context.Context, ps *floodsub.PubSub, host p2phost.Host, dstore ds.Datastore, sk ic.PrivKey) (*Sharer, error) {
+	s := &Sharer{
+		ctx:     ctx,
+		ps:      ps,
+		host:    host,
+		ds:      dstore,
+		sk:      sk,
+		groups:  make(map[string]*group),
+		invites: make(map[string]*pendingInvite),
+	}
+
+	res, err := dstore.Query(dsq.Query{Prefix: invitesPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	invites, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range invites {
+		b, ok := e.Value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("sharing: stored value for %s is not bytes", e.Key)
+		}
+		inv := new(pendingInvite)
+		if err := json.Unmarshal(b, inv); err != nil {
+			return nil, err
+		}
+		s.invites[inviteKey(inv.Info.Owner, inv.Info.Name)] = inv
+	}
+
+	res, err = dstore.Query(dsq.Query{Prefix: groupsPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		b, ok := e.Value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("sharing: stored value for %s is not bytes", e.Key)
+		}
+		g := new(group)
+		if err := json.Unmarshal(b, g); err != nil {
+			return nil, err
+		}
+		g.init()
+		if err := s.subscribe(g); err != nil {
+			return nil, err
+		}
+		s.groups[g.Name] = g
+	}
+
+	sub, err := ps.Subscribe(InviteTopic(s.self()))
+	if err != nil {
+		return nil, err
+	}
+	go s.inviteLoop(sub)
+	go s.announceLoop()
+	return s, nil
+}
+
+// Create creates a group owned by the node, with a new random key.
+func (s *Sharer) Create(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if _, ok := s.groups[name]; ok {
+		return fmt.Errorf("group %s already exists", name)
+	}
+
+	g := &group{
+		Name:  name,
+		Owner: s.self().Pretty(),
+		Key:   key,
+	}
+	g.init()
+	if err := s.store(g); err != nil {
+		return err
+	}
+	if err := s.subscribe(g); err != nil {
+		return err
+	}
+	s.groups[name] = g
+	return nil
+}
+
+// Invite sends the key of the group name to the peer p, whose public key is
+// pk. Only the owner of a group invites members, the invitation is resent
+// until the peer joins. The key must be an RSA key.
+func (s *Sharer) Invite(ctx context.Context, name string, p peer.ID, pk ic.PubKey) error {
+	out, err := s.addInvite(name, p, pk)
+	if err != nil {
+		return err
+	}
+
+	// the peer is dialed without the lock, which the other commands and the
+	// messages received need; pubsub only reaches connected peers
+	if err := s.host.Connect(ctx, pstore.PeerInfo{ID: p}); err != nil {
+		log.Debugf("sharing: connecting to %s: %s", p.Pretty(), err)
+	}
+	return s.publishAll(out)
+}
+
+// addInvite adds p to the members of the group name and stores its
+// invitation. It returns the invitation and the new members, to be sent.
+func (s *Sharer) addInvite(name string, p peer.ID, pk ic.PubKey) ([]outgoing, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	g, ok := s.groups[name]
+	if !ok {
+		return nil, ErrNoGroup
+	}
+	if g.Owner != s.self().Pretty() {
+		return nil, fmt.Errorf("only the owner of %s, %s, invites members", name, g.Owner)
+	}
+	if p == s.self() {
+		return nil, fmt.Errorf("the owner of %s is a member already", name)
+	}
+
+	// the group only changes once the invitation is stored
+	k := p.Pretty()
+	members := make(map[string]bool, len(g.Members)+1)
+	for m, joined := range g.Members {
+		members[m] = joined
+	}
+	seq := g.Seq
+	if _, ok := members[k]; !ok {
+		members[k] = false
+		seq++
+	}
+
+	inv, err := makeInvite(s.sk, p, pk, g.Key, &groupInfo{
+		Name:    g.Name,
+		Owner:   g.Owner,
+		Members: members,
+		Seq:     seq,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg, err := s.seal(g, &message{Kind: kindMembers, Members: members, Seq: seq})
+	if err != nil {
+		return nil, err
+	}
+
+	oldMembers, oldSeq := g.Members, g.Seq
+	oldInv, hadInv := g.Invites[k]
+	g.Members, g.Seq = members, seq
+	g.Invites[k] = inv
+	if err := s.store(g); err != nil {
+		g.Members, g.Seq = oldMembers, oldSeq
+		if hadInv {
+			g.Invites[k] = oldInv
+		} else {
+			delete(g.Invites, k)
+		}
+		return nil, err
+	}
+	return []outgoing{{InviteTopic(p), inv}, msg}, nil
+}
+
+// Publish names the content of the given manifest in the group name, and
+// sends the entry to the members.
+func (s *Sharer) Publish(name, entry string, manifest *cid.Cid) (*Entry, error) {
+	if err := ValidateName(entry); err != nil {
+		return nil, err
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	g, ok := s.groups[name]
+	if !ok {
+		return nil, ErrNoGroup
+	}
+
+	e := &Entry{
+		Name:      entry,
+		Manifest:  manifest.String(),
+		Publisher: s.self().Pretty(),
+		Published: time.Now(),
+	}
+	g.Entries[entryKey(e.Publisher, entry)] = e
+	if err := s.store(g); err != nil {
+		return nil, err
+	}
+	return e, s.send(g, &message{Kind: kindEntries, Entries: []*Entry{e}})
+}
+
+// Resolve returns the entry published under entry in the group name. Entry
+// is either <publisher>/<name>, or a name only one member published under.
+func (s *Sharer) Resolve(name, entry string) (*Entry, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	g, ok := s.groups[name]
+	if !ok {
+		return nil, ErrNoGroup
+	}
+	if e, ok := g.Entries[entry]; ok {
+		return e, nil
+	}
+
+	var found *Entry
+	for _, e := range g.Entries {
+		if e.Name != entry {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("several members published %s in group %s, give <publisher>/%s", entry, name, entry)
+		}
+		found = e
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no entry %s in group %s", entry, name)
+	}
+	return found, nil
+}
+
+// Leave forgets the group name and its entries. The node still has the key
+// of the group though, removing a member doesn't revoke its access.
+func (s *Sharer) Leave(name string) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	g, ok := s.groups[name]
+	if !ok {
+		return ErrNoGroup
+	}
+
+	g.cancel()
+	delete(s.groups, name)
+	return s.ds.Delete(groupsPrefix.ChildString(name))
+}
+
+// Key returns the key of the group name, which the content published in it
+// is encrypted with.
+func (s *Sharer) Key(name string) ([]byte, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	g, ok := s.groups[name]
+	if !ok {
+		return nil, ErrNoGroup
+	}
+	return g.Key, nil
+}
+
+// Groups returns the groups the node is a member of, by name.
+func (s *Sharer) Groups() []*GroupStatus {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	var names []string
+	for name := range s.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]*GroupStatus, 0, len(names))
+	for _, name := range names {
+		g := s.groups[name]
+		st := &GroupStatus{
+			Name:    g.Name,
+			Owner:   g.Owner,
+			Topic:   GroupTopic(g.Key),
+			Members: []Member{},
+			Entries: []*Entry{},
+		}
+
+		var members []string
+		for p := range g.Members {
+			members = append(members, p)
+		}
+		sort.Strings(members)
+		for _, p := range members {
+			st.Members = append(st.Members, Member{Peer: p, Joined: g.Members[p]})
+		}
+
+		var entries []string
+		for e := range g.Entries {
+			entries = append(entries, e)
+		}
+		sort.Strings(entries)
+		for _, e := range entries {
+			st.Entries = append(st.Entries, g.Entries[e])
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+func (s *Sharer) self() peer.ID {
+	id, _ := peer.IDFromPrivateKey(s.sk)
+	return id
+}
+
+func (g *group) init() {
+	if g.Members == nil {
+		g.Members = make(map[string]bool)
+	}
+	if g.Entries == nil {
+		g.Entries = make(map[string]*Entry)
+	}
+	if g.Invites == nil {
+		g.Invites = make(map[string][]byte)
+	}
+}
+
+// isMember tells whether p may publish in the group.
+func (g *group) isMember(p peer.ID) bool {
+	k := p.Pretty()
+	_, ok := g.Members[k]
+	return ok || k == g.Owner
+}
+
+// inviteLoop keeps the invitations the node receives, until they are
+// accepted with Join or declined.
+func (s *Sharer) inviteLoop(sub *floodsub.Subscription) {
+	defer sub.Cancel()
+
+	for {
+		msg, err := sub.Next(s.ctx)
+		if err != nil {
+			return
+		}
+
+		from, key, info, err := openInvite(s.sk, msg.Data)
+		if err != nil {
+			log.Warningf("sharing: dropping invitation: %s", err)
+			continue
+		}
+		if err := s.receiveInvite(key, info); err != nil {
+			log.Warningf("sharing: invitation to %s of %s: %s", info.Name, from.Pretty(), err)
+		}
+	}
+}
+
+// receiveInvite keeps an invitation for the user to accept or decline.
+func (s *Sharer) receiveInvite(key []byte, info *groupInfo) error {
+	if err := ValidateName(info.Name); err != nil {
+		return err
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if g, ok := s.groups[info.Name]; ok && GroupTopic(g.Key) == GroupTopic(key) {
+		// the owner didn't see that we joined
+		return s.send(g, &message{Kind: kindJoined})
+	}
+
+	k := inviteKey(info.Owner, info.Name)
+	inv, ok := s.invites[k]
+	switch {
+	case ok && inv.Declined:
+		return nil
+	case ok:
+		// a resent invitation, with the current members
+		inv.Key, inv.Info = key, info
+	default:
+		if s.pendingInvites() >= MaxPendingInvitations {
+			return errors.New("too many pending invitations")
+		}
+		inv = &pendingInvite{Key: key, Info: info, Received: time.Now()}
+	}
+	if err := s.storeInvite(inv); err != nil {
+		return err
+	}
+	s.invites[k] = inv
+	return nil
+}
+
+// Invitations returns the pending invitations of the node, by group name.
+func (s *Sharer) Invitations() []*Invitation {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	var keys []string
+	for k, inv := range s.invites {
+		if !inv.Declined {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	out := make([]*Invitation, 0, len(keys))
+	for _, k := range keys {
+		inv := s.invites[k]
+		out = append(out, &Invitation{
+			Group:    inv.Info.Name,
+			Owner:    inv.Info.Owner,
+			Members:  len(inv.Info.Members),
+			Received: inv.Received,
+		})
+	}
+	return out
+}
+
+// Join accepts the invitation to the group name, owned by owner, and tells
+// the members. The owner may be empty if only one invitation is for a
+// group of that name.
+func (s *Sharer) Join(name, owner string) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	k, err := s.findInvite(name, owner)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.groups[name]; ok {
+		return fmt.Errorf("another group is named %s", name)
+	}
+	inv := s.invites[k]
+
+	g := &group{
+		Name:    inv.Info.Name,
+		Owner:   inv.Info.Owner,
+		Key:     inv.Key,
+		Members: inv.Info.Members,
+		Seq:     inv.Info.Seq,
+	}
+	g.init()
+	g.Members[s.self().Pretty()] = true
+	if err := s.store(g); err != nil {
+		return err
+	}
+	if err := s.subscribe(g); err != nil {
+		return err
+	}
+	s.groups[g.Name] = g
+
+	delete(s.invites, k)
+	if err := s.ds.Delete(invitesPrefix.ChildString(k)); err != nil {
+		return err
+	}
+	return s.send(g, &message{Kind: kindJoined})
+}
+
+// Decline declines the invitation to the group name, owned by owner, which
+// may be empty as in Join. The invitation is ignored when the owner sends
+// it again.
+func (s *Sharer) Decline(name, owner string) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	k, err := s.findInvite(name, owner)
+	if err != nil {
+		return err
+	}
+	inv := s.invites[k]
+	inv.Declined = true
+	// the key isn't needed to ignore the invitation
+	inv.Key = nil
+	return s.storeInvite(inv)
+}
+
+// findInvite returns the key of the pending invitation to the group name of
+// owner, or of the only one to a group of that name if owner is empty. It
+// must be called with the lock held.
+func (s *Sharer) findInvite(name, owner string) (string, error) {
+	if owner != "" {
+		k := inviteKey(owner, name)
+		if inv, ok := s.invites[k]; ok && !inv.Declined {
+			return k, nil
+		}
+		return "", fmt.Errorf("no invitation to %s from %s", name, owner)
+	}
+
+	var found []string
+	for k, inv := range s.invites {
+		if inv.Info.Name == name && !inv.Declined {
+			found = append(found, k)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no invitation to %s", name)
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("several owners invited the node to a group named %s, give the owner", name)
+	}
+}
+
+// pendingInvites returns the number of invitations neither accepted nor
+// declined. It must be called with the lock held.
+func (s *Sharer) pendingInvites() int {
+	n := 0
+	for _, inv := range s.invites {
+		if !inv.Declined {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Sharer) storeInvite(inv *pendingInvite) error {
+	b, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(invitesPrefix.ChildString(inviteKey(inv.Info.Owner, inv.Info.Name)), b)
+}
+
+// entryKey returns the key of the entry name of publisher in the entries of
+// a group. Entry names have no slashes, and peer IDs are base58.
+func entryKey(publisher, name string) string {
+	return publisher + "/" + name
+}
+
+// inviteKey returns the key of the invitation to the group name of owner.
+// Group names have no slashes, and peer IDs are base58.
+func inviteKey(owner, name string) string {
+	return owner + "/" + name
+}
+
+// subscribe starts receiving the messages of the group, it must be called
+// with the lock held.
+func (s *Sharer) subscribe(g *group) error {
+	sub, err := s.ps.Subscribe(GroupTopic(g.Key))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	g.cancel = func() {
+		cancel()
+		sub.Cancel()
+	}
+
+	peers := []string{g.Owner}
+	for p := range g.Members {
+		peers = append(peers, p)
+	}
+
+	go func() {
+		// pubsub only reaches connected peers
+		for _, k := range peers {
+			p, err := peer.IDB58Decode(k)
+			if err != nil || p == s.self() {
+				continue
+			}
+			if err := s.host.Connect(ctx, pstore.PeerInfo{ID: p}); err != nil {
+				log.Debugf("sharing: connecting to %s: %s", k, err)
+			}
+		}
+
+		for {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				return
+			}
+
+			from, m, err := openMessage(g.Key, msg.Data)
+			if err != nil {
+				log.Warningf("sharing: dropping message in %s: %s", g.Name, err)
+				continue
+			}
+			if from == s.self() {
+				continue
+			}
+
+			s.receive(g, from, m)
+		}
+	}()
+	return nil
+}
+
+// receive applies a message of the group, and answers the members which
+// joined with the entries of the node.
+func (s *Sharer) receive(g *group, from peer.ID, m *message) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	announce, err := s.apply(g, from, m)
+	if err != nil {
+		log.Warningf("sharing: dropping message from %s in %s: %s", from.Pretty(), g.Name, err)
+		return
+	}
+	if err := s.store(g); err != nil {
+		log.Error("sharing: ", err)
+	}
+	if announce {
+		if err := s.sendEntries(g); err != nil {
+			log.Error("sharing: ", err)
+		}
+	}
+}
+
+// apply updates the group with a message from the peer from, and returns
+// whether the entries of the node should be resent.
+func (s *Sharer) apply(g *group, from peer.ID, m *message) (bool, error) {
+	if !g.isMember(from) {
+		return false, fmt.Errorf("%s is not a member", from.Pretty())
+	}
+
+	switch m.Kind {
+	case kindMembers:
+		if from.Pretty() != g.Owner {
+			return false, errors.New("only the owner sends the members")
+		}
+		if m.Seq <= g.Seq {
+			return false, nil
+		}
+		g.Members = m.Members
+		if g.Members == nil {
+			g.Members = make(map[string]bool)
+		}
+		g.Seq = m.Seq
+		return false, nil
+
+	case kindJoined:
+		g.Members[from.Pretty()] = true
+		delete(g.Invites, from.Pretty())
+		return true, nil
+
+	case kindEntries:
+		for _, e := range m.Entries {
+			if e.Publisher != from.Pretty() {
+				return false, fmt.Errorf("entry %s published by %s", e.Name, e.Publisher)
+			}
+			if ValidateName(e.Name) != nil {
+				continue
+			}
+			if _, err := cid.Decode(e.Manifest); err != nil {
+				continue
+			}
+			k := entryKey(e.Publisher, e.Name)
+			if old, ok := g.Entries[k]; ok && !e.Published.After(old.Published) {
+				continue
+			}
+			g.Entries[k] = e
+		}
+		return false, nil
+
+	default:
+		return false, fmt.Errorf("unknown message kind %q", m.Kind)
+	}
+}
+
+func (s *Sharer) send(g *group, m *message) error {
+	msg, err := s.seal(g, m)
+	if err != nil {
+		return err
+	}
+	return s.publishAll([]outgoing{msg})
+}
+
+// outgoing is a message to publish on a topic.
+type outgoing struct {
+	topic string
+	data  []byte
+}
+
+// seal seals m for the members of g.
+func (s *Sharer) seal(g *group, m *message) (outgoing, error) {
+	msg, err := sealMessage(s.sk, g.Key, m)
+	if err != nil {
+		return outgoing{}, err
+	}
+	return outgoing{GroupTopic(g.Key), msg}, nil
+}
+
+// publishAll publishes the messages, in order. Unless they are sent as part
+// of a change made under the lock, it should be called without it.
+func (s *Sharer) publishAll(out []outgoing) error {
+	for _, o := range out {
+		if err := s.ps.Publish(o.topic, o.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendEntries sends the entries the node published in the group.
+func (s *Sharer) sendEntries(g *group) error {
+	out, err := s.sealEntries(g)
+	if err != nil {
+		return err
+	}
+	return s.publishAll(out)
+}
+
+// sealEntries seals the entries the node published in the group, if any.
+func (s *Sharer) sealEntries(g *group) ([]outgoing, error) {
+	self := s.self().Pretty()
+	var entries []*Entry
+	for _, e := range g.Entries {
+		if e.Publisher == self {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	msg, err := s.seal(g, &message{Kind: kindEntries, Entries: entries})
+	if err != nil {
+		return nil, err
+	}
+	return []outgoing{msg}, nil
+}
+
+// announceLoop periodically resends the members and the invitations of the
+// groups the node owns, and the entries it published.
+func (s *Sharer) announceLoop() {
+	tick := time.NewTicker(AnnounceInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-tick.C:
+		}
+
+		// the messages are sealed with the lock, and published without
+		announces := make(map[string][]outgoing)
+		s.lk.Lock()
+		for name, g := range s.groups {
+			out, err := s.announce(g)
+			if err != nil {
+				log.Errorf("sharing: announcing %s: %s", name, err)
+				continue
+			}
+			announces[name] = out
+		}
+		s.lk.Unlock()
+
+		for name, out := range announces {
+			if err := s.publishAll(out); err != nil {
+				log.Errorf("sharing: announcing %s: %s", name, err)
+			}
+		}
+	}
+}
+
+// announce returns the messages announcing the group: its members and
+// invitations if the node owns it, and the entries the node published.
+func (s *Sharer) announce(g *group) ([]outgoing, error) {
+	var out []outgoing
+	if g.Owner == s.self().Pretty() {
+		msg, err := s.seal(g, &message{Kind: kindMembers, Members: g.Members, Seq: g.Seq})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, msg)
+		for k, inv := range g.Invites {
+			p, err := peer.IDB58Decode(k)
+			if err != nil {
+				continue
+			}
+			out = append(out, outgoing{InviteTopic(p), inv})
+		}
+	}
+	entries, err := s.sealEntries(g)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, entries...), nil
+}
+
+func (s *Sharer) store(g *group) error {
+	b, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(groupsPrefix.ChildString(g.Name), b)
+}
+
+// ValidateName tells whether name can name a group or an entry.
+func ValidateName(name string) error {
+	if name == "" || len(name) > 255 {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	for _, r := range name {
+		if r == '/' || r < ' ' {
+			return fmt.Errorf("invalid name %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package sharing
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func testKey(t *testing.T) (ic.PrivKey, peer.ID) {
+	sk, _, err := ic.GenerateKeyPair(ic.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sk, id
+}
+
+func testGroupKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestInvite(t *testing.T) {
+	owner, ownerID := testKey(t)
+	member, memberID := testKey(t)
+	other, _ := testKey(t)
+
+	key := testGroupKey(1)
+	info := &groupInfo{
+		Name:    "friends",
+		Owner:   ownerID.Pretty(),
+		Members: map[string]bool{memberID.Pretty(): false},
+		Seq:     1,
+	}
+
+	inv, err := makeInvite(owner, memberID, member.GetPublic(), key, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, got, gotInfo, err := openInvite(member, inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != ownerID || !bytes.Equal(got, key) {
+		t.Fatal("invitation decoded wrong")
+	}
+	if gotInfo.Name != "friends" || gotInfo.Seq != 1 || len(gotInfo.Members) != 1 {
+		t.Fatalf("group decoded wrong: %#v", gotInfo)
+	}
+
+	// the key is only readable by the invited peer
+	if _, _, _, err := openInvite(other, inv); err == nil {
+		t.Fatal("invitation of another peer should be rejected")
+	}
+	if bytes.Contains(inv, key) {
+		t.Fatal("the invitation holds the key in clear")
+	}
+
+	// the public key must be the one of the invited peer
+	if _, err := makeInvite(owner, memberID, other.GetPublic(), key, info); err == nil {
+		t.Fatal("invitation with the key of another peer should fail")
+	}
+
+	// only the owner invites
+	inv, err = makeInvite(other, memberID, member.GetPublic(), key, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := openInvite(member, inv); err == nil {
+		t.Fatal("invitation not sent by the owner should be rejected")
+	}
+}
+
+func TestSealedMessage(t *testing.T) {
+	sk, id := testKey(t)
+	key := testGroupKey(1)
+
+	data, err := sealMessage(sk, key, &message{Kind: kindJoined})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, m, err := openMessage(key, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != id || m.Kind != kindJoined {
+		t.Fatalf("message decoded wrong: %s %#v", from.Pretty(), m)
+	}
+
+	if _, _, err := openMessage(testGroupKey(2), data); err != ErrNotSealed {
+		t.Fatalf("expected %s, got %v", ErrNotSealed, err)
+	}
+
+	data[len(data)-1] ^= 1
+	if _, _, err := openMessage(key, data); err != ErrNotSealed {
+		t.Fatalf("expected %s, got %v", ErrNotSealed, err)
+	}
+
+	// the signature doesn't cover another message
+	signedData, err := sign(sk, &message{Kind: kindJoined})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sm signed
+	if err := json.Unmarshal(signedData, &sm); err != nil {
+		t.Fatal(err)
+	}
+	sm.Payload = []byte(`{"Kind":"members"}`)
+	tampered, err := json.Marshal(&sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verify(tampered, new(message)); err != ErrBadSignature {
+		t.Fatalf("expected %s, got %v", ErrBadSignature, err)
+	}
+}
+
+func TestApplyMessages(t *testing.T) {
+	sk, self := testKey(t)
+	_, owner := testKey(t)
+	_, member := testKey(t)
+	_, outsider := testKey(t)
+
+	s := &Sharer{
+		ds:     dssync.MutexWrap(ds.NewMapDatastore()),
+		sk:     sk,
+		groups: make(map[string]*group),
+	}
+	g := &group{
+		Name:    "friends",
+		Owner:   owner.Pretty(),
+		Key:     testGroupKey(1),
+		Members: map[string]bool{self.Pretty(): true},
+		Seq:     1,
+	}
+	g.init()
+
+	entry := func(name, manifest string, publisher peer.ID, at time.Time) *message {
+		return &message{Kind: kindEntries, Entries: []*Entry{{
+			Name:      name,
+			Manifest:  manifest,
+			Publisher: publisher.Pretty(),
+			Published: at,
+		}}}
+	}
+	const (
+		manifest1 = "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"
+		manifest2 = "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"
+	)
+	now := time.Now()
+
+	// the member isn't known yet
+	if _, err := s.apply(g, member, entry("a", manifest1, member, now)); err == nil {
+		t.Fatal("entry from a peer outside of the group should be rejected")
+	}
+
+	// only the owner sends the members
+	members := &message{Kind: kindMembers, Seq: 2, Members: map[string]bool{
+		self.Pretty():   true,
+		member.Pretty(): false,
+	}}
+	if _, err := s.apply(g, outsider, members); err == nil {
+		t.Fatal("members from a peer outside of the group should be rejected")
+	}
+	if _, err := s.apply(g, owner, members); err != nil {
+		t.Fatal(err)
+	}
+	if g.Seq != 2 || !g.isMember(member) {
+		t.Fatal("members not applied")
+	}
+
+	// older members are ignored
+	if _, err := s.apply(g, owner, &message{Kind: kindMembers, Seq: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if !g.isMember(member) {
+		t.Fatal("older members applied")
+	}
+
+	announce, err := s.apply(g, member, &message{Kind: kindJoined})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !announce || !g.Members[member.Pretty()] {
+		t.Fatal("joined member not recorded")
+	}
+
+	if _, err := s.apply(g, member, entry("a", manifest1, member, now)); err != nil {
+		t.Fatal(err)
+	}
+	if e := g.Entries[entryKey(member.Pretty(), "a")]; e == nil || e.Manifest != manifest1 {
+		t.Fatal("entry not applied")
+	}
+
+	// members can't publish in the name of others
+	if _, err := s.apply(g, member, entry("b", manifest1, owner, now)); err == nil {
+		t.Fatal("entry published for another peer should be rejected")
+	}
+
+	// members don't replace the entries of others
+	if _, err := s.apply(g, owner, entry("a", manifest2, owner, now.Add(time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	if g.Entries[entryKey(member.Pretty(), "a")].Manifest != manifest1 {
+		t.Fatal("entry of another member replaced")
+	}
+
+	// the newest entry of a member wins
+	if _, err := s.apply(g, member, entry("a", manifest2, member, now.Add(-time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	if g.Entries[entryKey(member.Pretty(), "a")].Manifest != manifest1 {
+		t.Fatal("older entry applied")
+	}
+	if _, err := s.apply(g, member, entry("a", manifest2, member, now.Add(time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	if g.Entries[entryKey(member.Pretty(), "a")].Manifest != manifest2 {
+		t.Fatal("newer entry not applied")
+	}
+
+	// the group is persisted
+	s.groups[g.Name] = g
+	if err := s.store(g); err != nil {
+		t.Fatal(err)
+	}
+	val, err := s.ds.Get(groupsPrefix.ChildString(g.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := new(group)
+	if err := json.Unmarshal(val.([]byte), stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Seq != 2 || len(stored.Entries) != 2 || !bytes.Equal(stored.Key, g.Key) {
+		t.Fatalf("group stored wrong: %#v", stored)
+	}
+
+	st := s.Groups()
+	if len(st) != 1 || len(st[0].Members) != 2 || len(st[0].Entries) != 2 {
+		t.Fatalf("wrong status: %#v", st)
+	}
+
+	// names published by several members are resolved with the publisher
+	if _, err := s.Resolve("friends", "a"); err == nil {
+		t.Fatal("expected a name published by several members to be ambiguous")
+	}
+	e, err := s.Resolve("friends", owner.Pretty()+"/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Publisher != owner.Pretty() {
+		t.Fatalf("resolved the entry of %s", e.Publisher)
+	}
+}
+
+func TestInvitations(t *testing.T) {
+	sk, _ := testKey(t)
+	_, owner := testKey(t)
+	_, other := testKey(t)
+
+	s := &Sharer{
+		ds:      dssync.MutexWrap(ds.NewMapDatastore()),
+		sk:      sk,
+		groups:  make(map[string]*group),
+		invites: make(map[string]*pendingInvite),
+	}
+	info := func(owner peer.ID, seq uint64) *groupInfo {
+		return &groupInfo{Name: "friends", Owner: owner.Pretty(), Seq: seq}
+	}
+
+	// invitations wait for the user
+	if err := s.receiveInvite(testGroupKey(1), info(owner, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.receiveInvite(testGroupKey(1), info(owner, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.receiveInvite(testGroupKey(2), info(other, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.groups) != 0 {
+		t.Fatal("invitation joined without the user accepting it")
+	}
+	invs := s.Invitations()
+	if len(invs) != 2 {
+		t.Fatalf("expected 2 invitations, got %d", len(invs))
+	}
+	if s.invites[inviteKey(owner.Pretty(), "friends")].Info.Seq != 2 {
+		t.Fatal("resent invitation not updated")
+	}
+
+	// two owners invited to a group of that name
+	if _, err := s.findInvite("friends", ""); err == nil {
+		t.Fatal("expected an ambiguous invitation to be refused")
+	}
+
+	if err := s.Decline("friends", other.Pretty()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.receiveInvite(testGroupKey(2), info(other, 2)); err != nil {
+		t.Fatal(err)
+	}
+	invs = s.Invitations()
+	if len(invs) != 1 || invs[0].Owner != owner.Pretty() {
+		t.Fatalf("declined invitation listed: %#v", invs)
+	}
+	if _, err := s.findInvite("friends", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// invitations are persisted
+	val, err := s.ds.Get(invitesPrefix.ChildString(inviteKey(other.Pretty(), "friends")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := new(pendingInvite)
+	if err := json.Unmarshal(val.([]byte), stored); err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Declined || stored.Key != nil {
+		t.Fatalf("declined invitation stored wrong: %#v", stored)
+	}
+
+	// pending invitations are bounded
+	defer func(max int) { MaxPendingInvitations = max }(MaxPendingInvitations)
+	MaxPendingInvitations = 1
+	if err := s.receiveInvite(testGroupKey(3), &groupInfo{Name: "others", Owner: owner.Pretty()}); err == nil {
+		t.Fatal("expected invitations past the limit to be dropped")
+	}
+}
@@ -153,8 +153,45 @@ test_config_cmd() {
   '
 }
 
+test_profile_apply() {
+  test_expect_success "'ipfs config profile ls' lists the profiles" '
+    ipfs config profile ls >profiles_out &&
+    grep "^server:" profiles_out &&
+    grep "^test:" profiles_out
+  '
+
+  test_expect_success "'ipfs config profile apply --dry-run' doesn't change the config" '
+    cp "$IPFS_PATH/config" config_before &&
+    ipfs config profile apply --dry-run server >dry_out &&
+    grep "/ip4/10.0.0.0/ipcidr/8" dry_out &&
+    test_cmp config_before "$IPFS_PATH/config"
+  '
+
+  test_expect_success "'ipfs config profile apply server' works" '
+    ipfs config profile apply server &&
+    ipfs config Swarm.AddrFilters >filters_out &&
+    grep "/ip4/10.0.0.0/ipcidr/8" filters_out &&
+    echo false >expected &&
+    ipfs config Discovery.MDNS.Enabled >actual &&
+    test_cmp expected actual
+  '
+
+  test_expect_success "'ipfs config profile apply local-discovery' reverts it" '
+    ipfs config profile apply local-discovery &&
+    ipfs config Swarm.AddrFilters >filters_out &&
+    test_must_fail grep "/ip4/10.0.0.0/ipcidr/8" filters_out
+  '
+
+  test_expect_success "'ipfs config profile apply' rejects unknown profiles" '
+    test_expect_code 1 ipfs config profile apply nope 2>apply_err &&
+    grep "nope is not a profile" apply_err
+  '
+}
+
 test_init_ipfs
 
+test_profile_apply
+
 # should work offline
 test_config_cmd
 
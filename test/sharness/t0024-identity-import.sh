@@ -0,0 +1,57 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test moving an identity with 'key export-identity' and 'init --import-identity'"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create an IPNS key and a peering peer" '
+	PEERID=$(ipfs config Identity.PeerID) &&
+	KEYID=$(ipfs key gen --type=rsa --size=2048 moved) &&
+	ipfs swarm peering add /ip4/127.0.0.1/tcp/9898/ipfs/QmUWKoHbjsqsSMesRC2Zoscs8edyFz6F77auBB1YBBhgpX
+'
+
+test_expect_success "export the identity" '
+	ipfs key export-identity -l ../identity.json >export_out &&
+	grep "$PEERID" export_out &&
+	grep "$KEYID" export_out &&
+	test -f "$IPFS_PATH/identity.exported"
+'
+
+test_expect_success "export won't overwrite a file" '
+	test_must_fail ipfs key export-identity ../identity.json
+'
+
+test_expect_success "the exported repo won't start" '
+	test_expect_code 1 ipfs daemon 2>daemon_err &&
+	grep "identity of this repo was exported" daemon_err
+'
+
+test_expect_success "init a new repo with the identity" '
+	IPFS_PATH="$(pwd)/.ipfs-new" &&
+	export IPFS_PATH &&
+	ipfs init -e --import-identity=../identity.json >init_out &&
+	grep "imported identity $PEERID" init_out
+'
+
+test_expect_success "the new repo has the identity" '
+	echo "$PEERID" >expected &&
+	ipfs config Identity.PeerID >actual &&
+	test_cmp expected actual &&
+	ipfs key list -l >keys_out &&
+	grep "$KEYID moved" keys_out &&
+	ipfs config Peering.Peers >peers_out &&
+	grep QmUWKoHbjsqsSMesRC2Zoscs8edyFz6F77auBB1YBBhgpX peers_out
+'
+
+test_expect_success "init rejects a tampered identity" '
+	sed -e "s/\"PeerID\": \"Qm/\"PeerID\": \"Qx/" ../identity.json >../bad.json &&
+	test_must_fail env IPFS_PATH="$(pwd)/.ipfs-bad" ipfs init -e --import-identity=../bad.json 2>bad_err &&
+	grep "doesn.t match peer ID" bad_err
+'
+
+test_done
@@ -0,0 +1,62 @@
+#!/bin/sh
+
+test_description="Test ipfs features"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "ipfs features ls lists the features" '
+	ipfs features ls >features_out &&
+	grep "^filestore  *disabled  *experimental " features_out &&
+	grep "^sharding  *disabled  *experimental " features_out &&
+	grep "^pubsub  *disabled  *experimental " features_out &&
+	grep "^p2p-http  *disabled  *experimental " features_out
+'
+
+test_expect_success "the legacy Experimental options still enable features" '
+	ipfs config --json Experimental.ShardingEnabled true &&
+	ipfs features ls >features_out &&
+	grep "^sharding  *enabled " features_out
+'
+
+test_expect_success "ipfs features disable overrides them" '
+	ipfs features disable sharding &&
+	ipfs features ls >features_out &&
+	grep "^sharding  *disabled " features_out &&
+	ipfs config --json Features >features_config &&
+	grep "\"sharding\": false" features_config
+'
+
+test_expect_success "ipfs features enable succeeds" '
+	ipfs features enable filestore pubsub >enable_out &&
+	grep "^filestore  *enabled " enable_out &&
+	grep "^pubsub  *enabled " enable_out &&
+	test $(wc -l <enable_out) -eq 2
+'
+
+test_expect_success "ipfs features enable fails for unknown features" '
+	test_must_fail ipfs features enable warp-drive 2>enable_err &&
+	grep "unknown feature \"warp-drive\"" enable_err
+'
+
+test_expect_success "commands of disabled features fail" '
+	ipfs features disable filestore &&
+	test_must_fail ipfs filestore ls 2>filestore_err &&
+	grep "the filestore feature is disabled, enable it with .ipfs features enable filestore." filestore_err
+'
+
+test_expect_success "they work once the feature is enabled" '
+	ipfs features enable filestore &&
+	ipfs filestore ls
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "the pubsub feature enables pubsub in the daemon" '
+	ipfs pubsub ls
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,63 @@
+#!/bin/sh
+
+test_description="Test ipfs id rotate"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "no rotations at first" '
+	ipfs id continuity >rot_out &&
+	test_must_be_empty rot_out
+'
+
+test_expect_success "ipfs id rotate succeeds" '
+	OLD_ID=$(ipfs config Identity.PeerID) &&
+	ipfs id rotate --type=ed25519 --oldkey=old >rotate_out &&
+	NEW_ID=$(ipfs config Identity.PeerID) &&
+	test "$OLD_ID" != "$NEW_ID"
+'
+
+test_expect_success "ipfs id rotate prints the rotation" '
+	grep "^$OLD_ID -> $NEW_ID " rotate_out
+'
+
+test_expect_success "ipfs id shows the new identity" '
+	test "$(ipfs id -f="<id>")" = "$NEW_ID"
+'
+
+test_expect_success "the old key was kept" '
+	ipfs key list -l | grep "^$OLD_ID *old"
+'
+
+test_expect_success "ipfs id continuity lists the rotation" '
+	ipfs id continuity >rot_out &&
+	test_cmp rotate_out rot_out
+'
+
+test_expect_success "ipfs id rotate won't overwrite a key" '
+	test_must_fail ipfs id rotate --type=ed25519 --oldkey=old &&
+	test "$(ipfs config Identity.PeerID)" = "$NEW_ID"
+'
+
+test_expect_success "a second rotation is chained" '
+	ipfs id rotate --type=ed25519 &&
+	ipfs id continuity >rot_out &&
+	test $(wc -l <rot_out) -eq 2 &&
+	grep "^$NEW_ID -> $(ipfs config Identity.PeerID) " rot_out
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "ipfs id rotate can't run on the daemon" '
+	test_must_fail ipfs id rotate --type=ed25519
+'
+
+test_expect_success "ipfs id continuity rejects invalid peer ids" '
+	test_must_fail ipfs id continuity notapeerid 2>err &&
+	grep "Invalid peer id" err
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -260,6 +260,15 @@ test_expect_success "ipfs cat output looks good" '
 	test_cmp expected actual
 '
 
+test_expect_success "ipfs cat --verify-only succeeds" '
+	ipfs cat --verify-only "$HASH" >actual
+'
+
+test_expect_success "ipfs cat --verify-only output looks good" '
+	echo "$HASH: verified $(wc -c <mountdir/hello.txt | tr -d " ") bytes in 1 blocks" >expected &&
+	test_cmp expected actual
+'
+
 test_expect_success FUSE "cat ipfs/stuff succeeds" '
 	cat "ipfs/$HASH" >actual
 '
@@ -499,6 +508,17 @@ test_expect_success "ipfs cat file fails" '
     test_must_fail ipfs cat $(cat oh_hash)
 '
 
+test_expect_success "ipfs add --report-dedup reports new blocks" '
+    random 300000 51 >dedup_file &&
+    ipfs add -q --report-dedup dedup_file >dedup_out &&
+    grep "^wrote [1-9][0-9]* blocks (.*), [0-9]* blocks (.*) were already stored\$" dedup_out
+'
+
+test_expect_success "ipfs add --report-dedup reports stored blocks" '
+    ipfs add -q --report-dedup dedup_file >dedup_out &&
+    grep "^wrote 0 blocks (0 B), [1-9][0-9]* blocks (.*) were already stored\$" dedup_out
+'
+
 test_add_named_pipe ""
 
 test_add_pwd_is_symlink
@@ -101,13 +101,40 @@ test_ls_cmd_raw_leaves() {
 	'
 }
 
+test_ls_cmd_paging() {
+	test_expect_success "'ipfs ls --count' lists the first links" '
+		ipfs ls --count=2 QmfNy183bXiRVyrhyWtq3TwHn79yHEkiAGFr18P7YNzESj >actual_ls_page &&
+		cat <<-\EOF >expected_ls_page &&
+			QmSix55yz8CzWXf5ZVM9vgEvijnEeeXiTSarVtsqiiCJss 246  d1/
+			QmR3jhV4XpxxPjPT3Y8vNnWvWNvakdcT3H6vqpRBsX1MLy 1143 d2/
+		EOF
+		test_cmp expected_ls_page actual_ls_page
+	'
+
+	test_expect_success "'ipfs ls --sorted --offset --count' lists a page" '
+		ipfs ls --sorted --offset=1 --count=2 QmfNy183bXiRVyrhyWtq3TwHn79yHEkiAGFr18P7YNzESj >actual_ls_page &&
+		cat <<-\EOF >expected_ls_page &&
+			QmR3jhV4XpxxPjPT3Y8vNnWvWNvakdcT3H6vqpRBsX1MLy 1143 d2/
+			QmeomffUNfmQy76CQGy9NdmqEnnHU9soCexBnGU3ezPHVH 13   f1
+		EOF
+		test_cmp expected_ls_page actual_ls_page
+	'
+
+	test_expect_success "'ipfs ls --offset' past the end lists nothing" '
+		ipfs ls --offset=10 QmfNy183bXiRVyrhyWtq3TwHn79yHEkiAGFr18P7YNzESj >actual_ls_page &&
+		test_must_be_empty actual_ls_page
+	'
+}
+
 # should work offline
 test_ls_cmd
+test_ls_cmd_paging
 test_ls_cmd_raw_leaves
 
 # should work online
 test_launch_ipfs_daemon
 test_ls_cmd
+test_ls_cmd_paging
 test_ls_cmd_raw_leaves
 test_kill_ipfs_daemon
 
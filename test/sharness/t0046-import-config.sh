@@ -0,0 +1,104 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the Import config defaults"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create a file" '
+	random 500000 41 > afile
+'
+
+test_expect_success "get hashes with the add options" '
+	HASH_V1=$(ipfs add -q --cid-version=1 afile) &&
+	HASH_BLAKE=$(ipfs add -q --hash=blake2b-256 afile) &&
+	HASH_TRICKLE=$(ipfs add -q --trickle --chunker=size-1000 afile) &&
+	HASH_DAG=$(echo "{\"a\":1}" | ipfs dag put --hash=blake2b-256)
+'
+
+test_expect_success "add uses Import.CidVersion" '
+	ipfs config --json Import.CidVersion 1 &&
+	ipfs add -q afile > out &&
+	echo $HASH_V1 > expected &&
+	test_cmp expected out
+'
+
+test_expect_success "the add option overrides the config" '
+	ipfs add -q --cid-version=0 afile > out &&
+	ipfs config --json Import.CidVersion 0 &&
+	ipfs add -q afile > expected &&
+	test_cmp expected out
+'
+
+test_expect_success "add --hash implies CID version 1" '
+	ipfs add -q --hash=sha2-256 afile > out &&
+	echo $HASH_V1 > expected &&
+	test_cmp expected out &&
+	ipfs add -q --hash=blake2b-256 afile > out &&
+	echo $HASH_BLAKE > expected &&
+	test_cmp expected out &&
+	test_must_fail grep "^Qm" out
+'
+
+test_expect_success "add uses Import.HashFunction" '
+	ipfs config Import.HashFunction blake2b-256 &&
+	ipfs add -q afile > out &&
+	echo $HASH_BLAKE > expected &&
+	test_cmp expected out
+'
+
+test_expect_success "dag put uses Import.HashFunction" '
+	echo "{\"a\":1}" | ipfs dag put > out &&
+	echo $HASH_DAG > expected &&
+	test_cmp expected out
+'
+
+test_expect_success "object new uses Import.HashFunction" '
+	ipfs object new > out &&
+	test_must_fail grep "^Qm" out
+'
+
+test_expect_success "add uses Import.Chunker and Import.Layout" '
+	ipfs config Import.HashFunction sha2-256 &&
+	ipfs config Import.Chunker size-1000 &&
+	ipfs config Import.Layout trickle &&
+	ipfs add -q afile > out &&
+	echo $HASH_TRICKLE > expected &&
+	test_cmp expected out
+'
+
+test_expect_success "add fails on an unknown layout" '
+	ipfs config Import.Layout nope &&
+	test_must_fail ipfs add -q afile
+'
+
+test_expect_success "get the hash of files write without Import settings" '
+	ipfs config Import.Layout balanced &&
+	ipfs config Import.Chunker size-262144 &&
+	ipfs files write --create /plain afile &&
+	HASH_WRITE=$(ipfs files stat --hash /plain)
+'
+
+test_expect_success "files write ignores Import.RawLeaves, Chunker and Layout" '
+	ipfs config --json Import.RawLeaves true &&
+	ipfs config Import.Chunker size-1000 &&
+	ipfs config Import.Layout trickle &&
+	ipfs files write --create /configured afile &&
+	ipfs files stat --hash /configured > out &&
+	echo $HASH_WRITE > expected &&
+	test_cmp expected out
+'
+
+test_expect_success "files write uses Import.CidVersion" '
+	ipfs config --json Import.CidVersion 1 &&
+	ipfs files write --create /v1 afile &&
+	ipfs files stat --hash /v1 > out &&
+	test_must_fail grep "^Qm" out &&
+	ipfs config --json Import.CidVersion 0
+'
+
+test_done
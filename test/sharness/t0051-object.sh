@@ -305,6 +305,10 @@ test_object_content_type() {
 
 	  test_expect_success "'ipfs object get --encoding=xml' returns the correct content type" '
   curl -sI "http://$API_ADDR/api/v0/object/get?arg=$HASH&encoding=xml" | grep -q "^Content-Type: application/xml"
+  '
+
+	  test_expect_success "'ipfs object get' reports its deprecation" '
+    curl -sI "http://$API_ADDR/api/v0/object/get?arg=$HASH" | grep -q "^X-Ipfs-Deprecated: use .ipfs dag get. instead"
   '
 }
 
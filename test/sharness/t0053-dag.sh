@@ -112,6 +112,81 @@ test_dag_cmd() {
 		test_cmp cat_exp cat_out
 	'
 
+	test_expect_success "dag resolve reports remaining path" '
+		ipfs dag resolve $IPLDHASH/sub/beep > resolve_out &&
+		echo "$IPLDHASH/sub/beep" > resolve_exp &&
+		test_cmp resolve_exp resolve_out
+	'
+
+	test_expect_success "dag resolve crosses block boundaries" '
+		ipfs dag resolve --blocks $IPLDHASH/cats/1/water > resolve_blocks_out &&
+		printf "%s\n  %s\n  %s\n" $HASH2 $IPLDHASH $HASH2 > resolve_blocks_exp &&
+		test_cmp resolve_blocks_exp resolve_blocks_out
+	'
+
+	test_expect_success "dag dot exports a dag" '
+		mkdir -p dotdir/sub &&
+		echo "a" > dotdir/a &&
+		echo "b" > dotdir/sub/b &&
+		DOTROOT=$(ipfs add -r -q dotdir | tail -n1) &&
+		ipfs dag dot $DOTROOT > dot_out &&
+		grep "^digraph \"$DOTROOT\" {\$" dot_out &&
+		test $(grep -c " -> " dot_out) = 3 &&
+		grep " \[label=\"sub\"\];\$" dot_out &&
+		test_must_fail grep "style=dashed" dot_out
+	'
+
+	test_expect_success "dag dot stops at the max depth" '
+		ipfs dag dot --max-depth=1 $DOTROOT > dot_out &&
+		test $(grep -c " -> " dot_out) = 2 &&
+		test $(grep -c "style=dashed" dot_out) = 1
+	'
+
+	test_expect_success "dag dot stops at the max number of nodes" '
+		ipfs dag dot --max-nodes=1 $DOTROOT > dot_out &&
+		test_must_fail grep " -> " dot_out
+	'
+
+	test_expect_success "dag dot exports graphml" '
+		ipfs dag dot --format=graphml $DOTROOT > graphml_out &&
+		grep "<graphml" graphml_out &&
+		test $(grep -c "<node " graphml_out) = 4 &&
+		test $(grep -c "<edge " graphml_out) = 3
+	'
+
+	test_expect_success "dag dot rejects unknown formats" '
+		test_must_fail ipfs dag dot --format=png $DOTROOT 2> dot_err &&
+		grep "unknown graph format" dot_err
+	'
+
+	test_expect_success "dag ls lists the links of a node" '
+		mkdir -p lsdir &&
+		for i in 1 2 3 4 5; do echo $i > lsdir/f$i; done &&
+		LSROOT=$(ipfs add -r -q lsdir | tail -n1) &&
+		ipfs dag ls $LSROOT > dag_ls_out &&
+		awk "{print \$3}" dag_ls_out > dag_ls_names &&
+		printf "f1\nf2\nf3\nf4\nf5\n" > dag_ls_exp &&
+		test_cmp dag_ls_exp dag_ls_names
+	'
+
+	test_expect_success "dag ls pages through the links" '
+		ipfs dag ls --offset=1 --count=2 $LSROOT | awk "{print \$3}" > dag_ls_names &&
+		printf "f2\nf3\n" > dag_ls_exp &&
+		test_cmp dag_ls_exp dag_ls_names &&
+		ipfs dag ls --offset=10 $LSROOT > dag_ls_out &&
+		test_must_be_empty dag_ls_out
+	'
+
+	test_expect_success "dag ls reports the total number of links" '
+		ipfs dag ls --count=1 --enc=json $LSROOT > dag_ls_json &&
+		grep "\"Total\": *5" dag_ls_json
+	'
+
+	test_expect_success "dag ls rejects negative offsets" '
+		test_must_fail ipfs dag ls --offset=-1 $LSROOT 2> dag_ls_err &&
+		grep "offset" dag_ls_err
+	'
+
 	test_expect_success "non-canonical cbor input is normalized" '
 	HASH=$(cat ../t0053-dag-data/non-canon.cbor | ipfs dag put --format=cbor --input-enc=raw) &&
 	test $HASH = "zdpuAmxF8q6iTUtkB3xtEYzmc5Sw762qwQJftt5iW8NTWLtjC" ||
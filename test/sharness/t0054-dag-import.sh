@@ -0,0 +1,87 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs dag import"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create two CAR files" '
+	ipfs files mkdir /a &&
+	ipfs files mkdir /b &&
+	echo "file of a" | ipfs files write --create /a/file &&
+	echo "file of b" | ipfs files write --create /b/file &&
+	ROOT_A=$(ipfs files stat --hash /a) &&
+	ROOT_B=$(ipfs files stat --hash /b) &&
+	ipfs files export /a a.car &&
+	ipfs files export /b b.car &&
+	ipfs files rm -r /a &&
+	ipfs files rm -r /b &&
+	ipfs repo gc >/dev/null
+'
+
+test_expect_success "'ipfs dag import --pin-roots' pins the selected roots" '
+	ipfs dag import --pin-roots=$ROOT_A a.car b.car >actual &&
+	cat >expected <<-EOF &&
+	imported 4 blocks
+	root $ROOT_A complete (2 blocks), pinned
+	root $ROOT_B complete (2 blocks), not pinned
+	EOF
+	test_cmp expected actual &&
+	ipfs pin ls --type=recursive -q >pins &&
+	grep "$ROOT_A" pins &&
+	test_must_fail grep "$ROOT_B" pins
+'
+
+test_expect_success "'ipfs dag import' fails on roots not in the archives" '
+	test_must_fail ipfs dag import --pin-roots=$ROOT_B a.car 2>err &&
+	grep "is not a root of the archives" err
+'
+
+test_expect_success "blocks not matching their hash are skipped" '
+	ipfs repo gc >/dev/null &&
+	cp b.car bad.car &&
+	SIZE=$(wc -c <bad.car) &&
+	printf "x" | dd of=bad.car bs=1 seek=$((SIZE - 2)) conv=notrunc 2>/dev/null &&
+	ipfs dag import bad.car >actual &&
+	grep "^imported 1 blocks\$" actual &&
+	grep "^skipped .*: data doesn.t match its hash\$" actual &&
+	grep "^root $ROOT_B incomplete, 1 blocks missing (1 blocks), not pinned\$" actual
+'
+
+test_expect_success "'ipfs dag import' pins all the roots by default" '
+	ipfs dag import a.car b.car &&
+	ipfs pin ls --type=recursive -q >pins &&
+	grep "$ROOT_A" pins &&
+	grep "$ROOT_B" pins
+'
+
+test_expect_success "'ipfs dag import --pin-all=false' pins nothing" '
+	ipfs pin rm $ROOT_A $ROOT_B &&
+	ipfs dag import --pin-all=false a.car >actual &&
+	grep "^root $ROOT_A complete (2 blocks), not pinned\$" actual
+'
+
+test_expect_success "'ipfs dag import --silent' writes nothing" '
+	ipfs dag import --silent --pin-all=false bad.car a.car >actual &&
+	test_must_be_empty actual
+'
+
+test_expect_success "'ipfs dag import --stats --silent' writes the size of the import" '
+	ipfs dag import --stats --silent --dup-check=false a.car b.car >actual &&
+	grep "^imported 4 blocks, [0-9]* B in [0-9.]*s ([0-9.]* [kMG]*B/s)\$" actual &&
+	test $(wc -l <actual) = 1 &&
+	ipfs pin ls --type=recursive -q >pins &&
+	grep "$ROOT_A" pins &&
+	grep "$ROOT_B" pins
+'
+
+test_expect_success "'ipfs dag import --stats' writes the bytes imported" '
+	ipfs dag import --stats --enc=json a.car >actual &&
+	grep "\"Stats\":{\"Bytes\":[1-9]" actual
+'
+
+test_done
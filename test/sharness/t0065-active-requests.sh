@@ -49,5 +49,24 @@ test_expect_success "command shows up as inactive" '
 	grep "log/tail" cmd_out3 | grep "false"
 '
 
+test_expect_success "start another long running command" '
+	ipfs log tail &
+	LOGPID=$!
+	go-sleep 100ms
+'
+
+test_expect_success "can cancel it by id" '
+	LOGID=$(ipfs diag cmds -v | grep "log/tail" | grep "true" | awk "{print \$1}") &&
+	ipfs diag cmds cancel $LOGID
+'
+
+test_expect_success "canceled command exits" '
+	go-timeout 5 sh -c "while kill -0 $LOGPID 2>/dev/null; do go-sleep 100ms; done"
+'
+
+test_expect_success "canceling an unknown command fails" '
+	test_must_fail ipfs diag cmds cancel 9999
+'
+
 test_kill_ipfs_daemon
 test_done
@@ -49,4 +49,25 @@ test_expect_success "output looks good" '
 	grep "Please get fs-repo-migrations from https://dist.ipfs.io" daemon_out > /dev/null
 '
 
+test_expect_success "pin an object in a version 5 repo" '
+	echo "6" > "$IPFS_PATH"/version &&
+	MIGRATED=$(echo "migrated pin" | ipfs add -q) &&
+	echo "5" > "$IPFS_PATH"/version
+'
+
+test_launch_ipfs_daemon --migrate=true
+
+test_expect_success "the repo was migrated to version 6 without fs-repo-migrations" '
+	grep "Running: 5-to-6 migration" actual_daemon &&
+	grep "fs-repo has been migrated to version 6." actual_daemon &&
+	echo "6" >expected &&
+	test_cmp expected "$IPFS_PATH"/version
+'
+
+test_expect_success "the pins are kept by the migration" '
+	ipfs pin ls --type=recursive $MIGRATED
+'
+
+test_kill_ipfs_daemon
+
 test_done
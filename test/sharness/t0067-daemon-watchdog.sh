@@ -0,0 +1,38 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the daemon watchdog"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "watchdog is configured by default" '
+  echo 30s > interval_exp &&
+  echo 1m > timeout_exp &&
+  ipfs config Watchdog.Interval > interval_out &&
+  ipfs config Watchdog.Timeout > timeout_out &&
+  test_cmp interval_exp interval_out &&
+  test_cmp timeout_exp timeout_out
+'
+
+test_expect_success "invalid watchdog durations are rejected" '
+  ipfs config Watchdog.Interval forever &&
+  test_expect_code 1 ipfs daemon > daemon_out 2> daemon_err &&
+  grep "invalid Watchdog.Interval" daemon_err &&
+  ipfs config Watchdog.Interval 100ms
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "a healthy daemon dumps no stacks" '
+  go-sleep 1s &&
+  ipfs version &&
+  test_must_fail ls "$IPFS_PATH"/diagnostics/*.stacks
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,58 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test restarting the services of a running daemon"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "restarting a service needs a daemon" '
+  test_must_fail ipfs daemon restart-service dht 2> err &&
+  grep "daemon" err
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "add some content" '
+  echo "restart me" > file &&
+  HASH=$(ipfs add -q file)
+'
+
+for service in bitswap dht reprovider; do
+  test_expect_success "'ipfs daemon restart-service $service' succeeds" '
+    ipfs daemon restart-service $service > restart_out &&
+    echo "restarted $service" > restart_exp &&
+    test_cmp restart_exp restart_out
+  '
+
+  test_expect_success "the node still works after restarting $service" '
+    ipfs cat $HASH > cat_out &&
+    test_cmp file cat_out &&
+    ipfs swarm peers > /dev/null
+  '
+done
+
+test_expect_success "'ipfs daemon restart-service gateway' succeeds" '
+  ipfs daemon restart-service gateway > restart_out &&
+  echo "restarted gateway" > restart_exp &&
+  test_cmp restart_exp restart_out
+'
+
+test_expect_success "the gateway listens again" '
+  GWAY_MADDR=$(sed -n "s/^Gateway (.*) server listening on //p" actual_daemon | tail -1) &&
+  GWAY_PORT=$(port_from_maddr $GWAY_MADDR) &&
+  curl -sf "http://127.0.0.1:$GWAY_PORT/ipfs/$HASH" > curl_out &&
+  test_cmp file curl_out
+'
+
+test_expect_success "unknown services are rejected" '
+  test_must_fail ipfs daemon restart-service nothing 2> err &&
+  grep "unknown service: nothing (services: bitswap, dht, gateway, reprovider)" err
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,60 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs benchmark"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+BENCH_OPTS="--nodes=2 --size=64KiB --runs=2"
+
+test_expect_success "'ipfs benchmark' succeeds" '
+  ipfs benchmark $BENCH_OPTS > bench_out
+'
+
+test_expect_success "'ipfs benchmark' output looks good" '
+  grep "2 nodes, 64 KiB per run, 2 runs, latency 0s, bandwidth unlimited, seed 1" bench_out &&
+  for b in add pin-fetch gc gateway; do
+    grep "^$b " bench_out || return 1
+  done
+'
+
+test_expect_success "'ipfs benchmark' runs the given benchmarks only" '
+  ipfs benchmark $BENCH_OPTS gc add > bench_out &&
+  grep "^add " bench_out &&
+  grep "^gc " bench_out &&
+  test_must_fail grep "^gateway " bench_out
+'
+
+test_expect_success "'ipfs benchmark' saves a report" '
+  ipfs benchmark $BENCH_OPTS --latency=5ms add pin-fetch --enc=json > baseline.json &&
+  grep "\"Name\":\"pin-fetch\"" baseline.json
+'
+
+test_expect_success "'ipfs benchmark --compare' compares to the report" '
+  ipfs benchmark $BENCH_OPTS --latency=5ms add pin-fetch --compare=baseline.json > bench_out &&
+  grep "BASELINE" bench_out &&
+  grep "^pin-fetch .*%$" bench_out
+'
+
+test_expect_success "'ipfs benchmark --compare' refuses reports of other options" '
+  test_must_fail ipfs benchmark $BENCH_OPTS --compare=baseline.json 2> bench_err &&
+  grep "baseline.json was run with other options" bench_err
+'
+
+test_expect_success "'ipfs benchmark' rejects unknown benchmarks" '
+  test_must_fail ipfs benchmark $BENCH_OPTS nothing 2> bench_err &&
+  grep "unknown benchmark: nothing" bench_err
+'
+
+test_expect_success "'ipfs benchmark' rejects bad options" '
+  test_must_fail ipfs benchmark --nodes=1 2> bench_err &&
+  grep "at least 2 nodes" bench_err &&
+  test_must_fail ipfs benchmark --latency=soon 2> bench_err &&
+  grep "error parsing latency option" bench_err
+'
+
+test_done
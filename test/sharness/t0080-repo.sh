@@ -39,6 +39,15 @@ test_expect_success "'ipfs repo gc' doesnt remove file" '
 	test_cmp out afile
 '
 
+test_expect_success "'ipfs stats gc' lists the gc runs" '
+	ipfs stats gc >gc_stats_out &&
+	grep "^manual gc at" gc_stats_out &&
+	test $(grep -c "^manual gc at" gc_stats_out) -eq 2 &&
+	ipfs stats gc -n=1 >gc_stats_out &&
+	test $(grep -c "^manual gc at" gc_stats_out) -eq 1 &&
+	grep "removed: [1-9][0-9]* blocks" gc_stats_out
+'
+
 test_expect_success "'ipfs pin rm' succeeds" '
 	ipfs pin rm -r "$HASH" >actual1
 '
@@ -219,6 +228,24 @@ test_expect_success "'ipfs refs --unique --recursive (bigger)'" '
 	test_sort_cmp expected actual || test_fsh cat refs_output
 '
 
+test_expect_success "'ipfs refs --max-depth=1' lists the direct links" '
+	ipfs refs "$hash" >expected &&
+	ipfs refs --max-depth=1 "$hash" >actual &&
+	test_cmp expected actual
+'
+
+test_expect_success "'ipfs refs --max-depth=0' lists nothing" '
+	ipfs refs --max-depth=0 "$hash" >actual &&
+	test_must_be_empty actual
+'
+
+test_expect_success "'ipfs refs --unique --max-depth' is correct" '
+	ipfs refs --max-depth=2 "$hash" >refs_output &&
+	sort refs_output | uniq >expected &&
+	ipfs refs -u --max-depth=2 "$hash" >actual &&
+	test_sort_cmp expected actual || test_fsh cat refs_output
+'
+
 get_field_num() {
   field=$1
   file=$2
@@ -261,6 +288,64 @@ test_expect_success "repo version came out correct" '
 	egrep "^fs-repo@[0-9]+" repo-version-q >/dev/null
 '
 
+test_expect_success "'ipfs repo du' succeeds" '
+  mkdir du_dir &&
+  echo "du file a" > du_dir/a &&
+  echo "du file b" > du_dir/b &&
+  DU_DIR=$(ipfs add -r -q du_dir | tail -n1) &&
+  ipfs repo du $DU_DIR > du_out
+'
+
+test_expect_success "'ipfs repo du' counts the blocks of the dag" '
+  grep "^$DU_DIR" du_out &&
+  grep "Size: *[0-9]* *(3 blocks)" du_out &&
+  grep "Shared: *0 *(0 blocks)" du_out &&
+  grep "Missing: *(0 blocks)" du_out
+'
+
+test_expect_success "'ipfs repo du' reports blocks shared with other pins" '
+  ipfs add -q du_dir/a &&
+  ipfs repo du $DU_DIR > du_out &&
+  grep "Shared: *[1-9][0-9]* *(1 blocks)" du_out &&
+  grep "Unique: *[1-9][0-9]* *(2 blocks)" du_out
+'
+
+test_expect_success "'ipfs add --expire-in' requires --pin=false" '
+  echo "retained" >retained_file &&
+  test_expect_code 1 ipfs add --expire-in=1h retained_file 2>add_err &&
+  grep "expire-in requires --pin=false" add_err
+'
+
+test_expect_success "'ipfs add --pin=false --expire-in' succeeds" '
+  RETAINED=$(ipfs add -q --pin=false --expire-in=1h retained_file)
+'
+
+test_expect_success "'ipfs repo gc' keeps the retained file" '
+  ipfs repo gc &&
+  ipfs refs local >local_refs &&
+  grep "$RETAINED" local_refs
+'
+
+test_expect_success "'ipfs repo gc' removes unpinned files without --expire-in" '
+  echo "not retained" >unretained_file &&
+  UNRETAINED=$(ipfs add -q --pin=false unretained_file) &&
+  ipfs repo gc >gc_out &&
+  grep "$UNRETAINED" gc_out
+'
+
+test_expect_success "back to back 'ipfs repo gc' reuse the marked set" '
+  ipfs repo gc &&
+  ipfs stats gc -n=1 >gc_stats_out &&
+  grep "mark: reused from a previous run" gc_stats_out
+'
+
+test_expect_success "'ipfs repo gc' marks again once the pins changed" '
+  echo "newly pinned" | ipfs add -q >/dev/null &&
+  ipfs repo gc &&
+  ipfs stats gc -n=1 >gc_stats_out &&
+  test_must_fail grep "mark: reused" gc_stats_out
+'
+
 test_kill_ipfs_daemon
 
 test_done
@@ -272,13 +272,58 @@ test_expect_success "test add nopin dir" '
 
 '
 
+test_pin_atomic() {
+	test_expect_success "add objects to pin atomically, one with a missing block" '
+		mkdir -p atomic_dir &&
+		echo "atomic file $1" >atomic_file &&
+		echo "atomic broken $1" >atomic_dir/broken &&
+		echo "atomic other $1" >atomic_dir/other &&
+		ATOMIC_FILE=$(ipfs add -q --pin=false atomic_file) &&
+		ATOMIC_BROKEN=$(ipfs add -q --pin=false atomic_dir/broken) &&
+		ATOMIC_DIR=$(ipfs add -q -r --pin=false atomic_dir | tail -n1) &&
+		ipfs block rm "$ATOMIC_BROKEN"
+	'
+
+	test_expect_success "'ipfs pin add --atomic' fails if a dag is incomplete" '
+		test_must_fail ipfs pin add --atomic $2 "$ATOMIC_FILE" "$ATOMIC_DIR"
+	'
+
+	test_expect_success "and pins none of the paths" '
+		test_pin_flag "$ATOMIC_FILE" recursive false &&
+		test_pin_flag "$ATOMIC_DIR" recursive false
+	'
+
+	test_expect_success "'ipfs pin add --atomic' rolls back the pins added" '
+		ipfs pin add "$ATOMIC_DIR/other" &&
+		test_must_fail ipfs pin add --atomic -r=false "$ATOMIC_FILE" "$ATOMIC_DIR/other" 2>atomic_err &&
+		grep "already pinned recursively" atomic_err &&
+		test_pin_flag "$ATOMIC_FILE" direct false &&
+		test_pin_flag "$ATOMIC_DIR/other" recursive true
+	'
+
+	test_expect_success "'ipfs pin add --atomic' pins all the paths when they all succeed" '
+		echo "atomic broken $1" | ipfs add -q --pin=false &&
+		ipfs pin add --atomic "$ATOMIC_FILE" "$ATOMIC_DIR" &&
+		test_pin_flag "$ATOMIC_FILE" recursive true &&
+		test_pin_flag "$ATOMIC_DIR" recursive true
+	'
+
+	test_expect_success "clean up" '
+		ipfs pin rm "$ATOMIC_FILE" "$ATOMIC_DIR" "$ATOMIC_DIR/other"
+	'
+}
+
+test_pin_atomic offline
+
 FICTIONAL_HASH="QmXV4f9v8a56MxWKBhP3ETsz4EaafudU1cKfPaaJnenc48"
 test_launch_ipfs_daemon
 test_expect_success "test unpinning a hash that's not pinned" "
-  test_expect_code 1 ipfs pin rm $FICTIONAL_HASH --timeout=2s
-  test_expect_code 1 ipfs pin rm $FICTIONAL_HASH/a --timeout=2s
-  test_expect_code 1 ipfs pin rm $FICTIONAL_HASH/a/b --timeout=2s
+  test_expect_code 13 ipfs pin rm $FICTIONAL_HASH --timeout=2s &&
+  test_expect_code 20 ipfs pin rm $FICTIONAL_HASH/a --timeout=2s &&
+  test_expect_code 20 ipfs pin rm $FICTIONAL_HASH/a/b --timeout=2s
 "
+
+test_pin_atomic online --timeout=2s
 test_kill_ipfs_daemon
 
 # test_kill_ipfs_daemon
@@ -27,6 +27,12 @@ test_expect_success 'blocks are swapped' '
 	test_must_fail test_cmp noswap swap
 '
 
+test_expect_success "cat --verify-only fails on the swapped block" '
+	test_must_fail ipfs cat --verify-only $H_BLOCK2 >verify_out 2>err_msg &&
+	grep "block in storage has different hash than requested" err_msg &&
+	test_must_fail test -s verify_out
+'
+
 ipfs config --bool Datastore.HashOnRead true
 
 test_check_bad_blocks() {
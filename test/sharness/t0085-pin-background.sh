@@ -0,0 +1,74 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs pin add --background"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create an unpinned object" '
+	mkdir -p dir &&
+	echo "background" >dir/file &&
+	HASH=$(ipfs add -r -q --pin=false dir | tail -1)
+'
+
+test_expect_success "'ipfs pin add --background' needs a daemon" '
+	test_must_fail ipfs pin add --background $HASH 2>err &&
+	grep "run .ipfs daemon. first" err
+'
+
+test_launch_ipfs_daemon --offline
+
+# wait_pin_job waits for the given job to be in the given state
+wait_pin_job() {
+	for i in $(test_seq 1 50)
+	do
+		ipfs pin status "$1" >status_out &&
+		grep "^job $1: $2\$" status_out && return
+		go-sleep 100ms
+	done
+	cat status_out
+	return 1
+}
+
+test_expect_success "'ipfs pin add --background' queues a job" '
+	ipfs pin add --background $HASH >actual &&
+	echo "queued pin job 1" >expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "the job pins the object" '
+	wait_pin_job 1 done &&
+	grep "^pinned: $HASH\$" status_out &&
+	grep "^attempts: 1\$" status_out &&
+	ipfs pin ls --type=recursive -q >pins &&
+	grep "$HASH" pins
+'
+
+test_expect_success "a failed job is retried" '
+	MISSING=$(echo "not stored" | ipfs add -q --only-hash) &&
+	ipfs pin add --background $MISSING >actual &&
+	echo "queued pin job 2" >expected &&
+	test_cmp expected actual &&
+	wait_pin_job 2 retrying &&
+	grep "^attempts: 1\$" status_out &&
+	grep "^error: " status_out
+'
+
+test_expect_success "'ipfs pin jobs' lists the jobs" '
+	ipfs pin jobs >jobs_out &&
+	grep "^1 done 1 $HASH\$" jobs_out &&
+	grep "^2 retrying 1 $MISSING\$" jobs_out
+'
+
+test_expect_success "'ipfs pin status' fails on unknown jobs" '
+	test_must_fail ipfs pin status 42 2>err &&
+	grep "no pin job 42" err
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,74 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs pin events"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "'ipfs pin events' needs the daemon" '
+	test_must_fail ipfs pin events
+'
+
+test_expect_success "add some objects" '
+	HASH=$(echo "pin events data" | ipfs add -q --pin=false) &&
+	PROBE=$(echo "pin events probe" | ipfs add -q --pin=false)
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "'ipfs pin events' rejects unknown types" '
+	test_must_fail ipfs pin events --type=added,bogus 2>err &&
+	grep "invalid pin event type \"bogus\"" err
+'
+
+# the events before the subscription aren't streamed: pin the probe until
+# its event shows up
+wait_for_pin_events() {
+	for i in $(test_seq 1 50)
+	do
+		ipfs pin add --recursive=false "$PROBE" >/dev/null &&
+		ipfs pin rm "$PROBE" >/dev/null || return 1
+		grep "$PROBE" "$1" >/dev/null && return 0
+		go-sleep 100ms
+	done
+	return 1
+}
+
+test_expect_success "'ipfs pin events' streams the changes of the pins" '
+	ipfs pin events >events_out &
+	EVENTS_PID=$! &&
+	ipfs pin events --type=removed >removed_out &
+	REMOVED_PID=$! &&
+	wait_for_pin_events events_out &&
+	wait_for_pin_events removed_out &&
+	ipfs pin add "$HASH" &&
+	ipfs pin rm "$HASH" &&
+	for i in $(test_seq 1 50)
+	do
+		grep "removed recursive $HASH" events_out &&
+		grep "removed recursive $HASH" removed_out && break
+		go-sleep 100ms
+	done &&
+	kill $EVENTS_PID $REMOVED_PID
+'
+
+test_expect_success "the events are streamed in order" '
+	grep "$HASH" events_out | cut -d" " -f2- >actual &&
+	echo "fetched recursive $HASH" >expected &&
+	echo "added recursive $HASH" >>expected &&
+	echo "removed recursive $HASH" >>expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "--type only streams the events of the given types" '
+	grep -v " removed " removed_out >other &&
+	test_must_be_empty other
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,70 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs pin export and import"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create pins" '
+	mkdir -p dir &&
+	echo "recursive" >dir/file &&
+	RECURSIVE=$(ipfs add -r -q --pin=false dir | tail -1) &&
+	ipfs pin add --name=site --meta=team=infra $RECURSIVE &&
+	DIRECT=$(echo "direct" | ipfs add -q --pin=false) &&
+	ipfs pin add -r=false --name=single $DIRECT &&
+	ipfs pin ls --type=recursive -q | sort >recursive_before &&
+	ipfs pin ls --type=direct -q | sort >direct_before
+'
+
+test_expect_success "'ipfs pin export' succeeds" '
+	ipfs pin export >pins.json
+'
+
+test_expect_success "'ipfs pin export' lists the pins with their info" '
+	grep "\"Version\": 1" pins.json &&
+	grep "\"Cid\": \"$RECURSIVE\"" pins.json &&
+	grep "\"Cid\": \"$DIRECT\"" pins.json &&
+	grep "\"Name\": \"site\"" pins.json &&
+	grep "\"team\": \"infra\"" pins.json
+'
+
+test_expect_success "remove the pins" '
+	ipfs pin ls --type=recursive -q | xargs ipfs pin rm &&
+	ipfs pin ls --type=direct -q | xargs ipfs pin rm -r=false &&
+	ipfs pin ls --type=recursive -q >pins_out &&
+	test_must_be_empty pins_out
+'
+
+test_expect_success "'ipfs pin import' restores the pins" '
+	ipfs pin import pins.json >import_out &&
+	grep "pinned $RECURSIVE recursively" import_out &&
+	grep "pinned $DIRECT directly" import_out &&
+	ipfs pin ls --type=recursive -q | sort >recursive_after &&
+	ipfs pin ls --type=direct -q | sort >direct_after &&
+	test_cmp recursive_before recursive_after &&
+	test_cmp direct_before direct_after
+'
+
+test_expect_success "'ipfs pin import' restores the pin info" '
+	ipfs pin info $RECURSIVE >info_out &&
+	grep "^name: site\$" info_out &&
+	grep "^meta: team=infra\$" info_out &&
+	ipfs pin export >pins_again.json &&
+	test_cmp pins.json pins_again.json
+'
+
+test_expect_success "'ipfs pin import' reads stdin" '
+	ipfs pin import <pins.json >import_out &&
+	grep "pinned $RECURSIVE recursively" import_out
+'
+
+test_expect_success "'ipfs pin import' fails on an invalid file" '
+	echo "not json" >bad.json &&
+	test_must_fail ipfs pin import bad.json
+'
+
+test_done
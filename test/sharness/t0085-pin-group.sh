@@ -0,0 +1,108 @@
+#!/bin/sh
+
+test_description="Test ipfs pin group"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_pin_group() {
+	test_expect_success "add versions of a website" '
+		V1=$(echo "website v1" | ipfs add -q --pin=false) &&
+		V2=$(echo "website v2" | ipfs add -q --pin=false) &&
+		ASSETS=$(echo "website assets" | ipfs add -q --pin=false) &&
+		KEPT=$(echo "pinned before" | ipfs add -q)
+	'
+
+	test_expect_success "'ipfs pin group set' creates a group and pins its roots" '
+		ipfs pin group set website $V1 $ASSETS >set_out &&
+		grep "^added $V1\$" set_out &&
+		grep "^pinned $ASSETS\$" set_out &&
+		ipfs pin ls --type=recursive -q >ls_out &&
+		grep $V1 ls_out &&
+		grep $ASSETS ls_out
+	'
+
+	test_expect_success "'ipfs pin group ls' lists the group" '
+		ipfs pin group ls -q website | sort >ls_out &&
+		(echo $V1; echo $ASSETS) | sort >expected &&
+		test_cmp expected ls_out &&
+		ipfs pin group ls | grep "^website "
+	'
+
+	test_expect_success "the roots of a group get its metadata" '
+		ipfs pin ls --meta=group=website -q | sort >ls_out &&
+		test_cmp expected ls_out
+	'
+
+	test_expect_success "'ipfs pin group set' swaps the roots" '
+		ipfs pin group set website $V2 $ASSETS >set_out &&
+		grep "^added $V2\$" set_out &&
+		grep "^removed $V1\$" set_out &&
+		grep "^unpinned $V1\$" set_out &&
+		test_must_fail grep "$ASSETS" set_out &&
+		ipfs pin ls --type=recursive -q >ls_out &&
+		test_must_fail grep $V1 ls_out &&
+		grep $V2 ls_out
+	'
+
+	test_expect_success "roots pinned before joining a group stay pinned" '
+		ipfs pin group set website $V2 $KEPT &&
+		ipfs pin group set website $V2 >set_out &&
+		grep "^removed $KEPT\$" set_out &&
+		test_must_fail grep "^unpinned $KEPT" set_out &&
+		ipfs pin ls --type=recursive -q | grep $KEPT
+	'
+
+	test_expect_success "roots another group has stay pinned" '
+		ipfs pin group set mirror $V2 &&
+		ipfs pin group rm website >rm_out &&
+		test_must_fail grep "^unpinned" rm_out &&
+		ipfs pin ls --type=recursive -q | grep $V2 &&
+		ipfs pin group rm mirror >rm_out &&
+		grep "^unpinned $V2\$" rm_out
+	'
+
+	test_expect_success "roots pinned by hand since stay pinned" '
+		ipfs pin group set website $ASSETS >set_out &&
+		grep "^pinned $ASSETS\$" set_out &&
+		ipfs pin add $ASSETS &&
+		ipfs pin group rm website >rm_out &&
+		grep "^removed $ASSETS\$" rm_out &&
+		test_must_fail grep "^unpinned" rm_out &&
+		ipfs pin ls --type=recursive -q | grep $ASSETS &&
+		ipfs pin rm $ASSETS
+	'
+
+	test_expect_success "a group which can't be fetched is left as it was" '
+		ipfs pin group set website $V1 &&
+		MISSING=$(echo "never added" | ipfs add -q -n) &&
+		test_must_fail ipfs pin group set --timeout=2s website $MISSING &&
+		ipfs pin group ls -q website >ls_out &&
+		echo $V1 >expected &&
+		test_cmp expected ls_out &&
+		ipfs pin ls --type=recursive -q | grep $V1
+	'
+
+	test_expect_success "'ipfs pin group rm' fails on unknown groups" '
+		ipfs pin group rm website &&
+		test_must_fail ipfs pin group rm website 2>rm_err &&
+		grep "no pin group named website" rm_err &&
+		ipfs pin group ls >ls_out &&
+		test_must_be_empty ls_out
+	'
+
+	test_expect_success "clean up" '
+		ipfs pin rm $KEPT
+	'
+}
+
+test_pin_group
+
+test_launch_ipfs_daemon --offline
+
+test_pin_group
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,138 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs pin orphans and pin rm --unpin-children"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_pin_orphans() {
+	test_expect_success "add a directory, and keep some of its files" '
+		mkdir -p orphans &&
+		echo "orphans a $1" >orphans/a &&
+		echo "orphans b $1" >orphans/b &&
+		echo "orphans c $1" >orphans/c &&
+		DIR=$(ipfs add -r -q orphans | tail -n1) &&
+		A=$(ipfs add -q --pin=false orphans/a) &&
+		B=$(ipfs add -q --pin=false orphans/b) &&
+		C=$(ipfs add -q --pin=false orphans/c) &&
+		ipfs pin add "$A" &&
+		ipfs files cp "/ipfs/$B" "/orphans-b-$1"
+	'
+
+	test_expect_success "'ipfs pin orphans' lists the blocks left to the gc" '
+		ipfs pin orphans -q "$DIR" | sort >actual &&
+		printf "%s\n" "$DIR" "$C" | sort >expected &&
+		test_cmp expected actual
+	'
+
+	test_expect_success "'ipfs pin orphans --kept' tells what keeps the others" '
+		ipfs pin orphans --kept "$DIR" >kept_out &&
+		grep "^kept $A [0-9]* recursive $A$" kept_out &&
+		grep "^kept $B [0-9]* files root " kept_out &&
+		grep "^orphans: 2 blocks" kept_out
+	'
+
+	test_expect_success "'ipfs pin orphans' leaves the pins as they are" '
+		ipfs pin ls --type=recursive "$DIR"
+	'
+
+	test_expect_success "'ipfs pin orphans --unpin-children=false' only looks at the root" '
+		echo "$DIR" >expected &&
+		ipfs pin orphans -q --unpin-children=false "$DIR" >actual &&
+		test_cmp expected actual
+	'
+
+	test_expect_success "'ipfs pin orphans' fails on objects not pinned" '
+		test_must_fail ipfs pin orphans "$C" 2>err &&
+		grep "$C is not pinned" err
+	'
+
+	test_expect_success "'ipfs pin rm --unpin-children=false' keeps the children pinned" '
+		ipfs pin rm --unpin-children=false "$DIR" &&
+		test_must_fail ipfs pin ls "$DIR" &&
+		ipfs pin ls --type=recursive "$A" "$B" "$C"
+	'
+
+	test_expect_success "'ipfs pin rm -r=false --unpin-children=false' fails" '
+		test_must_fail ipfs pin rm -r=false --unpin-children=false "$C" 2>err &&
+		grep "only applies to recursive pins" err
+	'
+
+	test_expect_success "clean up" '
+		ipfs pin rm "$A" "$B" "$C"
+	'
+}
+
+test_pin_coverage() {
+	test_expect_success "start from a collected repo" '
+		ipfs repo gc >/dev/null &&
+		ipfs pin orphans >coverage_out &&
+		grep "^orphans: 0 blocks" coverage_out &&
+		grep "^missing: 0 blocks" coverage_out &&
+		test_must_fail grep "^gc fails" coverage_out
+	'
+
+	test_expect_success "add a pinned directory and an unpinned file" '
+		mkdir -p coverage &&
+		echo "coverage leaf one $1" >coverage/one &&
+		echo "coverage leaf two $1" >coverage/two &&
+		COVDIR=$(ipfs add -r -q --raw-leaves coverage | tail -n1) &&
+		LEAF=$(ipfs add -q --raw-leaves --only-hash coverage/two) &&
+		ORPHAN=$(echo "coverage orphan $1" | ipfs add -q --pin=false)
+	'
+
+	test_expect_success "'ipfs pin orphans' without arguments lists the unpinned blocks" '
+		ipfs pin orphans -q >actual &&
+		echo "$ORPHAN" >expected &&
+		test_cmp expected actual
+	'
+
+	test_expect_success "'ipfs pin orphans --kept' without arguments lists the pinned blocks" '
+		ipfs pin orphans --kept >coverage_out &&
+		grep "^orphan $ORPHAN [0-9]*\$" coverage_out &&
+		grep "^kept $COVDIR [0-9]* recursive $COVDIR\$" coverage_out &&
+		grep "^kept $LEAF [0-9]* recursive $COVDIR\$" coverage_out &&
+		grep "^orphans: 1 blocks" coverage_out
+	'
+
+	test_expect_success "'ipfs repo gc' removes the orphans listed" '
+		ipfs repo gc >/dev/null &&
+		ipfs pin orphans -q >actual &&
+		test_must_be_empty actual
+	'
+
+	test_expect_success "remove a block of the pinned directory" '
+		LEAF_FILE=$(grep -rl "coverage leaf two $1" "$IPFS_PATH/blocks") &&
+		cp "$LEAF_FILE" leaf_backup &&
+		rm "$LEAF_FILE"
+	'
+
+	test_expect_success "'ipfs pin orphans' lists the missing blocks of the pins" '
+		ipfs pin orphans >coverage_out &&
+		grep "^missing $LEAF recursive $COVDIR\$" coverage_out &&
+		grep "^missing: 1 blocks" coverage_out &&
+		grep "^gc fails: garbage collection aborted: could not retrieve some links" coverage_out &&
+		grep "^orphans: 0 blocks" coverage_out
+	'
+
+	test_expect_success "clean up" '
+		cp leaf_backup "$LEAF_FILE" &&
+		ipfs pin rm "$COVDIR"
+	'
+}
+
+test_pin_orphans offline
+test_pin_coverage offline
+
+test_launch_ipfs_daemon
+
+test_pin_orphans online
+test_pin_coverage online
+
+test_kill_ipfs_daemon
+
+test_done
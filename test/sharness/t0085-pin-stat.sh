@@ -0,0 +1,69 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs pin stat"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_pin_stat() {
+	test_expect_success "pin a directory recursively and a file directly" '
+		mkdir -p stat_dir &&
+		random 100000 1 >stat_dir/big &&
+		echo "stat small $1" >stat_dir/small &&
+		echo "stat direct $1" >stat_direct &&
+		DIR=$(ipfs add -r -q stat_dir | tail -n1) &&
+		DIRECT=$(ipfs add -q --pin=false stat_direct) &&
+		ipfs pin add -r=false "$DIRECT"
+	'
+
+	test_expect_success "'ipfs pin stat' counts the pins as 'ipfs pin ls' lists them" '
+		ipfs pin stat >stat_out &&
+		for type in recursive direct indirect
+		do
+			echo "$type: $(ipfs pin ls --type=$type | wc -l)" || return 1
+		done >expected &&
+		head -n3 stat_out >actual &&
+		test_cmp expected actual
+	'
+
+	test_expect_success "'ipfs pin stat' tells the size and the largest pins" '
+		grep "^size: " stat_out &&
+		grep -A1 "^largest:$" stat_out | tail -n1 >largest &&
+		grep "^  $DIR recursive " largest &&
+		grep "^  $DIRECT direct " stat_out
+	'
+
+	test_expect_success "'ipfs pin stat -n' limits the largest pins" '
+		ipfs pin stat -n 1 >stat_out &&
+		test $(grep -c "^  " stat_out) -eq 1 &&
+		ipfs pin stat --largest=0 >stat_out &&
+		test_must_fail grep "^largest:" stat_out
+	'
+
+	test_expect_success "'ipfs pin stat' gives the size in bytes in json" '
+		ipfs pin stat --enc=json >stat_json &&
+		grep "\"Size\": *[1-9][0-9]*" stat_json
+	'
+
+	test_expect_success "'ipfs pin stat' fails with a negative --largest" '
+		test_must_fail ipfs pin stat --largest=-1
+	'
+
+	test_expect_success "clean up" '
+		ipfs pin rm "$DIR" "$DIRECT"
+	'
+}
+
+test_pin_stat offline
+
+test_launch_ipfs_daemon
+
+test_pin_stat online
+
+test_kill_ipfs_daemon
+
+test_done
@@ -95,6 +95,617 @@ test_pin_progress() {
 		cat err
 		grep -q " 5 nodes" err
 	'
+
+	test_expect_success "pin progress reports the bytes fetched" '
+		grep -q " 5 nodes, 1.0 MB of 1.0 MB" err
+	'
+
+	test_expect_success "'ipfs pin rm --progress' reports the nodes walked" '
+		CHILD=$(ipfs refs $HASH | head -1) &&
+		test_must_fail ipfs pin rm --progress $CHILD 2> rm_err &&
+		grep -q "Processed 1 nodes" rm_err &&
+		grep -q "is pinned indirectly under $HASH" rm_err
+	'
+
+	test_expect_success "'ipfs pin rm --progress' streams json" '
+		ipfs pin rm --progress --enc=json $HASH > rm_json &&
+		grep -q "\"Pins\":\[\"$HASH\"\]" rm_json
+	'
+}
+
+test_pin_info() {
+	REQUESTER=$1
+
+	test_expect_success "'ipfs pin add' records who pinned and when" '
+		HASH=$(echo "pin info $REQUESTER" | ipfs add -q --pin=false) &&
+		BEFORE=$(date -u +%Y-%m-%d) &&
+		ipfs pin add $HASH &&
+		AFTER=$(date -u +%Y-%m-%d) &&
+		ipfs pin info $HASH >info_out &&
+		grep "^$HASH recursive\$" info_out &&
+		grep -e "^created: ${BEFORE}T" -e "^created: ${AFTER}T" info_out &&
+		grep "^requester: $REQUESTER\$" info_out
+	'
+
+	test_expect_success "'ipfs pin ls --info' lists who pinned and when" '
+		ipfs pin ls --info $HASH >ls_out &&
+		grep "^$HASH recursive [0-9TZ:+-]* $REQUESTER\$" ls_out &&
+		ipfs pin ls --info --enc=json $HASH >ls_json &&
+		grep "\"Requester\":\"$REQUESTER\"" ls_json
+	'
+
+	test_expect_success "pinning again keeps the creation time" '
+		grep "^created:" info_out >created_expected &&
+		sleep 1 &&
+		ipfs pin add $HASH &&
+		ipfs pin info $HASH | grep "^created:" >created_actual &&
+		test_cmp created_expected created_actual
+	'
+
+	test_expect_success "'ipfs add' records who pinned" '
+		ADDED=$(echo "added pin info $REQUESTER" | ipfs add -q) &&
+		ipfs pin info $ADDED | grep "^requester: $REQUESTER\$"
+	'
+
+	test_expect_success "'ipfs pin info' fails for unpinned objects" '
+		ipfs pin rm $HASH &&
+		test_must_fail ipfs pin info $HASH 2>info_err &&
+		grep "is not pinned" info_err
+	'
+
+	test_expect_success "'ipfs pin info' shows direct pins" '
+		ipfs pin add -r=false $HASH &&
+		ipfs pin info $HASH >info_out &&
+		grep "^$HASH direct\$" info_out &&
+		ipfs pin rm -r=false $HASH
+	'
+}
+
+test_pin_names() {
+	test_expect_success "'ipfs pin add --name' names pins" '
+		NAMED=$(echo "named pin" | ipfs add -q --pin=false) &&
+		OTHER=$(echo "other named pin" | ipfs add -q --pin=false) &&
+		ipfs pin add --name="my website" $NAMED &&
+		ipfs pin add --name=backup $OTHER &&
+		ipfs pin info $NAMED >info_out &&
+		grep "^name: my website\$" info_out
+	'
+
+	test_expect_success "'ipfs pin ls' shows pin names" '
+		ipfs pin ls --type=recursive >ls_out &&
+		grep "^$NAMED recursive my website\$" ls_out &&
+		grep "^$OTHER recursive backup\$" ls_out &&
+		ipfs pin ls --info $NAMED >ls_out &&
+		grep "^$NAMED recursive [0-9TZ:+-]* [^ ]* my website\$" ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --name' filters pins by name" '
+		ipfs pin ls --name=web -q >ls_out &&
+		echo $NAMED >expected &&
+		test_cmp expected ls_out &&
+		ipfs pin ls --name=nothing -q >ls_out &&
+		test_must_be_empty ls_out
+	'
+
+	test_expect_success "pins keep their name when added again" '
+		ipfs pin add $NAMED &&
+		ipfs pin info $NAMED | grep "^name: my website\$"
+	'
+
+	test_expect_success "'ipfs pin update' keeps the pin name" '
+		UPDATED=$(echo "updated named pin" | ipfs add -q --pin=false) &&
+		ipfs pin update $NAMED $UPDATED &&
+		ipfs pin info $UPDATED | grep "^name: my website\$"
+	'
+
+	test_expect_success "pin names can't contain line breaks" '
+		test_must_fail ipfs pin add --name="$(printf "a\nb")" $OTHER
+	'
+
+	test_expect_success "clean up named pins" '
+		ipfs pin rm $UPDATED $OTHER
+	'
+}
+
+test_pin_meta() {
+	test_expect_success "'ipfs pin add --meta' records pin metadata" '
+		META=$(echo "pin with metadata" | ipfs add -q --pin=false) &&
+		PLAIN=$(echo "pin without metadata" | ipfs add -q --pin=false) &&
+		ipfs pin add --meta=origin=backup-job-42,team=infra $META &&
+		ipfs pin add $PLAIN &&
+		ipfs pin info $META >info_out &&
+		grep "^meta: origin=backup-job-42\$" info_out &&
+		grep "^meta: team=infra\$" info_out
+	'
+
+	test_expect_success "'ipfs pin ls --meta' filters pins by metadata" '
+		ipfs pin ls -q --meta=origin=backup-job-42 >ls_out &&
+		echo $META >expected &&
+		test_cmp expected ls_out &&
+		ipfs pin ls -q --meta=team >ls_out &&
+		test_cmp expected ls_out &&
+		ipfs pin ls -q --meta=origin=other-job >ls_out &&
+		test_must_be_empty ls_out
+	'
+
+	test_expect_success "'ipfs pin ls' shows metadata in json" '
+		ipfs pin ls --enc=json $META >ls_json &&
+		grep "\"origin\":\"backup-job-42\"" ls_json
+	'
+
+	test_expect_success "pinning again sets the given keys and keeps the others" '
+		ipfs pin add --meta=origin=backup-job-43 $META &&
+		ipfs pin info $META >info_out &&
+		grep "^meta: origin=backup-job-43\$" info_out &&
+		grep "^meta: team=infra\$" info_out
+	'
+
+	test_expect_success "'ipfs pin add --meta' rejects invalid metadata" '
+		test_must_fail ipfs pin add --meta=origin $PLAIN 2>meta_err &&
+		grep "not a key=value pair" meta_err
+	'
+
+	test_expect_success "clean up pins with metadata" '
+		ipfs pin rm $META $PLAIN
+	'
+}
+
+test_pin_times() {
+	test_expect_success "create a pin dated back with 'ipfs pin import'" '
+		OLD=$(echo "old pin" | ipfs add -q --pin=false) &&
+		NEW=$(echo "new pin" | ipfs add -q --pin=false) &&
+		printf "{\"Version\":1,\"Pins\":[{\"Cid\":\"%s\",\"Type\":\"recursive\",\"Info\":{\"Requester\":\"script\",\"Created\":\"2017-01-02T03:04:05Z\"}}]}" $OLD >old_pin.json &&
+		ipfs pin import old_pin.json &&
+		ipfs pin add $NEW
+	'
+
+	test_expect_success "'ipfs pin ls --before' lists the older pins" '
+		ipfs pin ls -q --before=2017-01-03 >ls_out &&
+		echo $OLD >expected &&
+		test_cmp expected ls_out &&
+		ipfs pin ls --before=2017-01-02T03:04:05Z >ls_out &&
+		test_must_be_empty ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --after' lists the newer pins" '
+		ipfs pin ls -q --after=2017-01-02T03:04:05Z >ls_out &&
+		grep "^$OLD\$" ls_out &&
+		grep "^$NEW\$" ls_out &&
+		ipfs pin ls -q --after=1h >ls_out &&
+		grep "^$NEW\$" ls_out &&
+		test_must_fail grep "^$OLD\$" ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --before --after' lists the pins in between" '
+		ipfs pin ls --after=2017-01-01 --before=7d >ls_out &&
+		grep "^$OLD recursive 2017-01-02T03:04:05Z script\$" ls_out &&
+		test_must_fail grep "^$NEW" ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --before' rejects invalid times" '
+		test_must_fail ipfs pin ls --before=yesterday 2>ls_err &&
+		grep "invalid --before" ls_err
+	'
+
+	test_expect_success "clean up dated pins" '
+		ipfs pin rm $OLD $NEW
+	'
+}
+
+test_pin_update() {
+	test_expect_success "create a directory to update" '
+		mkdir -p update/sub &&
+		echo "unchanged" >update/sub/same &&
+		echo "first version" >update/file &&
+		UPDATE_OLD=$(ipfs add -r -q --pin=false update | tail -1) &&
+		ipfs pin add $UPDATE_OLD &&
+		echo "second version" >update/file &&
+		UPDATE_NEW=$(ipfs add -r -q --pin=false update | tail -1)
+	'
+
+	test_expect_success "'ipfs pin update' moves the pin" '
+		ipfs pin update $UPDATE_OLD $UPDATE_NEW >update_out &&
+		echo "updated $UPDATE_OLD to $UPDATE_NEW" >update_exp &&
+		test_cmp update_exp update_out
+	'
+
+	test_expect_success "the updated pin is saved" '
+		ipfs pin ls --type=recursive -q >pins_out &&
+		grep $UPDATE_NEW pins_out &&
+		test_must_fail grep $UPDATE_OLD pins_out
+	'
+
+	test_expect_success "'ipfs pin update' to the same cid keeps the pin" '
+		ipfs pin update $UPDATE_NEW $UPDATE_NEW &&
+		ipfs pin ls --type=recursive -q | grep $UPDATE_NEW
+	'
+
+	test_expect_success "'ipfs pin update --unpin=false' keeps the old pin" '
+		ipfs pin update --unpin=false $UPDATE_NEW $UPDATE_OLD &&
+		ipfs pin ls --type=recursive -q >pins_out &&
+		grep $UPDATE_NEW pins_out &&
+		grep $UPDATE_OLD pins_out
+	'
+
+	test_expect_success "clean up updated pins" '
+		ipfs pin rm $UPDATE_OLD $UPDATE_NEW &&
+		rm -r update
+	'
+}
+
+test_pin_ls_size() {
+	test_expect_success "add a directory to size" '
+		mkdir -p sizedir/sub &&
+		echo "first" >sizedir/a &&
+		echo "second" >sizedir/sub/b &&
+		echo "first" >sizedir/sub/same &&
+		SIZE_DIR=$(ipfs add -r -q sizedir | tail -1) &&
+		(echo $SIZE_DIR && ipfs refs -r --unique $SIZE_DIR) |
+		while read c; do
+			ipfs block stat $c | grep "^Size:" | cut -d" " -f2
+		done | awk "{ s += \$1 } END { print s }" >size_expected
+	'
+
+	test_expect_success "'ipfs pin ls --size' lists the size of the dag" '
+		ipfs pin ls --size $SIZE_DIR >ls_out &&
+		echo "$SIZE_DIR recursive $(cat size_expected)" >expected &&
+		test_cmp expected ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --size --stream' lists the same sizes" '
+		ipfs pin ls --size --type=recursive | sort >ls_expected &&
+		ipfs pin ls --size --stream --type=recursive | sort >ls_out &&
+		test_cmp ls_expected ls_out &&
+		grep "^$SIZE_DIR recursive $(cat size_expected)\$" ls_out
+	'
+
+	test_expect_success "clean up sized pin" '
+		ipfs pin rm $SIZE_DIR &&
+		rm -r sizedir
+	'
+}
+
+test_pin_ls_explain() {
+	test_expect_success "add two directories sharing a file" '
+		mkdir -p explain1 explain2 &&
+		echo "shared by both" >explain1/shared &&
+		cp explain1/shared explain2/shared &&
+		echo "only in the first" >explain1/own &&
+		EXPLAIN1=$(ipfs add -r -q explain1 | tail -1) &&
+		EXPLAIN2=$(ipfs add -r -q explain2 | tail -1) &&
+		SHARED=$(ipfs add -q -n explain1/shared) &&
+		OWN=$(ipfs add -q -n explain1/own)
+	'
+
+	test_expect_success "'ipfs pin ls --explain' lists all the pins keeping a file" '
+		ipfs pin ls --explain $SHARED >ls_out &&
+		echo "$SHARED indirect through $( (echo $EXPLAIN1; echo $EXPLAIN2) | sort | tr "\n" " " | sed "s/ \$//")" >expected &&
+		test_cmp expected ls_out &&
+		ipfs pin ls --explain $OWN >ls_out &&
+		echo "$OWN indirect through $EXPLAIN1" >expected &&
+		test_cmp expected ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --explain' lists a direct pin with its recursive pins" '
+		ipfs pin add -r=false $SHARED &&
+		ipfs pin ls --explain --enc=json $SHARED >ls_out &&
+		grep "\"Type\":\"direct\"" ls_out &&
+		grep $EXPLAIN1 ls_out &&
+		grep $EXPLAIN2 ls_out &&
+		ipfs pin rm -r=false $SHARED
+	'
+
+	test_expect_success "'ipfs pin ls --explain' lists a recursive pin without itself" '
+		ipfs pin ls --explain $EXPLAIN1 >ls_out &&
+		echo "$EXPLAIN1 recursive" >expected &&
+		test_cmp expected ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --explain' follows the removal of pins" '
+		ipfs pin rm $EXPLAIN1 &&
+		ipfs pin ls --explain $SHARED >ls_out &&
+		echo "$SHARED indirect through $EXPLAIN2" >expected &&
+		test_cmp expected ls_out &&
+		test_must_fail ipfs pin ls --explain $OWN
+	'
+
+	test_expect_success "'ipfs pin ls --explain' needs arguments" '
+		test_must_fail ipfs pin ls --explain 2>explain_err &&
+		grep "needs the objects to explain" explain_err
+	'
+
+	test_expect_success "clean up explained pins" '
+		ipfs pin rm $EXPLAIN2 &&
+		rm -r explain1 explain2
+	'
+}
+
+test_pin_ls_cid() {
+	test_expect_success "pin objects with different CID versions and codecs" '
+		V0=$(echo "cid v0 pin" | ipfs add -q) &&
+		RAW=$(echo "cid v1 raw pin" | ipfs block put --format=raw) &&
+		CBOR=$(echo "{\"cbor\": \"pin\"}" | ipfs dag put) &&
+		ipfs pin add -r=false $RAW &&
+		ipfs pin add $CBOR
+	'
+
+	test_expect_success "'ipfs pin ls --cid-version' filters pins by CID version" '
+		ipfs pin ls --cid-version=0 -q >ls_out &&
+		grep $V0 ls_out &&
+		test_must_fail grep $RAW ls_out &&
+		ipfs pin ls --cid-version=1 -q >ls_out &&
+		grep $RAW ls_out &&
+		grep $CBOR ls_out &&
+		test_must_fail grep $V0 ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --codec' filters pins by codec" '
+		ipfs pin ls --codec=dag-cbor --type=recursive -q >ls_out &&
+		echo $CBOR >expected &&
+		test_cmp expected ls_out &&
+		ipfs pin ls --codec=raw --type=direct -q >ls_out &&
+		grep $RAW ls_out &&
+		ipfs pin ls --codec=dag-pb -q >ls_out &&
+		grep $V0 ls_out &&
+		test_must_fail grep $CBOR ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --prefix' filters pins by CID prefix" '
+		ipfs pin ls --prefix=$(echo $V0 | cut -c1-20) -q >ls_out &&
+		echo $V0 >expected &&
+		test_cmp expected ls_out
+	'
+
+	test_expect_success "'ipfs pin ls' rejects unknown CID versions and codecs" '
+		test_must_fail ipfs pin ls --cid-version=2 2>ls_err &&
+		grep "must be 0 or 1" ls_err &&
+		test_must_fail ipfs pin ls --codec=nope 2>ls_err &&
+		grep "unknown codec" ls_err
+	'
+
+	test_expect_success "clean up the pins" '
+		ipfs pin rm $V0 $CBOR &&
+		ipfs pin rm -r=false $RAW
+	'
+}
+
+test_pin_ls_stream() {
+	for type in all direct indirect recursive; do
+		test_expect_success "'ipfs pin ls --stream --type=$type' lists the same pins" '
+			ipfs pin ls --type=$type | sort >ls_expected &&
+			ipfs pin ls --stream --type=$type | sort >ls_out &&
+			test_cmp ls_expected ls_out
+		'
+	done
+
+	test_expect_success "'ipfs pin ls --stream' lists each pin once" '
+		ipfs pin ls --stream -q >ls_out &&
+		sort -u ls_out >ls_uniq &&
+		sort ls_out | test_cmp ls_uniq -
+	'
+
+	test_expect_success "'ipfs pin ls --stream' applies the filters" '
+		ipfs pin ls -q --meta=team | sort >ls_expected &&
+		ipfs pin ls --stream -q --meta=team | sort >ls_out &&
+		test_cmp ls_expected ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --stream' writes a list per pin in json" '
+		ipfs pin ls --stream --type=recursive --enc=json >ls_json &&
+		test $(grep -c "\"Keys\"" ls_json) -eq $(ipfs pin ls --type=recursive -q | wc -l)
+	'
+}
+
+test_pin_ls_sort() {
+	test_expect_success "pin objects of different sizes and times" '
+		SMALL=$(echo "small" | ipfs add -q) &&
+		BIG=$(random 20000 7 | ipfs add -q --pin=false) &&
+		DATED=$(echo "dated pin" | ipfs add -q --pin=false) &&
+		ipfs pin add -r=false $BIG &&
+		printf "{\"Version\":1,\"Pins\":[{\"Cid\":\"%s\",\"Type\":\"recursive\",\"Info\":{\"Requester\":\"script\",\"Created\":\"2017-01-02T03:04:05Z\"}}]}" $DATED >dated_pin.json &&
+		ipfs pin import dated_pin.json
+	'
+
+	test_expect_success "'ipfs pin ls' lists the pins by CID" '
+		ipfs pin ls -q >ls_out &&
+		LC_ALL=C sort ls_out >ls_expected &&
+		test_cmp ls_expected ls_out &&
+		ipfs pin ls -q --sort=cid >ls_sorted &&
+		test_cmp ls_out ls_sorted
+	'
+
+	test_expect_success "'ipfs pin ls --sort=type' lists the pins by type" '
+		ipfs pin ls --sort=type >ls_out &&
+		cut -d" " -f2 ls_out >types_out &&
+		LC_ALL=C sort types_out >types_expected &&
+		test_cmp types_expected types_out &&
+		grep "^$BIG direct\$" ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --sort=size' lists the pins by size" '
+		ipfs pin ls --sort=size --type=direct >ls_out &&
+		tail -1 ls_out | grep "^$BIG direct 2" &&
+		cut -d" " -f3 ls_out >sizes_out &&
+		sort -n sizes_out >sizes_expected &&
+		test_cmp sizes_expected sizes_out
+	'
+
+	test_expect_success "'ipfs pin ls --sort=time' lists the oldest pins first" '
+		ipfs pin ls -q --sort=time --type=recursive >ls_out &&
+		head -1 ls_out >ls_first &&
+		echo $DATED >expected &&
+		test_cmp expected ls_first &&
+		grep "^$SMALL\$" ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --offset --limit' lists a page of the pins" '
+		ipfs pin ls -q --sort=type >ls_all &&
+		ipfs pin ls -q --sort=type --offset=1 --limit=2 >ls_out &&
+		sed -n 2,3p ls_all >ls_expected &&
+		test_cmp ls_expected ls_out &&
+		ipfs pin ls -q --offset=1000000 >ls_out &&
+		test_must_be_empty ls_out &&
+		ipfs pin ls -q --limit=0 >ls_out &&
+		test_must_be_empty ls_out
+	'
+
+	test_expect_success "'ipfs pin ls --limit' lists the order with the JSON encoding" '
+		ipfs pin ls --sort=time --type=recursive --limit=1 --enc=json >ls_out &&
+		grep "\"Order\":\[\"$DATED\"\]" ls_out
+	'
+
+	test_expect_success "'ipfs pin ls' rejects bad sorts and pages" '
+		test_must_fail ipfs pin ls --sort=name 2>ls_err &&
+		grep "invalid --sort" ls_err &&
+		test_must_fail ipfs pin ls --limit=-1 2>ls_err &&
+		grep "must not be negative" ls_err &&
+		test_must_fail ipfs pin ls --stream --sort=cid 2>ls_err &&
+		grep "can.t be used with --sort" ls_err
+	'
+
+	test_expect_success "clean up the sorted pins" '
+		ipfs pin rm $SMALL $DATED &&
+		ipfs pin rm -r=false $BIG
+	'
+}
+
+test_pin_ls_glob() {
+	test_expect_success "add a site and an unpinned directory" '
+		mkdir -p site/docs/sub other &&
+		echo "glob index" >site/index.html &&
+		echo "glob a" >site/docs/a.html &&
+		echo "glob b" >site/docs/b.txt &&
+		echo "glob c" >site/docs/sub/c.html &&
+		echo "glob other" >other/d.html &&
+		SITE=$(ipfs add -r -q site | tail -1) &&
+		GLOB_A=$(ipfs add -q --only-hash site/docs/a.html) &&
+		GLOB_C=$(ipfs add -q --only-hash site/docs/sub/c.html) &&
+		OTHER=$(ipfs add -r -q --pin=false other | tail -1) &&
+		GLOB_D=$(ipfs add -q --only-hash other/d.html)
+	'
+
+	test_expect_success "'ipfs pin ls' matches globs under a path" '
+		ipfs pin ls "/ipfs/$SITE/docs/*.html" >ls_out &&
+		echo "$GLOB_A indirect /ipfs/$SITE/docs/a.html through $SITE" >expected &&
+		test_cmp expected ls_out
+	'
+
+	test_expect_success "'ipfs pin ls' matches ** against any number of segments" '
+		ipfs pin ls -q "/ipfs/$SITE/**" >ls_out &&
+		test_line_count = 7 ls_out &&
+		ipfs pin ls "/ipfs/$SITE/**/*.html" >ls_out &&
+		grep "^$GLOB_C indirect /ipfs/$SITE/docs/sub/c.html through $SITE\$" ls_out &&
+		grep "^$GLOB_A indirect /ipfs/$SITE/docs/a.html through $SITE\$" ls_out &&
+		test_line_count = 3 ls_out &&
+		ipfs pin ls "/ipfs/$SITE/**" >ls_out &&
+		grep "^$SITE recursive /ipfs/$SITE\$" ls_out
+	'
+
+	test_expect_success "'ipfs pin ls' matches the recursive pins with a glob CID" '
+		ipfs pin ls "/ipfs/*/docs/sub/*.html" >ls_out &&
+		echo "$GLOB_C indirect /ipfs/$SITE/docs/sub/c.html through $SITE" >expected &&
+		test_cmp expected ls_out
+	'
+
+	test_expect_success "'ipfs pin ls' lists the objects matched which are not pinned" '
+		ipfs pin ls "/ipfs/$OTHER/*.html" >ls_out &&
+		echo "$GLOB_D unpinned /ipfs/$OTHER/d.html" >expected &&
+		test_cmp expected ls_out &&
+		ipfs pin ls --type=recursive "/ipfs/$OTHER/*.html" >ls_out &&
+		test_must_be_empty ls_out
+	'
+
+	test_expect_success "'ipfs pin ls' rejects bad globs" '
+		test_must_fail ipfs pin ls "/ipfs/$SITE/[a" 2>ls_err &&
+		grep "invalid glob" ls_err &&
+		test_must_fail ipfs pin ls "/ipns/*/index.html" 2>ls_err &&
+		grep "can.t have wildcards" ls_err
+	'
+
+	test_expect_success "clean up the globbed pins" '
+		ipfs pin rm $SITE &&
+		rm -r site other
+	'
+}
+
+test_pin_provenance() {
+	test_expect_success "pin a path under a directory" '
+		mkdir -p prov/sub &&
+		echo "provenance f" >prov/sub/f &&
+		echo "provenance g" >prov/g &&
+		PROV=$(ipfs add -r -q --pin=false prov | tail -1) &&
+		PROV_SUB=$(ipfs add -r -q --only-hash prov/sub | tail -1) &&
+		PROV_G=$(ipfs add -q --only-hash prov/g) &&
+		ipfs pin add "/ipfs/$PROV/sub" &&
+		ipfs pin add -r=false $PROV_G
+	'
+
+	test_expect_success "'ipfs pin ls --provenance' lists the paths pinned" '
+		ipfs pin ls --provenance $PROV_SUB >ls_out &&
+		echo "$PROV_SUB recursive from /ipfs/$PROV/sub" >expected &&
+		test_cmp expected ls_out &&
+		ipfs pin ls --provenance $PROV_G >ls_out &&
+		echo "$PROV_G direct from /ipfs/$PROV_G" >expected &&
+		test_cmp expected ls_out &&
+		ipfs pin ls --provenance --enc=json --type=recursive >ls_out &&
+		grep "\"Source\":\"/ipfs/$PROV/sub\"" ls_out
+	'
+
+	test_expect_success "'ipfs pin ls' doesn't list the paths without --provenance" '
+		ipfs pin ls $PROV_SUB >ls_out &&
+		echo "$PROV_SUB recursive" >expected &&
+		test_cmp expected ls_out
+	'
+
+	test_expect_success "'ipfs pin update' records the path of the new version" '
+		ipfs pin update --unpin=true $PROV_SUB "/ipfs/$PROV" &&
+		ipfs pin ls --provenance $PROV >ls_out &&
+		echo "$PROV recursive from /ipfs/$PROV" >expected &&
+		test_cmp expected ls_out &&
+		ipfs pin info $PROV >info_out &&
+		grep "^source: /ipfs/$PROV\$" info_out
+	'
+
+	test_expect_success "clean up the provenance pins" '
+		ipfs pin rm $PROV $PROV_G &&
+		rm -r prov
+	'
+}
+
+test_pin_local_only() {
+	test_expect_success "'ipfs pin add --local-only' pins local content" '
+		random 300000 71 >localfile &&
+		LOCAL=$(ipfs add --pin=false -q localfile) &&
+		ipfs pin add --local-only $LOCAL >pin_out &&
+		echo "pinned $LOCAL recursively" >expected &&
+		test_cmp expected pin_out &&
+		ipfs pin rm $LOCAL
+	'
+
+	test_expect_success "'ipfs pin add --local-only' fails on a missing block" '
+		PART=$(ipfs refs $LOCAL | head -1) &&
+		ipfs block rm $PART &&
+		test_must_fail ipfs pin add --local-only $LOCAL 2>pin_err &&
+		grep "block $PART of $LOCAL is not local" pin_err &&
+		test_must_fail ipfs pin ls $LOCAL
+	'
+
+	test_expect_success "'ipfs pin add --local-only' pins none of the paths on failure" '
+		OTHER=$(echo "local only" | ipfs add --pin=false -q) &&
+		test_must_fail ipfs pin add --local-only $OTHER $LOCAL &&
+		test_must_fail ipfs pin ls $OTHER
+	'
+
+	test_expect_success "'ipfs pin add --local-only' fails on a missing root" '
+		MISSING=$(echo "never added" | ipfs add --only-hash -q) &&
+		test_must_fail ipfs pin add --local-only $MISSING 2>pin_err &&
+		grep "is not local" pin_err
+	'
+
+	test_expect_success "'ipfs pin add --local-only' rejects /ipns paths" '
+		test_must_fail ipfs pin add --local-only /ipns/$(ipfs config Identity.PeerID) 2>pin_err &&
+		grep "without the network" pin_err
+	'
 }
 
 test_init_ipfs
@@ -110,6 +721,32 @@ test_pin_dag --raw-leaves
 
 test_pin_progress
 
+test_pin_info local
+
+test_pin_names
+
+test_pin_meta
+
+test_pin_times
+
+test_pin_update
+
+test_pin_ls_stream
+
+test_pin_ls_size
+
+test_pin_ls_explain
+
+test_pin_ls_cid
+
+test_pin_ls_sort
+
+test_pin_ls_glob
+
+test_pin_provenance
+
+test_pin_local_only
+
 test_launch_ipfs_daemon --offline
 
 test_pins
@@ -123,6 +760,32 @@ test_pin_dag --raw-leaves
 
 test_pin_progress
 
+test_pin_info 127.0.0.1
+
+test_pin_names
+
+test_pin_meta
+
+test_pin_times
+
+test_pin_update
+
+test_pin_ls_stream
+
+test_pin_ls_size
+
+test_pin_ls_explain
+
+test_pin_ls_cid
+
+test_pin_ls_sort
+
+test_pin_ls_glob
+
+test_pin_provenance
+
+test_pin_local_only
+
 test_kill_ipfs_daemon
 
 test_done
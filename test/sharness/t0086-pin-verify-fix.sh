@@ -0,0 +1,52 @@
+#!/bin/sh
+
+test_description="Test ipfs pin verify --fix"
+
+. lib/test-lib.sh
+
+NUM_NODES=2
+test_expect_success 'init iptb' '
+  iptb init -n $NUM_NODES --bootstrap=none --port=0
+'
+
+startup_cluster $NUM_NODES
+
+test_expect_success 'node 1 pins a dag added to node 0' '
+  mkdir -p dir/sub &&
+  echo "pin verify fix one" >dir/one &&
+  echo "pin verify fix two" >dir/sub/two &&
+  DIR=$(ipfsi 0 add -r -q --raw-leaves dir | tail -1) &&
+  ipfsi 1 pin add $DIR
+'
+
+test_expect_success 'corrupt a block of node 1 and remove another' '
+  ONE=$(ipfsi 1 add -q --raw-leaves --only-hash dir/one) &&
+  TWO=$(ipfsi 1 add -q --raw-leaves --only-hash dir/sub/two) &&
+  ONE_FILE=$(grep -rl "pin verify fix one" "$IPTB_ROOT/1/blocks") &&
+  TWO_FILE=$(grep -rl "pin verify fix two" "$IPTB_ROOT/1/blocks") &&
+  echo "corrupted" >"$ONE_FILE" &&
+  rm "$TWO_FILE" &&
+  ipfsi 1 pin verify --quiet >verify_out &&
+  echo "$DIR" >verify_exp &&
+  test_cmp verify_exp verify_out
+'
+
+test_expect_success 'ipfs pin verify --fix fetches the bad blocks again' '
+  ipfsi 1 pin verify --fix >verify_out &&
+  grep "^$DIR fixed\$" verify_out &&
+  grep "^  $ONE: fixed\$" verify_out &&
+  grep "^  $TWO: fixed\$" verify_out
+'
+
+test_expect_success 'the pin is complete' '
+  ipfsi 1 pin verify >verify_out &&
+  test_must_be_empty verify_out &&
+  ipfsi 1 cat $DIR/one >one_out &&
+  test_cmp dir/one one_out
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
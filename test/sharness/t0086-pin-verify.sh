@@ -0,0 +1,74 @@
+#!/bin/sh
+
+test_description="Test ipfs pin verify"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "pin some files" '
+	mkdir -p dir/sub &&
+	echo "pin verify leaf one" >dir/one &&
+	echo "pin verify leaf two" >dir/sub/two &&
+	DIR=$(ipfs add -r -q --raw-leaves dir | tail -1) &&
+	echo "pin verify other" >other &&
+	OTHER=$(ipfs add -q other)
+'
+
+test_expect_success "ipfs pin verify prints nothing when all pins are ok" '
+	ipfs pin verify >verify_out &&
+	test_must_be_empty verify_out
+'
+
+test_expect_success "ipfs pin verify --verbose prints the ok pins" '
+	ipfs pin verify --verbose >verify_out &&
+	grep "^$DIR ok\$" verify_out &&
+	grep "^$OTHER ok\$" verify_out
+'
+
+test_expect_success "corrupt a block and remove another" '
+	ONE=$(ipfs add -q --raw-leaves --only-hash dir/one) &&
+	TWO=$(ipfs add -q --raw-leaves --only-hash dir/sub/two) &&
+	ONE_FILE=$(grep -rl "pin verify leaf one" "$IPFS_PATH/blocks") &&
+	TWO_FILE=$(grep -rl "pin verify leaf two" "$IPFS_PATH/blocks") &&
+	cp "$ONE_FILE" one_backup &&
+	cp "$TWO_FILE" two_backup &&
+	echo "corrupted" >"$ONE_FILE" &&
+	rm "$TWO_FILE"
+'
+
+test_expect_success "ipfs pin verify reports the broken pin" '
+	ipfs pin verify >verify_out &&
+	grep "^$DIR broken\$" verify_out &&
+	grep "^  $ONE: " verify_out &&
+	grep "^  $TWO: missing\$" verify_out &&
+	test_must_fail grep "$OTHER" verify_out
+'
+
+test_expect_success "ipfs pin verify --quiet prints the broken pins" '
+	ipfs pin verify --quiet >verify_out &&
+	echo "$DIR" >verify_exp &&
+	test_cmp verify_exp verify_out
+'
+
+test_expect_success "ipfs pin verify --fix can't fetch the blocks offline" '
+	ipfs pin verify --fix >verify_out &&
+	grep "^$DIR broken\$" verify_out &&
+	grep "^  $TWO: missing\$" verify_out &&
+	test_must_fail grep "fixed" verify_out
+'
+
+test_expect_success "ipfs pin verify --fix deleted the corrupt block" '
+	test_must_fail test -e "$ONE_FILE" &&
+	ipfs pin verify >verify_out &&
+	grep "^  $ONE: missing\$" verify_out
+'
+
+test_expect_success "restore the blocks" '
+	cp one_backup "$ONE_FILE" &&
+	cp two_backup "$TWO_FILE" &&
+	ipfs pin verify >verify_out &&
+	test_must_be_empty verify_out
+'
+
+test_done
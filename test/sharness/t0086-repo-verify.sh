@@ -57,4 +57,27 @@ do
 	check_random_corruption
 done
 
+test_expect_success "repo verify is paced as set in the config" '
+	ipfs config --json Datastore.MaintenanceMaxOps 1000 &&
+	ipfs config Datastore.MaintenanceMaxRate 100MB &&
+	ipfs repo verify
+'
+
+test_expect_success "repo gc is paced as set in the config" '
+	UNPINNED=$(echo "paced gc" | ipfs block put) &&
+	ipfs repo gc >gc_out &&
+	grep "removed $UNPINNED" gc_out &&
+	test_must_fail ipfs block stat $UNPINNED
+'
+
+test_expect_success "repo verify rejects an invalid pace" '
+	ipfs config Datastore.MaintenanceMaxRate fast &&
+	test_expect_code 1 ipfs repo verify 2>verify_err &&
+	grep "invalid maintenance rate" verify_err
+'
+
+test_expect_success "reset the pace" '
+	ipfs config Datastore.MaintenanceMaxRate ""
+'
+
 test_done
@@ -0,0 +1,61 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test targeted ipfs repo gc"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_gc_only() {
+  test_expect_success "add unpinned blocks" '
+    echo "obsolete 1" | ipfs block put >obsolete1 &&
+    echo "obsolete 2" | ipfs block put >obsolete2 &&
+    echo "other" | ipfs block put >other &&
+    echo "kept" | ipfs add -q >kept
+  '
+
+  test_expect_success "'ipfs repo gc' with a candidates file succeeds" '
+    cat obsolete1 >candidates &&
+    cat kept >>candidates &&
+    ipfs repo gc -q candidates >gc_out
+  '
+
+  test_expect_success "only the unpinned candidate was removed" '
+    test_cmp obsolete1 gc_out &&
+    ipfs refs local >local_refs &&
+    test_must_fail grep $(cat obsolete1) local_refs &&
+    ipfs block stat $(cat obsolete2) &&
+    ipfs block stat $(cat other) &&
+    ipfs block stat $(cat kept)
+  '
+
+  test_expect_success "'ipfs repo gc --only' removes the matching blocks" '
+    ipfs repo gc -q --only=$(cat obsolete2) >gc_out &&
+    test_cmp obsolete2 gc_out &&
+    ipfs block stat $(cat other)
+  '
+
+  test_expect_success "'ipfs repo gc --only' keeps the pinned blocks" '
+    ipfs repo gc -q --only=$(cat kept),$(cat other) >gc_out &&
+    test_cmp other gc_out &&
+    ipfs block stat $(cat kept)
+  '
+
+  test_expect_success "'ipfs repo gc' fails on an invalid candidate" '
+    echo "not a cid" >bad_candidates &&
+    test_must_fail ipfs repo gc bad_candidates
+  '
+}
+
+test_gc_only
+
+test_launch_ipfs_daemon
+
+test_gc_only
+
+test_kill_ipfs_daemon
+
+test_done
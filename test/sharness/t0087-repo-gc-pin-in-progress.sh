@@ -0,0 +1,40 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs repo gc while pins are in progress"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+test_launch_ipfs_daemon
+
+test_expect_success "start pinning an object nobody has" '
+	MISSING=$(echo "nobody has this" | ipfs add -q --only-hash) &&
+	(ipfs pin add --timeout=20s "$MISSING" >/dev/null 2>&1 &) &&
+	go-sleep 500ms
+'
+
+test_expect_success "'ipfs repo gc' doesn't wait for the pin" '
+	echo "garbage" | ipfs add -q --pin=false >garbage_hash &&
+	ipfs repo gc --timeout=10s >gc_out &&
+	grep "$(cat garbage_hash)" gc_out
+'
+
+test_expect_success "the blocks of a pin in progress are kept" '
+	mkdir -p inprogress &&
+	echo "in progress a" >inprogress/a &&
+	echo "in progress b" >inprogress/b &&
+	DIR=$(ipfs add -r -q --pin=false inprogress | tail -n1) &&
+	ipfs block rm $(ipfs add -q --only-hash inprogress/b) &&
+	(ipfs pin add --timeout=20s "$DIR" >pin_out 2>&1 &) &&
+	go-sleep 500ms &&
+	ipfs repo gc --timeout=10s &&
+	ipfs block stat "$DIR" &&
+	ipfs block stat $(ipfs add -q --only-hash inprogress/a)
+'
+
+test_kill_ipfs_daemon
+
+test_done
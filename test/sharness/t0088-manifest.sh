@@ -0,0 +1,45 @@
+#!/bin/sh
+
+test_description="Test signed content manifests"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add and pin some objects" '
+	HASH_A=$(echo "manifest a" | ipfs add -q) &&
+	HASH_B=$(echo "manifest b" | ipfs add -q)
+'
+
+test_expect_success "create a manifest with a named key" '
+	KEYID=$(ipfs key gen mirror --type=rsa --size=2048) &&
+	ipfs manifest create --key=mirror $HASH_A $HASH_B > manifest.json &&
+	grep "\"Publisher\": \"$KEYID\"" manifest.json
+'
+
+test_expect_success "manifest verifies" '
+	ipfs manifest verify --publisher=$KEYID manifest.json > verify_out &&
+	grep "$HASH_A [0-9]*: ok" verify_out &&
+	grep "$HASH_B [0-9]*: ok" verify_out &&
+	grep "all roots replicated" verify_out
+'
+
+test_expect_success "manifest from another publisher is rejected" '
+	PEERID=$(ipfs config Identity.PeerID) &&
+	test_must_fail ipfs manifest verify --publisher=$PEERID manifest.json
+'
+
+test_expect_success "unpinned roots are reported" '
+	ipfs pin rm $HASH_B &&
+	ipfs manifest verify manifest.json > verify_out &&
+	grep "$HASH_B [0-9]*: not pinned" verify_out &&
+	grep "some roots are not replicated" verify_out
+'
+
+test_expect_success "tampered manifest is rejected" '
+	sed -e "s/\"Size\": \([0-9]*\)/\"Size\": 1\1/" manifest.json > tampered.json &&
+	test_must_fail ipfs manifest verify tampered.json 2> verify_err &&
+	grep "signature is invalid" verify_err
+'
+
+test_done
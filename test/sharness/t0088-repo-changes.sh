@@ -0,0 +1,68 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs repo changes"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "'ipfs repo changes' fails when disabled" '
+	test_must_fail ipfs repo changes 2>err &&
+	grep "change feed is disabled" err
+'
+
+test_expect_success "enable the change feed" '
+	ipfs config --bool Datastore.ChangeFeed true
+'
+
+test_expect_success "added blocks are listed in order" '
+	HASH1=$(echo "first block" | ipfs block put) &&
+	HASH2=$(echo "second block" | ipfs block put) &&
+	ipfs repo changes >actual &&
+	grep "^[0-9]* $HASH1\$" actual &&
+	grep "^[0-9]* $HASH2\$" actual &&
+	SEQ1=$(grep "$HASH1" actual | cut -d" " -f1) &&
+	SEQ2=$(grep "$HASH2" actual | cut -d" " -f1) &&
+	test "$SEQ2" -eq $((SEQ1 + 1))
+'
+
+test_expect_success "blocks already stored are not listed again" '
+	echo "first block" | ipfs block put &&
+	ipfs repo changes >actual &&
+	test $(grep -c "$HASH1" actual) -eq 1
+'
+
+test_expect_success "--since lists the blocks added after the cursor" '
+	ipfs repo changes --since=$SEQ1 >actual &&
+	echo "$SEQ2 $HASH2" >expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "--data includes the content of the blocks" '
+	ipfs repo changes --since=$SEQ1 --data >actual &&
+	echo "$SEQ2 $HASH2 $(echo "second block" | base64)" >expected &&
+	test_cmp expected actual
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "--follow streams the new blocks" '
+	ipfs repo changes --follow --since=$SEQ2 >follow_out &
+	FOLLOW_PID=$! &&
+	HASH3=$(echo "third block" | ipfs block put) &&
+	for i in $(test_seq 1 50)
+	do
+		grep "$HASH3" follow_out && break
+		go-sleep 100ms
+	done &&
+	kill $FOLLOW_PID &&
+	echo "$((SEQ2 + 1)) $HASH3" >expected &&
+	test_cmp expected follow_out
+'
+
+test_kill_ipfs_daemon
+
+test_done
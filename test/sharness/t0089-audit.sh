@@ -0,0 +1,75 @@
+#!/bin/sh
+
+test_description="Test the audit log"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "nothing is recorded by default" '
+	HASH=$(echo "audited" | ipfs add -q --pin=false) &&
+	ipfs pin add $HASH &&
+	ipfs audit tail >tail_out &&
+	test_must_be_empty tail_out
+'
+
+test_expect_success "enable the audit log" '
+	ipfs config --bool Audit.Enabled true &&
+	ipfs config --bool Audit.HashChain true
+'
+
+test_expect_success "mutating commands are recorded" '
+	ipfs pin rm $HASH &&
+	ipfs files mkdir /audited &&
+	ipfs key gen --type=ed25519 auditkey &&
+	ipfs audit tail >tail_out &&
+	grep "local config Audit.HashChain true" tail_out &&
+	grep "local pin rm $HASH\$" tail_out &&
+	grep "local files mkdir /audited\$" tail_out &&
+	grep "local key gen auditkey\$" tail_out
+'
+
+test_expect_success "reading commands are not recorded" '
+	ipfs config Audit.Enabled &&
+	ipfs files ls / &&
+	ipfs audit tail -n 1 >tail_out &&
+	grep "key gen auditkey" tail_out
+'
+
+test_expect_success "failed requests are recorded" '
+	test_must_fail ipfs pin rm $HASH &&
+	ipfs audit tail -n 1 >tail_out &&
+	grep "pin rm $HASH (failed: " tail_out
+'
+
+test_expect_success "ipfs audit verify succeeds" '
+	ipfs audit verify >verify_out &&
+	grep "^verified 5 chained entries of 6\$" verify_out
+'
+
+test_expect_success "ipfs audit verify finds modified entries" '
+	cp "$IPFS_PATH/audit.log" audit_backup &&
+	sed -i.bak "s/auditkey/otherkey/" "$IPFS_PATH/audit.log" &&
+	test_must_fail ipfs audit verify 2>verify_err &&
+	grep "was modified" verify_err
+'
+
+test_expect_success "ipfs audit verify finds removed entries" '
+	sed "2d" audit_backup >"$IPFS_PATH/audit.log" &&
+	test_must_fail ipfs audit verify 2>verify_err &&
+	grep "entries were removed" verify_err &&
+	cp audit_backup "$IPFS_PATH/audit.log"
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "requests to the daemon are recorded" '
+	ipfs pin add $HASH &&
+	ipfs audit tail -n 1 >tail_out &&
+	grep "127.0.0.1 pin add $HASH\$" tail_out &&
+	ipfs audit verify
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,70 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the check of the repo on daemon start and its repair"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "a clean repo starts" '
+  ipfs files mkdir /recover-first &&
+  ROOT1=$(ipfs files stat --hash /) &&
+  ipfs files mkdir /recover-second &&
+  ROOT2=$(ipfs files stat --hash /)
+'
+
+test_launch_ipfs_daemon --recover=false
+
+test_expect_success "no problem is reported" '
+  test_must_fail grep "Found problems" actual_daemon
+'
+
+test_kill_ipfs_daemon
+
+# closing a node writes the block of the files root again, remove its file
+test_expect_success "remove the block of the files root" '
+  ROOT2_FILE=$(grep -rl "recover-second" "$IPFS_PATH/blocks") &&
+  rm "$ROOT2_FILE"
+'
+
+test_expect_success "'ipfs daemon --recover=false' fails" '
+  test_expect_code 1 ipfs daemon --recover=false >daemon_out 2>daemon_err
+'
+
+test_expect_success "the broken files root is reported" '
+  grep "Found problems in the repo" daemon_out &&
+  grep "files root: cannot load root $ROOT2" daemon_out &&
+  grep "ipfs daemon --recover" daemon_err
+'
+
+test_expect_success "offline commands point to the repair" '
+  test_must_fail ipfs files ls / 2>files_err &&
+  grep "ipfs daemon --recover" files_err
+'
+
+test_launch_ipfs_daemon --recover
+
+test_expect_success "the files root was repaired" '
+  grep "Repaired the files root." actual_daemon
+'
+
+test_expect_success "the last root which can be loaded is restored" '
+  echo $ROOT1 >expected_root &&
+  ipfs files stat --hash / >actual_root &&
+  test_cmp expected_root actual_root
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "an invalid version file is reported" '
+  cp "$IPFS_PATH/version" version.bak &&
+  echo garbage >"$IPFS_PATH/version" &&
+  test_must_fail ipfs daemon >daemon_out 2>daemon_err &&
+  grep "invalid repo version \"garbage\"" daemon_err &&
+  mv version.bak "$IPFS_PATH/version"
+'
+
+test_done
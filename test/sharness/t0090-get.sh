@@ -120,6 +120,25 @@ test_get_cmd() {
 		rm -r "$HASH2"
 	'
 
+	test_expect_success "ipfs get -j writes many files (directory)" '
+		mkdir -p many/sub &&
+		for i in 1 2 3 4 5 6 7 8 9 10 11 12; do
+			echo "small file $i" >many/f$i &&
+			echo "nested file $i" >many/sub/f$i || return 1
+		done &&
+		random 2000000 42 >many/sub/big &&
+		HASH3=`ipfs add -r -q many | tail -n 1` &&
+		ipfs get -j 4 -o many_out "$HASH3" &&
+		diff -r many many_out &&
+		rm -r many_out
+	'
+
+	test_expect_success "ipfs get -j 1 writes files one at a time (directory)" '
+		ipfs get -j 1 -o many_out "$HASH3" &&
+		diff -r many many_out &&
+		rm -r many_out
+	'
+
 	test_expect_success "ipfs get ../.. should fail" '
 		echo "Error: invalid 'ipfs ref' path" >expected &&
 		test_must_fail ipfs get ../.. 2>actual &&
@@ -74,4 +74,85 @@ test_expect_failure "publish with our explicit node ID looks good" '
 	test_cmp expected_node_id_publish actual_node_id_publish
 '
 
+# delegate a sub-name to another key
+
+test_expect_success "'ipfs name delegate' succeeds" '
+	ipfs key gen --type=rsa --size=2048 blogkey >blogkey_id &&
+	ipfs name delegate blog blogkey >delegation
+'
+
+test_expect_success "'ipfs name publish --delegation' succeeds" '
+	ipfs name publish --key=blogkey --delegation="$(cat delegation)" "/ipfs/$HASH_WELCOME_DOCS/about" >publish_out
+'
+
+test_expect_success "delegated publish output looks good" '
+	echo "Published to ${PEERID}/blog: /ipfs/$HASH_WELCOME_DOCS/about" >expected_delegated &&
+	test_cmp expected_delegated publish_out
+'
+
+test_expect_success "'ipfs name resolve' doesn't look the sub-name up by default" '
+	ipfs name resolve "/ipns/$PEERID/blog" >output &&
+	printf "/ipfs/%s/help/blog\n" "$HASH_WELCOME_DOCS" >expected_nosub &&
+	test_cmp expected_nosub output
+'
+
+test_expect_success "'ipfs name publish --sub-names' succeeds" '
+	ipfs name publish --sub-names "/ipfs/$HASH_WELCOME_DOCS" >publish_out
+'
+
+test_expect_success "'ipfs name resolve' resolves the sub-name" '
+	ipfs name resolve "/ipns/$PEERID/blog" >output &&
+	printf "/ipfs/%s/about\n" "$HASH_WELCOME_DOCS" >expected_sub &&
+	test_cmp expected_sub output
+'
+
+test_expect_success "'ipfs cat' reads the delegated content" '
+	ipfs cat "/ipfs/$HASH_WELCOME_DOCS/about" >expected &&
+	ipfs cat "/ipns/$PEERID/blog" >actual &&
+	test_cmp expected actual
+'
+
+test_expect_success "'ipfs name publish --delegation' fails with another key" '
+	test_must_fail ipfs name publish --delegation="$(cat delegation)" "/ipfs/$HASH_WELCOME_DOCS" 2>publish_err &&
+	grep "for another key" publish_err
+'
+
+test_expect_success "'ipfs name publish --delegation --sub-names' fails" '
+	test_must_fail ipfs name publish --key=blogkey --sub-names --delegation="$(cat delegation)" "/ipfs/$HASH_WELCOME_DOCS" 2>publish_err &&
+	grep "sub-names of their own" publish_err
+'
+
+# validity types and publish state
+
+test_expect_success "'ipfs name publish --validity-type=perpetual' succeeds" '
+	ipfs name publish --validity-type=perpetual "/ipfs/$HASH_WELCOME_DOCS" >publish_out &&
+	ipfs name resolve "$PEERID" >output &&
+	printf "/ipfs/%s\n" "$HASH_WELCOME_DOCS" >expected_perpetual &&
+	test_cmp expected_perpetual output
+'
+
+test_expect_success "'ipfs name publish' fails with an unknown validity type" '
+	test_must_fail ipfs name publish --validity-type=forever "/ipfs/$HASH_WELCOME_DOCS" 2>publish_err &&
+	grep "unknown validity type" publish_err
+'
+
+test_expect_success "'ipfs name state export' succeeds" '
+	ipfs name state export >state &&
+	grep "\"Name\": \"$PEERID\"" state &&
+	grep "\"Perpetual\": true" state
+'
+
+test_expect_success "'ipfs name state import' keeps the higher sequence" '
+	sed "s/\"Sequence\": [0-9]*/\"Sequence\": 1000/" state >state_ahead &&
+	ipfs name state import state_ahead &&
+	ipfs name state import state >import_out &&
+	grep "sequence 1000" import_out
+'
+
+test_expect_success "'ipfs name publish' continues from the imported sequence" '
+	ipfs name publish "/ipfs/$HASH_WELCOME_DOCS" &&
+	ipfs name state export >state_after &&
+	grep "\"Sequence\": 1001" state_after
+'
+
 test_done
@@ -165,11 +165,49 @@ test_bootstrap_cmd() {
   '
 
   test_bootstrap_list_cmd
+
+  test_expect_success "'ipfs bootstrap add default --set=public' adds the default peers" '
+    ipfs bootstrap add $BP2 &&
+    ipfs bootstrap add default --set=public >add3_actual &&
+    test_cmp add2_expected add3_actual
+  '
+
+  test_bootstrap_list_cmd $BP1 $BP2 $BP3 $BP4 $BP5 $BP6 $BP7 $BP8 $BP9 $BP10 $BP11 $BP12 $BP13 $BP14 $BP15 $BP16 $BP17
+
+  test_expect_success "'ipfs bootstrap add default --set=pnet --append=false' empties the list" '
+    ipfs bootstrap add default --set=pnet --append=false >add4_actual &&
+    test_must_be_empty add4_actual
+  '
+
+  test_bootstrap_list_cmd
+
+  test_expect_success "'ipfs bootstrap add --append=false' replaces the list" '
+    ipfs bootstrap add $BP1 $BP2 &&
+    ipfs bootstrap add --append=false $BP3
+  '
+
+  test_bootstrap_list_cmd $BP3
+
+  test_expect_success "'ipfs bootstrap add --default --set=lan --append=false' empties the list" '
+    ipfs bootstrap add --default --set=lan --append=false
+  '
+
+  test_bootstrap_list_cmd
+
+  test_expect_success "'ipfs bootstrap add default' fails with an unknown set" '
+    test_must_fail ipfs bootstrap add default --set=nope 2>set_err &&
+    grep "unknown set of bootstrap peers" set_err
+  '
 }
 
 # should work offline
 test_bootstrap_cmd
 
+test_expect_success "'ipfs bootstrap health' needs the daemon" '
+  test_must_fail ipfs bootstrap health 2>health_err &&
+  grep "must be run in online mode" health_err
+'
+
 # should work online
 test_launch_ipfs_daemon
 test_bootstrap_cmd
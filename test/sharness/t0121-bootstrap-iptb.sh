@@ -78,6 +78,21 @@ test_expect_success "correct number of peers" '
 	test `cat peers_out | wc -l` = 5
 '
 
+test_expect_success "'ipfs bootstrap health' reports the bootstrap peer" '
+	ipfsi 0 bootstrap health >health_out &&
+	grep "^ok $BADDR [0-9.]*[^ ]*s$" health_out &&
+	grep "^1 of 1 bootstrap peers reachable$" health_out
+'
+
+test_expect_success "'ipfs bootstrap health' reports dead peers" '
+	DEAD="/ip4/127.0.0.1/tcp/1/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ" &&
+	ipfsi 0 bootstrap add $DEAD &&
+	ipfsi 0 bootstrap health --dial-timeout=5s >health_out &&
+	grep "^ok $BADDR " health_out &&
+	grep "^failed $DEAD: " health_out &&
+	grep "^1 of 2 bootstrap peers reachable$" health_out
+'
+
 test_kill_ipfs_daemon
 
 test_expect_success "bring down iptb nodes" '
@@ -47,6 +47,65 @@ test_expect_success "cant trigger a dial backoff with swarm connect" '
 	test_expect_code 1 grep "backoff" connect_out
 '
 
+test_expect_success "version check works without peers" '
+	ipfs version check >check_out &&
+	grep "^local version: " check_out &&
+	grep "^peers: 0 (0 on a newer release)" check_out &&
+	test_must_fail grep WARNING check_out
+'
+
+peering_id="QmUWKoHbjsqsSMesRC2Zoscs8edyFz6F77auBB1YBBhgpX"
+
+test_expect_success "swarm peering add saves the peer" '
+	ipfs swarm peering add $addr >add_out &&
+	grep "^$peering_id disconnected" add_out &&
+	ipfs config Peering.Peers >peers_out &&
+	grep "$addr" peers_out
+'
+
+test_expect_success "swarm peering ls lists the peer" '
+	ipfs swarm peering ls --enc=json >ls_out &&
+	grep "\"ID\":\"$peering_id\"" ls_out &&
+	grep "/ip4/127.0.0.1/tcp/9898" ls_out
+'
+
+test_expect_success "swarm peering rm removes the peer" '
+	ipfs swarm peering rm $peering_id >rm_out &&
+	grep "^$peering_id" rm_out &&
+	ipfs swarm peering ls >ls_out &&
+	test_must_be_empty ls_out
+'
+
+test_expect_success "swarm peering add rejects invalid addresses" '
+	test_expect_code 1 ipfs swarm peering add /ip4/127.0.0.1/tcp/9898
+'
+
+test_expect_success "LAN mode is off by default" '
+	echo off >expected &&
+	ipfs swarm lan >actual &&
+	test_cmp expected actual
+'
+
+test_expect_success "swarm lan on turns the LAN mode on" '
+	echo on >expected &&
+	ipfs swarm lan on >actual &&
+	test_cmp expected actual &&
+	ipfs swarm filters >filters &&
+	grep "/ip4/8.0.0.0/ipcidr/7" filters
+'
+
+test_expect_success "swarm lan off removes the LAN filters" '
+	echo off >expected &&
+	ipfs swarm lan off >actual &&
+	test_cmp expected actual &&
+	ipfs swarm filters >filters &&
+	test_must_fail grep "/ip4/8.0.0.0/ipcidr/7" filters
+'
+
+test_expect_success "swarm lan rejects invalid modes" '
+	test_expect_code 1 ipfs swarm lan maybe
+'
+
 test_kill_ipfs_daemon
 
 test_done
@@ -0,0 +1,61 @@
+#!/bin/sh
+
+test_description="Test ipfs swarm stats"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "ipfs swarm stats needs a daemon" '
+	test_must_fail ipfs swarm stats 2>stats_err &&
+	grep "must be run in online mode" stats_err
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "ipfs swarm stats prints the totals" '
+	ipfs swarm stats >stats_out &&
+	grep "^PROTOCOL" stats_out &&
+	grep "^total " stats_out &&
+	test $(wc -l <stats_out) -eq 2
+'
+
+test_expect_success "init iptb" '
+	iptb init -n 1 --bootstrap=none --port=0 &&
+	iptb start &&
+	PEER_ID=$(iptb get id 0) &&
+	PEER_ADDR=$(ipfsi 0 swarm addrs local | grep 127.0.0.1 | head -1) &&
+	ipfs swarm connect "$PEER_ADDR/ipfs/$PEER_ID"
+'
+
+test_expect_success "fetching a block from the peer uses bitswap" '
+	HASH=$(echo "swarm stats" | ipfsi 0 add -q) &&
+	ipfs cat $HASH
+'
+
+test_expect_success "ipfs swarm stats --protocol breaks the stats down" '
+	ipfs swarm stats --protocol >stats_out &&
+	grep "^/ipfs/bitswap" stats_out &&
+	grep "^/ipfs/id/" stats_out &&
+	grep "^total " stats_out
+'
+
+test_expect_success "ipfs swarm stats --protocol counts bitswap streams" '
+	ipfs swarm stats --protocol --enc=json >stats_json &&
+	grep "\"Protocol\":\"/ipfs/bitswap" stats_json &&
+	grep "\"StreamsTotal\":[1-9]" stats_json
+'
+
+test_expect_success "the protocol stats are exported to prometheus" '
+	curl -s "$API_ADDR/debug/metrics/prometheus" >metrics_out &&
+	grep "^ipfs_p2p_protocol_streams_total{protocol=\"/ipfs/bitswap" metrics_out &&
+	grep "^ipfs_p2p_protocol_bytes_total{direction=\"in\",protocol=\"/ipfs/bitswap" metrics_out
+'
+
+test_expect_success "stop iptb" '
+	iptb stop
+'
+
+test_kill_ipfs_daemon
+
+test_done
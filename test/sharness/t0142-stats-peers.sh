@@ -0,0 +1,64 @@
+#!/bin/sh
+
+test_description="Test ipfs stats peers"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "ipfs stats peers needs a daemon" '
+	test_must_fail ipfs stats peers 2>stats_err &&
+	grep "must be run in online mode" stats_err
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "init iptb" '
+	iptb init -n 2 --bootstrap=none --port=0 &&
+	iptb start &&
+	for i in 0 1; do
+		PEER_ID=$(iptb get id $i) &&
+		PEER_ADDR=$(ipfsi $i swarm addrs local | grep 127.0.0.1 | head -1) &&
+		ipfs swarm connect "$PEER_ADDR/ipfs/$PEER_ID" || return 1
+	done &&
+	PEER0=$(iptb get id 0) &&
+	PEER1=$(iptb get id 1)
+'
+
+test_expect_success "fetching a file from a peer uses bitswap" '
+	random 100000 42 >bigfile &&
+	HASH=$(ipfsi 1 add -q bigfile) &&
+	ipfs cat $HASH >fetched &&
+	test_cmp bigfile fetched
+'
+
+test_expect_success "ipfs stats peers lists the connected peers" '
+	ipfs stats peers >stats_out &&
+	grep "^PEER" stats_out &&
+	grep "^$PEER0 " stats_out &&
+	grep "^$PEER1 " stats_out
+'
+
+test_expect_success "ipfs stats peers --sort=bitswap puts the peer which sent the file first" '
+	ipfs stats peers --sort=bitswap --top 1 >stats_out &&
+	test $(wc -l <stats_out) -eq 2 &&
+	grep "^$PEER1 " stats_out
+'
+
+test_expect_success "ipfs stats peers counts the bitswap bytes received" '
+	ipfs stats peers --sort=bitswap --top=1 --enc=json >stats_json &&
+	grep "\"BitswapRecv\":[1-9][0-9]\{5\}" stats_json
+'
+
+test_expect_success "ipfs stats peers --sort rejects unknown orders" '
+	test_must_fail ipfs stats peers --sort=height 2>stats_err &&
+	grep "unknown sort order" stats_err
+'
+
+test_expect_success "stop iptb" '
+	iptb stop
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,88 @@
+#!/bin/sh
+
+test_description="Test ipfs crypt"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_crypt() {
+	test_expect_success "ipfs crypt add needs a secret" '
+		random 200000 7 >plain &&
+		test_must_fail ipfs crypt add plain 2>add_err &&
+		grep "give the secret" add_err
+	'
+
+	test_expect_success "ipfs crypt add refuses the key of the node" '
+		test_must_fail ipfs crypt add --key=self plain 2>add_err &&
+		grep "identity key of the node" add_err
+	'
+
+	test_expect_success "ipfs crypt add encrypts a file with a keystore key" '
+		ipfs key gen crypttest --type=ed25519 &&
+		ipfs crypt add --key=crypttest plain >add_out &&
+		MANIFEST=$(sed -n "s/^added //p" add_out) &&
+		test -n "$MANIFEST"
+	'
+
+	test_expect_success "the manifest is pinned recursively" '
+		ipfs pin ls --type=recursive -q | grep $MANIFEST
+	'
+
+	test_expect_success "the content stored is encrypted" '
+		CONTENT=$(ipfs object links $MANIFEST | cut -d" " -f1) &&
+		ipfs cat $CONTENT >stored &&
+		test_must_fail test_cmp plain stored
+	'
+
+	test_expect_success "ipfs crypt cat decrypts the content" '
+		ipfs crypt cat --key=crypttest $MANIFEST >decrypted &&
+		test_cmp plain decrypted
+	'
+
+	test_expect_success "ipfs crypt cat fails with another key" '
+		ipfs key gen crypttest2 --type=ed25519 &&
+		test_must_fail ipfs crypt cat --key=crypttest2 $MANIFEST 2>cat_err &&
+		grep "wrong key" cat_err &&
+		grep "with the key crypttest$" cat_err
+	'
+
+	test_expect_success "ipfs crypt with a secret file" '
+		echo 000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f >secret &&
+		echo 000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e00 >other_secret &&
+		echo "secret key" | ipfs crypt add --secret-file=secret --pin=false >add_out &&
+		MANIFEST=$(sed -n "s/^added //p" add_out) &&
+		ipfs crypt cat --secret-file=secret $MANIFEST >decrypted &&
+		echo "secret key" >expected &&
+		test_cmp expected decrypted &&
+		test_must_fail ipfs crypt cat --secret-file=other_secret $MANIFEST
+	'
+
+	test_expect_success "ipfs crypt rejects bad secrets" '
+		echo 0102 >short_secret &&
+		test_must_fail ipfs crypt add --secret-file=short_secret plain 2>add_err &&
+		grep "32 bytes" add_err &&
+		echo nothex >bad_secret &&
+		test_must_fail ipfs crypt add --secret-file=bad_secret plain &&
+		test_must_fail ipfs crypt add --secret-file=missing_secret plain
+	'
+
+	test_expect_success "ipfs crypt cat rejects objects which aren't manifests" '
+		test_must_fail ipfs crypt cat --key=crypttest $CONTENT 2>cat_err &&
+		grep "not an encryption manifest" cat_err
+	'
+
+	test_expect_success "clean up" '
+		ipfs key rm crypttest crypttest2
+	'
+}
+
+test_crypt
+
+test_launch_ipfs_daemon
+
+test_crypt
+
+test_kill_ipfs_daemon
+
+test_done
@@ -25,9 +25,21 @@ test_expect_success 'findpeer' '
 '
 
 # ipfs dht put <key> <value>
-test_expect_success 'put' '
-  ipfsi 1 dht put planet pluto | sort >putted &&
-  [ -s putted ] ||
+test_expect_success 'put refuses keys without a keytype' '
+  test_must_fail ipfsi 1 dht put planet pluto 2>putted &&
+  grep "invalid record key" putted ||
+	test_fsh cat putted
+'
+
+test_expect_success 'put refuses unknown keytypes' '
+  test_must_fail ipfsi 1 dht put /planet/pluto moon 2>putted &&
+  grep "no validator is registered for the key namespace" putted ||
+	test_fsh cat putted
+'
+
+test_expect_success 'put refuses invalid records' '
+  test_must_fail ipfsi 1 dht put /ipns/$PEERID_2 pluto 2>putted &&
+  grep "invalid record" putted ||
 	test_fsh cat putted
 '
 
@@ -43,11 +55,60 @@ test_expect_success 'findprovs' '
 	test_cmp provs expected
 '
 
+# ipfs find-providers <key>
+test_expect_success 'find-providers' '
+	ipfsi 4 find-providers $HASH >provs &&
+	iptb get id 3 >expected &&
+	test_cmp expected provs
+'
+
+test_expect_success 'find-providers --streaming --num stops after the first provider' '
+	ipfsi 2 add -q afile &&
+	ipfsi 4 find-providers --streaming --num=1 $HASH >provs &&
+	test $(wc -l <provs) -eq 1
+'
+
+test_expect_success 'find-providers prints the providers metadata' '
+	ipfsi 4 find-providers --enc=json /ipfs/$HASH >provs_json &&
+	grep "\"ID\": *\"$(iptb get id 3)\"" provs_json &&
+	grep "\"Addrs\": *\[" provs_json &&
+	grep "/ip4/127.0.0.1/tcp/" provs_json
+'
+
+test_expect_success 'find-providers refuses a non positive --num' '
+	test_must_fail ipfsi 4 find-providers --num=0 $HASH 2>actual &&
+	grep "must be positive" actual
+'
+
 # ipfs dht get <key>
 test_expect_success 'get' '
-  ipfsi 0 dht put bar foo >actual &&
   ipfsi 4 dht get -v bar >actual &&
-  egrep "error: record key does not have selectorfunc" actual > /dev//null ||
+  egrep "error: routing: not found" actual > /dev//null ||
+	test_fsh cat actual
+'
+
+test_expect_success 'get with a quorum of one record' '
+  ipfsi 0 name publish $HASH &&
+  ipfsi 4 dht get --quorum=1 /ipns/$PEERID_0 >actual &&
+  grep -a "$HASH" actual ||
+	test_fsh cat actual
+'
+
+test_expect_success 'get with a timeout per attempt' '
+  ipfsi 4 dht get --quorum=1 --attempt-timeout=30s --retries=1 /ipns/$PEERID_0 >actual &&
+  grep -a "$HASH" actual ||
+	test_fsh cat actual
+'
+
+test_expect_success 'get refuses invalid attempt timeouts' '
+  test_must_fail ipfsi 4 dht get --attempt-timeout=soon /ipns/$PEERID_0 2>actual &&
+  grep "error parsing attempt-timeout option" actual ||
+	test_fsh cat actual
+'
+
+test_expect_success 'get refuses unknown keytypes' '
+  test_must_fail ipfsi 4 dht get /planet/pluto 2>actual &&
+  grep "no validator is registered for the key namespace" actual ||
 	test_fsh cat actual
 '
 
@@ -0,0 +1,53 @@
+#!/bin/sh
+
+test_description="Test the announce rules of the reprovider"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "announce rules are empty by default" '
+	ipfs config Reprovider.Rules >rules_out &&
+	echo "[]" >rules_exp &&
+	test_cmp rules_exp rules_out
+'
+
+test_expect_success "the daemon refuses unknown announce rules" '
+	ipfs config --json Reprovider.Rules "[{\"Match\": \"everything\"}]" &&
+	test_must_fail ipfs daemon >daemon_out 2>&1 &&
+	grep "unknown announce rule \"everything\"" daemon_out
+'
+
+test_expect_success "the daemon refuses relative MFS paths" '
+	ipfs config --json Reprovider.Rules "[{\"Match\": \"mfs:tmp\"}]" &&
+	test_must_fail ipfs daemon >daemon_out 2>&1 &&
+	grep "needs an absolute MFS path" daemon_out
+'
+
+test_expect_success "the daemon refuses unregistered announce filters" '
+	ipfs config --json Reprovider.Rules "[]" &&
+	ipfs config --json Reprovider.Filters "[\"nosuchfilter\"]" &&
+	test_must_fail ipfs daemon >daemon_out 2>&1 &&
+	grep "announce filter nosuchfilter is not registered" daemon_out
+'
+
+test_expect_success "configure valid announce rules" '
+	ipfs config --json Reprovider.Filters "[]" &&
+	ipfs config --json Reprovider.Rules "[
+		{\"Match\": \"mfs:/tmp\", \"Announce\": false},
+		{\"Match\": \"named-pins\", \"Announce\": true},
+		{\"Match\": \"all\", \"Announce\": false}
+	]"
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "content can be added with announce rules" '
+	echo "announce rules" | ipfs add -q >hash_out &&
+	ipfs files mkdir /tmp &&
+	ipfs files cp /ipfs/$(cat hash_out) /tmp/file
+'
+
+test_kill_ipfs_daemon
+
+test_done
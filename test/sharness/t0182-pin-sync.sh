@@ -0,0 +1,65 @@
+#!/bin/sh
+
+test_description="Test pinset sync over pubsub"
+
+. lib/test-lib.sh
+
+NUM_NODES=2
+test_expect_success 'init iptb' '
+  iptb init -n $NUM_NODES --bootstrap=none --port=0
+'
+
+startup_cluster $NUM_NODES --enable-pubsub-experiment
+
+test_expect_success 'peer ids' '
+  PEERID_0=$(iptb get id 0)
+'
+
+test_expect_success 'node 1 follows node 0' '
+  ipfsi 1 pin sync follow $PEERID_0 &&
+  ipfsi 1 pin sync status > status_out &&
+  grep "following $PEERID_0" status_out
+'
+
+test_expect_success 'wait for the subscription to propagate' '
+  go-sleep 1s
+'
+
+test_expect_success 'node 0 publishes an object' '
+  HASH=$(echo "pinsync" | ipfsi 0 add -q) &&
+  ipfsi 0 pin sync publish $HASH > publish_out &&
+  echo "published $HASH" > publish_exp &&
+  test_cmp publish_exp publish_out
+'
+
+test_expect_success 'node 1 mirrors the pin' '
+  for i in $(test_seq 1 50); do
+    ipfsi 1 pin ls --type=recursive $HASH && break
+    go-sleep 100ms
+  done &&
+  ipfsi 1 pin ls --type=recursive $HASH
+'
+
+test_expect_success 'node 0 unpublishes the object' '
+  ipfsi 0 pin sync publish --rm $HASH
+'
+
+test_expect_success 'node 1 drops the pin' '
+  for i in $(test_seq 1 50); do
+    test_must_fail ipfsi 1 pin ls --type=recursive $HASH && break
+    go-sleep 100ms
+  done &&
+  test_must_fail ipfsi 1 pin ls --type=recursive $HASH
+'
+
+test_expect_success 'node 1 unfollows node 0' '
+  ipfsi 1 pin sync unfollow $PEERID_0 &&
+  ipfsi 1 pin sync status > status_out &&
+  test_must_fail grep "following" status_out
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
@@ -0,0 +1,147 @@
+#!/bin/sh
+
+test_description="Test private sharing groups over pubsub"
+
+. lib/test-lib.sh
+
+NUM_NODES=3
+test_expect_success 'init iptb' '
+  iptb init -n $NUM_NODES --bootstrap=none --port=0
+'
+
+startup_cluster $NUM_NODES --enable-pubsub-experiment
+
+test_expect_success 'peer ids' '
+  PEERID_0=$(iptb get id 0) &&
+  PEERID_1=$(iptb get id 1)
+'
+
+test_expect_success 'node 0 creates a group' '
+  ipfsi 0 group create friends &&
+  ipfsi 0 group ls > ls_out &&
+  echo "friends owner $PEERID_0" > ls_exp &&
+  test_cmp ls_exp ls_out
+'
+
+test_expect_success 'a group name is only used once' '
+  test_must_fail ipfsi 0 group create friends
+'
+
+test_expect_success 'node 0 invites node 1' '
+  ipfsi 0 group invite friends $PEERID_1 &&
+  ipfsi 0 group ls friends > ls_out &&
+  grep "member $PEERID_1" ls_out
+'
+
+test_expect_success 'node 1 receives the invitation' '
+  for i in $(test_seq 1 50); do
+    ipfsi 1 group invitations | grep friends && break
+    go-sleep 100ms
+  done &&
+  ipfsi 1 group invitations > inv_out &&
+  echo "friends owner $PEERID_0 members 1" > inv_exp &&
+  test_cmp inv_exp inv_out
+'
+
+test_expect_success 'node 1 is not in the group until it joins' '
+  test_must_fail ipfsi 1 group ls friends
+'
+
+test_expect_success 'node 1 joins the group' '
+  ipfsi 1 group join friends &&
+  ipfsi 1 group ls friends > ls_out &&
+  grep "friends owner $PEERID_0" ls_out &&
+  ipfsi 1 group invitations > inv_out &&
+  test_must_be_empty inv_out
+'
+
+test_expect_success 'node 0 sees that node 1 joined' '
+  for i in $(test_seq 1 50); do
+    ipfsi 0 group ls friends | grep "member $PEERID_1 joined" && break
+    go-sleep 100ms
+  done &&
+  ipfsi 0 group ls friends | grep "member $PEERID_1 joined"
+'
+
+test_expect_success 'node 0 publishes a file' '
+  random 100000 5 > notes &&
+  ipfsi 0 group publish friends notes notes > publish_out &&
+  MANIFEST=$(sed -n "s/^published notes //p" publish_out) &&
+  test -n "$MANIFEST"
+'
+
+test_expect_success 'node 1 resolves and reads the file' '
+  for i in $(test_seq 1 50); do
+    ipfsi 1 group resolve friends notes && break
+    go-sleep 100ms
+  done &&
+  ipfsi 1 group resolve friends notes > resolve_out &&
+  echo "$MANIFEST" > resolve_exp &&
+  test_cmp resolve_exp resolve_out &&
+  ipfsi 1 group cat friends notes > notes_out &&
+  test_cmp notes notes_out
+'
+
+test_expect_success 'node 1 publishes a file back' '
+  echo "reply" > reply &&
+  ipfsi 1 group publish friends reply reply &&
+  for i in $(test_seq 1 50); do
+    ipfsi 0 group cat friends reply > reply_out && break
+    go-sleep 100ms
+  done &&
+  test_cmp reply reply_out
+'
+
+test_expect_success 'members do not replace the entries of others' '
+  echo "not a reply" > other &&
+  ipfsi 0 group publish friends reply other &&
+  for i in $(test_seq 1 50); do
+    test_must_fail ipfsi 1 group resolve friends reply 2>/dev/null && break
+    go-sleep 100ms
+  done &&
+  test_must_fail ipfsi 1 group resolve friends reply 2> resolve_err &&
+  grep "several members published reply" resolve_err &&
+  ipfsi 1 group cat friends $PEERID_1/reply > reply_out &&
+  test_cmp reply reply_out &&
+  ipfsi 1 group cat friends $PEERID_0/reply > other_out &&
+  test_cmp other other_out
+'
+
+test_expect_success 'node 2 declines an invitation' '
+  PEERID_2=$(iptb get id 2) &&
+  ipfsi 0 group invite friends $PEERID_2 &&
+  for i in $(test_seq 1 50); do
+    ipfsi 2 group invitations | grep friends && break
+    go-sleep 100ms
+  done &&
+  ipfsi 2 group decline friends $PEERID_0 &&
+  ipfsi 2 group invitations > inv_out &&
+  test_must_be_empty inv_out &&
+  test_must_fail ipfsi 2 group join friends
+'
+
+test_expect_success 'node 2 is not in the group' '
+  ipfsi 2 group ls > ls_out &&
+  test_must_be_empty ls_out &&
+  test_must_fail ipfsi 2 group resolve friends notes
+'
+
+test_expect_success 'node 2 can fetch the content but not read it' '
+  test_must_fail ipfsi 2 crypt cat $MANIFEST > /dev/null
+'
+
+test_expect_success 'only the owner invites' '
+  test_must_fail ipfsi 1 group invite friends $(iptb get id 2)
+'
+
+test_expect_success 'node 1 leaves the group' '
+  ipfsi 1 group leave friends &&
+  ipfsi 1 group ls > ls_out &&
+  test_must_be_empty ls_out
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
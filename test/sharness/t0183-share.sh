@@ -0,0 +1,72 @@
+#!/bin/sh
+
+test_description="Test ipfs share"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create a file" '
+	echo "share me" > afile
+'
+
+test_expect_success "ipfs share needs a daemon" '
+	test_must_fail ipfs share afile 2>share_err &&
+	grep "must be run in online mode" share_err
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "ipfs share succeeds" '
+	HASH=$(ipfs add -q --only-hash afile) &&
+	ipfs share afile >share_out
+'
+
+test_expect_success "ipfs share pins the file" '
+	ipfs pin ls --type=recursive | grep "$HASH"
+'
+
+test_expect_success "ipfs share prints the links and a QR code" '
+	grep "^shared $HASH\$" share_out &&
+	grep "^https://b[a-z2-7]*\.ipfs\.dweb\.link/\$" share_out &&
+	grep "^https://ipfs.io/ipfs/$HASH\$" share_out &&
+	grep "█" share_out
+'
+
+test_expect_success "ipfs share --qr=false uses the configured gateways" '
+	ipfs config Share.Gateway http://localhost:8080 &&
+	ipfs config Share.SubdomainGateway http://localhost:8080 &&
+	ipfs share --qr=false afile >share_out &&
+	grep "^http://b[a-z2-7]*\.ipfs\.localhost:8080/\$" share_out &&
+	grep "^http://localhost:8080/ipfs/$HASH\$" share_out &&
+	test_must_fail grep "█" share_out
+'
+
+test_expect_success "ipfs share -r shares a directory" '
+	mkdir adir &&
+	echo "in a dir" > adir/file &&
+	DIRHASH=$(ipfs add -q -r --only-hash adir | tail -n1) &&
+	ipfs share -r --qr=false adir >share_out &&
+	grep "^shared $DIRHASH\$" share_out
+'
+
+test_expect_success "ipfs share --remote needs a remote node" '
+	test_must_fail ipfs share --remote afile 2>share_err &&
+	grep "no remote pinning node set" share_err
+'
+
+test_expect_success "ipfs share --remote pins on the remote node" '
+	ipfs config Share.RemotePin "http://$API_ADDR" &&
+	ipfs share --remote --qr=false afile >share_out &&
+	grep "^pinned on http://$API_ADDR\$" share_out
+'
+
+test_expect_success "ipfs share --remote reports unreachable nodes" '
+	ipfs config Share.RemotePin "http://127.0.0.1:1" &&
+	test_must_fail ipfs share --remote afile 2>share_err &&
+	grep "pinning on http://127.0.0.1:1" share_err
+'
+
+test_kill_ipfs_daemon
+
+test_done
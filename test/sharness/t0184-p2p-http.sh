@@ -0,0 +1,103 @@
+#!/bin/sh
+
+test_description="Test ipfs p2p http"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "ipfs p2p needs the p2p-http feature" '
+	test_must_fail ipfs p2p http ls 2>ls_err &&
+	grep "the p2p-http feature is disabled" ls_err
+'
+
+test_expect_success "enable the p2p-http feature" '
+	ipfs features enable p2p-http
+'
+
+test_expect_success "the p2p http proxy is refused on a public address" '
+	ipfs config Addresses.P2PProxy /ip4/0.0.0.0/tcp/0 &&
+	test_must_fail ipfs daemon >daemon_out 2>daemon_err &&
+	grep "is not a loopback address" daemon_err
+'
+
+test_expect_success "serve the p2p http proxy on a loopback address" '
+	ipfs config Addresses.P2PProxy /ip4/127.0.0.1/tcp/0
+'
+
+test_expect_success "ipfs p2p http expose needs a daemon" '
+	test_must_fail ipfs p2p http expose http://127.0.0.1:5000 2>expose_err &&
+	grep "must be run in online mode" expose_err
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "the daemon serves the p2p http proxy" '
+	PROXY_MADDR=$(sed -n "s/^P2P proxy server listening on //p" actual_daemon) &&
+	PROXY_ADDR=$(convert_tcp_maddr $PROXY_MADDR)
+'
+
+# a second node exposes its API to the first one
+test_expect_success "init iptb" '
+	iptb init -n 1 --bootstrap=none --port=0 &&
+	ipfsi 0 features enable p2p-http &&
+	iptb start &&
+	SERVER_ID=$(iptb get id 0) &&
+	SERVER_API=$(cat "$IPTB_ROOT/0/api") &&
+	SERVER_ADDR=$(ipfsi 0 swarm addrs local | grep 127.0.0.1 | head -1) &&
+	ipfs swarm connect "$SERVER_ADDR/ipfs/$SERVER_ID"
+'
+
+test_expect_success "ipfs p2p http expose fails with an invalid target" '
+	test_must_fail ipfsi 0 p2p http expose ftp://127.0.0.1:21 2>expose_err &&
+	grep "must be an http URL or a multiaddr" expose_err
+'
+
+test_expect_success "ipfs p2p http expose succeeds" '
+	CLIENT_ID=$(ipfs id -f="<id>") &&
+	ipfsi 0 p2p http expose --allow="$CLIENT_ID" "$SERVER_API" >expose_out &&
+	echo "http://$(convert_tcp_maddr $SERVER_API)" >expected &&
+	echo "  allowed: $CLIENT_ID" >>expected &&
+	test_cmp expected expose_out
+'
+
+test_expect_success "ipfs p2p http ls lists the service" '
+	ipfsi 0 p2p http ls >ls_out &&
+	test_cmp expected ls_out
+'
+
+test_expect_success "the proxy forwards requests to the service" '
+	curl -sf "http://$PROXY_ADDR/p2p/$SERVER_ID/http/api/v0/id" >curl_out &&
+	grep "\"ID\": *\"$SERVER_ID\"" curl_out
+'
+
+test_expect_success "the gateway doesn't forward requests" '
+	curl -s -o /dev/null -w "%{http_code}" "http://$GWAY_ADDR/p2p/$SERVER_ID/http/api/v0/id" >curl_out &&
+	echo 404 >expected_code &&
+	test_cmp expected_code curl_out
+'
+
+test_expect_success "ipfs p2p http close succeeds" '
+	ipfsi 0 p2p http close &&
+	ipfsi 0 p2p http ls >ls_out &&
+	test_must_be_empty ls_out
+'
+
+test_expect_success "the proxy fails once the service is closed" '
+	curl -s -o /dev/null -w "%{http_code}" "http://$PROXY_ADDR/p2p/$SERVER_ID/http/api/v0/version" >curl_out &&
+	echo 502 >expected_code &&
+	test_cmp expected_code curl_out
+'
+
+test_expect_success "ipfs p2p http close fails when nothing is exposed" '
+	test_must_fail ipfsi 0 p2p http close 2>close_err &&
+	grep "no http service is exposed" close_err
+'
+
+test_expect_success "stop iptb" '
+	iptb stop
+'
+
+test_kill_ipfs_daemon
+
+test_done
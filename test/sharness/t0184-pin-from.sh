@@ -0,0 +1,73 @@
+#!/bin/sh
+
+test_description="Test pinning from given peers"
+
+. lib/test-lib.sh
+
+NUM_NODES=3
+test_expect_success 'init iptb' '
+  iptb init -n $NUM_NODES --bootstrap=none --port=0
+'
+
+startup_cluster $NUM_NODES
+
+test_expect_success 'peer ids and addresses' '
+  PEERID_0=$(iptb get id 0) &&
+  PEERID_1=$(iptb get id 1) &&
+  ADDR_1=$(ipfsi 1 swarm addrs local --id | grep 127.0.0.1 | head -1) &&
+  test -n "$ADDR_1"
+'
+
+test_expect_success 'node 0 adds some content' '
+  random 500000 11 > afile &&
+  HASH=$(ipfsi 0 add -q afile) &&
+  random 500000 12 > bfile &&
+  HASH_B=$(ipfsi 0 add -q bfile) &&
+  random 500000 13 > cfile &&
+  HASH_C=$(ipfsi 0 add -q cfile)
+'
+
+test_expect_success 'node 2 pins from node 0' '
+  ipfsi 2 pin add --from=$PEERID_0 $HASH > pin_out &&
+  echo "pinned $HASH recursively" > pin_exp &&
+  test_cmp pin_exp pin_out &&
+  ipfsi 2 cat $HASH > afile_out &&
+  test_cmp afile afile_out
+'
+
+test_expect_success 'node 2 does not fetch from other peers with --only-from' '
+  test_must_fail ipfsi 2 pin add --from=$ADDR_1 --only-from --timeout=3s $HASH_B &&
+  test_must_fail ipfsi 2 pin add --local-only $HASH_B
+'
+
+test_expect_success 'node 2 stops pinning with --only-from once the peers are gone' '
+  (ipfsi 2 pin add --from=$ADDR_1 --only-from $HASH_C 2> pin_err; echo $? > pin_status) &
+  PIN_PID=$! &&
+  go-sleep 1s &&
+  ipfsi 2 swarm disconnect $ADDR_1 &&
+  wait $PIN_PID &&
+  test $(cat pin_status) -ne 0 &&
+  grep "the --from peers disconnected" pin_err
+'
+
+test_expect_success 'node 2 falls back to the other peers without --only-from' '
+  ipfsi 2 pin add --from=$ADDR_1 $HASH_B > pin_out &&
+  echo "pinned $HASH_B recursively" > pin_exp &&
+  test_cmp pin_exp pin_out
+'
+
+test_expect_success '--only-from needs --from' '
+  test_must_fail ipfsi 2 pin add --only-from $HASH 2> pin_err &&
+  grep "needs the peers given with --from" pin_err
+'
+
+test_expect_success '--from rejects invalid peers' '
+  test_must_fail ipfsi 2 pin add --from=notapeer $HASH 2> pin_err &&
+  grep "invalid peer ID notapeer" pin_err
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
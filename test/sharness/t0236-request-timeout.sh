@@ -0,0 +1,37 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="test the global timeout option"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+test_launch_ipfs_daemon
+
+test_expect_success "make up a hash nobody has" '
+	MISSING=$(echo "nobody has this" | ipfs add -q --only-hash)
+'
+
+test_expect_success "cat of a missing object times out" '
+	test_must_fail ipfs cat --timeout=1s $MISSING 2> timeout_err
+'
+
+test_expect_success "the error names the timeout" '
+	grep "command timed out after 1s" timeout_err
+'
+
+test_expect_success "streaming commands time out too" '
+	test_must_fail ipfs refs -r --timeout=1s $MISSING 2> refs_err &&
+	grep "command timed out after 1s" refs_err
+'
+
+test_expect_success "api reports timeouts with 408" '
+	curl -s -o /dev/null -w "%{http_code}" "http://$API_ADDR/api/v0/cat?arg=$MISSING&timeout=1s" > status &&
+	echo 408 > status_exp &&
+	test_cmp status_exp status
+'
+
+test_kill_ipfs_daemon
+test_done
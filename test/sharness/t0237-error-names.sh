@@ -0,0 +1,61 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="test the stable names and exit codes of errors"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add an object which is not pinned" '
+	UNPINNED=$(echo "not pinned" | ipfs add -q --pin=false)
+'
+
+test_expect_success "errors without a name exit with 1" '
+	test_expect_code 1 ipfs config Nothing.Here
+'
+
+test_expect_success "invalid paths exit with 10" '
+	test_expect_code 10 ipfs pin add /notaprotocol/foo
+'
+
+test_expect_success "pins not found exit with 13" '
+	test_expect_code 13 ipfs pin rm "$UNPINNED" 2>pin_err &&
+	grep "not pinned" pin_err
+'
+
+test_expect_success "commands needing the daemon exit with 30" '
+	test_expect_code 30 ipfs pin events
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "timeouts exit with 20" '
+	MISSING=$(echo "nobody has this" | ipfs add -q --only-hash) &&
+	test_expect_code 20 ipfs cat --timeout=1s "$MISSING"
+'
+
+test_expect_success "pins not found exit with 13 through the daemon" '
+	test_expect_code 13 ipfs pin rm "$UNPINNED"
+'
+
+test_expect_success "the api gives the name of the error" '
+	curl -s "http://$API_ADDR/api/v0/pin/rm?arg=$UNPINNED" >api_err &&
+	grep "\"Name\":\"ErrPinNotFound\"" api_err
+'
+
+test_expect_success "errors without a name have none in the api" '
+	curl -s "http://$API_ADDR/api/v0/config?arg=Nothing.Here" >api_err &&
+	grep "\"Message\"" api_err &&
+	test_must_fail grep "\"Name\"" api_err
+'
+
+test_expect_success "commands refused while the daemon runs exit with 31" '
+	test_expect_code 31 ipfs repo fsck
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -66,7 +66,7 @@ verify_cannot_resolve() {
 		test_expect_success "$msg: resolution fails on node $node" '
 			# TODO: this should work without the timeout option
 			# but it currently hangs for some reason every so often
-			test_expect_code 1 ipfsi "$node" name resolve --timeout=300ms "$name"
+			test_must_fail ipfsi "$node" name resolve --timeout=300ms "$name"
 		'
 	done
 }
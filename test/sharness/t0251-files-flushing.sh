@@ -48,6 +48,26 @@ test_expect_success "file is still there" '
 	verify_path_exists /file
 '
 
+test_expect_success "can write files without flushing" '
+	ipfs files mkdir /dir &&
+	ipfs files stat --hash / > root_before &&
+	echo "a" | ipfs files write --flush=false --create /dir/a &&
+	echo "b" | ipfs files write --flush=false --create /dir/b
+'
+
+test_expect_success "flush prints the flushed hash" '
+	ipfs files flush /dir > flush_out &&
+	ipfs files stat --hash /dir > dir_hash &&
+	test_cmp dir_hash flush_out
+'
+
+test_expect_success "flushing the root picks up the changes" '
+	ipfs files flush / > root_after &&
+	test_must_fail test_cmp root_before root_after
+'
+
+verify_dir_contents /dir a b
+
 test_kill_ipfs_daemon
 
 test_done
@@ -0,0 +1,47 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test MIME type detection of unixfs files"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+test_launch_ipfs_daemon
+
+test_expect_success "add files without extensions" '
+	mkdir mimedir &&
+	echo "<!DOCTYPE html><html><body>hello</body></html>" > mimedir/page &&
+	printf "\211PNG\r\n\032\n not really an image" > mimedir/image &&
+	echo "plain words" > mimedir/notes &&
+	DIR=$(ipfs add -r -q mimedir | tail -n1) &&
+	PAGE=$(ipfs add -q mimedir/page)
+'
+
+test_expect_success "files stat --mime sniffs the content" '
+	ipfs files cp /ipfs/$DIR /mimedir &&
+	ipfs files stat --mime --format="<mime>" /mimedir/page > out &&
+	echo "text/html; charset=utf-8" > expected &&
+	test_cmp expected out
+'
+
+test_expect_success "files stat --mime shows in the default format" '
+	ipfs files stat --mime /mimedir/image > out &&
+	grep "MimeType: image/png" out
+'
+
+test_expect_success "ls --long shows the types" '
+	ipfs ls --long $DIR > out &&
+	grep "text/html; charset=utf-8 *page" out &&
+	grep "image/png *image" out &&
+	grep "text/plain; charset=utf-8 *notes" out
+'
+
+test_expect_success "gateway serves bare cids with the sniffed type" '
+	curl -sI "http://127.0.0.1:$GWAY_PORT/ipfs/$PAGE" > headers &&
+	grep -i "Content-Type: text/html" headers
+'
+
+test_kill_ipfs_daemon
+test_done
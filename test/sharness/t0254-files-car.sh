@@ -0,0 +1,75 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs files export and import"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_files_car() {
+	test_expect_success "create a tree in mfs" '
+		ipfs files mkdir -p /car/sub &&
+		echo "top file" | ipfs files write --create /car/a &&
+		random 500000 41 | ipfs files write --create /car/sub/b &&
+		ipfs files stat --hash /car >tree_hash
+	'
+
+	test_expect_success "'ipfs files export' writes a CAR file" '
+		ipfs files export /car tree.car &&
+		test -s tree.car
+	'
+
+	test_expect_success "'ipfs files export' writes to stdout without a file" '
+		ipfs files export /car >tree_stdout.car &&
+		test_cmp tree.car tree_stdout.car
+	'
+
+	test_expect_success "remove the tree and its blocks" '
+		ipfs files rm -r /car &&
+		ipfs repo gc >/dev/null &&
+		ipfs refs local >refs &&
+		test_must_fail grep "$(cat tree_hash)" refs
+	'
+
+	test_expect_success "'ipfs files import' restores the tree" '
+		ipfs files import tree.car /restored >import_out &&
+		grep "^imported $(cat tree_hash) (" import_out &&
+		ipfs files stat --hash /restored >restored_hash &&
+		test_cmp tree_hash restored_hash &&
+		echo "top file" >expected &&
+		ipfs files read /restored/a >actual &&
+		test_cmp expected actual
+	'
+
+	test_expect_success "'ipfs files import' reads stdin with -" '
+		ipfs files import - /restored2 <tree.car &&
+		ipfs files stat --hash /restored2 >restored_hash &&
+		test_cmp tree_hash restored_hash
+	'
+
+	test_expect_success "'ipfs files import' rejects a corrupt archive" '
+		cp tree.car corrupt.car &&
+		SIZE=$(wc -c <corrupt.car) &&
+		printf "x" | dd of=corrupt.car bs=1 seek=$((SIZE - 1)) conv=notrunc 2>/dev/null &&
+		test_must_fail ipfs files import corrupt.car /corrupt 2>err &&
+		grep "doesn.t match its hash" err
+	'
+
+	test_expect_success "clean up" '
+		ipfs files rm -r /restored &&
+		ipfs files rm -r /restored2
+	'
+}
+
+test_files_car
+
+test_launch_ipfs_daemon
+
+test_files_car
+
+test_kill_ipfs_daemon
+
+test_done
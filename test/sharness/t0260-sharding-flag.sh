@@ -55,6 +55,19 @@ test_expect_success "sharded and unsharded output look the same" '
 	test_cmp sharded_out unsharded_out
 '
 
+test_expect_success "sorted pages of sharded and unsharded dirs look the same" '
+	ipfs ls --sorted --offset=100 --count=20 "$SHARDED" > sharded_out &&
+	ipfs ls --sorted --offset=100 --count=20 "$UNSHARDED" > unsharded_out &&
+	test_cmp sharded_out unsharded_out &&
+	test $(wc -l < sharded_out) = 20
+'
+
+test_expect_success "unsorted pages of sharded dirs are in the order of the shards" '
+	ipfs ls "$SHARDED" | sed -n "11,15p" > sharded_all &&
+	ipfs ls --offset=10 --count=5 "$SHARDED" > sharded_page &&
+	test_cmp sharded_all sharded_page
+'
+
 test_expect_success "ipfs cat error output the same" '
 	test_expect_code 1 ipfs cat "$SHARDED" 2> sharded_err &&
 	test_expect_code 1 ipfs cat "$UNSHARDED" 2> unsharded_err &&
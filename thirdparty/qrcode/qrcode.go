@@ -0,0 +1,496 @@
+// Package qrcode encodes short texts, like URLs, in QR codes and renders
+// them for terminals. It supports byte mode, error correction level L and
+// versions 1 to 10, holding up to 271 bytes.
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrTooLong is returned for data that doesn't fit in the largest supported
+// version.
+var ErrTooLong = errors.New("data too long for a QR code")
+
+// Code is a QR code, a square of dark and light modules.
+type Code struct {
+	Version int
+	Size    int
+
+	modules [][]bool // [y][x], true for dark
+}
+
+// Dark returns whether the module at column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// error correction level L, by version - 1
+var (
+	totalCodewords = []int{26, 44, 70, 100, 134, 172, 196, 242, 292, 346}
+	eccPerBlock    = []int{7, 10, 15, 20, 26, 18, 20, 24, 30, 18}
+	numBlocks      = []int{1, 1, 1, 1, 1, 2, 2, 2, 2, 4}
+	remainderBits  = []int{0, 7, 7, 7, 7, 7, 0, 0, 0, 0}
+
+	alignmentPositions = [][]int{
+		nil,
+		{6, 18},
+		{6, 22},
+		{6, 26},
+		{6, 30},
+		{6, 34},
+		{6, 22, 38},
+		{6, 24, 42},
+		{6, 26, 46},
+		{6, 28, 50},
+	}
+)
+
+const maxVersion = 10
+
+// formatBitsL are the error correction level bits of level L in the format
+// information.
+const formatBitsL = 1
+
+// Encode returns the smallest QR code holding data.
+func Encode(data []byte) (*Code, error) {
+	version := 0
+	for v := 1; v <= maxVersion; v++ {
+		if len(data) <= dataCapacity(v) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	q := newQR(version)
+	q.drawFunctionPatterns()
+	q.drawCodewords(addErrorCorrection(version, encodeData(version, data)))
+
+	// keep the mask with the lowest penalty
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		q.applyMask(mask) // masks are their own inverse
+	}
+	q.applyMask(best)
+	q.drawFormatBits(best)
+
+	return &Code{Version: version, Size: q.size, modules: q.modules}, nil
+}
+
+// dataCapacity returns how many bytes a version holds in byte mode.
+func dataCapacity(version int) int {
+	bits := dataCodewords(version)*8 - 4 - charCountBits(version)
+	return bits / 8
+}
+
+func dataCodewords(version int) int {
+	return totalCodewords[version-1] - eccPerBlock[version-1]*numBlocks[version-1]
+}
+
+func charCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+type bitBuffer struct {
+	bytes []byte
+	n     int
+}
+
+func (b *bitBuffer) append(val, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if b.n%8 == 0 {
+			b.bytes = append(b.bytes, 0)
+		}
+		if (val>>uint(i))&1 != 0 {
+			b.bytes[b.n/8] |= 0x80 >> uint(b.n%8)
+		}
+		b.n++
+	}
+}
+
+// encodeData returns the data codewords: mode, length, data and padding.
+func encodeData(version int, data []byte) []byte {
+	capacity := dataCodewords(version) * 8
+
+	var b bitBuffer
+	b.append(0x4, 4) // byte mode
+	b.append(len(data), charCountBits(version))
+	for _, d := range data {
+		b.append(int(d), 8)
+	}
+
+	term := capacity - b.n
+	if term > 4 {
+		term = 4
+	}
+	b.append(0, term)
+	if b.n%8 != 0 {
+		b.append(0, 8-b.n%8)
+	}
+
+	for pad := 0xEC; b.n < capacity; pad ^= 0xEC ^ 0x11 {
+		b.append(pad, 8)
+	}
+	return b.bytes
+}
+
+// addErrorCorrection splits the data in blocks, and returns the interleaved
+// data and error correction codewords of the blocks.
+func addErrorCorrection(version int, data []byte) []byte {
+	nblocks := numBlocks[version-1]
+	ecLen := eccPerBlock[version-1]
+	total := totalCodewords[version-1]
+
+	shortBlocks := nblocks - total%nblocks
+	shortLen := total/nblocks - ecLen
+
+	divisor := rsDivisor(ecLen)
+	var blocks, eccs [][]byte
+	for i, off := 0, 0; i < nblocks; i++ {
+		n := shortLen
+		if i >= shortBlocks {
+			n++
+		}
+		blocks = append(blocks, data[off:off+n])
+		eccs = append(eccs, rsRemainder(data[off:off+n], divisor))
+		off += n
+	}
+
+	out := make([]byte, 0, total)
+	for i := 0; i <= shortLen; i++ {
+		for _, blk := range blocks {
+			if i < len(blk) {
+				out = append(out, blk[i])
+			}
+		}
+	}
+	for i := 0; i < ecLen; i++ {
+		for _, ecc := range eccs {
+			out = append(out, ecc[i])
+		}
+	}
+	return out
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of the given
+// degree, without its leading term.
+func rsDivisor(degree int) []byte {
+	res := make([]byte, degree)
+	res[degree-1] = 1
+
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range res {
+			res[j] = gfMul(res[j], root)
+			if j+1 < len(res) {
+				res[j] ^= res[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return res
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	res := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ res[0]
+		copy(res, res[1:])
+		res[len(res)-1] = 0
+		for i, d := range divisor {
+			res[i] ^= gfMul(d, factor)
+		}
+	}
+	return res
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+type qr struct {
+	version    int
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newQR(version int) *qr {
+	size := 17 + 4*version
+	q := &qr{version: version, size: size}
+	q.modules = make([][]bool, size)
+	q.isFunction = make([][]bool, size)
+	for y := range q.modules {
+		q.modules[y] = make([]bool, size)
+		q.isFunction[y] = make([]bool, size)
+	}
+	return q
+}
+
+func (q *qr) setFunction(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.isFunction[y][x] = true
+}
+
+func (q *qr) drawFunctionPatterns() {
+	for i := 0; i < q.size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+
+	q.drawFinder(3, 3)
+	q.drawFinder(q.size-4, 3)
+	q.drawFinder(3, q.size-4)
+
+	pos := alignmentPositions[q.version-1]
+	last := len(pos) - 1
+	for i, x := range pos {
+		for j, y := range pos {
+			// skip the corners taken by the finders
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			q.drawAlignment(x, y)
+		}
+	}
+
+	// reserve the format areas, drawn once the mask is known
+	q.drawFormatBits(0)
+	q.drawVersion()
+}
+
+// drawFinder draws a finder pattern centered at x, y, with its separator.
+func (q *qr) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= q.size || yy < 0 || yy >= q.size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			q.setFunction(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+func (q *qr) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			q.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// formatBits returns the BCH coded format information for level L and the
+// mask.
+func formatBits(mask int) int {
+	data := formatBitsL<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (q *qr) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>uint(i))&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, bit(i))
+	}
+	q.setFunction(8, 7, bit(6))
+	q.setFunction(8, 8, bit(7))
+	q.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.size-15+i, bit(i))
+	}
+	q.setFunction(8, q.size-8, true)
+}
+
+// versionBits returns the BCH coded version information.
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+func (q *qr) drawVersion() {
+	if q.version < 7 {
+		return
+	}
+
+	bits := versionBits(q.version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 != 0
+		a, b := q.size-11+i%3, i/3
+		q.setFunction(a, b, dark)
+		q.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords places the codewords in zigzag, two columns at a time from
+// the bottom right corner, around the function patterns.
+func (q *qr) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if q.isFunction[y][x] || i >= len(data)*8 {
+					continue
+				}
+				q.modules[y][x] = (data[i/8]>>uint(7-i%8))&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func (q *qr) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.isFunction[y][x] {
+				continue
+			}
+
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to read, following the four rules of
+// the specification: runs, 2x2 blocks, finder-like patterns and balance.
+func (q *qr) penalty() int {
+	score := 0
+	dark := 0
+
+	line := func(get func(i int) bool) {
+		run := 1
+		for i := 1; i < q.size; i++ {
+			if get(i) == get(i-1) {
+				run++
+				continue
+			}
+			if run >= 5 {
+				score += run - 2
+			}
+			run = 1
+		}
+		if run >= 5 {
+			score += run - 2
+		}
+
+		// dark-light-dark-dark-dark-light-dark with four light modules on
+		// either side
+		for i := 0; i+11 <= q.size; i++ {
+			var w bytes.Buffer
+			for j := i; j < i+11; j++ {
+				if get(j) {
+					w.WriteByte('1')
+				} else {
+					w.WriteByte('0')
+				}
+			}
+			if s := w.String(); s == "10111010000" || s == "00001011101" {
+				score += 40
+			}
+		}
+	}
+
+	for y := 0; y < q.size; y++ {
+		line(func(x int) bool { return q.modules[y][x] })
+	}
+	for x := 0; x < q.size; x++ {
+		line(func(y int) bool { return q.modules[y][x] })
+	}
+
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			c := q.modules[y][x]
+			if c {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size &&
+				c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+				score += 3
+			}
+		}
+	}
+
+	// 10 points per 5% away from an even balance
+	total := q.size * q.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	if k > 0 {
+		score += k * 10
+	}
+	return score
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
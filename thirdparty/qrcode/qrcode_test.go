@@ -0,0 +1,197 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestErrorCorrection(t *testing.T) {
+	// example of the specification, a 1-M code
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	exp := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	ecc := rsRemainder(data, rsDivisor(len(exp)))
+	if !bytes.Equal(ecc, exp) {
+		t.Fatalf("expected %v, got %v", exp, ecc)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	if b := formatBits(0); b != 0x77C4 {
+		t.Fatalf("expected format bits 0x77C4, got %#x", b)
+	}
+	if b := formatBits(7); b != 0x6976 {
+		t.Fatalf("expected format bits 0x6976, got %#x", b)
+	}
+	if b := versionBits(7); b != 0x07C94 {
+		t.Fatalf("expected version bits 0x07C94, got %#x", b)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, n := range []int{0, 17, 18, 100, 271} {
+		data := bytes.Repeat([]byte{'a'}, n)
+		c, err := Encode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Size != 17+4*c.Version {
+			t.Fatalf("version %d has size %d", c.Version, c.Size)
+		}
+		if dataCapacity(c.Version) < n || (c.Version > 1 && dataCapacity(c.Version-1) >= n) {
+			t.Fatalf("%d bytes encoded in version %d", n, c.Version)
+		}
+
+		// finder patterns in three corners
+		for _, corner := range [][2]int{{0, 0}, {c.Size - 7, 0}, {0, c.Size - 7}} {
+			x, y := corner[0], corner[1]
+			if !c.Dark(x, y) || c.Dark(x+1, y+1) || !c.Dark(x+3, y+3) {
+				t.Fatalf("no finder pattern at %d,%d", x, y)
+			}
+		}
+		// timing patterns
+		for i := 8; i < c.Size-8; i++ {
+			if c.Dark(i, 6) != (i%2 == 0) || c.Dark(6, i) != (i%2 == 0) {
+				t.Fatalf("broken timing pattern at %d", i)
+			}
+		}
+	}
+
+	if _, err := Encode(make([]byte, 272)); err != ErrTooLong {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+}
+
+// The golden codes were made by another encoder, github.com/boombuler/barcode
+// at level L in byte mode, which picks the same masks.
+var goldenCodes = []struct {
+	data    string
+	version int
+	modules []string
+}{
+	{
+		// a single block
+		data:    "hello ipfs",
+		version: 1,
+		modules: []string{
+			"#######...#.#.#######",
+			"#.....#.#.#.#.#.....#",
+			"#.###.#.#.##..#.###.#",
+			"#.###.#.....#.#.###.#",
+			"#.###.#.#####.#.###.#",
+			"#.....#.###...#.....#",
+			"#######.#.#.#.#######",
+			"........#............",
+			"##.#..##..###.###.##.",
+			"##...#..####.#..#..##",
+			"#.#.#.#..#.#.###.##.#",
+			"###..#.#.##.#.#..#.##",
+			"##.#####..##.........",
+			"........#....#.#..#.#",
+			"#######.#.###.######.",
+			"#.....#........#...##",
+			"#.###.#..##.#......#.",
+			"#.###.#.##...########",
+			"#.###.#..#.#.#..#.#.#",
+			"#.....#.##.##........",
+			"#######.##.....#.#.#.",
+		},
+	},
+	{
+		// two interleaved blocks, with version information
+		data:    "https://ipfs.io/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn/wiki/Anasayfa.html?filename=the-interplanetary-file-system-is-a-peer-to-peer-hypermedia-protocol",
+		version: 8,
+		modules: []string{
+			"#######..###..#..#.#...#.#.#.##..#.#.#..#.#######",
+			"#.....#.##.#######.#.#..#.#......##.#####.#.....#",
+			"#.###.#..##...#......#.....##..#.###...##.#.###.#",
+			"#.###.#.##.####..#.#..###.#...##.#####.#..#.###.#",
+			"#.###.#..##.#..#..#..#######..#..#.#......#.###.#",
+			"#.....#.#.....#...##..#...####.##.#.###...#.....#",
+			"#######.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#######",
+			".........##..#.#.#....#...#######.##.##.#........",
+			"#####.###.####.####.#######..###...##..###.#.#.#.",
+			".....#..##..#.#....#..##.#...###...###...##.##...",
+			"..#...#...###...#.#.####.##....####...#....###.##",
+			".###.#.##.##.#..#####..#..##.##..#..#..###..#..##",
+			"#.#.#.###...#..#..#..##.##...###...###.##.....##.",
+			"##..#...#..###..#####.####.#####...#.#.#.#######.",
+			"......##...######.#.#.#.#.#..##...#.#.#.#......##",
+			".##.##..#...#...#####......####.##...#.#.##.#...#",
+			"##.##.##.#...#..###.#######..##....###.#####.####",
+			".#.....#.##.#.##.#.#..#.##...#####.##....###.#...",
+			".#.#.##.....####.#.#.####.#.##.#####..####....#.#",
+			".#..#..##..#..#.#....#..#..##.###...###....###.##",
+			".#.######.#.###..#.#.#####.....#.#####..####..###",
+			".#.##..#.#..#..#..#...#.......##...###...##.#.##.",
+			".#########.#.#.....#.#######.#...#..##.##########",
+			"##..#...##.#.#.#.##..##...###.#.#.#..##.#...#...#",
+			"##..#.#.#..###.####..##.#.#....#....##..#.#.#.###",
+			".#.##...#..##.#....#.##...###.#....###.##...###..",
+			"..#.#####.###.....##########...#..##.############",
+			"....##..#.##.#..#####..##.#.#.###.#.##..#..#.#..#",
+			"#...###.#..##..#..#...##.#...###.#.###.####.###..",
+			"..#......###.#..#########.#..##.....##.##.##...#.",
+			"..#.#.####.#.######.#.##...#.##.#.#.#....##..#.##",
+			"..####..###.#.#.#..##.##.##.##.###......#..##...#",
+			".#.#.##....###.####.#.##.##..###.#.#####.#..###..",
+			"###.##.##.....#........###...###...###.#.#.#..#..",
+			"..##.###.....###.#.#.##.##.....#########..#...###",
+			".###.......#..#.....##...##.#..#.######......#..#",
+			".####.#########..#.#..#..#....##.#.##.###.#.#####",
+			"..####.####.#..#..#..####.#...###..#.#.#.###.....",
+			".#...###...##.....##..#......#..#...#...###....##",
+			".###........##.#.##..#..#####...#.....#.##......#",
+			"###...##...#.#..#.#.#######...#....###.######.###",
+			"........#...#.#....#.##...##.##.#...##..#...#.##.",
+			"#######.#.###..#..###.#.#.##.#...########.#.#.###",
+			"#.....#...####.####.###...#.#.#.##.#.####...#..##",
+			"#.###.#.###.#..#..#..#######.#.#...##...########.",
+			"#.###.#.#..#.#..#####......#..#.#...##..##.###...",
+			"#.###.#.#....#####..#####....#.#...#.....#.#.##..",
+			"#.....#.#.##.#..#..##..#.#..#..##..#...#####....#",
+			"#######.#....#.####.#..####..###...#####....#.###",
+		},
+	},
+}
+
+func TestEncodeGolden(t *testing.T) {
+	for _, g := range goldenCodes {
+		c, err := Encode([]byte(g.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Version != g.version || c.Size != len(g.modules) {
+			t.Fatalf("expected version %d, got %d", g.version, c.Version)
+		}
+		for y, row := range g.modules {
+			for x := range row {
+				if c.Dark(x, y) != (row[x] == '#') {
+					t.Fatalf("version %d: module %d,%d differs from the golden code", g.version, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	c, err := Encode([]byte("https://ipfs.io/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(c.Terminal(), "\n"), "\n")
+	width := c.Size + 2*quietZone
+	if len(lines) != (width+1)/2 {
+		t.Fatalf("expected %d lines, got %d", (width+1)/2, len(lines))
+	}
+	for _, l := range lines {
+		if n := len([]rune(l)); n != width {
+			t.Fatalf("expected lines of %d characters, got %d", width, n)
+		}
+	}
+	if lines[0] != strings.Repeat("█", width) {
+		t.Fatal("expected a quiet zone above the code")
+	}
+}
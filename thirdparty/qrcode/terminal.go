@@ -0,0 +1,41 @@
+package qrcode
+
+import "bytes"
+
+// quietZone is the width of the light border around the code.
+const quietZone = 4
+
+// Terminal renders the code with block characters, two rows of modules per
+// line. Light modules are drawn and dark ones left blank, for terminals with
+// a dark background.
+func (c *Code) Terminal() string {
+	light := func(x, y int) bool {
+		x -= quietZone
+		y -= quietZone
+		if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+			return true
+		}
+		return !c.modules[y][x]
+	}
+
+	width := c.Size + 2*quietZone
+	var buf bytes.Buffer
+	for y := 0; y < width; y += 2 {
+		for x := 0; x < width; x++ {
+			top := light(x, y)
+			bottom := y+1 < width && light(x, y+1)
+			switch {
+			case top && bottom:
+				buf.WriteString("█")
+			case top:
+				buf.WriteString("▀")
+			case bottom:
+				buf.WriteString("▄")
+			default:
+				buf.WriteString(" ")
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
@@ -2,20 +2,40 @@ package tar
 
 import (
 	"archive/tar"
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	gopath "path"
 	fp "path/filepath"
 	"strings"
+	"sync"
 )
 
+// ExtractBufferSize is the size up to which files are read in memory, to be
+// written in the background while the next ones are read.
+var ExtractBufferSize int64 = 1 << 20
+
 type Extractor struct {
 	Path     string
 	Progress func(int64) int64
+
+	// Workers is the number of files written at the same time. With 1 or
+	// less, the files are written as they are read.
+	Workers int
 }
 
 func (te *Extractor) Extract(reader io.Reader) error {
+	writers := newFileWriters(te.Workers)
+	err := te.extract(reader, writers)
+	if werr := writers.close(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+func (te *Extractor) extract(reader io.Reader, writers *fileWriters) error {
 	tarReader := tar.NewReader(reader)
 
 	// Check if the output path already exists, so we know whether we should
@@ -47,7 +67,7 @@ func (te *Extractor) Extract(reader io.Reader) error {
 				return err
 			}
 		case tar.TypeReg:
-			if err := te.extractFile(header, tarReader, i, rootExists, rootIsDir); err != nil {
+			if err := te.extractFile(header, tarReader, i, rootExists, rootIsDir, writers); err != nil {
 				return err
 			}
 		case tar.TypeSymlink:
@@ -91,7 +111,7 @@ func (te *Extractor) extractSymlink(h *tar.Header) error {
 	return os.Symlink(h.Linkname, te.outputPath(h.Name))
 }
 
-func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, rootExists bool, rootIsDir bool) error {
+func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, rootExists bool, rootIsDir bool, writers *fileWriters) error {
 	path := te.outputPath(h.Name)
 
 	if depth == 0 { // if depth is 0, this is the only file (we aren't 'ipfs get'ing a directory)
@@ -106,6 +126,14 @@ func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, rootEx
 		} // else if old file exists, just overwrite it.
 	}
 
+	if writers != nil && h.Size <= ExtractBufferSize {
+		buf := bytes.NewBuffer(make([]byte, 0, h.Size))
+		if err := copyWithProgress(buf, r, te.Progress); err != nil {
+			return err
+		}
+		return writers.write(path, buf.Bytes())
+	}
+
 	file, err := os.Create(path)
 	if err != nil {
 		return err
@@ -139,3 +167,70 @@ func copyWithProgress(to io.Writer, from io.Reader, cb func(int64) int64) error
 	}
 
 }
+
+// fileWriters writes files in the background.
+type fileWriters struct {
+	files chan bufferedFile
+	wg    sync.WaitGroup
+
+	lk  sync.Mutex
+	err error
+}
+
+type bufferedFile struct {
+	path string
+	data []byte
+}
+
+// newFileWriters starts n writers, or returns nil if n is 1 or less.
+func newFileWriters(n int) *fileWriters {
+	if n <= 1 {
+		return nil
+	}
+
+	fw := &fileWriters{files: make(chan bufferedFile)}
+	fw.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go fw.work()
+	}
+	return fw
+}
+
+func (fw *fileWriters) work() {
+	defer fw.wg.Done()
+	for f := range fw.files {
+		if err := ioutil.WriteFile(f.path, f.data, 0666); err != nil {
+			fw.lk.Lock()
+			if fw.err == nil {
+				fw.err = err
+			}
+			fw.lk.Unlock()
+		}
+	}
+}
+
+func (fw *fileWriters) error() error {
+	fw.lk.Lock()
+	defer fw.lk.Unlock()
+	return fw.err
+}
+
+// write queues data to be written at path, and returns the first error of
+// the previous writes.
+func (fw *fileWriters) write(path string, data []byte) error {
+	if err := fw.error(); err != nil {
+		return err
+	}
+	fw.files <- bufferedFile{path: path, data: data}
+	return nil
+}
+
+// close waits for the queued files to be written.
+func (fw *fileWriters) close() error {
+	if fw == nil {
+		return nil
+	}
+	close(fw.files)
+	fw.wg.Wait()
+	return fw.error()
+}
@@ -30,8 +30,10 @@ func (i *identityWriteCloser) Close() error {
 	return nil
 }
 
-// DagArchive is equivalent to `ipfs getdag $hash | maybe_tar | maybe_gzip`
-func DagArchive(ctx context.Context, nd node.Node, name string, dag mdag.DAGService, archive bool, compression int) (io.Reader, error) {
+// DagArchive is equivalent to `ipfs getdag $hash | maybe_tar | maybe_gzip`.
+// For directories, the dags of fetchWorkers entries are fetched at the same
+// time.
+func DagArchive(ctx context.Context, nd node.Node, name string, dag mdag.DAGService, archive bool, compression int, fetchWorkers int) (io.Reader, error) {
 
 	_, filename := path.Split(name)
 
@@ -85,6 +87,7 @@ func DagArchive(ctx context.Context, nd node.Node, name string, dag mdag.DAGServ
 		if checkErrAndClosePipe(err) {
 			return nil, err
 		}
+		w.FetchWorkers = fetchWorkers
 
 		go func() {
 			// write all the nodes recursively
@@ -13,6 +13,7 @@ import (
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 	upb "github.com/ipfs/go-ipfs/unixfs/pb"
 
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
 	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
 )
@@ -24,7 +25,13 @@ type Writer struct {
 	Dag  mdag.DAGService
 	TarW *tar.Writer
 
-	ctx context.Context
+	// FetchWorkers is the number of entries of the written directory whose
+	// dags are fetched at the same time, ahead of their writing. With 1 or
+	// less, the nodes are only fetched as they are written.
+	FetchWorkers int
+
+	ctx         context.Context
+	prefetching bool
 }
 
 // NewWriter wraps given io.Writer.
@@ -41,6 +48,15 @@ func (w *Writer) writeDir(nd *mdag.ProtoNode, fpath string) error {
 		return err
 	}
 
+	// the whole dags of the entries of the top directory are prefetched,
+	// which covers the sub directories
+	if !w.prefetching && w.FetchWorkers > 1 {
+		w.prefetching = true
+		ctx, cancel := context.WithCancel(w.ctx)
+		defer cancel()
+		go w.prefetch(ctx, nd.Links())
+	}
+
 	for i, ng := range mdag.GetDAG(w.ctx, w.Dag, nd) {
 		child, err := ng.Get(w.ctx)
 		if err != nil {
@@ -56,6 +72,24 @@ func (w *Writer) writeDir(nd *mdag.ProtoNode, fpath string) error {
 	return nil
 }
 
+// prefetch fetches the dags of links, FetchWorkers at a time, so that they're
+// local when they are written. Failures are left to the writing to report.
+func (w *Writer) prefetch(ctx context.Context, links []*node.Link) {
+	sem := make(chan struct{}, w.FetchWorkers)
+	for _, l := range links {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		go func(c *cid.Cid) {
+			defer func() { <-sem }()
+			mdag.FetchGraph(ctx, c, w.Dag)
+		}(l.Cid)
+	}
+}
+
 func (w *Writer) writeFile(nd *mdag.ProtoNode, pb *upb.Data, fpath string) error {
 	if err := writeFileHeader(w.TarW, fpath, pb.GetFilesize()); err != nil {
 		return err
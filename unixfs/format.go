@@ -5,6 +5,7 @@ package unixfs
 
 import (
 	"errors"
+	"time"
 
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	pb "github.com/ipfs/go-ipfs/unixfs/pb"
@@ -137,6 +138,9 @@ type FSNode struct {
 
 	// node type of this node
 	Type pb.Data_DataType
+
+	// modification time of the file, zero if it isn't stored
+	ModTime time.Time
 }
 
 func FSNodeFromBytes(b []byte) (*FSNode, error) {
@@ -151,6 +155,9 @@ func FSNodeFromBytes(b []byte) (*FSNode, error) {
 	n.blocksizes = pbn.Blocksizes
 	n.subtotal = pbn.GetFilesize() - uint64(len(n.Data))
 	n.Type = pbn.GetType()
+	if mt := pbn.GetMtime(); mt != nil {
+		n.ModTime = time.Unix(mt.GetSeconds(), int64(mt.GetFractionalNanoseconds()))
+	}
 	return n, nil
 }
 
@@ -171,6 +178,12 @@ func (n *FSNode) GetBytes() ([]byte, error) {
 	pbn.Filesize = proto.Uint64(uint64(len(n.Data)) + n.subtotal)
 	pbn.Blocksizes = n.blocksizes
 	pbn.Data = n.Data
+	if !n.ModTime.IsZero() {
+		pbn.Mtime = &pb.UnixTime{Seconds: proto.Int64(n.ModTime.Unix())}
+		if ns := n.ModTime.Nanosecond(); ns != 0 {
+			pbn.Mtime.FractionalNanoseconds = proto.Uint32(uint32(ns))
+		}
+	}
 	return proto.Marshal(pbn)
 }
 
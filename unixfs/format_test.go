@@ -3,6 +3,7 @@ package unixfs
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
 
@@ -53,6 +54,34 @@ func TestFSNode(t *testing.T) {
 	}
 }
 
+func TestFSNodeModTime(t *testing.T) {
+	fsn := &FSNode{Type: TFile, Data: []byte("data")}
+	b, err := fsn.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nfsn, err := FSNodeFromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nfsn.ModTime.IsZero() {
+		t.Fatalf("expected no modification time, got %s", nfsn.ModTime)
+	}
+
+	fsn.ModTime = time.Unix(1500000000, 250)
+	b, err = fsn.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nfsn, err = FSNodeFromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nfsn.ModTime.Equal(fsn.ModTime) {
+		t.Fatalf("expected modification time %s, got %s", fsn.ModTime, nfsn.ModTime)
+	}
+}
+
 func TestPBdataTools(t *testing.T) {
 	raw := []byte{0x00, 0x01, 0x02, 0x17, 0xA1}
 	rawPB := WrapData(raw)
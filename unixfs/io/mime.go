@@ -0,0 +1,46 @@
+package io
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at.
+const sniffLen = 512
+
+// DetectContentType returns the MIME type of a file named name. A type known
+// from the extension of the name wins, otherwise it is sniffed from the first
+// bytes read from r. Names without extension, like bare CIDs, are common in
+// ipfs, so the sniffing is what most files get.
+func DetectContentType(name string, r io.Reader) (string, error) {
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		return ctype, nil
+	}
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// ContentType returns the MIME type of the unixfs file nd named name, see
+// DetectContentType. Only the start of the file is read, which is in its
+// first block unless the file was chunked very finely.
+func ContentType(ctx context.Context, name string, nd node.Node, serv mdag.DAGService) (string, error) {
+	dr, err := NewDagReader(ctx, nd, serv)
+	if err != nil {
+		return "", err
+	}
+	defer dr.Close()
+
+	return DetectContentType(name, dr)
+}
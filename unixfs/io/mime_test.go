@@ -0,0 +1,39 @@
+package io
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	testu "github.com/ipfs/go-ipfs/unixfs/test"
+
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+func TestContentType(t *testing.T) {
+	dserv := testu.GetDAGServ()
+	ctx := context.Background()
+
+	html := testu.GetNode(t, dserv, []byte("<!DOCTYPE html><html><body>hi</body></html>"))
+	png := testu.GetNode(t, dserv, []byte("\x89PNG\x0D\x0A\x1A\x0A rest of the image"))
+
+	cases := []struct {
+		name  string
+		nd    node.Node
+		ctype string
+	}{
+		{"QmSomeCid", html, "text/html; charset=utf-8"},
+		{"image", png, "image/png"},
+		// the extension wins
+		{"style.css", html, "text/css; charset=utf-8"},
+	}
+	for _, c := range cases {
+		ctype, err := ContentType(ctx, c.name, c.nd, dserv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.EqualFold(ctype, c.ctype) {
+			t.Errorf("%s: expected %q, got %q", c.name, c.ctype, ctype)
+		}
+	}
+}
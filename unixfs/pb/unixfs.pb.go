@@ -10,6 +10,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	Data
+	UnixTime
 	Metadata
 */
 package unixfs_pb
@@ -75,6 +76,7 @@ type Data struct {
 	Blocksizes       []uint64       `protobuf:"varint,4,rep,name=blocksizes" json:"blocksizes,omitempty"`
 	HashType         *uint64        `protobuf:"varint,5,opt,name=hashType" json:"hashType,omitempty"`
 	Fanout           *uint64        `protobuf:"varint,6,opt,name=fanout" json:"fanout,omitempty"`
+	Mtime            *UnixTime      `protobuf:"bytes,8,opt,name=mtime" json:"mtime,omitempty"`
 	XXX_unrecognized []byte         `json:"-"`
 }
 
@@ -124,6 +126,37 @@ func (m *Data) GetFanout() uint64 {
 	return 0
 }
 
+func (m *Data) GetMtime() *UnixTime {
+	if m != nil {
+		return m.Mtime
+	}
+	return nil
+}
+
+type UnixTime struct {
+	Seconds               *int64  `protobuf:"varint,1,req,name=Seconds" json:"Seconds,omitempty"`
+	FractionalNanoseconds *uint32 `protobuf:"fixed32,2,opt,name=FractionalNanoseconds" json:"FractionalNanoseconds,omitempty"`
+	XXX_unrecognized      []byte  `json:"-"`
+}
+
+func (m *UnixTime) Reset()         { *m = UnixTime{} }
+func (m *UnixTime) String() string { return proto.CompactTextString(m) }
+func (*UnixTime) ProtoMessage()    {}
+
+func (m *UnixTime) GetSeconds() int64 {
+	if m != nil && m.Seconds != nil {
+		return *m.Seconds
+	}
+	return 0
+}
+
+func (m *UnixTime) GetFractionalNanoseconds() uint32 {
+	if m != nil && m.FractionalNanoseconds != nil {
+		return *m.FractionalNanoseconds
+	}
+	return 0
+}
+
 type Metadata struct {
 	MimeType         *string `protobuf:"bytes,1,opt,name=MimeType" json:"MimeType,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
@@ -142,6 +175,7 @@ func (m *Metadata) GetMimeType() string {
 
 func init() {
 	proto.RegisterType((*Data)(nil), "unixfs.pb.Data")
+	proto.RegisterType((*UnixTime)(nil), "unixfs.pb.UnixTime")
 	proto.RegisterType((*Metadata)(nil), "unixfs.pb.Metadata")
 	proto.RegisterEnum("unixfs.pb.Data_DataType", Data_DataType_name, Data_DataType_value)
 }